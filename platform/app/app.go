@@ -0,0 +1,210 @@
+// Package app bootstraps the HTTP services of the repository. An Application opens
+// the database, builds the router, runs start hooks and background workers, serves
+// until it is told to stop, and then shuts everything down in reverse order.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultAddr            = ":8080"
+	DefaultStartTimeout    = 10 * time.Second
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// Hook runs at a point of the application's lifecycle
+type Hook func(ctx context.Context) error
+
+// Options configure an Application. Only Router is required.
+type Options struct {
+	// Name identifies the service in logs
+	Name string
+	// Addr is where Run listens, DefaultAddr if empty
+	Addr string
+	// OpenDB opens the SQL database, which is pinged before use and closed last.
+	// Services without one, or with another kind of store, leave it nil and manage
+	// their store with hooks.
+	OpenDB func(ctx context.Context) (*sql.DB, error)
+	// Router builds the handler, once the database is open
+	Router func(a *Application) (http.Handler, error)
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
+
+	// StartTimeout bounds opening the database
+	StartTimeout time.Duration
+	// ShutdownTimeout bounds draining the requests in flight and the stop hooks
+	ShutdownTimeout time.Duration
+
+	// OnStart hooks run in order before the server accepts connections, an error
+	// aborts the start
+	OnStart []Hook
+	// Background workers run while the server does, their context is cancelled on
+	// shutdown and they are waited for before the stop hooks
+	Background []func(ctx context.Context)
+	// OnStop hooks run in reverse order after the server stopped
+	OnStop []Hook
+}
+
+// Application is a service assembled from Options
+type Application struct {
+	Name   string
+	DB     *sql.DB
+	Router http.Handler
+	Logger *slog.Logger
+
+	opts Options
+}
+
+// NewApplication opens the database and builds the router. The database is closed
+// again if the router can't be built.
+func NewApplication(opts Options) (*Application, error) {
+	if opts.Router == nil {
+		return nil, errors.New("app: Options.Router is required")
+	}
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
+	if opts.StartTimeout <= 0 {
+		opts.StartTimeout = DefaultStartTimeout
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	a := &Application{Name: opts.Name, Logger: opts.Logger, opts: opts}
+
+	if opts.OpenDB != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), opts.StartTimeout)
+		defer cancel()
+		db, err := opts.OpenDB(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("app: opening database: %w", err)
+		}
+		if err := db.PingContext(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("app: connecting to database: %w", err)
+		}
+		a.DB = db
+	}
+
+	router, err := opts.Router(a)
+	if err != nil {
+		a.closeDB()
+		return nil, fmt.Errorf("app: building router: %w", err)
+	}
+	a.Router = router
+	return a, nil
+}
+
+// Run listens on the configured address and serves until ctx is done or the process
+// gets SIGINT or SIGTERM
+func (a *Application) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", a.opts.Addr)
+	if err != nil {
+		a.closeDB()
+		return err
+	}
+	return a.Serve(ctx, ln)
+}
+
+// Serve runs the start hooks and serves on ln until ctx is done. It then stops
+// accepting connections, waits up to the shutdown timeout for the requests in flight
+// and the background workers, runs the stop hooks and closes the database. The
+// errors of every step are returned together.
+func (a *Application) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var errs []error
+	started := 0
+	for _, hook := range a.opts.OnStart {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("app: start hook: %w", err))
+			break
+		}
+		started++
+	}
+
+	if started == len(a.opts.OnStart) {
+		var background sync.WaitGroup
+		for _, worker := range a.opts.Background {
+			background.Add(1)
+			go func(worker func(context.Context)) {
+				defer background.Done()
+				worker(ctx)
+			}(worker)
+		}
+
+		srv := &http.Server{Handler: a.Router, ReadHeaderTimeout: 10 * time.Second}
+		a.Logger.Info("Server starting", "service", a.Name, "addr", ln.Addr().String())
+		if err := a.serve(ctx, srv, ln); err != nil {
+			errs = append(errs, err)
+		}
+		cancel()
+		background.Wait()
+	} else {
+		ln.Close()
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), a.opts.ShutdownTimeout)
+	defer stopCancel()
+	for i := len(a.opts.OnStop) - 1; i >= 0; i-- {
+		if err := a.opts.OnStop[i](stopCtx); err != nil {
+			errs = append(errs, fmt.Errorf("app: stop hook: %w", err))
+		}
+	}
+	if err := a.closeDB(); err != nil {
+		errs = append(errs, fmt.Errorf("app: closing database: %w", err))
+	}
+	a.Logger.Info("Server shut down", "service", a.Name)
+	return errors.Join(errs...)
+}
+
+// serve runs srv on ln until ctx is done, then stops accepting connections and waits
+// for the requests in flight
+func (a *Application) serve(ctx context.Context, srv *http.Server, ln net.Listener) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.opts.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (a *Application) closeDB() error {
+	if a.DB == nil {
+		return nil
+	}
+	return a.DB.Close()
+}
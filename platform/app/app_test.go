@@ -0,0 +1,184 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestServeDrainsRequestsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	a, err := NewApplication(Options{
+		Router: func(*Application) (http.Handler, error) {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				time.Sleep(100 * time.Millisecond)
+				io.WriteString(w, "done")
+			}), nil
+		},
+		ShutdownTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln := listen(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- a.Serve(ctx, ln)
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	cancel()
+	if got := <-body; got != "done" {
+		t.Errorf("Expected the request in flight to complete, got %q", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected a clean shutdown, got %v", err)
+	}
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Errorf("Expected new connections to be refused after shutdown")
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectPing()
+	mock.ExpectClose()
+
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a, err := NewApplication(Options{
+		OpenDB: func(context.Context) (*sql.DB, error) { return db, nil },
+		Router: func(a *Application) (http.Handler, error) {
+			if a.DB != db {
+				t.Errorf("Expected the router to get the open database")
+			}
+			return http.NotFoundHandler(), nil
+		},
+		OnStart: []Hook{hook("start 1"), hook("start 2")},
+		Background: []func(context.Context){func(ctx context.Context) {
+			// the worker only stops when the application does
+			cancel()
+			<-ctx.Done()
+			calls = append(calls, "background")
+		}},
+		OnStop: []Hook{hook("stop 1"), hook("stop 2")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Serve(ctx, listen(t)); err != nil {
+		t.Fatalf("Expected a clean shutdown, got %v", err)
+	}
+	want := []string{"start 1", "start 2", "background", "stop 2", "stop 1"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected hooks to run as %v, got %v", want, calls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected the database to be pinged and closed: %v", err)
+	}
+}
+
+func TestStartHookErrorStops(t *testing.T) {
+	errIndexes := errors.New("indexes")
+	stopped := false
+	a, err := NewApplication(Options{
+		Router: func(*Application) (http.Handler, error) { return http.NotFoundHandler(), nil },
+		OnStart: []Hook{
+			func(context.Context) error { return errIndexes },
+			func(context.Context) error {
+				t.Errorf("Expected the hooks after a failed one not to run")
+				return nil
+			},
+		},
+		Background: []func(context.Context){func(context.Context) {
+			t.Errorf("Expected the workers not to run after a failed start")
+		}},
+		OnStop: []Hook{func(context.Context) error {
+			stopped = true
+			return nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln := listen(t)
+	if err := a.Serve(context.Background(), ln); !errors.Is(err, errIndexes) {
+		t.Errorf("Expected the start hook error, got %v", err)
+	}
+	if !stopped {
+		t.Errorf("Expected the stop hooks to run after a failed start")
+	}
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Errorf("Expected the listener to be closed")
+	}
+}
+
+func TestRouterErrorClosesDB(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectPing()
+	mock.ExpectClose()
+
+	errRoutes := errors.New("routes")
+	_, err = NewApplication(Options{
+		OpenDB: func(context.Context) (*sql.DB, error) { return db, nil },
+		Router: func(*Application) (http.Handler, error) { return nil, errRoutes },
+	})
+	if !errors.Is(err, errRoutes) {
+		t.Errorf("Expected the router error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected the database to be closed: %v", err)
+	}
+}
+
+func TestRequiresRouter(t *testing.T) {
+	if _, err := NewApplication(Options{}); err == nil {
+		t.Errorf("Expected an error without a router")
+	}
+}
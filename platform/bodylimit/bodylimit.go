@@ -0,0 +1,119 @@
+// Package bodylimit guards the request bodies of the HTTP services: MaxBytes rejects
+// bodies over a size with 413 and RequireContentType rejects bodies of other media
+// types with 415. Both come as net/http middleware and, prefixed with Gin, as gin
+// middleware, and both let requests without a body through.
+package bodylimit
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxBytes is the size limit of JSON bodies
+const DefaultMaxBytes = 1 << 20
+
+func tooLarge(limit int64, err error) error {
+	return errs.Wrap(errs.ErrTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit), err)
+}
+
+// Exceeded returns the 413 error for err if it comes from reading a body past the
+// limit of MaxBytes, nil otherwise. Handlers decoding the body check it before
+// reporting a malformed body.
+func Exceeded(err error) error {
+	var maxBytes *http.MaxBytesError
+	if errors.As(err, &maxBytes) {
+		return tooLarge(maxBytes.Limit, err)
+	}
+	return nil
+}
+
+// limitBody rejects a declared Content-Length over limit and wraps the body of r in
+// http.MaxBytesReader, so bodies of unknown length fail once read past limit
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) error {
+	if r.ContentLength > limit {
+		return tooLarge(limit, nil)
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	return nil
+}
+
+// MaxBytes rejects requests whose body is larger than limit bytes
+func MaxBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := limitBody(w, r, limit); err != nil {
+				errs.Write(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinMaxBytes is MaxBytes for gin
+func GinMaxBytes(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := limitBody(c.Writer, c.Request, limit); err != nil {
+			errs.Write(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// checkContentType returns the 415 error unless r has no body or one of types,
+// parameters such as charset are ignored
+func checkContentType(r *http.Request, types []string) error {
+	if !hasBody(r) {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		for _, t := range types {
+			if strings.EqualFold(mediaType, t) {
+				return nil
+			}
+		}
+	}
+	return errs.New(errs.ErrUnsupportedType, "Content-Type must be "+strings.Join(types, " or "))
+}
+
+// RequireContentType rejects requests with a body of none of the media types. As
+// requests without a body pass, it can guard a whole API with its GET and DELETE
+// routes.
+func RequireContentType(types ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := checkContentType(r, types); err != nil {
+				errs.Write(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GinRequireContentType is RequireContentType for gin
+func GinRequireContentType(types ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := checkContentType(c.Request, types); err != nil {
+			errs.Write(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package bodylimit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// echo answers with the body it read, or with the problem details of the failed read
+func echo(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if tooLarge := Exceeded(err); tooLarge != nil {
+			err = tooLarge
+		}
+		errs.Write(w, r, err)
+		return
+	}
+	w.Write(body)
+}
+
+func request(method, body, contentType string) func() *http.Request {
+	return func() *http.Request {
+		req := httptest.NewRequest(method, "/items", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return req
+	}
+}
+
+// streamed hides the length of the body like a chunked request
+func streamed(newRequest func() *http.Request) func() *http.Request {
+	return func() *http.Request {
+		req := newRequest()
+		req.ContentLength = -1
+		req.Body = io.NopCloser(req.Body)
+		return req
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(GinMaxBytes(8), GinRequireContentType("application/json"))
+	router.Any("/items", gin.WrapF(echo))
+
+	handlers := map[string]http.Handler{
+		"net/http": MaxBytes(8)(RequireContentType("application/json")(http.HandlerFunc(echo))),
+		"gin":      router,
+	}
+	tests := []struct {
+		name   string
+		req    func() *http.Request
+		status int
+		detail string
+	}{
+		{"within the limit", request("POST", `{"a":1}`, "application/json"), http.StatusOK, ""},
+		{"charset", request("POST", `{"a":1}`, "application/json; charset=utf-8"), http.StatusOK, ""},
+		{"declared too large", request("POST", `{"a":"long"}`, "application/json"), http.StatusRequestEntityTooLarge, "Request body exceeds 8 bytes"},
+		{"streamed too large", streamed(request("POST", `{"a":"long"}`, "application/json")), http.StatusRequestEntityTooLarge, "Request body exceeds 8 bytes"},
+		{"form", request("POST", "a=1", "application/x-www-form-urlencoded"), http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"no content type", request("PUT", `{"a":1}`, ""), http.StatusUnsupportedMediaType, "Content-Type must be application/json"},
+		{"no body", request("DELETE", "", ""), http.StatusOK, ""},
+	}
+	for name, h := range handlers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, tt.req())
+				if w.Code != tt.status {
+					t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body)
+				}
+				if tt.detail == "" {
+					return
+				}
+				var problem errs.Problem
+				if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Detail != tt.detail {
+					t.Errorf("Expected the detail %q, got %+v (%v)", tt.detail, problem, err)
+				}
+			})
+		}
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	if err := Exceeded(io.ErrUnexpectedEOF); err != nil {
+		t.Errorf("Expected no error for other failures, got %v", err)
+	}
+	err := Exceeded(&http.MaxBytesError{Limit: 8})
+	if errs.Status(err) != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a 413 error, got %v", err)
+	}
+}
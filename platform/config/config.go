@@ -0,0 +1,188 @@
+// Package config reads the settings of the services from layered sources: files,
+// mounted secrets, the environment and flags, each overriding the ones before it.
+//
+// Settings are named by keys like "mongo.conn.url". Every source maps its own names to
+// keys the same way, lowercasing them and turning '_' and '-' into dots, so the key
+// above is set by MONGO_CONN_URL in the environment, by -mongo-conn-url on the command
+// line and by conn_url under mongo in a YAML file.
+//
+// The getters never fail. They record settings that are missing or don't parse, and
+// Err reports them all at once after the service read its configuration:
+//
+//	cfg.Require("mongo.conn.url", "jwt.secret")
+//	timeout := cfg.Duration("request.timeout", 10*time.Second)
+//	if err := cfg.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMissing is the error of a required setting that no source sets
+var ErrMissing = errors.New("missing required setting")
+
+// FieldError is the error of a setting
+type FieldError struct {
+	Key string
+	// Source is the name of the source the value came from, empty if it's missing
+	Source string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config: %s (%s): %v", e.Key, e.Source, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Config holds the settings merged from its sources
+type Config struct {
+	values  map[string]string
+	origins map[string]string
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// New loads the sources in order, later ones overriding the settings of earlier ones.
+// It fails with the errors of all the sources that couldn't be loaded.
+func New(sources ...Source) (*Config, error) {
+	c := &Config{values: make(map[string]string), origins: make(map[string]string)}
+	var errs []error
+	for _, source := range sources {
+		values, err := source.Load()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config: loading %s: %w", source.Name(), err))
+			continue
+		}
+		for name, value := range values {
+			key := Key(name)
+			c.values[key] = value
+			c.origins[key] = source.Name()
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Key returns the key a source name maps to
+func Key(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' {
+			return '.'
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(name)))
+}
+
+// Lookup returns the value of the setting and whether it is set
+func (c *Config) Lookup(key string) (string, bool) {
+	value, ok := c.values[Key(key)]
+	return value, ok
+}
+
+// Source returns the name of the source that set the setting
+func (c *Config) Source(key string) string {
+	return c.origins[Key(key)]
+}
+
+// Require records the settings that aren't set or are empty as missing
+func (c *Config) Require(keys ...string) {
+	for _, key := range keys {
+		if value, ok := c.Lookup(key); !ok || value == "" {
+			c.fail(&FieldError{Key: Key(key), Err: ErrMissing})
+		}
+	}
+}
+
+// String returns the setting, or def if it's not set
+func (c *Config) String(key, def string) string {
+	if value, ok := c.Lookup(key); ok {
+		return value
+	}
+	return def
+}
+
+// Strings returns the comma separated values of the setting, or def if it's not set
+func (c *Config) Strings(key string, def []string) []string {
+	value, ok := c.Lookup(key)
+	if !ok {
+		return def
+	}
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Int returns the setting as an integer, or def if it's not set or invalid
+func (c *Config) Int(key string, def int) int {
+	return parse(c, key, def, strconv.Atoi)
+}
+
+// Float returns the setting as a number, or def if it's not set or invalid
+func (c *Config) Float(key string, def float64) float64 {
+	return parse(c, key, def, func(s string) (float64, error) { return strconv.ParseFloat(s, 64) })
+}
+
+// Bool returns the setting as a boolean, or def if it's not set or invalid
+func (c *Config) Bool(key string, def bool) bool {
+	return parse(c, key, def, strconv.ParseBool)
+}
+
+// Duration returns the setting as a duration like "1m30s", or def if it's not set or
+// invalid
+func (c *Config) Duration(key string, def time.Duration) time.Duration {
+	return parse(c, key, def, time.ParseDuration)
+}
+
+// Check records err as the error of the setting unless it's nil. It lets services
+// validate values beyond their type, like a duration that must be positive.
+func (c *Config) Check(key string, err error) {
+	if err != nil {
+		c.fail(&FieldError{Key: Key(key), Source: c.Source(key), Err: err})
+	}
+}
+
+// Err returns the errors recorded by the getters, nil if there are none
+func (c *Config) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return errors.Join(c.errs...)
+}
+
+func (c *Config) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+func parse[T any](c *Config, key string, def T, parse func(string) (T, error)) T {
+	value, ok := c.Lookup(key)
+	if !ok || value == "" {
+		return def
+	}
+	v, err := parse(strings.TrimSpace(value))
+	if err != nil {
+		c.fail(&FieldError{Key: Key(key), Source: c.Source(key), Err: fmt.Errorf("invalid value %q", value)})
+		return def
+	}
+	return v
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLayers(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := writeFile(t, dir, "config.yaml", `
+mongo:
+  conn_url: mongodb://yaml
+  database: inventory
+request:
+  timeout: 5s
+cors:
+  origins: [http://a, http://b]
+`)
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.Mkdir(secrets, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, secrets, "jwt_secret", "s3cret\n")
+	t.Setenv("TEST_MONGO_CONN_URL", "mongodb://env")
+	t.Setenv("TEST_REQUEST_TIMEOUT", "7s")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.String("request-timeout", "1s", "")
+	flags.Int("quota", 100, "")
+	if err := flags.Parse([]string{"-request-timeout=9s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := New(Map(map[string]string{"quota": "50"}), YAMLFile(yamlPath), SecretsDir(secrets), Env("TEST_"), Flags(flags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.String("mongo.conn.url", ""); got != "mongodb://env" {
+		t.Errorf("Expected the environment to override the file, got %q", got)
+	}
+	if got := cfg.String("mongo.database", ""); got != "inventory" {
+		t.Errorf("Expected the nested YAML setting, got %q", got)
+	}
+	if got := cfg.Duration("request.timeout", 0); got != 9*time.Second {
+		t.Errorf("Expected the flag to override the environment, got %v", got)
+	}
+	if got := cfg.Int("quota", 0); got != 50 {
+		t.Errorf("Expected a flag left at its default not to override, got %d", got)
+	}
+	if got := cfg.String("jwt.secret", ""); got != "s3cret" {
+		t.Errorf("Expected the secret without its newline, got %q", got)
+	}
+	if got := cfg.Strings("cors.origins", nil); strings.Join(got, " ") != "http://a http://b" {
+		t.Errorf("Expected the YAML list, got %v", got)
+	}
+	if got := cfg.Source("REQUEST_TIMEOUT"); got != "flags" {
+		t.Errorf("Expected the timeout to come from the flags, got %q", got)
+	}
+	if err := cfg.Err(); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestEnvSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEST_DB_PASSWORD_FILE", writeFile(t, dir, "password", "hunter2\n"))
+	t.Setenv("TEST_API_KEY", "from-env")
+	t.Setenv("TEST_API_KEY_FILE", writeFile(t, dir, "key", "from-file"))
+
+	cfg, err := New(Env("TEST_"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.String("db.password", ""); got != "hunter2" {
+		t.Errorf("Expected the password from its file, got %q", got)
+	}
+	if got := cfg.String("api.key", ""); got != "from-env" {
+		t.Errorf("Expected the variable to win over its file, got %q", got)
+	}
+
+	t.Setenv("TEST_MISSING_FILE", filepath.Join(dir, "missing"))
+	if _, err := New(Env("TEST_")); err == nil || !strings.Contains(err.Error(), "TEST_MISSING_FILE") {
+		t.Errorf("Expected an error for the missing secret file, got %v", err)
+	}
+}
+
+func TestErrorsAreAggregated(t *testing.T) {
+	cfg, err := New(Map(map[string]string{"port": "http", "debug": "maybe", "timeout": "-1s", "name": ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Require("name", "jwt.secret")
+	if got := cfg.Int("port", 8080); got != 8080 {
+		t.Errorf("Expected the default for an invalid value, got %d", got)
+	}
+	cfg.Bool("debug", false)
+	if timeout := cfg.Duration("timeout", time.Second); timeout <= 0 {
+		cfg.Check("timeout", errors.New("must be positive"))
+	}
+
+	err = cfg.Err()
+	var fieldErrs []*FieldError
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fieldErr *FieldError
+		if !errors.As(e, &fieldErr) {
+			t.Fatalf("Expected field errors, got %v", e)
+		}
+		fieldErrs = append(fieldErrs, fieldErr)
+	}
+	if len(fieldErrs) != 5 {
+		t.Fatalf("Expected 5 errors, got %v", err)
+	}
+	if !errors.Is(fieldErrs[0], ErrMissing) || fieldErrs[1].Key != "jwt.secret" {
+		t.Errorf("Expected the missing settings first, got %v", err)
+	}
+	if want := `config: port (defaults): invalid value "http"`; fieldErrs[2].Error() != want {
+		t.Errorf("Expected %q, got %q", want, fieldErrs[2].Error())
+	}
+}
+
+func TestOptional(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(YAMLFile(filepath.Join(dir, "missing.yaml"))); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	cfg, err := New(Optional(YAMLFile(filepath.Join(dir, "missing.yaml"))), Optional(SecretsDir(filepath.Join(dir, "secrets"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Lookup("anything"); ok {
+		t.Error("Expected no settings")
+	}
+
+	bad := writeFile(t, dir, "bad.yaml", "mongo: [")
+	if _, err := New(Optional(YAMLFile(bad))); err == nil {
+		t.Error("Expected an optional file to fail when it doesn't parse")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "config.yaml", "http:\n  addr: \":9000\"\nname: yaml\n")
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	writeFile(t, dir, ".env", "NAME=dotenv\nLEVEL=debug\n")
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Load(flags, []string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.String("http.addr", ""); got != ":9000" {
+		t.Errorf("Expected the address from the file, got %q", got)
+	}
+	if got := cfg.String("name", ""); got != "yaml" {
+		t.Errorf("Expected the file to override .env, got %q", got)
+	}
+	if got := cfg.String("level", ""); got != "debug" {
+		t.Errorf("Expected the .env setting, got %q", got)
+	}
+	if _, ok := os.LookupEnv("LEVEL"); ok {
+		t.Error("Expected .env not to be loaded into the environment")
+	}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "flag"
+
+// DefaultSecretsDir is where secrets are mounted in containers
+const DefaultSecretsDir = "/run/secrets"
+
+// Load parses args with flags and loads the configuration the services share, from
+// lowest to highest precedence:
+//
+//   - a .env file in the working directory, if there is one
+//   - the YAML file named by the -config flag, which Load defines
+//   - the secrets in DefaultSecretsDir, if it exists
+//   - the environment
+//   - the flags set in args
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	path := flags.String("config", "", "YAML configuration `file`")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	sources := []Source{Optional(DotEnvFile(".env"))}
+	if *path != "" {
+		sources = append(sources, YAMLFile(*path))
+	}
+	sources = append(sources, Optional(SecretsDir(DefaultSecretsDir)), Env(""), Flags(flags))
+	return New(sources...)
+}
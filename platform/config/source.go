@@ -0,0 +1,174 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is a layer of settings
+type Source interface {
+	// Name describes the source in errors
+	Name() string
+	// Load returns the settings of the source by name
+	Load() (map[string]string, error)
+}
+
+type source struct {
+	name string
+	load func() (map[string]string, error)
+}
+
+func (s source) Name() string                     { return s.name }
+func (s source) Load() (map[string]string, error) { return s.load() }
+
+// Map is a source of fixed settings, for defaults and tests
+func Map(values map[string]string) Source {
+	return source{"defaults", func() (map[string]string, error) { return values, nil }}
+}
+
+// Env reads the environment variables starting with prefix, which is stripped from
+// their names. A variable NAME_FILE holding the path of a file sets NAME to the
+// content of the file, so secrets can be mounted instead of passed in the environment.
+// NAME itself wins if both are set.
+func Env(prefix string) Source {
+	return source{"env", func() (map[string]string, error) {
+		values := make(map[string]string)
+		files := make(map[string]string)
+		for _, kv := range os.Environ() {
+			name, value, _ := strings.Cut(kv, "=")
+			name, ok := strings.CutPrefix(name, prefix)
+			if !ok || name == "" {
+				continue
+			}
+			if secret, ok := strings.CutSuffix(name, "_FILE"); ok && secret != "" {
+				files[secret] = value
+				continue
+			}
+			values[name] = value
+		}
+
+		var errs []error
+		for name, path := range files {
+			if _, ok := values[name]; ok {
+				continue
+			}
+			value, err := readSecret(path)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s_FILE: %w", prefix+name, err))
+				continue
+			}
+			values[name] = value
+		}
+		return values, errors.Join(errs...)
+	}}
+}
+
+// Flags reads the flags set on the command line. Flags left at their defaults don't
+// override the other sources, so they must be parsed before the configuration loads.
+func Flags(flags *flag.FlagSet) Source {
+	return source{"flags", func() (map[string]string, error) {
+		values := make(map[string]string)
+		flags.Visit(func(f *flag.Flag) {
+			values[f.Name] = f.Value.String()
+		})
+		return values, nil
+	}}
+}
+
+// YAMLFile reads a YAML file. Nested mappings are joined into keys with dots and
+// sequences into comma separated values.
+func YAMLFile(path string) Source {
+	return source{path, func() (map[string]string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var doc map[string]any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		values := make(map[string]string)
+		flatten(values, "", doc)
+		return values, nil
+	}}
+}
+
+func flatten(values map[string]string, prefix string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, child := range v {
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flatten(values, name, child)
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+}
+
+// DotEnvFile reads a .env file of NAME=value lines. Unlike godotenv.Load it doesn't
+// touch the environment of the process.
+func DotEnvFile(path string) Source {
+	return source{path, func() (map[string]string, error) {
+		return godotenv.Read(path)
+	}}
+}
+
+// SecretsDir reads a directory of mounted secrets, one per file, named after the file.
+// Trailing newlines are trimmed from the values.
+func SecretsDir(dir string) Source {
+	return source{dir, func() (map[string]string, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string]string)
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			value, err := readSecret(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			values[entry.Name()] = value
+		}
+		return values, nil
+	}}
+}
+
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Optional makes a file source optional: a missing file loads no settings
+func Optional(s Source) Source {
+	return source{s.Name(), func() (map[string]string, error) {
+		values, err := s.Load()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return values, err
+	}}
+}
@@ -0,0 +1,182 @@
+// Package cors lets browsers call the HTTP services from other origins. Middleware and
+// Gin answer the preflight requests themselves and add the CORS headers to requests
+// from allowed origins. Requests from other origins are passed on without CORS
+// headers, so browsers block the response, and their preflights get 403.
+//
+// A Config is applied per handler, so routes can differ in what they allow:
+//
+//	public := cors.Middleware(cors.Config{AllowedOrigins: []string{"*"}})
+//	private := cors.Middleware(cors.Config{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true})
+//	mux.Handle("/status", public(status))
+//	mux.Handle("/users", private(users))
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"awesomeProject/platform/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Config is what a handler allows
+type Config struct {
+	// AllowedOrigins are exact origins, "*" for any origin, or patterns with one
+	// wildcard such as "https://*.example.com"
+	AllowedOrigins []string
+	// AllowedMethods default to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders are the request headers clients may send, "*" allows any.
+	// They default to Content-Type, Authorization and X-Request-ID.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// policy is a Config with its defaults applied
+type policy struct {
+	Config
+	methods string
+	exposed string
+}
+
+func newPolicy(cfg Config) *policy {
+	if cfg.AllowedMethods == nil {
+		cfg.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	if cfg.AllowedHeaders == nil {
+		cfg.AllowedHeaders = []string{"Content-Type", "Authorization", logging.RequestIDHeader}
+	}
+	return &policy{Config: cfg, methods: strings.Join(cfg.AllowedMethods, ", "), exposed: strings.Join(cfg.ExposedHeaders, ", ")}
+}
+
+// apply sets the CORS headers of the response to r. It returns true when r goes on
+// to the handler, false when the preflight was answered, and the 403 error of a
+// preflight asking for more than allowed.
+func (p *policy) apply(w http.ResponseWriter, r *http.Request) (bool, error) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true, nil
+	}
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if preflight {
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+	}
+
+	allowed, wildcard := p.allowsOrigin(origin)
+	if !allowed {
+		if preflight {
+			return false, errs.New(errs.ErrForbidden, "Origin "+origin+" is not allowed")
+		}
+		return true, nil
+	}
+
+	// the wildcard cannot be combined with credentials, the origin is echoed instead
+	if wildcard && !p.AllowCredentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if p.exposed != "" {
+			h.Set("Access-Control-Expose-Headers", p.exposed)
+		}
+		return true, nil
+	}
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	if !containsFold(p.AllowedMethods, method) {
+		return false, errs.New(errs.ErrForbidden, "Method "+method+" is not allowed")
+	}
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header != "" && !containsFold(p.AllowedHeaders, "*") && !containsFold(p.AllowedHeaders, header) {
+			return false, errs.New(errs.ErrForbidden, "Header "+header+" is not allowed")
+		}
+	}
+
+	h.Set("Access-Control-Allow-Methods", p.methods)
+	if requested != "" {
+		h.Set("Access-Control-Allow-Headers", requested)
+	}
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return false, nil
+}
+
+// allowsOrigin also reports whether the origin matched the "*" wildcard
+func (p *policy) allowsOrigin(origin string) (allowed, wildcard bool) {
+	for _, pattern := range p.AllowedOrigins {
+		if pattern == "*" {
+			return true, true
+		}
+		if prefix, suffix, ok := strings.Cut(pattern, "*"); ok {
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true, false
+			}
+		} else if strings.EqualFold(pattern, origin) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware applies cfg to the requests of next
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	p := newPolicy(cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pass, err := p.apply(w, r)
+			if err != nil {
+				errs.Write(w, r, err)
+			}
+			if pass {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// Gin is Middleware for gin. Use it on the engine rather than on a group, gin only
+// runs the middleware of a group for the routes registered with it, and those rarely
+// include OPTIONS.
+func Gin(cfg Config) gin.HandlerFunc {
+	p := newPolicy(cfg)
+	return func(c *gin.Context) {
+		pass, err := p.apply(c.Writer, c.Request)
+		if err != nil {
+			errs.Write(c.Writer, c.Request, err)
+		}
+		if !pass {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
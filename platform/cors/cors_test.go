@@ -0,0 +1,162 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var private = Config{
+	AllowedOrigins:   []string{"https://*.example.com", "https://app.test"},
+	AllowedMethods:   []string{"GET", "PUT"},
+	ExposedHeaders:   []string{"X-RateLimit-Remaining"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]func(Config) http.Handler{
+		"net/http": func(cfg Config) http.Handler {
+			return Middleware(cfg)(http.HandlerFunc(ok))
+		},
+		"gin": func(cfg Config) http.Handler {
+			router := gin.New()
+			router.Use(Gin(cfg))
+			router.Any("/", gin.WrapF(ok))
+			return router
+		},
+	}
+	public := Config{AllowedOrigins: []string{"*"}}
+	tests := []struct {
+		name        string
+		cfg         Config
+		method      string
+		headers     map[string]string
+		status      int
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "no origin",
+			cfg:         public,
+			method:      "GET",
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "any origin",
+			cfg:         public,
+			method:      "GET",
+			headers:     map[string]string{"Origin": "https://evil.test"},
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*", "Vary": "Origin"},
+		},
+		{
+			name:    "wildcard subdomain with credentials",
+			cfg:     private,
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://api.example.com"},
+			status:  http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://api.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-RateLimit-Remaining",
+			},
+		},
+		{
+			name:        "wildcard does not match the bare domain",
+			cfg:         private,
+			method:      "GET",
+			headers:     map[string]string{"Origin": "https://example.com"},
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:    "preflight",
+			cfg:     private,
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type, authorization"},
+			status:  http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.test",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "content-type, authorization",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:    "preflight for a method not allowed",
+			cfg:     private,
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "DELETE"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "preflight for a header not allowed",
+			cfg:     private,
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Custom"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "preflight from an origin not allowed",
+			cfg:     private,
+			method:  "OPTIONS",
+			headers: map[string]string{"Origin": "https://evil.test", "Access-Control-Request-Method": "GET"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:        "plain OPTIONS request reaches the handler",
+			cfg:         private,
+			method:      "OPTIONS",
+			headers:     map[string]string{"Origin": "https://app.test"},
+			status:      http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+	}
+
+	for name, newHandler := range handlers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, "/", nil)
+				for key, value := range tt.headers {
+					req.Header.Set(key, value)
+				}
+				w := httptest.NewRecorder()
+				newHandler(tt.cfg).ServeHTTP(w, req)
+
+				if w.Code != tt.status {
+					t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+				}
+				for key, want := range tt.wantHeaders {
+					if got := w.Header().Get(key); got != want {
+						t.Errorf("Expected %s %q, got %q", key, want, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestGinPreflightWithoutRoute covers the usual gin setup, where no route handles OPTIONS
+func TestGinPreflightWithoutRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Gin(private))
+	router.PUT("/items", gin.WrapF(ok))
+
+	req := httptest.NewRequest("OPTIONS", "/items", nil)
+	req.Header.Set("Origin", "https://app.test")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Methods") != "GET, PUT" {
+		t.Errorf("Expected the preflight to be answered, got %d %v", w.Code, w.Header())
+	}
+}
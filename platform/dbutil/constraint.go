@@ -0,0 +1,27 @@
+package dbutil
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// mysqlDuplicateEntry is the MySQL error number of a unique key violation
+const mysqlDuplicateEntry = 1062
+
+// IsUniqueViolation reports whether err is the violation of a unique key or index, as
+// when inserting a row that duplicates another
+func IsUniqueViolation(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDuplicateEntry
+	}
+	// PostgreSQL drivers report the SQLSTATE
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		return stateErr.SQLState() == "23505" // unique_violation
+	}
+	// SQLite only tells by the message, unless the driver's types are imported
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
@@ -0,0 +1,134 @@
+// Package dbutil wraps database/sql for the SQL services: transactions that commit
+// or roll back on their own, retries of transient failures, named placeholders
+// rewritten for the dialect of the database, and hooks that observe every query.
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Querier runs queries, on the database or in a transaction
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// QueryHook is called after every query with its duration and error. The error of
+// QueryRowContext is only known once the row is scanned, hooks get nil until then.
+type QueryHook func(ctx context.Context, query string, args []any, elapsed time.Duration, err error)
+
+// DB is a database of a dialect whose queries are observed by hooks
+type DB struct {
+	db      *sql.DB
+	dialect Dialect
+	hooks   []QueryHook
+}
+
+// New wraps db, calling hooks after every query
+func New(db *sql.DB, dialect Dialect, hooks ...QueryHook) *DB {
+	return &DB{db: db, dialect: dialect, hooks: hooks}
+}
+
+// SQL returns the wrapped database
+func (d *DB) SQL() *sql.DB {
+	return d.db
+}
+
+func (d *DB) Dialect() Dialect {
+	return d.dialect
+}
+
+func (d *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return exec(ctx, d.db, d.hooks, query, args)
+}
+
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return queryRows(ctx, d.db, d.hooks, query, args)
+}
+
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return queryRow(ctx, d.db, d.hooks, query, args)
+}
+
+// Tx is a transaction whose queries are observed by the hooks of its DB
+type Tx struct {
+	tx *sql.Tx
+	db *DB
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return exec(ctx, t.tx, t.db.hooks, query, args)
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return queryRows(ctx, t.tx, t.db.hooks, query, args)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return queryRow(ctx, t.tx, t.db.hooks, query, args)
+}
+
+// WithTx runs fn in a transaction, which is committed if fn returns nil and rolled
+// back if it fails or panics
+func WithTx(ctx context.Context, db *DB, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	tx := &Tx{tx: sqlTx, db: db}
+	defer func() {
+		if p := recover(); p != nil {
+			sqlTx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			sqlTx.Rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// WithTxRetry runs fn in a transaction like WithTx, starting over in a new one when
+// the policy retries the failure. fn must not have effects outside the transaction.
+func WithTxRetry(ctx context.Context, db *DB, policy RetryPolicy, fn func(tx *Tx) error) error {
+	return policy.Do(ctx, func(ctx context.Context) error {
+		return WithTx(ctx, db, fn)
+	})
+}
+
+func exec(ctx context.Context, q Querier, hooks []QueryHook, query string, args []any) (sql.Result, error) {
+	start := time.Now()
+	result, err := q.ExecContext(ctx, query, args...)
+	observe(ctx, hooks, query, args, start, err)
+	return result, err
+}
+
+func queryRows(ctx context.Context, q Querier, hooks []QueryHook, query string, args []any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args...)
+	observe(ctx, hooks, query, args, start, err)
+	return rows, err
+}
+
+func queryRow(ctx context.Context, q Querier, hooks []QueryHook, query string, args []any) *sql.Row {
+	start := time.Now()
+	row := q.QueryRowContext(ctx, query, args...)
+	observe(ctx, hooks, query, args, start, row.Err())
+	return row
+}
+
+func observe(ctx context.Context, hooks []QueryHook, query string, args []any, start time.Time, err error) {
+	if len(hooks) == 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	for _, hook := range hooks {
+		hook(ctx, query, args, elapsed, err)
+	}
+}
@@ -0,0 +1,285 @@
+package dbutil
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func newMock(t *testing.T, hooks ...QueryHook) (*DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return New(db, MySQL, hooks...), mock
+}
+
+func TestWithTx(t *testing.T) {
+	ctx := context.Background()
+	insert := func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO users (name) VALUES (?)", "ann")
+		return err
+	}
+
+	t.Run("commits", func(t *testing.T) {
+		db, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO users (name) VALUES (?)").WithArgs("ann").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		if err := WithTx(ctx, db, insert); err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rolls back on error", func(t *testing.T) {
+		db, mock := newMock(t)
+		errExists := errors.New("exists")
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		err := WithTx(ctx, db, func(tx *Tx) error { return errExists })
+		if !errors.Is(err, errExists) {
+			t.Errorf("Expected the error of fn, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rolls back on panic", func(t *testing.T) {
+		db, mock := newMock(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected the panic to be passed on")
+				}
+			}()
+			WithTx(ctx, db, func(tx *Tx) error { panic("boom") })
+		}()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestWithTxRetry(t *testing.T) {
+	ctx := context.Background()
+	db, mock := newMock(t)
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE stock SET units = units - 1").WillReturnError(deadlock)
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE stock SET units = units - 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	attempts := 0
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+	err := WithTxRetry(ctx, db, policy, func(tx *Tx) error {
+		attempts++
+		_, err := tx.ExecContext(ctx, "UPDATE stock SET units = units - 1")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	t.Run("gives up after the attempts", func(t *testing.T) {
+		attempts := 0
+		err := policy.Do(ctx, func(context.Context) error {
+			attempts++
+			return driver.ErrBadConn
+		})
+		if !errors.Is(err, driver.ErrBadConn) || attempts != 3 {
+			t.Errorf("Expected 3 failed attempts, got %d with %v", attempts, err)
+		}
+	})
+
+	t.Run("doesn't retry permanent errors", func(t *testing.T) {
+		attempts := 0
+		policy.Do(ctx, func(context.Context) error {
+			attempts++
+			return &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+		})
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		attempts := 0
+		policy := RetryPolicy{Attempts: 3, Backoff: time.Hour}
+		policy.Do(ctx, func(context.Context) error {
+			attempts++
+			cancel()
+			return driver.ErrBadConn
+		})
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+}
+
+type pgError string
+
+func (e pgError) Error() string    { return "pq: " + string(e) }
+func (e pgError) SQLState() string { return string(e) }
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1213}, true},
+		{&mysql.MySQLError{Number: 1205}, true},
+		{&mysql.MySQLError{Number: 1062}, false},
+		{pgError("40001"), true},
+		{pgError("23505"), false},
+		{driver.ErrBadConn, true},
+		{errors.New("syntax error"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&mysql.MySQLError{Number: 1062}, true},
+		{fmt.Errorf("inserting: %w", &mysql.MySQLError{Number: 1062}), true},
+		{&mysql.MySQLError{Number: 1213}, false},
+		{pgError("23505"), true},
+		{pgError("40001"), false},
+		{errors.New("UNIQUE constraint failed: products.name"), true},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsUniqueViolation(tt.err); got != tt.want {
+			t.Errorf("IsUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNamed(t *testing.T) {
+	args := map[string]any{"name": "ann", "id": 7}
+	tests := []struct {
+		dialect   Dialect
+		query     string
+		wantQuery string
+		wantArgs  []any
+	}{
+		{MySQL, "UPDATE users SET name = :name WHERE id = :id", "UPDATE users SET name = ? WHERE id = ?", []any{"ann", 7}},
+		{Postgres, "UPDATE users SET name = :name WHERE id = :id OR parent = :id", "UPDATE users SET name = $1 WHERE id = $2 OR parent = $3", []any{"ann", 7, 7}},
+		{Postgres, "SELECT ':name', created::date FROM users WHERE id = :id", "SELECT ':name', created::date FROM users WHERE id = $1", []any{7}},
+		{SQLite, "SELECT `a:b` FROM t WHERE x = :name", "SELECT `a:b` FROM t WHERE x = ?", []any{"ann"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.String(), func(t *testing.T) {
+			query, got, err := tt.dialect.Named(tt.query, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query != tt.wantQuery || !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("Expected %q %v, got %q %v", tt.wantQuery, tt.wantArgs, query, got)
+			}
+		})
+	}
+
+	if _, _, err := MySQL.Named("SELECT :missing", args); err == nil {
+		t.Errorf("Expected an error for a missing argument")
+	}
+	if _, _, err := MySQL.Named("SELECT 'open", args); err == nil {
+		t.Errorf("Expected an error for an unterminated quote")
+	}
+}
+
+func TestHooks(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	var queries []string
+	record := func(ctx context.Context, query string, args []any, elapsed time.Duration, err error) {
+		queries = append(queries, query)
+	}
+	db, mock := newMock(t, record, LogHook(logger, time.Hour))
+	ctx := context.Background()
+	mock.ExpectQuery("SELECT name FROM users WHERE id = ?").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("ann"))
+	mock.ExpectExec("DELETE FROM users").WillReturnError(errors.New("locked"))
+
+	var name string
+	if err := db.QueryRowContext(ctx, "SELECT name FROM users WHERE id = ?", 1).Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	db.ExecContext(ctx, "DELETE FROM users")
+
+	if want := []string{"SELECT name FROM users WHERE id = ?", "DELETE FROM users"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("Expected hooks to see %v, got %v", want, queries)
+	}
+	log := buf.String()
+	if !strings.Contains(log, `level=DEBUG msg=Query query="SELECT name`) || !strings.Contains(log, `level=ERROR msg="Query failed" query="DELETE FROM users"`) {
+		t.Errorf("Unexpected log:\n%s", log)
+	}
+	if strings.Contains(log, "ann") {
+		t.Errorf("Expected arguments to be left out of the log:\n%s", log)
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	metrics := NewQueryMetrics()
+	hook := metrics.Hook(100 * time.Millisecond)
+	ctx := context.Background()
+	hook(ctx, "\n        SELECT id FROM users", nil, 10*time.Millisecond, nil)
+	hook(ctx, "SELECT id FROM users WHERE id = ?", []any{1}, 5*time.Millisecond, sql.ErrNoRows)
+	hook(ctx, "UPDATE users SET name = ?", []any{"ann"}, 300*time.Millisecond, errors.New("locked"))
+
+	stats := metrics.Stats()
+	want := map[string]int64{"select": 2, "update": 1}
+	if stats.Queries != 3 || stats.Errors != 1 || stats.Slow != 1 || !reflect.DeepEqual(stats.Statements, want) {
+		t.Errorf("Unexpected totals %+v", stats)
+	}
+	if stats.MaxSeconds != 0.3 || stats.Seconds < 0.314 || stats.Seconds > 0.316 {
+		t.Errorf("Unexpected durations %+v", stats)
+	}
+
+	var decoded QueryStats
+	if err := json.Unmarshal([]byte(metrics.String()), &decoded); err != nil || decoded.Queries != 3 {
+		t.Errorf("Expected the totals as JSON, got %s (%v)", metrics.String(), err)
+	}
+}
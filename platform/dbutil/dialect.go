@@ -0,0 +1,81 @@
+package dbutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect is the SQL flavor of a database, which decides its placeholders
+type Dialect int
+
+const (
+	// MySQL and SQLite number their placeholders by position: ?
+	MySQL Dialect = iota
+	SQLite
+	// Postgres numbers its placeholders: $1, $2, ...
+	Postgres
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case MySQL:
+		return "mysql"
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	}
+	return "Dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// Placeholder returns the placeholder of the nth argument, counting from 1
+func (d Dialect) Placeholder(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Named rewrites the :name placeholders of query into placeholders of the dialect and
+// returns the arguments in their order. A name used twice is passed twice. Quoted
+// strings and identifiers are left alone, as are PostgreSQL :: casts.
+func (d Dialect) Named(query string, args map[string]any) (string, []any, error) {
+	var b strings.Builder
+	var positional []any
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return "", nil, fmt.Errorf("dbutil: unterminated quote in %q", query)
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			b.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(query) && isNameByte(query[i+1]):
+			end := i + 1
+			for end < len(query) && isNameByte(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+			arg, ok := args[name]
+			if !ok {
+				return "", nil, fmt.Errorf("dbutil: no argument for :%s", name)
+			}
+			positional = append(positional, arg)
+			b.WriteString(d.Placeholder(len(positional)))
+			i = end - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), positional, nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
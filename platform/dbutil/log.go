@@ -0,0 +1,26 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// LogHook logs queries to logger: failures as errors, queries slower than slow as
+// warnings and the rest at debug level. Arguments are left out as they may hold
+// secrets. A missing row is not a failure.
+func LogHook(logger *slog.Logger, slow time.Duration) QueryHook {
+	return func(ctx context.Context, query string, args []any, elapsed time.Duration, err error) {
+		attrs := []any{"query", query, "args", len(args), "elapsed", elapsed}
+		switch {
+		case err != nil && !errors.Is(err, sql.ErrNoRows):
+			logger.ErrorContext(ctx, "Query failed", append(attrs, "error", err)...)
+		case slow > 0 && elapsed >= slow:
+			logger.WarnContext(ctx, "Slow query", attrs...)
+		default:
+			logger.DebugContext(ctx, "Query", attrs...)
+		}
+	}
+}
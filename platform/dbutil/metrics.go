@@ -0,0 +1,74 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryMetrics aggregates the queries seen by its hooks. It is an expvar.Var, so a
+// service can publish it with expvar.Publish and read it at /debug/vars.
+type QueryMetrics struct {
+	mu    sync.Mutex
+	stats QueryStats
+}
+
+// QueryStats are the totals of a QueryMetrics
+type QueryStats struct {
+	Queries int64 `json:"queries"`
+	// Errors leaves out missing rows, like LogHook
+	Errors int64 `json:"errors"`
+	Slow   int64 `json:"slow"`
+	// Seconds is the time spent in queries, MaxSeconds the longest query
+	Seconds    float64 `json:"seconds"`
+	MaxSeconds float64 `json:"max_seconds"`
+	// Statements counts the queries by their first keyword, like select or insert
+	Statements map[string]int64 `json:"statements"`
+}
+
+func NewQueryMetrics() *QueryMetrics {
+	return &QueryMetrics{stats: QueryStats{Statements: make(map[string]int64)}}
+}
+
+// Hook counts the queries, those taking slow or longer as slow
+func (m *QueryMetrics) Hook(slow time.Duration) QueryHook {
+	return func(ctx context.Context, query string, args []any, elapsed time.Duration, err error) {
+		statement, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+		seconds := elapsed.Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.stats.Queries++
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			m.stats.Errors++
+		}
+		if slow > 0 && elapsed >= slow {
+			m.stats.Slow++
+		}
+		m.stats.Seconds += seconds
+		m.stats.MaxSeconds = max(m.stats.MaxSeconds, seconds)
+		m.stats.Statements[strings.ToLower(statement)]++
+	}
+}
+
+// Stats returns a copy of the totals
+func (m *QueryMetrics) Stats() QueryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := m.stats
+	stats.Statements = make(map[string]int64, len(m.stats.Statements))
+	for k, v := range m.stats.Statements {
+		stats.Statements[k] = v
+	}
+	return stats
+}
+
+// String returns the totals as JSON, for expvar
+func (m *QueryMetrics) String() string {
+	b, _ := json.Marshal(m.Stats())
+	return string(b)
+}
@@ -0,0 +1,80 @@
+package dbutil
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// RetryPolicy retries operations that failed with a retryable error, waiting Backoff
+// before the first retry and twice as long before each next one, up to MaxBackoff
+type RetryPolicy struct {
+	// Attempts is how often the operation runs at most, including the first time
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Retryable decides which errors are retried, IsTransient if nil
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy retries transient failures twice
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Backoff: 50 * time.Millisecond, MaxBackoff: time.Second}
+
+// Do runs fn until it succeeds, fails with an error that isn't retried, runs out of
+// attempts or ctx is done. It returns the last error of fn.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+	}
+}
+
+// MySQL error numbers of transient failures
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// IsTransient reports whether err is a failure that may not happen again: a deadlock,
+// a lock wait timeout, a serialization failure or a broken connection
+func IsTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlDeadlock || mysqlErr.Number == mysqlLockWaitTimeout
+	}
+	// PostgreSQL drivers report the SQLSTATE
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		switch stateErr.SQLState() {
+		case "40001", "40P01", "55P03":
+			// serialization_failure, deadlock_detected, lock_not_available
+			return true
+		}
+	}
+	return false
+}
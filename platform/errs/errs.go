@@ -0,0 +1,142 @@
+// Package errs is the error vocabulary of the HTTP services. Errors are classified by
+// wrapping one of the kinds below, Status maps a kind to its HTTP status and Write
+// responds with an RFC 7807 application/problem+json document.
+//
+// A service declares its own errors on top of the kinds:
+//
+//	var ErrUserNotFound = errs.New(errs.ErrNotFound, "User not found")
+//
+// and its handlers pass every error to Write, which only shows clients the messages
+// given to New, Wrap and Validation. Anything else is reported as an internal error.
+package errs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// The kinds of errors, each with its own status
+var (
+	ErrInvalid         = errors.New("invalid request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooLarge        = errors.New("too large")
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrValidation      = errors.New("validation failed")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrInternal        = errors.New("internal error")
+	ErrUnavailable     = errors.New("unavailable")
+	ErrTimeout         = errors.New("timeout")
+)
+
+var statuses = []struct {
+	kind   error
+	status int
+}{
+	{ErrInvalid, http.StatusBadRequest},
+	{ErrUnauthorized, http.StatusUnauthorized},
+	{ErrForbidden, http.StatusForbidden},
+	{ErrNotFound, http.StatusNotFound},
+	{ErrConflict, http.StatusConflict},
+	{ErrTooLarge, http.StatusRequestEntityTooLarge},
+	{ErrUnsupportedType, http.StatusUnsupportedMediaType},
+	{ErrValidation, http.StatusUnprocessableEntity},
+	{ErrTooManyRequests, http.StatusTooManyRequests},
+	{ErrInternal, http.StatusInternalServerError},
+	{ErrUnavailable, http.StatusServiceUnavailable},
+	{ErrTimeout, http.StatusGatewayTimeout},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout},
+}
+
+// Error is an error of a kind with a message for clients. The cause, if any, is
+// kept for errors.Is and errors.As and for logs, but never shown to clients.
+type Error struct {
+	Kind    error
+	Message string
+	// Fields holds a message per invalid field of a validation error
+	Fields map[string]string
+	Err    error
+}
+
+// New returns an error of kind with message, suitable as a sentinel
+func New(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns an error of kind with message, caused by err
+func Wrap(kind error, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// Validation returns a validation error with a message per invalid field
+func Validation(fields map[string]string) *Error {
+	return &Error{Kind: ErrValidation, Message: "Validation failed", Fields: fields}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// Status returns the HTTP status of the kind of err, 500 if it has none. The kind of
+// the outermost Error wins over the errors it wraps.
+func Status(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		err = e.Kind
+	}
+	for _, s := range statuses {
+		if errors.Is(err, s.kind) {
+			return s.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// ContentType is the media type of problem details
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Fields is an extension member
+// with the invalid fields of a validation error.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// ProblemFor describes err for clients. The detail is the message of the outermost
+// Error, errors without one only get the title of their status.
+func ProblemFor(err error, instance string) Problem {
+	status := Status(err)
+	p := Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Instance: instance}
+	var e *Error
+	if errors.As(err, &e) {
+		p.Detail = e.Message
+		p.Fields = e.Fields
+	}
+	return p
+}
+
+// Write responds to r with the problem details of err
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFor(err, r.URL.Path)
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,95 @@
+package errs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var errUserNotFound = New(ErrNotFound, "User not found")
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"sentinel", errUserNotFound, http.StatusNotFound},
+		{"wrapped sentinel", fmt.Errorf("loading user 7: %w", errUserNotFound), http.StatusNotFound},
+		{"kind", ErrConflict, http.StatusConflict},
+		{"with cause", Wrap(ErrUnavailable, "Database unavailable", errors.New("dial tcp")), http.StatusServiceUnavailable},
+		{"outer kind wins", Wrap(ErrInternal, "Failed to load user", errUserNotFound), http.StatusInternalServerError},
+		{"validation", Validation(map[string]string{"name": "is required"}), http.StatusUnprocessableEntity},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{"untyped", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Status(tt.err); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestErrorKeepsCause(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := Wrap(ErrConflict, "Username already exists", cause)
+	if !errors.Is(err, cause) || !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected the error to match its kind and cause")
+	}
+	if err.Error() != "Username already exists: duplicate key" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "typed",
+			err:  fmt.Errorf("get: %w", errUserNotFound),
+			want: Problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "User not found", Instance: "/users/7"},
+		},
+		{
+			name: "validation",
+			err:  Validation(map[string]string{"price": "must be greater than 0"}),
+			want: Problem{Type: "about:blank", Title: "Unprocessable Entity", Status: 422, Detail: "Validation failed", Instance: "/users/7",
+				Fields: map[string]string{"price": "must be greater than 0"}},
+		},
+		{
+			// the message of untyped errors may reveal internals
+			name: "untyped",
+			err:  errors.New("sql: connection refused"),
+			want: Problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Instance: "/users/7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Write(w, httptest.NewRequest("GET", "/users/7", nil), tt.err)
+
+			if w.Code != tt.want.Status {
+				t.Errorf("Expected status %d, got %d", tt.want.Status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != ContentType {
+				t.Errorf("Expected content type %s, got %s", ContentType, ct)
+			}
+			var got Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package health serves the endpoints deployments and load balancers check:
+// liveness, readiness and the build the process runs.
+//
+// The build is described by Version, Commit and BuildTime, set at link time:
+//
+//	go build -ldflags "-X awesomeProject/platform/health.Commit=$(git rev-parse HEAD) \
+//		-X awesomeProject/platform/health.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Without them the commit and time recorded by the Go toolchain are used, if any.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Set with -ldflags "-X awesomeProject/platform/health.Name=value"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// DefaultTimeout bounds each readiness check
+const DefaultTimeout = 2 * time.Second
+
+// Check reports whether a dependency can be used
+type Check func(ctx context.Context) error
+
+// Pinger is a dependency with a ping, like *sql.DB
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping checks p with its ping
+func Ping(p Pinger) Check {
+	return p.PingContext
+}
+
+// Status is the body of the liveness and readiness responses
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Info is the body of the version response
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// BuildInfo describes the running build
+func BuildInfo() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	return info
+}
+
+// Liveness answers as long as the process serves requests
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Status{Status: "ok"})
+}
+
+// Readiness returns a handler answering 200 when every check passes within timeout,
+// DefaultTimeout if it is zero, and 503 otherwise. The response holds the outcome of
+// each check.
+func Readiness(timeout time.Duration, checks map[string]Check) http.HandlerFunc {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		status := Status{Status: "ok", Checks: make(map[string]string, len(checks))}
+		code := http.StatusOK
+		for name, check := range checks {
+			if err := check(ctx); err != nil {
+				status.Status = "unavailable"
+				status.Checks[name] = err.Error()
+				code = http.StatusServiceUnavailable
+			} else {
+				status.Checks[name] = "ok"
+			}
+		}
+		writeJSON(w, code, status)
+	}
+}
+
+// VersionHandler serves BuildInfo
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, BuildInfo())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"awesomeProject/platform/testkit"
+)
+
+func TestReadiness(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := []struct {
+		name   string
+		checks map[string]Check
+		status int
+		want   map[string]string
+	}{
+		{"ready", map[string]Check{"database": ok}, http.StatusOK, map[string]string{"database": "ok"}},
+		{"failing check", map[string]Check{"database": down, "cache": ok}, http.StatusServiceUnavailable, map[string]string{"database": "connection refused", "cache": "ok"}},
+		{"timeout", map[string]Check{"database": slow}, http.StatusServiceUnavailable, map[string]string{"database": context.DeadlineExceeded.Error()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Readiness(10*time.Millisecond, tt.checks)(w, httptest.NewRequest("GET", "/readyz", nil))
+			if err := testkit.ExpectStatus(w, tt.status); err != nil {
+				t.Error(err)
+			}
+			var got Status
+			if err := testkit.DecodeJSON(w, &got); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got.Checks[name] != want {
+					t.Errorf("Expected %s to be %q, got %q", name, want, got.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	defer func(commit, built string) { Commit, BuildTime = commit, built }(Commit, BuildTime)
+	Commit, BuildTime = "abc123", "2024-05-01T10:00:00Z"
+
+	w := httptest.NewRecorder()
+	VersionHandler(w, httptest.NewRequest("GET", "/version", nil))
+	var got Info
+	if err := testkit.DecodeJSON(w, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Commit != "abc123" || got.BuildTime != "2024-05-01T10:00:00Z" || got.Version != "dev" || got.GoVersion == "" {
+		t.Errorf("Expected the linked build info, got %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	Liveness(w, httptest.NewRequest("GET", "/healthz", nil))
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected an uncached 200 (%v)", err)
+	}
+}
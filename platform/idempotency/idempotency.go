@@ -0,0 +1,343 @@
+// Package idempotency makes POST and PUT requests carrying an Idempotency-Key header
+// safe to retry. The first response per key, route and caller is kept in a Store and
+// replayed to the retries, marked with the Idempotent-Replayed header. Retries that
+// arrive while the first request is still being processed get 409, and reusing a key
+// with a different body gets 422. Server errors and panics release the key, so a
+// retry runs the handler again.
+//
+// Middleware is the net/http middleware and Gin the gin one. Both run after the
+// authentication, which tells them the caller, and let requests without the header
+// through.
+//
+// MemoryStore keeps the responses of a single instance, RedisStore shares them
+// between the instances of a service.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header carries the key the client picked for a request and its retries
+	Header = "Idempotency-Key"
+	// ReplayedHeader marks the responses replayed from the store
+	ReplayedHeader = "Idempotent-Replayed"
+	// DefaultTTL covers client retries spread over a day
+	DefaultTTL   = 24 * time.Hour
+	maxKeyLength = 255
+)
+
+var (
+	errKeyTooLong = errs.New(errs.ErrInvalid, fmt.Sprintf("%s must not be longer than %d characters", Header, maxKeyLength))
+	errKeyReused  = errs.New(errs.ErrValidation, Header+" was already used with a different request body")
+	errInProgress = errs.New(errs.ErrConflict, "A request with this "+Header+" is still being processed")
+)
+
+// Response is a stored response
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Record is what a Store keeps per key
+type Record struct {
+	// Fingerprint is the hash of the request body, a retry must send the same body
+	Fingerprint string `json:"fingerprint"`
+	// Response is nil while the first request is being processed
+	Response *Response `json:"response,omitempty"`
+}
+
+// Store keeps the records. A store shared by the instances of a service lets a retry
+// reach any of them.
+type Store interface {
+	// Reserve stores record under key unless the key is taken, then the stored record
+	// is returned instead. It returns nil when the caller now holds the key.
+	Reserve(ctx context.Context, key string, record *Record, ttl time.Duration) (*Record, error)
+	// Save replaces the record of a reserved key with the completed one
+	Save(ctx context.Context, key string, record *Record, ttl time.Duration) error
+	// Release frees a reserved key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// guard holds the key of a request between reserving it and saving its response
+type guard struct {
+	store       Store
+	ttl         time.Duration
+	key         string
+	fingerprint string
+	// before holds the headers set ahead of the handler, such as the request ID, which
+	// belong to each request and are not stored
+	before http.Header
+}
+
+// reserve takes the key of r for caller. It returns nil without a record when r is
+// not guarded, and the stored record when r is a retry. The body of r is buffered to
+// fingerprint it.
+func reserve(store Store, ttl time.Duration, r *http.Request, caller string) (*guard, *Record, error) {
+	key := r.Header.Get(Header)
+	if key == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut) {
+		return nil, nil, nil
+	}
+	if len(key) > maxKeyLength {
+		return nil, nil, errKeyTooLong
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			if tooLarge := bodylimit.Exceeded(err); tooLarge != nil {
+				return nil, nil, tooLarge
+			}
+			return nil, nil, errs.Wrap(errs.ErrInvalid, "Reading the request body failed", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+
+	if caller == "" {
+		caller = "anonymous"
+	}
+	g := &guard{
+		store:       store,
+		ttl:         ttl,
+		key:         caller + "|" + r.Method + " " + r.URL.Path + "|" + key,
+		fingerprint: hex.EncodeToString(sum[:]),
+	}
+	existing, err := store.Reserve(r.Context(), g.key, &Record{Fingerprint: g.fingerprint}, ttl)
+	if err != nil {
+		// running the request could duplicate it, unlike the rate limits this fails closed
+		return nil, nil, errs.Wrap(errs.ErrUnavailable, "Idempotency store unavailable, retry later", err)
+	}
+	return g, existing, nil
+}
+
+// replay writes the response of record, or returns the error when record is not the
+// completed response to the same body
+func (g *guard) replay(w http.ResponseWriter, record *Record) error {
+	switch {
+	case record.Fingerprint != g.fingerprint:
+		return errKeyReused
+	case record.Response == nil:
+		w.Header().Set("Retry-After", "1")
+		return errInProgress
+	}
+	for name, values := range record.Response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(record.Response.Status)
+	w.Write(record.Response.Body)
+	return nil
+}
+
+// finish saves the response of the handler, or releases the key when there is none
+// to replay: after a server error or a panic, when status is 0
+func (g *guard) finish(ctx context.Context, status int, header http.Header, body []byte) {
+	ctx = context.WithoutCancel(ctx)
+	if status == 0 || status >= 500 {
+		g.store.Release(ctx, g.key)
+		return
+	}
+	// the response is already sent, a failed save only lets a retry run again
+	g.store.Save(ctx, g.key, &Record{
+		Fingerprint: g.fingerprint,
+		Response:    &Response{Status: status, Header: addedHeaders(g.before, header), Body: body},
+	}, g.ttl)
+}
+
+// addedHeaders returns the headers of after that before doesn't have with the same
+// values
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			added[name] = slices.Clone(values)
+		}
+	}
+	return added
+}
+
+// Middleware guards the requests of next with store, keeping the responses for ttl.
+// caller identifies the client of a request, the clients it returns "" for share one
+// scope. A nil store keeps the responses in memory.
+func Middleware(store Store, ttl time.Duration, caller func(*http.Request) string) func(http.Handler) http.Handler {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			g, existing, err := reserve(store, ttl, r, caller(r))
+			if err != nil {
+				errs.Write(w, r, err)
+				return
+			}
+			if g == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if existing != nil {
+				if err := g.replay(w, existing); err != nil {
+					errs.Write(w, r, err)
+				}
+				return
+			}
+
+			g.before = w.Header().Clone()
+			rec := &recorder{ResponseWriter: w}
+			status := 0
+			defer func() { g.finish(r.Context(), status, w.Header(), rec.body.Bytes()) }()
+			next.ServeHTTP(rec, r)
+			status = rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+		})
+	}
+}
+
+// Gin is Middleware for gin
+func Gin(store Store, ttl time.Duration, caller func(*gin.Context) string) gin.HandlerFunc {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return func(c *gin.Context) {
+		g, existing, err := reserve(store, ttl, c.Request, caller(c))
+		if err != nil {
+			errs.Write(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		if g == nil {
+			c.Next()
+			return
+		}
+		if existing != nil {
+			if err := g.replay(c.Writer, existing); err != nil {
+				errs.Write(c.Writer, c.Request, err)
+			}
+			c.Abort()
+			return
+		}
+
+		g.before = c.Writer.Header().Clone()
+		rec := &ginRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		status := 0
+		defer func() {
+			c.Writer = rec.ResponseWriter
+			g.finish(c.Request.Context(), status, c.Writer.Header(), rec.body.Bytes())
+		}()
+		c.Next()
+		status = c.Writer.Status()
+	}
+}
+
+// recorder keeps a copy of the response written through it
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *recorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ginRecorder keeps a copy of the body written through it, gin tracks the status
+type ginRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *ginRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *ginRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+type entry struct {
+	record  *Record
+	expires time.Time
+}
+
+// MemoryStore keeps the records of a single instance, expired entries are dropped
+// when their key is used again or by Sweep
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]entry)}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, record *Record, ttl time.Duration) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && time.Now().Before(e.expires) {
+		return e.record, nil
+	}
+	s.entries[key] = entry{record: record, expires: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = entry{record: record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Sweep removes expired entries, call it periodically for keys that are not used again
+func (s *MemoryStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
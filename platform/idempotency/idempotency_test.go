@@ -0,0 +1,195 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// create answers 201 with the number of the call and the body, 500 for "fail"
+func create(calls *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if string(body) == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/items/%d", n))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%d %s", n, body)
+	}
+}
+
+func request(method, key, user, body string) *http.Request {
+	req := httptest.NewRequest(method, "/items", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	req.Header.Set("X-User", user)
+	return req
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]func(*atomic.Int32) http.Handler{
+		"net/http": func(calls *atomic.Int32) http.Handler {
+			caller := func(r *http.Request) string { return r.Header.Get("X-User") }
+			return Middleware(nil, time.Minute, caller)(create(calls))
+		},
+		"gin": func(calls *atomic.Int32) http.Handler {
+			router := gin.New()
+			caller := func(c *gin.Context) string { return c.GetHeader("X-User") }
+			router.Use(Gin(nil, time.Minute, caller))
+			router.Any("/items", gin.WrapF(create(calls)))
+			return router
+		},
+	}
+	for name, newHandler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int32
+			h := newHandler(&calls)
+			serve := func(req *http.Request) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				return w
+			}
+
+			first := serve(request("POST", "k1", "ann", "apple"))
+			retry := serve(request("POST", "k1", "ann", "apple"))
+			if first.Code != http.StatusCreated || first.Body.String() != "1 apple" {
+				t.Fatalf("Expected the item to be created, got %d %s", first.Code, first.Body)
+			}
+			if retry.Code != http.StatusCreated || retry.Body.String() != "1 apple" || retry.Header().Get("Location") != "/items/1" {
+				t.Errorf("Expected the first response to be replayed, got %d %s %v", retry.Code, retry.Body, retry.Header())
+			}
+			if retry.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+				t.Errorf("Expected only the replay to be marked")
+			}
+
+			if w := serve(request("POST", "k1", "ann", "pear")); w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected 422 for another body, got %d", w.Code)
+			}
+			if w := serve(request("POST", "k1", "bob", "apple")); w.Body.String() != "2 apple" {
+				t.Errorf("Expected the key to be scoped to the caller, got %s", w.Body)
+			}
+			if w := serve(request("POST", "", "ann", "apple")); w.Body.String() != "3 apple" {
+				t.Errorf("Expected requests without a key to pass, got %s", w.Body)
+			}
+			if w := serve(request("DELETE", "k1", "ann", "")); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "" {
+				t.Errorf("Expected other methods to pass, got %d", w.Code)
+			}
+
+			serve(request("POST", "k2", "ann", "fail"))
+			if w := serve(request("POST", "k2", "ann", "fail")); w.Code != http.StatusInternalServerError || w.Header().Get(ReplayedHeader) != "" {
+				t.Errorf("Expected a server error to be retried, got %d", w.Code)
+			}
+			if w := serve(request("POST", strings.Repeat("k", maxKeyLength+1), "ann", "apple")); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400 for a long key, got %d", w.Code)
+			}
+			if n := calls.Load(); n != 6 {
+				t.Errorf("Expected 6 calls of the handler, got %d", n)
+			}
+		})
+	}
+}
+
+func TestReplayKeepsPerRequestHeaders(t *testing.T) {
+	var calls atomic.Int32
+	guarded := Middleware(nil, time.Minute, func(*http.Request) string { return "ann" })(create(&calls))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+		guarded.ServeHTTP(w, r)
+	})
+
+	for _, id := range []string{"req-1", "req-2"} {
+		req := request("POST", "k1", "ann", "apple")
+		req.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if got := w.Header().Get("X-Request-ID"); got != id {
+			t.Errorf("Expected the request ID %s, got %s", id, got)
+		}
+		if w.Header().Get("Location") != "/items/1" {
+			t.Errorf("Expected the headers of the handler to be replayed, got %v", w.Header())
+		}
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	store := NewMemoryStore()
+	started, release := make(chan struct{}), make(chan struct{})
+	h := Middleware(store, time.Minute, func(*http.Request) string { return "ann" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(httptest.NewRecorder(), request("POST", "k1", "ann", "apple"))
+	}()
+	<-started
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("POST", "k1", "ann", "apple"))
+	close(release)
+	<-done
+
+	if w.Code != http.StatusConflict || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 409 with Retry-After while the first request runs, got %d", w.Code)
+	}
+}
+
+func TestPanicReleasesKey(t *testing.T) {
+	store := NewMemoryStore()
+	h := Middleware(store, time.Minute, func(*http.Request) string { return "" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), request("POST", "k1", "", "apple"))
+	}()
+	if len(store.entries) != 0 {
+		t.Errorf("Expected the key to be released, got %v", store.entries)
+	}
+}
+
+type failingStore struct{ *MemoryStore }
+
+func (failingStore) Reserve(context.Context, string, *Record, time.Duration) (*Record, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestStoreDown(t *testing.T) {
+	var calls atomic.Int32
+	h := Middleware(failingStore{NewMemoryStore()}, time.Minute, func(*http.Request) string { return "" })(create(&calls))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request("POST", "k1", "", "apple"))
+	if w.Code != http.StatusServiceUnavailable || calls.Load() != 0 {
+		t.Errorf("Expected 503 without running the handler, got %d after %d calls", w.Code, calls.Load())
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Reserve(ctx, "old", &Record{Fingerprint: "a"}, -time.Second)
+	if existing, _ := store.Reserve(ctx, "old", &Record{Fingerprint: "b"}, time.Minute); existing != nil {
+		t.Errorf("Expected an expired key to be reserved again, got %+v", existing)
+	}
+	store.Save(ctx, "gone", &Record{}, -time.Second)
+	store.Sweep()
+	if _, ok := store.entries["gone"]; ok || len(store.entries) != 1 {
+		t.Errorf("Expected Sweep to drop the expired entry, got %v", store.entries)
+	}
+}
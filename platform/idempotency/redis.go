@@ -0,0 +1,60 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveScript sets the record unless the key exists and returns the existing one,
+// so concurrent first requests cannot both reserve the key
+var reserveScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return false
+end
+return redis.call("GET", KEYS[1])
+`)
+
+// RedisStore shares the records between the instances of a service, Redis expires them
+type RedisStore struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{Client: client, Prefix: "idempotency:"}
+}
+
+func (s *RedisStore) Reserve(ctx context.Context, key string, record *Record, ttl time.Duration) (*Record, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := reserveScript.Run(ctx, s.Client, []string{s.Prefix + key}, data, ttl.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored Record
+	if err := json.Unmarshal([]byte(existing), &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, key string, record *Record, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, s.Prefix+key, data, ttl).Err()
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, s.Prefix+key).Err()
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// fields are the IDs of a request. They are shared by the contexts derived from the
+// request's, so a user authenticated deep in the middleware chain shows up in the
+// access log written on the way out.
+type fields struct {
+	mu        sync.Mutex
+	requestID string
+	userID    string
+}
+
+func fieldsFrom(ctx context.Context) *fields {
+	f, _ := ctx.Value(contextKey{}).(*fields)
+	return f
+}
+
+// WithRequestID returns a context carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, &fields{requestID: id})
+}
+
+// WithUserID records the ID of the user making the request. It is set on the request
+// the context belongs to, and on a new context if it doesn't belong to any.
+func WithUserID(ctx context.Context, id string) context.Context {
+	f := fieldsFrom(ctx)
+	if f == nil {
+		return context.WithValue(ctx, contextKey{}, &fields{userID: id})
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.userID = id
+	return ctx
+}
+
+// RequestID returns the request ID of the context, empty if there is none
+func RequestID(ctx context.Context) string {
+	f := fieldsFrom(ctx)
+	if f == nil {
+		return ""
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requestID
+}
+
+// UserID returns the user ID of the context, empty if there is none
+func UserID(ctx context.Context) string {
+	f := fieldsFrom(ctx)
+	if f == nil {
+		return ""
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.userID
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID. An ID sent by a client or a proxy is kept,
+// otherwise one is generated, and it is echoed in the response either way.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds the IDs taken from clients, longer ones are replaced
+const maxRequestIDLen = 128
+
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); id != "" && len(id) <= maxRequestIDLen {
+		return id
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// logRequest writes the access log line of a request. Server errors are logged as
+// errors, client errors as warnings.
+func logRequest(logger *slog.Logger, r *http.Request, status, size int, elapsed time.Duration) {
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+	logger.LogAttrs(r.Context(), level, "Request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int("bytes", size),
+		slog.Duration("elapsed", elapsed),
+		slog.String("remote", r.RemoteAddr),
+	)
+}
+
+// Middleware assigns request IDs and writes an access log line per request. It fits
+// net/http muxes and gorilla/mux's Router.Use alike.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := requestID(r)
+			w.Header().Set(RequestIDHeader, id)
+			r = r.WithContext(WithRequestID(r.Context(), id))
+
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			logRequest(logger, r, rec.status, rec.size, time.Since(start))
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+	wrote  bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wrote {
+		w.status = status
+		w.wrote = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wrote = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Gin is Middleware for gin, replacing gin.Logger. Errors added to the context with
+// c.Error are logged with the request.
+func Gin(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id := requestID(c.Request)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		if len(c.Errors) > 0 {
+			logger.ErrorContext(c.Request.Context(), "Request errors", "errors", c.Errors.String())
+		}
+		logRequest(logger, c.Request, c.Writer.Status(), max(c.Writer.Size(), 0), time.Since(start))
+	}
+}
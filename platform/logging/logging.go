@@ -0,0 +1,114 @@
+// Package logging sets up the slog loggers of the services. Records are written as
+// JSON or as console text and carry the request and user IDs found in the context, so
+// every line logged while serving a request can be traced back to it:
+//
+//	logger := logging.FromConfig(cfg, "inventory")
+//	slog.SetDefault(logger)
+//	router.Use(logging.Gin(logger))
+//
+// and later, in a handler:
+//
+//	slog.InfoContext(c.Request.Context(), "Item created", "id", id)
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"awesomeProject/platform/config"
+)
+
+// The formats of the records
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Options configures a logger
+type Options struct {
+	// Format is FormatJSON or FormatConsole, JSON if empty
+	Format string
+	// Level is the minimum level logged, info if nil
+	Level slog.Leveler
+	// Output defaults to stderr
+	Output io.Writer
+	// Service is added to every record when set
+	Service string
+}
+
+// New returns a logger writing records as configured by opts
+func New(opts Options) *slog.Logger {
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+
+	var handler slog.Handler
+	if opts.Format == FormatConsole {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	}
+	logger := slog.New(NewContextHandler(handler))
+	if opts.Service != "" {
+		logger = logger.With("service", opts.Service)
+	}
+	return logger
+}
+
+// FromConfig returns a logger for service configured by the log.format and log.level
+// settings, LOG_FORMAT and LOG_LEVEL in the environment. Invalid values are recorded
+// as errors of cfg and replaced by the defaults.
+func FromConfig(cfg *config.Config, service string) *slog.Logger {
+	format := strings.ToLower(cfg.String("log.format", FormatJSON))
+	if format != FormatJSON && format != FormatConsole {
+		cfg.Check("log.format", fmt.Errorf("unknown format %q, want %s or %s", format, FormatJSON, FormatConsole))
+		format = FormatJSON
+	}
+	level, err := ParseLevel(cfg.String("log.level", "info"))
+	cfg.Check("log.level", err)
+	return New(Options{Format: format, Level: level, Service: service})
+}
+
+// ParseLevel parses a level name like "debug" or "WARN", optionally with an offset
+// like "info+2"
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, err
+	}
+	return level, nil
+}
+
+// ContextHandler adds the request and user IDs of the context to the records of the
+// handler it wraps
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(h slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: h}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if id := UserID(ctx); id != "" {
+		r.AddAttrs(slog.String("user_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
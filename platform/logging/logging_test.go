@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/config"
+	"github.com/gin-gonic/gin"
+)
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Invalid record %q: %v", line, err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Output: &buf, Service: "users"}).With("component", "test")
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	derived := WithUserID(ctx, "42")
+	logger.InfoContext(ctx, "Hello")
+	logger.DebugContext(derived, "Hidden")
+
+	got := records(t, &buf)
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 record at info level, got %v", got)
+	}
+	want := map[string]any{"msg": "Hello", "service": "users", "component": "test", "request_id": "req-1", "user_id": "42"}
+	for key, value := range want {
+		if got[0][key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, got[0][key])
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	cfg, err := config.New(config.Map(map[string]string{"LOG_LEVEL": "debug", "LOG_FORMAT": "console"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := FromConfig(cfg, "users")
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected debug to be enabled")
+	}
+	if _, ok := logger.Handler().(*ContextHandler).Handler.(*slog.TextHandler); !ok {
+		t.Errorf("Expected a text handler, got %T", logger.Handler().(*ContextHandler).Handler)
+	}
+
+	cfg, _ = config.New(config.Map(map[string]string{"LOG_LEVEL": "loud", "LOG_FORMAT": "xml"}))
+	FromConfig(cfg, "users")
+	if err := cfg.Err(); err == nil || !strings.Contains(err.Error(), "log.level") || !strings.Contains(err.Error(), "log.format") {
+		t.Errorf("Expected both settings to be reported, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Options{Output: &buf})
+	h := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		WithUserID(r.Context(), "ann")
+		logger.InfoContext(r.Context(), "Handling")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("missing"))
+	}))
+
+	req := httptest.NewRequest("GET", "/items/1", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Header().Get(RequestIDHeader) != "abc" {
+		t.Errorf("Expected the request ID to be echoed, got %q", w.Header().Get(RequestIDHeader))
+	}
+	got := records(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 records, got %v", got)
+	}
+	access := got[1]
+	if access["msg"] != "Request" || access["level"] != "WARN" || access["status"] != float64(404) || access["bytes"] != float64(7) {
+		t.Errorf("Unexpected access log %v", access)
+	}
+	if access["request_id"] != "abc" || access["user_id"] != "ann" || got[0]["request_id"] != "abc" {
+		t.Errorf("Expected the IDs on every record, got %v", got)
+	}
+}
+
+func TestGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger := New(Options{Output: &buf})
+	r := gin.New()
+	r.Use(Gin(logger))
+	r.GET("/boom", func(c *gin.Context) {
+		c.Error(http.ErrAbortHandler)
+		c.Status(http.StatusInternalServerError)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/boom", nil))
+
+	id := w.Header().Get(RequestIDHeader)
+	if len(id) != 32 {
+		t.Errorf("Expected a generated request ID, got %q", id)
+	}
+	got := records(t, &buf)
+	if len(got) != 2 || got[0]["msg"] != "Request errors" {
+		t.Fatalf("Expected the errors and the access log, got %v", got)
+	}
+	if got[1]["level"] != "ERROR" || got[1]["status"] != float64(500) || got[1]["request_id"] != id {
+		t.Errorf("Unexpected access log %v", got[1])
+	}
+}
@@ -0,0 +1,146 @@
+// Package ratelimit allows each client a number of requests per fixed window. The
+// responses carry the X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// headers and requests over the limit get 429 with Retry-After. Requests are let
+// through when the store fails, an unreachable Redis must not take a service down.
+//
+// A Limiter serves as net/http middleware and, with its Gin method, as gin
+// middleware. MemoryStore counts the requests of a single instance, RedisStore shares
+// the counts between the instances of a service.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// Store counts requests per key in fixed windows
+type Store interface {
+	// Increment counts a request for key and returns the number of requests in the
+	// current window and when the window ends
+	Increment(ctx context.Context, key string, window time.Duration) (count int, reset time.Time, err error)
+}
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// ClientIP keys requests by the address of the peer. Services behind a proxy key
+// them by the address the proxy forwards instead.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Limiter allows Limit requests per Window for each key, the requests are keyed by
+// ClientIP when Key is nil
+type Limiter struct {
+	Store  Store
+	Limit  int
+	Window time.Duration
+	Key    KeyFunc
+}
+
+// Middleware limits clients with an in-memory store, use a Limiter with a RedisStore
+// to share the limits between instances
+func Middleware(limit int, window time.Duration, key KeyFunc) func(http.Handler) http.Handler {
+	return Limiter{Store: NewMemoryStore(), Limit: limit, Window: window, Key: key}.Middleware
+}
+
+// check counts r and sets the rate limit headers, it returns the 429 error when r is
+// over the limit
+func (l Limiter) check(w http.ResponseWriter, r *http.Request) error {
+	key := ClientIP
+	if l.Key != nil {
+		key = l.Key
+	}
+	count, reset, err := l.Store.Increment(r.Context(), key(r), l.Window)
+	if err != nil {
+		return nil
+	}
+
+	remaining := max(l.Limit-count, 0)
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+	if count <= l.Limit {
+		return nil
+	}
+	retry := max(int(time.Until(reset).Round(time.Second)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	return errs.New(errs.ErrTooManyRequests, fmt.Sprintf("Rate limit of %d requests per %s exceeded", l.Limit, l.Window))
+}
+
+// Middleware limits the requests to next
+func (l Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.check(w, r); err != nil {
+			errs.Write(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Gin is Middleware for gin
+func (l Limiter) Gin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := l.check(c.Writer, c.Request); err != nil {
+			errs.Write(c.Writer, c.Request, err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type window struct {
+	count int
+	reset time.Time
+}
+
+// MemoryStore keeps the counters of a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{windows: make(map[string]*window), now: time.Now}
+}
+
+func (s *MemoryStore) Increment(ctx context.Context, key string, length time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	// drop expired windows now and then so idle clients do not accumulate
+	if now.Sub(s.lastSweep) > length {
+		for k, w := range s.windows {
+			if !now.Before(w.reset) {
+				delete(s.windows, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	w, ok := s.windows[key]
+	if !ok || !now.Before(w.reset) {
+		w = &window{reset: now.Add(length)}
+		s.windows[key] = w
+	}
+	w.count++
+	return w.count, w.reset, nil
+}
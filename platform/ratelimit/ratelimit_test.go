@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]func() http.Handler{
+		"net/http": func() http.Handler {
+			return Middleware(2, time.Minute, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		},
+		"gin": func() http.Handler {
+			router := gin.New()
+			router.Use(Limiter{Store: NewMemoryStore(), Limit: 2, Window: time.Minute}.Gin())
+			router.GET("/", func(c *gin.Context) {})
+			return router
+		},
+	}
+	tests := []struct {
+		remoteAddr    string
+		status        int
+		wantRemaining string
+	}{
+		{"10.0.0.1:1234", http.StatusOK, "1"},
+		{"10.0.0.1:5678", http.StatusOK, "0"},
+		{"10.0.0.1:1234", http.StatusTooManyRequests, "0"},
+		{"10.0.0.2:1234", http.StatusOK, "1"},
+	}
+	for name, newHandler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			h := newHandler()
+			for i, tt := range tests {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.RemoteAddr = tt.remoteAddr
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+
+				if w.Code != tt.status || w.Header().Get("X-RateLimit-Remaining") != tt.wantRemaining {
+					t.Errorf("Request %d: expected %d with %s remaining, got %d with %s", i, tt.status, tt.wantRemaining, w.Code, w.Header().Get("X-RateLimit-Remaining"))
+				}
+				if w.Header().Get("X-RateLimit-Limit") != "2" || w.Header().Get("X-RateLimit-Reset") == "" {
+					t.Errorf("Request %d: expected the rate limit headers, got %v", i, w.Header())
+				}
+				if tt.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+					t.Errorf("Request %d: expected Retry-After 60, got %q", i, w.Header().Get("Retry-After"))
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	for i := 1; i <= 3; i++ {
+		if count, _, _ := store.Increment(context.Background(), "a", time.Second); count != i {
+			t.Fatalf("Expected the count %d, got %d", i, count)
+		}
+	}
+
+	now = now.Add(time.Second)
+	count, reset, _ := store.Increment(context.Background(), "a", time.Second)
+	if count != 1 || !reset.Equal(now.Add(time.Second)) {
+		t.Errorf("Expected a new window, got the count %d and the reset %v", count, reset)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	return 0, time.Time{}, errors.New("connection refused")
+}
+
+func TestStoreDown(t *testing.T) {
+	h := Limiter{Store: failingStore{}, Limit: 1, Window: time.Minute}.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the request to pass, got %d", w.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrementScript starts the window expiry with the first request, so the counter
+// and its TTL are set atomically
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+// RedisStore shares the counters between the instances of a service
+type RedisStore struct {
+	Client redis.Scripter
+	Prefix string
+}
+
+func NewRedisStore(client redis.Scripter) *RedisStore {
+	return &RedisStore{Client: client, Prefix: "ratelimit:"}
+}
+
+func (s *RedisStore) Increment(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	result, err := incrementScript.Run(ctx, s.Client, []string{s.Prefix + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	ttl := time.Duration(result[1]) * time.Millisecond
+	if ttl < 0 {
+		ttl = window
+	}
+	return int(result[0]), time.Now().Add(ttl), nil
+}
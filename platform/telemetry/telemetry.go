@@ -0,0 +1,232 @@
+// Package telemetry traces the HTTP services with OpenTelemetry. Setup installs the
+// OTLP exporters configured by the environment, Middleware and Gin start a server span
+// per request and record the http.server.request.duration histogram, and Transport
+// makes the outgoing requests client spans carrying the trace to the next service:
+//
+//	shutdown, err := telemetry.Setup(ctx, "inventory")
+//	router.Use(telemetry.Gin())
+//	client := &http.Client{Transport: telemetry.Transport(nil)}
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "awesomeProject/platform/telemetry"
+
+// Setup installs the global tracer and meter providers exporting over OTLP/HTTP.
+// Exporters are configured by the standard environment variables, such as
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_SERVICE_NAME, and OTEL_SDK_DISABLED=true turns
+// telemetry off. The returned function flushes and stops the exporters, it fits
+// app.Options.OnStop.
+func Setup(ctx context.Context, serviceName string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// OTEL_SERVICE_NAME in the environment wins over serviceName
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, err
+	}
+	if envRes, err := resource.New(ctx, resource.WithFromEnv()); err == nil {
+		res, _ = resource.Merge(res, envRes)
+	}
+
+	traceExporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metricExporter, err := otlpmetrichttp.New(ctx)
+	if err != nil {
+		traceExporter.Shutdown(ctx)
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res))
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res))
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+	}, nil
+}
+
+// RouteFunc names the route of a request for span names and metrics. It must return
+// a template such as "/users/{id}", not the path, to keep the metric cardinality low.
+type RouteFunc func(r *http.Request) string
+
+// instruments are the tracer and the duration histogram of a middleware
+type instruments struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+}
+
+func newInstruments() instruments {
+	duration, _ := otel.Meter(instrumentationName).Float64Histogram("http.server.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of HTTP server requests"))
+	return instruments{tracer: otel.Tracer(instrumentationName), duration: duration}
+}
+
+// serverSpan is the span of a request being served
+type serverSpan struct {
+	span  trace.Span
+	attrs []attribute.KeyValue
+	start time.Time
+}
+
+// start continues the caller's trace from the headers of r with a server span named
+// after the method and route, and returns the context carrying the span
+func (in instruments) start(r *http.Request, route string) (context.Context, *serverSpan) {
+	s := &serverSpan{start: time.Now(), attrs: []attribute.KeyValue{attribute.String("http.request.method", r.Method)}}
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+	name := r.Method
+	if route != "" {
+		s.attrs = append(s.attrs, attribute.String("http.route", route))
+		name += " " + route
+	}
+	ctx, s.span = in.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(s.attrs...),
+		trace.WithAttributes(attribute.String("url.path", r.URL.Path)),
+	)
+	return ctx, s
+}
+
+// end records the status of the response, marking server errors, and ends the span
+func (in instruments) end(ctx context.Context, s *serverSpan, status int) {
+	s.span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		s.span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	s.span.End()
+	attrs := append(s.attrs, attribute.Int("http.response.status_code", status))
+	in.duration.Record(ctx, time.Since(s.start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// Middleware starts a server span per request, continuing the caller's trace, and
+// records the http.server.request.duration histogram. Responses with a 5xx status
+// mark the span as an error. route may be nil, the spans are then only named after
+// the method.
+func Middleware(route RouteFunc) func(http.Handler) http.Handler {
+	in := newInstruments()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			template := ""
+			if route != nil {
+				template = route(r)
+			}
+			ctx, s := in.start(r, template)
+			rec := &statusRecorder{ResponseWriter: w}
+			// a panic leaves the status unwritten, the recovery answers it with 500
+			status := http.StatusInternalServerError
+			defer func() { in.end(ctx, s, status) }()
+
+			next.ServeHTTP(rec, r.WithContext(ctx))
+			status = rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+		})
+	}
+}
+
+// Gin is Middleware for gin, the routes are the templates gin matched such as
+// "/users/:id"
+func Gin() gin.HandlerFunc {
+	in := newInstruments()
+	return func(c *gin.Context) {
+		ctx, s := in.start(c.Request, c.FullPath())
+		c.Request = c.Request.WithContext(ctx)
+		defer func() { in.end(ctx, s, c.Writer.Status()) }()
+		c.Next()
+	}
+}
+
+// statusRecorder keeps the status of the response, 0 until it is written
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Transport returns a RoundTripper making the requests through base client spans
+// that propagate the trace context and baggage of the request context. A nil base is
+// http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, tracer: otel.Tracer(instrumentationName)}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.full", req.URL.Redacted()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
@@ -0,0 +1,143 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	callerTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	callerSpanID  = "00f067aa0ba902b7"
+)
+
+func setup(t *testing.T) (*tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	return spans, reader
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handlers := map[string]func() http.Handler{
+		"net/http": func() http.Handler {
+			route := func(*http.Request) string { return "/users/{id}" }
+			return Middleware(route)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+		},
+		"gin": func() http.Handler {
+			router := gin.New()
+			router.Use(Gin())
+			router.GET("/users/:id", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+			return router
+		},
+	}
+	names := map[string]string{"net/http": "GET /users/{id}", "gin": "GET /users/:id"}
+
+	for name, newHandler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			spans, reader := setup(t)
+			req := httptest.NewRequest("GET", "/users/7", nil)
+			req.Header.Set("traceparent", "00-"+callerTraceID+"-"+callerSpanID+"-01")
+			newHandler().ServeHTTP(httptest.NewRecorder(), req)
+
+			ended := spans.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("Expected a server span, got %d spans", len(ended))
+			}
+			span := ended[0]
+			if span.Name() != names[name] || span.SpanKind() != trace.SpanKindServer {
+				t.Errorf("Expected the server span %q, got %q of kind %v", names[name], span.Name(), span.SpanKind())
+			}
+			if span.Parent().SpanID().String() != callerSpanID || span.SpanContext().TraceID().String() != callerTraceID {
+				t.Errorf("Expected the caller's trace to be continued, got the parent %v", span.Parent())
+			}
+			if span.Status().Code != codes.Error || !slices.Contains(span.Attributes(), attribute.Int("http.response.status_code", http.StatusBadGateway)) {
+				t.Errorf("Expected an error span with the status, got %v and %v", span.Status(), span.Attributes())
+			}
+
+			var metrics metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &metrics); err != nil {
+				t.Fatal(err)
+			}
+			recorded := false
+			for _, scope := range metrics.ScopeMetrics {
+				for _, m := range scope.Metrics {
+					if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "http.server.request.duration" {
+						recorded = len(h.DataPoints) == 1 && h.DataPoints[0].Count == 1
+					}
+				}
+			}
+			if !recorded {
+				t.Errorf("Expected one http.server.request.duration data point, got %+v", metrics.ScopeMetrics)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	spans, _ := setup(t)
+	h := Middleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/items", nil))
+	}()
+
+	ended := spans.Ended()
+	if len(ended) != 1 || ended[0].Name() != "POST" || ended[0].Status().Code != codes.Error {
+		t.Errorf("Expected the span to end as an error, got %v", ended)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	spans, _ := setup(t)
+	var downstream http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "handler")
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	client := spans.Ended()[0]
+	if client.SpanKind() != trace.SpanKindClient || client.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected a client span within the handler's, got %q of kind %v", client.Name(), client.SpanKind())
+	}
+	if client.Status().Code != codes.Error {
+		t.Errorf("Expected the 503 to mark the span, got %v", client.Status())
+	}
+	want := "00-" + parent.SpanContext().TraceID().String() + "-" + client.SpanContext().SpanID().String() + "-01"
+	if got := downstream.Get("traceparent"); got != want {
+		t.Errorf("Expected the traceparent %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,47 @@
+package testkit
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// LoadFixture decodes the JSON file name of the testdata directory into v
+func LoadFixture(t testing.TB, name string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("Failed to decode fixture %s: %v", name, err)
+	}
+}
+
+// FixtureRows loads mock rows from the JSON file name of the testdata directory,
+// an object with the columns and the rows as arrays of values:
+//
+//	{"columns": ["id", "name"], "rows": [[1, "Laptop"]]}
+//
+// JSON numbers become float64, which database/sql converts when scanning.
+func FixtureRows(t testing.TB, name string) *sqlmock.Rows {
+	t.Helper()
+	var fixture struct {
+		Columns []string `json:"columns"`
+		Rows    [][]any  `json:"rows"`
+	}
+	LoadFixture(t, name, &fixture)
+	rows := sqlmock.NewRows(fixture.Columns)
+	for _, row := range fixture.Rows {
+		values := make([]driver.Value, len(row))
+		for i, v := range row {
+			values[i] = v
+		}
+		rows.AddRow(values...)
+	}
+	return rows
+}
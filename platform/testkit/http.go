@@ -0,0 +1,90 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// NewRequest returns a request to target with body, which may be nil
+func NewRequest(method, target string, body io.Reader) *http.Request {
+	return httptest.NewRequest(method, target, body)
+}
+
+// JSONRequest returns a request to target with v encoded as its JSON body
+func JSONRequest(method, target string, v any) *http.Request {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("testkit: encoding request body: %v", err))
+	}
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// FormRequest returns a request to target with form as its urlencoded body
+func FormRequest(method, target string, form url.Values) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// Serve serves req with h and returns the recorded response
+func Serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// ExpectStatus returns an error unless the response has one of the statuses
+func ExpectStatus(w *httptest.ResponseRecorder, statuses ...int) error {
+	for _, status := range statuses {
+		if w.Code == status {
+			return nil
+		}
+	}
+	if len(statuses) == 1 {
+		return fmt.Errorf("expected status %d, got %d", statuses[0], w.Code)
+	}
+	return fmt.Errorf("expected status %v, got %d", statuses, w.Code)
+}
+
+// DecodeJSON decodes the body of the response into v
+func DecodeJSON(w *httptest.ResponseRecorder, v any) error {
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		return fmt.Errorf("decoding response %q: %w", w.Body.String(), err)
+	}
+	return nil
+}
+
+// Client serves requests with a handler like a browser would, sending back the
+// cookies it was given, so sessions carry over from one request to the next
+type Client struct {
+	Handler http.Handler
+	cookies map[string]*http.Cookie
+}
+
+func NewClient(h http.Handler) *Client {
+	return &Client{Handler: h, cookies: make(map[string]*http.Cookie)}
+}
+
+// Do serves req with the cookies of the client and keeps the cookies it sets
+func (c *Client) Do(req *http.Request) *httptest.ResponseRecorder {
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	w := Serve(c.Handler, req)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie
+		}
+	}
+	return w
+}
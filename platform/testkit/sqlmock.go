@@ -0,0 +1,33 @@
+package testkit
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// MockDB returns a mock database matching queries with matcher, or by regular
+// expression if it's nil. Pings aren't expected. It is closed when the test ends.
+func MockDB(t testing.TB, matcher sqlmock.QueryMatcher) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	if matcher == nil {
+		matcher = sqlmock.QueryMatcherRegexp
+	}
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher), sqlmock.MonitorPingsOption(false))
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+// Rows returns mock rows with columns and records
+func Rows(columns []string, records ...[]driver.Value) *sqlmock.Rows {
+	rows := sqlmock.NewRows(columns)
+	for _, record := range records {
+		rows.AddRow(record...)
+	}
+	return rows
+}
@@ -0,0 +1,133 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter(t)
+	r.SetOutput(&out)
+	r.KnownFailures("known")
+	r.Run("passes", func(t *testing.T) error { return nil })
+	r.Run("known", func(t *testing.T) error { return errors.New("still broken") })
+	r.Run("panics known", func(t *testing.T) error {
+		r.KnownFailures("panics known")
+		panic("boom")
+	})
+
+	results := r.Results()
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	if results[0].Err != nil || results[1].Err == nil || !results[1].Known {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "panic: boom") {
+		t.Errorf("Expected the panic to be reported, got %v", results[2].Err)
+	}
+	want := "Test 1# passes: Passed\nTest 2# known: Known failure: still broken\nTest 3# panics known: Known failure: panic: boom\n"
+	if out.String() != want {
+		t.Errorf("Expected report\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestClientKeepsCookies(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "ann"})
+	})
+	mux.HandleFunc("/logout", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", MaxAge: -1})
+	})
+	mux.HandleFunc("/me", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(cookie.Value))
+	})
+	client := NewClient(mux)
+
+	if err := ExpectStatus(client.Do(NewRequest("GET", "/me", nil)), http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+	client.Do(FormRequest("POST", "/login", url.Values{"user": {"ann"}}))
+	if w := client.Do(NewRequest("GET", "/me", nil)); w.Body.String() != "ann" {
+		t.Errorf("Expected the session cookie to be sent, got %d %q", w.Code, w.Body.String())
+	}
+	client.Do(NewRequest("POST", "/logout", nil))
+	if err := ExpectStatus(client.Do(NewRequest("GET", "/me", nil)), http.StatusUnauthorized); err != nil {
+		t.Errorf("Expected the deleted cookie to be dropped: %v", err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&in) != nil || in["name"] != "ann" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	})
+	w := Serve(h, JSONRequest("POST", "/users", map[string]string{"name": "ann"}))
+	if err := ExpectStatus(w, http.StatusOK, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+	var body struct{ ID int }
+	if err := DecodeJSON(w, &body); err != nil || body.ID != 1 {
+		t.Errorf("Expected id 1, got %d (%v)", body.ID, err)
+	}
+	if err := ExpectStatus(w, http.StatusOK); err == nil || err.Error() != "expected status 200, got 201" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestFixtureRows(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Mkdir(filepath.Join(dir, "testdata"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fixture := `{"columns": ["id", "name", "price"], "rows": [[1, "Laptop", 999.99], [2, "Mouse", 19.5]]}`
+	if err := os.WriteFile(filepath.Join(dir, "testdata", "products.json"), []byte(fixture), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	db, mock := MockDB(t, nil)
+	mock.ExpectQuery("SELECT id, name, price FROM products").WillReturnRows(FixtureRows(t, "products.json"))
+	rows, err := db.Query("SELECT id, name, price FROM products")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var id int
+		var name string
+		var price float64
+		if err := rows.Scan(&id, &name, &price); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if strings.Join(names, ",") != "Laptop,Mouse" {
+		t.Errorf("Expected the fixture rows, got %v", names)
+	}
+}
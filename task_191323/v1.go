@@ -28,15 +28,29 @@ const DOCKER_JWT_RUNNING = "Docker rest_api_jwt_pdv_app rodando normalmente"
 type Docker struct {
 	Name         string        `json:"name"`
 	Running      bool          `json:"running"`
+	Status       string        `json:"status,omitempty"`
 	CheckedAt    time.Time     `json:"checkedAt"`
 	StatusCode   int           `json:"statusCode"`
 	ResponseTime time.Duration `json:"responseTime"`
+	Target       string        `json:"target,omitempty"`
+	Attempts     int           `json:"attempts,omitempty"`
+	Breaker      string        `json:"breaker,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Silence      *Silence      `json:"silence,omitempty"`
+	Upstream     []string      `json:"upstream,omitempty"`
 }
 
 // Server Struct
 type Server struct {
-	Router *mux.Router
-	Client *http.Client
+	Router  *mux.Router
+	Client  *http.Client
+	Monitor *Monitor
+	Auth    *Authenticator
+	Store   *ResultStore
+
+	// ConfigPath is the YAML config file, the configuration comes from the
+	// environment when it is empty
+	ConfigPath string
 }
 
 // Middleware
@@ -57,6 +71,18 @@ func JSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	}
 }
 
+func ERROR(w http.ResponseWriter, statusCode int, err error) {
+	if err != nil {
+		JSON(w, statusCode, struct {
+			Error string `json:"error"`
+		}{
+			Error: err.Error(),
+		})
+		return
+	}
+	JSON(w, http.StatusBadRequest, nil)
+}
+
 // Services Function
 func GetAllDockers(client *http.Client, dockerAPIURL string, timeDelay time.Duration) []Docker {
 	var dockers []Docker
@@ -128,17 +154,35 @@ func (server *Server) Initialize() {
 		timeDelay = 15
 	}
 
-	dockerAPIURL := os.Getenv("DOCKER_API_JWT")
-	if dockerAPIURL == "" {
-		log.Fatal("DOCKER_API_JWT environment variable not set")
-	}
-
 	server.Client = &http.Client{
 		Timeout: time.Duration(timeDelay) * time.Second,
 	}
 
+	server.ConfigPath = os.Getenv("MONITOR_CONFIG")
+	cfg, err := server.loadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	server.Monitor = NewMonitor(server.Client, RetryPolicy{}, 0, 0)
+	if err := server.Monitor.Apply(cfg); err != nil {
+		log.Fatalf("Error applying configuration: %v", err)
+	}
+
+	dbPath := os.Getenv("MONITOR_DB_PATH")
+	if dbPath == "" {
+		dbPath = "monitor.db"
+	}
+	store, err := NewResultStore(dbPath)
+	if err != nil {
+		log.Fatalf("Error opening result store %s: %v", dbPath, err)
+	}
+	server.Store = store
+	server.Monitor.Store = store
+
+	server.Auth = NewAuthenticatorFromEnv()
+
 	server.Router = mux.NewRouter()
-	server.initializeRoutes(dockerAPIURL, time.Duration(timeDelay)*time.Second)
+	server.initializeRoutes()
 }
 
 func (server *Server) Run(addr string) {
@@ -149,6 +193,23 @@ func (server *Server) Run(addr string) {
 		Handler: server.Router,
 	}
 
+	// Periodic checks feed the SLA report
+	checksCtx, stopChecks := context.WithCancel(context.Background())
+	go server.Monitor.Run(checksCtx)
+
+	// Configuration reloads
+	go func() {
+		sighup := make(chan os.Signal, 1)
+		signal.Notify(sighup, syscall.SIGHUP)
+		for range sighup {
+			if err := server.Reload(); err != nil {
+				log.Printf("Error reloading configuration, keeping the current one: %v", err)
+				continue
+			}
+			log.Printf("Configuration reloaded from %s", server.ConfigPath)
+		}
+	}()
+
 	// Graceful shutdown
 	go func() {
 		sigint := make(chan os.Signal, 1)
@@ -156,12 +217,16 @@ func (server *Server) Run(addr string) {
 		<-sigint
 
 		log.Println("Shutting down server...")
+		stopChecks()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Fatalf("Server forced to shutdown: %v", err)
 		}
+		if err := server.Store.Close(); err != nil {
+			log.Printf("Error closing result store: %v", err)
+		}
 		log.Println("Server gracefully stopped")
 	}()
 
@@ -170,17 +235,35 @@ func (server *Server) Run(addr string) {
 }
 
 // Initialize Routes
-func (s *Server) initializeRoutes(dockerAPIURL string, timeDelay time.Duration) {
-	s.Router.HandleFunc("/", SetMiddlewareJSON(func(w http.ResponseWriter, r *http.Request) {
-		s.GetAllDockers(w, r, dockerAPIURL, timeDelay)
-	})).Methods("GET")
+func (s *Server) initializeRoutes() {
+	s.Router.HandleFunc("/", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeRead, s.GetAllDockers))).Methods("GET")
+	s.Router.HandleFunc("/dashboard", s.Auth.SetMiddlewareAuth(ScopeRead, s.GetDashboard)).Methods("GET")
+	s.Router.HandleFunc("/events", s.Auth.SetMiddlewareAuth(ScopeRead, s.GetEvents)).Methods("GET")
+	s.Router.HandleFunc("/report", s.Auth.SetMiddlewareAuth(ScopeRead, s.GetReport)).Methods("GET")
+	s.Router.HandleFunc("/admin/breakers/reset", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeAdmin, s.ResetBreakers))).Methods("POST")
+	s.Router.HandleFunc("/admin/reload", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeAdmin, s.ReloadConfig))).Methods("POST")
+	s.Router.HandleFunc("/silence", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeRead, s.GetSilences))).Methods("GET")
+	s.Router.HandleFunc("/silence", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeAdmin, s.CreateSilence))).Methods("POST")
+	s.Router.HandleFunc("/silence/{id}", SetMiddlewareJSON(s.Auth.SetMiddlewareAuth(ScopeAdmin, s.DeleteSilence))).Methods("DELETE")
 }
 
 // Controller Function
-func (server *Server) GetAllDockers(w http.ResponseWriter, r *http.Request, dockerAPIURL string, timeDelay time.Duration) {
-	resp := GetAllDockers(server.Client, dockerAPIURL, timeDelay)
+func (server *Server) GetAllDockers(w http.ResponseWriter, r *http.Request) {
+	resp := server.Monitor.CheckAll(r.Context())
 	JSON(w, http.StatusOK, resp)
+}
 
+func (server *Server) ResetBreakers(w http.ResponseWriter, r *http.Request) {
+	server.Monitor.ResetBreakers()
+	JSON(w, http.StatusOK, map[string]string{"message": "circuit breakers reset"})
+}
+
+func (server *Server) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := server.Reload(); err != nil {
+		ERROR(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	JSON(w, http.StatusOK, map[string]string{"message": "configuration reloaded"})
 }
 
 // Entry point
@@ -0,0 +1,88 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Alert is sent when a target goes down or recovers
+type Alert struct {
+	Target    string    `json:"target"`
+	Running   bool      `json:"running"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+func (a Alert) String() string {
+	if a.Running {
+		return fmt.Sprintf("%s recovered", a.Target)
+	}
+	return fmt.Sprintf("%s is down: %s", a.Target, a.Error)
+}
+
+// Alerter delivers alerts to a notification channel
+type Alerter interface {
+	Alert(ctx context.Context, a Alert) error
+}
+
+// MultiAlerter sends alerts to several channels, returning the errors of those that failed
+type MultiAlerter []Alerter
+
+func (m MultiAlerter) Alert(ctx context.Context, a Alert) error {
+	var errs []error
+	for _, alerter := range m {
+		if err := alerter.Alert(ctx, a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogAlerter writes alerts to the log, it is used when no channel is configured
+type LogAlerter struct{}
+
+func (LogAlerter) Alert(ctx context.Context, a Alert) error {
+	log.Printf("ALERT: %s", a)
+	return nil
+}
+
+// WebhookAlerter posts alerts as JSON to a URL (Slack-compatible "text" included)
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *WebhookAlerter) Alert(ctx context.Context, a Alert) error {
+	body, err := json.Marshal(struct {
+		Alert
+		Text string `json:"text"`
+	}{a, a.String()})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Access scopes. Admin implies read.
+const (
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+type basicCredential struct {
+	password string
+	scope    string
+}
+
+// Authenticator checks API keys (X-API-Key header) and basic-auth credentials against
+// the scopes configured through the environment
+type Authenticator struct {
+	apiKeys map[string]string
+	users   map[string]basicCredential
+}
+
+// NewAuthenticatorFromEnv reads comma separated credential lists:
+//
+//	MONITOR_READ_API_KEYS, MONITOR_ADMIN_API_KEYS    key1,key2
+//	MONITOR_READ_USERS, MONITOR_ADMIN_USERS          user:password,user2:password2
+func NewAuthenticatorFromEnv() *Authenticator {
+	a := &Authenticator{
+		apiKeys: make(map[string]string),
+		users:   make(map[string]basicCredential),
+	}
+
+	for _, key := range splitEnvList("MONITOR_READ_API_KEYS") {
+		a.apiKeys[key] = ScopeRead
+	}
+	for _, key := range splitEnvList("MONITOR_ADMIN_API_KEYS") {
+		a.apiKeys[key] = ScopeAdmin
+	}
+	a.addUsers("MONITOR_READ_USERS", ScopeRead)
+	a.addUsers("MONITOR_ADMIN_USERS", ScopeAdmin)
+
+	if !a.Enabled() {
+		log.Println("No API keys or users configured, status API is unauthenticated and admin endpoints are disabled")
+	}
+	return a
+}
+
+func (a *Authenticator) addUsers(envKey, scope string) {
+	for _, entry := range splitEnvList(envKey) {
+		user, password, ok := strings.Cut(entry, ":")
+		if !ok || user == "" {
+			log.Printf("Ignoring malformed entry in %s, expected user:password", envKey)
+			continue
+		}
+		a.users[user] = basicCredential{password: password, scope: scope}
+	}
+}
+
+// Enabled reports whether any credentials are configured. Without credentials read requests are
+// allowed and admin requests refused.
+func (a *Authenticator) Enabled() bool {
+	return len(a.apiKeys) > 0 || len(a.users) > 0
+}
+
+// scopeFor returns the scope granted to the request credentials
+func (a *Authenticator) scopeFor(r *http.Request) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		for known, scope := range a.apiKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(known)) == 1 {
+				return scope, true
+			}
+		}
+		return "", false
+	}
+
+	if user, password, ok := r.BasicAuth(); ok {
+		cred, exists := a.users[user]
+		if exists && subtle.ConstantTimeCompare([]byte(password), []byte(cred.password)) == 1 {
+			return cred.scope, true
+		}
+	}
+	return "", false
+}
+
+// SetMiddlewareAuth rejects requests whose credentials don't grant the required scope. Admin
+// endpoints always need credentials, they can't be left open by a missing configuration.
+func (a *Authenticator) SetMiddlewareAuth(required string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			if required == ScopeAdmin {
+				ERROR(w, http.StatusForbidden, errors.New("admin endpoints are disabled, configure MONITOR_ADMIN_API_KEYS or MONITOR_ADMIN_USERS"))
+				return
+			}
+			next(w, r)
+			return
+		}
+
+		scope, ok := a.scopeFor(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="docker-monitor"`)
+			ERROR(w, http.StatusUnauthorized, errors.New("missing or invalid credentials"))
+			return
+		}
+		if required == ScopeAdmin && scope != ScopeAdmin {
+			ERROR(w, http.StatusForbidden, errors.New("admin scope required"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func splitEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
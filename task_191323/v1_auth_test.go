@@ -0,0 +1,123 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestStatusAPIAuthentication(t *testing.T) {
+	os.Setenv("MONITOR_READ_API_KEYS", "read-key")
+	os.Setenv("MONITOR_ADMIN_API_KEYS", "admin-key")
+	os.Setenv("MONITOR_READ_USERS", "viewer:viewpass")
+	os.Setenv("MONITOR_ADMIN_USERS", "ops:opspass")
+	defer func() {
+		for _, key := range []string{"MONITOR_READ_API_KEYS", "MONITOR_ADMIN_API_KEYS", "MONITOR_READ_USERS", "MONITOR_ADMIN_USERS"} {
+			os.Unsetenv(key)
+		}
+	}()
+	auth := NewAuthenticatorFromEnv()
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	serve := func(scope string, r *http.Request) int {
+		w := httptest.NewRecorder()
+		auth.SetMiddlewareAuth(scope, ok)(w, r)
+		return w.Code
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Missing credentials are rejected", func(t *testing.T) {
+			if code := serve(ScopeRead, httptest.NewRequest("GET", "/", nil)); code != http.StatusUnauthorized {
+				t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, code)
+			}
+		}},
+		{"Read API key can query status", func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-API-Key", "read-key")
+			if code := serve(ScopeRead, r); code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+			}
+		}},
+		{"Read API key cannot use admin endpoints", func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/admin/breakers/reset", nil)
+			r.Header.Set("X-API-Key", "read-key")
+			if code := serve(ScopeAdmin, r); code != http.StatusForbidden {
+				t.Errorf("Expected status %d, got %d", http.StatusForbidden, code)
+			}
+		}},
+		{"Admin API key can use admin endpoints", func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/admin/breakers/reset", nil)
+			r.Header.Set("X-API-Key", "admin-key")
+			if code := serve(ScopeAdmin, r); code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, code)
+			}
+		}},
+		{"Unknown API key is rejected", func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("X-API-Key", "nope")
+			if code := serve(ScopeRead, r); code != http.StatusUnauthorized {
+				t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, code)
+			}
+		}},
+		{"Basic auth scopes", func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.SetBasicAuth("viewer", "viewpass")
+			if code := serve(ScopeRead, r); code != http.StatusOK {
+				t.Errorf("Expected viewer read status %d, got %d", http.StatusOK, code)
+			}
+			if code := serve(ScopeAdmin, r); code != http.StatusForbidden {
+				t.Errorf("Expected viewer admin status %d, got %d", http.StatusForbidden, code)
+			}
+
+			r = httptest.NewRequest("POST", "/admin/breakers/reset", nil)
+			r.SetBasicAuth("ops", "opspass")
+			if code := serve(ScopeAdmin, r); code != http.StatusOK {
+				t.Errorf("Expected ops admin status %d, got %d", http.StatusOK, code)
+			}
+
+			r.SetBasicAuth("ops", "wrong")
+			if code := serve(ScopeAdmin, r); code != http.StatusUnauthorized {
+				t.Errorf("Expected wrong password status %d, got %d", http.StatusUnauthorized, code)
+			}
+		}},
+		{"No configured credentials leaves only the read API open", func(t *testing.T) {
+			open := &Authenticator{apiKeys: map[string]string{}, users: map[string]basicCredential{}}
+			w := httptest.NewRecorder()
+			open.SetMiddlewareAuth(ScopeRead, ok)(w, httptest.NewRequest("GET", "/", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected read status %d, got %d", http.StatusOK, w.Code)
+			}
+			w = httptest.NewRecorder()
+			open.SetMiddlewareAuth(ScopeAdmin, ok)(w, httptest.NewRequest("POST", "/admin/breakers/reset", nil))
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected admin status %d, got %d", http.StatusForbidden, w.Code)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
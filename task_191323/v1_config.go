@@ -0,0 +1,281 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Alert channel types
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+)
+
+// Config describes what the monitor checks and how. It is read from a YAML file,
+// see monitor.example.yaml, and can be reloaded while the monitor runs.
+type Config struct {
+	Interval time.Duration  `yaml:"interval"`
+	Timeout  time.Duration  `yaml:"timeout"` // of the targets without their own
+	Retry    RetryPolicy    `yaml:"retry"`
+	Breaker  BreakerConfig  `yaml:"breaker"`
+	Alerting AlertingConfig `yaml:"alerting"`
+	Targets  []TargetConfig `yaml:"targets"`
+}
+
+type BreakerConfig struct {
+	Threshold int           `yaml:"threshold"`
+	Cooldown  time.Duration `yaml:"cooldown"`
+}
+
+type AlertingConfig struct {
+	Channels []AlertChannel `yaml:"channels"`
+}
+
+type AlertChannel struct {
+	Type string `yaml:"type"`
+	URL  string `yaml:"url"`
+}
+
+type TargetConfig struct {
+	Name        string              `yaml:"name"`
+	Type        string              `yaml:"type"`
+	URL         string              `yaml:"url"`
+	Timeout     time.Duration       `yaml:"timeout"`
+	DependsOn   []string            `yaml:"dependsOn"`
+	Maintenance []MaintenanceConfig `yaml:"maintenance"`
+}
+
+type MaintenanceConfig struct {
+	Schedule string        `yaml:"schedule"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// DefaultConfig holds the settings a config file leaves out
+func DefaultConfig() *Config {
+	return &Config{
+		Interval: time.Minute,
+		Timeout:  15 * time.Second,
+		Retry:    RetryPolicy{MaxRetries: 2, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second},
+		Breaker:  BreakerConfig{Threshold: 3, Cooldown: 30 * time.Second},
+		Alerting: AlertingConfig{Channels: []AlertChannel{{Type: ChannelLog}}},
+	}
+}
+
+// LoadConfig reads and validates the config file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := DefaultConfig()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ConfigFromEnv builds the configuration from the environment variables of .env,
+// for deployments without a config file
+func ConfigFromEnv() (*Config, error) {
+	dockerAPIURL := os.Getenv("DOCKER_API_JWT")
+	if dockerAPIURL == "" {
+		return nil, errors.New("DOCKER_API_JWT environment variable not set")
+	}
+
+	cfg := DefaultConfig()
+	cfg.Interval = time.Duration(getEnvInt("CHECK_INTERVAL", 60)) * time.Second
+	cfg.Timeout = time.Duration(getEnvInt("CHECK_TIMEOUT", getEnvInt("TIME_DELAY", 15))) * time.Second
+	cfg.Retry = RetryPolicy{
+		MaxRetries: getEnvInt("MAX_RETRIES", 2),
+		BaseDelay:  time.Duration(getEnvInt("RETRY_BASE_DELAY_MS", 200)) * time.Millisecond,
+		MaxDelay:   time.Duration(getEnvInt("RETRY_MAX_DELAY_MS", 2000)) * time.Millisecond,
+	}
+	cfg.Breaker = BreakerConfig{
+		Threshold: getEnvInt("BREAKER_THRESHOLD", 3),
+		Cooldown:  time.Duration(getEnvInt("BREAKER_COOLDOWN", 30)) * time.Second,
+	}
+	if webhook := os.Getenv("ALERT_WEBHOOK_URL"); webhook != "" {
+		cfg.Alerting.Channels = []AlertChannel{{Type: ChannelWebhook, URL: webhook}}
+	}
+
+	// Additional targets, each probed over the protocol of its URL scheme
+	targets, err := ParseTargets(os.Getenv("MONITOR_TARGETS"), 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MONITOR_TARGETS: %w", err)
+	}
+	targets = append([]Target{{Name: "rest_api_jwt_pdv_app", URL: dockerAPIURL}}, targets...)
+
+	deps, err := ParseDependencies(os.Getenv("TARGET_DEPENDENCIES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TARGET_DEPENDENCIES: %w", err)
+	}
+	windows, err := ParseMaintenanceWindows(os.Getenv("MAINTENANCE_WINDOWS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAINTENANCE_WINDOWS: %w", err)
+	}
+
+	known := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		known[t.Name] = true
+	}
+	for name := range windows {
+		if !known[name] {
+			return nil, fmt.Errorf("invalid MAINTENANCE_WINDOWS: unknown target %q", name)
+		}
+	}
+
+	for _, t := range targets {
+		tc := TargetConfig{Name: t.Name, URL: t.URL, DependsOn: deps[t.Name]}
+		for _, w := range windows[t.Name] {
+			tc.Maintenance = append(tc.Maintenance, MaintenanceConfig{Schedule: w.Schedule.String(), Duration: w.Duration})
+		}
+		cfg.Targets = append(cfg.Targets, tc)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every problem of the configuration at once
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Interval > 0, "interval must be positive")
+	check(c.Timeout > 0, "timeout must be positive")
+	check(c.Retry.MaxRetries >= 0, "retry.maxRetries can't be negative")
+	check(c.Retry.BaseDelay >= 0 && c.Retry.MaxDelay >= 0, "retry delays can't be negative")
+	check(c.Breaker.Threshold >= 1, "breaker.threshold must be at least 1")
+	check(c.Breaker.Cooldown > 0, "breaker.cooldown must be positive")
+
+	for i, ch := range c.Alerting.Channels {
+		switch ch.Type {
+		case ChannelLog:
+		case ChannelWebhook:
+			u, err := url.Parse(ch.URL)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+				"alerting.channels[%d]: webhook channels need an http(s) url", i)
+		default:
+			errs = append(errs, fmt.Errorf("alerting.channels[%d]: unknown type %q", i, ch.Type))
+		}
+	}
+
+	check(len(c.Targets) > 0, "at least one target is required")
+	names := make(map[string]bool, len(c.Targets))
+	for i, tc := range c.Targets {
+		if tc.Name == "" {
+			errs = append(errs, fmt.Errorf("targets[%d]: name is required", i))
+			continue
+		}
+		check(!names[tc.Name], "target %s is defined twice", tc.Name)
+		names[tc.Name] = true
+
+		check(tc.Timeout >= 0, "target %s: timeout can't be negative", tc.Name)
+		if err := c.target(tc).validate(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, m := range tc.Maintenance {
+			if _, err := ParseSchedule(m.Schedule); err != nil {
+				errs = append(errs, fmt.Errorf("target %s: %w", tc.Name, err))
+			}
+			check(m.Duration > 0 && m.Duration <= maxSilence,
+				"target %s: maintenance duration must be positive and up to %v", tc.Name, maxSilence)
+		}
+	}
+	if len(errs) == 0 {
+		if err := ValidateDependencies(c.targets()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// target returns the monitored target of tc, with the default timeout when it has none
+func (c *Config) target(tc TargetConfig) Target {
+	t := Target{Name: tc.Name, Type: tc.Type, URL: tc.URL, Timeout: tc.Timeout, DependsOn: tc.DependsOn}
+	if t.Timeout == 0 {
+		t.Timeout = c.Timeout
+	}
+	return t
+}
+
+func (c *Config) targets() []Target {
+	targets := make([]Target, len(c.Targets))
+	for i, tc := range c.Targets {
+		targets[i] = c.target(tc)
+	}
+	return targets
+}
+
+func (c *Config) maintenanceWindows() (map[string][]MaintenanceWindow, error) {
+	windows := make(map[string][]MaintenanceWindow)
+	for _, tc := range c.Targets {
+		for _, m := range tc.Maintenance {
+			schedule, err := ParseSchedule(m.Schedule)
+			if err != nil {
+				return nil, err
+			}
+			windows[tc.Name] = append(windows[tc.Name], MaintenanceWindow{Schedule: schedule, Duration: m.Duration})
+		}
+	}
+	return windows, nil
+}
+
+// alerter returns the alerter sending to every channel
+func (a AlertingConfig) alerter(client *http.Client) Alerter {
+	var alerters MultiAlerter
+	for _, ch := range a.Channels {
+		switch ch.Type {
+		case ChannelLog:
+			alerters = append(alerters, LogAlerter{})
+		case ChannelWebhook:
+			alerters = append(alerters, &WebhookAlerter{URL: ch.URL, Client: client})
+		}
+	}
+	if len(alerters) == 0 {
+		return nil
+	}
+	return alerters
+}
+
+// loadConfig reads the config file, or the environment when there is none
+func (server *Server) loadConfig() (*Config, error) {
+	if server.ConfigPath == "" {
+		return ConfigFromEnv()
+	}
+	return LoadConfig(server.ConfigPath)
+}
+
+// Reload applies the config file again. An invalid file leaves the current
+// configuration in place.
+func (server *Server) Reload() error {
+	if server.ConfigPath == "" {
+		return errors.New("no config file to reload, set MONITOR_CONFIG")
+	}
+	cfg, err := LoadConfig(server.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return server.Monitor.Apply(cfg)
+}
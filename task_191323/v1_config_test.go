@@ -0,0 +1,193 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestConfig(t *testing.T) {
+	writeConfig := func(t *testing.T, path, yaml string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Example config is valid", func(t *testing.T) {
+			cfg, err := LoadConfig("monitor.example.yaml")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Interval != time.Minute || cfg.Retry.MaxDelay != 2*time.Second || len(cfg.Targets) != 2 {
+				t.Errorf("Unexpected config %+v", cfg)
+			}
+			targets := cfg.targets()
+			if targets[1].ProbeType() != ProbeTCP || targets[0].Timeout != 10*time.Second || targets[0].DependsOn[0] != "db" {
+				t.Errorf("Unexpected targets %+v", targets)
+			}
+		}},
+		{"Defaults fill what the file leaves out", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "monitor.yaml")
+			writeConfig(t, path, "targets:\n  - name: app\n    url: http://app/health\n")
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Breaker.Threshold != 3 || cfg.Timeout != 15*time.Second || cfg.Alerting.Channels[0].Type != ChannelLog {
+				t.Errorf("Expected the defaults, got %+v", cfg)
+			}
+		}},
+		{"Every problem is reported", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "monitor.yaml")
+			writeConfig(t, path, `
+interval: 0s
+breaker:
+  threshold: 0
+alerting:
+  channels:
+    - type: webhook
+targets:
+  - name: app
+    url: tcp://app
+    maintenance:
+      - schedule: "0 25 * * *"
+        duration: 1h
+  - name: app
+    url: http://app
+`)
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("Expected the config to be rejected")
+			}
+			for _, want := range []string{"interval", "breaker.threshold", "webhook", "host:port", "hour", "defined twice"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected %q in %v", want, err)
+				}
+			}
+		}},
+		{"Unknown fields are rejected", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "monitor.yaml")
+			writeConfig(t, path, "intervall: 30s\ntargets:\n  - name: app\n    url: http://app\n")
+			if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "intervall") {
+				t.Errorf("Expected the misspelled field to be rejected, got %v", err)
+			}
+		}},
+		{"Reload keeps the state of kept targets", func(t *testing.T) {
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 0, 0)
+			m.Probers["fake"] = ProberFunc(func(ctx context.Context, t Target) (int, error) {
+				return -1, fmt.Errorf("down")
+			})
+			cfg := DefaultConfig()
+			cfg.Retry = RetryPolicy{}
+			cfg.Breaker.Threshold = 1
+			cfg.Targets = []TargetConfig{{Name: "app", Type: "tcp", URL: "app:80"}}
+			if err := m.Apply(cfg); err != nil {
+				t.Fatal(err)
+			}
+			m.Probers[ProbeTCP] = m.Probers["fake"]
+			m.CheckAll(context.Background())
+
+			cfg.Interval = time.Hour
+			cfg.Targets = append(cfg.Targets, TargetConfig{Name: "db", Type: "tcp", URL: "db:5432"})
+			if err := m.Apply(cfg); err != nil {
+				t.Fatal(err)
+			}
+			dockers := m.CheckAll(context.Background())
+			if len(dockers) != 2 || dockers[0].Breaker != BreakerOpen || dockers[1].Attempts != 1 {
+				t.Errorf("Expected app to keep its open breaker and db to be probed, got %+v", dockers)
+			}
+			if m.interval() != time.Hour {
+				t.Errorf("Expected the new interval, got %v", m.interval())
+			}
+
+			cfg.Targets[0].URL = "app"
+			if err := m.Apply(cfg); err == nil || !m.HasTarget("db") {
+				t.Errorf("Expected an invalid config to be rejected and the current one kept, got %v", err)
+			}
+		}},
+		{"Admin reload endpoint", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "monitor.yaml")
+			writeConfig(t, path, "targets:\n  - name: app\n    url: http://app\n")
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := &Server{
+				Monitor:    NewMonitor(&http.Client{}, RetryPolicy{}, 0, 0),
+				Auth:       &Authenticator{apiKeys: map[string]string{"admin-key": ScopeAdmin}},
+				Router:     mux.NewRouter(),
+				ConfigPath: path,
+			}
+			server.Monitor.Apply(cfg)
+			server.initializeRoutes()
+			reload := func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest("POST", "/admin/reload", nil)
+				r.Header.Set("X-API-Key", "admin-key")
+				server.Router.ServeHTTP(w, r)
+				return w
+			}
+
+			writeConfig(t, path, "targets:\n  - name: app\n    url: http://app\n  - name: db\n    url: tcp://db:5432\n")
+			if w := reload(); w.Code != http.StatusOK || !server.Monitor.HasTarget("db") {
+				t.Fatalf("Expected db to be added, got %d: %s", w.Code, w.Body.String())
+			}
+
+			writeConfig(t, path, "targets: []\n")
+			if w := reload(); w.Code != http.StatusUnprocessableEntity || !server.Monitor.HasTarget("db") {
+				t.Errorf("Expected the invalid file to be rejected, got %d: %s", w.Code, w.Body.String())
+			}
+		}},
+		{"Environment configuration without a file", func(t *testing.T) {
+			t.Setenv("DOCKER_API_JWT", "http://localhost:8080/api/docker")
+			t.Setenv("MONITOR_TARGETS", "db=tcp://db:5432")
+			t.Setenv("TARGET_DEPENDENCIES", "rest_api_jwt_pdv_app=db")
+			t.Setenv("MAINTENANCE_WINDOWS", "db|0 3 * * 0|1h")
+			t.Setenv("CHECK_TIMEOUT", "7")
+			cfg, err := ConfigFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Targets) != 2 || cfg.Timeout != 7*time.Second || cfg.Targets[1].Maintenance[0].Schedule != "0 3 * * 0" {
+				t.Errorf("Unexpected config %+v", cfg)
+			}
+			if err := (&Server{}).Reload(); err == nil {
+				t.Error("Expected reloading without a config file to fail")
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
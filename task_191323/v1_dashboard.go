@@ -0,0 +1,185 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//go:embed v1_dashboard.html
+var dashboardHTML []byte
+
+const (
+	// historySize is how many results per target the history keeps, an hour at the default interval
+	historySize = 60
+	// eventsHeartbeat keeps idle event streams from being closed by proxies
+	eventsHeartbeat = 15 * time.Second
+)
+
+// History keeps the latest results of every target, oldest first
+type History struct {
+	mu      sync.Mutex
+	size    int
+	results map[string][]Docker
+}
+
+func NewHistory(size int) *History {
+	return &History{size: size, results: make(map[string][]Docker)}
+}
+
+func (h *History) Add(dockers []Docker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, d := range dockers {
+		results := append(h.results[d.Target], d)
+		if len(results) > h.size {
+			results = append(results[:0:0], results[len(results)-h.size:]...)
+		}
+		h.results[d.Target] = results
+	}
+}
+
+// Results returns a copy of the history of target
+func (h *History) Results(target string) []Docker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Docker(nil), h.results[target]...)
+}
+
+// Broadcaster passes every round of results to the subscribed event streams
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan struct{}]struct{})}
+}
+
+// Subscribe returns a channel signalled after every round, and the function ending the subscription
+func (b *Broadcaster) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Publish signals the subscribers. Subscribers that haven't caught up with the
+// previous round are signalled once, they read the latest state anyway.
+func (b *Broadcaster) Publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// HistoryPoint is a result in the latency sparkline of a target
+type HistoryPoint struct {
+	At         time.Time `json:"at"`
+	ResponseMS int64     `json:"ms"`
+	Running    bool      `json:"running"`
+}
+
+// DashboardTile is the state of a target on the dashboard: its latest result, if
+// it has been checked yet, and its recent history
+type DashboardTile struct {
+	Target  string         `json:"target"`
+	Latest  *Docker        `json:"latest,omitempty"`
+	History []HistoryPoint `json:"history"`
+}
+
+// Dashboard returns a tile per target, in configuration order
+func (m *Monitor) Dashboard() []DashboardTile {
+	targets := m.round().targets
+	tiles := make([]DashboardTile, len(targets))
+	for i, t := range targets {
+		results := m.History.Results(t.Name)
+		tiles[i] = DashboardTile{Target: t.Name, History: make([]HistoryPoint, len(results))}
+		for j, d := range results {
+			tiles[i].History[j] = HistoryPoint{At: d.CheckedAt, ResponseMS: d.ResponseTime.Milliseconds(), Running: d.Running}
+		}
+		if len(results) > 0 {
+			tiles[i].Latest = &results[len(results)-1]
+		}
+	}
+	return tiles
+}
+
+// GetDashboard serves the status dashboard page
+func (server *Server) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// GetEvents streams the dashboard tiles as server-sent "status" events, right away
+// and after every round of checks. EventSource can't send the X-API-Key header,
+// so browsers authenticate with basic auth.
+func (server *Server) GetEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ERROR(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	rounds, unsubscribe := server.Monitor.Events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	send := func() bool {
+		data, err := json.Marshal(server.Monitor.Dashboard())
+		if err != nil {
+			log.Printf("Error encoding dashboard: %v", err)
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	if !send() {
+		return
+	}
+	for {
+		select {
+		case <-rounds:
+			if !send() {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,131 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDashboard(t *testing.T) {
+	newServer := func() *Server {
+		m := NewMonitor(&http.Client{}, RetryPolicy{}, 3, time.Minute, Target{Name: "app", Type: "fake"}, Target{Name: "db", Type: "fake"})
+		m.Probers["fake"] = ProberFunc(func(ctx context.Context, t Target) (int, error) {
+			if t.Name == "db" {
+				return -1, fmt.Errorf("connection refused")
+			}
+			return 0, nil
+		})
+		server := &Server{Monitor: m, Auth: &Authenticator{}, Router: mux.NewRouter()}
+		server.initializeRoutes()
+		return server
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"History keeps the latest results", func(t *testing.T) {
+			h := NewHistory(3)
+			for i := 0; i < 5; i++ {
+				h.Add([]Docker{{Target: "app", Attempts: i}})
+			}
+			results := h.Results("app")
+			if len(results) != 3 || results[0].Attempts != 2 || results[2].Attempts != 4 {
+				t.Errorf("Expected the last 3 results, got %+v", results)
+			}
+		}},
+		{"Dashboard tiles follow the targets", func(t *testing.T) {
+			server := newServer()
+			tiles := server.Monitor.Dashboard()
+			if len(tiles) != 2 || tiles[0].Latest != nil || len(tiles[0].History) != 0 {
+				t.Fatalf("Expected pending tiles before the first check, got %+v", tiles)
+			}
+
+			server.Monitor.CheckAll(context.Background())
+			server.Monitor.CheckAll(context.Background())
+			tiles = server.Monitor.Dashboard()
+			if len(tiles[1].History) != 2 || tiles[1].Latest.Error != "connection refused" || tiles[1].History[0].Running {
+				t.Errorf("Expected db's history and last error, got %+v", tiles[1])
+			}
+		}},
+		{"Dashboard page is served", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newServer().Router.ServeHTTP(w, httptest.NewRequest("GET", "/dashboard", nil))
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+				t.Fatalf("Expected the HTML page, got %d %q", w.Code, w.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(w.Body.String(), `new EventSource("events")`) {
+				t.Error("Expected the page to subscribe to the event stream")
+			}
+		}},
+		{"Event stream sends every round", func(t *testing.T) {
+			server := newServer()
+			ts := httptest.NewServer(server.Router)
+			defer ts.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL+"/events", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Fatalf("Expected an event stream, got %q", ct)
+			}
+
+			lines := bufio.NewScanner(resp.Body)
+			next := func() []DashboardTile {
+				for lines.Scan() {
+					if data, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+						var tiles []DashboardTile
+						if err := json.Unmarshal([]byte(data), &tiles); err != nil {
+							t.Fatalf("Invalid event data %q: %v", data, err)
+						}
+						return tiles
+					}
+				}
+				t.Fatalf("Stream ended: %v", lines.Err())
+				return nil
+			}
+
+			if tiles := next(); len(tiles) != 2 || tiles[0].Latest != nil {
+				t.Errorf("Expected the initial state, got %+v", tiles)
+			}
+			server.Monitor.CheckAll(context.Background())
+			if tiles := next(); tiles[0].Latest == nil || !tiles[0].Latest.Running {
+				t.Errorf("Expected the results of the round, got %+v", tiles)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
@@ -0,0 +1,142 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Target statuses. A target failing while one of its dependencies is down is
+// degraded by the upstream outage: it isn't alerted on its own, the dependency is.
+const (
+	StatusUp               = "up"
+	StatusDown             = "down"
+	StatusDegradedUpstream = "degraded (upstream)"
+)
+
+// ParseDependencies parses semicolon-separated target=dependency,dependency
+// entries, e.g. "app=db,cache;gateway=app", into dependencies by target
+func ParseDependencies(spec string) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid dependency %q, expected target=dependency,dependency", entry)
+		}
+		for _, dep := range strings.Split(list, ",") {
+			if dep = strings.TrimSpace(dep); dep != "" {
+				deps[name] = append(deps[name], dep)
+			}
+		}
+	}
+	return deps, nil
+}
+
+// ValidateDependencies checks that targets only depend on known targets, and not
+// on themselves through a cycle
+func ValidateDependencies(targets []Target) error {
+	byName := make(map[string]Target, len(targets))
+	for _, t := range targets {
+		byName[t.Name] = t
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(targets))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle %s", strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range byName[name].DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("target %s depends on unknown target %q", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+
+	for _, t := range targets {
+		if err := visit(t.Name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markUpstream sets the status of the results, in target order, and lists the
+// root causes of the targets degraded by a dependency outage: the failed
+// dependencies that aren't degraded themselves.
+func markUpstream(targets []*monitoredTarget, dockers []Docker) {
+	index := make(map[string]int, len(targets))
+	for i, t := range targets {
+		index[t.Name] = i
+	}
+
+	resolved := make([]bool, len(dockers))
+	var resolve func(i int)
+	resolve = func(i int) {
+		if resolved[i] {
+			return
+		}
+		// Marked first, so a cycle that slipped past validation ends here
+		resolved[i] = true
+
+		d := &dockers[i]
+		if d.Running {
+			d.Status = StatusUp
+			return
+		}
+		d.Status = StatusDown
+
+		var causes []string
+		for _, dep := range targets[i].DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				continue
+			}
+			resolve(j)
+			switch dockers[j].Status {
+			case StatusDown:
+				causes = appendUnique(causes, dep)
+			case StatusDegradedUpstream:
+				for _, cause := range dockers[j].Upstream {
+					causes = appendUnique(causes, cause)
+				}
+			}
+		}
+		if len(causes) > 0 {
+			d.Status = StatusDegradedUpstream
+			d.Upstream = causes
+		}
+	}
+
+	for i := range dockers {
+		resolve(i)
+	}
+}
+
+func appendUnique(values []string, v string) []string {
+	for _, existing := range values {
+		if existing == v {
+			return values
+		}
+	}
+	return append(values, v)
+}
@@ -0,0 +1,128 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDependencies(t *testing.T) {
+	// newMonitor probes every target with a fake prober, failing the targets in down
+	newMonitor := func(down map[string]bool, targets ...Target) (*Monitor, *recordingAlerter) {
+		for i := range targets {
+			targets[i].Type = "fake"
+		}
+		alerter := &recordingAlerter{}
+		m := NewMonitor(&http.Client{}, RetryPolicy{}, 10, time.Minute, targets...)
+		m.Alerter = alerter
+		m.Probers["fake"] = ProberFunc(func(ctx context.Context, t Target) (int, error) {
+			if down[t.Name] {
+				return -1, errors.New("connection refused")
+			}
+			return 0, nil
+		})
+		return m, alerter
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Dependencies are parsed and validated", func(t *testing.T) {
+			deps, err := ParseDependencies("app=db, cache; gateway=app")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := map[string][]string{"app": {"db", "cache"}, "gateway": {"app"}}; !reflect.DeepEqual(deps, want) {
+				t.Errorf("Expected %v, got %v", want, deps)
+			}
+			if _, err := ParseDependencies("app"); err == nil {
+				t.Error("Expected an error for an entry without dependencies")
+			}
+
+			targets := []Target{{Name: "db"}, {Name: "app", DependsOn: []string{"db"}}}
+			if err := ValidateDependencies(targets); err != nil {
+				t.Errorf("Expected valid dependencies, got %v", err)
+			}
+			targets[0].DependsOn = []string{"app"}
+			if err := ValidateDependencies(targets); err == nil || !strings.Contains(err.Error(), "cycle") {
+				t.Errorf("Expected a cycle error, got %v", err)
+			}
+			targets[0].DependsOn = []string{"queue"}
+			if err := ValidateDependencies(targets); err == nil || !strings.Contains(err.Error(), "unknown") {
+				t.Errorf("Expected an unknown target error, got %v", err)
+			}
+		}},
+		{"Only the root cause is alerted", func(t *testing.T) {
+			m, alerter := newMonitor(map[string]bool{"db": true, "app": true, "gateway": true},
+				Target{Name: "gateway", DependsOn: []string{"app"}},
+				Target{Name: "app", DependsOn: []string{"db"}},
+				Target{Name: "db"})
+
+			dockers := m.CheckAll(context.Background())
+			for _, d := range dockers[:2] {
+				if d.Status != StatusDegradedUpstream || !reflect.DeepEqual(d.Upstream, []string{"db"}) {
+					t.Errorf("Expected %s to be degraded by db, got %q %v", d.Target, d.Status, d.Upstream)
+				}
+			}
+			if dockers[2].Status != StatusDown {
+				t.Errorf("Expected db to be down, got %q", dockers[2].Status)
+			}
+			if len(alerter.alerts) != 1 || alerter.alerts[0].Target != "db" {
+				t.Errorf("Expected a single alert for db, got %v", alerter.alerts)
+			}
+		}},
+		{"Dependents still down after the dependency recovers are alerted", func(t *testing.T) {
+			down := map[string]bool{"db": true, "app": true}
+			m, alerter := newMonitor(down, Target{Name: "app", DependsOn: []string{"db"}}, Target{Name: "db"})
+			m.CheckAll(context.Background())
+
+			down["db"] = false
+			dockers := m.CheckAll(context.Background())
+			if dockers[0].Status != StatusDown || len(dockers[0].Upstream) != 0 {
+				t.Errorf("Expected app to be down on its own, got %q %v", dockers[0].Status, dockers[0].Upstream)
+			}
+			var got []string
+			for _, a := range alerter.alerts {
+				got = append(got, fmt.Sprintf("%s:%v", a.Target, a.Running))
+			}
+			if want := []string{"db:false", "app:false", "db:true"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected alerts %v, got %v", want, got)
+			}
+		}},
+		{"Running dependents stay up", func(t *testing.T) {
+			m, _ := newMonitor(map[string]bool{"cache": true},
+				Target{Name: "app", DependsOn: []string{"cache"}}, Target{Name: "cache"})
+			dockers := m.CheckAll(context.Background())
+			if dockers[0].Status != StatusUp || dockers[0].Upstream != nil {
+				t.Errorf("Expected app to be up, got %q %v", dockers[0].Status, dockers[0].Upstream)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
@@ -0,0 +1,445 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Target describes a single endpoint watched by the monitor. Type selects the
+// probe, see ProbeType. DependsOn names the targets it needs to work.
+type Target struct {
+	Name      string        `json:"name"`
+	Type      string        `json:"type,omitempty"`
+	URL       string        `json:"url"`
+	Timeout   time.Duration `json:"timeout"`
+	DependsOn []string      `json:"dependsOn,omitempty"`
+}
+
+// RetryPolicy controls how many times a failed probe is retried and how long to wait between attempts
+type RetryPolicy struct {
+	MaxRetries int           `yaml:"maxRetries"`
+	BaseDelay  time.Duration `yaml:"baseDelay"`
+	MaxDelay   time.Duration `yaml:"maxDelay"`
+}
+
+// Backoff returns the jittered delay before the given retry attempt (1-based).
+// It uses "full jitter": a random duration between zero and the capped exponential delay.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 || attempt <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay << uint(attempt-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// CircuitBreaker stops probing a target after repeated failures until a cooldown has passed
+type CircuitBreaker struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: threshold,
+		Cooldown:         cooldown,
+		state:            BreakerClosed,
+		now:              time.Now,
+	}
+}
+
+// Allow reports whether a probe may be sent. An open breaker moves to half-open
+// once the cooldown has elapsed, letting a single trial probe through.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.Cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// A trial probe is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.FailureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Configure changes the threshold and cooldown, keeping the current state
+func (b *CircuitBreaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.FailureThreshold = threshold
+	b.Cooldown = cooldown
+}
+
+// Reset forces the breaker back to closed, e.g. after a manual remediation
+func (b *CircuitBreaker) Reset() {
+	b.RecordSuccess()
+}
+
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+type monitoredTarget struct {
+	Target
+	// The breaker and alert state are kept across configuration reloads for as long
+	// as the target stays configured, so a reload neither closes an open breaker nor
+	// repeats an alert.
+	breaker *CircuitBreaker
+	alerts  *alertState
+}
+
+type alertState struct {
+	mu   sync.Mutex
+	down bool // as last alerted
+}
+
+// defaultInterval is how often Run checks the targets when no interval is configured
+const defaultInterval = time.Minute
+
+// Monitor probes every configured target with retries, timeouts and a per-target circuit breaker
+type Monitor struct {
+	Client   *http.Client
+	Store    *ResultStore      // optional, results are persisted when set
+	Probers  map[string]Prober // by probe type
+	Silences *Silences
+	History  *History     // recent results per target, drawn as the dashboard sparklines
+	Events   *Broadcaster // signalled after every round of checks
+
+	// mu guards the configuration below, which Apply replaces. Each round of checks
+	// runs with the configuration it started with.
+	mu       sync.RWMutex
+	Retry    RetryPolicy
+	Alerter  Alerter       // optional, notified when a target goes down or recovers
+	Interval time.Duration // how often Run checks the targets, defaultInterval when zero
+	// Maintenance holds the maintenance windows by target name. Alerts are held
+	// back during the windows and the ad-hoc Silences.
+	Maintenance map[string][]MaintenanceWindow
+	targets     []*monitoredTarget
+	reloaded    chan struct{}
+}
+
+func NewMonitor(client *http.Client, retry RetryPolicy, breakerThreshold int, breakerCooldown time.Duration, targets ...Target) *Monitor {
+	m := &Monitor{
+		Client:   client,
+		Retry:    retry,
+		Probers:  DefaultProbers(client),
+		Silences: &Silences{},
+		History:  NewHistory(historySize),
+		Events:   NewBroadcaster(),
+		reloaded: make(chan struct{}, 1),
+	}
+	for _, t := range targets {
+		m.targets = append(m.targets, &monitoredTarget{
+			Target:  t,
+			breaker: NewCircuitBreaker(breakerThreshold, breakerCooldown),
+			alerts:  &alertState{},
+		})
+	}
+	return m
+}
+
+// round is the configuration a round of checks runs with
+type round struct {
+	retry       RetryPolicy
+	alerter     Alerter
+	maintenance map[string][]MaintenanceWindow
+	targets     []*monitoredTarget
+}
+
+func (m *Monitor) round() round {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return round{retry: m.Retry, alerter: m.Alerter, maintenance: m.Maintenance, targets: m.targets}
+}
+
+// Apply validates cfg and switches to its targets and settings. Targets kept
+// under the same name keep their circuit breaker and alert state, and checks
+// already running finish with the previous configuration.
+func (m *Monitor) Apply(cfg *Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	maintenance, err := cfg.maintenanceWindows()
+	if err != nil {
+		return err
+	}
+	alerter := cfg.Alerting.alerter(m.Client)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := make(map[string]*monitoredTarget, len(m.targets))
+	for _, t := range m.targets {
+		previous[t.Name] = t
+	}
+	targets := make([]*monitoredTarget, 0, len(cfg.Targets))
+	for _, t := range cfg.targets() {
+		mt, ok := previous[t.Name]
+		if ok {
+			mt.breaker.Configure(cfg.Breaker.Threshold, cfg.Breaker.Cooldown)
+			mt = &monitoredTarget{Target: t, breaker: mt.breaker, alerts: mt.alerts}
+		} else {
+			mt = &monitoredTarget{Target: t, breaker: NewCircuitBreaker(cfg.Breaker.Threshold, cfg.Breaker.Cooldown), alerts: &alertState{}}
+		}
+		targets = append(targets, mt)
+	}
+
+	m.targets = targets
+	m.Retry = cfg.Retry
+	m.Alerter = alerter
+	m.Interval = cfg.Interval
+	m.Maintenance = maintenance
+
+	select {
+	case m.reloaded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// CheckAll probes all targets concurrently and returns one result per target, in configuration order
+func (m *Monitor) CheckAll(ctx context.Context) []Docker {
+	r := m.round()
+	dockers := make([]Docker, len(r.targets))
+	var wg sync.WaitGroup
+
+	for i, t := range r.targets {
+		wg.Add(1)
+		go func(i int, t *monitoredTarget) {
+			defer wg.Done()
+			dockers[i] = m.check(ctx, r.retry, t)
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	markUpstream(r.targets, dockers)
+	for i, t := range r.targets {
+		m.notify(ctx, r, t, &dockers[i])
+	}
+	m.History.Add(dockers)
+	m.Events.Publish()
+
+	if m.Store != nil {
+		if err := m.Store.Save(ctx, dockers); err != nil {
+			log.Printf("Error saving check results: %v", err)
+		}
+	}
+	return dockers
+}
+
+// Run checks all targets every interval until the context is cancelled. A new
+// configuration is checked right away, and at its interval from then on.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval())
+	defer ticker.Stop()
+
+	for {
+		m.CheckAll(ctx)
+		select {
+		case <-ticker.C:
+		case <-m.reloaded:
+			ticker.Reset(m.interval())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Monitor) interval() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.Interval <= 0 {
+		return defaultInterval
+	}
+	return m.Interval
+}
+
+// HasTarget reports whether a target with the name is monitored
+func (m *Monitor) HasTarget(name string) bool {
+	for _, t := range m.round().targets {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ResetBreakers closes the circuit breaker of every target
+func (m *Monitor) ResetBreakers() {
+	for _, t := range m.round().targets {
+		t.breaker.Reset()
+	}
+}
+
+func (m *Monitor) check(ctx context.Context, retry RetryPolicy, t *monitoredTarget) Docker {
+	docker := Docker{
+		Name:   fmt.Sprintf("Docker %s com problema", t.Name),
+		Target: t.Name,
+	}
+
+	if !t.breaker.Allow() {
+		docker.CheckedAt = time.Now()
+		docker.StatusCode = -1
+		docker.Breaker = t.breaker.State()
+		docker.Error = "circuit breaker open, probe skipped"
+		return docker
+	}
+
+	start := time.Now()
+	var err error
+	for attempt := 0; attempt <= retry.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(retry.Backoff(attempt)):
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		docker.Attempts = attempt + 1
+		docker.StatusCode, err = m.probe(ctx, t.Target)
+		if err == nil {
+			break
+		}
+		log.Printf("Probe %d/%d for %s failed: %v", attempt+1, retry.MaxRetries+1, t.Name, err)
+	}
+
+	docker.CheckedAt = time.Now()
+	docker.ResponseTime = time.Since(start)
+
+	if err != nil {
+		t.breaker.RecordFailure()
+		docker.Error = err.Error()
+	} else {
+		t.breaker.RecordSuccess()
+		docker.Name = fmt.Sprintf("Docker %s rodando normalmente", t.Name)
+		docker.Running = true
+	}
+	docker.Breaker = t.breaker.State()
+	return docker
+}
+
+// silence returns what mutes the alerts of the target at t, if anything
+func (m *Monitor) silence(r round, target string, t time.Time) *Silence {
+	if m.Silences != nil {
+		if s, ok := m.Silences.For(target, t); ok {
+			return &s
+		}
+	}
+	for _, w := range r.maintenance[target] {
+		if end, ok := w.End(t); ok {
+			return &Silence{Target: target, Reason: "maintenance window " + w.Schedule.String(), From: end.Add(-w.Duration), Until: end}
+		}
+	}
+	return nil
+}
+
+// notify records the silence of the result and alerts when the target went down
+// or recovered. Changes are held back while silenced or degraded by an upstream
+// outage, so a target still down when that ends is alerted then.
+func (m *Monitor) notify(ctx context.Context, r round, t *monitoredTarget, docker *Docker) {
+	docker.Silence = m.silence(r, t.Name, docker.CheckedAt)
+	if r.alerter == nil || docker.Silence != nil || docker.Status == StatusDegradedUpstream {
+		return
+	}
+
+	t.alerts.mu.Lock()
+	changed := t.alerts.down == docker.Running
+	t.alerts.down = !docker.Running
+	t.alerts.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	alert := Alert{Target: t.Name, Running: docker.Running, Error: docker.Error, CheckedAt: docker.CheckedAt}
+	if err := r.alerter.Alert(ctx, alert); err != nil {
+		log.Printf("Error sending alert for %s: %v", t.Name, err)
+	}
+}
+
+// probe runs a single probe of the target type, bounded by the target timeout
+func (m *Monitor) probe(ctx context.Context, t Target) (int, error) {
+	prober, ok := m.Probers[t.ProbeType()]
+	if !ok {
+		return -1, fmt.Errorf("unknown probe type %q", t.ProbeType())
+	}
+
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	return prober.Probe(ctx, t)
+}
+
+// Helper function to get environment variable as int with default value
+func getEnvInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		log.Printf("Invalid value for environment variable %s: %s. Using default value %d.", key, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
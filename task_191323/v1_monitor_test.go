@@ -0,0 +1,146 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMonitorRetryAndBreaker(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Backoff stays within capped exponential delay", func(t *testing.T) {
+			p := RetryPolicy{MaxRetries: 5, BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond}
+			for attempt := 1; attempt <= 5; attempt++ {
+				for i := 0; i < 20; i++ {
+					if d := p.Backoff(attempt); d < 0 || d > 40*time.Millisecond {
+						t.Fatalf("Backoff(%d) = %v, out of range", attempt, d)
+					}
+				}
+			}
+			if d := p.Backoff(0); d != 0 {
+				t.Errorf("Expected no delay before the first attempt, got %v", d)
+			}
+		}},
+		{"Transient failure is retried", func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			m := NewMonitor(&http.Client{}, RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, 3, time.Minute,
+				Target{Name: "app", URL: server.URL, Timeout: time.Second})
+			dockers := m.CheckAll(context.Background())
+
+			if len(dockers) != 1 {
+				t.Fatalf("Expected 1 result, got %d", len(dockers))
+			}
+			if !dockers[0].Running || dockers[0].Attempts != 2 {
+				t.Errorf("Expected running after 2 attempts, got running=%v attempts=%d", dockers[0].Running, dockers[0].Attempts)
+			}
+			if dockers[0].Breaker != BreakerClosed {
+				t.Errorf("Expected breaker %q, got %q", BreakerClosed, dockers[0].Breaker)
+			}
+		}},
+		{"Per-target timeout aborts slow probes", func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+				}
+			}))
+			defer server.Close()
+
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 3, time.Minute,
+				Target{Name: "slow", URL: server.URL, Timeout: 50 * time.Millisecond})
+			start := time.Now()
+			dockers := m.CheckAll(context.Background())
+
+			if time.Since(start) >= time.Second {
+				t.Errorf("Probe was not bounded by the target timeout")
+			}
+			if dockers[0].Running || dockers[0].Error == "" {
+				t.Errorf("Expected a failed probe with an error, got %+v", dockers[0])
+			}
+		}},
+		{"Breaker opens after threshold and skips probes", func(t *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer server.Close()
+
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 2, time.Minute,
+				Target{Name: "dead", URL: server.URL, Timeout: time.Second})
+			m.CheckAll(context.Background())
+			dockers := m.CheckAll(context.Background())
+			if dockers[0].Breaker != BreakerOpen {
+				t.Fatalf("Expected breaker %q, got %q", BreakerOpen, dockers[0].Breaker)
+			}
+
+			dockers = m.CheckAll(context.Background())
+			if got := atomic.LoadInt32(&calls); got != 2 {
+				t.Errorf("Expected open breaker to skip the probe, target was hit %d times", got)
+			}
+			if dockers[0].StatusCode != -1 || dockers[0].Running {
+				t.Errorf("Expected skipped probe to report failure, got %+v", dockers[0])
+			}
+		}},
+		{"Breaker half-opens after cooldown", func(t *testing.T) {
+			now := time.Now()
+			b := NewCircuitBreaker(1, 10*time.Second)
+			b.now = func() time.Time { return now }
+
+			b.RecordFailure()
+			if b.Allow() {
+				t.Fatal("Open breaker should not allow probes during cooldown")
+			}
+
+			now = now.Add(11 * time.Second)
+			if !b.Allow() || b.State() != BreakerHalfOpen {
+				t.Fatalf("Expected half-open trial after cooldown, got state %q", b.State())
+			}
+			if b.Allow() {
+				t.Error("Half-open breaker should only allow a single trial probe")
+			}
+
+			b.RecordSuccess()
+			if b.State() != BreakerClosed {
+				t.Errorf("Expected breaker to close after a successful trial, got %q", b.State())
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
@@ -0,0 +1,310 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Probe types, selected per target with Target.Type or the scheme of Target.URL
+const (
+	ProbeHTTP = "http"
+	ProbeTCP  = "tcp"
+	ProbeICMP = "icmp"
+	ProbeGRPC = "grpc"
+)
+
+// Prober checks a single target once. The returned code depends on the protocol:
+// the HTTP status, the gRPC serving status, 0 for TCP and ICMP, and -1 when the
+// target could not be reached at all.
+type Prober interface {
+	Probe(ctx context.Context, t Target) (int, error)
+}
+
+// ProberFunc adapts a function to the Prober interface
+type ProberFunc func(ctx context.Context, t Target) (int, error)
+
+func (f ProberFunc) Probe(ctx context.Context, t Target) (int, error) {
+	return f(ctx, t)
+}
+
+// DefaultProbers returns a prober for every probe type, HTTP probes going through client
+func DefaultProbers(client *http.Client) map[string]Prober {
+	return map[string]Prober{
+		ProbeHTTP: &HTTPProber{Client: client},
+		ProbeTCP:  &TCPProber{},
+		ProbeICMP: &ICMPProber{},
+		ProbeGRPC: &GRPCProber{},
+	}
+}
+
+// ProbeType returns the probe type of the target: its Type when set, otherwise the
+// scheme of its URL, with https and URLs without a scheme probed over HTTP
+func (t Target) ProbeType() string {
+	if t.Type != "" {
+		return strings.ToLower(t.Type)
+	}
+	scheme, _, ok := strings.Cut(t.URL, "://")
+	if !ok {
+		return ProbeHTTP
+	}
+	switch scheme = strings.ToLower(scheme); scheme {
+	case "https":
+		return ProbeHTTP
+	default:
+		return scheme
+	}
+}
+
+// address returns the host (and port) part of the target URL, which may leave the scheme out
+func (t Target) address() string {
+	if _, rest, ok := strings.Cut(t.URL, "://"); ok {
+		host, _, _ := strings.Cut(rest, "/")
+		return host
+	}
+	return t.URL
+}
+
+// ParseTargets parses a comma-separated list of name=url targets, e.g.
+// "db=tcp://db:5432,gateway=icmp://10.0.0.1,api=grpc://api:50051/orders.Orders".
+// The URL scheme selects the probe type, see Target.ProbeType.
+func ParseTargets(spec string, timeout time.Duration) ([]Target, error) {
+	var targets []Target
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, rawURL, ok := strings.Cut(entry, "=")
+		name, rawURL = strings.TrimSpace(name), strings.TrimSpace(rawURL)
+		if !ok || name == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid target %q, expected name=url", entry)
+		}
+
+		t := Target{Name: name, URL: rawURL, Timeout: timeout}
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// validate checks that the target can be probed
+func (t Target) validate() error {
+	if t.URL == "" {
+		return fmt.Errorf("target %s: url is required", t.Name)
+	}
+	switch t.ProbeType() {
+	case ProbeHTTP, ProbeICMP, ProbeGRPC:
+	case ProbeTCP:
+		if _, _, err := net.SplitHostPort(t.address()); err != nil {
+			return fmt.Errorf("target %s: TCP probes need a host:port address: %w", t.Name, err)
+		}
+	default:
+		return fmt.Errorf("target %s: unknown probe type %q", t.Name, t.ProbeType())
+	}
+	return nil
+}
+
+// HTTPProber sends a GET request and expects a 200 response
+type HTTPProber struct {
+	Client *http.Client
+}
+
+func (p *HTTPProber) Probe(ctx context.Context, t Target) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return -1, err
+	}
+	defer resp.Body.Close()
+
+	if _, err := ioutil.ReadAll(resp.Body); err != nil {
+		return resp.StatusCode, fmt.Errorf("reading response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// TCPProber succeeds when a TCP connection to the target host:port can be opened
+type TCPProber struct{}
+
+func (p *TCPProber) Probe(ctx context.Context, t Target) (int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.address())
+	if err != nil {
+		return -1, err
+	}
+	conn.Close()
+	return 0, nil
+}
+
+// ICMPProber sends a single echo request. Raw ICMP sockets need root or
+// CAP_NET_RAW, so without them it falls back to unprivileged datagram ICMP
+// sockets (allowed by net.ipv4.ping_group_range on Linux) and finally to the
+// system ping command, which is usually installed setuid.
+type ICMPProber struct{}
+
+// icmpSeq numbers the echo requests, so concurrent probes don't take each other's replies
+var icmpSeq uint32
+
+func (p *ICMPProber) Probe(ctx context.Context, t Target) (int, error) {
+	host := t.address()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return -1, err
+	}
+	var ip net.IP
+	for _, a := range addr {
+		if a.IP.To4() != nil {
+			ip = a.IP
+			break
+		}
+	}
+	if ip == nil {
+		return -1, fmt.Errorf("no IPv4 address for %s", host)
+	}
+
+	err = pingSocket(ctx, "ip4:icmp", &net.IPAddr{IP: ip})
+	if isPermissionError(err) {
+		err = pingSocket(ctx, "udp4", &net.UDPAddr{IP: ip})
+	}
+	if isPermissionError(err) {
+		err = pingCommand(ctx, ip)
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// isPermissionError reports whether the ICMP socket couldn't be opened for lack of
+// privileges, or because the kernel doesn't offer that kind of socket
+func isPermissionError(err error) bool {
+	return errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EPROTONOSUPPORT)
+}
+
+// pingSocket sends an echo request over an ICMP socket of the given network and
+// waits for the matching reply
+func pingSocket(ctx context.Context, network string, dst net.Addr) error {
+	conn, err := icmp.ListenPacket(network, "0.0.0.0")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	seq := int(atomic.AddUint32(&icmpSeq, 1) & 0xffff)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: os.Getpid() & 0xffff, Seq: seq, Data: []byte("docker-monitor")},
+	}
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.WriteTo(wb, dst); err != nil {
+		return err
+	}
+
+	rb := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(rb)
+		if err != nil {
+			return err
+		}
+		reply, err := icmp.ParseMessage(1, rb[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		// Datagram sockets rewrite the ID, so only the sequence number and peer are matched
+		echo, ok := reply.Body.(*icmp.Echo)
+		if ok && echo.Seq == seq && addrIP(peer).Equal(addrIP(dst)) {
+			return nil
+		}
+	}
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
+
+// pingCommand runs the system ping command once
+func pingCommand(ctx context.Context, ip net.IP) error {
+	wait := 5
+	if deadline, ok := ctx.Deadline(); ok {
+		wait = max(1, int(time.Until(deadline).Seconds()))
+	}
+	out, err := exec.CommandContext(ctx, "ping", "-c", "1", "-W", strconv.Itoa(wait), ip.String()).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ping %s: %v: %s", ip, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// GRPCProber calls the standard grpc.health.v1.Health/Check method and expects
+// SERVING. The path of the target URL names the service to check, the server as a
+// whole is checked when it is empty.
+type GRPCProber struct{}
+
+func (p *GRPCProber) Probe(ctx context.Context, t Target) (int, error) {
+	var service string
+	if u, err := url.Parse(t.URL); err == nil && u.Host != "" {
+		service = strings.Trim(u.Path, "/")
+	}
+
+	conn, err := grpc.DialContext(ctx, t.address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return -1, err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return int(resp.Status), fmt.Errorf("health status %s", resp.Status)
+	}
+	return int(resp.Status), nil
+}
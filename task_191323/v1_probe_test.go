@@ -0,0 +1,127 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestProbers(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Probe type comes from Type or the URL scheme", func(t *testing.T) {
+			cases := []struct {
+				target Target
+				want   string
+			}{
+				{Target{URL: "http://app/health"}, ProbeHTTP},
+				{Target{URL: "https://app/health"}, ProbeHTTP},
+				{Target{URL: "TCP://db:5432"}, ProbeTCP},
+				{Target{URL: "icmp://10.0.0.1"}, ProbeICMP},
+				{Target{URL: "grpc://api:50051/orders"}, ProbeGRPC},
+				{Target{Type: "tcp", URL: "db:5432"}, ProbeTCP},
+			}
+			for _, c := range cases {
+				if got := c.target.ProbeType(); got != c.want {
+					t.Errorf("ProbeType(%+v) = %q, want %q", c.target, got, c.want)
+				}
+			}
+		}},
+		{"Targets are parsed from the environment list", func(t *testing.T) {
+			targets, err := ParseTargets("db=tcp://db:5432, gateway=icmp://10.0.0.1,api=grpc://api:50051/orders.Orders", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(targets) != 3 || targets[0].Name != "db" || targets[1].URL != "icmp://10.0.0.1" || targets[2].Timeout != time.Second {
+				t.Errorf("Unexpected targets %+v", targets)
+			}
+
+			for _, spec := range []string{"db", "db=tcp://db", "x=ftp://host"} {
+				if _, err := ParseTargets(spec, time.Second); err == nil {
+					t.Errorf("Expected an error for %q", spec)
+				}
+			}
+		}},
+		{"TCP probe connects to the port", func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := ln.Addr().String()
+
+			if code, err := (&TCPProber{}).Probe(context.Background(), Target{URL: "tcp://" + addr}); err != nil || code != 0 {
+				t.Errorf("Expected an open port to pass, got %d %v", code, err)
+			}
+			ln.Close()
+			if code, err := (&TCPProber{}).Probe(context.Background(), Target{URL: "tcp://" + addr}); err == nil || code != -1 {
+				t.Errorf("Expected a closed port to fail, got %d %v", code, err)
+			}
+		}},
+		{"gRPC probe checks the serving status", func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := grpc.NewServer()
+			healthSrv := health.NewServer()
+			healthSrv.SetServingStatus("orders.Orders", healthpb.HealthCheckResponse_NOT_SERVING)
+			healthpb.RegisterHealthServer(srv, healthSrv)
+			go srv.Serve(ln)
+			defer srv.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			base := "grpc://" + ln.Addr().String()
+			if code, err := (&GRPCProber{}).Probe(ctx, Target{URL: base}); err != nil || code != int(healthpb.HealthCheckResponse_SERVING) {
+				t.Errorf("Expected the server to be serving, got %d %v", code, err)
+			}
+			if _, err := (&GRPCProber{}).Probe(ctx, Target{URL: base + "/orders.Orders"}); err == nil || !strings.Contains(err.Error(), "NOT_SERVING") {
+				t.Errorf("Expected a not serving service to fail, got %v", err)
+			}
+			if _, err := (&GRPCProber{}).Probe(ctx, Target{URL: base + "/unknown"}); err == nil {
+				t.Error("Expected an unknown service to fail")
+			}
+		}},
+		{"ICMP probe pings the loopback address", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			if _, err := (&ICMPProber{}).Probe(ctx, Target{URL: "icmp://127.0.0.1"}); err != nil {
+				t.Skipf("ICMP not available in this environment: %v", err)
+			}
+		}},
+		{"Monitor picks the prober of the target type", func(t *testing.T) {
+			var probed []string
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 3, time.Minute,
+				Target{Name: "db", URL: "tcp://db:5432"},
+				Target{Name: "legacy", Type: "custom", URL: "db:1"})
+			m.Probers[ProbeTCP] = ProberFunc(func(ctx context.Context, t Target) (int, error) {
+				probed = append(probed, t.Name)
+				return 0, nil
+			})
+
+			dockers := m.CheckAll(context.Background())
+			if !dockers[0].Running || len(probed) != 1 || probed[0] != "db" {
+				t.Errorf("Expected db to go through the TCP prober, got %+v (probed %v)", dockers[0], probed)
+			}
+			if dockers[1].Running || !strings.Contains(dockers[1].Error, "unknown probe type") {
+				t.Errorf("Expected an unknown probe type to fail, got %+v", dockers[1])
+			}
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, tc.testFunc)
+	}
+}
@@ -0,0 +1,263 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const createCheckResultsSQL = `
+CREATE TABLE IF NOT EXISTS check_results (
+	id               INTEGER PRIMARY KEY AUTOINCREMENT,
+	target           TEXT    NOT NULL,
+	running          INTEGER NOT NULL,
+	status_code      INTEGER NOT NULL,
+	response_time_ms INTEGER NOT NULL,
+	error            TEXT    NOT NULL DEFAULT '',
+	checked_at       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_check_results_target_time ON check_results (target, checked_at);
+`
+
+// ResultStore persists check results in SQLite so uptime can be reported over time
+type ResultStore struct {
+	DB *sql.DB
+}
+
+func NewResultStore(path string) (*ResultStore, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, avoid "database is locked" under concurrent checks
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(createCheckResultsSQL); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	return &ResultStore{DB: db}, nil
+}
+
+func (s *ResultStore) Save(ctx context.Context, dockers []Docker) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, d := range dockers {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO check_results (target, running, status_code, response_time_ms, error, checked_at) VALUES (?, ?, ?, ?, ?, ?)",
+			d.Target, d.Running, d.StatusCode, d.ResponseTime.Milliseconds(), d.Error, d.CheckedAt.UnixMilli())
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Results returns the checks of a target within [from, to], oldest first
+func (s *ResultStore) Results(ctx context.Context, target string, from, to time.Time) ([]Docker, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		"SELECT running, status_code, response_time_ms, error, checked_at FROM check_results WHERE target = ? AND checked_at BETWEEN ? AND ? ORDER BY checked_at",
+		target, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []Docker
+	for rows.Next() {
+		var d Docker
+		var responseMs, checkedAt int64
+		if err := rows.Scan(&d.Running, &d.StatusCode, &responseMs, &d.Error, &checkedAt); err != nil {
+			return nil, err
+		}
+		d.Target = target
+		d.ResponseTime = time.Duration(responseMs) * time.Millisecond
+		d.CheckedAt = time.UnixMilli(checkedAt)
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
+
+func (s *ResultStore) Close() error {
+	return s.DB.Close()
+}
+
+// Outage is a contiguous run of failed checks
+type Outage struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	Ongoing         bool      `json:"ongoing"`
+	LastError       string    `json:"lastError,omitempty"`
+}
+
+// SLAReport summarizes availability of a target over a period
+type SLAReport struct {
+	Target        string    `json:"target"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Checks        int       `json:"checks"`
+	FailedChecks  int       `json:"failedChecks"`
+	UptimePercent float64   `json:"uptimePercent"`
+	MTTRSeconds   float64   `json:"mttrSeconds"`
+	Outages       []Outage  `json:"outages"`
+}
+
+// BuildSLAReport computes time-weighted uptime from ordered check results: each result is
+// assumed to hold until the next one, the last one until the end of the period.
+// MTTR is the mean duration of outages that recovered within the period.
+func BuildSLAReport(target string, from, to time.Time, results []Docker) SLAReport {
+	report := SLAReport{Target: target, From: from, To: to, Checks: len(results), Outages: []Outage{}}
+	if len(results) == 0 {
+		return report
+	}
+
+	var up, covered, repaired time.Duration
+	var recovered int
+	var current *Outage
+
+	for i, d := range results {
+		end := to
+		if i+1 < len(results) {
+			end = results[i+1].CheckedAt
+		}
+		span := end.Sub(d.CheckedAt)
+		covered += span
+
+		if d.Running {
+			up += span
+			if current != nil {
+				current.End = d.CheckedAt
+				current.DurationSeconds = current.End.Sub(current.Start).Seconds()
+				repaired += current.End.Sub(current.Start)
+				recovered++
+				report.Outages = append(report.Outages, *current)
+				current = nil
+			}
+			continue
+		}
+
+		report.FailedChecks++
+		if current == nil {
+			current = &Outage{Start: d.CheckedAt}
+		}
+		current.LastError = d.Error
+	}
+
+	if current != nil {
+		current.End = to
+		current.Ongoing = true
+		current.DurationSeconds = current.End.Sub(current.Start).Seconds()
+		report.Outages = append(report.Outages, *current)
+	}
+
+	if covered > 0 {
+		report.UptimePercent = float64(up) / float64(covered) * 100
+	} else if results[len(results)-1].Running {
+		report.UptimePercent = 100
+	}
+	if recovered > 0 {
+		report.MTTRSeconds = (repaired / time.Duration(recovered)).Seconds()
+	}
+	return report
+}
+
+// GetReport handles GET /report?target=&from=&to=&format=csv
+func (server *Server) GetReport(w http.ResponseWriter, r *http.Request) {
+	if server.Store == nil {
+		ERROR(w, http.StatusServiceUnavailable, errors.New("result persistence is not configured"))
+		return
+	}
+
+	query := r.URL.Query()
+	target := query.Get("target")
+	if target == "" {
+		ERROR(w, http.StatusBadRequest, errors.New("target query parameter is required"))
+		return
+	}
+
+	to, err := parseReportTime(query.Get("to"), time.Now())
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid to: %w", err))
+		return
+	}
+	from, err := parseReportTime(query.Get("from"), to.AddDate(0, -1, 0))
+	if err != nil {
+		ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid from: %w", err))
+		return
+	}
+	if !from.Before(to) {
+		ERROR(w, http.StatusBadRequest, errors.New("from must be before to"))
+		return
+	}
+
+	results, err := server.Store.Results(r.Context(), target, from, to)
+	if err != nil {
+		ERROR(w, http.StatusInternalServerError, err)
+		return
+	}
+	report := BuildSLAReport(target, from, to, results)
+
+	if query.Get("format") == "csv" {
+		writeReportCSV(w, report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	JSON(w, http.StatusOK, report)
+}
+
+// parseReportTime accepts RFC3339 timestamps or plain dates (2006-01-02)
+func parseReportTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func writeReportCSV(w http.ResponseWriter, report SLAReport) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q",
+		fmt.Sprintf("sla-%s-%s.csv", report.Target, report.From.Format("2006-01-02"))))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"target", "from", "to", "checks", "failed_checks", "uptime_percent", "mttr_seconds"})
+	cw.Write([]string{
+		report.Target,
+		report.From.Format(time.RFC3339),
+		report.To.Format(time.RFC3339),
+		strconv.Itoa(report.Checks),
+		strconv.Itoa(report.FailedChecks),
+		strconv.FormatFloat(report.UptimePercent, 'f', 3, 64),
+		strconv.FormatFloat(report.MTTRSeconds, 'f', 0, 64),
+	})
+	cw.Write(nil)
+	cw.Write([]string{"outage_start", "outage_end", "duration_seconds", "ongoing", "last_error"})
+	for _, o := range report.Outages {
+		cw.Write([]string{
+			o.Start.Format(time.RFC3339),
+			o.End.Format(time.RFC3339),
+			strconv.FormatFloat(o.DurationSeconds, 'f', 0, 64),
+			strconv.FormatBool(o.Ongoing),
+			o.LastError,
+		})
+	}
+	cw.Flush()
+}
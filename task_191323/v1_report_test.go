@@ -0,0 +1,136 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUptimeReport(t *testing.T) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+
+	// 100 minute period: down 10:00-20:00 and 50:00-55:00, otherwise up
+	results := []Docker{
+		{Target: "app", Running: true, CheckedAt: at(0)},
+		{Target: "app", Running: false, Error: "timeout", CheckedAt: at(10)},
+		{Target: "app", Running: true, CheckedAt: at(20)},
+		{Target: "app", Running: false, Error: "502", CheckedAt: at(50)},
+		{Target: "app", Running: true, CheckedAt: at(55)},
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Uptime, MTTR and outages", func(t *testing.T) {
+			report := BuildSLAReport("app", at(0), at(100), results)
+
+			if math.Abs(report.UptimePercent-85) > 0.001 {
+				t.Errorf("Expected 85%% uptime, got %v", report.UptimePercent)
+			}
+			if len(report.Outages) != 2 {
+				t.Fatalf("Expected 2 outages, got %d", len(report.Outages))
+			}
+			if report.MTTRSeconds != 450 {
+				t.Errorf("Expected MTTR of 450s, got %v", report.MTTRSeconds)
+			}
+			if report.Outages[1].LastError != "502" || report.FailedChecks != 2 {
+				t.Errorf("Unexpected outage details: %+v", report.Outages)
+			}
+		}},
+		{"Ongoing outage is reported until the end of the period", func(t *testing.T) {
+			report := BuildSLAReport("app", at(0), at(100), results[:4])
+
+			last := report.Outages[len(report.Outages)-1]
+			if !last.Ongoing || !last.End.Equal(at(100)) {
+				t.Errorf("Expected ongoing outage ending at the period end, got %+v", last)
+			}
+			if report.MTTRSeconds != 600 {
+				t.Errorf("Ongoing outages should not count towards MTTR, got %v", report.MTTRSeconds)
+			}
+		}},
+		{"Report endpoint reads persisted results", func(t *testing.T) {
+			store, err := NewResultStore(filepath.Join(t.TempDir(), "monitor.db"))
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+
+			if err := store.Save(context.Background(), results); err != nil {
+				t.Fatalf("Failed to save results: %v", err)
+			}
+			server := &Server{Store: store}
+
+			w := httptest.NewRecorder()
+			server.GetReport(w, httptest.NewRequest("GET", "/report?target=app&from=2024-05-01T00:00:00Z&to=2024-05-01T01:40:00Z", nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+
+			var report SLAReport
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Invalid JSON response: %v", err)
+			}
+			if report.Checks != 5 || len(report.Outages) != 2 {
+				t.Errorf("Expected 5 checks and 2 outages, got %d and %d", report.Checks, len(report.Outages))
+			}
+		}},
+		{"CSV export", func(t *testing.T) {
+			store, err := NewResultStore(filepath.Join(t.TempDir(), "monitor.db"))
+			if err != nil {
+				t.Fatalf("Failed to open store: %v", err)
+			}
+			defer store.Close()
+			store.Save(context.Background(), results)
+			server := &Server{Store: store}
+
+			w := httptest.NewRecorder()
+			server.GetReport(w, httptest.NewRequest("GET", "/report?target=app&from=2024-05-01T00:00:00Z&to=2024-05-01T01:40:00Z&format=csv", nil))
+
+			if ct := w.Header().Get("Content-Type"); ct != "text/csv" {
+				t.Errorf("Expected text/csv, got %q", ct)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "uptime_percent") || !strings.Contains(body, "85.000") || strings.Count(body, "timeout") != 1 {
+				t.Errorf("Unexpected CSV body:\n%s", body)
+			}
+		}},
+		{"Missing target is rejected", func(t *testing.T) {
+			server := &Server{Store: &ResultStore{}}
+			w := httptest.NewRecorder()
+			server.GetReport(w, httptest.NewRequest("GET", "/report", nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
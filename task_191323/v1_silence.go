@@ -0,0 +1,290 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxSilence bounds maintenance windows and silences, so a typo can't mute a target for good
+const maxSilence = 7 * 24 * time.Hour
+
+// Schedule is a cron expression with the five standard fields: minute, hour, day
+// of month, month and day of week (0 or 7 is Sunday). Fields accept *, numbers,
+// ranges, steps and lists, e.g. "*/15 2-4 * * 1,3". As in cron, a time matches
+// either day field when both are restricted.
+type Schedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var scheduleFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseSchedule(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("schedule %q: expected %d fields, got %d", spec, len(scheduleFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseScheduleField(field, scheduleFields[i].min, scheduleFields[i].max); err != nil {
+			return nil, fmt.Errorf("schedule %q: %s: %w", spec, scheduleFields[i].name, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &Schedule{
+		spec:   spec,
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}, nil
+}
+
+func parseScheduleField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether the schedule fires at the minute of t
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// MaintenanceWindow mutes the alerts of a target for Duration every time Schedule fires
+type MaintenanceWindow struct {
+	Schedule *Schedule
+	Duration time.Duration
+}
+
+// End returns the end of the window t falls in, if any
+func (w MaintenanceWindow) End(t time.Time) (time.Time, bool) {
+	start := t.Truncate(time.Minute)
+	for end := start.Add(w.Duration); end.After(t); start, end = start.Add(-time.Minute), end.Add(-time.Minute) {
+		if w.Schedule.Matches(start) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseMaintenanceWindows parses semicolon-separated target|schedule|duration
+// entries, e.g. "db|0 2 * * 0|2h;app|30 1 * * *|30m", into windows by target
+func ParseMaintenanceWindows(spec string) (map[string][]MaintenanceWindow, error) {
+	windows := make(map[string][]MaintenanceWindow)
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid maintenance window %q, expected target|schedule|duration", entry)
+		}
+
+		target := strings.TrimSpace(parts[0])
+		schedule, err := ParseSchedule(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		duration, err := time.ParseDuration(strings.TrimSpace(parts[2]))
+		if err != nil || duration <= 0 || duration > maxSilence {
+			return nil, fmt.Errorf("invalid maintenance window duration %q, expected up to %v", parts[2], maxSilence)
+		}
+		windows[target] = append(windows[target], MaintenanceWindow{Schedule: schedule, Duration: duration})
+	}
+	return windows, nil
+}
+
+// Silence mutes the alerts of a target, or of all targets when Target is empty,
+// from its creation or the start of the maintenance window until it expires
+type Silence struct {
+	ID     int       `json:"id,omitempty"`
+	Target string    `json:"target,omitempty"`
+	Reason string    `json:"reason"`
+	From   time.Time `json:"from"`
+	Until  time.Time `json:"until"`
+}
+
+func (s Silence) covers(target string, t time.Time) bool {
+	return (s.Target == "" || s.Target == target) && t.Before(s.Until)
+}
+
+// Silences holds the ad-hoc silences created through the API. They are kept in
+// memory and dropped once expired.
+type Silences struct {
+	mu     sync.Mutex
+	nextID int
+	items  []Silence
+}
+
+func (s *Silences) Add(target, reason string, d time.Duration) Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	now := time.Now()
+	silence := Silence{ID: s.nextID, Target: target, Reason: reason, From: now, Until: now.Add(d)}
+	s.items = append(s.items, silence)
+	return silence
+}
+
+// Active returns the silences that haven't expired at t
+func (s *Silences) Active(t time.Time) []Silence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.items[:0]
+	for _, silence := range s.items {
+		if t.Before(silence.Until) {
+			active = append(active, silence)
+		}
+	}
+	s.items = active
+	return append(make([]Silence, 0, len(active)), active...)
+}
+
+// For returns the silence covering target at t, the one lasting longest if several do
+func (s *Silences) For(target string, t time.Time) (Silence, bool) {
+	var found Silence
+	var ok bool
+	for _, silence := range s.Active(t) {
+		if silence.covers(target, t) && (!ok || silence.Until.After(found.Until)) {
+			found, ok = silence, true
+		}
+	}
+	return found, ok
+}
+
+// Remove ends a silence early
+func (s *Silences) Remove(id int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, silence := range s.items {
+		if silence.ID == id {
+			s.items = append(s.items[:i], s.items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// GetSilences handles GET /silence
+func (server *Server) GetSilences(w http.ResponseWriter, r *http.Request) {
+	silences := server.Monitor.Silences.Active(time.Now())
+	sort.Slice(silences, func(i, j int) bool { return silences[i].Until.Before(silences[j].Until) })
+	JSON(w, http.StatusOK, silences)
+}
+
+// CreateSilence handles POST /silence with a JSON body like
+// {"target": "app", "duration": "30m", "reason": "deploy"}. Leaving the target
+// out silences all targets.
+func (server *Server) CreateSilence(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target   string `json:"target"`
+		Duration string `json:"duration"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		ERROR(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 || duration > maxSilence {
+		ERROR(w, http.StatusBadRequest, fmt.Errorf("duration must be a positive duration up to %v, like 30m or 2h", maxSilence))
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		ERROR(w, http.StatusBadRequest, errors.New("reason is required"))
+		return
+	}
+	if req.Target != "" && !server.Monitor.HasTarget(req.Target) {
+		ERROR(w, http.StatusNotFound, fmt.Errorf("unknown target %q", req.Target))
+		return
+	}
+
+	JSON(w, http.StatusCreated, server.Monitor.Silences.Add(req.Target, strings.TrimSpace(req.Reason), duration))
+}
+
+// DeleteSilence handles DELETE /silence/{id}
+func (server *Server) DeleteSilence(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || !server.Monitor.Silences.Remove(id) {
+		ERROR(w, http.StatusNotFound, errors.New("silence not found"))
+		return
+	}
+	JSON(w, http.StatusOK, map[string]string{"message": "silence removed"})
+}
@@ -0,0 +1,203 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type recordingAlerter struct {
+	alerts []Alert
+}
+
+func (r *recordingAlerter) Alert(ctx context.Context, a Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestMaintenanceAndSilences(t *testing.T) {
+	// 2024-05-05 is a Sunday
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 5, day, hour, minute, 0, 0, time.UTC) }
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Schedules match like cron", func(t *testing.T) {
+			cases := []struct {
+				spec string
+				t    time.Time
+				want bool
+			}{
+				{"0 2 * * *", at(6, 2, 0), true},
+				{"0 2 * * *", at(6, 2, 1), false},
+				{"*/15 2-4 * * *", at(6, 4, 45), true},
+				{"*/15 2-4 * * *", at(6, 5, 0), false},
+				{"30 1 * * 0", at(5, 1, 30), true},
+				{"30 1 * * 7", at(5, 1, 30), true},
+				{"30 1 * * 1-5", at(5, 1, 30), false},
+				{"0 0 1 * 0", at(5, 0, 0), true}, // either day field matches
+				{"0 0 1,15 5 *", at(15, 0, 0), true},
+				{"0 0 1,15 6 *", at(15, 0, 0), false},
+			}
+			for _, c := range cases {
+				s, err := ParseSchedule(c.spec)
+				if err != nil {
+					t.Fatalf("ParseSchedule(%q): %v", c.spec, err)
+				}
+				if got := s.Matches(c.t); got != c.want {
+					t.Errorf("%q at %v: got %v, want %v", c.spec, c.t, got, c.want)
+				}
+			}
+
+			for _, spec := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+				if _, err := ParseSchedule(spec); err == nil {
+					t.Errorf("Expected an error for %q", spec)
+				}
+			}
+		}},
+		{"Maintenance window lasts its duration", func(t *testing.T) {
+			windows, err := ParseMaintenanceWindows("app|0 2 * * 0|90m; db|0 3 * * *|1h")
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := windows["app"][0]
+			if end, ok := w.End(at(5, 3, 29)); !ok || !end.Equal(at(5, 3, 30)) {
+				t.Errorf("Expected the window to end at 03:30, got %v %v", end, ok)
+			}
+			if _, ok := w.End(at(5, 3, 30)); ok {
+				t.Error("Expected the window to be over at 03:30")
+			}
+			if _, ok := w.End(at(6, 2, 10)); ok {
+				t.Error("Expected no window on Monday")
+			}
+
+			for _, spec := range []string{"app|0 2 * * 0", "app|0 2 * * 0|0s", "app|0 2 * * 0|200h", "app|0 2 * *|1h"} {
+				if _, err := ParseMaintenanceWindows(spec); err == nil {
+					t.Errorf("Expected an error for %q", spec)
+				}
+			}
+		}},
+		{"Silenced failures don't alert until the silence ends", func(t *testing.T) {
+			healthy := true
+			alerter := &recordingAlerter{}
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 10, time.Minute, Target{Name: "app", Type: "fake"})
+			m.Alerter = alerter
+			m.Probers["fake"] = ProberFunc(func(ctx context.Context, t Target) (int, error) {
+				if healthy {
+					return 0, nil
+				}
+				return -1, fmt.Errorf("connection refused")
+			})
+
+			silence := m.Silences.Add("app", "deploy", time.Hour)
+			healthy = false
+			dockers := m.CheckAll(context.Background())
+			if len(alerter.alerts) != 0 {
+				t.Fatalf("Expected no alert while silenced, got %v", alerter.alerts)
+			}
+			if dockers[0].Silence == nil || dockers[0].Silence.Reason != "deploy" {
+				t.Errorf("Expected the silence in the status, got %+v", dockers[0])
+			}
+
+			m.Silences.Remove(silence.ID)
+			m.CheckAll(context.Background())
+			m.CheckAll(context.Background())
+			if len(alerter.alerts) != 1 || alerter.alerts[0].Running {
+				t.Fatalf("Expected a single down alert once unsilenced, got %v", alerter.alerts)
+			}
+
+			healthy = true
+			m.CheckAll(context.Background())
+			if len(alerter.alerts) != 2 || !alerter.alerts[1].Running {
+				t.Errorf("Expected a recovery alert, got %v", alerter.alerts)
+			}
+		}},
+		{"Maintenance windows show in the status", func(t *testing.T) {
+			m := NewMonitor(&http.Client{}, RetryPolicy{}, 10, time.Minute, Target{Name: "app", Type: "fake"})
+			m.Probers["fake"] = ProberFunc(func(ctx context.Context, t Target) (int, error) { return 0, nil })
+			schedule, _ := ParseSchedule("* * * * *")
+			m.Maintenance = map[string][]MaintenanceWindow{"app": {{Schedule: schedule, Duration: time.Minute}}}
+
+			dockers := m.CheckAll(context.Background())
+			if s := dockers[0].Silence; s == nil || !strings.HasPrefix(s.Reason, "maintenance window") {
+				t.Errorf("Expected the maintenance window in the status, got %+v", dockers[0])
+			}
+		}},
+		{"Silence endpoints", func(t *testing.T) {
+			server := &Server{
+				Monitor: NewMonitor(&http.Client{}, RetryPolicy{}, 3, time.Minute, Target{Name: "app"}),
+				Auth:    &Authenticator{apiKeys: map[string]string{"admin-key": ScopeAdmin}},
+				Router:  mux.NewRouter(),
+			}
+			server.initializeRoutes()
+			serve := func(method, target, body string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(method, target, strings.NewReader(body))
+				r.Header.Set("X-API-Key", "admin-key")
+				server.Router.ServeHTTP(w, r)
+				return w
+			}
+
+			for _, body := range []string{`{"duration":"30m"}`, `{"duration":"forever","reason":"x"}`, `{"duration":"30m","reason":"x","target":"nope"}`} {
+				if w := serve("POST", "/silence", body); w.Code == http.StatusCreated {
+					t.Errorf("Expected %s to be rejected", body)
+				}
+			}
+
+			w := serve("POST", "/silence", `{"target":"app","duration":"30m","reason":"db migration"}`)
+			if w.Code != http.StatusCreated {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+			var created Silence
+			json.Unmarshal(w.Body.Bytes(), &created)
+			if created.ID == 0 || created.Until.Sub(created.From) != 30*time.Minute {
+				t.Errorf("Unexpected silence %+v", created)
+			}
+
+			var listed []Silence
+			json.Unmarshal(serve("GET", "/silence", "").Body.Bytes(), &listed)
+			if len(listed) != 1 || listed[0].Reason != "db migration" {
+				t.Errorf("Expected the silence to be listed, got %+v", listed)
+			}
+
+			if w := serve("DELETE", fmt.Sprintf("/silence/%d", created.ID), ""); w.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+			}
+			if w := serve("DELETE", fmt.Sprintf("/silence/%d", created.ID), ""); w.Code != http.StatusNotFound {
+				t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+			}
+			if body := serve("GET", "/silence", "").Body.String(); strings.TrimSpace(body) != "[]" {
+				t.Errorf("Expected no silences, got %s", body)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
@@ -4,33 +4,49 @@
 package main
 
 import (
+	"awesomeProject/platform/app"
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/config"
+	"awesomeProject/platform/cors"
+	"awesomeProject/platform/errs"
+	"awesomeProject/platform/idempotency"
+	"awesomeProject/platform/logging"
+	"awesomeProject/platform/ratelimit"
+	"awesomeProject/platform/telemetry"
 	"context"
-	"github.com/gin-contrib/cors"
+	"errors"
+	"flag"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"time"
 )
 
-var client *mongo.Client
-var dbcollection *mongo.Collection
-var databaseName string
-var inventoryCollection string
-var usersCollection string
-var jwtSecret string
-
+// InventoryItem is a product of an organization. Units is the opening stock on creation,
+// afterwards it only changes through stock movements. A product needs reordering
+// when its units drop to ReorderLevel, zero disables the alert. Attributes follow
+// the schema of the product's category. SKU and Barcode are unique per organization.
+// UnitCost is what a unit costs to buy, it values the opening stock and is the
+// default cost of purchases.
 type InventoryItem struct {
-	ID          string  `json:"id,omitempty" bson:"_id,omitempty"`
-	UserID      string  `json:"userID,omitempty" bson:"userID,omitempty"`
-	ProductName string  `json:"productName" bson:"productName"`
-	Units       int     `json:"units" bson:"units"`
-	Price       float64 `json:"price" bson:"price"`
+	ID           string                 `json:"id,omitempty" bson:"_id,omitempty"`
+	OrgID        string                 `json:"orgID,omitempty" bson:"orgID,omitempty"`
+	ProductName  string                 `json:"productName" bson:"productName"`
+	SKU          string                 `json:"sku,omitempty" bson:"sku,omitempty"`
+	Barcode      string                 `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	Units        int                    `json:"units" bson:"units"`
+	Price        float64                `json:"price" bson:"price"`
+	UnitCost     float64                `json:"unitCost" bson:"unitCost"`
+	ReorderLevel int                    `json:"reorderLevel" bson:"reorderLevel"`
+	CategoryID   string                 `json:"categoryID,omitempty" bson:"categoryID,omitempty"`
+	Tags         []string               `json:"tags,omitempty" bson:"tags,omitempty"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty" bson:"attributes,omitempty"`
 }
 
 type User struct {
@@ -39,81 +55,101 @@ type User struct {
 	Password string `json:"password" bson:"password"`
 }
 
-func initDB() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
-	}
+// Server holds the dependencies of the handlers
+type Server struct {
+	Inventory  InventoryRepository
+	Warehouses WarehouseRepository
+	Categories CategoryRepository
+	Orgs       OrganizationRepository
+	Audit      AuditLog
+	Users      UserRepository
+	Revoked    TokenDenylist
+	Quotas     QuotaStore
+	JWTSecret  string
+	// DailyWriteQuota is the number of writes per user and day, 0 disables the quota
+	DailyWriteQuota int
+	// Idempotency keeps the responses to the writes for their retries, in memory when nil
+	Idempotency idempotency.Store
+}
+
+func initDB(databaseURL string) *mongo.Client {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	databaseURL := os.Getenv("MONGO_CONN_URL")
 	clientOptions := options.Client().ApplyURI(databaseURL)
-	var err error
-	client, err = mongo.Connect(context.Background(), clientOptions)
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = client.Ping(context.Background(), nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	log.Println("Connected to MongoDB")
+	return client
 }
 
-func initCollection() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+// newMongoServer wires the handlers to the collections named in the configuration
+func newMongoServer(client *mongo.Client, cfg *config.Config) *Server {
+	db := client.Database(cfg.String("database.name", ""))
+	inventory := &MongoInventoryRepository{
+		client:     client,
+		items:      db.Collection(cfg.String("inventory.collection", "")),
+		movements:  db.Collection(cfg.String("movements.collection", "movements")),
+		warehouses: db.Collection(cfg.String("warehouses.collection", "warehouses")),
+		stock:      db.Collection(cfg.String("stock.collection", "stock")),
+		transfers:  db.Collection(cfg.String("transfers.collection", "transfers")),
+		categories: db.Collection(cfg.String("categories.collection", "categories")),
+	}
+	orgs := &MongoOrganizationRepository{
+		orgs:        db.Collection(cfg.String("organizations.collection", "organizations")),
+		members:     db.Collection(cfg.String("members.collection", "members")),
+		invitations: db.Collection(cfg.String("invitations.collection", "invitations")),
 	}
 
-	databaseName = os.Getenv("DATABASE_NAME")
-	inventoryCollection = os.Getenv("INVENTORY_COLLECTION")
-	usersCollection = os.Getenv("USERS_COLLECTION")
-	jwtSecret = os.Getenv("JWT_SECRET")
-
-	dbcollection = client.Database(databaseName).Collection(inventoryCollection)
+	return &Server{
+		Inventory:  inventory,
+		Warehouses: inventory,
+		Categories: inventory,
+		Orgs:       orgs,
+		Audit:      &MongoAuditLog{entries: db.Collection(cfg.String("audit.collection", "audit"))},
+		Users:      &MongoUserRepository{users: db.Collection(cfg.String("users.collection", ""))},
+		Revoked:    &MongoTokenDenylist{revoked: db.Collection(cfg.String("revoked.tokens.collection", "revoked_tokens"))},
+		Quotas:     &MongoQuotaStore{quotas: db.Collection(cfg.String("quotas.collection", "quotas"))},
+		JWTSecret:  cfg.String("jwt.secret", ""),
+	}
 }
 
-func signUp(c *gin.Context) {
+func (s *Server) signUp(c *gin.Context) {
 	var user User
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid JSON format",
-		})
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid JSON format"))
 		return
 	}
 
-	existingUser := User{}
-	err := client.Database(databaseName).Collection(usersCollection).FindOne(context.Background(), bson.M{"username": user.Username}).Decode(&existingUser)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error checking username existence",
-			})
-			return
-		}
-	} else {
-		c.JSON(http.StatusConflict, gin.H{
-			"error": "Username already exists",
-		})
+	_, err := s.Users.FindByUsername(c.Request.Context(), user.Username)
+	if err == nil {
+		respondError(c, errs.New(errs.ErrConflict, "Username already exists"))
+		return
+	}
+	if !errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrInternal, "Error checking username existence"))
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error hashing password",
-		})
+		respondError(c, errs.New(errs.ErrInternal, "Error hashing password"))
 		return
 	}
 
+	user.ID = ""
 	user.Password = string(hashedPassword)
 
-	_, err = client.Database(databaseName).Collection(usersCollection).InsertOne(context.Background(), user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error creating user",
-		})
+	if err := s.Users.Create(c.Request.Context(), &user); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error creating user"))
 		return
 	}
 
@@ -122,130 +158,293 @@ func signUp(c *gin.Context) {
 	})
 }
 
-func signIn(c *gin.Context) {
-	// Implementation pending...
+func (s *Server) getProductById(c *gin.Context) {
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, product)
 }
 
-func authMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Implementation of token authentication middleware
+func (s *Server) createProduct(c *gin.Context) {
+	// Retrieve the organization from context
+	orgID, exists := c.Get("org")
+	if !exists {
+		respondError(c, errs.New(errs.ErrUnauthorized, "Unauthorized"))
+		return
 	}
-}
 
-func getUserProducts(c *gin.Context) {
-	userID, _ := c.Get("user")
+	// Bind and validate the JSON input
+	var input ProductInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	product := input.item(orgID.(string))
+	category, ok := s.checkCategory(c, &product)
+	if !ok {
+		return
+	}
 
-	cursor, err := client.Database("Go-Gin-Inventory").Collection("inventory").Find(context.Background(), bson.M{"userID": userID})
+	// Check if a product with the same name already exists in the organization
+	exists, err := s.Inventory.NameExists(c.Request.Context(), product.OrgID, product.ProductName, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching products"})
+		respondError(c, errs.New(errs.ErrInternal, "Failed to check product existence"))
 		return
 	}
 
-	defer cursor.Close(context.Background())
+	if exists {
+		respondError(c, errs.New(errs.ErrConflict, "Product with this name already exists in this organization"))
+		return
+	}
+	if err := s.assignCodes(c.Request.Context(), &product, category); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	var products []InventoryItem
-	if err := cursor.All(context.Background(), &products); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding products"})
+	// Insert the new product into the database
+	if err := s.Inventory.Create(c.Request.Context(), &product); err != nil {
+		log.Println("Error inserting product:", err) // Log error for debugging
+		respondError(c, errs.New(errs.ErrInternal, "Failed to create product"))
 		return
 	}
+	s.audit(c, AuditEntry{Entity: auditProduct, Action: auditCreate, ItemID: product.ID}, nil, product)
 
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully!", "id": product.ID})
 }
 
-func getProductById(c *gin.Context) {
-	id := c.Param("id")
-	objectId, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid ID format",
-		})
-		return
+// findOwnedProduct loads the product with the id in the path and checks that it belongs
+// to the organization of the request. It writes the error response and returns false otherwise.
+func (s *Server) findOwnedProduct(c *gin.Context) (*InventoryItem, bool) {
+	return s.ownedProduct(c, c.Param("id"))
+}
+
+func (s *Server) ownedProduct(c *gin.Context, id string) (*InventoryItem, bool) {
+	if !primitive.IsValidObjectID(id) {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid ID format"))
+		return nil, false
 	}
 
-	userID, _ := c.Get("user")
+	orgID, exists := c.Get("org")
+	if !exists {
+		respondError(c, errs.New(errs.ErrUnauthorized, "Unauthorized"))
+		return nil, false
+	}
 
-	var product InventoryItem
-	err = dbcollection.FindOne(context.Background(), bson.M{"_id": objectId, "userID": userID}).Decode(&product)
+	product, err := s.Inventory.FindByID(c.Request.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrNotFound, "Product not found"))
+		return nil, false
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error fetching product",
-		})
-		return
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching product"))
+		return nil, false
 	}
-	c.JSON(http.StatusOK, product)
+	if product.OrgID != orgID {
+		respondError(c, errs.New(errs.ErrForbidden, "Product belongs to another organization"))
+		return nil, false
+	}
+	return product, true
 }
 
-func createProduct(c *gin.Context) {
-	var product InventoryItem
+func (s *Server) updateProduct(c *gin.Context) {
+	if !primitive.IsValidObjectID(c.Param("id")) {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid ID format"))
+		return
+	}
 
-	// Retrieve user ID from context
-	userID, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	var input ProductInput
+	if !bindJSON(c, &input) {
 		return
 	}
-	product.UserID = userID.(string)
 
-	// Bind JSON input to product struct
-	if err := c.ShouldBindJSON(&product); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+	existing, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+	product := input.item(existing.OrgID)
+	product.ID = existing.ID
+	if product.SKU == "" {
+		product.SKU = existing.SKU
+	}
+	category, ok := s.checkCategory(c, &product)
+	if !ok {
 		return
 	}
 
-	// Check if the product name is provided
-	if product.ProductName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Product name is required"})
+	// Renaming must not collide with another product of the same organization
+	exists, err := s.Inventory.NameExists(c.Request.Context(), product.OrgID, product.ProductName, product.ID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to check product existence"))
+		return
+	}
+	if exists {
+		respondError(c, errs.New(errs.ErrConflict, "Product with this name already exists in this organization"))
+		return
+	}
+	if err := s.assignCodes(c.Request.Context(), &product, category); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Check if a product with the same name already exists for the user
-	filter := bson.M{"userID": product.UserID, "productName": product.ProductName}
-	count, err := dbcollection.CountDocuments(context.Background(), filter)
+	// Units are not overwritten, stock changes are recorded as movements
+	updated, err := s.Inventory.Update(c.Request.Context(), product)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check product existence"})
+		respondError(c, errs.New(errs.ErrInternal, "Failed to update product"))
 		return
 	}
+	s.audit(c, AuditEntry{Entity: auditProduct, Action: auditUpdate, ItemID: updated.ID}, existing, updated)
+	c.JSON(http.StatusOK, updated)
+}
 
-	if count > 0 {
-		c.JSON(http.StatusConflict, gin.H{"error": "Product with this name already exists for this user"})
+func (s *Server) deleteProduct(c *gin.Context) {
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
 		return
 	}
 
-	// Insert the new product into the database
-	_, err = dbcollection.InsertOne(context.Background(), product)
-	if err != nil {
-		log.Println("Error inserting product:", err) // Log error for debugging
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
+	if err := s.Inventory.Delete(c.Request.Context(), product.OrgID, product.ID); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to delete product"))
 		return
 	}
+	s.audit(c, AuditEntry{Entity: auditProduct, Action: auditDelete, ItemID: product.ID}, product, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully!"})
+}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Product created successfully!"})
+// idempotencyCaller scopes the Idempotency-Key of a request to the user and the
+// organization it works in
+func idempotencyCaller(c *gin.Context) string {
+	caller := "user:" + c.GetString("user")
+	if org := c.GetString("org"); org != "" {
+		caller += "|org:" + org
+	}
+	return caller
 }
 
-func setupRoutes(r *gin.Engine) {
-	r.POST("/signup", signUp)
-	r.POST("/signin", signIn)
+func (s *Server) setupRoutes(r *gin.Engine) {
+	// every route but the import takes JSON bodies
+	api := r.Group("")
+	api.Use(bodylimit.GinMaxBytes(bodylimit.DefaultMaxBytes), bodylimit.GinRequireContentType("application/json"))
+
+	// against password guessing and sign-up floods, per client address
+	signIn := ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), Limit: signInLimit, Window: time.Minute}.Gin()
+	api.POST("/signup", signIn, s.signUp)
+	api.POST("/signin", signIn, s.signIn)
+	api.POST("/refresh", s.refresh)
+	api.POST("/signout", s.authMiddleware(), s.signOut)
+
+	// organizations of the user, outside of any organization
+	authGroup := api.Group("/auth")
+	authGroup.Use(s.authMiddleware(), s.writeQuota())
+	// retried creations and updates with an Idempotency-Key get the first response
+	idempotent := idempotency.Gin(s.Idempotency, idempotency.DefaultTTL, idempotencyCaller)
+	{
+		authGroup.POST("/orgs", idempotent, s.createOrganization)
+		authGroup.GET("/orgs", s.getOrganizations)
+		authGroup.GET("/invitations", s.getInvitations)
+		authGroup.POST("/invitations/:id/accept", s.acceptInvitation)
+	}
 
-	authGroup := r.Group("/auth")
-	authGroup.Use(authMiddleware())
+	// the inventory of the organization selected by X-Organization-ID
+	orgGroup := authGroup.Group("")
+	orgGroup.Use(s.orgMiddleware(), authorizeRole(), idempotent)
 	{
-		authGroup.GET("/allProducts", getUserProducts)
-		authGroup.GET("/products/:id", getProductById)
-		authGroup.POST("/createProduct", createProduct)
+		orgGroup.GET("/allProducts", s.getUserProducts)
+		orgGroup.GET("/products/lookup", s.lookupProduct)
+		orgGroup.GET("/products/:id", s.getProductById)
+		orgGroup.POST("/createProduct", s.createProduct)
+		orgGroup.PUT("/products/:id", s.updateProduct)
+		orgGroup.DELETE("/products/:id", s.deleteProduct)
+		orgGroup.POST("/products/:id/movements", s.recordMovement)
+		orgGroup.GET("/products/:id/movements", s.getMovements)
+		orgGroup.POST("/products/:id/sales", s.recordSale)
+		orgGroup.GET("/lowStock", s.getLowStock)
+		orgGroup.GET("/products/:id/stock", s.getProductStock)
+		orgGroup.POST("/warehouses", s.createWarehouse)
+		orgGroup.GET("/warehouses", s.getWarehouses)
+		orgGroup.GET("/warehouses/:id/stock", s.getWarehouseStock)
+		orgGroup.POST("/transfers", s.transferStock)
+		orgGroup.GET("/stock", s.getStockTotals)
+		orgGroup.GET("/reports/valuation", s.getValuation)
+		orgGroup.GET("/reports/turnover", s.getTurnover)
+		orgGroup.GET("/reports/top-moving", s.getTopMoving)
+		orgGroup.POST("/categories", s.createCategory)
+		orgGroup.GET("/categories", s.getCategories)
+		orgGroup.GET("/categories/:id", s.getCategory)
+		orgGroup.PUT("/categories/:id", s.updateCategory)
+		orgGroup.DELETE("/categories/:id", s.deleteCategory)
+		orgGroup.GET("/export", s.exportProducts)
+		orgGroup.GET("/audit", requireRole(roleManager), s.getAudit)
+		orgGroup.GET("/org/members", s.getMembers)
+		orgGroup.POST("/org/invitations", requireRole(roleOwner), s.inviteMember)
+		orgGroup.PUT("/org/members/:userID", requireRole(roleOwner), s.updateMember)
+		orgGroup.DELETE("/org/members/:userID", requireRole(roleOwner), s.removeMember)
 	}
+
+	// the import takes spreadsheets, larger than JSON bodies, and limits them itself
+	importGroup := r.Group("/auth")
+	importGroup.Use(s.authMiddleware(), s.writeQuota(), s.orgMiddleware(), authorizeRole())
+	importGroup.POST("/import", s.importProducts)
 }
 
 func main() {
-	initDB()
-	initCollection()
-	r := gin.Default()
-
-	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{"http://localhost:5173"}
-	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE"}
-	config.AllowHeaders = []string{"Authorization", "Content-Type"}
-	r.Use(cors.New(config))
-
-	setupRoutes(r)
-	r.Run(":8080")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Require("mongo.conn.url", "database.name", "inventory.collection", "users.collection", "jwt.secret")
+	origins := cfg.Strings("cors.origins", []string{"http://localhost:5173"})
+	timeout := positiveDuration(cfg, "request.timeout", defaultRequestTimeout)
+	opts := app.Options{
+		Name:            "inventory",
+		Addr:            cfg.String("http.addr", app.DefaultAddr),
+		ShutdownTimeout: positiveDuration(cfg, "shutdown.timeout", defaultShutdownTimeout),
+	}
+	notifier := notifierFromConfig(cfg)
+	interval := positiveDuration(cfg, "low.stock.interval", time.Hour)
+	quota := dailyWriteQuota(cfg)
+	logger := logging.FromConfig(cfg, "inventory")
+	if err := cfg.Err(); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	opts.Logger = logger
+	shutdownTelemetry, err := telemetry.Setup(context.Background(), "inventory")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	client := initDB(cfg.String("mongo.conn.url", ""))
+	s := newMongoServer(client, cfg)
+	s.DailyWriteQuota = quota
+
+	opts.Router = func(*app.Application) (http.Handler, error) {
+		r := gin.New()
+		r.Use(gin.Recovery(), logging.Gin(logger), telemetry.Gin())
+
+		r.Use(cors.Gin(cors.Config{
+			AllowedOrigins: origins,
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", orgHeader, idempotency.Header},
+			MaxAge:         12 * time.Hour,
+		}))
+		r.Use(requestTimeout(timeout))
+
+		s.setupRoutes(r)
+		return r, nil
+	}
+	opts.OnStart = []app.Hook{s.Inventory.EnsureIndexes, s.Orgs.EnsureIndexes, s.Audit.EnsureIndexes, s.Revoked.EnsureIndexes, s.Quotas.EnsureIndexes}
+	opts.OnStop = []app.Hook{shutdownTelemetry, client.Disconnect}
+	if notifier != nil {
+		opts.Background = append(opts.Background, func(ctx context.Context) {
+			runLowStockChecker(ctx, s.Inventory, interval, notifier)
+		})
+	}
+
+	application, err := app.NewApplication(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := application.Run(context.Background()); err != nil {
+		log.Println("Server stopped:", err)
+	}
 }
@@ -0,0 +1,144 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audited entities and actions. Stock adjustments and transfers are recorded on the
+// product they change.
+const (
+	auditProduct   = "product"
+	auditCategory  = "category"
+	auditWarehouse = "warehouse"
+
+	auditCreate   = "create"
+	auditUpdate   = "update"
+	auditDelete   = "delete"
+	auditStock    = "stock"
+	auditTransfer = "transfer"
+)
+
+// AuditEntry records a mutation of an organization's inventory. Before and After are
+// snapshots of the entity as the API returns it, creations have no Before and
+// deletions no After. Reference is the ID of the movement or transfer behind stock
+// changes.
+type AuditEntry struct {
+	ID        string                 `json:"id,omitempty" bson:"_id,omitempty"`
+	OrgID     string                 `json:"orgID" bson:"orgID"`
+	Actor     string                 `json:"actor" bson:"actor"`
+	Entity    string                 `json:"entity" bson:"entity"`
+	Action    string                 `json:"action" bson:"action"`
+	ItemID    string                 `json:"itemID" bson:"itemID"`
+	Reference string                 `json:"reference,omitempty" bson:"reference,omitempty"`
+	Before    map[string]interface{} `json:"before,omitempty" bson:"before,omitempty"`
+	After     map[string]interface{} `json:"after,omitempty" bson:"after,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// auditQuery narrows the audit trail to an item and to entries created in [From, To),
+// zero times leave the period open
+type auditQuery struct {
+	ItemID string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// AuditLog stores the audit trail. Entries are only ever appended.
+type AuditLog interface {
+	// Record stores a new entry and sets its ID
+	Record(ctx context.Context, entry *AuditEntry) error
+	// List returns a page of the organization's entries, newest first, and the total
+	// number of matches
+	List(ctx context.Context, orgID string, q auditQuery) ([]AuditEntry, int64, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+// audit records a mutation made by the user of the request in the organization of
+// the request
+func (s *Server) audit(c *gin.Context, entry AuditEntry, before, after interface{}) {
+	entry.OrgID = c.GetString("org")
+	entry.Actor = c.GetString("user")
+	s.recordAudit(c.Request.Context(), entry, before, after)
+}
+
+// recordAudit snapshots before and after, nil for none, and stores the entry. The
+// mutation already happened, so failures are logged rather than returned.
+func (s *Server) recordAudit(ctx context.Context, entry AuditEntry, before, after interface{}) {
+	entry.ID = ""
+	entry.CreatedAt = time.Now().UTC()
+	entry.Before, entry.After = snapshot(before), snapshot(after)
+	if err := s.Audit.Record(ctx, &entry); err != nil {
+		log.Println("Error recording audit entry:", err)
+	}
+}
+
+// snapshot converts v to its JSON object form, so the audit trail keeps the fields as
+// clients see them whatever the entity
+func snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// getAudit lists the audit trail of the organization, filtered by the item query
+// parameter and the from and to times, and paged with limit and offset
+func (s *Server) getAudit(c *gin.Context) {
+	q := auditQuery{ItemID: c.Query("item")}
+	if q.ItemID != "" && !primitive.IsValidObjectID(q.ItemID) {
+		respondError(c, errs.New(errs.ErrInvalid, "item must be an ID"))
+		return
+	}
+	var msg string
+	if q.Limit, q.Offset, msg = parsePage(c); msg != "" {
+		respondError(c, errs.New(errs.ErrInvalid, msg))
+		return
+	}
+	for name, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if v := c.Query(name); v != "" {
+			t, err := parseReportTime(v, name == "to")
+			if err != nil {
+				respondError(c, errs.New(errs.ErrInvalid, name+" must be a date or an RFC 3339 time"))
+				return
+			}
+			*target = t
+		}
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		respondError(c, errs.New(errs.ErrInvalid, "from must be before to"))
+		return
+	}
+
+	entries, total, err := s.Audit.List(c.Request.Context(), c.GetString("org"), q)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching audit trail"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"items":  entries,
+		"total":  total,
+		"limit":  q.Limit,
+		"offset": q.Offset,
+	})
+}
@@ -0,0 +1,167 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuditTrail(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	do := func(user, method, path, body string) *httptest.ResponseRecorder {
+		token, _, _ := s.generateToken(user)
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(orgHeader, "owner")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(do("owner", "POST", "/auth/createProduct", `{"productName":"Widget","units":5,"price":2.5}`).Body.Bytes(), &created)
+	do("owner", "PUT", "/auth/products/"+created.ID, `{"productName":"Widget","units":5,"price":3}`)
+	do("owner", "POST", "/auth/products/"+created.ID+"/movements", `{"quantity":-2,"reason":"sale"}`)
+	do("owner", "POST", "/auth/categories", `{"name":"Tools"}`)
+	do("owner", "DELETE", "/auth/products/"+created.ID, "")
+
+	// a viewer of the personal organization of owner
+	s.Orgs.EnsurePersonal(context.Background(), "owner")
+	s.Orgs.AcceptInvitation(context.Background(), Invitation{OrgID: "owner", UserID: "viewer", Role: roleViewer})
+
+	type page struct {
+		Items []AuditEntry `json:"items"`
+		Total int64        `json:"total"`
+	}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+
+	tests := []struct {
+		name            string
+		user            string
+		query           string
+		expectedStatus  int
+		expectedActions []string
+	}{
+		{
+			name:            "Whole Trail Newest First",
+			user:            "owner",
+			expectedStatus:  200,
+			expectedActions: []string{"delete", "create", "stock", "update", "create"},
+		},
+		{
+			name:            "Filtered By Item",
+			user:            "owner",
+			query:           "item=" + created.ID,
+			expectedStatus:  200,
+			expectedActions: []string{"delete", "stock", "update", "create"},
+		},
+		{
+			name:            "Paged",
+			user:            "owner",
+			query:           "item=" + created.ID + "&limit=2&offset=1",
+			expectedStatus:  200,
+			expectedActions: []string{"stock", "update"},
+		},
+		{
+			name:            "Period Before The Changes",
+			user:            "owner",
+			query:           "from=2020-01-01&to=2020-01-31",
+			expectedStatus:  200,
+			expectedActions: []string{},
+		},
+		{
+			name:            "Period From Tomorrow",
+			user:            "owner",
+			query:           "from=" + tomorrow,
+			expectedStatus:  200,
+			expectedActions: []string{},
+		},
+		{
+			name:           "Invalid Item",
+			user:           "owner",
+			query:          "item=widget",
+			expectedStatus: 400,
+		},
+		{
+			name:           "Invalid Period",
+			user:           "owner",
+			query:          "from=2024-02-01&to=2024-01-01",
+			expectedStatus: 400,
+		},
+		{
+			name:           "Viewers Can't Read The Trail",
+			user:           "viewer",
+			expectedStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.user, "GET", "/auth/audit?"+tt.query, "")
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedActions == nil {
+				return
+			}
+			var response page
+			json.Unmarshal(w.Body.Bytes(), &response)
+			actions := []string{}
+			for _, entry := range response.Items {
+				actions = append(actions, entry.Action)
+			}
+			if !reflect.DeepEqual(actions, tt.expectedActions) {
+				t.Errorf("Expected actions %v, got %v", tt.expectedActions, actions)
+			}
+		})
+	}
+
+	var response page
+	json.Unmarshal(do("owner", "GET", "/auth/audit?item="+created.ID, "").Body.Bytes(), &response)
+	if len(response.Items) != 4 {
+		t.Fatalf("Expected 4 entries for the product, got %+v", response.Items)
+	}
+	deleted, stock, update, create := response.Items[0], response.Items[1], response.Items[2], response.Items[3]
+	if create.Actor != "owner" || create.Entity != auditProduct || create.Before != nil || create.After["productName"] != "Widget" {
+		t.Errorf("Expected the creation by owner with the new product, got %+v", create)
+	}
+	if update.Before["price"] != 2.5 || update.After["price"] != 3.0 {
+		t.Errorf("Expected the price change from 2.5 to 3, got %v -> %v", update.Before["price"], update.After["price"])
+	}
+	if stock.Reference == "" || stock.Before["units"] != 5.0 || stock.After["units"] != 3.0 {
+		t.Errorf("Expected the sale to take units from 5 to 3, got %+v", stock)
+	}
+	if deleted.Before["units"] != 3.0 || deleted.After != nil {
+		t.Errorf("Expected the deleted product with 3 units, got %+v", deleted)
+	}
+}
+
+func TestImportIsAudited(t *testing.T) {
+	s := newTestServer()
+	input := ProductInput{ProductName: "Widget", Units: 1, Price: 2}
+	s.importProduct(context.Background(), "org", "importer", input)
+	input.Price = 4
+	s.importProduct(context.Background(), "org", "importer", input)
+
+	entries, total, _ := s.Audit.List(context.Background(), "org", auditQuery{Limit: 10})
+	if total != 2 || entries[0].Action != auditUpdate || entries[1].Action != auditCreate || entries[0].Actor != "importer" {
+		t.Errorf("Expected the import to record a creation and an update by importer, got %+v", entries)
+	}
+}
@@ -0,0 +1,230 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"awesomeProject/platform/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+
+	accessToken  = "access"
+	refreshToken = "refresh"
+
+	// signInLimit bounds the sign-ins and sign-ups per client address and minute
+	signInLimit = 10
+)
+
+type Credentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// tokenClaims identify the user by the subject claim and the token by the ID claim,
+// which is what revocation stores. Type keeps refresh tokens out of the API.
+type tokenClaims struct {
+	Type string `json:"type"`
+	jwt.RegisteredClaims
+}
+
+// generateToken issues an access token for the user
+func (s *Server) generateToken(userID string) (string, time.Time, error) {
+	return s.signToken(userID, accessToken, accessTokenTTL)
+}
+
+func (s *Server) generateRefreshToken(userID string) (string, time.Time, error) {
+	return s.signToken(userID, refreshToken, refreshTokenTTL)
+}
+
+func (s *Server) signToken(userID, tokenType string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	claims := tokenClaims{
+		Type: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        primitive.NewObjectID().Hex(),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.JWTSecret))
+	return token, expiresAt, err
+}
+
+// parseToken validates the signature, expiry and type of a token. Revocation is
+// checked by the callers.
+func (s *Server) parseToken(tokenString, tokenType string) (*tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(s.JWTSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, errors.New("token has no subject or ID")
+	}
+	if claims.Type != tokenType {
+		return nil, fmt.Errorf("expected %s token, got %q", tokenType, claims.Type)
+	}
+	return &claims, nil
+}
+
+// issueTokens responds with a new access and refresh token for the user
+func (s *Server) issueTokens(c *gin.Context, userID string) {
+	token, expiresAt, err := s.generateToken(userID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error generating token"))
+		return
+	}
+	refresh, refreshExpiresAt, err := s.generateRefreshToken(userID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error generating token"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":                 token,
+		"expiresAt":             expiresAt,
+		"refreshToken":          refresh,
+		"refreshTokenExpiresAt": refreshExpiresAt,
+	})
+}
+
+func (s *Server) signIn(c *gin.Context) {
+	var credentials Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Username and password are required"))
+		return
+	}
+
+	user, err := s.Users.FindByUsername(c.Request.Context(), credentials.Username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching user"))
+		return
+	}
+
+	// Unknown users and wrong passwords get the same answer so usernames can't be probed
+	if errors.Is(err, ErrNotFound) || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password)) != nil {
+		respondError(c, errs.New(errs.ErrUnauthorized, "Invalid username or password"))
+		return
+	}
+
+	s.issueTokens(c, user.ID)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// refresh exchanges a refresh token for a new pair of tokens. Every refresh token can
+// be used once: it is revoked by the exchange, so a stolen token that was already
+// used is rejected.
+func (s *Server) refresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Refresh token is required"))
+		return
+	}
+
+	claims, err := s.parseToken(req.RefreshToken, refreshToken)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrUnauthorized, "Invalid or expired refresh token"))
+		return
+	}
+	first, err := s.Revoked.Revoke(c.Request.Context(), claims.ID, claims.ExpiresAt.Time)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error revoking token"))
+		return
+	}
+	if !first {
+		respondError(c, errs.New(errs.ErrUnauthorized, "Refresh token has been revoked"))
+		return
+	}
+
+	s.issueTokens(c, claims.Subject)
+}
+
+type signOutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// signOut revokes the access token of the request and the refresh token in the body,
+// if any. The refresh token must belong to the same user.
+func (s *Server) signOut(c *gin.Context) {
+	var req signOutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, errs.New(errs.ErrInvalid, "Invalid JSON format"))
+			return
+		}
+	}
+
+	access := c.MustGet("token").(*tokenClaims)
+	revoke := []*tokenClaims{access}
+	if req.RefreshToken != "" {
+		claims, err := s.parseToken(req.RefreshToken, refreshToken)
+		if err != nil || claims.Subject != access.Subject {
+			respondError(c, errs.New(errs.ErrInvalid, "Invalid refresh token"))
+			return
+		}
+		revoke = append(revoke, claims)
+	}
+
+	for _, claims := range revoke {
+		if _, err := s.Revoked.Revoke(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			respondError(c, errs.New(errs.ErrInternal, "Error revoking token"))
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Signed out successfully!"})
+}
+
+// authMiddleware validates the Bearer access token and checks it wasn't revoked. It
+// stores the user ID as "user" and the claims as "token" in the context, and records
+// the user for the logs of the request.
+func (s *Server) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			respondError(c, errs.New(errs.ErrUnauthorized, "Authorization token required"))
+			return
+		}
+
+		claims, err := s.parseToken(tokenString, accessToken)
+		if err != nil {
+			respondError(c, errs.New(errs.ErrUnauthorized, "Invalid or expired token"))
+			return
+		}
+		revoked, err := s.Revoked.IsRevoked(c.Request.Context(), claims.ID)
+		if err != nil {
+			respondError(c, errs.New(errs.ErrInternal, "Error checking token"))
+			return
+		}
+		if revoked {
+			respondError(c, errs.New(errs.ErrUnauthorized, "Token has been revoked"))
+			return
+		}
+
+		c.Set("user", claims.Subject)
+		c.Set("token", claims)
+		logging.WithUserID(c.Request.Context(), claims.Subject)
+		c.Next()
+	}
+}
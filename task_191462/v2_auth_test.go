@@ -0,0 +1,205 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+
+	validToken, _, _ := s.generateToken("user-1")
+	expiredToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	}).SignedString([]byte(s.JWTSecret))
+	foreignToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user-1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("other-secret"))
+
+	tests := []struct {
+		name           string
+		header         string
+		expectedStatus int
+		expectedUser   string
+	}{
+		{
+			name:           "Valid Token",
+			header:         "Bearer " + validToken,
+			expectedStatus: 200,
+			expectedUser:   "user-1",
+		},
+		{
+			name:           "Missing Header",
+			expectedStatus: 401,
+		},
+		{
+			name:           "Token Without Bearer Scheme",
+			header:         validToken,
+			expectedStatus: 401,
+		},
+		{
+			name:           "Expired Token",
+			header:         "Bearer " + expiredToken,
+			expectedStatus: 401,
+		},
+		{
+			name:           "Token Signed With Another Secret",
+			header:         "Bearer " + foreignToken,
+			expectedStatus: 401,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user interface{}
+			r := gin.New()
+			r.GET("/auth/allProducts", s.authMiddleware(), func(c *gin.Context) {
+				user, _ = c.Get("user")
+				c.Status(200)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/auth/allProducts", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			r.ServeHTTP(w, req)
+
+			// Check the status code and the user set for the handlers
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedUser != "" && user != tt.expectedUser {
+				t.Errorf("Expected user %q, got %v", tt.expectedUser, user)
+			}
+		})
+	}
+}
+
+func TestRefreshAndSignOut(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	do := func(path, token, body string) (int, map[string]string) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		var response map[string]string
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+	refreshBody := func(token string) string {
+		return `{"refreshToken":"` + token + `"}`
+	}
+
+	do("/signup", "", `{"username":"alice","password":"secret"}`)
+	code, first := do("/signin", "", `{"username":"alice","password":"secret"}`)
+	if code != 200 || first["token"] == "" || first["refreshToken"] == "" {
+		t.Fatalf("Expected tokens from sign in, got %d %v", code, first)
+	}
+
+	code, second := do("/refresh", "", refreshBody(first["refreshToken"]))
+	if code != 200 || second["refreshToken"] == "" || second["refreshToken"] == first["refreshToken"] {
+		t.Fatalf("Expected a new refresh token, got %d %v", code, second)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Used Refresh Token Is Rejected",
+			path:           "/refresh",
+			body:           refreshBody(first["refreshToken"]),
+			expectedStatus: 401,
+		},
+		{
+			name:           "Access Token Can't Refresh",
+			path:           "/refresh",
+			body:           refreshBody(second["token"]),
+			expectedStatus: 401,
+		},
+		{
+			name:           "Refresh Token Can't Access The API",
+			path:           "/signout",
+			token:          second["refreshToken"],
+			expectedStatus: 401,
+		},
+		{
+			name:           "Sign Out Revokes Both Tokens",
+			path:           "/signout",
+			token:          second["token"],
+			body:           refreshBody(second["refreshToken"]),
+			expectedStatus: 200,
+		},
+		{
+			name:           "Revoked Access Token",
+			path:           "/signout",
+			token:          second["token"],
+			expectedStatus: 401,
+		},
+		{
+			name:           "Revoked Refresh Token",
+			path:           "/refresh",
+			body:           refreshBody(second["refreshToken"]),
+			expectedStatus: 401,
+		},
+		{
+			name:           "Earlier Access Token Still Works",
+			path:           "/signout",
+			token:          first["token"],
+			expectedStatus: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, response := do(tt.path, tt.token, tt.body)
+			if code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %v", tt.expectedStatus, code, response)
+			}
+		})
+	}
+}
+
+func TestMemoryTokenDenylistExpiry(t *testing.T) {
+	d := NewMemoryTokenDenylist()
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if first, _ := d.Revoke(context.Background(), "jti", now.Add(time.Minute)); !first {
+		t.Errorf("Expected the first revocation to be reported")
+	}
+	if first, _ := d.Revoke(context.Background(), "jti", now.Add(time.Minute)); first {
+		t.Errorf("Expected the second revocation not to be reported")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if revoked, _ := d.IsRevoked(context.Background(), "jti"); revoked {
+		t.Errorf("Expected the entry to expire with the token")
+	}
+}
@@ -0,0 +1,319 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	attributeString  = "string"
+	attributeNumber  = "number"
+	attributeInteger = "integer"
+	attributeBoolean = "boolean"
+)
+
+// Category groups an organization's products. Its schema lists the attributes products of the
+// category may have, attributes outside the schema are rejected.
+type Category struct {
+	ID         string          `json:"id,omitempty" bson:"_id,omitempty"`
+	OrgID      string          `json:"orgID" bson:"orgID"`
+	Name       string          `json:"name" bson:"name"`
+	Attributes []AttributeSpec `json:"attributes" bson:"attributes"`
+}
+
+// AttributeSpec describes an attribute such as color or size. Values, if set, lists
+// the allowed values of a string attribute.
+type AttributeSpec struct {
+	Name     string   `json:"name" bson:"name" binding:"required,max=50"`
+	Type     string   `json:"type" bson:"type" binding:"required,oneof=string number integer boolean"`
+	Required bool     `json:"required,omitempty" bson:"required,omitempty"`
+	Values   []string `json:"values,omitempty" bson:"values,omitempty" binding:"max=100"`
+}
+
+type CategoryInput struct {
+	Name       string          `json:"name" binding:"required,max=100"`
+	Attributes []AttributeSpec `json:"attributes" binding:"max=50,dive"`
+}
+
+// CategoryRepository stores the categories of organizations. Products refer to them by ID.
+type CategoryRepository interface {
+	// CreateCategory stores a new category and sets its ID
+	CreateCategory(ctx context.Context, category *Category) error
+	// FindCategory returns ErrNotFound for unknown categories, it doesn't check the owner
+	FindCategory(ctx context.Context, id string) (*Category, error)
+	// ListCategories returns the organization's categories ordered by name
+	ListCategories(ctx context.Context, orgID string) ([]Category, error)
+	// UpdateCategory sets the name and the schema of a category
+	UpdateCategory(ctx context.Context, category Category) (*Category, error)
+	DeleteCategory(ctx context.Context, orgID, id string) error
+}
+
+// schemaErrors checks that attribute names are unique and that only string attributes
+// list allowed values
+func (input CategoryInput) schemaErrors() map[string]string {
+	fields := map[string]string{}
+	seen := make(map[string]bool)
+	for i, spec := range input.Attributes {
+		field := fmt.Sprintf("attributes[%d]", i)
+		if seen[spec.Name] {
+			fields[field+".name"] = "is already defined"
+		}
+		seen[spec.Name] = true
+		if len(spec.Values) > 0 && spec.Type != attributeString {
+			fields[field+".values"] = "are only allowed for string attributes"
+		}
+	}
+	return fields
+}
+
+// validateAttributes checks the attributes of a product against the schema of its
+// category. Errors are keyed attributes.<name>.
+func validateAttributes(schema []AttributeSpec, attributes map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	specs := make(map[string]AttributeSpec, len(schema))
+	for _, spec := range schema {
+		specs[spec.Name] = spec
+		if _, ok := attributes[spec.Name]; !ok && spec.Required {
+			fields["attributes."+spec.Name] = "is required"
+		}
+	}
+
+	for name, value := range attributes {
+		field := "attributes." + name
+		spec, ok := specs[name]
+		if !ok {
+			fields[field] = "is not defined for the category"
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if spec.Type != attributeString {
+				fields[field] = "must be " + attributeTypeName(spec.Type)
+			} else if len(spec.Values) > 0 && !containsString(spec.Values, v) {
+				fields[field] = "must be one of " + strings.Join(spec.Values, ", ")
+			}
+		case float64:
+			if spec.Type == attributeInteger && v != math.Trunc(v) || spec.Type != attributeNumber && spec.Type != attributeInteger {
+				fields[field] = "must be " + attributeTypeName(spec.Type)
+			}
+		case bool:
+			if spec.Type != attributeBoolean {
+				fields[field] = "must be " + attributeTypeName(spec.Type)
+			}
+		default:
+			fields[field] = "must be " + attributeTypeName(spec.Type)
+		}
+	}
+	return fields
+}
+
+func attributeTypeName(t string) string {
+	switch t {
+	case attributeNumber:
+		return "a number"
+	case attributeInteger:
+		return "an integer"
+	case attributeBoolean:
+		return "a boolean"
+	}
+	return "a string"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTags lowercases and trims the tags, dropping empty and repeated ones, and
+// sorts them
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// checkCategory validates the category and the attributes of a product and returns
+// the category, nil for products without one. It writes the error response and
+// returns false when they are invalid.
+func (s *Server) checkCategory(c *gin.Context, product *InventoryItem) (*Category, bool) {
+	if product.CategoryID == "" {
+		if len(product.Attributes) > 0 {
+			respondError(c, errs.Validation(map[string]string{"attributes": "require a category"}))
+			return nil, false
+		}
+		return nil, true
+	}
+
+	category, ok := s.ownedCategory(c, product.CategoryID)
+	if !ok {
+		return nil, false
+	}
+	if fields := validateAttributes(category.Attributes, product.Attributes); len(fields) > 0 {
+		respondError(c, errs.Validation(fields))
+		return nil, false
+	}
+	return category, true
+}
+
+// ownedCategory loads a category and checks that it belongs to the authenticated
+// user. It writes the error response and returns false otherwise.
+func (s *Server) ownedCategory(c *gin.Context, id string) (*Category, bool) {
+	if !primitive.IsValidObjectID(id) {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid category ID format"))
+		return nil, false
+	}
+
+	category, err := s.Categories.FindCategory(c.Request.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrNotFound, "Category not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching category"))
+		return nil, false
+	}
+	if category.OrgID != c.GetString("org") {
+		respondError(c, errs.New(errs.ErrForbidden, "Category belongs to another organization"))
+		return nil, false
+	}
+	return category, true
+}
+
+// bindCategory binds and validates the category payload, checking that the name is
+// not used by another category of the organization
+func (s *Server) bindCategory(c *gin.Context, excludeID string) (*CategoryInput, bool) {
+	var input CategoryInput
+	if !bindJSON(c, &input) {
+		return nil, false
+	}
+	if fields := input.schemaErrors(); len(fields) > 0 {
+		respondError(c, errs.Validation(fields))
+		return nil, false
+	}
+
+	categories, err := s.Categories.ListCategories(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching categories"))
+		return nil, false
+	}
+	for _, category := range categories {
+		if category.Name == input.Name && category.ID != excludeID {
+			respondError(c, errs.New(errs.ErrConflict, "Category with this name already exists in this organization"))
+			return nil, false
+		}
+	}
+	if input.Attributes == nil {
+		input.Attributes = []AttributeSpec{}
+	}
+	return &input, true
+}
+
+func (s *Server) createCategory(c *gin.Context) {
+	input, ok := s.bindCategory(c, "")
+	if !ok {
+		return
+	}
+
+	category := Category{OrgID: c.GetString("org"), Name: input.Name, Attributes: input.Attributes}
+	if err := s.Categories.CreateCategory(c.Request.Context(), &category); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to create category"))
+		return
+	}
+	s.audit(c, AuditEntry{Entity: auditCategory, Action: auditCreate, ItemID: category.ID}, nil, category)
+	c.JSON(http.StatusCreated, category)
+}
+
+func (s *Server) getCategories(c *gin.Context) {
+	categories, err := s.Categories.ListCategories(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching categories"))
+		return
+	}
+	c.JSON(http.StatusOK, categories)
+}
+
+func (s *Server) getCategory(c *gin.Context) {
+	category, ok := s.ownedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// updateCategory renames a category or changes its schema. Products keep their
+// attributes, the new schema applies when they are next updated.
+func (s *Server) updateCategory(c *gin.Context) {
+	existing, ok := s.ownedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	input, ok := s.bindCategory(c, existing.ID)
+	if !ok {
+		return
+	}
+
+	updated, err := s.Categories.UpdateCategory(c.Request.Context(), Category{
+		ID:         existing.ID,
+		OrgID:      existing.OrgID,
+		Name:       input.Name,
+		Attributes: input.Attributes,
+	})
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to update category"))
+		return
+	}
+	s.audit(c, AuditEntry{Entity: auditCategory, Action: auditUpdate, ItemID: updated.ID}, existing, updated)
+	c.JSON(http.StatusOK, updated)
+}
+
+// deleteCategory deletes a category without products
+func (s *Server) deleteCategory(c *gin.Context) {
+	category, ok := s.ownedCategory(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	_, total, err := s.Inventory.List(c.Request.Context(), category.OrgID, listQuery{Limit: 1, CategoryID: category.ID})
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching products"))
+		return
+	}
+	if total > 0 {
+		respondError(c, errs.New(errs.ErrConflict, "Category still has products"))
+		return
+	}
+
+	if err := s.Categories.DeleteCategory(c.Request.Context(), category.OrgID, category.ID); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to delete category"))
+		return
+	}
+	s.audit(c, AuditEntry{Entity: auditCategory, Action: auditDelete, ItemID: category.ID}, category, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully!"})
+}
@@ -0,0 +1,173 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCategoryProducts(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	shirts := Category{OrgID: "owner", Name: "Shirts", Attributes: []AttributeSpec{
+		{Name: "color", Type: "string", Required: true, Values: []string{"red", "blue"}},
+		{Name: "size", Type: "integer"},
+	}}
+	foreign := Category{OrgID: "someone-else", Name: "Shoes"}
+	for _, category := range []*Category{&shirts, &foreign} {
+		s.Categories.CreateCategory(context.Background(), category)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedFields map[string]string
+	}{
+		{
+			name:           "Duplicate Category Name",
+			method:         "POST",
+			path:           "/auth/categories",
+			body:           `{"name":"Shirts"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Duplicate Attribute In Schema",
+			method:         "POST",
+			path:           "/auth/categories",
+			body:           `{"name":"Hats","attributes":[{"name":"size","type":"number"},{"name":"size","type":"string"}]}`,
+			expectedStatus: 422,
+			expectedFields: map[string]string{"attributes[1].name": "is already defined"},
+		},
+		{
+			name:           "Unknown Attribute Type",
+			method:         "POST",
+			path:           "/auth/categories",
+			body:           `{"name":"Hats","attributes":[{"name":"size","type":"date"}]}`,
+			expectedStatus: 422,
+			expectedFields: map[string]string{"type": "must be one of string, number, integer, boolean"},
+		},
+		{
+			name:           "Product With Valid Attributes",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Red Shirt","units":3,"price":20,"categoryID":"` + shirts.ID + `","tags":["Summer"," sale "],"attributes":{"color":"red","size":42}}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Attributes Outside The Schema",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Blue Shirt","units":3,"price":20,"categoryID":"` + shirts.ID + `","attributes":{"color":"green","size":4.5,"fabric":"linen"}}`,
+			expectedStatus: 422,
+			expectedFields: map[string]string{
+				"attributes.color":  "must be one of red, blue",
+				"attributes.size":   "must be an integer",
+				"attributes.fabric": "is not defined for the category",
+			},
+		},
+		{
+			name:           "Missing Required Attribute",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Blue Shirt","units":3,"price":20,"categoryID":"` + shirts.ID + `","attributes":{"size":40}}`,
+			expectedStatus: 422,
+			expectedFields: map[string]string{"attributes.color": "is required"},
+		},
+		{
+			name:           "Attributes Without A Category",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Blue Shirt","units":3,"price":20,"attributes":{"color":"blue"}}`,
+			expectedStatus: 422,
+			expectedFields: map[string]string{"attributes": "require a category"},
+		},
+		{
+			name:           "Another User's Category",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Blue Shirt","units":3,"price":20,"categoryID":"` + foreign.ID + `"}`,
+			expectedStatus: 403,
+		},
+		{
+			name:           "Tagged Product Without Category",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Sunglasses","units":1,"price":15,"tags":["summer"]}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Category With Products Can't Be Deleted",
+			method:         "DELETE",
+			path:           "/auth/categories/" + shirts.ID,
+			expectedStatus: 409,
+		},
+	}
+
+	token, _, _ := s.generateToken("owner")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.path, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedFields != nil {
+				var response errs.Problem
+				json.Unmarshal(w.Body.Bytes(), &response)
+				if !reflect.DeepEqual(response.Fields, tt.expectedFields) {
+					t.Errorf("Expected field errors %v, got %v", tt.expectedFields, response.Fields)
+				}
+			}
+		})
+	}
+
+	listed := func(query string) []InventoryItem {
+		var page struct {
+			Items []InventoryItem `json:"items"`
+		}
+		json.Unmarshal(do("GET", "/auth/allProducts?"+query, "").Body.Bytes(), &page)
+		return page.Items
+	}
+	for query, expected := range map[string][]string{
+		"category=" + shirts.ID: {"Red Shirt"},
+		"tag=SUMMER":            {"Red Shirt", "Sunglasses"},
+		"tag=summer&tag=sale":   {"Red Shirt"},
+		"tag=winter":            {},
+	} {
+		names := []string{}
+		for _, item := range listed(query) {
+			names = append(names, item.ProductName)
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected %v for %s, got %v", expected, query, names)
+		}
+	}
+
+	if items := listed("category=" + shirts.ID); len(items) != 1 || !reflect.DeepEqual(items[0].Tags, []string{"sale", "summer"}) {
+		t.Errorf("Expected normalized tags [sale summer], got %+v", items)
+	}
+}
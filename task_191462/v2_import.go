@@ -0,0 +1,348 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 10000
+)
+
+// exportHeader is also accepted as the header of imports, so exported files can be
+// imported again. Columns are matched case insensitively, spaces are ignored.
+var exportHeader = []string{"Product Name", "Units", "Price", "Reorder Level", "Stock Value"}
+
+var errUnsupportedFormat = errors.New("unsupported file format, use .csv or .xlsx")
+
+// ImportRowError reports why a row was not imported, Row is the row number in the
+// file counting the header as row 1
+type ImportRowError struct {
+	Row    int               `json:"row"`
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// ImportResult summarizes an import. Rows naming an existing product update its
+// price and reorder level, the units of existing products only change through
+// stock movements.
+type ImportResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+// readImportRows returns the rows of a CSV file or of the first sheet of an XLSX file
+func readImportRows(filename string, r io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return reader.ReadAll()
+	case ".xlsx":
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetRows(f.GetSheetName(0))
+	}
+	return nil, errUnsupportedFormat
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", ""))
+}
+
+// importColumns maps the columns of the header to their index. Product name, units
+// and price are required, the reorder level is optional.
+func importColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[normalizeColumn(name)] = i
+	}
+	for _, required := range []string{"productname", "units", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	return columns, nil
+}
+
+// isTotalsRow reports the totals row of an exported file
+func isTotalsRow(row []string, columns map[string]int) bool {
+	name, price := columns["productname"], columns["price"]
+	return name < len(row) && row[name] == "Total" && (price >= len(row) || strings.TrimSpace(row[price]) == "")
+}
+
+// parseImportRow converts a row to a validated ProductInput, or returns the errors
+// per field
+func parseImportRow(row []string, columns map[string]int) (ProductInput, map[string]string) {
+	cell := func(column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	fields := make(map[string]string)
+	input := ProductInput{ProductName: cell("productname")}
+	var err error
+	if input.Units, err = strconv.Atoi(cell("units")); err != nil {
+		fields["units"] = "must be an integer"
+	}
+	if input.Price, err = strconv.ParseFloat(cell("price"), 64); err != nil {
+		fields["price"] = "must be a number"
+	}
+	if v := cell("reorderlevel"); v != "" {
+		if input.ReorderLevel, err = strconv.Atoi(v); err != nil {
+			fields["reorderLevel"] = "must be an integer"
+		}
+	}
+
+	var fieldErrs validator.ValidationErrors
+	if errors.As(binding.Validator.ValidateStruct(input), &fieldErrs) {
+		for _, fe := range fieldErrs {
+			if _, ok := fields[fe.Field()]; !ok {
+				fields[fe.Field()] = validationMessage(fe)
+			}
+		}
+	}
+	if len(fields) > 0 {
+		return input, fields
+	}
+	return input, nil
+}
+
+// importProducts reads the "file" upload. Every row is validated on its own, invalid
+// rows and repeated product names are reported without stopping the import.
+func (s *Server) importProducts(c *gin.Context) {
+	orgID := c.GetString("org")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "A file upload named file is required"))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Error reading upload"))
+		return
+	}
+	defer file.Close()
+
+	rows, err := readImportRows(fileHeader.Filename, file)
+	if errors.Is(err, errUnsupportedFormat) {
+		respondError(c, errs.New(errs.ErrUnsupportedType, "Unsupported file format, use .csv or .xlsx"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Error parsing file: "+err.Error()))
+		return
+	}
+	if len(rows) == 0 {
+		respondError(c, errs.New(errs.ErrInvalid, "File is empty"))
+		return
+	}
+	if len(rows)-1 > maxImportRows {
+		respondError(c, errs.New(errs.ErrTooLarge, fmt.Sprintf("Files are limited to %d rows", maxImportRows)))
+		return
+	}
+	columns, err := importColumns(rows[0])
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid header: "+err.Error()))
+		return
+	}
+
+	result := ImportResult{Errors: []ImportRowError{}}
+	seen := make(map[string]int)
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+		if strings.TrimSpace(strings.Join(row, "")) == "" || isTotalsRow(row, columns) {
+			continue
+		}
+
+		input, fields := parseImportRow(row, columns)
+		if fields != nil {
+			result.Errors = append(result.Errors, ImportRowError{Row: rowNumber, Error: "Validation failed", Fields: fields})
+			continue
+		}
+		if first, ok := seen[input.ProductName]; ok {
+			result.Errors = append(result.Errors, ImportRowError{Row: rowNumber, Error: fmt.Sprintf("Duplicate of row %d", first)})
+			continue
+		}
+		seen[input.ProductName] = rowNumber
+
+		created, err := s.importProduct(c.Request.Context(), orgID, c.GetString("user"), input)
+		if err != nil {
+			log.Println("Error importing product:", err)
+			result.Errors = append(result.Errors, ImportRowError{Row: rowNumber, Error: "Failed to save product"})
+			continue
+		}
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// importProduct creates the product, or updates the organization's product of the same
+// name, and records the change as made by actor
+func (s *Server) importProduct(ctx context.Context, orgID, actor string, input ProductInput) (bool, error) {
+	product := input.item(orgID)
+	entry := AuditEntry{OrgID: orgID, Actor: actor, Entity: auditProduct}
+	existing, err := s.Inventory.FindByName(ctx, orgID, product.ProductName)
+	if errors.Is(err, ErrNotFound) {
+		if err := s.assignCodes(ctx, &product, nil); err != nil {
+			return false, err
+		}
+		if err := s.Inventory.Create(ctx, &product); err != nil {
+			return false, err
+		}
+		entry.Action, entry.ItemID = auditCreate, product.ID
+		s.recordAudit(ctx, entry, nil, product)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// files don't carry the catalog fields, keep them
+	product.ID = existing.ID
+	product.CategoryID, product.Tags, product.Attributes = existing.CategoryID, existing.Tags, existing.Attributes
+	product.SKU, product.Barcode, product.UnitCost = existing.SKU, existing.Barcode, existing.UnitCost
+	updated, err := s.Inventory.Update(ctx, product)
+	if err != nil {
+		return false, err
+	}
+	entry.Action, entry.ItemID = auditUpdate, updated.ID
+	s.recordAudit(ctx, entry, existing, updated)
+	return false, nil
+}
+
+// exportProducts streams the organization's inventory as CSV or XLSX, selected by the format
+// query parameter, with the stock value of each product and a totals row
+func (s *Server) exportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		respondError(c, errs.New(errs.ErrInvalid, "format must be csv or xlsx"))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="inventory.`+format+`"`)
+
+	var err error
+	if format == "csv" {
+		err = s.exportCSV(c)
+	} else {
+		err = s.exportXLSX(c)
+	}
+	if err != nil {
+		// the response may be partly written, the truncated file is all we can do
+		log.Println("Error exporting inventory:", err)
+		if !c.Writer.Written() {
+			respondError(c, errs.New(errs.ErrInternal, "Error exporting inventory"))
+		}
+	}
+}
+
+func (s *Server) exportCSV(c *gin.Context) error {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	w := csv.NewWriter(c.Writer)
+	if err := w.Write(exportHeader); err != nil {
+		return err
+	}
+
+	var totalUnits int
+	var totalValue float64
+	err := s.Inventory.Each(c.Request.Context(), c.GetString("org"), func(item InventoryItem) error {
+		value := float64(item.Units) * item.Price
+		totalUnits += item.Units
+		totalValue += value
+		return w.Write([]string{
+			item.ProductName,
+			strconv.Itoa(item.Units),
+			strconv.FormatFloat(item.Price, 'f', -1, 64),
+			strconv.Itoa(item.ReorderLevel),
+			strconv.FormatFloat(value, 'f', 2, 64),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Write([]string{"Total", strconv.Itoa(totalUnits), "", "", strconv.FormatFloat(totalValue, 'f', 2, 64)})
+	w.Flush()
+	return w.Error()
+}
+
+// exportXLSX streams the rows into the workbook, the workbook itself is written once
+// complete as the format is a zip archive
+func (s *Server) exportXLSX(c *gin.Context) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	sheet := "Inventory"
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return err
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(exportHeader))
+	for i, name := range exportHeader {
+		header[i] = name
+	}
+	if err := sw.SetRow("A1", header); err != nil {
+		return err
+	}
+
+	row := 1
+	var totalUnits int
+	var totalValue float64
+	err = s.Inventory.Each(c.Request.Context(), c.GetString("org"), func(item InventoryItem) error {
+		row++
+		value := float64(item.Units) * item.Price
+		totalUnits += item.Units
+		totalValue += value
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		return sw.SetRow(cell, []interface{}{item.ProductName, item.Units, item.Price, item.ReorderLevel, value})
+	})
+	if err != nil {
+		return err
+	}
+
+	cell, _ := excelize.CoordinatesToCellName(1, row+1)
+	if err := sw.SetRow(cell, []interface{}{"Total", totalUnits, nil, nil, totalValue}); err != nil {
+		return err
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Status(http.StatusOK)
+	return f.Write(c.Writer)
+}
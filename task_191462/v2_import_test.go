@@ -0,0 +1,153 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+func uploadRequest(s *Server, filename string, content []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", filename)
+	part.Write(content)
+	mw.Close()
+
+	token, _, _ := s.generateToken("importer")
+	req := httptest.NewRequest("POST", "/auth/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	r := gin.New()
+	s.setupRoutes(r)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestImportProducts(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	existing := InventoryItem{OrgID: "importer", ProductName: "Bolt", Units: 7, Price: 0.1}
+	s.Inventory.Create(context.Background(), &existing)
+
+	csvFile := "Product Name,Units,Price,Reorder Level\n" +
+		"Nut,100,0.05,20\n" +
+		"Bolt,50,0.2,\n" +
+		",5,1\n" +
+		"Washer,many,-1\n" +
+		"Nut,3,0.05\n" +
+		"\n" +
+		"Screw,40,0.08,10\n"
+
+	w := uploadRequest(s, "stock.csv", []byte(csvFile))
+	if w.Code != 200 {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result ImportResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Created != 2 || result.Updated != 1 {
+		t.Errorf("Expected 2 created and 1 updated, got %d and %d", result.Created, result.Updated)
+	}
+
+	expectedErrors := map[int]string{4: "productName", 5: "units,price", 6: "Duplicate of row 2"}
+	if len(result.Errors) != len(expectedErrors) {
+		t.Fatalf("Expected %d row errors, got %+v", len(expectedErrors), result.Errors)
+	}
+	for _, rowErr := range result.Errors {
+		expected := expectedErrors[rowErr.Row]
+		if rowErr.Fields == nil {
+			if rowErr.Error != expected {
+				t.Errorf("Row %d: expected error '%s', got '%s'", rowErr.Row, expected, rowErr.Error)
+			}
+			continue
+		}
+		for _, field := range strings.Split(expected, ",") {
+			if rowErr.Fields[field] == "" {
+				t.Errorf("Row %d: expected an error for %s, got %v", rowErr.Row, field, rowErr.Fields)
+			}
+		}
+	}
+
+	bolt, _ := s.Inventory.FindByName(context.Background(), "importer", "Bolt")
+	if bolt.Units != 7 || bolt.Price != 0.2 {
+		t.Errorf("Expected Bolt to keep 7 units at the new price 0.2, got %d at %v", bolt.Units, bolt.Price)
+	}
+
+	w = uploadRequest(s, "stock.pdf", []byte("%PDF"))
+	if w.Code != 415 {
+		t.Errorf("Expected status 415, got %d", w.Code)
+	}
+	w = uploadRequest(s, "stock.csv", []byte("Name,Count\nNut,1\n"))
+	if w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestExportProducts(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	for _, item := range []InventoryItem{
+		{OrgID: "importer", ProductName: "Nut", Units: 100, Price: 0.05, ReorderLevel: 20},
+		{OrgID: "importer", ProductName: "Bolt", Units: 10, Price: 0.25},
+		{OrgID: "someone-else", ProductName: "Gear", Units: 1, Price: 9},
+	} {
+		s.Inventory.Create(context.Background(), &item)
+	}
+	r := gin.New()
+	s.setupRoutes(r)
+	token, _, _ := s.generateToken("importer")
+
+	export := func(format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/auth/export?format="+format, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := export("csv")
+	expected := "Product Name,Units,Price,Reorder Level,Stock Value\n" +
+		"Bolt,10,0.25,0,2.50\n" +
+		"Nut,100,0.05,20,5.00\n" +
+		"Total,110,,,7.50\n"
+	if w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("Expected CSV:\n%s\nGot %d:\n%s", expected, w.Code, w.Body.String())
+	}
+
+	w = export("xlsx")
+	f, err := excelize.OpenReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a workbook, got %v", err)
+	}
+	rows, _ := f.GetRows("Inventory")
+	if len(rows) != 4 || rows[1][0] != "Bolt" || rows[3][0] != "Total" || rows[3][1] != "110" || rows[3][4] != "7.5" {
+		t.Errorf("Unexpected workbook rows: %v", rows)
+	}
+
+	// an exported workbook imports again without errors
+	var xlsx bytes.Buffer
+	f.Write(&xlsx)
+	w = uploadRequest(s, "inventory.xlsx", xlsx.Bytes())
+	var result ImportResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Updated != 2 || len(result.Errors) != 0 {
+		t.Errorf("Expected 2 updates without errors, got %+v", result)
+	}
+
+	if w := export("pdf"); w.Code != 400 {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,167 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// sortFields maps the sort query values to document fields, a leading "-" sorts descending
+var sortFields = map[string]string{
+	"name":  "productName",
+	"price": "price",
+	"units": "units",
+}
+
+// listQuery holds the parsed query parameters of the product listing
+type listQuery struct {
+	Limit    int
+	Offset   int
+	Search   string
+	MinPrice *float64
+	MaxPrice *float64
+	SortBy   string
+	Desc     bool
+	// CategoryID and Tags narrow the listing to the category and the products carrying
+	// all of the tags
+	CategoryID string
+	Tags       []string
+}
+
+// parseListQuery reads limit, offset, q, minPrice, maxPrice, sort, category and the
+// repeatable tag, returning an error message for invalid values
+func parseListQuery(c *gin.Context) (listQuery, string) {
+	q := listQuery{
+		Search:     strings.TrimSpace(c.Query("q")),
+		CategoryID: c.Query("category"),
+		Tags:       normalizeTags(c.QueryArray("tag")),
+	}
+	if q.CategoryID != "" && !primitive.IsValidObjectID(q.CategoryID) {
+		return q, "category must be a category ID"
+	}
+
+	var msg string
+	if q.Limit, q.Offset, msg = parsePage(c); msg != "" {
+		return q, msg
+	}
+	for name, target := range map[string]**float64{"minPrice": &q.MinPrice, "maxPrice": &q.MaxPrice} {
+		if v := c.Query(name); v != "" {
+			price, err := strconv.ParseFloat(v, 64)
+			if err != nil || price < 0 {
+				return q, name + " must be a non-negative number"
+			}
+			*target = &price
+		}
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return q, "minPrice must not exceed maxPrice"
+	}
+	if v := c.Query("sort"); v != "" {
+		field, ok := sortFields[strings.TrimPrefix(v, "-")]
+		if !ok {
+			return q, "sort must be one of name, price or units, optionally prefixed with -"
+		}
+		q.SortBy, q.Desc = field, strings.HasPrefix(v, "-")
+	}
+	return q, ""
+}
+
+// parsePage reads limit and offset, returning an error message for invalid values
+func parsePage(c *gin.Context) (int, int, string) {
+	limit, offset := defaultPageSize, 0
+	if v := c.Query("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, "limit must be between 1 and " + strconv.Itoa(maxPageSize)
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		var err error
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, "offset must be a non-negative integer"
+		}
+	}
+	return limit, offset, ""
+}
+
+func (q listQuery) filter(orgID string) bson.M {
+	filter := bson.M{"orgID": orgID}
+	if q.Search != "" {
+		filter["$text"] = bson.M{"$search": q.Search}
+	}
+	price := bson.M{}
+	if q.MinPrice != nil {
+		price["$gte"] = *q.MinPrice
+	}
+	if q.MaxPrice != nil {
+		price["$lte"] = *q.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["price"] = price
+	}
+	if q.CategoryID != "" {
+		filter["categoryID"] = q.CategoryID
+	}
+	if len(q.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": q.Tags}
+	}
+	return filter
+}
+
+// findOptions pages and sorts the results. Searches without an explicit sort are
+// ordered by relevance, everything else falls back to the product name. The _id
+// tiebreaker keeps pages stable.
+func (q listQuery) findOptions() *options.FindOptions {
+	opts := options.Find().SetSkip(int64(q.Offset)).SetLimit(int64(q.Limit))
+	switch {
+	case q.SortBy != "":
+		direction := 1
+		if q.Desc {
+			direction = -1
+		}
+		opts.SetSort(bson.D{{Key: q.SortBy, Value: direction}, {Key: "_id", Value: 1}})
+	case q.Search != "":
+		score := bson.M{"$meta": "textScore"}
+		opts.SetProjection(bson.M{"score": score}).SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}})
+	default:
+		opts.SetSort(bson.D{{Key: "productName", Value: 1}, {Key: "_id", Value: 1}})
+	}
+	return opts
+}
+
+func (s *Server) getUserProducts(c *gin.Context) {
+	q, msg := parseListQuery(c)
+	if msg != "" {
+		respondError(c, errs.New(errs.ErrInvalid, msg))
+		return
+	}
+
+	products, total, err := s.Inventory.List(c.Request.Context(), c.GetString("org"), q)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  products,
+		"total":  total,
+		"limit":  q.Limit,
+		"offset": q.Offset,
+	})
+}
@@ -0,0 +1,97 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseListQuery(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedFilter bson.M
+		expectedSort   interface{}
+		expectedError  string
+	}{
+		{
+			name:           "Defaults",
+			query:          "",
+			expectedFilter: bson.M{"orgID": "u1"},
+			expectedSort:   bson.D{{Key: "productName", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			name:           "Search Sorted By Relevance",
+			query:          "q=widget",
+			expectedFilter: bson.M{"orgID": "u1", "$text": bson.M{"$search": "widget"}},
+			expectedSort:   bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}},
+		},
+		{
+			name:           "Price Range And Descending Sort",
+			query:          "minPrice=5&maxPrice=10.5&sort=-price",
+			expectedFilter: bson.M{"orgID": "u1", "price": bson.M{"$gte": 5.0, "$lte": 10.5}},
+			expectedSort:   bson.D{{Key: "price", Value: -1}, {Key: "_id", Value: 1}},
+		},
+		{
+			name:           "Category And Tags",
+			query:          "category=64b7f0c2e13f4a2d9c8b4567&tag=Sale&tag=summer",
+			expectedFilter: bson.M{"orgID": "u1", "categoryID": "64b7f0c2e13f4a2d9c8b4567", "tags": bson.M{"$all": []string{"sale", "summer"}}},
+			expectedSort:   bson.D{{Key: "productName", Value: 1}, {Key: "_id", Value: 1}},
+		},
+		{
+			name:          "Invalid Category",
+			query:         "category=shirts",
+			expectedError: "category must be a category ID",
+		},
+		{
+			name:          "Limit Too Large",
+			query:         "limit=1000",
+			expectedError: "limit must be between 1 and 100",
+		},
+		{
+			name:          "Negative Offset",
+			query:         "offset=-1",
+			expectedError: "offset must be a non-negative integer",
+		},
+		{
+			name:          "Inverted Price Range",
+			query:         "minPrice=10&maxPrice=5",
+			expectedError: "minPrice must not exceed maxPrice",
+		},
+		{
+			name:          "Unknown Sort Field",
+			query:         "sort=userID",
+			expectedError: "sort must be one of name, price or units, optionally prefixed with -",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/auth/allProducts?"+tt.query, nil)
+
+			q, msg := parseListQuery(c)
+			if msg != tt.expectedError {
+				t.Fatalf("Expected error '%s', got '%s'", tt.expectedError, msg)
+			}
+			if msg != "" {
+				return
+			}
+			if filter := q.filter("u1"); !reflect.DeepEqual(filter, tt.expectedFilter) {
+				t.Errorf("Expected filter %v, got %v", tt.expectedFilter, filter)
+			}
+			if sort := q.findOptions().Sort; !reflect.DeepEqual(sort, tt.expectedSort) {
+				t.Errorf("Expected sort %v, got %v", tt.expectedSort, sort)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"awesomeProject/platform/config"
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) getLowStock(c *gin.Context) {
+	products, err := s.Inventory.LowStock(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching low stock products"))
+		return
+	}
+	c.JSON(http.StatusOK, products)
+}
+
+// Notifier delivers low stock alerts for the products of an organization
+type Notifier interface {
+	Notify(ctx context.Context, orgID string, items []InventoryItem) error
+}
+
+// WebhookNotifier posts {"orgID": ..., "items": [...]} as JSON to URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, orgID string, items []InventoryItem) error {
+	body, err := json.Marshal(gin.H{"orgID": orgID, "items": items})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpClient := n.Client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// EmailNotifier mails alerts to a fixed list of recipients, such as the purchasing team
+type EmailNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, orgID string, items []InventoryItem) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\nTo: %s\r\nSubject: Low stock for %d products\r\n\r\n", n.From, strings.Join(n.To, ", "), len(items))
+	fmt.Fprintf(&body, "The following products of organization %s are at or below their reorder level:\r\n\r\n", orgID)
+	for _, item := range items {
+		fmt.Fprintf(&body, "- %s: %d units (reorder level %d)\r\n", item.ProductName, item.Units, item.ReorderLevel)
+	}
+	return smtp.SendMail(n.Addr, n.Auth, n.From, n.To, []byte(body.String()))
+}
+
+// notifierFromConfig configures the webhook from low.stock.webhook.url or the email
+// alerts from smtp.addr, smtp.from and low.stock.email.to. It returns nil when neither
+// is set.
+func notifierFromConfig(cfg *config.Config) Notifier {
+	if url := cfg.String("low.stock.webhook.url", ""); url != "" {
+		return &WebhookNotifier{URL: url}
+	}
+	to := cfg.Strings("low.stock.email.to", nil)
+	if addr := cfg.String("smtp.addr", ""); addr != "" && len(to) > 0 {
+		n := &EmailNotifier{Addr: addr, From: cfg.String("smtp.from", ""), To: to}
+		if user := cfg.String("smtp.username", ""); user != "" {
+			host, _, _ := strings.Cut(addr, ":")
+			n.Auth = smtp.PlainAuth("", user, cfg.String("smtp.password", ""), host)
+		}
+		return n
+	}
+	return nil
+}
+
+// lowStockChecker remembers which products it alerted about, so a product is reported
+// once when it drops to its reorder level and again only after it was restocked
+type lowStockChecker struct {
+	repo     InventoryRepository
+	notifier Notifier
+	notified map[string]bool
+}
+
+// runLowStockChecker scans the inventory of all organizations every interval until ctx is done
+func runLowStockChecker(ctx context.Context, repo InventoryRepository, interval time.Duration, notifier Notifier) {
+	checker := &lowStockChecker{repo: repo, notifier: notifier, notified: make(map[string]bool)}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := checker.check(ctx); err != nil {
+			log.Println("Low stock check failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (lc *lowStockChecker) check(ctx context.Context) error {
+	items, err := lc.repo.LowStock(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for orgID, orgItems := range lc.newAlerts(items) {
+		if err := lc.notifier.Notify(ctx, orgID, orgItems); err != nil {
+			log.Printf("Low stock notification for organization %s failed: %v", orgID, err)
+			// retried on the next check
+			for _, item := range orgItems {
+				delete(lc.notified, item.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// newAlerts groups the low stock items that were not reported yet by organization
+func (lc *lowStockChecker) newAlerts(items []InventoryItem) map[string][]InventoryItem {
+	low := make(map[string]bool, len(items))
+	alerts := make(map[string][]InventoryItem)
+	for _, item := range items {
+		low[item.ID] = true
+		if !lc.notified[item.ID] {
+			lc.notified[item.ID] = true
+			alerts[item.OrgID] = append(alerts[item.OrgID], item)
+		}
+	}
+	// restocked products alert again next time
+	for id := range lc.notified {
+		if !low[id] {
+			delete(lc.notified, id)
+		}
+	}
+	return alerts
+}
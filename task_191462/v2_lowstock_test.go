@@ -0,0 +1,85 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLowStockAlertsAreReportedOnce(t *testing.T) {
+	checker := &lowStockChecker{notified: make(map[string]bool)}
+	a := InventoryItem{ID: "a", OrgID: "u1", Units: 1, ReorderLevel: 5}
+	b := InventoryItem{ID: "b", OrgID: "u1", Units: 0, ReorderLevel: 2}
+	c := InventoryItem{ID: "c", OrgID: "u2", Units: 3, ReorderLevel: 3}
+
+	tests := []struct {
+		name           string
+		lowItems       []InventoryItem
+		expectedAlerts map[string]int
+	}{
+		{
+			name:           "First Check Reports Everything",
+			lowItems:       []InventoryItem{a, b, c},
+			expectedAlerts: map[string]int{"u1": 2, "u2": 1},
+		},
+		{
+			name:           "Unchanged Items Are Not Reported Again",
+			lowItems:       []InventoryItem{a, b, c},
+			expectedAlerts: map[string]int{},
+		},
+		{
+			name:           "Restocked Item Drops Out",
+			lowItems:       []InventoryItem{a, c},
+			expectedAlerts: map[string]int{},
+		},
+		{
+			name:           "Item Low Again Is Reported",
+			lowItems:       []InventoryItem{a, b, c},
+			expectedAlerts: map[string]int{"u1": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := checker.newAlerts(tt.lowItems)
+			if len(alerts) != len(tt.expectedAlerts) {
+				t.Fatalf("Expected alerts for %d users, got %v", len(tt.expectedAlerts), alerts)
+			}
+			for userID, count := range tt.expectedAlerts {
+				if len(alerts[userID]) != count {
+					t.Errorf("Expected %d alerts for %s, got %d", count, userID, len(alerts[userID]))
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received struct {
+		OrgID string          `json:"orgID"`
+		Items []InventoryItem `json:"items"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	notifier := &WebhookNotifier{URL: server.URL}
+	err := notifier.Notify(context.Background(), "u1", []InventoryItem{{ProductName: "Widget", Units: 1, ReorderLevel: 5}})
+	if err != nil || received.OrgID != "u1" || len(received.Items) != 1 || received.Items[0].ProductName != "Widget" {
+		t.Errorf("Expected the alert to be posted, got %+v (%v)", received, err)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (&WebhookNotifier{URL: failing.URL}).Notify(context.Background(), "u1", nil); err == nil {
+		t.Errorf("Expected an error for a failing webhook")
+	}
+}
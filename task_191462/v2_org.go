@@ -0,0 +1,355 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	roleOwner   = "owner"
+	roleManager = "manager"
+	roleViewer  = "viewer"
+
+	// orgHeader selects the organization of a request, the personal organization of
+	// the user when missing
+	orgHeader = "X-Organization-ID"
+
+	invitationTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrAlreadyMember = errors.New("already a member")
+
+	// roleRank orders the roles, each role may do what the lower ones may
+	roleRank = map[string]int{roleViewer: 1, roleManager: 2, roleOwner: 3}
+)
+
+// Organization owns an inventory shared by its members. Every user has a personal
+// organization whose ID is the user ID, it holds the products created before
+// organizations existed.
+type Organization struct {
+	ID        string    `json:"id" bson:"_id"`
+	Name      string    `json:"name" bson:"name"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	// Role is the role of the user listing their organizations
+	Role string `json:"role,omitempty" bson:"-"`
+}
+
+// Membership gives a user a role in an organization. Owners manage the members,
+// managers change the inventory and viewers only read it.
+type Membership struct {
+	OrgID    string    `json:"orgID" bson:"orgID"`
+	UserID   string    `json:"userID" bson:"userID"`
+	Role     string    `json:"role" bson:"role"`
+	JoinedAt time.Time `json:"joinedAt" bson:"joinedAt"`
+}
+
+// Invitation offers a user a role in an organization until it expires
+type Invitation struct {
+	ID        string    `json:"id" bson:"_id"`
+	OrgID     string    `json:"orgID" bson:"orgID"`
+	OrgName   string    `json:"orgName" bson:"orgName"`
+	UserID    string    `json:"userID" bson:"userID"`
+	Role      string    `json:"role" bson:"role"`
+	InvitedBy string    `json:"invitedBy" bson:"invitedBy"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+type OrganizationInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type InvitationInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required,oneof=owner manager viewer"`
+}
+
+type RoleInput struct {
+	Role string `json:"role" binding:"required,oneof=owner manager viewer"`
+}
+
+// OrganizationRepository stores organizations, their members and the pending
+// invitations
+type OrganizationRepository interface {
+	// CreateOrganization stores a new organization, sets its ID and makes ownerID
+	// its owner
+	CreateOrganization(ctx context.Context, org *Organization, ownerID string) error
+	// EnsurePersonal creates the personal organization of a user and their owner
+	// membership, unless they exist
+	EnsurePersonal(ctx context.Context, userID string) error
+	FindOrganization(ctx context.Context, id string) (*Organization, error)
+	// Organizations lists the organizations of a user with the user's role, ordered
+	// by name
+	Organizations(ctx context.Context, userID string) ([]Organization, error)
+	// Membership returns ErrNotFound when the user isn't a member
+	Membership(ctx context.Context, orgID, userID string) (*Membership, error)
+	// Members lists the members of an organization, oldest first
+	Members(ctx context.Context, orgID string) ([]Membership, error)
+	SetRole(ctx context.Context, orgID, userID, role string) error
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	// CreateInvitation stores a new invitation and sets its ID
+	CreateInvitation(ctx context.Context, invitation *Invitation) error
+	// FindInvitation returns ErrNotFound for unknown and expired invitations
+	FindInvitation(ctx context.Context, id string) (*Invitation, error)
+	// Invitations lists the pending invitations of a user
+	Invitations(ctx context.Context, userID string) ([]Invitation, error)
+	// AcceptInvitation makes the invitee a member with the offered role and deletes
+	// the invitation. It returns ErrAlreadyMember when the invitee is a member.
+	AcceptInvitation(ctx context.Context, invitation Invitation) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+func hasRole(role, required string) bool {
+	return roleRank[role] >= roleRank[required]
+}
+
+// orgMiddleware resolves the organization of the request from the X-Organization-ID
+// header and checks the user is a member. It stores the organization ID as "org" and
+// the user's role as "role" in the context. The personal organization is created on
+// first use.
+func (s *Server) orgMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user")
+		orgID := c.GetHeader(orgHeader)
+		if orgID == "" {
+			orgID = userID
+		}
+
+		membership, err := s.Orgs.Membership(c.Request.Context(), orgID, userID)
+		if errors.Is(err, ErrNotFound) && orgID == userID {
+			membership = &Membership{OrgID: orgID, UserID: userID, Role: roleOwner}
+			err = s.Orgs.EnsurePersonal(c.Request.Context(), userID)
+		}
+		if errors.Is(err, ErrNotFound) {
+			respondError(c, errs.New(errs.ErrForbidden, "Not a member of this organization"))
+			return
+		}
+		if err != nil {
+			respondError(c, errs.New(errs.ErrInternal, "Error checking membership"))
+			return
+		}
+
+		c.Set("org", orgID)
+		c.Set("role", membership.Role)
+		c.Next()
+	}
+}
+
+// requireRole rejects members below role with 403
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(c.GetString("role"), role) {
+			respondError(c, errs.New(errs.ErrForbidden, "Requires the "+role+" role"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeRole lets every member read and requires managers for writes
+func authorizeRole() gin.HandlerFunc {
+	write := requireRole(roleManager)
+	return func(c *gin.Context) {
+		if isWrite(c.Request.Method) {
+			write(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) createOrganization(c *gin.Context) {
+	var input OrganizationInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	org := Organization{Name: input.Name, CreatedAt: time.Now().UTC()}
+	if err := s.Orgs.CreateOrganization(c.Request.Context(), &org, c.GetString("user")); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to create organization"))
+		return
+	}
+	org.Role = roleOwner
+	c.JSON(http.StatusCreated, org)
+}
+
+// getOrganizations lists the organizations of the user, the personal one included
+func (s *Server) getOrganizations(c *gin.Context) {
+	userID := c.GetString("user")
+	if err := s.Orgs.EnsurePersonal(c.Request.Context(), userID); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching organizations"))
+		return
+	}
+	orgs, err := s.Orgs.Organizations(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching organizations"))
+		return
+	}
+	c.JSON(http.StatusOK, orgs)
+}
+
+func (s *Server) getInvitations(c *gin.Context) {
+	invitations, err := s.Orgs.Invitations(c.Request.Context(), c.GetString("user"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching invitations"))
+		return
+	}
+	c.JSON(http.StatusOK, invitations)
+}
+
+// acceptInvitation joins the organization of an invitation addressed to the user.
+// Invitations of other users are reported as not found.
+func (s *Server) acceptInvitation(c *gin.Context) {
+	invitation, err := s.Orgs.FindInvitation(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, ErrNotFound) || err == nil && invitation.UserID != c.GetString("user") {
+		respondError(c, errs.New(errs.ErrNotFound, "Invitation not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching invitation"))
+		return
+	}
+
+	err = s.Orgs.AcceptInvitation(c.Request.Context(), *invitation)
+	if errors.Is(err, ErrAlreadyMember) {
+		respondError(c, errs.New(errs.ErrConflict, "Already a member of this organization"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to accept invitation"))
+		return
+	}
+	c.JSON(http.StatusOK, Membership{OrgID: invitation.OrgID, UserID: invitation.UserID, Role: invitation.Role})
+}
+
+func (s *Server) getMembers(c *gin.Context) {
+	members, err := s.Orgs.Members(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching members"))
+		return
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// inviteMember invites a user by username to the organization of the request
+func (s *Server) inviteMember(c *gin.Context) {
+	var input InvitationInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	orgID := c.GetString("org")
+	user, err := s.Users.FindByUsername(ctx, input.Username)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrNotFound, "User not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching user"))
+		return
+	}
+	_, err = s.Orgs.Membership(ctx, orgID, user.ID)
+	if err == nil {
+		respondError(c, errs.New(errs.ErrConflict, "User is already a member of this organization"))
+		return
+	}
+	if !errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrInternal, "Error checking membership"))
+		return
+	}
+	org, err := s.Orgs.FindOrganization(ctx, orgID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching organization"))
+		return
+	}
+
+	now := time.Now().UTC()
+	invitation := Invitation{
+		OrgID:     orgID,
+		OrgName:   org.Name,
+		UserID:    user.ID,
+		Role:      input.Role,
+		InvitedBy: c.GetString("user"),
+		CreatedAt: now,
+		ExpiresAt: now.Add(invitationTTL),
+	}
+	if err := s.Orgs.CreateInvitation(ctx, &invitation); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to create invitation"))
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// updateMember changes the role of a member, the last owner can't step down
+func (s *Server) updateMember(c *gin.Context) {
+	var input RoleInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	member, ok := s.changeableMember(c, input.Role != roleOwner)
+	if !ok {
+		return
+	}
+
+	if err := s.Orgs.SetRole(c.Request.Context(), member.OrgID, member.UserID, input.Role); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to update member"))
+		return
+	}
+	member.Role = input.Role
+	c.JSON(http.StatusOK, member)
+}
+
+// removeMember removes a member from the organization, but not the last owner
+func (s *Server) removeMember(c *gin.Context) {
+	member, ok := s.changeableMember(c, true)
+	if !ok {
+		return
+	}
+
+	if err := s.Orgs.RemoveMember(c.Request.Context(), member.OrgID, member.UserID); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to remove member"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully!"})
+}
+
+// changeableMember loads the member in the path. When demoting is set it checks that
+// the organization keeps another owner. It writes the error response and returns
+// false otherwise.
+func (s *Server) changeableMember(c *gin.Context, demoting bool) (*Membership, bool) {
+	members, err := s.Orgs.Members(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching members"))
+		return nil, false
+	}
+
+	var member *Membership
+	owners := 0
+	for i := range members {
+		if members[i].UserID == c.Param("userID") {
+			member = &members[i]
+		}
+		if members[i].Role == roleOwner {
+			owners++
+		}
+	}
+	if member == nil {
+		respondError(c, errs.New(errs.ErrNotFound, "Member not found"))
+		return nil, false
+	}
+	if demoting && member.Role == roleOwner && owners == 1 {
+		respondError(c, errs.New(errs.ErrConflict, "An organization needs an owner"))
+		return nil, false
+	}
+	return member, true
+}
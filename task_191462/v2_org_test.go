@@ -0,0 +1,293 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOrganizations(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	users := map[string]*User{}
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		users[name] = &User{Username: name}
+		s.Users.Create(context.Background(), users[name])
+	}
+	do := func(user, org, method, path, body string) *httptest.ResponseRecorder {
+		token, _, _ := s.generateToken(users[user].ID)
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if org != "" {
+			req.Header.Set(orgHeader, org)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var acme Organization
+	w := do("alice", "", "POST", "/auth/orgs", `{"name":"Acme"}`)
+	if w.Code != 201 || json.Unmarshal(w.Body.Bytes(), &acme) != nil || acme.Role != roleOwner {
+		t.Fatalf("Expected the organization to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	invitations := map[string]Invitation{}
+	for user, role := range map[string]string{"bob": roleViewer, "carol": roleManager} {
+		var invitation Invitation
+		w := do("alice", acme.ID, "POST", "/auth/org/invitations", `{"username":"`+user+`","role":"`+role+`"}`)
+		if w.Code != 201 || json.Unmarshal(w.Body.Bytes(), &invitation) != nil || invitation.OrgName != "Acme" {
+			t.Fatalf("Expected %s to be invited, got %d: %s", user, w.Code, w.Body.String())
+		}
+		invitations[user] = invitation
+	}
+
+	tests := []struct {
+		name           string
+		user           string
+		org            string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Owner Creates Product",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Widget","units":5,"price":2.5}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Non Member Is Forbidden",
+			user:           "bob",
+			org:            acme.ID,
+			method:         "GET",
+			path:           "/auth/allProducts",
+			expectedStatus: 403,
+		},
+		{
+			name:           "Unknown Organization",
+			user:           "bob",
+			org:            "64b7f0c2e13f4a2d9c8b4567",
+			method:         "GET",
+			path:           "/auth/allProducts",
+			expectedStatus: 403,
+		},
+		{
+			name:           "Inviting An Unknown User",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/org/invitations",
+			body:           `{"username":"nobody","role":"viewer"}`,
+			expectedStatus: 404,
+		},
+		{
+			name:           "Inviting With An Unknown Role",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/org/invitations",
+			body:           `{"username":"dave","role":"admin"}`,
+			expectedStatus: 422,
+		},
+		{
+			name:           "Accepting Another User's Invitation",
+			user:           "carol",
+			method:         "POST",
+			path:           "/auth/invitations/" + invitations["bob"].ID + "/accept",
+			expectedStatus: 404,
+		},
+		{
+			name:           "Viewer Accepts",
+			user:           "bob",
+			method:         "POST",
+			path:           "/auth/invitations/" + invitations["bob"].ID + "/accept",
+			expectedStatus: 200,
+		},
+		{
+			name:           "Accepting Twice",
+			user:           "bob",
+			method:         "POST",
+			path:           "/auth/invitations/" + invitations["bob"].ID + "/accept",
+			expectedStatus: 404,
+		},
+		{
+			name:           "Inviting A Member",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/org/invitations",
+			body:           `{"username":"bob","role":"manager"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Viewer Reads Products",
+			user:           "bob",
+			org:            acme.ID,
+			method:         "GET",
+			path:           "/auth/allProducts",
+			expectedStatus: 200,
+		},
+		{
+			name:           "Viewer Can't Create Products",
+			user:           "bob",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":3}`,
+			expectedStatus: 403,
+		},
+		{
+			name:           "Viewer Writes To The Personal Inventory",
+			user:           "bob",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":3}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Manager Accepts",
+			user:           "carol",
+			method:         "POST",
+			path:           "/auth/invitations/" + invitations["carol"].ID + "/accept",
+			expectedStatus: 200,
+		},
+		{
+			name:           "Manager Creates Products",
+			user:           "carol",
+			org:            acme.ID,
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Sprocket","units":2,"price":4}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Manager Can't Change Roles",
+			user:           "carol",
+			org:            acme.ID,
+			method:         "PUT",
+			path:           "/auth/org/members/" + users["bob"].ID,
+			body:           `{"role":"manager"}`,
+			expectedStatus: 403,
+		},
+		{
+			name:           "Last Owner Can't Step Down",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "PUT",
+			path:           "/auth/org/members/" + users["alice"].ID,
+			body:           `{"role":"manager"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Owner Promotes Viewer",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "PUT",
+			path:           "/auth/org/members/" + users["bob"].ID,
+			body:           `{"role":"owner"}`,
+			expectedStatus: 200,
+		},
+		{
+			name:           "Owner Steps Down Leaving Another Owner",
+			user:           "alice",
+			org:            acme.ID,
+			method:         "PUT",
+			path:           "/auth/org/members/" + users["alice"].ID,
+			body:           `{"role":"manager"}`,
+			expectedStatus: 200,
+		},
+		{
+			name:           "New Owner Removes Manager",
+			user:           "bob",
+			org:            acme.ID,
+			method:         "DELETE",
+			path:           "/auth/org/members/" + users["carol"].ID,
+			expectedStatus: 200,
+		},
+		{
+			name:           "Removed Member Is Forbidden",
+			user:           "carol",
+			org:            acme.ID,
+			method:         "GET",
+			path:           "/auth/allProducts",
+			expectedStatus: 403,
+		},
+		{
+			name:           "Removing An Unknown Member",
+			user:           "bob",
+			org:            acme.ID,
+			method:         "DELETE",
+			path:           "/auth/org/members/" + users["dave"].ID,
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.user, tt.org, tt.method, tt.path, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	listed := func(user, org string) []string {
+		var page struct {
+			Items []InventoryItem `json:"items"`
+		}
+		json.Unmarshal(do(user, org, "GET", "/auth/allProducts", "").Body.Bytes(), &page)
+		names := []string{}
+		for _, item := range page.Items {
+			names = append(names, item.ProductName)
+		}
+		return names
+	}
+	if names := listed("bob", acme.ID); !reflect.DeepEqual(names, []string{"Sprocket", "Widget"}) {
+		t.Errorf("Expected the shared inventory [Sprocket Widget], got %v", names)
+	}
+	if names := listed("bob", ""); !reflect.DeepEqual(names, []string{"Gadget"}) {
+		t.Errorf("Expected the personal inventory [Gadget], got %v", names)
+	}
+
+	var orgs []Organization
+	json.Unmarshal(do("bob", "", "GET", "/auth/orgs", "").Body.Bytes(), &orgs)
+	roles := map[string]string{}
+	for _, org := range orgs {
+		roles[org.ID] = org.Role
+	}
+	if expected := map[string]string{acme.ID: roleOwner, users["bob"].ID: roleOwner}; !reflect.DeepEqual(roles, expected) {
+		t.Errorf("Expected roles %v, got %v", expected, roles)
+	}
+}
+
+func TestInvitationExpiry(t *testing.T) {
+	orgs := NewMemoryOrganizationRepository()
+	invitation := Invitation{OrgID: "org", UserID: "bob", Role: roleViewer, ExpiresAt: orgs.now().Add(invitationTTL)}
+	orgs.CreateInvitation(context.Background(), &invitation)
+
+	orgs.now = func() time.Time { return invitation.ExpiresAt }
+	if _, err := orgs.FindInvitation(context.Background(), invitation.ID); err != ErrNotFound {
+		t.Errorf("Expected an expired invitation to be not found, got %v", err)
+	}
+	if pending, _ := orgs.Invitations(context.Background(), "bob"); len(pending) != 0 {
+		t.Errorf("Expected no pending invitations, got %+v", pending)
+	}
+}
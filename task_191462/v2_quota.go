@@ -0,0 +1,82 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"awesomeProject/platform/config"
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDailyWriteQuota = 1000
+
+// QuotaStore counts the writes of a user per UTC day
+type QuotaStore interface {
+	// Increment adds one write to the user's counter of the day and returns the new
+	// count. The counter can be dropped after expiresAt.
+	Increment(ctx context.Context, userID, day string, expiresAt time.Time) (int, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+// dailyWriteQuota reads the daily.write.quota setting, 0 disables the quota
+func dailyWriteQuota(cfg *config.Config) int {
+	quota := cfg.Int("daily.write.quota", defaultDailyWriteQuota)
+	if quota < 0 {
+		cfg.Check("daily.write.quota", errors.New("must not be negative"))
+		return defaultDailyWriteQuota
+	}
+	return quota
+}
+
+// writeQuota limits the write requests of every user to DailyWriteQuota per UTC day.
+// Reads are not counted, every write attempt is, whether it succeeds or not. The
+// X-Quota-* headers report the limit, the writes left and when the quota resets.
+// The quota fails open: when the counter can't be updated the request goes through.
+func (s *Server) writeQuota() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.DailyWriteQuota <= 0 || s.Quotas == nil || !isWrite(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		now := time.Now().UTC()
+		reset := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+		count, err := s.Quotas.Increment(c.Request.Context(), c.GetString("user"), now.Format("2006-01-02"), reset)
+		if err != nil {
+			log.Println("Error updating write quota:", err)
+			c.Next()
+			return
+		}
+
+		remaining := s.DailyWriteQuota - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		c.Header("X-Quota-Limit", strconv.Itoa(s.DailyWriteQuota))
+		c.Header("X-Quota-Remaining", strconv.Itoa(remaining))
+		c.Header("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if count > s.DailyWriteQuota {
+			c.Header("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+			respondError(c, errs.New(errs.ErrTooManyRequests, "Daily write quota exceeded"))
+			return
+		}
+		c.Next()
+	}
+}
+
+func isWrite(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
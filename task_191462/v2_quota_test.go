@@ -0,0 +1,110 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWriteQuota(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	s.DailyWriteQuota = 2
+	r := gin.New()
+	s.setupRoutes(r)
+
+	tokens := map[string]string{}
+	for _, user := range []string{"busy", "quiet"} {
+		tokens[user], _, _ = s.generateToken(user)
+	}
+
+	tests := []struct {
+		name              string
+		user              string
+		method            string
+		path              string
+		expectedStatus    int
+		expectedRemaining string
+	}{
+		{
+			name:              "First Write",
+			user:              "busy",
+			method:            "POST",
+			path:              "/auth/warehouses",
+			expectedStatus:    422,
+			expectedRemaining: "1",
+		},
+		{
+			name:              "Reads Are Not Counted",
+			user:              "busy",
+			method:            "GET",
+			path:              "/auth/warehouses",
+			expectedStatus:    200,
+			expectedRemaining: "",
+		},
+		{
+			name:              "Last Write Of The Day",
+			user:              "busy",
+			method:            "POST",
+			path:              "/auth/warehouses",
+			expectedStatus:    422,
+			expectedRemaining: "0",
+		},
+		{
+			name:              "Quota Exceeded",
+			user:              "busy",
+			method:            "POST",
+			path:              "/auth/warehouses",
+			expectedStatus:    429,
+			expectedRemaining: "0",
+		},
+		{
+			name:              "Reads Still Allowed",
+			user:              "busy",
+			method:            "GET",
+			path:              "/auth/warehouses",
+			expectedStatus:    200,
+			expectedRemaining: "",
+		},
+		{
+			name:              "Other Users Have Their Own Quota",
+			user:              "quiet",
+			method:            "POST",
+			path:              "/auth/warehouses",
+			expectedStatus:    422,
+			expectedRemaining: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.user])
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("X-Quota-Remaining"); got != tt.expectedRemaining {
+				t.Errorf("Expected %q writes remaining, got %q", tt.expectedRemaining, got)
+			}
+			if tt.expectedStatus == 429 {
+				reset, _ := strconv.ParseInt(w.Header().Get("X-Quota-Reset"), 10, 64)
+				retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+				if reset <= time.Now().Unix() || retryAfter <= 0 || retryAfter > 86400 {
+					t.Errorf("Expected reset at the next UTC midnight, got reset %d and Retry-After %d", reset, retryAfter)
+				}
+			}
+		})
+	}
+}
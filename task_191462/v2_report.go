@@ -0,0 +1,432 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Costing methods of the reports. FIFO takes stock out at the cost of the oldest units
+// still held, average at the weighted average cost of all units held.
+const (
+	costFIFO    = "fifo"
+	costAverage = "average"
+)
+
+const (
+	defaultReportDays = 30
+	defaultTopMoving  = 10
+	maxTopMoving      = 100
+)
+
+// SaleInput records a sale, or the consumption of stock for internal use. Sales
+// default to the product's price.
+type SaleInput struct {
+	Quantity    int     `json:"quantity" binding:"gt=0"`
+	UnitPrice   float64 `json:"unitPrice" binding:"gte=0"`
+	Reason      string  `json:"reason" binding:"omitempty,oneof=sale consumption"`
+	WarehouseID string  `json:"warehouseID"`
+	Note        string  `json:"note" binding:"max=500"`
+}
+
+type ValuationItem struct {
+	ProductID   string  `json:"productID"`
+	ProductName string  `json:"productName"`
+	Units       int     `json:"units"`
+	UnitCost    float64 `json:"unitCost"`
+	Value       float64 `json:"value"`
+}
+
+// ValuationReport values the stock held at a point in time
+type ValuationReport struct {
+	Method string          `json:"method"`
+	At     time.Time       `json:"at"`
+	Value  float64         `json:"value"`
+	Items  []ValuationItem `json:"items"`
+}
+
+// TurnoverItem relates the cost of the stock taken out in a period to the average
+// value of the stock held. Turnover is 0 when no stock was held.
+type TurnoverItem struct {
+	ProductID        string  `json:"productID,omitempty"`
+	ProductName      string  `json:"productName,omitempty"`
+	UnitsOut         int     `json:"unitsOut"`
+	CostOfGoodsSold  float64 `json:"costOfGoodsSold"`
+	OpeningValue     float64 `json:"openingValue"`
+	ClosingValue     float64 `json:"closingValue"`
+	AverageInventory float64 `json:"averageInventory"`
+	Turnover         float64 `json:"turnover"`
+}
+
+type TurnoverReport struct {
+	Method string    `json:"method"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	TurnoverItem
+	Items []TurnoverItem `json:"items"`
+}
+
+// MovingItem sums the sales and consumption of a product in a period
+type MovingItem struct {
+	ProductID   string  `json:"productID" bson:"_id"`
+	ProductName string  `json:"productName" bson:"productName"`
+	UnitsOut    int     `json:"unitsOut" bson:"unitsOut"`
+	UnitsSold   int     `json:"unitsSold" bson:"unitsSold"`
+	Revenue     float64 `json:"revenue" bson:"revenue"`
+}
+
+// recordSale takes sold or consumed units out of stock
+func (s *Server) recordSale(c *gin.Context) {
+	var input SaleInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+	if input.WarehouseID != "" {
+		if _, ok := s.ownedWarehouse(c, input.WarehouseID); !ok {
+			return
+		}
+	}
+
+	reason := input.Reason
+	if reason == "" {
+		reason = ReasonSale
+	}
+	s.saveMovement(c, product, StockMovement{
+		WarehouseID: input.WarehouseID,
+		Quantity:    -input.Quantity,
+		Reason:      reason,
+		UnitPrice:   input.UnitPrice,
+		Note:        input.Note,
+	})
+}
+
+type costLayer struct {
+	units int
+	cost  float64
+}
+
+// costTracker follows the units and the value of a product's stock
+type costTracker struct {
+	fifo   bool
+	layers []costLayer
+	units  int
+	value  float64
+}
+
+func (t *costTracker) add(units int, cost float64) {
+	t.units += units
+	t.value += float64(units) * cost
+	if t.fifo {
+		t.layers = append(t.layers, costLayer{units: units, cost: cost})
+	}
+}
+
+// remove takes units out of stock and returns their cost
+func (t *costTracker) remove(units int) float64 {
+	units = min(units, t.units)
+	var cost float64
+	if t.fifo {
+		for remaining := units; remaining > 0; {
+			layer := &t.layers[0]
+			n := min(remaining, layer.units)
+			cost += float64(n) * layer.cost
+			layer.units -= n
+			remaining -= n
+			if layer.units == 0 {
+				t.layers = t.layers[1:]
+			}
+		}
+	} else if t.units > 0 {
+		cost = t.value * float64(units) / float64(t.units)
+	}
+
+	t.units -= units
+	t.value -= cost
+	if t.units == 0 {
+		t.value = 0
+	}
+	return cost
+}
+
+func (t *costTracker) unitCost() float64 {
+	if t.units == 0 {
+		return 0
+	}
+	return t.value / float64(t.units)
+}
+
+// replay runs the opening stock of a product and its movements before until through a
+// cost tracker. The opening stock is what the product held before its first movement,
+// valued at its unit cost like stock in without a cost of its own. taken is called
+// with the cost of every movement taking stock out.
+func replay(product InventoryItem, movements []StockMovement, method string, until time.Time, taken func(StockMovement, float64)) *costTracker {
+	t := &costTracker{fifo: method == costFIFO}
+	if id, err := primitive.ObjectIDFromHex(product.ID); err == nil && !id.Timestamp().Before(until) {
+		return t
+	}
+
+	opening := product.Units
+	for _, movement := range movements {
+		opening -= movement.Quantity
+	}
+	if opening > 0 {
+		t.add(opening, product.UnitCost)
+	}
+
+	for _, movement := range movements {
+		if !movement.CreatedAt.Before(until) {
+			break
+		}
+		if movement.Quantity > 0 {
+			cost := movement.UnitCost
+			if cost == 0 {
+				cost = product.UnitCost
+			}
+			t.add(movement.Quantity, cost)
+			continue
+		}
+		cost := t.remove(-movement.Quantity)
+		if taken != nil {
+			taken(movement, cost)
+		}
+	}
+	return t
+}
+
+// eachHistory calls fn for every product of the organization with its movements, oldest
+// first. Only the movements of one product are held at a time.
+func (s *Server) eachHistory(ctx context.Context, orgID string, fn func(InventoryItem, []StockMovement)) error {
+	products := make(map[string]InventoryItem)
+	err := s.Inventory.Each(ctx, orgID, func(item InventoryItem) error {
+		products[item.ID] = item
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var productID string
+	var movements []StockMovement
+	flush := func() {
+		if product, ok := products[productID]; ok {
+			fn(product, movements)
+			delete(products, productID)
+		}
+	}
+	err = s.Inventory.Ledger(ctx, orgID, func(movement StockMovement) error {
+		if movement.ProductID != productID {
+			flush()
+			productID, movements = movement.ProductID, nil
+		}
+		movements = append(movements, movement)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flush()
+
+	for _, product := range products {
+		fn(product, nil)
+	}
+	return nil
+}
+
+// getValuation values the stock held at the time in the at query parameter, now by
+// default, with the costing method in method
+func (s *Server) getValuation(c *gin.Context) {
+	method, ok := costMethod(c)
+	if !ok {
+		return
+	}
+	at := time.Now().UTC()
+	if v := c.Query("at"); v != "" {
+		var err error
+		if at, err = parseReportTime(v, true); err != nil {
+			respondError(c, errs.New(errs.ErrInvalid, "at must be a date or an RFC 3339 time"))
+			return
+		}
+	}
+
+	report := ValuationReport{Method: method, At: at, Items: []ValuationItem{}}
+	err := s.eachHistory(c.Request.Context(), c.GetString("org"), func(product InventoryItem, movements []StockMovement) {
+		t := replay(product, movements, method, at, nil)
+		if t.units == 0 {
+			return
+		}
+		report.Value += t.value
+		report.Items = append(report.Items, ValuationItem{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Units:       t.units,
+			UnitCost:    roundMoney(t.unitCost()),
+			Value:       roundMoney(t.value),
+		})
+	})
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error computing valuation"))
+		return
+	}
+
+	report.Value = roundMoney(report.Value)
+	sort.Slice(report.Items, func(i, j int) bool { return report.Items[i].ProductName < report.Items[j].ProductName })
+	c.JSON(http.StatusOK, report)
+}
+
+// getTurnover computes the inventory turnover of the period between from and to, the
+// cost of the stock taken out divided by the average of the opening and closing value
+func (s *Server) getTurnover(c *gin.Context) {
+	method, ok := costMethod(c)
+	if !ok {
+		return
+	}
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+
+	report := TurnoverReport{Method: method, From: from, To: to, Items: []TurnoverItem{}}
+	err := s.eachHistory(c.Request.Context(), c.GetString("org"), func(product InventoryItem, movements []StockMovement) {
+		item := TurnoverItem{ProductID: product.ID, ProductName: product.ProductName}
+		item.OpeningValue = replay(product, movements, method, from, nil).value
+		item.ClosingValue = replay(product, movements, method, to, func(movement StockMovement, cost float64) {
+			if !movement.CreatedAt.Before(from) && movement.Reason != ReasonCorrection && movement.Reason != ReasonDamage {
+				item.UnitsOut -= movement.Quantity
+				item.CostOfGoodsSold += cost
+			}
+		}).value
+		if item.UnitsOut == 0 && item.OpeningValue == 0 && item.ClosingValue == 0 {
+			return
+		}
+
+		report.UnitsOut += item.UnitsOut
+		report.CostOfGoodsSold += item.CostOfGoodsSold
+		report.OpeningValue += item.OpeningValue
+		report.ClosingValue += item.ClosingValue
+		report.Items = append(report.Items, item.rounded())
+	})
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error computing turnover"))
+		return
+	}
+
+	report.TurnoverItem = report.TurnoverItem.rounded()
+	sort.Slice(report.Items, func(i, j int) bool {
+		a, b := report.Items[i], report.Items[j]
+		if a.Turnover != b.Turnover {
+			return a.Turnover > b.Turnover
+		}
+		return a.ProductName < b.ProductName
+	})
+	c.JSON(http.StatusOK, report)
+}
+
+// rounded computes the average inventory and the turnover and rounds the amounts
+func (item TurnoverItem) rounded() TurnoverItem {
+	item.AverageInventory = (item.OpeningValue + item.ClosingValue) / 2
+	if item.AverageInventory > 0 {
+		item.Turnover = math.Round(item.CostOfGoodsSold/item.AverageInventory*100) / 100
+	}
+	item.CostOfGoodsSold = roundMoney(item.CostOfGoodsSold)
+	item.OpeningValue = roundMoney(item.OpeningValue)
+	item.ClosingValue = roundMoney(item.ClosingValue)
+	item.AverageInventory = roundMoney(item.AverageInventory)
+	return item
+}
+
+// getTopMoving lists the products with the most units sold or consumed between from
+// and to, limit of them
+func (s *Server) getTopMoving(c *gin.Context) {
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+	limit := defaultTopMoving
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTopMoving {
+			respondError(c, errs.New(errs.ErrInvalid, "limit must be between 1 and "+strconv.Itoa(maxTopMoving)))
+			return
+		}
+		limit = n
+	}
+
+	items, err := s.Inventory.TopMoving(c.Request.Context(), c.GetString("org"), from, to, limit)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error computing top moving products"))
+		return
+	}
+	for i := range items {
+		items[i].Revenue = roundMoney(items[i].Revenue)
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "items": items})
+}
+
+func costMethod(c *gin.Context) (string, bool) {
+	method := c.DefaultQuery("method", costFIFO)
+	if method != costFIFO && method != costAverage {
+		respondError(c, errs.New(errs.ErrInvalid, "method must be fifo or average"))
+		return "", false
+	}
+	return method, true
+}
+
+// reportPeriod reads from and to, the last 30 days by default. A date as to includes
+// the whole day.
+func reportPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		var err error
+		if to, err = parseReportTime(v, true); err != nil {
+			respondError(c, errs.New(errs.ErrInvalid, "to must be a date or an RFC 3339 time"))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	from := to.AddDate(0, 0, -defaultReportDays)
+	if v := c.Query("from"); v != "" {
+		var err error
+		if from, err = parseReportTime(v, false); err != nil {
+			respondError(c, errs.New(errs.ErrInvalid, "from must be a date or an RFC 3339 time"))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if !from.Before(to) {
+		respondError(c, errs.New(errs.ErrInvalid, "from must be before to"))
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// parseReportTime accepts RFC 3339 times and dates in UTC, endOfDay moves a date to
+// the start of the next day
+func parseReportTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,136 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCostTracker(t *testing.T) {
+	tests := []struct {
+		name          string
+		fifo          bool
+		expectedCost  float64
+		expectedValue float64
+	}{
+		{name: "FIFO Takes The Oldest Units", fifo: true, expectedCost: 10*2 + 5*4, expectedValue: 5 * 4},
+		{name: "Average Cost", fifo: false, expectedCost: 15 * 3, expectedValue: 5 * 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &costTracker{fifo: tt.fifo}
+			tracker.add(10, 2)
+			tracker.add(10, 4)
+			if cost := tracker.remove(15); cost != tt.expectedCost {
+				t.Errorf("Expected cost %v, got %v", tt.expectedCost, cost)
+			}
+			if tracker.units != 5 || tracker.value != tt.expectedValue {
+				t.Errorf("Expected 5 units worth %v, got %d worth %v", tt.expectedValue, tracker.units, tracker.value)
+			}
+		})
+	}
+}
+
+func TestReports(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	shirt := InventoryItem{OrgID: "owner", ProductName: "Shirt", Units: 10, Price: 10, UnitCost: 2}
+	mug := InventoryItem{OrgID: "owner", ProductName: "Mug", Units: 4, Price: 5, UnitCost: 1}
+	for _, item := range []*InventoryItem{&shirt, &mug} {
+		s.Inventory.Create(context.Background(), item)
+	}
+
+	token, _, _ := s.generateToken("owner")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tt := range []struct {
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"/auth/products/" + shirt.ID + "/movements", `{"quantity":10,"reason":"purchase","unitCost":4}`, 201},
+		{"/auth/products/" + shirt.ID + "/sales", `{"quantity":15}`, 201},
+		{"/auth/products/" + mug.ID + "/sales", `{"quantity":3,"reason":"consumption"}`, 201},
+		{"/auth/products/" + mug.ID + "/sales", `{"quantity":2}`, 409},
+		{"/auth/products/" + mug.ID + "/sales", `{"quantity":1,"reason":"gift"}`, 422},
+	} {
+		if w := do("POST", tt.path, tt.body); w.Code != tt.expectedStatus {
+			t.Fatalf("Expected status %d for %s, got %d: %s", tt.expectedStatus, tt.body, w.Code, w.Body.String())
+		}
+	}
+
+	period := "from=" + time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly) + "&to=" + time.Now().UTC().Format(time.DateOnly)
+	tests := []struct {
+		name              string
+		method            string
+		expectedValue     float64
+		expectedCOGS      float64
+		expectedTurnover  float64
+		expectedShirtCost float64
+	}{
+		{name: "FIFO", method: "fifo", expectedValue: 5*4 + 1*1, expectedCOGS: 40 + 3, expectedTurnover: 4, expectedShirtCost: 4},
+		{name: "Average", method: "average", expectedValue: 5*3 + 1*1, expectedCOGS: 45 + 3, expectedTurnover: 6, expectedShirtCost: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var valuation ValuationReport
+			json.Unmarshal(do("GET", "/auth/reports/valuation?method="+tt.method, "").Body.Bytes(), &valuation)
+			if valuation.Value != tt.expectedValue || len(valuation.Items) != 2 ||
+				valuation.Items[1].ProductName != "Shirt" || valuation.Items[1].UnitCost != tt.expectedShirtCost {
+				t.Errorf("Unexpected valuation: %+v", valuation)
+			}
+
+			var turnover TurnoverReport
+			json.Unmarshal(do("GET", "/auth/reports/turnover?method="+tt.method+"&"+period, "").Body.Bytes(), &turnover)
+			if turnover.CostOfGoodsSold != tt.expectedCOGS || turnover.UnitsOut != 18 || len(turnover.Items) != 2 {
+				t.Fatalf("Unexpected turnover: %+v", turnover)
+			}
+			for _, item := range turnover.Items {
+				if item.ProductName == "Shirt" && item.Turnover != tt.expectedTurnover {
+					t.Errorf("Expected a shirt turnover of %v, got %+v", tt.expectedTurnover, item)
+				}
+			}
+		})
+	}
+
+	var past ValuationReport
+	json.Unmarshal(do("GET", "/auth/reports/valuation?at=2020-01-01", "").Body.Bytes(), &past)
+	if past.Value != 0 || len(past.Items) != 0 {
+		t.Errorf("Expected no stock before the products were created, got %+v", past)
+	}
+
+	var top struct {
+		Items []MovingItem `json:"items"`
+	}
+	json.Unmarshal(do("GET", "/auth/reports/top-moving?limit=1&"+period, "").Body.Bytes(), &top)
+	if len(top.Items) != 1 || top.Items[0].ProductName != "Shirt" || top.Items[0].UnitsOut != 15 || top.Items[0].Revenue != 150 {
+		t.Errorf("Unexpected top moving products: %+v", top.Items)
+	}
+
+	for _, query := range []string{"method=lifo", "from=2024-02-01&to=2024-01-01", "from=yesterday"} {
+		if w := do("GET", "/auth/reports/turnover?"+query, ""); w.Code != 400 {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,1075 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// InventoryRepository stores products and their stock movements. IDs are hex
+// ObjectIDs in every implementation.
+type InventoryRepository interface {
+	// Create stores a new product and sets its ID
+	Create(ctx context.Context, item *InventoryItem) error
+	// FindByID returns ErrNotFound for unknown products, it doesn't check the owner
+	FindByID(ctx context.Context, id string) (*InventoryItem, error)
+	// FindByName returns the organization's product named name, or ErrNotFound
+	FindByName(ctx context.Context, orgID, name string) (*InventoryItem, error)
+	// FindBySKU returns the organization's product with the SKU, or ErrNotFound
+	FindBySKU(ctx context.Context, orgID, sku string) (*InventoryItem, error)
+	// FindByBarcode returns the organization's product with the barcode, or ErrNotFound
+	FindByBarcode(ctx context.Context, orgID, barcode string) (*InventoryItem, error)
+	// NameExists reports whether the organization has a product named name, other than excludeID
+	NameExists(ctx context.Context, orgID, name, excludeID string) (bool, error)
+	// Update sets the name, codes, price, cost, reorder level, category, tags and attributes
+	// of a product, but not its units
+	Update(ctx context.Context, item InventoryItem) (*InventoryItem, error)
+	Delete(ctx context.Context, orgID, id string) error
+	// List returns a page of the organization's products and the total number of matches
+	List(ctx context.Context, orgID string, q listQuery) ([]InventoryItem, int64, error)
+	// Each calls fn for every product of the organization ordered by name, stopping at the
+	// first error. Products are streamed rather than loaded at once.
+	Each(ctx context.Context, orgID string, fn func(InventoryItem) error) error
+	// LowStock returns the products at or below their reorder level, of all organizations
+	// when orgID is empty
+	LowStock(ctx context.Context, orgID string) ([]InventoryItem, error)
+	// RecordMovement adjusts the product's units and appends the movement to the
+	// ledger atomically. Stock can't go below zero: ErrInsufficientStock.
+	RecordMovement(ctx context.Context, movement *StockMovement) error
+	// Movements lists the ledger of a product, newest first
+	Movements(ctx context.Context, orgID, productID string) ([]StockMovement, error)
+	// Ledger calls fn for every movement of the organization ordered by product and then oldest
+	// first, stopping at the first error
+	Ledger(ctx context.Context, orgID string, fn func(StockMovement) error) error
+	// TopMoving sums the sales and consumption per product created in [from, to) and
+	// returns the limit products with the most units out
+	TopMoving(ctx context.Context, orgID string, from, to time.Time, limit int) ([]MovingItem, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type UserRepository interface {
+	// FindByUsername returns ErrNotFound for unknown users
+	FindByUsername(ctx context.Context, username string) (*User, error)
+	// Create stores a new user and sets its ID
+	Create(ctx context.Context, user *User) error
+}
+
+// TokenDenylist holds the IDs of revoked tokens until they expire, after which the
+// token is rejected anyway
+type TokenDenylist interface {
+	// Revoke adds the token ID, it reports false if it was already revoked
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+// MongoInventoryRepository implements InventoryRepository, WarehouseRepository and
+// CategoryRepository, stock movements and transfers share transactions across the
+// collections
+type MongoInventoryRepository struct {
+	client     *mongo.Client
+	items      *mongo.Collection
+	movements  *mongo.Collection
+	warehouses *mongo.Collection
+	stock      *mongo.Collection
+	transfers  *mongo.Collection
+	categories *mongo.Collection
+}
+
+func (r *MongoInventoryRepository) Create(ctx context.Context, item *InventoryItem) error {
+	item.ID = ""
+	result, err := r.items.InsertOne(ctx, item)
+	if err != nil {
+		return err
+	}
+	item.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *MongoInventoryRepository) FindByID(ctx context.Context, id string) (*InventoryItem, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var item InventoryItem
+	err = r.items.FindOne(ctx, bson.M{"_id": objectId}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *MongoInventoryRepository) FindByName(ctx context.Context, orgID, name string) (*InventoryItem, error) {
+	var item InventoryItem
+	err := r.items.FindOne(ctx, bson.M{"orgID": orgID, "productName": name}).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *MongoInventoryRepository) FindBySKU(ctx context.Context, orgID, sku string) (*InventoryItem, error) {
+	return r.findOne(ctx, bson.M{"orgID": orgID, "sku": sku})
+}
+
+func (r *MongoInventoryRepository) FindByBarcode(ctx context.Context, orgID, barcode string) (*InventoryItem, error) {
+	return r.findOne(ctx, bson.M{"orgID": orgID, "barcode": barcode})
+}
+
+func (r *MongoInventoryRepository) findOne(ctx context.Context, filter bson.M) (*InventoryItem, error) {
+	var item InventoryItem
+	err := r.items.FindOne(ctx, filter).Decode(&item)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (r *MongoInventoryRepository) NameExists(ctx context.Context, orgID, name, excludeID string) (bool, error) {
+	filter := bson.M{"orgID": orgID, "productName": name}
+	if objectId, err := primitive.ObjectIDFromHex(excludeID); err == nil {
+		filter["_id"] = bson.M{"$ne": objectId}
+	}
+	count, err := r.items.CountDocuments(ctx, filter)
+	return count > 0, err
+}
+
+func (r *MongoInventoryRepository) Update(ctx context.Context, item InventoryItem) (*InventoryItem, error) {
+	objectId, err := primitive.ObjectIDFromHex(item.ID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	set, unset := bson.M{
+		"productName":  item.ProductName,
+		"price":        item.Price,
+		"unitCost":     item.UnitCost,
+		"reorderLevel": item.ReorderLevel,
+	}, bson.M{}
+	// the fields are omitted when empty, like on insert
+	for field, value := range map[string]interface{}{
+		"sku":        item.SKU,
+		"barcode":    item.Barcode,
+		"categoryID": item.CategoryID,
+		"tags":       item.Tags,
+		"attributes": item.Attributes,
+	} {
+		if reflect.ValueOf(value).IsZero() {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	var updated InventoryItem
+	err = r.items.FindOneAndUpdate(ctx, bson.M{"_id": objectId, "orgID": item.OrgID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (r *MongoInventoryRepository) Delete(ctx context.Context, orgID, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := r.items.DeleteOne(ctx, bson.M{"_id": objectId, "orgID": orgID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	_, err = r.stock.DeleteMany(ctx, bson.M{"productID": id, "orgID": orgID})
+	return err
+}
+
+func (r *MongoInventoryRepository) List(ctx context.Context, orgID string, q listQuery) ([]InventoryItem, int64, error) {
+	filter := q.filter(orgID)
+	total, err := r.items.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cursor, err := r.items.Find(ctx, filter, q.findOptions())
+	if err != nil {
+		return nil, 0, err
+	}
+	items := []InventoryItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (r *MongoInventoryRepository) Each(ctx context.Context, orgID string, fn func(InventoryItem) error) error {
+	cursor, err := r.items.Find(ctx, bson.M{"orgID": orgID}, options.Find().SetSort(bson.D{{Key: "productName", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item InventoryItem
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *MongoInventoryRepository) LowStock(ctx context.Context, orgID string) ([]InventoryItem, error) {
+	filter := bson.M{
+		"reorderLevel": bson.M{"$gt": 0},
+		"$expr":        bson.M{"$lte": bson.A{"$units", "$reorderLevel"}},
+	}
+	if orgID != "" {
+		filter["orgID"] = orgID
+	}
+
+	cursor, err := r.items.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "units", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	items := []InventoryItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// RecordMovement runs in a transaction, which needs MongoDB to run as a replica set
+func (r *MongoInventoryRepository) RecordMovement(ctx context.Context, movement *StockMovement) error {
+	objectId, err := primitive.ObjectIDFromHex(movement.ProductID)
+	if err != nil {
+		return ErrNotFound
+	}
+
+	session, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		filter := bson.M{"_id": objectId, "orgID": movement.OrgID}
+		if movement.Quantity < 0 {
+			// stock can't go below zero
+			filter["units"] = bson.M{"$gte": -movement.Quantity}
+		}
+
+		var updated InventoryItem
+		err := r.items.FindOneAndUpdate(sc, filter, bson.M{"$inc": bson.M{"units": movement.Quantity}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInsufficientStock
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if movement.WarehouseID != "" {
+			if err := r.adjustStock(sc, movement.OrgID, movement.ProductID, movement.WarehouseID, movement.Quantity); err != nil {
+				return nil, err
+			}
+		} else if movement.Quantity < 0 {
+			// stock held in warehouses has to be taken out of a warehouse
+			allocated, err := r.allocatedUnits(sc, movement.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			if updated.Units < allocated {
+				return nil, ErrInsufficientStock
+			}
+		}
+
+		movement.ID = ""
+		movement.UnitsAfter = updated.Units
+		result, err := r.movements.InsertOne(sc, movement)
+		if err != nil {
+			return nil, err
+		}
+		movement.ID = result.InsertedID.(primitive.ObjectID).Hex()
+		return nil, nil
+	})
+	return err
+}
+
+func (r *MongoInventoryRepository) Movements(ctx context.Context, orgID, productID string) ([]StockMovement, error) {
+	filter := bson.M{"productID": productID, "orgID": orgID}
+	cursor, err := r.movements.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	movements := []StockMovement{}
+	if err := cursor.All(ctx, &movements); err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+func (r *MongoInventoryRepository) Ledger(ctx context.Context, orgID string, fn func(StockMovement) error) error {
+	sort := bson.D{{Key: "productID", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+	cursor, err := r.movements.Find(ctx, bson.M{"orgID": orgID}, options.Find().SetSort(sort))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var movement StockMovement
+		if err := cursor.Decode(&movement); err != nil {
+			return err
+		}
+		if err := fn(movement); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *MongoInventoryRepository) TopMoving(ctx context.Context, orgID string, from, to time.Time, limit int) ([]MovingItem, error) {
+	unitsOut := bson.M{"$multiply": bson.A{"$quantity", -1}}
+	cursor, err := r.movements.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"orgID":     orgID,
+			"reason":    bson.M{"$in": bson.A{ReasonSale, ReasonConsumption}},
+			"createdAt": bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$productID",
+			"unitsOut": bson.M{"$sum": unitsOut},
+			"unitsSold": bson.M{"$sum": bson.M{
+				"$cond": bson.A{bson.M{"$eq": bson.A{"$reason", ReasonSale}}, unitsOut, 0},
+			}},
+			"revenue": bson.M{"$sum": bson.M{
+				"$multiply": bson.A{unitsOut, bson.M{"$ifNull": bson.A{"$unitPrice", 0}}},
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "unitsOut", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$addFields", Value: bson.M{"productObjectID": bson.M{"$toObjectId": "$_id"}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.items.Name(),
+			"localField":   "productObjectID",
+			"foreignField": "_id",
+			"as":           "product",
+		}}},
+		// deleted products are listed without a name
+		{{Key: "$unwind", Value: bson.M{"path": "$product", "preserveNullAndEmptyArrays": true}}},
+		{{Key: "$project", Value: bson.M{
+			"productName": "$product.productName",
+			"unitsOut":    1,
+			"unitsSold":   1,
+			"revenue":     1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "unitsOut", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := []MovingItem{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// EnsureIndexes migrates documents scoped by user to their personal organizations and
+// creates the indexes behind the listing: the text index for searches and
+// per-organization compound indexes for the sort orders and filters, the unique code
+// indexes used by lookups, and the ledger indexes
+func (r *MongoInventoryRepository) EnsureIndexes(ctx context.Context) error {
+	for _, collection := range []*mongo.Collection{r.items, r.movements, r.warehouses, r.stock, r.transfers, r.categories} {
+		if err := migrateToOrganizations(ctx, collection); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.items.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "productName", Value: "text"}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "productName", Value: 1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "price", Value: 1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "units", Value: 1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "categoryID", Value: 1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "tags", Value: 1}}},
+		// products without codes don't have the fields
+		{
+			Keys:    bson.D{{Key: "orgID", Value: 1}, {Key: "sku", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$exists": true}}),
+		},
+		{
+			Keys:    bson.D{{Key: "orgID", Value: 1}, {Key: "barcode", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"barcode": bson.M{"$exists": true}}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.movements.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "productID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// the ledger replayed by the reports and the period matched by TopMoving
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "productID", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "createdAt", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.warehouses.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "orgID", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.stock.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "productID", Value: 1}, {Key: "warehouseID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "warehouseID", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.categories.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "orgID", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// migrateToOrganizations renames the userID of documents written before organizations
+// to orgID, the personal organization of a user has the user's ID. The indexes on
+// userID are dropped, the unique ones would reject documents without it.
+func migrateToOrganizations(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"userID": bson.M{"$exists": true}, "orgID": bson.M{"$exists": false}},
+		bson.M{"$rename": bson.M{"userID": "orgID"}})
+	if err != nil {
+		return err
+	}
+
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return err
+	}
+	var indexes []struct {
+		Name string `bson:"name"`
+		Key  bson.D `bson:"key"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		for _, key := range index.Key {
+			if key.Key == "userID" {
+				if _, err := collection.Indexes().DropOne(ctx, index.Name); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *MongoInventoryRepository) CreateWarehouse(ctx context.Context, warehouse *Warehouse) error {
+	warehouse.ID = ""
+	result, err := r.warehouses.InsertOne(ctx, warehouse)
+	if err != nil {
+		return err
+	}
+	warehouse.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *MongoInventoryRepository) FindWarehouse(ctx context.Context, id string) (*Warehouse, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var warehouse Warehouse
+	err = r.warehouses.FindOne(ctx, bson.M{"_id": objectId}).Decode(&warehouse)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &warehouse, nil
+}
+
+func (r *MongoInventoryRepository) ListWarehouses(ctx context.Context, orgID string) ([]Warehouse, error) {
+	cursor, err := r.warehouses.Find(ctx, bson.M{"orgID": orgID}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	warehouses := []Warehouse{}
+	if err := cursor.All(ctx, &warehouses); err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+func (r *MongoInventoryRepository) WarehouseStock(ctx context.Context, orgID, warehouseID string) ([]StockLevel, error) {
+	filter := bson.M{"orgID": orgID, "warehouseID": warehouseID, "units": bson.M{"$gt": 0}}
+	cursor, err := r.stock.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "productID", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	levels := []StockLevel{}
+	if err := cursor.All(ctx, &levels); err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// Transfer runs in a transaction, which needs MongoDB to run as a replica set
+func (r *MongoInventoryRepository) Transfer(ctx context.Context, transfer *StockTransfer) error {
+	session, err := r.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		if err := r.adjustStock(sc, transfer.OrgID, transfer.ProductID, transfer.FromWarehouseID, -transfer.Quantity); err != nil {
+			return nil, err
+		}
+		if err := r.adjustStock(sc, transfer.OrgID, transfer.ProductID, transfer.ToWarehouseID, transfer.Quantity); err != nil {
+			return nil, err
+		}
+
+		transfer.ID = ""
+		result, err := r.transfers.InsertOne(sc, transfer)
+		if err != nil {
+			return nil, err
+		}
+		transfer.ID = result.InsertedID.(primitive.ObjectID).Hex()
+		return nil, nil
+	})
+	return err
+}
+
+// adjustStock changes the stock level of a product in a warehouse by delta, creating
+// the level on first use. Levels can't go below zero: ErrInsufficientStock.
+func (r *MongoInventoryRepository) adjustStock(ctx context.Context, orgID, productID, warehouseID string, delta int) error {
+	filter := bson.M{"productID": productID, "warehouseID": warehouseID}
+	if delta < 0 {
+		filter["units"] = bson.M{"$gte": -delta}
+		result, err := r.stock.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"units": delta}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInsufficientStock
+		}
+		return nil
+	}
+
+	update := bson.M{"$inc": bson.M{"units": delta}, "$setOnInsert": bson.M{"orgID": orgID}}
+	_, err := r.stock.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// allocatedUnits sums the stock of a product held in warehouses
+func (r *MongoInventoryRepository) allocatedUnits(ctx context.Context, productID string) (int, error) {
+	cursor, err := r.stock.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"productID": productID}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "units": bson.M{"$sum": "$units"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var result []struct {
+		Units int `bson:"units"`
+	}
+	if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+		return 0, err
+	}
+	return result[0].Units, nil
+}
+
+// StockTotals groups the stock levels by product, joining the warehouse names and the
+// product's name and total units
+func (r *MongoInventoryRepository) StockTotals(ctx context.Context, orgID, productID string) ([]ProductStock, error) {
+	match := bson.M{"orgID": orgID, "units": bson.M{"$gt": 0}}
+	if productID != "" {
+		match["productID"] = productID
+	}
+
+	cursor, err := r.stock.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$addFields", Value: bson.M{"warehouseObjectID": bson.M{"$toObjectId": "$warehouseID"}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.warehouses.Name(),
+			"localField":   "warehouseObjectID",
+			"foreignField": "_id",
+			"as":           "warehouse",
+		}}},
+		{{Key: "$unwind", Value: "$warehouse"}},
+		{{Key: "$sort", Value: bson.D{{Key: "warehouse.name", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":       "$productID",
+			"allocated": bson.M{"$sum": "$units"},
+			"warehouses": bson.M{"$push": bson.M{
+				"warehouseID":   "$warehouseID",
+				"warehouseName": "$warehouse.name",
+				"units":         "$units",
+			}},
+		}}},
+		{{Key: "$addFields", Value: bson.M{"productObjectID": bson.M{"$toObjectId": "$_id"}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         r.items.Name(),
+			"localField":   "productObjectID",
+			"foreignField": "_id",
+			"as":           "product",
+		}}},
+		{{Key: "$unwind", Value: "$product"}},
+		{{Key: "$project", Value: bson.M{
+			"productName": "$product.productName",
+			"units":       "$product.units",
+			"allocated":   1,
+			"unallocated": bson.M{"$subtract": bson.A{"$product.units", "$allocated"}},
+			"warehouses":  1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "productName", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	totals := []ProductStock{}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (r *MongoInventoryRepository) CreateCategory(ctx context.Context, category *Category) error {
+	category.ID = ""
+	result, err := r.categories.InsertOne(ctx, category)
+	if err != nil {
+		return err
+	}
+	category.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r *MongoInventoryRepository) FindCategory(ctx context.Context, id string) (*Category, error) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	var category Category
+	err = r.categories.FindOne(ctx, bson.M{"_id": objectId}).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *MongoInventoryRepository) ListCategories(ctx context.Context, orgID string) ([]Category, error) {
+	cursor, err := r.categories.Find(ctx, bson.M{"orgID": orgID}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	categories := []Category{}
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *MongoInventoryRepository) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
+	objectId, err := primitive.ObjectIDFromHex(category.ID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	update := bson.M{"$set": bson.M{"name": category.Name, "attributes": category.Attributes}}
+	var updated Category
+	err = r.categories.FindOneAndUpdate(ctx, bson.M{"_id": objectId, "orgID": category.OrgID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (r *MongoInventoryRepository) DeleteCategory(ctx context.Context, orgID, id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	result, err := r.categories.DeleteOne(ctx, bson.M{"_id": objectId, "orgID": orgID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type MongoUserRepository struct {
+	users *mongo.Collection
+}
+
+func (r *MongoUserRepository) FindByUsername(ctx context.Context, username string) (*User, error) {
+	var user User
+	err := r.users.FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) Create(ctx context.Context, user *User) error {
+	result, err := r.users.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+// MongoTokenDenylist stores revoked token IDs as document IDs, a TTL index removes
+// them once the token expired
+type MongoTokenDenylist struct {
+	revoked *mongo.Collection
+}
+
+func (d *MongoTokenDenylist) Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	_, err := d.revoked.InsertOne(ctx, bson.M{"_id": jti, "expiresAt": expiresAt})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (d *MongoTokenDenylist) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	// the TTL monitor runs once a minute, expired entries can linger a little
+	count, err := d.revoked.CountDocuments(ctx, bson.M{"_id": jti})
+	return count > 0, err
+}
+
+func (d *MongoTokenDenylist) EnsureIndexes(ctx context.Context) error {
+	_, err := d.revoked.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// MongoQuotaStore keeps a counter document per user and day, a TTL index removes
+// the counters of past days
+type MongoQuotaStore struct {
+	quotas *mongo.Collection
+}
+
+func (q *MongoQuotaStore) Increment(ctx context.Context, userID, day string, expiresAt time.Time) (int, error) {
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := q.quotas.FindOneAndUpdate(ctx,
+		bson.M{"_id": userID + ":" + day},
+		bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"userID": userID, "expiresAt": expiresAt}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Count, err
+}
+
+func (q *MongoQuotaStore) EnsureIndexes(ctx context.Context) error {
+	_, err := q.quotas.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// MongoOrganizationRepository stores organizations, memberships and invitations in
+// their own collections. Organization and invitation IDs are hex ObjectIDs stored as
+// strings, so that personal organizations can reuse the user ID.
+type MongoOrganizationRepository struct {
+	orgs        *mongo.Collection
+	members     *mongo.Collection
+	invitations *mongo.Collection
+}
+
+func (r *MongoOrganizationRepository) CreateOrganization(ctx context.Context, org *Organization, ownerID string) error {
+	org.ID = primitive.NewObjectID().Hex()
+	if _, err := r.orgs.InsertOne(ctx, org); err != nil {
+		return err
+	}
+	_, err := r.members.InsertOne(ctx, Membership{OrgID: org.ID, UserID: ownerID, Role: roleOwner, JoinedAt: org.CreatedAt})
+	return err
+}
+
+func (r *MongoOrganizationRepository) EnsurePersonal(ctx context.Context, userID string) error {
+	now := time.Now().UTC()
+	_, err := r.orgs.UpdateOne(ctx, bson.M{"_id": userID},
+		bson.M{"$setOnInsert": bson.M{"name": "Personal", "createdAt": now}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+	_, err = r.members.UpdateOne(ctx, bson.M{"orgID": userID, "userID": userID},
+		bson.M{"$setOnInsert": bson.M{"role": roleOwner, "joinedAt": now}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoOrganizationRepository) FindOrganization(ctx context.Context, id string) (*Organization, error) {
+	var org Organization
+	err := r.orgs.FindOne(ctx, bson.M{"_id": id}).Decode(&org)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *MongoOrganizationRepository) Organizations(ctx context.Context, userID string) ([]Organization, error) {
+	cursor, err := r.members.Find(ctx, bson.M{"userID": userID})
+	if err != nil {
+		return nil, err
+	}
+	var memberships []Membership
+	if err := cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	roles := make(map[string]string, len(memberships))
+	ids := make([]string, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.OrgID] = membership.Role
+		ids = append(ids, membership.OrgID)
+	}
+
+	cursor, err = r.orgs.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	orgs := []Organization{}
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return nil, err
+	}
+	for i := range orgs {
+		orgs[i].Role = roles[orgs[i].ID]
+	}
+	return orgs, nil
+}
+
+func (r *MongoOrganizationRepository) Membership(ctx context.Context, orgID, userID string) (*Membership, error) {
+	var membership Membership
+	err := r.members.FindOne(ctx, bson.M{"orgID": orgID, "userID": userID}).Decode(&membership)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (r *MongoOrganizationRepository) Members(ctx context.Context, orgID string) ([]Membership, error) {
+	cursor, err := r.members.Find(ctx, bson.M{"orgID": orgID}, options.Find().SetSort(bson.D{{Key: "joinedAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	members := []Membership{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *MongoOrganizationRepository) SetRole(ctx context.Context, orgID, userID, role string) error {
+	result, err := r.members.UpdateOne(ctx, bson.M{"orgID": orgID, "userID": userID}, bson.M{"$set": bson.M{"role": role}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoOrganizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	result, err := r.members.DeleteOne(ctx, bson.M{"orgID": orgID, "userID": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoOrganizationRepository) CreateInvitation(ctx context.Context, invitation *Invitation) error {
+	invitation.ID = primitive.NewObjectID().Hex()
+	_, err := r.invitations.InsertOne(ctx, invitation)
+	return err
+}
+
+func (r *MongoOrganizationRepository) FindInvitation(ctx context.Context, id string) (*Invitation, error) {
+	var invitation Invitation
+	// the TTL monitor runs once a minute, expired invitations can linger a little
+	err := r.invitations.FindOne(ctx, bson.M{"_id": id, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *MongoOrganizationRepository) Invitations(ctx context.Context, userID string) ([]Invitation, error) {
+	cursor, err := r.invitations.Find(ctx, bson.M{"userID": userID, "expiresAt": bson.M{"$gt": time.Now()}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	invitations := []Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *MongoOrganizationRepository) AcceptInvitation(ctx context.Context, invitation Invitation) error {
+	_, err := r.members.InsertOne(ctx, Membership{
+		OrgID:    invitation.OrgID,
+		UserID:   invitation.UserID,
+		Role:     invitation.Role,
+		JoinedAt: time.Now().UTC(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyMember
+	}
+	if err != nil {
+		return err
+	}
+	_, err = r.invitations.DeleteOne(ctx, bson.M{"_id": invitation.ID})
+	return err
+}
+
+func (r *MongoOrganizationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.members.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "orgID", Value: 1}, {Key: "userID", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "userID", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.invitations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userID", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+// MongoAuditLog appends the audit trail to its own collection
+type MongoAuditLog struct {
+	entries *mongo.Collection
+}
+
+func (l *MongoAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	entry.ID = ""
+	result, err := l.entries.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	entry.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (l *MongoAuditLog) List(ctx context.Context, orgID string, q auditQuery) ([]AuditEntry, int64, error) {
+	filter := bson.M{"orgID": orgID}
+	if q.ItemID != "" {
+		filter["itemID"] = q.ItemID
+	}
+	period := bson.M{}
+	if !q.From.IsZero() {
+		period["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		period["$lt"] = q.To
+	}
+	if len(period) > 0 {
+		filter["createdAt"] = period
+	}
+
+	total, err := l.entries.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(q.Offset)).
+		SetLimit(int64(q.Limit))
+	cursor, err := l.entries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (l *MongoAuditLog) EnsureIndexes(ctx context.Context) error {
+	_, err := l.entries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "orgID", Value: 1}, {Key: "itemID", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
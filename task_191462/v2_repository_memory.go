@@ -0,0 +1,791 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryInventoryRepository keeps the inventory in the process, for tests and for
+// running the service without MongoDB. Search matches any word of the query as a
+// case insensitive substring instead of using a text index.
+type MemoryInventoryRepository struct {
+	mu         sync.Mutex
+	items      map[string]InventoryItem
+	movements  []StockMovement
+	warehouses map[string]Warehouse
+	stock      map[stockKey]int
+	transfers  []StockTransfer
+	categories map[string]Category
+}
+
+type stockKey struct {
+	productID   string
+	warehouseID string
+}
+
+func NewMemoryInventoryRepository() *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{
+		items:      make(map[string]InventoryItem),
+		warehouses: make(map[string]Warehouse),
+		stock:      make(map[stockKey]int),
+		categories: make(map[string]Category),
+	}
+}
+
+func (r *MemoryInventoryRepository) Create(ctx context.Context, item *InventoryItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item.ID = primitive.NewObjectID().Hex()
+	r.items[item.ID] = *item
+	return nil
+}
+
+func (r *MemoryInventoryRepository) FindByID(ctx context.Context, id string) (*InventoryItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (r *MemoryInventoryRepository) FindByName(ctx context.Context, orgID, name string) (*InventoryItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, item := range r.items {
+		if item.OrgID == orgID && item.ProductName == name {
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryInventoryRepository) FindBySKU(ctx context.Context, orgID, sku string) (*InventoryItem, error) {
+	return r.find(func(item InventoryItem) bool { return item.OrgID == orgID && item.SKU == sku })
+}
+
+func (r *MemoryInventoryRepository) FindByBarcode(ctx context.Context, orgID, barcode string) (*InventoryItem, error) {
+	return r.find(func(item InventoryItem) bool { return item.OrgID == orgID && item.Barcode == barcode })
+}
+
+func (r *MemoryInventoryRepository) find(match func(InventoryItem) bool) (*InventoryItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, item := range r.items {
+		if match(item) {
+			return &item, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *MemoryInventoryRepository) NameExists(ctx context.Context, orgID, name, excludeID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, item := range r.items {
+		if item.OrgID == orgID && item.ProductName == name && id != excludeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *MemoryInventoryRepository) Update(ctx context.Context, item InventoryItem) (*InventoryItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.items[item.ID]
+	if !ok || stored.OrgID != item.OrgID {
+		return nil, ErrNotFound
+	}
+	stored.ProductName, stored.Price, stored.UnitCost, stored.ReorderLevel = item.ProductName, item.Price, item.UnitCost, item.ReorderLevel
+	stored.SKU, stored.Barcode = item.SKU, item.Barcode
+	stored.CategoryID, stored.Tags, stored.Attributes = item.CategoryID, item.Tags, item.Attributes
+	r.items[item.ID] = stored
+	return &stored, nil
+}
+
+func (r *MemoryInventoryRepository) Delete(ctx context.Context, orgID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if item, ok := r.items[id]; !ok || item.OrgID != orgID {
+		return ErrNotFound
+	}
+	delete(r.items, id)
+	for key := range r.stock {
+		if key.productID == id {
+			delete(r.stock, key)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInventoryRepository) List(ctx context.Context, orgID string, q listQuery) ([]InventoryItem, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matches []InventoryItem
+	for _, item := range r.items {
+		if item.OrgID == orgID && q.matches(item) {
+			matches = append(matches, item)
+		}
+	}
+
+	field, desc := q.SortBy, q.Desc
+	if field == "" {
+		field = "productName"
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		var less, equal bool
+		switch field {
+		case "price":
+			less, equal = a.Price < b.Price, a.Price == b.Price
+		case "units":
+			less, equal = a.Units < b.Units, a.Units == b.Units
+		default:
+			less, equal = a.ProductName < b.ProductName, a.ProductName == b.ProductName
+		}
+		if equal {
+			return a.ID < b.ID
+		}
+		return less != desc
+	})
+
+	total := int64(len(matches))
+	start := q.Offset
+	if start > len(matches) {
+		start = len(matches)
+	}
+	end := start + q.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return append([]InventoryItem{}, matches[start:end]...), total, nil
+}
+
+// matches applies the filters of the query the way the Mongo filter does
+func (q listQuery) matches(item InventoryItem) bool {
+	if q.MinPrice != nil && item.Price < *q.MinPrice || q.MaxPrice != nil && item.Price > *q.MaxPrice {
+		return false
+	}
+	if q.CategoryID != "" && item.CategoryID != q.CategoryID {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !containsString(item.Tags, tag) {
+			return false
+		}
+	}
+	if q.Search == "" {
+		return true
+	}
+	name := strings.ToLower(item.ProductName)
+	for _, word := range strings.Fields(strings.ToLower(q.Search)) {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryInventoryRepository) Each(ctx context.Context, orgID string, fn func(InventoryItem) error) error {
+	r.mu.Lock()
+	var items []InventoryItem
+	for _, item := range r.items {
+		if item.OrgID == orgID {
+			items = append(items, item)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ProductName < items[j].ProductName })
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInventoryRepository) LowStock(ctx context.Context, orgID string) ([]InventoryItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	items := []InventoryItem{}
+	for _, item := range r.items {
+		if item.ReorderLevel > 0 && item.Units <= item.ReorderLevel && (orgID == "" || item.OrgID == orgID) {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Units < items[j].Units })
+	return items, nil
+}
+
+func (r *MemoryInventoryRepository) RecordMovement(ctx context.Context, movement *StockMovement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	item, ok := r.items[movement.ProductID]
+	if !ok || item.OrgID != movement.OrgID || item.Units+movement.Quantity < 0 {
+		return ErrInsufficientStock
+	}
+	if movement.WarehouseID != "" {
+		key := stockKey{movement.ProductID, movement.WarehouseID}
+		if r.stock[key]+movement.Quantity < 0 {
+			return ErrInsufficientStock
+		}
+		r.stock[key] += movement.Quantity
+	} else if item.Units+movement.Quantity < r.allocatedUnits(item.ID) {
+		return ErrInsufficientStock
+	}
+	item.Units += movement.Quantity
+	r.items[item.ID] = item
+
+	movement.ID = primitive.NewObjectID().Hex()
+	movement.UnitsAfter = item.Units
+	r.movements = append(r.movements, *movement)
+	return nil
+}
+
+func (r *MemoryInventoryRepository) Movements(ctx context.Context, orgID, productID string) ([]StockMovement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	movements := []StockMovement{}
+	for i := len(r.movements) - 1; i >= 0; i-- {
+		if m := r.movements[i]; m.OrgID == orgID && m.ProductID == productID {
+			movements = append(movements, m)
+		}
+	}
+	return movements, nil
+}
+
+func (r *MemoryInventoryRepository) Ledger(ctx context.Context, orgID string, fn func(StockMovement) error) error {
+	r.mu.Lock()
+	var movements []StockMovement
+	for _, m := range r.movements {
+		if m.OrgID == orgID {
+			movements = append(movements, m)
+		}
+	}
+	r.mu.Unlock()
+
+	// movements are appended in order, a stable sort keeps them oldest first
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].ProductID < movements[j].ProductID })
+	for _, m := range movements {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryInventoryRepository) TopMoving(ctx context.Context, orgID string, from, to time.Time, limit int) ([]MovingItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byProduct := make(map[string]*MovingItem)
+	for _, m := range r.movements {
+		if m.OrgID != orgID || m.Reason != ReasonSale && m.Reason != ReasonConsumption ||
+			m.CreatedAt.Before(from) || !m.CreatedAt.Before(to) {
+			continue
+		}
+		item, ok := byProduct[m.ProductID]
+		if !ok {
+			item = &MovingItem{ProductID: m.ProductID, ProductName: r.items[m.ProductID].ProductName}
+			byProduct[m.ProductID] = item
+		}
+		item.UnitsOut -= m.Quantity
+		if m.Reason == ReasonSale {
+			item.UnitsSold -= m.Quantity
+			item.Revenue -= float64(m.Quantity) * m.UnitPrice
+		}
+	}
+
+	items := []MovingItem{}
+	for _, item := range byProduct {
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].UnitsOut != items[j].UnitsOut {
+			return items[i].UnitsOut > items[j].UnitsOut
+		}
+		return items[i].ProductID < items[j].ProductID
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (r *MemoryInventoryRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *MemoryInventoryRepository) CreateWarehouse(ctx context.Context, warehouse *Warehouse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warehouse.ID = primitive.NewObjectID().Hex()
+	r.warehouses[warehouse.ID] = *warehouse
+	return nil
+}
+
+func (r *MemoryInventoryRepository) FindWarehouse(ctx context.Context, id string) (*Warehouse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warehouse, ok := r.warehouses[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &warehouse, nil
+}
+
+func (r *MemoryInventoryRepository) ListWarehouses(ctx context.Context, orgID string) ([]Warehouse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	warehouses := []Warehouse{}
+	for _, warehouse := range r.warehouses {
+		if warehouse.OrgID == orgID {
+			warehouses = append(warehouses, warehouse)
+		}
+	}
+	sort.Slice(warehouses, func(i, j int) bool { return warehouses[i].Name < warehouses[j].Name })
+	return warehouses, nil
+}
+
+func (r *MemoryInventoryRepository) WarehouseStock(ctx context.Context, orgID, warehouseID string) ([]StockLevel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	levels := []StockLevel{}
+	for key, units := range r.stock {
+		if key.warehouseID == warehouseID && units > 0 && r.items[key.productID].OrgID == orgID {
+			levels = append(levels, StockLevel{ProductID: key.productID, WarehouseID: warehouseID, OrgID: orgID, Units: units})
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i].ProductID < levels[j].ProductID })
+	return levels, nil
+}
+
+func (r *MemoryInventoryRepository) Transfer(ctx context.Context, transfer *StockTransfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from := stockKey{transfer.ProductID, transfer.FromWarehouseID}
+	if r.stock[from] < transfer.Quantity {
+		return ErrInsufficientStock
+	}
+	r.stock[from] -= transfer.Quantity
+	r.stock[stockKey{transfer.ProductID, transfer.ToWarehouseID}] += transfer.Quantity
+
+	transfer.ID = primitive.NewObjectID().Hex()
+	r.transfers = append(r.transfers, *transfer)
+	return nil
+}
+
+func (r *MemoryInventoryRepository) allocatedUnits(productID string) int {
+	var units int
+	for key, n := range r.stock {
+		if key.productID == productID {
+			units += n
+		}
+	}
+	return units
+}
+
+func (r *MemoryInventoryRepository) StockTotals(ctx context.Context, orgID, productID string) ([]ProductStock, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byProduct := make(map[string]*ProductStock)
+	for key, units := range r.stock {
+		item, ok := r.items[key.productID]
+		if units <= 0 || !ok || item.OrgID != orgID || productID != "" && key.productID != productID {
+			continue
+		}
+		total, ok := byProduct[key.productID]
+		if !ok {
+			total = &ProductStock{ProductID: item.ID, ProductName: item.ProductName, Units: item.Units}
+			byProduct[key.productID] = total
+		}
+		total.Allocated += units
+		total.Warehouses = append(total.Warehouses, WarehouseStock{
+			WarehouseID:   key.warehouseID,
+			WarehouseName: r.warehouses[key.warehouseID].Name,
+			Units:         units,
+		})
+	}
+
+	totals := []ProductStock{}
+	for _, total := range byProduct {
+		total.Unallocated = total.Units - total.Allocated
+		sort.Slice(total.Warehouses, func(i, j int) bool {
+			return total.Warehouses[i].WarehouseName < total.Warehouses[j].WarehouseName
+		})
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].ProductName < totals[j].ProductName })
+	return totals, nil
+}
+
+func (r *MemoryInventoryRepository) CreateCategory(ctx context.Context, category *Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	category.ID = primitive.NewObjectID().Hex()
+	r.categories[category.ID] = *category
+	return nil
+}
+
+func (r *MemoryInventoryRepository) FindCategory(ctx context.Context, id string) (*Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	category, ok := r.categories[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &category, nil
+}
+
+func (r *MemoryInventoryRepository) ListCategories(ctx context.Context, orgID string) ([]Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	categories := []Category{}
+	for _, category := range r.categories {
+		if category.OrgID == orgID {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (r *MemoryInventoryRepository) UpdateCategory(ctx context.Context, category Category) (*Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.categories[category.ID]
+	if !ok || stored.OrgID != category.OrgID {
+		return nil, ErrNotFound
+	}
+	stored.Name, stored.Attributes = category.Name, category.Attributes
+	r.categories[category.ID] = stored
+	return &stored, nil
+}
+
+func (r *MemoryInventoryRepository) DeleteCategory(ctx context.Context, orgID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if category, ok := r.categories[id]; !ok || category.OrgID != orgID {
+		return ErrNotFound
+	}
+	delete(r.categories, id)
+	return nil
+}
+
+type MemoryUserRepository struct {
+	mu    sync.Mutex
+	users map[string]User
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[string]User)}
+}
+
+func (r *MemoryUserRepository) FindByUsername(ctx context.Context, username string) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[username]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.ID = primitive.NewObjectID().Hex()
+	r.users[user.Username] = *user
+	return nil
+}
+
+// MemoryTokenDenylist forgets revoked tokens once they expire, like the TTL index
+type MemoryTokenDenylist struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryTokenDenylist() *MemoryTokenDenylist {
+	return &MemoryTokenDenylist{revoked: make(map[string]time.Time), now: time.Now}
+}
+
+func (d *MemoryTokenDenylist) Revoke(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for id, expiry := range d.revoked {
+		if !expiry.After(now) {
+			delete(d.revoked, id)
+		}
+	}
+	if _, ok := d.revoked[jti]; ok {
+		return false, nil
+	}
+	d.revoked[jti] = expiresAt
+	return true, nil
+}
+
+func (d *MemoryTokenDenylist) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiry, ok := d.revoked[jti]
+	return ok && expiry.After(d.now()), nil
+}
+
+func (d *MemoryTokenDenylist) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// MemoryQuotaStore keeps the counters of the current day per user
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	day    string
+	counts map[string]int
+}
+
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counts: make(map[string]int)}
+}
+
+func (q *MemoryQuotaStore) Increment(ctx context.Context, userID, day string, expiresAt time.Time) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if day != q.day {
+		q.day, q.counts = day, make(map[string]int)
+	}
+	q.counts[userID]++
+	return q.counts[userID], nil
+}
+
+func (q *MemoryQuotaStore) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// MemoryOrganizationRepository keeps organizations, memberships and invitations in
+// the process
+type MemoryOrganizationRepository struct {
+	mu          sync.Mutex
+	orgs        map[string]Organization
+	members     []Membership
+	invitations map[string]Invitation
+	now         func() time.Time
+}
+
+func NewMemoryOrganizationRepository() *MemoryOrganizationRepository {
+	return &MemoryOrganizationRepository{
+		orgs:        make(map[string]Organization),
+		invitations: make(map[string]Invitation),
+		now:         time.Now,
+	}
+}
+
+func (r *MemoryOrganizationRepository) CreateOrganization(ctx context.Context, org *Organization, ownerID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	org.ID = primitive.NewObjectID().Hex()
+	r.orgs[org.ID] = *org
+	r.members = append(r.members, Membership{OrgID: org.ID, UserID: ownerID, Role: roleOwner, JoinedAt: org.CreatedAt})
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) EnsurePersonal(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now().UTC()
+	if _, ok := r.orgs[userID]; !ok {
+		r.orgs[userID] = Organization{ID: userID, Name: "Personal", CreatedAt: now}
+	}
+	if r.member(userID, userID) < 0 {
+		r.members = append(r.members, Membership{OrgID: userID, UserID: userID, Role: roleOwner, JoinedAt: now})
+	}
+	return nil
+}
+
+// member returns the index of the membership, -1 if there is none
+func (r *MemoryOrganizationRepository) member(orgID, userID string) int {
+	for i, membership := range r.members {
+		if membership.OrgID == orgID && membership.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *MemoryOrganizationRepository) FindOrganization(ctx context.Context, id string) (*Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	org, ok := r.orgs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &org, nil
+}
+
+func (r *MemoryOrganizationRepository) Organizations(ctx context.Context, userID string) ([]Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	orgs := []Organization{}
+	for _, membership := range r.members {
+		if org, ok := r.orgs[membership.OrgID]; ok && membership.UserID == userID {
+			org.Role = membership.Role
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].Name < orgs[j].Name })
+	return orgs, nil
+}
+
+func (r *MemoryOrganizationRepository) Membership(ctx context.Context, orgID, userID string) (*Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.member(orgID, userID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	membership := r.members[i]
+	return &membership, nil
+}
+
+func (r *MemoryOrganizationRepository) Members(ctx context.Context, orgID string) ([]Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members := []Membership{}
+	for _, membership := range r.members {
+		if membership.OrgID == orgID {
+			members = append(members, membership)
+		}
+	}
+	return members, nil
+}
+
+func (r *MemoryOrganizationRepository) SetRole(ctx context.Context, orgID, userID, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.member(orgID, userID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.members[i].Role = role
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.member(orgID, userID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	r.members = append(r.members[:i], r.members[i+1:]...)
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) CreateInvitation(ctx context.Context, invitation *Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitation.ID = primitive.NewObjectID().Hex()
+	r.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) FindInvitation(ctx context.Context, id string) (*Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitation, ok := r.invitations[id]
+	if !ok || !invitation.ExpiresAt.After(r.now()) {
+		return nil, ErrNotFound
+	}
+	return &invitation, nil
+}
+
+func (r *MemoryOrganizationRepository) Invitations(ctx context.Context, userID string) ([]Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invitations := []Invitation{}
+	for _, invitation := range r.invitations {
+		if invitation.UserID == userID && invitation.ExpiresAt.After(r.now()) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].CreatedAt.Before(invitations[j].CreatedAt) })
+	return invitations, nil
+}
+
+func (r *MemoryOrganizationRepository) AcceptInvitation(ctx context.Context, invitation Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.member(invitation.OrgID, invitation.UserID) >= 0 {
+		return ErrAlreadyMember
+	}
+	r.members = append(r.members, Membership{
+		OrgID:    invitation.OrgID,
+		UserID:   invitation.UserID,
+		Role:     invitation.Role,
+		JoinedAt: r.now().UTC(),
+	})
+	delete(r.invitations, invitation.ID)
+	return nil
+}
+
+func (r *MemoryOrganizationRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// MemoryAuditLog keeps the audit trail in the process, in the order it was recorded
+type MemoryAuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (l *MemoryAuditLog) Record(ctx context.Context, entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.ID = primitive.NewObjectID().Hex()
+	l.entries = append(l.entries, *entry)
+	return nil
+}
+
+func (l *MemoryAuditLog) List(ctx context.Context, orgID string, q auditQuery) ([]AuditEntry, int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var matches []AuditEntry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		entry := l.entries[i]
+		if entry.OrgID != orgID || q.ItemID != "" && entry.ItemID != q.ItemID ||
+			!q.From.IsZero() && entry.CreatedAt.Before(q.From) || !q.To.IsZero() && !entry.CreatedAt.Before(q.To) {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+
+	total := int64(len(matches))
+	start := q.Offset
+	if start > len(matches) {
+		start = len(matches)
+	}
+	end := start + q.Limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	return append([]AuditEntry{}, matches[start:end]...), total, nil
+}
+
+func (l *MemoryAuditLog) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,209 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestServer returns a server backed by the in-memory repositories
+func newTestServer() *Server {
+	inventory := NewMemoryInventoryRepository()
+	return &Server{
+		Inventory:  inventory,
+		Warehouses: inventory,
+		Categories: inventory,
+		Orgs:       NewMemoryOrganizationRepository(),
+		Audit:      NewMemoryAuditLog(),
+		Users:      NewMemoryUserRepository(),
+		Revoked:    NewMemoryTokenDenylist(),
+		Quotas:     NewMemoryQuotaStore(),
+		JWTSecret:  "test-secret",
+	}
+}
+
+func TestProductHandlers(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	product := InventoryItem{OrgID: "owner", ProductName: "Widget", Units: 5, Price: 2.5}
+	s.Inventory.Create(context.Background(), &product)
+	missing := "64b7f0c2e13f4a2d9c8b4567"
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		user           string
+		body           string
+		expectedStatus int
+		expectedUnits  int
+	}{
+		{
+			name:           "Owner Gets Product",
+			method:         "GET",
+			path:           "/auth/products/" + product.ID,
+			user:           "owner",
+			expectedStatus: 200,
+			expectedUnits:  5,
+		},
+		{
+			name:           "Other User Is Forbidden",
+			method:         "GET",
+			path:           "/auth/products/" + product.ID,
+			user:           "intruder",
+			expectedStatus: 403,
+		},
+		{
+			name:           "Unknown Product",
+			method:         "GET",
+			path:           "/auth/products/" + missing,
+			user:           "owner",
+			expectedStatus: 404,
+		},
+		{
+			name:           "Sale Reduces Stock",
+			method:         "POST",
+			path:           "/auth/products/" + product.ID + "/movements",
+			user:           "owner",
+			body:           `{"quantity":-3,"reason":"sale"}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Sale Beyond Stock Is Rejected",
+			method:         "POST",
+			path:           "/auth/products/" + product.ID + "/movements",
+			user:           "owner",
+			body:           `{"quantity":-3,"reason":"sale"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Update Keeps Units",
+			method:         "PUT",
+			path:           "/auth/products/" + product.ID,
+			user:           "owner",
+			body:           `{"productName":"Gadget","units":100,"price":3}`,
+			expectedStatus: 200,
+			expectedUnits:  2,
+		},
+		{
+			name:           "Other User Can't Delete",
+			method:         "DELETE",
+			path:           "/auth/products/" + product.ID,
+			user:           "intruder",
+			expectedStatus: 403,
+		},
+		{
+			name:           "Owner Deletes Product",
+			method:         "DELETE",
+			path:           "/auth/products/" + product.ID,
+			user:           "owner",
+			expectedStatus: 200,
+		},
+		{
+			name:           "Deleted Product Is Gone",
+			method:         "GET",
+			path:           "/auth/products/" + product.ID,
+			user:           "owner",
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			token, _, _ := s.generateToken(tt.user)
+			req.Header.Set("Authorization", "Bearer "+token)
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedUnits != 0 {
+				var got InventoryItem
+				json.Unmarshal(w.Body.Bytes(), &got)
+				if got.Units != tt.expectedUnits {
+					t.Errorf("Expected %d units, got %d", tt.expectedUnits, got.Units)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryInventoryList(t *testing.T) {
+	repo := NewMemoryInventoryRepository()
+	for _, item := range []InventoryItem{
+		{OrgID: "u1", ProductName: "Red Apple", Price: 1.5},
+		{OrgID: "u1", ProductName: "Green Apple", Price: 1},
+		{OrgID: "u1", ProductName: "Banana", Price: 0.5},
+		{OrgID: "u2", ProductName: "Apple Pie", Price: 6},
+	} {
+		repo.Create(context.Background(), &item)
+	}
+	maxPrice := 1.2
+
+	tests := []struct {
+		name          string
+		query         listQuery
+		expectedNames []string
+		expectedTotal int64
+	}{
+		{
+			name:          "Sorted By Name",
+			query:         listQuery{Limit: 10},
+			expectedNames: []string{"Banana", "Green Apple", "Red Apple"},
+			expectedTotal: 3,
+		},
+		{
+			name:          "Search Is Case Insensitive",
+			query:         listQuery{Limit: 10, Search: "APPLE"},
+			expectedNames: []string{"Green Apple", "Red Apple"},
+			expectedTotal: 2,
+		},
+		{
+			name:          "Price Filter And Descending Sort",
+			query:         listQuery{Limit: 10, MaxPrice: &maxPrice, SortBy: "price", Desc: true},
+			expectedNames: []string{"Green Apple", "Banana"},
+			expectedTotal: 2,
+		},
+		{
+			name:          "Page Beyond The End",
+			query:         listQuery{Limit: 2, Offset: 2},
+			expectedNames: []string{"Red Apple"},
+			expectedTotal: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := repo.List(context.Background(), "u1", tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, item := range items {
+				names = append(names, item.ProductName)
+			}
+			if total != tt.expectedTotal || len(names) != len(tt.expectedNames) {
+				t.Fatalf("Expected %v of %d, got %v of %d", tt.expectedNames, tt.expectedTotal, names, total)
+			}
+			for i := range names {
+				if names[i] != tt.expectedNames[i] {
+					t.Errorf("Expected %v, got %v", tt.expectedNames, names)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"awesomeProject/platform/config"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRequestTimeout  = 10 * time.Second
+	defaultShutdownTimeout = 30 * time.Second
+)
+
+// positiveDuration reads a duration setting that must be positive
+func positiveDuration(cfg *config.Config, key string, def time.Duration) time.Duration {
+	d := cfg.Duration(key, def)
+	if d <= 0 {
+		cfg.Check(key, errors.New("must be positive"))
+		return def
+	}
+	return d
+}
+
+// requestTimeout bounds the request context, and with it the database calls of the
+// handlers. A client hanging up cancels the context as well.
+func requestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
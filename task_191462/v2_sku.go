@@ -0,0 +1,175 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strings"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	// skuAlphabet leaves out 0, O, 1 and I, which are easily confused on labels
+	skuAlphabet     = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	skuSuffixLength = 6
+	skuAttempts     = 5
+)
+
+// validSKU accepts letters, digits, dashes, underscores and dots
+func validSKU(fl validator.FieldLevel) bool {
+	for _, r := range fl.Field().String() {
+		if !isAlphanumeric(r) && r != '-' && r != '_' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// validBarcode accepts printable ASCII without spaces, as used by Code 128 and QR
+// labels. Numeric codes of GTIN lengths (EAN-8, UPC-A, EAN-13 and GTIN-14) must have a
+// valid check digit, which catches misreads and typos.
+func validBarcode(fl validator.FieldLevel) bool {
+	code := fl.Field().String()
+	digits := true
+	for _, r := range code {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+		digits = digits && r >= '0' && r <= '9'
+	}
+	switch len(code) {
+	case 8, 12, 13, 14:
+		if digits {
+			return gtinCheckDigit(code[:len(code)-1]) == code[len(code)-1]
+		}
+	}
+	return true
+}
+
+// gtinCheckDigit computes the check digit of a GTIN without it: digits are weighted 3
+// and 1 alternately from the right
+func gtinCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		weight := 1
+		if (len(digits)-1-i)%2 == 0 {
+			weight = 3
+		}
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isAlphanumeric(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// generateSKU builds a SKU from the first three letters or digits of the category and
+// of the product name and a random suffix, a "Red Shirt" in "Shirts" gets SHI-RED-7K2Q9X.
+// Products without a category use GEN.
+func generateSKU(categoryName, productName string) (string, error) {
+	suffix := make([]byte, skuSuffixLength)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	for i, b := range suffix {
+		suffix[i] = skuAlphabet[int(b)%len(skuAlphabet)]
+	}
+	return skuPart(categoryName, "GEN") + "-" + skuPart(productName, "ITM") + "-" + string(suffix), nil
+}
+
+func skuPart(name, fallback string) string {
+	var part strings.Builder
+	for _, r := range name {
+		if isAlphanumeric(r) {
+			part.WriteRune(r)
+			if part.Len() == 3 {
+				break
+			}
+		}
+	}
+	if part.Len() == 0 {
+		return fallback
+	}
+	return strings.ToUpper(part.String())
+}
+
+// assignCodes checks that the SKU and the barcode of a product aren't used by another
+// product of the organization, and generates a SKU when the product has none
+func (s *Server) assignCodes(ctx context.Context, product *InventoryItem, category *Category) error {
+	if product.Barcode != "" {
+		if err := s.codeAvailable(ctx, product, s.Inventory.FindByBarcode, product.Barcode, "Barcode"); err != nil {
+			return err
+		}
+	}
+	if product.SKU != "" {
+		return s.codeAvailable(ctx, product, s.Inventory.FindBySKU, product.SKU, "SKU")
+	}
+
+	categoryName := ""
+	if category != nil {
+		categoryName = category.Name
+	}
+	for i := 0; i < skuAttempts; i++ {
+		sku, err := generateSKU(categoryName, product.ProductName)
+		if err != nil {
+			return err
+		}
+		_, err = s.Inventory.FindBySKU(ctx, product.OrgID, sku)
+		if errors.Is(err, ErrNotFound) {
+			product.SKU = sku
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return errs.New(errs.ErrConflict, "Could not generate a unique SKU, set one")
+}
+
+func (s *Server) codeAvailable(ctx context.Context, product *InventoryItem, find func(context.Context, string, string) (*InventoryItem, error), code, name string) error {
+	existing, err := find(ctx, product.OrgID, code)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != product.ID {
+		return errs.New(errs.ErrConflict, name+" is already used by another product")
+	}
+	return nil
+}
+
+// lookupProduct finds a product of the organization by its barcode or SKU, for scanners
+func (s *Server) lookupProduct(c *gin.Context) {
+	barcode, sku := strings.TrimSpace(c.Query("barcode")), strings.ToUpper(strings.TrimSpace(c.Query("sku")))
+	if (barcode == "") == (sku == "") {
+		respondError(c, errs.New(errs.ErrInvalid, "Either barcode or sku is required"))
+		return
+	}
+
+	var product *InventoryItem
+	var err error
+	if barcode != "" {
+		product, err = s.Inventory.FindByBarcode(c.Request.Context(), c.GetString("org"), barcode)
+	} else {
+		product, err = s.Inventory.FindBySKU(c.Request.Context(), c.GetString("org"), sku)
+	}
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrNotFound, "Product not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching product"))
+		return
+	}
+	c.JSON(http.StatusOK, product)
+}
@@ -0,0 +1,153 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGenerateSKU(t *testing.T) {
+	tests := []struct {
+		category string
+		product  string
+		pattern  string
+	}{
+		{category: "Shirts", product: "Red Shirt", pattern: `^SHI-RED-[2-9A-HJ-NP-Z]{6}$`},
+		{category: "", product: "4K TV", pattern: `^GEN-4KT-[2-9A-HJ-NP-Z]{6}$`},
+		{category: "Über", product: "!!", pattern: `^BER-ITM-[2-9A-HJ-NP-Z]{6}$`},
+	}
+
+	for _, tt := range tests {
+		sku, err := generateSKU(tt.category, tt.product)
+		if err != nil || !regexp.MustCompile(tt.pattern).MatchString(sku) {
+			t.Errorf("Expected a SKU matching %s for %q in %q, got %q (%v)", tt.pattern, tt.product, tt.category, sku, err)
+		}
+	}
+}
+
+func TestProductCodes(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	token, _, _ := s.generateToken("owner")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(do("POST", "/auth/createProduct", `{"productName":"Widget","units":1,"price":2,"barcode":"4006381333931"}`).Body.Bytes(), &created)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedSKU    string
+	}{
+		{
+			name:           "Invalid Check Digit",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":2,"barcode":"4006381333932"}`,
+			expectedStatus: 422,
+		},
+		{
+			name:           "Invalid SKU Characters",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":2,"sku":"GAD 01"}`,
+			expectedStatus: 422,
+		},
+		{
+			name:           "Duplicate Barcode",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":2,"barcode":"4006381333931"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Own SKU Is Uppercased",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gadget","units":1,"price":2,"sku":"gad-01","barcode":"CODE128-XYZ"}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Duplicate SKU",
+			method:         "POST",
+			path:           "/auth/createProduct",
+			body:           `{"productName":"Gizmo","units":1,"price":2,"sku":"GAD-01"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Lookup By Barcode",
+			method:         "GET",
+			path:           "/auth/products/lookup?barcode=4006381333931",
+			expectedStatus: 200,
+		},
+		{
+			name:           "Lookup By SKU Ignores Case",
+			method:         "GET",
+			path:           "/auth/products/lookup?sku=gad-01",
+			expectedStatus: 200,
+			expectedSKU:    "GAD-01",
+		},
+		{
+			name:           "Lookup Unknown Barcode",
+			method:         "GET",
+			path:           "/auth/products/lookup?barcode=96385074",
+			expectedStatus: 404,
+		},
+		{
+			name:           "Lookup Needs One Code",
+			method:         "GET",
+			path:           "/auth/products/lookup?barcode=96385074&sku=GAD-01",
+			expectedStatus: 400,
+		},
+		{
+			name:           "Update Without SKU Keeps It",
+			method:         "PUT",
+			path:           "/auth/products/" + created.ID,
+			body:           `{"productName":"Widget","price":3}`,
+			expectedStatus: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.path, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			var product InventoryItem
+			json.Unmarshal(w.Body.Bytes(), &product)
+			if tt.expectedSKU != "" && product.SKU != tt.expectedSKU {
+				t.Errorf("Expected SKU %s, got %s", tt.expectedSKU, product.SKU)
+			}
+		})
+	}
+
+	var widget InventoryItem
+	json.Unmarshal(do("GET", "/auth/products/"+created.ID, "").Body.Bytes(), &widget)
+	if !regexp.MustCompile(`^GEN-WID-\w{6}$`).MatchString(widget.SKU) || widget.Barcode != "" {
+		t.Errorf("Expected the generated SKU to be kept and the barcode cleared, got %+v", widget)
+	}
+}
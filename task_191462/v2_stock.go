@@ -0,0 +1,144 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+)
+
+// Reason codes of stock movements. Purchases and returns add stock, sales, consumption
+// and damage remove it, corrections go either way.
+const (
+	ReasonPurchase    = "purchase"
+	ReasonReturn      = "return"
+	ReasonSale        = "sale"
+	ReasonConsumption = "consumption"
+	ReasonDamage      = "damage"
+	ReasonCorrection  = "correction"
+)
+
+// StockMovement is an entry of the stock ledger. Quantity is positive for stock in
+// and negative for stock out, UnitsAfter is the product's stock after the movement.
+// Movements with a WarehouseID also change the stock held in that warehouse.
+// Stock in carries the UnitCost it is valued at, sales the UnitPrice it was sold for.
+type StockMovement struct {
+	ID          string    `json:"id,omitempty" bson:"_id,omitempty"`
+	ProductID   string    `json:"productID" bson:"productID"`
+	OrgID       string    `json:"orgID" bson:"orgID"`
+	WarehouseID string    `json:"warehouseID,omitempty" bson:"warehouseID,omitempty"`
+	Quantity    int       `json:"quantity" bson:"quantity"`
+	Reason      string    `json:"reason" bson:"reason"`
+	UnitCost    float64   `json:"unitCost,omitempty" bson:"unitCost,omitempty"`
+	UnitPrice   float64   `json:"unitPrice,omitempty" bson:"unitPrice,omitempty"`
+	Note        string    `json:"note,omitempty" bson:"note,omitempty"`
+	UnitsAfter  int       `json:"unitsAfter" bson:"unitsAfter"`
+	CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// validateMovement returns the first problem with a movement, or "" if it is valid
+func validateMovement(movement StockMovement) string {
+	if movement.Quantity == 0 {
+		return "Quantity must not be zero"
+	}
+	if movement.UnitCost < 0 || movement.UnitPrice < 0 {
+		return "Unit cost and price must not be negative"
+	}
+	switch movement.Reason {
+	case ReasonPurchase, ReasonReturn:
+		if movement.Quantity < 0 {
+			return "Reason " + movement.Reason + " requires a positive quantity"
+		}
+	case ReasonSale, ReasonConsumption, ReasonDamage:
+		if movement.Quantity > 0 {
+			return "Reason " + movement.Reason + " requires a negative quantity"
+		}
+	case ReasonCorrection:
+	default:
+		return "Unknown reason code"
+	}
+	return ""
+}
+
+// recordMovement applies a stock adjustment to a product and appends it to the ledger
+// atomically, so the materialized Units always match the movement history
+func (s *Server) recordMovement(c *gin.Context) {
+	var movement StockMovement
+	if err := c.ShouldBindJSON(&movement); err != nil {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid JSON format"))
+		return
+	}
+	if msg := validateMovement(movement); msg != "" {
+		respondError(c, errs.New(errs.ErrInvalid, msg))
+		return
+	}
+
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+	if movement.WarehouseID != "" {
+		if _, ok := s.ownedWarehouse(c, movement.WarehouseID); !ok {
+			return
+		}
+	}
+	s.saveMovement(c, product, movement)
+}
+
+// saveMovement records a validated movement of an owned product and responds with it.
+// Stock in is valued at the product's unit cost unless the movement has its own, sales
+// default to the product's price.
+func (s *Server) saveMovement(c *gin.Context, product *InventoryItem, movement StockMovement) {
+	movement.ID = ""
+	movement.ProductID = product.ID
+	movement.OrgID = product.OrgID
+	movement.CreatedAt = time.Now().UTC()
+	if movement.Quantity > 0 {
+		if movement.UnitCost == 0 {
+			movement.UnitCost = product.UnitCost
+		}
+		movement.UnitPrice = 0
+	} else {
+		movement.UnitCost = 0
+		if movement.Reason != ReasonSale {
+			movement.UnitPrice = 0
+		} else if movement.UnitPrice == 0 {
+			movement.UnitPrice = product.Price
+		}
+	}
+
+	err := s.Inventory.RecordMovement(c.Request.Context(), &movement)
+	if errors.Is(err, ErrInsufficientStock) {
+		respondError(c, errs.New(errs.ErrConflict, "Insufficient stock"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to record stock movement"))
+		return
+	}
+	after := *product
+	after.Units = movement.UnitsAfter
+	s.audit(c, AuditEntry{Entity: auditProduct, Action: auditStock, ItemID: product.ID, Reference: movement.ID}, product, after)
+
+	c.JSON(http.StatusCreated, movement)
+}
+
+// getMovements lists the ledger of a product, newest first
+func (s *Server) getMovements(c *gin.Context) {
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+
+	movements, err := s.Inventory.Movements(c.Request.Context(), product.OrgID, product.ID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching stock movements"))
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
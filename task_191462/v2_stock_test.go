@@ -0,0 +1,64 @@
+//go:build v2
+// +build v2
+
+package main
+
+import "testing"
+
+func TestValidateMovement(t *testing.T) {
+	tests := []struct {
+		name          string
+		movement      StockMovement
+		expectedError string
+	}{
+		{
+			name:     "Purchase Adds Stock",
+			movement: StockMovement{Quantity: 5, Reason: ReasonPurchase},
+		},
+		{
+			name:     "Sale Removes Stock",
+			movement: StockMovement{Quantity: -2, Reason: ReasonSale},
+		},
+		{
+			name:     "Correction Goes Either Way",
+			movement: StockMovement{Quantity: -1, Reason: ReasonCorrection},
+		},
+		{
+			name:          "Zero Quantity",
+			movement:      StockMovement{Quantity: 0, Reason: ReasonPurchase},
+			expectedError: "Quantity must not be zero",
+		},
+		{
+			name:          "Sale With Positive Quantity",
+			movement:      StockMovement{Quantity: 3, Reason: ReasonSale},
+			expectedError: "Reason sale requires a negative quantity",
+		},
+		{
+			name:          "Return With Negative Quantity",
+			movement:      StockMovement{Quantity: -3, Reason: ReasonReturn},
+			expectedError: "Reason return requires a positive quantity",
+		},
+		{
+			name:     "Consumption Removes Stock",
+			movement: StockMovement{Quantity: -1, Reason: ReasonConsumption},
+		},
+		{
+			name:          "Negative Unit Cost",
+			movement:      StockMovement{Quantity: 5, Reason: ReasonPurchase, UnitCost: -1},
+			expectedError: "Unit cost and price must not be negative",
+		},
+		{
+			name:          "Unknown Reason",
+			movement:      StockMovement{Quantity: 1, Reason: "gift"},
+			expectedError: "Unknown reason code",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateMovement(tt.movement); got != tt.expectedError {
+				t.Errorf("Expected error '%s', got '%s'", tt.expectedError, got)
+			}
+		})
+	}
+}
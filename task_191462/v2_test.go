@@ -4,16 +4,22 @@
 package main
 
 import (
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/errs"
+	"awesomeProject/platform/idempotency"
 	"bytes"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 func TestCreateProduct(t *testing.T) {
 	// Set Gin to Test Mode
 	gin.SetMode(gin.TestMode)
+	s := newTestServer()
 
 	tests := []struct {
 		name           string
@@ -36,7 +42,7 @@ func TestCreateProduct(t *testing.T) {
 				Units:       10,
 				Price:       9.99,
 			},
-			expectedStatus: 201,
+			expectedStatus: 422,
 		},
 		{
 			name: "Negative Units",
@@ -45,7 +51,7 @@ func TestCreateProduct(t *testing.T) {
 				Units:       -1,
 				Price:       9.99,
 			},
-			expectedStatus: 201,
+			expectedStatus: 422,
 		},
 		{
 			name: "Zero Price",
@@ -54,7 +60,7 @@ func TestCreateProduct(t *testing.T) {
 				Units:       10,
 				Price:       0,
 			},
-			expectedStatus: 201,
+			expectedStatus: 422,
 		},
 	}
 
@@ -64,8 +70,9 @@ func TestCreateProduct(t *testing.T) {
 			w := httptest.NewRecorder()
 			c, _ := gin.CreateTestContext(w)
 
-			// Set the user in the context
+			// Set the user and their personal organization in the context
 			c.Set("user", "test-user-id")
+			c.Set("org", "test-user-id")
 
 			// Create request body
 			jsonProduct, _ := json.Marshal(tt.product)
@@ -73,7 +80,7 @@ func TestCreateProduct(t *testing.T) {
 			c.Request.Header.Set("Content-Type", "application/json")
 
 			// Call the function
-			createProduct(c)
+			s.createProduct(c)
 
 			// Check the status code
 			if w.Code != tt.expectedStatus {
@@ -81,11 +88,167 @@ func TestCreateProduct(t *testing.T) {
 			}
 
 			// Check the response body
-			var response map[string]string
+			var response map[string]interface{}
 			json.Unmarshal(w.Body.Bytes(), &response)
+			if tt.expectedStatus == 422 {
+				if response["fields"] == nil {
+					t.Errorf("Expected per-field errors, got %v", response)
+				}
+				return
+			}
 			if response["message"] != "Product created successfully!" {
 				t.Errorf("Expected message 'Product created successfully!', got '%s'", response["message"])
 			}
 		})
 	}
 }
+
+func TestUpdateProductValidation(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		expectedStatus int
+		expectedError  string
+		expectedFields map[string]string
+	}{
+		{
+			name:           "Invalid ID",
+			id:             "not-an-id",
+			body:           `{"productName":"Test Product","units":10,"price":9.99}`,
+			expectedStatus: 400,
+			expectedError:  "Invalid ID format",
+		},
+		{
+			name:           "Invalid JSON",
+			id:             "64b7f0c2e13f4a2d9c8b4567",
+			body:           `{"productName":`,
+			expectedStatus: 400,
+			expectedError:  "Invalid JSON format",
+		},
+		{
+			name:           "Invalid Fields",
+			id:             "64b7f0c2e13f4a2d9c8b4567",
+			body:           `{"productName":"","units":-1,"price":0,"reorderLevel":-2}`,
+			expectedStatus: 422,
+			expectedError:  "Validation failed",
+			expectedFields: map[string]string{
+				"productName":  "is required",
+				"units":        "must be at least 0",
+				"price":        "must be greater than 0",
+				"reorderLevel": "must be at least 0",
+			},
+		},
+		{
+			name:           "Unknown Field",
+			id:             "64b7f0c2e13f4a2d9c8b4567",
+			body:           `{"productName":"Test Product","units":10,"price":9.99,"orgID":"someone-else"}`,
+			expectedStatus: 422,
+			expectedError:  "Validation failed",
+			expectedFields: map[string]string{"orgID": "is not allowed"},
+		},
+		{
+			name:           "Wrong Type",
+			id:             "64b7f0c2e13f4a2d9c8b4567",
+			body:           `{"productName":"Test Product","units":"ten","price":9.99}`,
+			expectedStatus: 422,
+			expectedError:  "Validation failed",
+			expectedFields: map[string]string{"units": "must be an integer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("user", "test-user-id")
+			c.Set("org", "test-user-id")
+			c.Params = gin.Params{{Key: "id", Value: tt.id}}
+			c.Request = httptest.NewRequest("PUT", "/auth/products/"+tt.id, bytes.NewBufferString(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			s.updateProduct(c)
+
+			// Invalid requests are rejected before the database is queried
+			var response errs.Problem
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if w.Code != tt.expectedStatus || response.Detail != tt.expectedError {
+				t.Errorf("Expected status %d with error '%s', got %d with '%s'", tt.expectedStatus, tt.expectedError, w.Code, response.Detail)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != errs.ContentType {
+				t.Errorf("Expected content type %s, got %s", errs.ContentType, ct)
+			}
+			if len(response.Fields) != len(tt.expectedFields) {
+				t.Errorf("Expected field errors %v, got %v", tt.expectedFields, response.Fields)
+			}
+			for field, msg := range tt.expectedFields {
+				if response.Fields[field] != msg {
+					t.Errorf("Expected '%s' for %s, got '%s'", msg, field, response.Fields[field])
+				}
+			}
+		})
+	}
+}
+
+func TestJSONBodyLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+	token, _, _ := s.generateToken("test-user-id")
+
+	serve := func(body, contentType string) int {
+		req := httptest.NewRequest("POST", "/auth/createProduct", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := serve("productName=Test", "application/x-www-form-urlencoded"); code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected 415 for a form, got %d", code)
+	}
+	large := `{"productName":"` + strings.Repeat("a", bodylimit.DefaultMaxBytes) + `"}`
+	if code := serve(large, "application/json"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large body, got %d", code)
+	}
+	if code := serve(`{"productName":"Test","units":1,"price":1}`, "application/json; charset=utf-8"); code != http.StatusCreated {
+		t.Errorf("Expected the product to be created, got %d", code)
+	}
+}
+
+func TestCreateProductRetry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+	token, _, _ := s.generateToken("test-user-id")
+
+	create := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/auth/createProduct", strings.NewReader(`{"productName":"Retried","units":1,"price":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := create("create-1")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected the product to be created, got %d %s", first.Code, first.Body)
+	}
+	retry := create("create-1")
+	if retry.Code != http.StatusCreated || retry.Header().Get(idempotency.ReplayedHeader) != "true" || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the first response to be replayed, got %d %s", retry.Code, retry.Body)
+	}
+	if w := create(""); w.Code != http.StatusConflict {
+		t.Errorf("Expected the product to exist once, got %d", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ProductInput is the payload to create or update a product. The ID, owner and any
+// other field are rejected, they are not the client's to set.
+type ProductInput struct {
+	ProductName string `json:"productName" binding:"required,max=200"`
+	// SKU is generated when empty
+	SKU          string                 `json:"sku" binding:"omitempty,max=64,sku"`
+	Barcode      string                 `json:"barcode" binding:"omitempty,max=64,barcode"`
+	Units        int                    `json:"units" binding:"gte=0"`
+	Price        float64                `json:"price" binding:"gt=0"`
+	UnitCost     float64                `json:"unitCost" binding:"gte=0"`
+	ReorderLevel int                    `json:"reorderLevel" binding:"gte=0"`
+	CategoryID   string                 `json:"categoryID"`
+	Tags         []string               `json:"tags" binding:"max=20,dive,max=50"`
+	Attributes   map[string]interface{} `json:"attributes" binding:"max=50"`
+}
+
+func (input ProductInput) item(orgID string) InventoryItem {
+	return InventoryItem{
+		OrgID:        orgID,
+		ProductName:  input.ProductName,
+		SKU:          strings.ToUpper(input.SKU),
+		Barcode:      input.Barcode,
+		Units:        input.Units,
+		Price:        input.Price,
+		UnitCost:     input.UnitCost,
+		ReorderLevel: input.ReorderLevel,
+		CategoryID:   input.CategoryID,
+		Tags:         normalizeTags(input.Tags),
+		Attributes:   input.Attributes,
+	}
+}
+
+// respondError writes err as problem details and stops the handler chain
+func respondError(c *gin.Context, err error) {
+	errs.Write(c.Writer, c.Request, err)
+	c.Abort()
+}
+
+func init() {
+	// report fields by their JSON names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+		v.RegisterValidation("sku", validSKU)
+		v.RegisterValidation("barcode", validBarcode)
+	}
+}
+
+// bindJSON decodes the request body into obj, rejecting unknown fields, and validates
+// it. Malformed JSON gets 400, unknown fields and failed validations get 422 with an
+// error per field, bodies cut off at the size limit get 413. It returns false when a
+// response was written.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	decoder := json.NewDecoder(c.Request.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(obj)
+
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		respondError(c, errs.Validation(map[string]string{field: "is not allowed"}))
+		return false
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		respondError(c, errs.Validation(map[string]string{typeErr.Field: "must be " + kindName(typeErr.Type.Kind())}))
+		return false
+	}
+	if tooLarge := bodylimit.Exceeded(err); tooLarge != nil {
+		respondError(c, tooLarge)
+		return false
+	}
+	if err != nil || decoder.Decode(&struct{}{}) != io.EOF {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid JSON format"))
+		return false
+	}
+
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			respondError(c, errs.New(errs.ErrValidation, err.Error()))
+			return false
+		}
+		fields := map[string]string{}
+		for _, fe := range fieldErrs {
+			fields[fe.Field()] = validationMessage(fe)
+		}
+		respondError(c, errs.Validation(fields))
+		return false
+	}
+	return true
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "max":
+		if fe.Kind() == reflect.String {
+			return "must be at most " + fe.Param() + " characters"
+		}
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "sku":
+		return "may only contain letters, digits, -, _ and ."
+	case "barcode":
+		return "must be a valid barcode"
+	}
+	return fmt.Sprintf("failed the %s check", fe.Tag())
+}
+
+func kindName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	}
+	return "a " + kind.String()
+}
@@ -0,0 +1,241 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"awesomeProject/platform/errs"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Warehouse is a location holding stock of an organization's products
+type Warehouse struct {
+	ID       string `json:"id,omitempty" bson:"_id,omitempty"`
+	OrgID    string `json:"orgID" bson:"orgID"`
+	Name     string `json:"name" bson:"name"`
+	Location string `json:"location,omitempty" bson:"location,omitempty"`
+}
+
+type WarehouseInput struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Location string `json:"location" binding:"max=200"`
+}
+
+// StockLevel is the stock of a product held in a warehouse
+type StockLevel struct {
+	ProductID   string `json:"productID" bson:"productID"`
+	WarehouseID string `json:"warehouseID" bson:"warehouseID"`
+	OrgID       string `json:"orgID" bson:"orgID"`
+	Units       int    `json:"units" bson:"units"`
+}
+
+// StockTransfer moves units of a product from one warehouse to another, the total
+// stock of the product doesn't change
+type StockTransfer struct {
+	ID              string    `json:"id,omitempty" bson:"_id,omitempty"`
+	OrgID           string    `json:"orgID" bson:"orgID"`
+	ProductID       string    `json:"productID" bson:"productID"`
+	FromWarehouseID string    `json:"fromWarehouseID" bson:"fromWarehouseID"`
+	ToWarehouseID   string    `json:"toWarehouseID" bson:"toWarehouseID"`
+	Quantity        int       `json:"quantity" bson:"quantity"`
+	Note            string    `json:"note,omitempty" bson:"note,omitempty"`
+	CreatedAt       time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+type TransferInput struct {
+	ProductID       string `json:"productID" binding:"required"`
+	FromWarehouseID string `json:"fromWarehouseID" binding:"required"`
+	ToWarehouseID   string `json:"toWarehouseID" binding:"required,nefield=FromWarehouseID"`
+	Quantity        int    `json:"quantity" binding:"gt=0"`
+	Note            string `json:"note" binding:"max=500"`
+}
+
+// ProductStock is the stock of a product across warehouses. Units is the product's
+// total stock, Allocated the part held in warehouses and Unallocated the rest.
+type ProductStock struct {
+	ProductID   string           `json:"productID" bson:"_id"`
+	ProductName string           `json:"productName" bson:"productName"`
+	Units       int              `json:"units" bson:"units"`
+	Allocated   int              `json:"allocated" bson:"allocated"`
+	Unallocated int              `json:"unallocated" bson:"unallocated"`
+	Warehouses  []WarehouseStock `json:"warehouses" bson:"warehouses"`
+}
+
+type WarehouseStock struct {
+	WarehouseID   string `json:"warehouseID" bson:"warehouseID"`
+	WarehouseName string `json:"warehouseName" bson:"warehouseName"`
+	Units         int    `json:"units" bson:"units"`
+}
+
+// WarehouseRepository stores warehouses and how the stock of products is split across
+// them. Stock movements with a warehouse adjust its stock level along with the
+// product's units, movements without one only use unallocated stock.
+type WarehouseRepository interface {
+	// CreateWarehouse stores a new warehouse and sets its ID
+	CreateWarehouse(ctx context.Context, warehouse *Warehouse) error
+	// FindWarehouse returns ErrNotFound for unknown warehouses, it doesn't check the owner
+	FindWarehouse(ctx context.Context, id string) (*Warehouse, error)
+	ListWarehouses(ctx context.Context, orgID string) ([]Warehouse, error)
+	// WarehouseStock returns the stock levels of a warehouse
+	WarehouseStock(ctx context.Context, orgID, warehouseID string) ([]StockLevel, error)
+	// Transfer moves stock between warehouses atomically, ErrInsufficientStock when the
+	// source warehouse holds less than the quantity
+	Transfer(ctx context.Context, transfer *StockTransfer) error
+	// StockTotals aggregates the stock per product across warehouses, ordered by product
+	// name. With a productID only that product is returned, if it has allocated stock.
+	StockTotals(ctx context.Context, orgID, productID string) ([]ProductStock, error)
+}
+
+func (s *Server) createWarehouse(c *gin.Context) {
+	var input WarehouseInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	orgID := c.GetString("org")
+
+	warehouses, err := s.Warehouses.ListWarehouses(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching warehouses"))
+		return
+	}
+	for _, warehouse := range warehouses {
+		if warehouse.Name == input.Name {
+			respondError(c, errs.New(errs.ErrConflict, "Warehouse with this name already exists in this organization"))
+			return
+		}
+	}
+
+	warehouse := Warehouse{OrgID: orgID, Name: input.Name, Location: input.Location}
+	if err := s.Warehouses.CreateWarehouse(c.Request.Context(), &warehouse); err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to create warehouse"))
+		return
+	}
+	s.audit(c, AuditEntry{Entity: auditWarehouse, Action: auditCreate, ItemID: warehouse.ID}, nil, warehouse)
+	c.JSON(http.StatusCreated, warehouse)
+}
+
+func (s *Server) getWarehouses(c *gin.Context) {
+	warehouses, err := s.Warehouses.ListWarehouses(c.Request.Context(), c.GetString("org"))
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching warehouses"))
+		return
+	}
+	c.JSON(http.StatusOK, warehouses)
+}
+
+// ownedWarehouse loads a warehouse and checks that it belongs to the authenticated
+// user. It writes the error response and returns false otherwise.
+func (s *Server) ownedWarehouse(c *gin.Context, id string) (*Warehouse, bool) {
+	if !primitive.IsValidObjectID(id) {
+		respondError(c, errs.New(errs.ErrInvalid, "Invalid warehouse ID format"))
+		return nil, false
+	}
+
+	warehouse, err := s.Warehouses.FindWarehouse(c.Request.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		respondError(c, errs.New(errs.ErrNotFound, "Warehouse not found"))
+		return nil, false
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching warehouse"))
+		return nil, false
+	}
+	if warehouse.OrgID != c.GetString("org") {
+		respondError(c, errs.New(errs.ErrForbidden, "Warehouse belongs to another organization"))
+		return nil, false
+	}
+	return warehouse, true
+}
+
+func (s *Server) getWarehouseStock(c *gin.Context) {
+	warehouse, ok := s.ownedWarehouse(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	levels, err := s.Warehouses.WarehouseStock(c.Request.Context(), warehouse.OrgID, warehouse.ID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching stock levels"))
+		return
+	}
+	c.JSON(http.StatusOK, levels)
+}
+
+func (s *Server) transferStock(c *gin.Context) {
+	var input TransferInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	product, ok := s.ownedProduct(c, input.ProductID)
+	if !ok {
+		return
+	}
+	for _, id := range []string{input.FromWarehouseID, input.ToWarehouseID} {
+		if _, ok := s.ownedWarehouse(c, id); !ok {
+			return
+		}
+	}
+
+	transfer := StockTransfer{
+		OrgID:           product.OrgID,
+		ProductID:       product.ID,
+		FromWarehouseID: input.FromWarehouseID,
+		ToWarehouseID:   input.ToWarehouseID,
+		Quantity:        input.Quantity,
+		Note:            input.Note,
+		CreatedAt:       time.Now().UTC(),
+	}
+	err := s.Warehouses.Transfer(c.Request.Context(), &transfer)
+	if errors.Is(err, ErrInsufficientStock) {
+		respondError(c, errs.New(errs.ErrConflict, "Insufficient stock in source warehouse"))
+		return
+	}
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Failed to transfer stock"))
+		return
+	}
+	s.audit(c, AuditEntry{Entity: auditProduct, Action: auditTransfer, ItemID: product.ID, Reference: transfer.ID}, nil, transfer)
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// getProductStock returns the stock of a product per warehouse
+func (s *Server) getProductStock(c *gin.Context) {
+	product, ok := s.findOwnedProduct(c)
+	if !ok {
+		return
+	}
+
+	totals, err := s.Warehouses.StockTotals(c.Request.Context(), product.OrgID, product.ID)
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching stock levels"))
+		return
+	}
+	if len(totals) == 0 {
+		c.JSON(http.StatusOK, ProductStock{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Units:       product.Units,
+			Unallocated: product.Units,
+			Warehouses:  []WarehouseStock{},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, totals[0])
+}
+
+// getStockTotals returns the stock of every product with allocated stock per warehouse
+func (s *Server) getStockTotals(c *gin.Context) {
+	totals, err := s.Warehouses.StockTotals(c.Request.Context(), c.GetString("org"), "")
+	if err != nil {
+		respondError(c, errs.New(errs.ErrInternal, "Error fetching stock totals"))
+		return
+	}
+	c.JSON(http.StatusOK, totals)
+}
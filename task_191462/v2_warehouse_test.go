@@ -0,0 +1,132 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWarehouseStock(t *testing.T) {
+	// Set Gin to Test Mode
+	gin.SetMode(gin.TestMode)
+	s := newTestServer()
+	r := gin.New()
+	s.setupRoutes(r)
+
+	product := InventoryItem{OrgID: "owner", ProductName: "Widget", Units: 5, Price: 2}
+	s.Inventory.Create(context.Background(), &product)
+	north := Warehouse{OrgID: "owner", Name: "North"}
+	south := Warehouse{OrgID: "owner", Name: "South"}
+	foreign := Warehouse{OrgID: "someone-else", Name: "Elsewhere"}
+	for _, w := range []*Warehouse{&north, &south, &foreign} {
+		s.Warehouses.CreateWarehouse(context.Background(), w)
+	}
+	transfer := func(from, to string, quantity int) string {
+		body, _ := json.Marshal(TransferInput{ProductID: product.ID, FromWarehouseID: from, ToWarehouseID: to, Quantity: quantity})
+		return string(body)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Duplicate Warehouse Name",
+			method:         "POST",
+			path:           "/auth/warehouses",
+			body:           `{"name":"North"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Purchase Into Warehouse",
+			method:         "POST",
+			path:           "/auth/products/" + product.ID + "/movements",
+			body:           `{"quantity":10,"reason":"purchase","warehouseID":"` + north.ID + `"}`,
+			expectedStatus: 201,
+		},
+		{
+			name:           "Transfer Between Warehouses",
+			method:         "POST",
+			path:           "/auth/transfers",
+			body:           transfer(north.ID, south.ID, 4),
+			expectedStatus: 201,
+		},
+		{
+			name:           "Transfer More Than Held",
+			method:         "POST",
+			path:           "/auth/transfers",
+			body:           transfer(north.ID, south.ID, 7),
+			expectedStatus: 409,
+		},
+		{
+			name:           "Transfer To The Same Warehouse",
+			method:         "POST",
+			path:           "/auth/transfers",
+			body:           transfer(north.ID, north.ID, 1),
+			expectedStatus: 422,
+		},
+		{
+			name:           "Transfer To Another User's Warehouse",
+			method:         "POST",
+			path:           "/auth/transfers",
+			body:           transfer(north.ID, foreign.ID, 1),
+			expectedStatus: 403,
+		},
+		{
+			name:           "Sale Beyond Unallocated Stock",
+			method:         "POST",
+			path:           "/auth/products/" + product.ID + "/movements",
+			body:           `{"quantity":-6,"reason":"sale"}`,
+			expectedStatus: 409,
+		},
+		{
+			name:           "Sale From Warehouse",
+			method:         "POST",
+			path:           "/auth/products/" + product.ID + "/movements",
+			body:           `{"quantity":-1,"reason":"sale","warehouseID":"` + south.ID + `"}`,
+			expectedStatus: 201,
+		},
+	}
+
+	token, _, _ := s.generateToken("owner")
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.path, tt.body)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	var stock ProductStock
+	json.Unmarshal(do("GET", "/auth/products/"+product.ID+"/stock", "").Body.Bytes(), &stock)
+	if stock.Units != 14 || stock.Allocated != 9 || stock.Unallocated != 5 || len(stock.Warehouses) != 2 ||
+		stock.Warehouses[0].WarehouseName != "North" || stock.Warehouses[0].Units != 6 || stock.Warehouses[1].Units != 3 {
+		t.Errorf("Unexpected product stock: %+v", stock)
+	}
+
+	var totals []ProductStock
+	json.Unmarshal(do("GET", "/auth/stock", "").Body.Bytes(), &totals)
+	if len(totals) != 1 || totals[0].Allocated != 9 {
+		t.Errorf("Unexpected stock totals: %+v", totals)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/platform/logging"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cookieKey signs the session and favorites cookies. It is set from the cookie.secret
+// setting, a random key signs everybody out and loses the favorites on restart.
+var cookieKey = randomKey()
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func signCookie(payload string) string {
+	mac := hmac.New(sha256.New, cookieKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// credentials are the account allowed to manage the products
+type credentials struct {
+	Username string
+	// PasswordHash is a bcrypt hash. Without one nobody can sign in.
+	PasswordHash []byte
+}
+
+// adminCredentials are set from the admin.username and admin.password.hash settings
+var adminCredentials = credentials{Username: "admin"}
+
+func (c credentials) check(username, password string) bool {
+	if len(c.PasswordHash) == 0 {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(c.Username)) == 1
+	passwordOK := bcrypt.CompareHashAndPassword(c.PasswordHash, []byte(password)) == nil
+	return userOK && passwordOK
+}
+
+const (
+	sessionCookie = "session"
+	sessionTTL    = 12 * time.Hour
+)
+
+// startSession signs the user in with a cookie holding the user name and the expiry
+func startSession(w http.ResponseWriter, username string) {
+	expires := time.Now().Add(sessionTTL)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(username + "|" + strconv.FormatInt(expires.Unix(), 10)))
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    payload + "." + signCookie(payload),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func endSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+}
+
+// sessionUser returns the signed in user, if the session cookie is valid and current
+func sessionUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	payload, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCookie(payload))) {
+		return "", false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+	username, expiresStr, ok := strings.Cut(string(data), "|")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if !ok || err != nil || time.Now().Unix() >= expires {
+		return "", false
+	}
+	return username, true
+}
+
+// isAdmin reports whether the request comes from the signed in administrator
+func isAdmin(r *http.Request) bool {
+	_, ok := sessionUser(r)
+	return ok
+}
+
+// requireAdmin sends visitors who aren't signed in to the login page, which brings
+// them back afterwards
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, ok := sessionUser(r)
+		if !ok {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		logging.WithUserID(r.Context(), username)
+		next(w, r)
+	}
+}
+
+// LoginViewModel is the model of the login page
+type LoginViewModel struct {
+	Username string
+	Next     string
+	Error    string
+}
+
+// safeNext returns next if it is a path of this site, so the login can't redirect to
+// another one
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// Handlers
+
+// loginLimit bounds the requests per client address and minute to the sign-in page,
+// against password guessing
+const loginLimit = 10
+
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+	if r.Method != http.MethodPost {
+		render(w, r, "login.html", LoginViewModel{Next: next})
+		return
+	}
+
+	username := r.FormValue("username")
+	if !adminCredentials.check(username, r.FormValue("password")) {
+		w.WriteHeader(http.StatusUnauthorized)
+		render(w, r, "login.html", LoginViewModel{Username: username, Next: next, Error: "error.invalid_credentials"})
+		return
+	}
+	startSession(w, username)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	endSession(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// signedIn adds the session of the administrator to req
+func signedIn(req *http.Request) *http.Request {
+	w := testkit.Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startSession(w, "admin")
+	}), testkit.NewRequest("GET", "/", nil))
+	req.AddCookie(w.Result().Cookies()[0])
+	return req
+}
+
+func withAdmin(t *testing.T, password string) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := adminCredentials
+	adminCredentials = credentials{Username: "admin", PasswordHash: hash}
+	t.Cleanup(func() { adminCredentials = saved })
+}
+
+func TestProtectedRoutes(t *testing.T) {
+	for _, target := range []string{"/create", "/edit/1", "/delete/1", "/stock/1/increment"} {
+		w := testkit.Serve(routes(), testkit.NewRequest("GET", target, nil))
+		if err := testkit.ExpectStatus(w, http.StatusSeeOther); err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+		if want := "/login?next=" + url.QueryEscape(target); w.Header().Get("Location") != want {
+			t.Errorf("%s: expected to be sent to %s, got %s", target, want, w.Header().Get("Location"))
+		}
+	}
+	for _, req := range []*http.Request{
+		testkit.FormRequest("POST", "/store", url.Values{"name": {"Laptop"}, "price": {"10"}}),
+		testkit.FormRequest("POST", "/update/1", url.Values{"name": {"Laptop"}, "price": {"10"}}),
+	} {
+		if w := testkit.Serve(routes(), req); !strings.HasPrefix(w.Header().Get("Location"), "/login") {
+			t.Errorf("%s: expected to be sent to the login, got %d %s", req.URL.Path, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	w := testkit.Serve(routes(), signedIn(testkit.NewRequest("GET", "/create", nil)))
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	withAdmin(t, "s3cret")
+	client := testkit.NewClient(routes())
+
+	w := client.Do(testkit.FormRequest("POST", "/login", url.Values{"username": {"admin"}, "password": {"wrong"}, "next": {"/create"}}))
+	if err := testkit.ExpectStatus(w, http.StatusUnauthorized); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.Body.String(), "Invalid username or password") {
+		t.Errorf("Expected the error on the login page, got %s", w.Body.String())
+	}
+
+	w = client.Do(testkit.FormRequest("POST", "/login", url.Values{"username": {"admin"}, "password": {"s3cret"}, "next": {"/create"}}))
+	if err := testkit.ExpectStatus(w, http.StatusSeeOther); err != nil || w.Header().Get("Location") != "/create" {
+		t.Fatalf("Expected to go on to the page asked for, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/create", nil)), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+
+	client.Do(testkit.NewRequest("POST", "/logout", nil))
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/create", nil)), http.StatusSeeOther); err != nil {
+		t.Errorf("Expected the logout to end the session: %v", err)
+	}
+}
+
+func TestLoginWithoutPasswordHash(t *testing.T) {
+	saved := adminCredentials
+	adminCredentials = credentials{Username: "admin"}
+	defer func() { adminCredentials = saved }()
+
+	if adminCredentials.check("admin", "") {
+		t.Error("Expected nobody to sign in without a password hash")
+	}
+}
+
+func TestSession(t *testing.T) {
+	if _, ok := sessionUser(signedIn(testkit.NewRequest("GET", "/", nil))); !ok {
+		t.Fatal("Expected a valid session")
+	}
+
+	expired := base64.RawURLEncoding.EncodeToString([]byte("admin|" + strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)))
+	req := testkit.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: expired + "." + signCookie(expired)})
+	if _, ok := sessionUser(req); ok {
+		t.Error("Expected an expired session to be rejected")
+	}
+
+	forged := base64.RawURLEncoding.EncodeToString([]byte("admin|" + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)))
+	req = testkit.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookie, Value: forged + ".bogus"})
+	if _, ok := sessionUser(req); ok {
+		t.Error("Expected a forged session to be rejected")
+	}
+}
+
+func TestDeleteRequiresPost(t *testing.T) {
+	mock := setupTestDB(t)
+
+	// a link from another site brings the session cookie along
+	w := testkit.Serve(routes(), signedIn(testkit.NewRequest("GET", "/delete/1", nil)))
+	if err := testkit.ExpectStatus(w, http.StatusMethodNotAllowed); err != nil {
+		t.Error(err)
+	}
+
+	mock.ExpectExec("DELETE FROM products WHERE id = ?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	w = testkit.Serve(routes(), signedIn(testkit.NewRequest("POST", "/delete/1", nil)))
+	if err := testkit.ExpectStatus(w, http.StatusSeeOther); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoginRateLimit(t *testing.T) {
+	h := routes()
+	for i := 0; i < loginLimit; i++ {
+		testkit.Serve(h, testkit.FormRequest("POST", "/login", url.Values{"username": {"admin"}, "password": {"guess" + strconv.Itoa(i)}}))
+	}
+	w := testkit.Serve(h, testkit.FormRequest("POST", "/login", url.Values{"username": {"admin"}, "password": {"s3cret"}}))
+	if err := testkit.ExpectStatus(w, http.StatusTooManyRequests); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSafeNext(t *testing.T) {
+	tests := map[string]string{
+		"/edit/1":             "/edit/1",
+		"":                    "/",
+		"https://example.com": "/",
+		"//example.com":       "/",
+		"/\\example.com":      "/",
+	}
+	for next, want := range tests {
+		if got := safeNext(next); got != want {
+			t.Errorf("safeNext(%q): expected %q, got %q", next, want, got)
+		}
+	}
+}
+
+func TestIndexHidesManagementFromVisitors(t *testing.T) {
+	mock := setupTestDB(t)
+	query := "SELECT id, name, description, price, stock, category FROM products"
+	mock.ExpectQuery(query).WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 2, "Computers"}))
+	mock.ExpectQuery(query).WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 2, "Computers"}))
+
+	body := testkit.Serve(routes(), testkit.NewRequest("GET", "/", nil)).Body.String()
+	if strings.Contains(body, "/edit/1") || strings.Contains(body, `href="/create"`) || !strings.Contains(body, `href="/login"`) {
+		t.Errorf("Expected visitors to see no management actions, got %s", body)
+	}
+	body = testkit.Serve(routes(), signedIn(testkit.NewRequest("GET", "/", nil))).Body.String()
+	if !strings.Contains(body, "/edit/1") || !strings.Contains(body, "/stock/1/decrement") || !strings.Contains(body, `action="/logout"`) {
+		t.Errorf("Expected the administrator to see the management actions, got %s", body)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/idempotency"
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+var errDuplicateName = &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'laptop' for key 'products.products_name_lower'"}
+
+func TestStoreRejectsDuplicateName(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectExec("INSERT INTO products (name, description, price, stock, category) VALUES (?, ?, ?, ?, ?)").
+		WithArgs("LAPTOP", "Fast", 999.0, 3, "").WillReturnError(errDuplicateName)
+
+	form := url.Values{"name": {"LAPTOP"}, "description": {"Fast"}, "price": {"999"}, "stock": {"3"}}
+	w := testkit.Serve(routes(), signedIn(testkit.FormRequest("POST", "/store", form)))
+	if err := testkit.ExpectStatus(w, http.StatusConflict); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "A product with this name already exists") || !strings.Contains(body, `value="LAPTOP"`) {
+		t.Errorf("Expected the form with the error and the values, got %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateRejectsDuplicateName(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectExec("UPDATE products SET name = ?, description = ?, price = ?, category = ? WHERE id = ?").
+		WithArgs("Mouse", "Renamed", 5.0, "", 1).WillReturnError(errDuplicateName)
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id = ?").WithArgs(1).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 6, "Computers"}))
+
+	form := url.Values{"name": {"Mouse"}, "description": {"Renamed"}, "price": {"5"}}
+	req := signedIn(testkit.FormRequest("POST", "/update/1", form))
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "de"})
+	w := testkit.Serve(routes(), req)
+	if err := testkit.ExpectStatus(w, http.StatusConflict); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Ein Produkt mit diesem Namen gibt es bereits") || !strings.Contains(body, `value="Mouse"`) || !strings.Contains(body, ": 6") {
+		t.Errorf("Expected the translated error, the submitted values and the stock, got %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreReportsOtherErrors(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectExec("INSERT INTO products (name, description, price, stock, category) VALUES (?, ?, ?, ?, ?)").WillReturnError(sqlmock.ErrCancelled)
+
+	form := url.Values{"name": {"Laptop"}, "price": {"999"}}
+	w := testkit.Serve(routes(), signedIn(testkit.FormRequest("POST", "/store", form)))
+	if err := testkit.ExpectStatus(w, http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStoreRetry(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectExec("INSERT INTO products (name, description, price, stock, category) VALUES (?, ?, ?, ?, ?)").
+		WithArgs("Laptop", "Fast", 999.0, 3, "").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	h := routes()
+	form := url.Values{"name": {"Laptop"}, "description": {"Fast"}, "price": {"999"}, "stock": {"3"}}
+	store := func() *httptest.ResponseRecorder {
+		req := signedIn(testkit.FormRequest("POST", "/store", form))
+		req.Header.Set(idempotency.Header, "store-1")
+		return testkit.Serve(h, req)
+	}
+	if err := testkit.ExpectStatus(store(), http.StatusSeeOther); err != nil {
+		t.Fatal(err)
+	}
+	retry := store()
+	if err := testkit.ExpectStatus(retry, http.StatusSeeOther); err != nil || retry.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Errorf("Expected the redirect to be replayed without a second insert: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
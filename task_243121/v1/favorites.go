@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"awesomeProject/platform/errs"
+)
+
+// Favorites are kept in a cookie signed with cookieKey, so visitors can't slip
+// arbitrary values in, and no server side storage is needed
+const (
+	favoritesCookie = "favorites"
+	favoritesMaxAge = 365 * 24 * time.Hour
+	// maxFavorites bounds the cookie size
+	maxFavorites = 200
+)
+
+// readFavorites returns the product IDs of the visitor's favorites. A missing, tampered
+// or malformed cookie holds none.
+func readFavorites(r *http.Request) []int {
+	return readIDCookie(r, favoritesCookie)
+}
+
+func writeFavorites(w http.ResponseWriter, ids []int) {
+	writeIDCookie(w, favoritesCookie, ids, favoritesMaxAge)
+}
+
+// readIDCookie returns the product IDs in the signed cookie name, none if it is
+// missing, tampered or malformed
+func readIDCookie(r *http.Request, name string) []int {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	payload, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCookie(payload))) {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	var ids []int
+	for _, s := range strings.Split(string(data), ",") {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// writeIDCookie stores ids in the signed cookie name, a maxAge of 0 keeps it for the
+// browser session
+func writeIDCookie(w http.ResponseWriter, name string, ids []int, maxAge time.Duration) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ",")))
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    payload + "." + signCookie(payload),
+		Path:     "/",
+		MaxAge:   int(maxAge / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// addFavorite adds id to the favorites, most recent first
+func addFavorite(ids []int, id int) []int {
+	return prependID(ids, id, maxFavorites)
+}
+
+// prependID moves id to the front of ids, dropping the last ones beyond max
+func prependID(ids []int, id int, max int) []int {
+	ids = append([]int{id}, slices.DeleteFunc(slices.Clone(ids), func(v int) bool { return v == id })...)
+	if len(ids) > max {
+		ids = ids[:max]
+	}
+	return ids
+}
+
+func removeFavorite(ids []int, id int) []int {
+	return slices.DeleteFunc(slices.Clone(ids), func(v int) bool { return v == id })
+}
+
+// favoriteSet returns the favorites for the templates to look products up in
+func favoriteSet(ids []int) map[int]bool {
+	set := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// Handlers
+
+// favoritesHandler shows the favorite products. Favorites of deleted products are
+// left out.
+func favoritesHandler(w http.ResponseWriter, r *http.Request) {
+	ids := readFavorites(r)
+	products, err := getProductsByID(r.Context(), ids)
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+
+	viewModel := ProductViewModel{Products: products, Favorites: favoriteSet(ids), FavoriteCount: len(products)}
+	render(w, r, "favorites.html", viewModel)
+}
+
+// toggleFavoriteHandler serves the forms of the pages, /favorites/add/{id} and
+// /favorites/remove/{id}, and redirects back to the page the form was on
+func toggleFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	action, idStr, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/favorites/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		errs.Write(w, r, errInvalidProductID)
+		return
+	}
+
+	switch action {
+	case "add":
+		writeFavorites(w, addFavorite(readFavorites(r), id))
+	case "remove":
+		writeFavorites(w, removeFavorite(readFavorites(r), id))
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	redirect := "/"
+	switch r.FormValue("back") {
+	case "favorites":
+		redirect = "/favorites"
+	case "product":
+		redirect = "/products/" + strconv.Itoa(id)
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
+
+// favoritesResponse is the JSON representation of the favorites
+type favoritesResponse struct {
+	IDs      []int     `json:"ids"`
+	Count    int       `json:"count"`
+	Products []Product `json:"products,omitempty"`
+}
+
+// apiFavoritesHandler serves the JSON API:
+//
+//	GET    /api/favorites       lists the favorite products
+//	PUT    /api/favorites/{id}  adds a favorite
+//	DELETE /api/favorites/{id}  removes a favorite
+//
+// Adding a product that doesn't exist fails with 404.
+func apiFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	ids := readFavorites(r)
+	idStr := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/favorites"), "/")
+
+	if idStr == "" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		products, err := getProductsByID(r.Context(), ids)
+		if err != nil {
+			errs.Write(w, r, err)
+			return
+		}
+		writeFavoritesJSON(w, ids, products)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		errs.Write(w, r, errInvalidProductID)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if _, err := getProduct(r.Context(), id); err != nil {
+			errs.Write(w, r, err)
+			return
+		}
+		ids = addFavorite(ids, id)
+	case http.MethodDelete:
+		ids = removeFavorite(ids, id)
+	default:
+		methodNotAllowed(w, http.MethodPut, http.MethodDelete)
+		return
+	}
+	writeFavorites(w, ids)
+	writeFavoritesJSON(w, ids, nil)
+}
+
+func writeFavoritesJSON(w http.ResponseWriter, ids []int, products []Product) {
+	if ids == nil {
+		ids = []int{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favoritesResponse{IDs: ids, Count: len(ids), Products: products})
+}
+
+func methodNotAllowed(w http.ResponseWriter, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// Database functions
+
+// getProductsByID returns the products with the IDs in the order of the IDs, skipping
+// the ones that don't exist
+func getProductsByID(ctx context.Context, ids []int) ([]Product, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = db.Dialect().Placeholder(i + 1)
+		args[i] = id
+	}
+	rows, err := db.QueryContext(ctx, "SELECT "+productColumns+" FROM products WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int]Product, len(ids))
+	for rows.Next() {
+		var p Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		byID[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var products []Product
+	for _, id := range ids {
+		if p, ok := byID[id]; ok {
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
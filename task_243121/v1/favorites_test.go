@@ -0,0 +1,155 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var productColumnNames = []string{"id", "name", "description", "price", "stock", "category"}
+
+func TestFavoritesCookie(t *testing.T) {
+	ids := addFavorite(addFavorite(addFavorite(nil, 3), 7), 3)
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 7 {
+		t.Fatalf("Expected the latest favorite first without duplicates, got %v", ids)
+	}
+
+	w := testkit.Serve(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeFavorites(w, ids)
+	}), testkit.NewRequest("GET", "/", nil))
+	cookie := w.Result().Cookies()[0]
+
+	req := testkit.NewRequest("GET", "/", nil)
+	req.AddCookie(cookie)
+	if got := readFavorites(req); len(got) != 2 || got[0] != 3 || got[1] != 7 {
+		t.Errorf("Expected the favorites back, got %v", got)
+	}
+
+	payload, _, _ := strings.Cut(cookie.Value, ".")
+	tampered := testkit.NewRequest("GET", "/", nil)
+	tampered.AddCookie(&http.Cookie{Name: favoritesCookie, Value: payload + "." + signCookie("MSwyLDM")})
+	if got := readFavorites(tampered); got != nil {
+		t.Errorf("Expected a tampered cookie to be ignored, got %v", got)
+	}
+}
+
+func TestFavoritesPages(t *testing.T) {
+	mock := setupTestDB(t)
+	client := testkit.NewClient(routes())
+
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("POST", "/favorites/add/2", nil)), http.StatusSeeOther); err != nil {
+		t.Fatal(err)
+	}
+	client.Do(testkit.NewRequest("POST", "/favorites/add/1", nil))
+	w := client.Do(testkit.NewRequest("POST", "/favorites/remove/1?back=favorites", nil))
+	if location := w.Header().Get("Location"); location != "/favorites" {
+		t.Errorf("Expected to go back to the favorites, got %q", location)
+	}
+	client.Do(testkit.NewRequest("POST", "/favorites/add/5", nil))
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id IN (?, ?)").WithArgs(5, 2).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{2, "Mouse", "Wireless", 19.5, 3, "Computers"}))
+	w = client.Do(testkit.NewRequest("GET", "/favorites", nil))
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, "Mouse") || !strings.Contains(body, `badge-secondary">1<`) {
+		t.Errorf("Expected the remaining favorite and its count, got %s", body)
+	}
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products").
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 0, "Computers"}, []driver.Value{2, "Mouse", "Wireless", 19.5, 3, "Computers"}))
+	body = client.Do(testkit.NewRequest("GET", "/", nil)).Body.String()
+	if !strings.Contains(body, `action="/favorites/remove/2"`) || !strings.Contains(body, `action="/favorites/add/1"`) {
+		t.Errorf("Expected the favorite to be starred, got %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFavoritesAPI(t *testing.T) {
+	mock := setupTestDB(t)
+	client := testkit.NewClient(routes())
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id = ?").WithArgs(4).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{4, "Desk", "Oak", 250.0, 1, "Furniture"}))
+	w := client.Do(testkit.NewRequest("PUT", "/api/favorites/4", nil))
+	var got favoritesResponse
+	if err := testkit.DecodeJSON(w, &got); err != nil || got.Count != 1 || got.IDs[0] != 4 {
+		t.Fatalf("Expected the favorite to be added, got %+v (%v)", got, err)
+	}
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id = ?").WithArgs(9).WillReturnError(sqlmock.ErrCancelled)
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id = ?").WithArgs(8).
+		WillReturnRows(testkit.Rows(productColumnNames))
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("PUT", "/api/favorites/9", nil)), http.StatusInternalServerError); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("PUT", "/api/favorites/8", nil)), http.StatusNotFound); err != nil {
+		t.Error(err)
+	}
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id IN (?)").WithArgs(4).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{4, "Desk", "Oak", 250.0, 1, "Furniture"}))
+	w = client.Do(testkit.NewRequest("GET", "/api/favorites", nil))
+	got = favoritesResponse{}
+	if err := testkit.DecodeJSON(w, &got); err != nil || len(got.Products) != 1 || got.Products[0].Name != "Desk" {
+		t.Errorf("Expected the favorite product, got %+v (%v)", got, err)
+	}
+
+	w = client.Do(testkit.NewRequest("DELETE", "/api/favorites/4", nil))
+	got = favoritesResponse{}
+	if err := testkit.DecodeJSON(w, &got); err != nil || got.Count != 0 || got.IDs == nil {
+		t.Errorf("Expected no favorites left, got %+v (%v)", got, err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("POST", "/api/favorites", nil)), http.StatusMethodNotAllowed); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	setupTestDB(t)
+
+	form := url.Values{"id": {"4"}}
+	if err := testkit.ExpectStatus(testkit.Serve(routes(), testkit.FormRequest("PUT", "/api/favorites/4", form)), http.StatusUnsupportedMediaType); err != nil {
+		t.Error(err)
+	}
+	form = url.Values{"name": {"Laptop"}, "description": {strings.Repeat("a", bodylimit.DefaultMaxBytes)}, "price": {"999"}}
+	if err := testkit.ExpectStatus(testkit.Serve(routes(), signedIn(testkit.FormRequest("POST", "/store", form))), http.StatusRequestEntityTooLarge); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFavoritesAPICORS(t *testing.T) {
+	apiOrigins = []string{"https://shop.example.com"}
+	t.Cleanup(func() { apiOrigins = nil })
+	h := routes()
+
+	preflight := func(origin string) *http.Request {
+		req := testkit.NewRequest("OPTIONS", "/api/favorites/3", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		return req
+	}
+	w := testkit.Serve(h, preflight("https://shop.example.com"))
+	if err := testkit.ExpectStatus(w, http.StatusNoContent); err != nil {
+		t.Error(err)
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://shop.example.com" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("Expected the origin to be allowed with its cookies, got %v", w.Header())
+	}
+	if err := testkit.ExpectStatus(testkit.Serve(h, preflight("https://evil.test")), http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,193 @@
+// Package i18n translates the product pages. Catalogs are JSON files named after their
+// language, like de.json, holding the messages and the number and date formats:
+//
+//	{
+//	  "name": "Deutsch",
+//	  "format": {"decimal": ",", "group": ".", "currency": "{amount} €", "date": "02.01.2006"},
+//	  "messages": {"products.title": "Produkte", "products.count": "%d Produkte"}
+//	}
+//
+// Messages are fmt formats. A message missing from a catalog falls back to the
+// default language and then to its key.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format holds the conventions of a language for numbers and dates
+type Format struct {
+	Decimal string `json:"decimal"`
+	Group   string `json:"group"`
+	// Currency places the formatted amount, like "${amount}"
+	Currency string `json:"currency"`
+	// Date is a time layout, like "Jan 2, 2006"
+	Date string `json:"date"`
+}
+
+// Catalog holds the messages of a language
+type Catalog struct {
+	Lang     string            `json:"-"`
+	Name     string            `json:"name"`
+	Format   Format            `json:"format"`
+	Messages map[string]string `json:"messages"`
+}
+
+// Bundle holds the catalogs of the supported languages
+type Bundle struct {
+	catalogs map[string]*Catalog
+	fallback string
+}
+
+// Load reads the catalogs in the root of fsys. fallback is the default language, its
+// catalog must be among them.
+func Load(fsys fs.FS, fallback string) (*Bundle, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{catalogs: make(map[string]*Catalog), fallback: fallback}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		c := &Catalog{Lang: strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))}
+		if err := json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", file, err)
+		}
+		b.catalogs[c.Lang] = c
+	}
+	if b.catalogs[fallback] == nil {
+		return nil, fmt.Errorf("i18n: no catalog for the default language %q", fallback)
+	}
+	return b, nil
+}
+
+// Languages returns the supported languages, sorted
+func (b *Bundle) Languages() []*Catalog {
+	catalogs := make([]*Catalog, 0, len(b.catalogs))
+	for _, c := range b.catalogs {
+		catalogs = append(catalogs, c)
+	}
+	slices.SortFunc(catalogs, func(a, b *Catalog) int { return strings.Compare(a.Lang, b.Lang) })
+	return catalogs
+}
+
+// Supports reports whether there is a catalog for lang
+func (b *Bundle) Supports(lang string) bool {
+	return b.catalogs[strings.ToLower(lang)] != nil
+}
+
+// Localizer returns the localizer of lang, the default language's if it isn't supported
+func (b *Bundle) Localizer(lang string) *Localizer {
+	c := b.catalogs[strings.ToLower(lang)]
+	if c == nil {
+		c = b.catalogs[b.fallback]
+	}
+	return &Localizer{bundle: b, catalog: c, fallback: b.catalogs[b.fallback]}
+}
+
+// Localizer translates and formats for a language
+type Localizer struct {
+	bundle   *Bundle
+	catalog  *Catalog
+	fallback *Catalog
+}
+
+// Lang returns the language of the localizer
+func (l *Localizer) Lang() string {
+	return l.catalog.Lang
+}
+
+// T returns the message of key formatted with args
+func (l *Localizer) T(key string, args ...any) string {
+	message, ok := l.catalog.Messages[key]
+	if !ok {
+		if message, ok = l.fallback.Messages[key]; !ok {
+			message = key
+		}
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// Number formats v with two decimals and grouped thousands
+func (l *Localizer) Number(v float64) string {
+	f := l.format()
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(f.Group)
+		}
+		b.WriteRune(digit)
+	}
+	return sign + b.String() + f.Decimal + frac
+}
+
+// Price formats v as an amount of the currency of the language
+func (l *Localizer) Price(v float64) string {
+	return strings.Replace(l.format().Currency, "{amount}", l.Number(v), 1)
+}
+
+// Date formats t with the date layout of the language
+func (l *Localizer) Date(t time.Time) string {
+	return t.Format(l.format().Date)
+}
+
+// format returns the format of the language, completed by the default language's
+func (l *Localizer) format() Format {
+	f, fb := l.catalog.Format, l.fallback.Format
+	if f.Decimal == "" {
+		// The separators go together, an empty group means no grouping
+		f.Decimal, f.Group = cmpOr(fb.Decimal, "."), fb.Group
+	}
+	if f.Currency == "" {
+		f.Currency = cmpOr(fb.Currency, "{amount}")
+	}
+	if f.Date == "" {
+		f.Date = cmpOr(fb.Date, time.DateOnly)
+	}
+	return f
+}
+
+func cmpOr(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+// FuncMap returns the template functions of the localizer:
+//
+//	{{ T "products.count" 3 }}  translates a message
+//	{{ price .Price }}          formats a price
+//	{{ date .CreatedAt }}       formats a date
+//	{{ lang }}                  is the language, for the lang attribute
+//	{{ languages }}             lists the supported languages, for a switcher
+func (l *Localizer) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"T":         l.T,
+		"price":     l.Price,
+		"date":      l.Date,
+		"lang":      l.Lang,
+		"languages": l.bundle.Languages,
+	}
+}
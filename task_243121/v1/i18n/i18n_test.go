@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var catalogs = fstest.MapFS{
+	"en.json": {Data: []byte(`{"name": "English", "format": {"decimal": ".", "group": ",", "currency": "${amount}", "date": "Jan 2, 2006"},
+		"messages": {"title": "Products", "count": "%d products", "only.en": "English only"}}`)},
+	"de.json": {Data: []byte(`{"name": "Deutsch", "format": {"decimal": ",", "group": ".", "currency": "{amount} €", "date": "02.01.2006"},
+		"messages": {"title": "Produkte", "count": "%d Produkte"}}`)},
+	"fr.json": {Data: []byte(`{"name": "Français", "messages": {"title": "Produits"}}`)},
+}
+
+func TestLocalizer(t *testing.T) {
+	b, err := Load(catalogs, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		lang, title, count, fallback, price, date string
+	}{
+		{"en", "Products", "3 products", "English only", "$1,234,567.50", "Mar 9, 2024"},
+		{"de", "Produkte", "3 Produkte", "English only", "1.234.567,50 €", "09.03.2024"},
+		// French has no formats and falls back to English's
+		{"fr", "Produits", "3 products", "English only", "$1,234,567.50", "Mar 9, 2024"},
+		{"xx", "Products", "3 products", "English only", "$1,234,567.50", "Mar 9, 2024"},
+	}
+	for _, tt := range tests {
+		l := b.Localizer(tt.lang)
+		got := []string{l.T("title"), l.T("count", 3), l.T("only.en"), l.Price(1234567.5), l.Date(day)}
+		want := []string{tt.title, tt.count, tt.fallback, tt.price, tt.date}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected %q, got %q", tt.lang, want[i], got[i])
+			}
+		}
+	}
+
+	l := b.Localizer("en")
+	if got := l.T("missing.key"); got != "missing.key" {
+		t.Errorf("Expected the key for a missing message, got %q", got)
+	}
+	if got := l.Number(-999.999); got != "-1,000.00" {
+		t.Errorf("Expected -1,000.00, got %q", got)
+	}
+	if _, err := Load(catalogs, "es"); err == nil {
+		t.Error("Expected an error without a catalog for the default language")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	b, _ := Load(catalogs, "en")
+	tests := map[string]string{
+		"":                          "en",
+		"de":                        "de",
+		"de-CH, en;q=0.5":           "de",
+		"es, fr;q=0.4, de;q=0.8":    "de",
+		"en;q=0.1, fr":              "fr",
+		"fr;q=0, es":                "en",
+		"*":                         "en",
+		"FR-ca;q=0.9, es;q=invalid": "fr",
+	}
+	for header, want := range tests {
+		if got := b.Match(header); got != want {
+			t.Errorf("Match(%q): expected %s, got %s", header, want, got)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	b, _ := Load(catalogs, "en")
+	h := b.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(FromContext(r.Context()).T("title")))
+	}))
+	serve := func(target, accept string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Accept-Language", accept)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("/", "de-DE,de;q=0.9", nil); w.Body.String() != "Produkte" || w.Header().Get("Content-Language") != "de" {
+		t.Errorf("Expected German from the header, got %q", w.Body.String())
+	}
+
+	w := serve("/?lang=fr", "de", nil)
+	if w.Body.String() != "Produits" {
+		t.Errorf("Expected the query to override the header, got %q", w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CookieName || cookies[0].Value != "fr" {
+		t.Fatalf("Expected the language to be remembered, got %v", cookies)
+	}
+	if w := serve("/", "de", cookies[0]); w.Body.String() != "Produits" {
+		t.Errorf("Expected the cookie to override the header, got %q", w.Body.String())
+	}
+	if w := serve("/?lang=xx", "de", &http.Cookie{Name: CookieName, Value: "yy"}); w.Body.String() != "Produkte" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Expected unsupported languages to be ignored, got %q", w.Body.String())
+	}
+}
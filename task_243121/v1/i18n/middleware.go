@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie holding the language picked by the visitor. It overrides
+// the Accept-Language header.
+const CookieName = "lang"
+
+const cookieMaxAge = 365 * 24 * time.Hour
+
+type contextKey struct{}
+
+// FromContext returns the localizer of the request, nil outside of Middleware
+func FromContext(ctx context.Context) *Localizer {
+	l, _ := ctx.Value(contextKey{}).(*Localizer)
+	return l
+}
+
+// Middleware picks the language of each request and stores its localizer in the
+// request context. A supported ?lang= query parameter switches the language and is
+// remembered in the cookie, then the cookie and the Accept-Language header are
+// consulted, and the default language is the last resort.
+func (b *Bundle) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := ""
+		if q := r.URL.Query().Get("lang"); q != "" && b.Supports(q) {
+			lang = strings.ToLower(q)
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    lang,
+				Path:     "/",
+				MaxAge:   int(cookieMaxAge / time.Second),
+				SameSite: http.SameSiteLaxMode,
+			})
+		} else if cookie, err := r.Cookie(CookieName); err == nil && b.Supports(cookie.Value) {
+			lang = cookie.Value
+		} else {
+			lang = b.Match(r.Header.Get("Accept-Language"))
+		}
+
+		l := b.Localizer(lang)
+		w.Header().Set("Content-Language", l.Lang())
+		w.Header().Add("Vary", "Accept-Language, Cookie")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, l)))
+	})
+}
+
+// Match returns the supported language the Accept-Language header prefers. A regional
+// tag like de-CH matches de. It returns the default language if none match.
+func (b *Bundle) Match(header string) string {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag != "" && q > 0 {
+			choices = append(choices, choice{strings.ToLower(tag), q})
+		}
+	}
+	slices.SortStableFunc(choices, func(a, b choice) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+
+	for _, c := range choices {
+		if b.Supports(c.tag) {
+			return c.tag
+		}
+		if base, _, ok := strings.Cut(c.tag, "-"); ok && b.Supports(base) {
+			return base
+		}
+	}
+	return b.fallback
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+	"awesomeProject/task_243121/v1/i18n"
+)
+
+func TestLocalizedListing(t *testing.T) {
+	mock := setupTestDB(t)
+
+	for _, tt := range []struct {
+		lang  string
+		texts []string
+	}{
+		{"en", []string{`lang="en"`, "Products", "$1,299.90", "4 in stock", "Out of stock"}},
+		{"de", []string{`lang="de"`, "Produkte", "1.299,90 €", "4 vorrätig", "Nicht vorrätig", "Nicht vorrätige Produkte ausblenden"}},
+	} {
+		mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products").
+			WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 1299.9, 4, "Computers"}, []driver.Value{2, "Mouse", "Wireless", 19.5, 0, "Computers"}))
+		req := testkit.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: i18n.CookieName, Value: tt.lang})
+		body := testkit.Serve(routes(), req).Body.String()
+		for _, text := range tt.texts {
+			if !strings.Contains(body, text) {
+				t.Errorf("%s: expected %q in the page", tt.lang, text)
+			}
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	catalogs := translations.Languages()
+	if len(catalogs) < 2 {
+		t.Fatalf("Expected at least two languages, got %d", len(catalogs))
+	}
+	var en *i18n.Catalog
+	for _, c := range catalogs {
+		if c.Lang == "en" {
+			en = c
+		}
+	}
+	for _, c := range catalogs {
+		for key := range en.Messages {
+			if _, ok := c.Messages[key]; !ok {
+				t.Errorf("%s: missing message %s", c.Lang, key)
+			}
+		}
+		for key := range c.Messages {
+			if _, ok := en.Messages[key]; !ok {
+				t.Errorf("%s: message %s isn't in the English catalog", c.Lang, key)
+			}
+		}
+	}
+}
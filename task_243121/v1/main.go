@@ -1,22 +1,49 @@
 package main
 
 import (
+	"awesomeProject/platform/app"
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/config"
+	"awesomeProject/platform/cors"
+	"awesomeProject/platform/dbutil"
+	"awesomeProject/platform/errs"
+	"awesomeProject/platform/idempotency"
+	"awesomeProject/platform/logging"
+	"awesomeProject/platform/ratelimit"
+	"awesomeProject/platform/telemetry"
+	"awesomeProject/task_243121/v1/i18n"
+	"context"
 	"database/sql"
+	"embed"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
 
 // Model
 type Product struct {
-	ID          int
-	Name        string
-	Description string
-	Price       float64
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	Stock       int     `json:"stock"`
+	Category    string  `json:"category"`
+}
+
+// InStock reports whether the product can be ordered
+func (p Product) InStock() bool {
+	return p.Stock > 0
 }
 
 // ViewModel
@@ -24,47 +51,181 @@ type ProductViewModel struct {
 	Product  Product
 	Products []Product
 	Error    string
+	// Favorites holds the IDs of the visitor's favorite products
+	Favorites     map[int]bool
+	FavoriteCount int
+	// HideOutOfStock is set when the listing leaves out products that are out of stock
+	HideOutOfStock bool
+	// Admin is set for the signed in administrator, who sees the management actions
+	Admin bool
+	// Related are the products recommended along with Product
+	Related []Recommendation
 }
 
+var (
+	errInvalidProductID = errs.New(errs.ErrInvalid, "Invalid product ID")
+	errProductNotFound  = errs.New(errs.ErrNotFound, "Product not found")
+)
+
+// slowQuery is how long a query may take before it is logged as slow
+const slowQuery = 200 * time.Millisecond
+
 // Database Configuration
-var db *sql.DB
+var db *dbutil.DB
+
+// apiOrigins are the origins of the browser apps calling the API, set from the
+// cors.origins setting
+var apiOrigins []string
+
+// defaultDSN is the database of a local development setup
+const defaultDSN = "user:password@tcp(localhost:3306)/dbname"
+
+func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	dsn := cfg.String("mysql.dsn", defaultDSN)
+	addr := cfg.String("http.addr", app.DefaultAddr)
+	if secret := cfg.String("cookie.secret", ""); secret != "" {
+		cookieKey = []byte(secret)
+	}
+	adminCredentials.Username = cfg.String("admin.username", adminCredentials.Username)
+	apiOrigins = cfg.Strings("cors.origins", nil)
+	adminCredentials.PasswordHash = []byte(cfg.String("admin.password.hash", ""))
+	logger := logging.FromConfig(cfg, "products")
+	if err := cfg.Err(); err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+	if len(adminCredentials.PasswordHash) == 0 {
+		logger.Warn("No admin.password.hash configured, nobody can sign in to manage the products")
+	}
+	shutdownTelemetry, err := telemetry.Setup(context.Background(), "products")
+	if err != nil {
+		log.Fatal(err)
+	}
 
-func init() {
-	var err error
-	db, err = sql.Open("mysql", "user:password@tcp(localhost:3306)/dbname")
+	application, err := app.NewApplication(app.Options{
+		Name:   "products",
+		Addr:   addr,
+		Logger: logger,
+		OpenDB: func(context.Context) (*sql.DB, error) {
+			return sql.Open("mysql", dsn)
+		},
+		Router: func(a *app.Application) (http.Handler, error) {
+			db = dbutil.New(a.DB, dbutil.MySQL, dbutil.LogHook(a.Logger, slowQuery))
+			return logging.Middleware(a.Logger)(routes()), nil
+		},
+		OnStop: []app.Hook{shutdownTelemetry},
+	})
 	if err != nil {
-		panic(err.Error())
+		log.Fatal(err)
+	}
+	fmt.Println("Server started on", addr)
+	if err := application.Run(context.Background()); err != nil {
+		log.Fatal(err)
 	}
 }
 
-func main() {
-	http.HandleFunc("/", indexHandler)
-	http.HandleFunc("/create", createHandler)
-	http.HandleFunc("/store", storeHandler)
-	http.HandleFunc("/edit/", editHandler)
-	http.HandleFunc("/update/", updateHandler)
-	http.HandleFunc("/delete/", deleteHandler)
-	fmt.Println("Server started on :8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+// locales holds the message catalogs of the pages
+//
+//go:embed locales/*.json
+var locales embed.FS
+
+// translations are the catalogs of the supported languages, English by default
+var translations = mustLoadTranslations()
+
+func mustLoadTranslations() *i18n.Bundle {
+	catalogs, err := fs.Sub(locales, "locales")
+	if err != nil {
+		panic(err)
+	}
+	bundle, err := i18n.Load(catalogs, "en")
+	if err != nil {
+		panic(err)
+	}
+	return bundle
+}
+
+func routes() http.Handler {
+	// a retried creation with an Idempotency-Key gets the first response
+	idempotentStore := idempotency.Middleware(nil, idempotency.DefaultTTL, func(r *http.Request) string {
+		user, _ := sessionUser(r)
+		return user
+	})(http.HandlerFunc(storeHandler))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", indexHandler)
+	mux.Handle("/login", ratelimit.Middleware(loginLimit, time.Minute, nil)(http.HandlerFunc(loginHandler)))
+	mux.HandleFunc("/logout", logoutHandler)
+	mux.HandleFunc("/create", requireAdmin(createHandler))
+	mux.HandleFunc("/store", requireAdmin(idempotentStore.ServeHTTP))
+	mux.HandleFunc("/edit/", requireAdmin(editHandler))
+	mux.HandleFunc("/update/", requireAdmin(updateHandler))
+	mux.HandleFunc("/delete/", requireAdmin(deleteHandler))
+	mux.HandleFunc("/stock/", requireAdmin(stockHandler))
+	mux.HandleFunc("/favorites", favoritesHandler)
+	mux.HandleFunc("/favorites/", toggleFavoriteHandler)
+	mux.HandleFunc("/products/", productHandler)
+
+	// the API takes JSON bodies, the pages post forms
+	api := http.NewServeMux()
+	api.HandleFunc("/api/favorites", apiFavoritesHandler)
+	api.HandleFunc("/api/favorites/", apiFavoritesHandler)
+	api.HandleFunc("/api/products/", apiRelatedHandler)
+	// the favorites live in a cookie, so the origins get to send theirs
+	apiCORS := cors.Middleware(cors.Config{
+		AllowedOrigins:   apiOrigins,
+		AllowedMethods:   []string{"GET", "PUT", "DELETE"},
+		AllowCredentials: true,
+	})
+	mux.Handle("/api/", apiCORS(bodylimit.RequireContentType("application/json")(api)))
+
+	// the spans are named after the patterns of mux, not the paths
+	route := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}
+	return telemetry.Middleware(route)(translations.Middleware(bodylimit.MaxBytes(bodylimit.DefaultMaxBytes)(mux)))
+}
+
+// render executes the page template name with the translation functions of the
+// language of the request
+func render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	l := i18n.FromContext(r.Context())
+	if l == nil {
+		l = translations.Localizer("")
+	}
+	funcs := l.FuncMap()
+	funcs["today"] = time.Now
+	tmpl := template.Must(template.New(name).Funcs(funcs).ParseFiles("templates/" + name))
+	tmpl.Execute(w, data)
 }
 
 // Handlers
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
-	products, err := getProducts()
+	hideOutOfStock := r.URL.Query().Get("hide_out_of_stock") != ""
+	products, err := getProducts(r.Context(), hideOutOfStock)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errs.Write(w, r, err)
 		return
 	}
 
-	viewModel := ProductViewModel{Products: products}
-	tmpl := template.Must(template.ParseFiles("templates/index.html"))
-	tmpl.Execute(w, viewModel)
+	favorites := readFavorites(r)
+	viewModel := ProductViewModel{
+		Products:       products,
+		Favorites:      favoriteSet(favorites),
+		FavoriteCount:  len(favorites),
+		HideOutOfStock: hideOutOfStock,
+		Admin:          isAdmin(r),
+	}
+	render(w, r, "index.html", viewModel)
 }
 
 func createHandler(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.ParseFiles("templates/create.html"))
-	tmpl.Execute(w, nil)
+	render(w, r, "create.html", ProductViewModel{})
 }
 
 func storeHandler(w http.ResponseWriter, r *http.Request) {
@@ -75,17 +236,29 @@ func storeHandler(w http.ResponseWriter, r *http.Request) {
 
 	name := r.FormValue("name")
 	description := r.FormValue("description")
+	category := strings.TrimSpace(r.FormValue("category"))
 	price, err := strconv.ParseFloat(r.FormValue("price"), 64)
 	if err != nil {
-		viewModel := ProductViewModel{Error: "Invalid price"}
-		tmpl := template.Must(template.ParseFiles("templates/create.html"))
-		tmpl.Execute(w, viewModel)
+		viewModel := ProductViewModel{Error: "error.invalid_price"}
+		render(w, r, "create.html", viewModel)
+		return
+	}
+	stock, err := parseStock(r.FormValue("stock"))
+	if err != nil {
+		viewModel := ProductViewModel{Error: "error.invalid_stock"}
+		render(w, r, "create.html", viewModel)
 		return
 	}
 
-	_, err = db.Exec("INSERT INTO products (name, description, price) VALUES (?, ?, ?)", name, description, price)
+	_, err = db.ExecContext(r.Context(), "INSERT INTO products (name, description, price, stock, category) VALUES (?, ?, ?, ?, ?)", name, description, price, stock, category)
+	if dbutil.IsUniqueViolation(err) {
+		viewModel := ProductViewModel{Error: "error.duplicate_name", Product: Product{Name: name, Description: description, Price: price, Stock: stock, Category: category}}
+		w.WriteHeader(http.StatusConflict)
+		render(w, r, "create.html", viewModel)
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errs.Write(w, r, err)
 		return
 	}
 
@@ -96,19 +269,18 @@ func editHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Path[len("/edit/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		errs.Write(w, r, errInvalidProductID)
 		return
 	}
 
-	product, err := getProduct(id)
+	product, err := getProduct(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errs.Write(w, r, err)
 		return
 	}
 
 	viewModel := ProductViewModel{Product: product}
-	tmpl := template.Must(template.ParseFiles("templates/edit.html"))
-	tmpl.Execute(w, viewModel)
+	render(w, r, "edit.html", viewModel)
 }
 
 func updateHandler(w http.ResponseWriter, r *http.Request) {
@@ -120,40 +292,59 @@ func updateHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Path[len("/update/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		errs.Write(w, r, errInvalidProductID)
 		return
 	}
 
 	name := r.FormValue("name")
 	description := r.FormValue("description")
+	category := strings.TrimSpace(r.FormValue("category"))
 	price, err := strconv.ParseFloat(r.FormValue("price"), 64)
 	if err != nil {
-		viewModel := ProductViewModel{Error: "Invalid price", Product: Product{ID: id}}
-		tmpl := template.Must(template.ParseFiles("templates/edit.html"))
-		tmpl.Execute(w, viewModel)
+		viewModel := ProductViewModel{Error: "error.invalid_price", Product: Product{ID: id}}
+		render(w, r, "edit.html", viewModel)
 		return
 	}
 
-	_, err = db.Exec("UPDATE products SET name = ?, description = ?, price = ? WHERE id = ?", name, description, price, id)
+	query, args, err := db.Dialect().Named("UPDATE products SET name = :name, description = :description, price = :price, category = :category WHERE id = :id",
+		map[string]any{"name": name, "description": description, "price": price, "category": category, "id": id})
+	if err == nil {
+		_, err = db.ExecContext(r.Context(), query, args...)
+	}
+	if dbutil.IsUniqueViolation(err) {
+		// Show the stock as it is, along with the values that were submitted
+		product, _ := getProduct(r.Context(), id)
+		product.ID, product.Name, product.Description, product.Price, product.Category = id, name, description, price, category
+		w.WriteHeader(http.StatusConflict)
+		render(w, r, "edit.html", ProductViewModel{Error: "error.duplicate_name", Product: product})
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errs.Write(w, r, err)
 		return
 	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// deleteHandler only deletes on POST, the session cookie also comes along with the
+// top-level GETs other sites link to
 func deleteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
 	idStr := r.URL.Path[len("/delete/"):]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		errs.Write(w, r, errInvalidProductID)
 		return
 	}
 
-	_, err = db.Exec("DELETE FROM products WHERE id = ?", id)
+	_, err = db.ExecContext(r.Context(), "DELETE FROM products WHERE id = ?", id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		errs.Write(w, r, err)
 		return
 	}
 
@@ -162,8 +353,20 @@ func deleteHandler(w http.ResponseWriter, r *http.Request) {
 
 // Database functions
 
-func getProducts() ([]Product, error) {
-	rows, err := db.Query("SELECT * FROM products")
+// productColumns are the columns scanned by scanProduct
+const productColumns = "id, name, description, price, stock, category"
+
+func scanProduct(row interface{ Scan(...any) error }, p *Product) error {
+	return row.Scan(&p.ID, &p.Name, &p.Description, &p.Price, &p.Stock, &p.Category)
+}
+
+// getProducts returns the products, only the ones in stock if inStockOnly is set
+func getProducts(ctx context.Context, inStockOnly bool) ([]Product, error) {
+	query := "SELECT " + productColumns + " FROM products"
+	if inStockOnly {
+		query += " WHERE stock > 0"
+	}
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +375,7 @@ func getProducts() ([]Product, error) {
 	var products []Product
 	for rows.Next() {
 		var p Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.Price); err != nil {
+		if err := scanProduct(rows, &p); err != nil {
 			return nil, err
 		}
 		products = append(products, p)
@@ -180,9 +383,12 @@ func getProducts() ([]Product, error) {
 	return products, nil
 }
 
-func getProduct(id int) (Product, error) {
+func getProduct(ctx context.Context, id int) (Product, error) {
 	var p Product
-	err := db.QueryRow("SELECT * FROM products WHERE id = ?", id).Scan(&p.ID, &p.Name, &p.Description, &p.Price)
+	err := scanProduct(db.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = ?", id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Product{}, errProductNotFound
+	}
 	if err != nil {
 		return Product{}, err
 	}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"awesomeProject/platform/errs"
+)
+
+// The products viewed in a browser session are kept in a signed session cookie, like
+// the favorites, to recommend them along with each other
+const (
+	viewedCookie = "viewed"
+	// maxViewed bounds the cookie size, older views are dropped
+	maxViewed = 20
+	// maxRelated is the number of related products recommended
+	maxRelated = 4
+	// priceBand is how far the price of a product may be off to count as similar, as
+	// a fraction of the price of the product shown
+	priceBand = 0.25
+)
+
+// Reasons a product is recommended. The page translates them as related.{reason}.
+const (
+	reasonSameCategory   = "same_category"
+	reasonSimilarPrice   = "similar_price"
+	reasonViewedTogether = "viewed_together"
+)
+
+// reasonScores weigh the reasons. A product viewed in the same session says more
+// about the visitor than the catalog does.
+var reasonScores = map[string]int{
+	reasonViewedTogether: 3,
+	reasonSameCategory:   2,
+	reasonSimilarPrice:   1,
+}
+
+// Recommendation is a related product with the reasons it was picked
+type Recommendation struct {
+	Product
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons"`
+}
+
+func readViewed(r *http.Request) []int {
+	return readIDCookie(r, viewedCookie)
+}
+
+func writeViewed(w http.ResponseWriter, ids []int) {
+	writeIDCookie(w, viewedCookie, ids, 0)
+}
+
+// addViewed adds id to the products viewed, most recent first
+func addViewed(ids []int, id int) []int {
+	return prependID(ids, id, maxViewed)
+}
+
+// similarPrice reports whether other is within priceBand of price
+func similarPrice(price, other float64) bool {
+	return math.Abs(other-price) <= price*priceBand
+}
+
+// recommend ranks the candidates related to product, viewed being the products of
+// the session. Candidates related in no way and product itself are left out. Equal
+// scores go to the closer price, then to the lower ID so the order is stable.
+func recommend(product Product, candidates []Product, viewed []int, limit int) []Recommendation {
+	viewedSet := make(map[int]bool, len(viewed))
+	for _, id := range viewed {
+		viewedSet[id] = true
+	}
+
+	var recommendations []Recommendation
+	for _, candidate := range candidates {
+		if candidate.ID == product.ID {
+			continue
+		}
+		var reasons []string
+		if viewedSet[candidate.ID] {
+			reasons = append(reasons, reasonViewedTogether)
+		}
+		if product.Category != "" && strings.EqualFold(candidate.Category, product.Category) {
+			reasons = append(reasons, reasonSameCategory)
+		}
+		if similarPrice(product.Price, candidate.Price) {
+			reasons = append(reasons, reasonSimilarPrice)
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		score := 0
+		for _, reason := range reasons {
+			score += reasonScores[reason]
+		}
+		recommendations = append(recommendations, Recommendation{Product: candidate, Score: score, Reasons: reasons})
+	}
+
+	slices.SortFunc(recommendations, func(a, b Recommendation) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(math.Abs(a.Price-product.Price), math.Abs(b.Price-product.Price)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+	return recommendations
+}
+
+// relatedProducts returns the recommendations for product
+func relatedProducts(ctx context.Context, product Product, viewed []int) ([]Recommendation, error) {
+	candidates, err := getRelatedCandidates(ctx, product, viewed)
+	if err != nil {
+		return nil, err
+	}
+	return recommend(product, candidates, viewed, maxRelated), nil
+}
+
+// Handlers
+
+// productHandler shows /products/{id} with its related products, and adds it to the
+// products viewed in the session
+func productHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/products/"))
+	if err != nil || id <= 0 {
+		errs.Write(w, r, errInvalidProductID)
+		return
+	}
+	product, err := getProduct(r.Context(), id)
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+	viewed := readViewed(r)
+	related, err := relatedProducts(r.Context(), product, viewed)
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+
+	writeViewed(w, addViewed(viewed, id))
+	favorites := readFavorites(r)
+	viewModel := ProductViewModel{
+		Product:       product,
+		Related:       related,
+		Favorites:     favoriteSet(favorites),
+		FavoriteCount: len(favorites),
+		Admin:         isAdmin(r),
+	}
+	render(w, r, "product.html", viewModel)
+}
+
+// relatedResponse is the JSON representation of the related products
+type relatedResponse struct {
+	ProductID int              `json:"product_id"`
+	Products  []Recommendation `json:"products"`
+}
+
+// apiRelatedHandler serves GET /api/products/{id}/related, taking the products viewed
+// in the session into account without counting this request as a view
+func apiRelatedHandler(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/products/"), "/")
+	if action != "related" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		errs.Write(w, r, errInvalidProductID)
+		return
+	}
+
+	product, err := getProduct(r.Context(), id)
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+	related, err := relatedProducts(r.Context(), product, readViewed(r))
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+	if related == nil {
+		related = []Recommendation{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(relatedResponse{ProductID: id, Products: related})
+}
+
+// Database functions
+
+// getRelatedCandidates returns the products other than product that share its
+// category, have a similar price or were viewed in the session
+func getRelatedCandidates(ctx context.Context, product Product, viewed []int) ([]Product, error) {
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return db.Dialect().Placeholder(len(args))
+	}
+
+	query := "SELECT " + productColumns + " FROM products WHERE id <> " + arg(product.ID) + " AND ("
+	var conditions []string
+	if product.Category != "" {
+		conditions = append(conditions, "category = "+arg(product.Category))
+	}
+	conditions = append(conditions, "price BETWEEN "+arg(product.Price*(1-priceBand))+" AND "+arg(product.Price*(1+priceBand)))
+	if len(viewed) > 0 {
+		placeholders := make([]string, len(viewed))
+		for i, id := range viewed {
+			placeholders[i] = arg(id)
+		}
+		conditions = append(conditions, "id IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	query += strings.Join(conditions, " OR ") + ")"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []Product
+	for rows.Next() {
+		var p Product
+		if err := scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
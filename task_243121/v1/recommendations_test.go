@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+)
+
+func TestRecommend(t *testing.T) {
+	laptop := Product{ID: 1, Name: "Laptop", Price: 1000, Category: "Computers"}
+	candidates := []Product{
+		laptop,
+		{ID: 2, Name: "Mouse", Price: 20, Category: "computers"},
+		{ID: 3, Name: "Monitor", Price: 900, Category: "Computers"},
+		{ID: 4, Name: "Chair", Price: 1100, Category: "Furniture"},
+		{ID: 5, Name: "Desk", Price: 300, Category: "Furniture"},
+		{ID: 6, Name: "Phone", Price: 800, Category: "Phones"},
+		{ID: 7, Name: "Tablet", Price: 1200, Category: "Phones"},
+	}
+
+	got := recommend(laptop, candidates, []int{5, 1}, 10)
+	var names []string
+	for _, r := range got {
+		names = append(names, r.Name)
+	}
+	// the monitor matches the category and price, the desk was viewed, the mouse is
+	// in the same category, and the chair is closer in price than the phone and tablet
+	want := "Monitor Desk Mouse Chair Phone Tablet"
+	if strings.Join(names, " ") != want {
+		t.Fatalf("Expected %s, got %v", want, names)
+	}
+	if got[0].Score != 3 || len(got[0].Reasons) != 2 || got[1].Reasons[0] != reasonViewedTogether {
+		t.Errorf("Expected the scores and reasons, got %+v", got[:2])
+	}
+
+	if got := recommend(laptop, candidates, nil, 2); len(got) != 2 {
+		t.Errorf("Expected the limit to apply, got %d", len(got))
+	}
+	uncategorized := Product{ID: 8, Price: 5000}
+	if got := recommend(uncategorized, []Product{{ID: 9, Price: 10}}, nil, 10); len(got) != 0 {
+		t.Errorf("Expected products without a category not to match each other, got %+v", got)
+	}
+}
+
+func TestProductPage(t *testing.T) {
+	mock := setupTestDB(t)
+	client := testkit.NewClient(routes())
+	const selectProduct = "SELECT id, name, description, price, stock, category FROM products WHERE id = ?"
+
+	mock.ExpectQuery(selectProduct).WithArgs(2).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{2, "Mouse", "Wireless", 19.5, 3, "Computers"}))
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id <> ? AND (category = ? OR price BETWEEN ? AND ?)").
+		WithArgs(2, "Computers", 14.625, 24.375).
+		WillReturnRows(testkit.Rows(productColumnNames))
+	w := client.Do(testkit.NewRequest("GET", "/products/2", nil))
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "Wireless") || !strings.Contains(body, "No related products yet.") {
+		t.Errorf("Expected the product without related products, got %s", body)
+	}
+
+	mock.ExpectQuery(selectProduct).WithArgs(1).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 1000.0, 0, "Computers"}))
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id <> ? AND (category = ? OR price BETWEEN ? AND ? OR id IN (?))").
+		WithArgs(1, "Computers", 750.0, 1250.0, 2).
+		WillReturnRows(testkit.Rows(productColumnNames,
+			[]driver.Value{2, "Mouse", "Wireless", 19.5, 3, "Computers"},
+			[]driver.Value{3, "Monitor", "Wide", 900.0, 1, "Computers"}))
+	w = client.Do(testkit.NewRequest("GET", "/products/1", nil))
+	body := w.Body.String()
+	monitor, mouse := strings.Index(body, `href="/products/3"`), strings.Index(body, `href="/products/2"`)
+	if mouse < 0 || monitor < mouse || !strings.Contains(body, "Viewed in this session") {
+		t.Errorf("Expected the viewed mouse before the monitor, got %s", body)
+	}
+
+	mock.ExpectQuery(selectProduct).WithArgs(3).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{3, "Monitor", "Wide", 900.0, 1, "Computers"}))
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id <> ? AND (category = ? OR price BETWEEN ? AND ? OR id IN (?, ?))").
+		WithArgs(3, "Computers", 675.0, 1125.0, 1, 2).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 1000.0, 0, "Computers"}))
+	w = client.Do(testkit.NewRequest("GET", "/api/products/3/related", nil))
+	var got relatedResponse
+	if err := testkit.DecodeJSON(w, &got); err != nil || got.ProductID != 3 || len(got.Products) != 1 || got.Products[0].Score != 6 {
+		t.Errorf("Expected the laptop with all reasons, got %+v (%v)", got, err)
+	}
+
+	mock.ExpectQuery(selectProduct).WithArgs(9).WillReturnRows(testkit.Rows(productColumnNames))
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/api/products/9/related", nil)), http.StatusNotFound); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("POST", "/api/products/3/related", nil)), http.StatusMethodNotAllowed); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/products/x", nil)), http.StatusBadRequest); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
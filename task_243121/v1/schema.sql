@@ -0,0 +1,27 @@
+-- Schema of the products database (MySQL 8)
+
+CREATE TABLE IF NOT EXISTS products (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    name        VARCHAR(255)   NOT NULL,
+    description TEXT,
+    price       DECIMAL(10, 2) NOT NULL,
+    stock       INT            NOT NULL DEFAULT 0,
+    category    VARCHAR(100)   NOT NULL DEFAULT '',
+    CONSTRAINT products_stock_not_negative CHECK (stock >= 0)
+);
+
+-- Product names are unique regardless of case. Existing databases need their
+-- duplicate names renamed before the index can be created.
+CREATE UNIQUE INDEX products_name_lower ON products ((LOWER(name)));
+
+-- Related products are looked up by category and price
+CREATE INDEX products_category_price ON products (category, price);
+
+-- Databases created before stock tracking:
+-- ALTER TABLE products
+--     ADD COLUMN stock INT NOT NULL DEFAULT 0,
+--     ADD CONSTRAINT products_stock_not_negative CHECK (stock >= 0);
+
+-- Databases created before categories:
+-- ALTER TABLE products ADD COLUMN category VARCHAR(100) NOT NULL DEFAULT '';
+-- CREATE INDEX products_category_price ON products (category, price);
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"awesomeProject/platform/errs"
+)
+
+var (
+	errInvalidQuantity   = errs.New(errs.ErrInvalid, "Quantity must be a positive whole number")
+	errInsufficientStock = errs.New(errs.ErrConflict, "Not enough stock")
+)
+
+// parseStock parses the stock of the product forms, empty means none
+func parseStock(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	stock, err := strconv.Atoi(s)
+	if err != nil || stock < 0 {
+		return 0, errs.New(errs.ErrInvalid, "Invalid stock")
+	}
+	return stock, nil
+}
+
+// stockHandler serves /stock/{id}/increment and /stock/{id}/decrement, changing the
+// stock by the quantity form value, 1 if it's missing. Clients asking for JSON get
+// the new stock, forms are redirected to the product list.
+func stockHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/stock/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		errs.Write(w, r, errInvalidProductID)
+		return
+	}
+	quantity := 1
+	if s := r.FormValue("quantity"); s != "" {
+		if quantity, err = strconv.Atoi(s); err != nil || quantity <= 0 {
+			errs.Write(w, r, errInvalidQuantity)
+			return
+		}
+	}
+
+	var stock int
+	switch action {
+	case "increment":
+		stock, err = incrementStock(r.Context(), id, quantity)
+	case "decrement":
+		stock, err = decrementStock(r.Context(), id, quantity)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		errs.Write(w, r, err)
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"id": id, "stock": stock})
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Database functions
+
+// incrementStock adds quantity to the stock of the product and returns the new stock
+func incrementStock(ctx context.Context, id, quantity int) (int, error) {
+	res, err := db.ExecContext(ctx, "UPDATE products SET stock = stock + ? WHERE id = ?", quantity, id)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, errProductNotFound
+	}
+	return getStock(ctx, id)
+}
+
+// decrementStock takes quantity from the stock of the product and returns the new
+// stock. The update only applies while there is enough stock, so concurrent orders
+// can't take the stock below zero.
+func decrementStock(ctx context.Context, id, quantity int) (int, error) {
+	res, err := db.ExecContext(ctx, "UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?", quantity, id, quantity)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		// Either the product is gone or its stock is too low
+		if _, err := getStock(ctx, id); err != nil {
+			return 0, err
+		}
+		return 0, errInsufficientStock
+	}
+	return getStock(ctx, id)
+}
+
+func getStock(ctx context.Context, id int) (int, error) {
+	p, err := getProduct(ctx, id)
+	return p.Stock, err
+}
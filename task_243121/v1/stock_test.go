@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func stockRequest(target string, form url.Values) *http.Request {
+	req := testkit.FormRequest("POST", target, form)
+	req.Header.Set("Accept", "application/json")
+	return signedIn(req)
+}
+
+func TestStock(t *testing.T) {
+	const selectProduct = "SELECT id, name, description, price, stock, category FROM products WHERE id = ?"
+	const decrement = "UPDATE products SET stock = stock - ? WHERE id = ? AND stock >= ?"
+
+	tests := []struct {
+		name   string
+		target string
+		form   url.Values
+		expect func(mock sqlmock.Sqlmock)
+		status int
+		body   string
+	}{
+		{
+			name:   "increment",
+			target: "/stock/1/increment",
+			form:   url.Values{"quantity": {"5"}},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE products SET stock = stock + ? WHERE id = ?").WithArgs(5, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(selectProduct).WithArgs(1).
+					WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 7, "Computers"}))
+			},
+			status: http.StatusOK,
+			body:   `{"id":1,"stock":7}`,
+		},
+		{
+			name:   "decrement by one",
+			target: "/stock/1/decrement",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(decrement).WithArgs(1, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(selectProduct).WithArgs(1).
+					WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 0, "Computers"}))
+			},
+			status: http.StatusOK,
+			body:   `{"id":1,"stock":0}`,
+		},
+		{
+			name:   "decrement below zero",
+			target: "/stock/1/decrement",
+			form:   url.Values{"quantity": {"3"}},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(decrement).WithArgs(3, 1, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(selectProduct).WithArgs(1).
+					WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 2, "Computers"}))
+			},
+			status: http.StatusConflict,
+			body:   "Not enough stock",
+		},
+		{
+			name:   "decrement missing product",
+			target: "/stock/9/decrement",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(decrement).WithArgs(1, 9, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(selectProduct).WithArgs(9).WillReturnRows(testkit.Rows(productColumnNames))
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "invalid quantity",
+			target: "/stock/1/increment",
+			form:   url.Values{"quantity": {"-2"}},
+			status: http.StatusBadRequest,
+			body:   "positive whole number",
+		},
+		{
+			name:   "unknown action",
+			target: "/stock/1/reset",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := setupTestDB(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+			w := testkit.Serve(routes(), stockRequest(tt.target, tt.form))
+			if err := testkit.ExpectStatus(w, tt.status); err != nil {
+				t.Fatalf("%v: %s", err, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.body) {
+				t.Errorf("Expected %q in the response, got %s", tt.body, w.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestStockFormRedirects(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectExec("UPDATE products SET stock = stock + ? WHERE id = ?").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE id = ?").WithArgs(2).
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{2, "Mouse", "Wireless", 19.5, 1, "Computers"}))
+
+	w := testkit.Serve(routes(), signedIn(testkit.FormRequest("POST", "/stock/2/increment", nil)))
+	if err := testkit.ExpectStatus(w, http.StatusSeeOther); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListingHidesOutOfStock(t *testing.T) {
+	mock := setupTestDB(t)
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products").
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{1, "Laptop", "Fast", 999.0, 0, "Computers"}, []driver.Value{2, "Mouse", "Wireless", 19.5, 4, "Computers"}))
+	body := testkit.Serve(routes(), testkit.NewRequest("GET", "/", nil)).Body.String()
+	if !strings.Contains(body, "Out of stock") || !strings.Contains(body, "4 in stock") {
+		t.Errorf("Expected the availability of both products, got %s", body)
+	}
+
+	mock.ExpectQuery("SELECT id, name, description, price, stock, category FROM products WHERE stock > 0").
+		WillReturnRows(testkit.Rows(productColumnNames, []driver.Value{2, "Mouse", "Wireless", 19.5, 4, "Computers"}))
+	body = testkit.Serve(routes(), testkit.NewRequest("GET", "/?hide_out_of_stock=1", nil)).Body.String()
+	if strings.Contains(body, "Laptop") || !strings.Contains(body, "checked") {
+		t.Errorf("Expected the filter to be applied, got %s", body)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
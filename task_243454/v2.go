@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 )
 
 type User struct {
@@ -24,14 +23,14 @@ type AuthService interface {
 	GetUserFromToken(token string) (*User, error)
 }
 
-type defaultAuthService struct{}
-
-func (s *defaultAuthService) GetUserFromToken(token string) (*User, error) {
-	return &User{ID: 1, Name: "Test", Role: "Admin"}, nil
-}
-
-func NewAuthService() AuthService {
-	return &defaultAuthService{}
+// NewAuthService validates JWTs with the keys configured in the environment. It
+// fails with ErrNoJWTKey when none is configured.
+func NewAuthService() (AuthService, error) {
+	svc, err := NewJWTAuthService(JWTConfigFromEnv())
+	if err != nil {
+		return nil, err
+	}
+	return svc, nil
 }
 
 func AuthMiddleware(authService AuthService) func(http.Handler) http.Handler {
@@ -55,16 +54,6 @@ func AuthMiddleware(authService AuthService) func(http.Handler) http.Handler {
 	}
 }
 
-func TimeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), timeout)
-			defer cancel()
-			next.ServeHTTP(w, r.WithContext(ctx))
-		})
-	}
-}
-
 func UserHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value(UserKey).(*User)
 	fmt.Println(user)
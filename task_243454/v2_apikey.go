@@ -0,0 +1,282 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	// apiKeyPrefix marks keys so they are recognizable in configs and secret scanners
+	apiKeyPrefix = "ak_"
+)
+
+const APIKeyKey ContextKey = "apiKey"
+
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// APIKey is a machine client credential. Only the SHA-256 hash of the key is stored,
+// keys are 256 bit random values so a slow password hash is not needed.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Hash   string   `json:"-"`
+	Scopes []string `json:"scopes"`
+	// Hint is the start of the key, to tell keys apart in listings
+	Hint       string    `json:"hint"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	Revoked    bool      `json:"revoked"`
+}
+
+// HasScope reports whether the key grants scope, "*" grants every scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == "*" || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) valid(now time.Time) bool {
+	return !k.Revoked && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+type APIKeyStore interface {
+	Create(ctx context.Context, key *APIKey) error
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	List(ctx context.Context) ([]*APIKey, error)
+	Revoke(ctx context.Context, id string) error
+	// Touch records the last use of a key
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// GenerateAPIKey returns a new key, which is shown to its owner once, and the hash
+// to store
+func GenerateAPIKey() (key, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, hashAPIKey(key)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuth authenticates requests by the key in the X-API-Key header and stores the
+// APIKey in the context. Missing, unknown, revoked and expired keys get 401.
+func APIKeyAuth(store APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw := r.Header.Get(APIKeyHeader)
+			if raw == "" {
+				writeProblem(w, r, http.StatusUnauthorized, "API key required")
+				return
+			}
+
+			key, err := store.FindByHash(r.Context(), hashAPIKey(raw))
+			now := time.Now()
+			if err != nil || !key.valid(now) {
+				writeProblem(w, r, http.StatusUnauthorized, "invalid API key")
+				return
+			}
+			// a failed update must not reject an otherwise valid request
+			store.Touch(r.Context(), key.ID, now)
+
+			ctx := context.WithValue(r.Context(), APIKeyKey, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func APIKeyFromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(APIKeyKey).(*APIKey)
+	return key, ok
+}
+
+// RequireScope rejects requests whose API key lacks scope with 403, it runs after
+// APIKeyAuth
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := APIKeyFromContext(r.Context())
+			if !ok {
+				writeProblem(w, r, http.StatusUnauthorized, "API key required")
+				return
+			}
+			if !key.HasScope(scope) {
+				writeProblem(w, r, http.StatusForbidden, "API key lacks scope "+scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// APIKeyAdminHandler serves the key management API relative to its mount point:
+//
+//	GET    /      lists keys
+//	POST   /      mints a key from {"name", "scopes", "ttl_seconds"}, the key is only returned here
+//	DELETE /{id}  revokes a key
+//
+// Bodies must be JSON of at most DefaultMaxBodyBytes. It does no authorization
+// itself, mount it behind an admin policy:
+//
+//	mux.Handle("/admin/keys/", Chain(AuthMiddleware(auth), RequireRole("Admin"))(
+//		http.StripPrefix("/admin/keys", APIKeyAdminHandler(store))))
+func APIKeyAdminHandler(store APIKeyStore) http.Handler {
+	return JSONBody(DefaultMaxBodyBytes)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.Trim(r.URL.Path, "/")
+		switch {
+		case id == "" && r.Method == http.MethodGet:
+			keys, err := store.List(r.Context())
+			if err != nil {
+				writeProblem(w, r, http.StatusInternalServerError, "listing API keys failed")
+				return
+			}
+			writeJSON(w, http.StatusOK, keys)
+		case id == "" && r.Method == http.MethodPost:
+			mintAPIKey(w, r, store)
+		case id != "" && r.Method == http.MethodDelete:
+			err := store.Revoke(r.Context(), id)
+			if errors.Is(err, ErrAPIKeyNotFound) {
+				writeProblem(w, r, http.StatusNotFound, "API key "+id+" not found")
+				return
+			}
+			if err != nil {
+				writeProblem(w, r, http.StatusInternalServerError, "revoking API key failed")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeProblem(w, r, http.StatusMethodNotAllowed, "")
+		}
+	}))
+}
+
+func mintAPIKey(w http.ResponseWriter, r *http.Request, store APIKeyStore) {
+	var req struct {
+		Name       string   `json:"name"`
+		Scopes     []string `json:"scopes"`
+		TTLSeconds int64    `json:"ttl_seconds"`
+	}
+	const invalid = "name is required and ttl_seconds must not be negative"
+	if !decodeJSON(w, r, &req, invalid) {
+		return
+	}
+	if req.Name == "" || req.TTLSeconds < 0 {
+		writeProblem(w, r, http.StatusBadRequest, invalid)
+		return
+	}
+
+	raw, hash := GenerateAPIKey()
+	key := &APIKey{
+		ID:        randomHex(8),
+		Name:      req.Name,
+		Hash:      hash,
+		Scopes:    req.Scopes,
+		Hint:      raw[:len(apiKeyPrefix)+4],
+		CreatedAt: time.Now().UTC(),
+	}
+	if req.TTLSeconds > 0 {
+		key.ExpiresAt = key.CreatedAt.Add(time.Duration(req.TTLSeconds) * time.Second)
+	}
+	if err := store.Create(r.Context(), key); err != nil {
+		writeProblem(w, r, http.StatusInternalServerError, "creating API key failed")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, struct {
+		*APIKey
+		Key string `json:"key"`
+	}{key, raw})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// MemoryAPIKeyStore keeps keys in the process, for tests and single instance services
+type MemoryAPIKeyStore struct {
+	mu     sync.Mutex
+	byID   map[string]*APIKey
+	byHash map[string]*APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{byID: make(map[string]*APIKey), byHash: make(map[string]*APIKey)}
+}
+
+func (s *MemoryAPIKeyStore) Create(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *key
+	s.byID[key.ID] = &stored
+	s.byHash[key.Hash] = &stored
+	return nil
+}
+
+// FindByHash returns a copy, so callers cannot race with Touch
+func (s *MemoryAPIKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *MemoryAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]*APIKey, 0, len(s.byID))
+	for _, key := range s.byID {
+		k := *key
+		keys = append(keys, &k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.byID[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	key.Revoked = true
+	return nil
+}
+
+func (s *MemoryAPIKeyStore) Touch(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.byID[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	key.LastUsedAt = at
+	return nil
+}
@@ -0,0 +1,105 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	admin := APIKeyAdminHandler(store)
+	api := APIKeyAuth(store)(RequireScope("search:read")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	mint := func(body string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		admin.ServeHTTP(rr, req)
+		var resp map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+	call := func(key string) int {
+		req := httptest.NewRequest("GET", "/search", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		api.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	status, minted := mint(`{"name":"indexer","scopes":["search:read"]}`)
+	key, _ := minted["key"].(string)
+	if status != http.StatusCreated || !strings.HasPrefix(key, apiKeyPrefix) || minted["hash"] != nil {
+		t.Fatalf("got status %d and response %v", status, minted)
+	}
+	_, unscoped := mint(`{"name":"reporting","scopes":["reports:read"]}`)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{name: "valid key with scope", key: key, wantStatus: http.StatusOK},
+		{name: "missing key", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", key: apiKeyPrefix + "unknown", wantStatus: http.StatusUnauthorized},
+		{name: "key without scope", key: unscoped["key"].(string), wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := call(tt.key); got != tt.wantStatus {
+				t.Errorf("got status %d want %d", got, tt.wantStatus)
+			}
+		})
+	}
+
+	found, _ := store.FindByHash(context.Background(), hashAPIKey(key))
+	if found.LastUsedAt.IsZero() {
+		t.Errorf("last use of the key was not recorded")
+	}
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), key) || strings.Contains(rr.Body.String(), found.Hash) {
+		t.Errorf("got status %d and listing %s, want keys without secrets", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("DELETE", "/"+found.ID, nil))
+	if rr.Code != http.StatusNoContent || call(key) != http.StatusUnauthorized {
+		t.Errorf("got status %d, want the revoked key to be rejected", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest("DELETE", "/missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("got status %d revoking an unknown key want %d", rr.Code, http.StatusNotFound)
+	}
+
+	if status, _ := mint(`{"scopes":["*"]}`); status != http.StatusBadRequest {
+		t.Errorf("got status %d minting a key without a name want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	store := NewMemoryAPIKeyStore()
+	raw, hash := GenerateAPIKey()
+	store.Create(context.Background(), &APIKey{ID: "1", Hash: hash, Scopes: []string{"*"}})
+	store.byID["1"].ExpiresAt = store.byID["1"].CreatedAt.Add(1)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(APIKeyHeader, raw)
+	APIKeyAuth(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d for an expired key want %d", rr.Code, http.StatusUnauthorized)
+	}
+}
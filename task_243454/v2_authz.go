@@ -0,0 +1,101 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// RolePermissions grants permissions to roles. A permission "users:*" grants every
+// permission of the users resource and "*" grants everything.
+var RolePermissions = map[string][]string{
+	"Admin":  {"*"},
+	"Editor": {"users:read", "users:write"},
+	"Viewer": {"users:read"},
+}
+
+// HasRole reports whether the user has one of roles, role names are case insensitive
+func (u *User) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if strings.EqualFold(u.Role, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the user's role grants perm in RolePermissions
+func (u *User) Can(perm string) bool {
+	for role, granted := range RolePermissions {
+		if !strings.EqualFold(u.Role, role) {
+			continue
+		}
+		for _, g := range granted {
+			if g == "*" || g == perm || (strings.HasSuffix(g, ":*") && strings.HasPrefix(perm, strings.TrimSuffix(g, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Policy declares who may call a route: the user needs one of Roles, when set,
+// and every one of Permissions. The zero Policy only requires an authenticated user.
+type Policy struct {
+	Roles       []string
+	Permissions []string
+}
+
+// Middleware enforces the policy on the user set by AuthMiddleware, so it must run
+// after it. Requests without a user get 401, users the policy denies get 403.
+func (p Policy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value(UserKey).(*User)
+		if !ok || user == nil {
+			writeProblem(w, r, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if len(p.Roles) > 0 && !user.HasRole(p.Roles...) {
+			writeProblem(w, r, http.StatusForbidden, fmt.Sprintf("requires role %s", strings.Join(p.Roles, " or ")))
+			return
+		}
+		for _, perm := range p.Permissions {
+			if !user.Can(perm) {
+				writeProblem(w, r, http.StatusForbidden, fmt.Sprintf("requires permission %s", perm))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return Policy{Roles: roles}.Middleware
+}
+
+func RequirePermission(perm string) func(http.Handler) http.Handler {
+	return Policy{Permissions: []string{perm}}.Middleware
+}
+
+// Routes declares per-route policies in one place:
+//
+//	Routes{
+//		"/users":       {Handler: listUsers, Policy: Policy{Permissions: []string{"users:read"}}},
+//		"/admin/stats": {Handler: stats, Policy: Policy{Roles: []string{"Admin"}}},
+//	}.Register(mux, AuthMiddleware(auth))
+type Routes map[string]Route
+
+type Route struct {
+	Handler http.Handler
+	Policy  Policy
+}
+
+// Register adds every route to mux behind auth and the route's policy
+func (routes Routes) Register(mux *http.ServeMux, auth func(http.Handler) http.Handler) {
+	for pattern, route := range routes {
+		mux.Handle(pattern, Chain(auth, route.Policy.Middleware)(route.Handler))
+	}
+}
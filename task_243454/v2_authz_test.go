@@ -0,0 +1,89 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type roleAuthService struct{}
+
+func (roleAuthService) GetUserFromToken(token string) (*User, error) {
+	return &User{ID: 1, Name: token, Role: token}, nil
+}
+
+func TestPolicyMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux := http.NewServeMux()
+	Routes{
+		"/admin":  {Handler: ok, Policy: Policy{Roles: []string{"Admin"}}},
+		"/users":  {Handler: ok, Policy: Policy{Permissions: []string{"users:read"}}},
+		"/edit":   {Handler: ok, Policy: Policy{Roles: []string{"Admin", "Editor"}, Permissions: []string{"users:write"}}},
+		"/public": {Handler: ok},
+	}.Register(mux, AuthMiddleware(roleAuthService{}))
+
+	tests := []struct {
+		name       string
+		path       string
+		role       string
+		wantStatus int
+	}{
+		{name: "admin role", path: "/admin", role: "Admin", wantStatus: http.StatusOK},
+		{name: "role is case insensitive", path: "/admin", role: "admin", wantStatus: http.StatusOK},
+		{name: "missing role", path: "/admin", role: "Viewer", wantStatus: http.StatusForbidden},
+		{name: "granted permission", path: "/users", role: "Viewer", wantStatus: http.StatusOK},
+		{name: "wildcard permission", path: "/users", role: "Admin", wantStatus: http.StatusOK},
+		{name: "unknown role", path: "/users", role: "Guest", wantStatus: http.StatusForbidden},
+		{name: "role and permission", path: "/edit", role: "Editor", wantStatus: http.StatusOK},
+		{name: "authenticated user only", path: "/public", role: "Guest", wantStatus: http.StatusOK},
+		{name: "unauthenticated", path: "/public", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.role != "" {
+				req.Header.Set("Authorization", tt.role)
+			}
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireRoleProblem(t *testing.T) {
+	handler := RequireRole("Admin")(http.HandlerFunc(UserHandler))
+
+	for _, user := range []*User{nil, {ID: 2, Role: "Viewer"}} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), UserKey, user))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Fatalf("got invalid problem body: %v", err)
+		}
+		if rr.Header().Get("Content-Type") != "application/problem+json" || problem.Status != rr.Code {
+			t.Errorf("got content type %q and problem %+v", rr.Header().Get("Content-Type"), problem)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	rr := httptest.NewRecorder()
+	RequirePermission("users:delete")(http.HandlerFunc(UserHandler)).ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), UserKey, &User{Role: "Editor"})))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("got status %d want %d", rr.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/errs"
+)
+
+// DefaultMaxBodyBytes limits request bodies in DefaultStack and the JSON APIs of this package
+const DefaultMaxBodyBytes = bodylimit.DefaultMaxBytes
+
+// JSONBody is the body policy of JSON APIs: bodies up to limit bytes of application/json
+func JSONBody(limit int64) func(http.Handler) http.Handler {
+	return Chain(bodylimit.MaxBytes(limit), bodylimit.RequireContentType("application/json"))
+}
+
+// decodeJSON decodes the request body into v and writes the problem response when
+// it fails: 413 when bodylimit.MaxBytes cut the body off, 400 with badRequest otherwise
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, badRequest string) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	if tooLarge := bodylimit.Exceeded(err); tooLarge != nil {
+		errs.Write(w, r, tooLarge)
+		return false
+	}
+	writeProblem(w, r, http.StatusBadRequest, badRequest)
+	return false
+}
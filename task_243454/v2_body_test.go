@@ -0,0 +1,78 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONBody(t *testing.T) {
+	var decoded map[string]string
+	handler := JSONBody(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decoded = nil
+		if decodeJSON(w, r, &decoded, "invalid body") {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		body        io.Reader
+		contentType string
+		wantStatus  int
+	}{
+		{name: "small JSON body", method: "POST", body: strings.NewReader(`{"a":"b"}`), contentType: "application/json", wantStatus: http.StatusNoContent},
+		{name: "media type parameters are ignored", method: "PUT", body: strings.NewReader(`{"a":"b"}`), contentType: "Application/JSON; charset=utf-8", wantStatus: http.StatusNoContent},
+		{name: "declared length over the limit", method: "POST", body: strings.NewReader(`{"a":"0123456789abcdef"}`), contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge},
+		// a reader of unknown length is only cut off while it is read
+		{name: "streamed body over the limit", method: "POST", body: io.MultiReader(strings.NewReader(`{"a":"0123456789abcdef"}`)), contentType: "application/json", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "wrong content type", method: "POST", body: strings.NewReader(`a=b`), contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing content type", method: "POST", body: strings.NewReader(`{}`), wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed JSON", method: "POST", body: strings.NewReader(`{`), contentType: "application/json", wantStatus: http.StatusBadRequest},
+		{name: "requests without a body pass", method: "DELETE", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", tt.body)
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if rr.Code >= 400 && rr.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("got Content-Type %q want a problem body", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestDefaultStackLimitsBodies(t *testing.T) {
+	var read int
+	handler := DefaultStack(StackConfig{MaxBodyBytes: 8})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		read = len(b)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("0123456789")))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d want %d", rr.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader("0123456789"))))
+	if read != 8 {
+		t.Errorf("handler read %d bytes want the 8 allowed", read)
+	}
+}
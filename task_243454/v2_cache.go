@@ -0,0 +1,261 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CachedResponse is a response as stored in a CacheStore
+type CachedResponse struct {
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"stored_at"`
+}
+
+type CacheStore interface {
+	// Get returns nil without an error when key is not cached
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+}
+
+// DefaultCacheKey keys responses by the request URI. Requests with credentials are
+// not cached, their responses may differ per caller; use a KeyFunc that includes
+// the caller to cache them. Requests with an API key are cached per key once
+// APIKeyAuth has authenticated it, and not at all before.
+func DefaultCacheKey(r *http.Request) string {
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		return ""
+	}
+	if r.Header.Get(APIKeyHeader) != "" {
+		key, ok := APIKeyFromContext(r.Context())
+		if !ok {
+			return ""
+		}
+		return "apikey:" + key.ID + ":" + r.URL.RequestURI()
+	}
+	return r.URL.RequestURI()
+}
+
+// CacheMiddleware caches successful GET responses for ttl. An empty key from keyFunc
+// skips the cache. Request Cache-Control directives are honored: no-store bypasses the
+// cache, no-cache revalidates by calling the handler and max-age limits the age of a
+// cached response. Responses marked no-store or private, or that set cookies, are not
+// stored. The X-Cache header tells whether a response was a HIT or a MISS.
+func CacheMiddleware(ttl time.Duration, keyFunc KeyFunc, store CacheStore) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = DefaultCacheKey
+	}
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ""
+			if r.Method == http.MethodGet {
+				key = keyFunc(r)
+			}
+			directives := parseCacheControl(r.Header.Get("Cache-Control"))
+			if _, noStore := directives["no-store"]; key == "" || noStore {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, noCache := directives["no-cache"]; !noCache {
+				cached, err := store.Get(r.Context(), key)
+				if err == nil && cached != nil && fresh(cached, directives) {
+					for name, values := range cached.Header {
+						w.Header()[name] = values
+					}
+					w.Header().Set("X-Cache", "HIT")
+					w.Header().Set("Age", strconv.Itoa(int(time.Since(cached.StoredAt)/time.Second)))
+					w.WriteHeader(cached.Status)
+					w.Write(cached.Body)
+					return
+				}
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			// the headers set so far belong to this request, such as its request ID and
+			// rate limits, only the ones the handler adds are stored
+			before := w.Header().Clone()
+			cw := &cacheWriter{ResponseWriter: w}
+			next.ServeHTTP(cw, r)
+
+			if cw.status == 0 {
+				cw.status, cw.header = http.StatusOK, w.Header().Clone()
+			}
+			if cw.status == http.StatusOK && storable(cw.header) {
+				// a failed store only costs the next request a MISS
+				store.Set(r.Context(), key, &CachedResponse{
+					Status:   cw.status,
+					Header:   addedHeaders(before, cw.header),
+					Body:     cw.body.Bytes(),
+					StoredAt: time.Now(),
+				}, ttl)
+			}
+		})
+	}
+}
+
+// addedHeaders returns the headers of after that before doesn't have with the same
+// values
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			added[name] = values
+		}
+	}
+	return added
+}
+
+// cacheWriter writes the response through and keeps a copy for the cache
+type cacheWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+func fresh(cached *CachedResponse, directives map[string]string) bool {
+	maxAge, ok := directives["max-age"]
+	if !ok {
+		return true
+	}
+	seconds, err := strconv.Atoi(maxAge)
+	return err == nil && time.Since(cached.StoredAt) <= time.Duration(seconds)*time.Second
+}
+
+func storable(header http.Header) bool {
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	_, noStore := directives["no-store"]
+	_, private := directives["private"]
+	return !noStore && !private
+}
+
+type cacheEntry struct {
+	resp    *CachedResponse
+	expires time.Time
+}
+
+// MemoryCacheStore keeps responses in the process, expired entries are dropped
+// when they are read or by Sweep
+type MemoryCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func NewMemoryCacheStore() *MemoryCacheStore {
+	return &MemoryCacheStore{entries: make(map[string]cacheEntry)}
+}
+
+func (s *MemoryCacheStore) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.entries, key)
+		return nil, nil
+	}
+	return entry.resp, nil
+}
+
+func (s *MemoryCacheStore) Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = cacheEntry{resp: resp, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Sweep removes expired entries, call it periodically for keys that are not read again
+func (s *MemoryCacheStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// RedisCacheStore shares cached responses between instances, Redis expires them
+type RedisCacheStore struct {
+	Client redis.Cmdable
+	Prefix string
+}
+
+func NewRedisCacheStore(client redis.Cmdable) *RedisCacheStore {
+	return &RedisCacheStore{Client: client, Prefix: "cache:"}
+}
+
+func (s *RedisCacheStore) Get(ctx context.Context, key string) (*CachedResponse, error) {
+	data, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resp CachedResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *RedisCacheStore) Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.Client.Set(ctx, s.Prefix+key, data, ttl).Err()
+}
@@ -0,0 +1,134 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheMiddleware(t *testing.T) {
+	calls := 0
+	handler := CacheMiddleware(time.Minute, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/private":
+			w.Header().Set("Cache-Control", "private")
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "call %d", calls)
+	}))
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		headers   map[string]string
+		wantCache string
+		wantBody  string
+	}{
+		{name: "first request", path: "/items?q=a", wantCache: "MISS", wantBody: "call 1"},
+		{name: "cached", path: "/items?q=a", wantCache: "HIT", wantBody: "call 1"},
+		{name: "query is part of the key", path: "/items?q=b", wantCache: "MISS", wantBody: "call 2"},
+		{name: "no-cache revalidates", path: "/items?q=a", headers: map[string]string{"Cache-Control": "no-cache"}, wantCache: "MISS", wantBody: "call 3"},
+		{name: "revalidated response is cached", path: "/items?q=a", wantCache: "HIT", wantBody: "call 3"},
+		{name: "no-store bypasses", path: "/items?q=a", headers: map[string]string{"Cache-Control": "no-store"}, wantBody: "call 4"},
+		{name: "max-age accepts a fresh response", path: "/items?q=a", headers: map[string]string{"Cache-Control": "max-age=60"}, wantCache: "HIT", wantBody: "call 3"},
+		{name: "credentials bypass", path: "/items?q=a", headers: map[string]string{"Authorization": "Bearer x"}, wantBody: "call 5"},
+		{name: "post bypasses", method: "POST", path: "/items?q=a", wantBody: "call 6"},
+		{name: "private response", path: "/private", wantCache: "MISS", wantBody: "call 7"},
+		{name: "private response is not stored", path: "/private", wantCache: "MISS", wantBody: "call 8"},
+		{name: "error response", path: "/missing", wantCache: "MISS", wantBody: "call 9"},
+		{name: "error response is not stored", path: "/missing", wantCache: "MISS", wantBody: "call 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("got X-Cache %q want %q", got, tt.wantCache)
+			}
+			if rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %q want %q", rr.Body.String(), tt.wantBody)
+			}
+			if tt.wantCache == "HIT" && (rr.Header().Get("Content-Type") != "text/plain" || rr.Header().Get("Age") == "") {
+				t.Errorf("got headers %v", rr.Header())
+			}
+		})
+	}
+}
+
+func TestCacheKeepsPerRequestHeaders(t *testing.T) {
+	handler := Chain(RequestIDMiddleware, CacheMiddleware(time.Minute, nil, nil))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, "items")
+	}))
+
+	for _, id := range []string{"req-1", "req-2"} {
+		req := httptest.NewRequest("GET", "/items", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get(RequestIDHeader); got != id {
+			t.Errorf("got request ID %q want %q (X-Cache %s)", got, id, rr.Header().Get("X-Cache"))
+		}
+		if rr.Header().Get("Content-Type") != "text/plain" {
+			t.Errorf("got headers %v", rr.Header())
+		}
+	}
+}
+
+func TestDefaultCacheKeyAPIKey(t *testing.T) {
+	anonymous := httptest.NewRequest("GET", "/items", nil)
+	unauthenticated := httptest.NewRequest("GET", "/items", nil)
+	unauthenticated.Header.Set(APIKeyHeader, "ak_secret")
+	authenticated := unauthenticated.WithContext(context.WithValue(unauthenticated.Context(), APIKeyKey, &APIKey{ID: "key1"}))
+	other := unauthenticated.WithContext(context.WithValue(unauthenticated.Context(), APIKeyKey, &APIKey{ID: "key2"}))
+
+	if got := DefaultCacheKey(unauthenticated); got != "" {
+		t.Errorf("got key %q for an API key that was not authenticated, want no caching", got)
+	}
+	keys := map[string]bool{DefaultCacheKey(anonymous): true, DefaultCacheKey(authenticated): true, DefaultCacheKey(other): true}
+	if len(keys) != 3 || strings.Contains(DefaultCacheKey(authenticated), "ak_secret") {
+		t.Errorf("got keys %v, want one per API key without the secret", keys)
+	}
+}
+
+func TestMemoryCacheStoreExpiry(t *testing.T) {
+	store := NewMemoryCacheStore()
+	handler := CacheMiddleware(time.Millisecond, nil, store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	time.Sleep(5 * time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("got X-Cache %q for an expired entry want MISS", rr.Header().Get("X-Cache"))
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	store.Sweep()
+	if len(store.entries) != 0 {
+		t.Errorf("got %d entries after Sweep want 0", len(store.entries))
+	}
+}
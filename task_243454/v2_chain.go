@@ -0,0 +1,74 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/idempotency"
+)
+
+// Chain composes middlewares so that the first one is the outermost:
+// Chain(a, b)(h) is a(b(h)).
+func Chain(middlewares ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(final http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			final = middlewares[i](final)
+		}
+		return final
+	}
+}
+
+type StackConfig struct {
+	Logger *slog.Logger
+	// TrustedProxies are the proxies whose forwarded headers tell the client address
+	TrustedProxies []netip.Prefix
+	Timeout        time.Duration
+	// MaxBodyBytes limits request bodies, 0 means DefaultMaxBodyBytes and a negative
+	// value disables the limit
+	MaxBodyBytes int64
+	AuthService  AuthService
+	// Idempotency, when set, makes POST and PUT requests with an Idempotency-Key
+	// header replay their first response for idempotency.DefaultTTL
+	Idempotency idempotency.Store
+}
+
+// DefaultStack is the standard middleware order for services: the request ID and
+// client address exist before anything logs, recovery sits inside logging so
+// recovered panics are logged as 500s, oversized bodies are rejected before any work
+// is done and authentication runs within the timeout. Auth is skipped when no AuthService is set.
+func DefaultStack(cfg StackConfig) func(http.Handler) http.Handler {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	middlewares := []func(http.Handler) http.Handler{
+		RequestIDMiddleware,
+		ClientIPMiddleware(ClientIPResolver{TrustedProxies: cfg.TrustedProxies}),
+		LoggingMiddleware(cfg.Logger),
+		RecoveryMiddleware(cfg.Logger),
+	}
+	if cfg.MaxBodyBytes > 0 {
+		middlewares = append(middlewares, bodylimit.MaxBytes(cfg.MaxBodyBytes))
+	}
+	middlewares = append(middlewares, TimeoutMiddleware(cfg.Timeout))
+	if cfg.AuthService != nil {
+		middlewares = append(middlewares, AuthMiddleware(cfg.AuthService))
+	}
+	// after auth, the stored responses are scoped to the user
+	if cfg.Idempotency != nil {
+		middlewares = append(middlewares, IdempotencyMiddleware(cfg.Idempotency, idempotency.DefaultTTL))
+	}
+	return Chain(middlewares...)
+}
@@ -0,0 +1,133 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := Chain(record("first"), record("second"), record("third"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got := strings.Join(order, ","); got != "first,second,third,handler" {
+		t.Errorf("got order %q want %q", got, "first,second,third,handler")
+	}
+
+	var called bool
+	Chain()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !called {
+		t.Error("empty chain did not call the handler")
+	}
+}
+
+func TestDefaultStack(t *testing.T) {
+	var logs bytes.Buffer
+	stack := DefaultStack(StackConfig{
+		Logger:      slog.New(slog.NewTextHandler(&logs, nil)),
+		Timeout:     time.Second,
+		AuthService: &mockAuthService{},
+	})
+
+	tests := []struct {
+		name       string
+		token      string
+		requestID  string
+		handler    http.HandlerFunc
+		wantStatus int
+	}{
+		{
+			name:       "authenticated request reaches the handler",
+			token:      "valid-token",
+			handler:    UserHandler,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token is rejected inside the stack",
+			handler:    UserHandler,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:      "panics are recovered",
+			token:     "valid-token",
+			requestID: "req-123",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", "/user", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			rr := httptest.NewRecorder()
+
+			stack(tt.handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v want %v", rr.Code, tt.wantStatus)
+			}
+			id := rr.Header().Get(RequestIDHeader)
+			if id == "" || (tt.requestID != "" && id != tt.requestID) {
+				t.Errorf("unexpected request ID %q", id)
+			}
+			if !strings.Contains(logs.String(), "request_id="+id) {
+				t.Errorf("logs do not mention the request ID:\n%s", logs.String())
+			}
+		})
+	}
+}
+
+// panickingHandler is a named function so its frame can be found in the logged stack
+func panickingHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func TestDefaultStackPanicStack(t *testing.T) {
+	var logs bytes.Buffer
+	stack := DefaultStack(StackConfig{
+		Logger:  slog.New(slog.NewJSONHandler(&logs, nil)),
+		Timeout: time.Second,
+	})
+
+	rr := httptest.NewRecorder()
+	stack(http.HandlerFunc(panickingHandler)).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %v want %v", rr.Code, http.StatusInternalServerError)
+	}
+	if !strings.Contains(logs.String(), ".panickingHandler(") {
+		t.Errorf("logged stack does not contain the handler frame:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), `"error":"boom"`) {
+		t.Errorf("logged error is not the panic value:\n%s", logs.String())
+	}
+}
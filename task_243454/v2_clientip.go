@@ -0,0 +1,169 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const ClientIPKey ContextKey = "clientIP"
+
+// ParsePrefixes parses CIDRs such as "10.0.0.0/8", single addresses are accepted as
+// prefixes of their full length
+func ParsePrefixes(values ...string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address or CIDR %q", value)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPResolver finds the address of the client behind the proxies in front of
+// the service. Forwarded headers are only believed when the request comes from one
+// of TrustedProxies, anyone else could send them to spoof their address.
+type ClientIPResolver struct {
+	TrustedProxies []netip.Prefix
+}
+
+// Resolve walks X-Forwarded-For from the right, skipping trusted proxies, and returns
+// the first address that is not one; X-Real-IP is used when X-Forwarded-For is
+// missing. An invalid entry stops the walk at the last proxy that was trusted.
+func (res ClientIPResolver) Resolve(r *http.Request) (netip.Addr, bool) {
+	addr, ok := remoteAddr(r)
+	if !ok || !containsAddr(res.TrustedProxies, addr) {
+		return addr, ok
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	if len(hops) == 0 {
+		if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return real.Unmap(), true
+		}
+		return addr, true
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return addr, true
+		}
+		addr = hop.Unmap()
+		if !containsAddr(res.TrustedProxies, addr) {
+			return addr, true
+		}
+	}
+	// every hop is a proxy, the leftmost is the closest to the client
+	return addr, true
+}
+
+// ClientIPMiddleware stores the resolved client address in the context, where
+// ClientIP, IPFilter and the request log read it. In DefaultStack it runs before
+// logging.
+func ClientIPMiddleware(res ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr, ok := res.Resolve(r); ok {
+				r = r.WithContext(context.WithValue(r.Context(), ClientIPKey, addr))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIPFromContext returns the address set by ClientIPMiddleware
+func ClientIPFromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(ClientIPKey).(netip.Addr)
+	return addr, ok
+}
+
+// clientAddr is the resolved client address, or the remote address of requests
+// that did not pass ClientIPMiddleware
+func clientAddr(r *http.Request) (netip.Addr, bool) {
+	if addr, ok := ClientIPFromContext(r.Context()); ok {
+		return addr, true
+	}
+	return remoteAddr(r)
+}
+
+// clientIPString formats the client address for keys and logs, falling back to the
+// raw remote address when it cannot be parsed
+func clientIPString(r *http.Request) string {
+	if addr, ok := clientAddr(r); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// IPFilter admits clients by address. Deny wins over Allow, and when Allow is set
+// only clients in it are admitted:
+//
+//	internal, _ := ParsePrefixes("10.0.0.0/8", "192.168.0.0/16")
+//	mux.Handle("/admin/", IPFilter{Allow: internal}.Middleware(admin))
+type IPFilter struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+func (f IPFilter) Allows(addr netip.Addr) bool {
+	if containsAddr(f.Deny, addr) {
+		return false
+	}
+	return len(f.Allow) == 0 || containsAddr(f.Allow, addr)
+}
+
+// Middleware rejects clients the filter does not admit with 403, by the address
+// ClientIPMiddleware resolved. Requests whose address cannot be parsed are rejected
+// when there are any rules.
+func (f IPFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := clientAddr(r)
+		if (!ok && (len(f.Allow) > 0 || len(f.Deny) > 0)) || (ok && !f.Allows(addr)) {
+			writeProblem(w, r, http.StatusForbidden, "client address is not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,131 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	proxies, err := ParsePrefixes("10.0.0.0/8", "fd00::/8", "203.0.113.7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := ClientIPResolver{TrustedProxies: proxies}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{name: "direct client", remoteAddr: "198.51.100.1:1234", want: "198.51.100.1"},
+		{name: "headers from untrusted clients are ignored", remoteAddr: "198.51.100.1:1234", forwarded: []string{"1.2.3.4"}, realIP: "5.6.7.8", want: "198.51.100.1"},
+		{name: "through one proxy", remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "trusted hops are skipped", remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, 203.0.113.7", "10.1.1.1"}, want: "198.51.100.1"},
+		{name: "spoofed entries left of the client are ignored", remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "invalid hop stops at the last proxy", remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, bogus, 10.1.1.1"}, want: "10.1.1.1"},
+		{name: "all hops are proxies", remoteAddr: "10.0.0.1:1234", forwarded: []string{"10.2.2.2, 10.1.1.1"}, want: "10.2.2.2"},
+		{name: "X-Real-IP without X-Forwarded-For", remoteAddr: "10.0.0.1:1234", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "IPv6 proxy", remoteAddr: "[fd00::1]:1234", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "IPv4-mapped addresses are unmapped", remoteAddr: "[::ffff:10.0.0.1]:1234", forwarded: []string{"::ffff:198.51.100.1"}, want: "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			got, ok := res.Resolve(req)
+			if !ok || got.String() != tt.want {
+				t.Errorf("got %v (%v) want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	allow, _ := ParsePrefixes("198.51.100.0/24")
+	deny, _ := ParsePrefixes("198.51.100.66")
+	proxies, _ := ParsePrefixes("10.0.0.0/8")
+	handler := Chain(
+		ClientIPMiddleware(ClientIPResolver{TrustedProxies: proxies}),
+		IPFilter{Allow: allow, Deny: deny}.Middleware,
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		wantStatus int
+	}{
+		{name: "allowed client", remoteAddr: "198.51.100.1:1234", wantStatus: http.StatusOK},
+		{name: "client outside the allowlist", remoteAddr: "192.0.2.1:1234", wantStatus: http.StatusForbidden},
+		{name: "deny wins over allow", remoteAddr: "198.51.100.66:1234", wantStatus: http.StatusForbidden},
+		{name: "allowed client behind a proxy", remoteAddr: "10.0.0.1:1234", forwarded: "198.51.100.1", wantStatus: http.StatusOK},
+		{name: "denied client behind a proxy", remoteAddr: "10.0.0.1:1234", forwarded: "198.51.100.66", wantStatus: http.StatusForbidden},
+		{name: "spoofed header from outside", remoteAddr: "192.0.2.1:1234", forwarded: "198.51.100.1", wantStatus: http.StatusForbidden},
+		{name: "unparseable address", remoteAddr: "pipe", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestParsePrefixesRejectsInvalid(t *testing.T) {
+	if _, err := ParsePrefixes("10.0.0.0/8", "10.0.0.300"); err == nil {
+		t.Error("got no error for an invalid address")
+	}
+}
+
+func TestRateLimitUsesResolvedClientIP(t *testing.T) {
+	proxies, _ := ParsePrefixes("10.0.0.0/8")
+	handler := Chain(
+		ClientIPMiddleware(ClientIPResolver{TrustedProxies: proxies}),
+		RateLimitMiddleware(1, time.Minute, nil),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// every request arrives from the load balancer, the clients only differ by header
+	for _, tt := range []struct {
+		client     string
+		wantStatus int
+	}{
+		{"198.51.100.1", http.StatusOK},
+		{"198.51.100.2", http.StatusOK},
+		{"198.51.100.1", http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", tt.client)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.wantStatus {
+			t.Errorf("got status %d for %s want %d", rr.Code, tt.client, tt.wantStatus)
+		}
+	}
+}
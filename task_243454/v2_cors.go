@@ -0,0 +1,20 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+
+	"awesomeProject/platform/cors"
+)
+
+// CORSConfig is applied per handler, so routes can differ in what they allow, see
+// cors.Config
+type CORSConfig = cors.Config
+
+// CORSMiddleware is cors.Middleware: it answers preflight requests itself and adds
+// the CORS headers to requests from allowed origins
+func CORSMiddleware(cfg CORSConfig) func(http.Handler) http.Handler {
+	return cors.Middleware(cfg)
+}
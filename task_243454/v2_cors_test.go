@@ -0,0 +1,130 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	public := CORSMiddleware(CORSConfig{AllowedOrigins: []string{"*"}})(ok)
+	private := CORSMiddleware(CORSConfig{
+		AllowedOrigins:   []string{"https://*.example.com", "https://app.test"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		ExposedHeaders:   []string{"X-RateLimit-Remaining"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})(ok)
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		method      string
+		headers     map[string]string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "no origin",
+			handler:     public,
+			method:      "GET",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "any origin",
+			handler:     public,
+			method:      "GET",
+			headers:     map[string]string{"Origin": "https://evil.test"},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*", "Vary": "Origin"},
+		},
+		{
+			name:       "wildcard subdomain with credentials",
+			handler:    private,
+			method:     "GET",
+			headers:    map[string]string{"Origin": "https://api.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://api.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-RateLimit-Remaining",
+			},
+		},
+		{
+			name:        "wildcard does not match the bare domain",
+			handler:     private,
+			method:      "GET",
+			headers:     map[string]string{"Origin": "https://example.com"},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "preflight",
+			handler:    private,
+			method:     "OPTIONS",
+			headers:    map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "content-type, authorization"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.test",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "content-type, authorization",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name:       "preflight for a method not allowed",
+			handler:    private,
+			method:     "OPTIONS",
+			headers:    map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "DELETE"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "preflight for a header not allowed",
+			handler:    private,
+			method:     "OPTIONS",
+			headers:    map[string]string{"Origin": "https://app.test", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Custom"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "preflight from an origin not allowed",
+			handler:    private,
+			method:     "OPTIONS",
+			headers:    map[string]string{"Origin": "https://evil.test", "Access-Control-Request-Method": "GET"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "plain OPTIONS request reaches the handler",
+			handler:     private,
+			method:      "OPTIONS",
+			headers:     map[string]string{"Origin": "https://app.test"},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := rr.Header().Get(key); got != want {
+					t.Errorf("got %s %q want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"awesomeProject/platform/idempotency"
+)
+
+// IdempotencyMiddleware is idempotency.Middleware scoped to the user set by
+// AuthMiddleware or the key set by APIKeyAuth, so it runs after them. The body is
+// buffered to fingerprint it, limit it with bodylimit.MaxBytes.
+func IdempotencyMiddleware(store idempotency.Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return idempotency.Middleware(store, ttl, idempotencyCaller)
+}
+
+// idempotencyCaller scopes the client's keys to its user or API key, so clients cannot
+// collide with or read each other's responses
+func idempotencyCaller(r *http.Request) string {
+	if user, ok := r.Context().Value(UserKey).(*User); ok && user != nil {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	if apiKey, ok := APIKeyFromContext(r.Context()); ok {
+		return "key:" + apiKey.ID
+	}
+	return ""
+}
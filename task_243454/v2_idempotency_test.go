@@ -0,0 +1,149 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/platform/idempotency"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	created := 0
+	fail := false
+	handler := IdempotencyMiddleware(nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "database down", http.StatusInternalServerError)
+			return
+		}
+		created++
+		w.Header().Set("Location", fmt.Sprintf("/products/%d", created))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%d}`, created)
+	}))
+
+	send := func(method, path, key, body string, user *User) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		if user != nil {
+			req = req.WithContext(context.WithValue(req.Context(), UserKey, user))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	alice, bob := &User{ID: 1}, &User{ID: 2}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		key          string
+		body         string
+		user         *User
+		fail         bool
+		wantStatus   int
+		wantBody     string
+		wantReplayed bool
+	}{
+		{name: "first request runs", method: "POST", path: "/products", key: "k1", body: "a", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":1}`},
+		{name: "retry is replayed", method: "POST", path: "/products", key: "k1", body: "a", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":1}`, wantReplayed: true},
+		{name: "other body with the same key", method: "POST", path: "/products", key: "k1", body: "b", user: alice, wantStatus: http.StatusUnprocessableEntity},
+		{name: "other user with the same key", method: "POST", path: "/products", key: "k1", body: "a", user: bob, wantStatus: http.StatusCreated, wantBody: `{"id":2}`},
+		{name: "other route with the same key", method: "POST", path: "/users", key: "k1", body: "a", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":3}`},
+		{name: "requests without a key are not deduplicated", method: "POST", path: "/products", body: "a", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":4}`},
+		{name: "safe methods are passed through", method: "GET", path: "/products", key: "k1", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":5}`},
+		{name: "server errors are not stored", method: "PUT", path: "/products/1", key: "k2", user: alice, fail: true, wantStatus: http.StatusInternalServerError},
+		{name: "retry after a server error runs again", method: "PUT", path: "/products/1", key: "k2", user: alice, wantStatus: http.StatusCreated, wantBody: `{"id":6}`},
+		{name: "overlong key", method: "POST", path: "/products", key: strings.Repeat("k", 256), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail = tt.fail
+			rr := send(tt.method, tt.path, tt.key, tt.body, tt.user)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d: %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("got body %s want %s", rr.Body.String(), tt.wantBody)
+			}
+			if replayed := rr.Header().Get(idempotency.ReplayedHeader) == "true"; replayed != tt.wantReplayed {
+				t.Errorf("got replayed %v want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && rr.Header().Get("Location") != "/products/1" {
+				t.Errorf("got Location %q, want the stored headers replayed", rr.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestIdempotencyConcurrentRetry(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := IdempotencyMiddleware(nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+	request := func() *http.Request {
+		req := httptest.NewRequest("POST", "/inventory", strings.NewReader("item"))
+		req.Header.Set(idempotency.Header, "k")
+		return req
+	}
+
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request())
+		done <- rr.Code
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request())
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("got status %d for a retry in flight want %d with Retry-After", rr.Code, http.StatusConflict)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("got status %d for the first request want %d", code, http.StatusCreated)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(nil, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	request := func() *http.Request {
+		req := httptest.NewRequest("POST", "/products", strings.NewReader("a"))
+		req.Header.Set(idempotency.Header, "k")
+		return req
+	}
+
+	func() {
+		defer func() { recover() }()
+		handler.ServeHTTP(httptest.NewRecorder(), request())
+	}()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request())
+
+	if rr.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("got status %d after %d calls for the retry after a panic want it to run again", rr.Code, calls)
+	}
+}
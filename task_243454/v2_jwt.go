@@ -0,0 +1,299 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTConfig selects the accepted keys and claims. HS256 tokens are accepted when
+// HMACSecret is set, RS256 tokens when PublicKey or JWKSURL is set.
+type JWTConfig struct {
+	HMACSecret []byte
+	PublicKey  *rsa.PublicKey
+	JWKSURL    string
+	JWKSTTL    time.Duration
+	Audience   string
+	Issuer     string
+	// Leeway tolerates clock skew in exp and nbf checks
+	Leeway time.Duration
+}
+
+func JWTConfigFromEnv() JWTConfig {
+	cfg := JWTConfig{
+		JWKSURL:  os.Getenv("JWT_JWKS_URL"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Leeway:   30 * time.Second,
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		cfg.HMACSecret = []byte(secret)
+	}
+	return cfg
+}
+
+// UserClaims are the claims mapped to a User: sub is the numeric user ID
+type UserClaims struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+type JWTAuthService struct {
+	config JWTConfig
+	parser *jwt.Parser
+	jwks   *JWKSCache
+}
+
+// ErrNoJWTKey is returned when neither a secret, a public key nor a JWKS URL is
+// configured, as no token could be verified
+var ErrNoJWTKey = errors.New("no JWT secret, public key or JWKS URL configured")
+
+func NewJWTAuthService(config JWTConfig) (*JWTAuthService, error) {
+	var methods []string
+	if len(config.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if config.PublicKey != nil || config.JWKSURL != "" {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	// An empty list would let the parser accept any algorithm
+	if len(methods) == 0 {
+		return nil, ErrNoJWTKey
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+
+	s := &JWTAuthService{config: config, parser: jwt.NewParser(options...)}
+	if config.JWKSURL != "" {
+		s.jwks = NewJWKSCache(config.JWKSURL, config.JWKSTTL)
+	}
+	return s, nil
+}
+
+// GetUserFromToken verifies the signature and the exp, nbf, aud and iss claims of a
+// token, with or without the "Bearer " prefix, and maps its claims to a User
+func (s *JWTAuthService) GetUserFromToken(token string) (*User, error) {
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = token[7:]
+	}
+
+	var claims UserClaims
+	if _, err := s.parser.ParseWithClaims(token, &claims, s.key); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject %q: %w", claims.Subject, err)
+	}
+	return &User{ID: id, Name: claims.Name, Role: claims.Role}, nil
+}
+
+// key returns the verification key for the token's algorithm. The parser has
+// already rejected algorithms that are not configured, the checks here keep a
+// missing key from ever verifying a token.
+func (s *JWTAuthService) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(s.config.HMACSecret) == 0 {
+			return nil, ErrNoJWTKey
+		}
+		return s.config.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if s.jwks != nil && (kid != "" || s.config.PublicKey == nil) {
+		return s.jwks.Key(context.Background(), kid)
+	}
+	if s.config.PublicKey == nil {
+		return nil, ErrNoJWTKey
+	}
+	return s.config.PublicKey, nil
+}
+
+// JWKSCache fetches RSA signing keys from a JWKS endpoint. Keys are refreshed when
+// the TTL expires or a token names an unknown kid, which is how rotated keys show up.
+// Refetches for unknown kids, and retries after a failed fetch, are limited to one
+// per MinRefresh. Concurrent lookups share a single fetch.
+type JWKSCache struct {
+	URL        string
+	Client     *http.Client
+	TTL        time.Duration
+	MinRefresh time.Duration
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PublicKey
+	// fetched is the time of the last fetch, err its error
+	fetched time.Time
+	err     error
+	// refreshing is closed when the fetch in progress is done
+	refreshing chan struct{}
+}
+
+func NewJWKSCache(url string, ttl time.Duration) *JWKSCache {
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &JWKSCache{
+		URL:        url,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		TTL:        ttl,
+		MinRefresh: time.Minute,
+	}
+}
+
+var ErrUnknownKey = errors.New("no JWKS key matches the token kid")
+
+// Key returns the key with the given kid, an empty kid matches a single published key
+func (c *JWKSCache) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	key, found := c.lookup(kid)
+	interval := c.TTL
+	if !found || c.err != nil {
+		interval = c.MinRefresh
+	}
+	if time.Since(c.fetched) < interval {
+		err := c.err
+		c.mu.Unlock()
+		switch {
+		case found:
+			// Keep serving cached keys while the endpoint is down
+			return key, nil
+		case err != nil:
+			return nil, fmt.Errorf("fetching JWKS: %w", err)
+		}
+		return nil, ErrUnknownKey
+	}
+
+	done := c.refreshing
+	if done == nil {
+		done = make(chan struct{})
+		c.refreshing = done
+		c.mu.Unlock()
+
+		keys, err := c.fetch(ctx)
+		c.mu.Lock()
+		if err == nil {
+			c.keys = keys
+		}
+		c.fetched, c.err = time.Now(), err
+		c.refreshing = nil
+		close(done)
+	} else {
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	defer c.mu.Unlock()
+
+	if key, found = c.lookup(kid); found {
+		return key, nil
+	}
+	if c.err != nil {
+		return nil, fmt.Errorf("fetching JWKS: %w", c.err)
+	}
+	return nil, ErrUnknownKey
+}
+
+func (c *JWKSCache) lookup(kid string) (*rsa.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// fetch downloads the published keys, it runs without holding the mutex
+func (c *JWKSCache) fetch(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		key, err := jwk.rsaKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() < 3 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
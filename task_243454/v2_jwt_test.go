@@ -0,0 +1,281 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testSecret = []byte("test-secret")
+
+func signHS256(t *testing.T, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newJWTAuthService(t *testing.T, config JWTConfig) *JWTAuthService {
+	t.Helper()
+	svc, err := NewJWTAuthService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func validClaims() UserClaims {
+	now := time.Now()
+	return UserClaims{
+		Name: "Alice",
+		Role: "Admin",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "42",
+			Audience:  jwt.ClaimStrings{"api"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+}
+
+func TestJWTAuthServiceHS256(t *testing.T) {
+	svc := newJWTAuthService(t, JWTConfig{HMACSecret: testSecret, Audience: "api"})
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	notYetValid := validClaims()
+	notYetValid.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"other"}
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	badSubject := validClaims()
+	badSubject.Subject = "alice"
+
+	wrongKey, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("other-secret"))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid token", token: signHS256(t, validClaims())},
+		{name: "bearer prefix", token: "Bearer " + signHS256(t, validClaims())},
+		{name: "expired", token: signHS256(t, expired), wantErr: true},
+		{name: "not valid yet", token: signHS256(t, notYetValid), wantErr: true},
+		{name: "wrong audience", token: signHS256(t, wrongAudience), wantErr: true},
+		{name: "missing expiry", token: signHS256(t, noExpiry), wantErr: true},
+		{name: "non numeric subject", token: signHS256(t, badSubject), wantErr: true},
+		{name: "wrong signature", token: wrongKey, wantErr: true},
+		{name: "alg none", token: unsigned, wantErr: true},
+		{name: "garbage", token: "not-a-jwt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := svc.GetUserFromToken(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got user %+v, want error", user)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if *user != (User{ID: 42, Name: "Alice", Role: "Admin"}) {
+				t.Errorf("got user %+v", *user)
+			}
+		})
+	}
+}
+
+func TestJWTAuthServiceWithoutKey(t *testing.T) {
+	if _, err := NewJWTAuthService(JWTConfig{Audience: "api"}); !errors.Is(err, ErrNoJWTKey) {
+		t.Fatalf("got error %v, want ErrNoJWTKey", err)
+	}
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_JWKS_URL", "")
+	if svc, err := NewAuthService(); svc != nil || !errors.Is(err, ErrNoJWTKey) {
+		t.Fatalf("got %v and error %v, want no service without a configured key", svc, err)
+	}
+
+	// A service with only a public key still rejects tokens signed with an empty secret
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	svc := newJWTAuthService(t, JWTConfig{PublicKey: &key.PublicKey})
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS256, jwt.SigningMethodHS512} {
+		token, _ := jwt.NewWithClaims(method, validClaims()).SignedString([]byte{})
+		if user, err := svc.GetUserFromToken(token); err == nil {
+			t.Errorf("got user %+v for %s signed with an empty key", user, method.Alg())
+		}
+	}
+}
+
+// jwksServer publishes the current keys and counts fetches
+type jwksServer struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func (s *jwksServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	for kid, key := range s.keys {
+		set.Keys = append(set.Keys, jsonWebKey{
+			Kid: kid,
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims())
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJWTAuthServiceJWKSRotation(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	jwks := &jwksServer{keys: map[string]*rsa.PrivateKey{"k1": oldKey}}
+	srv := httptest.NewServer(jwks)
+	defer srv.Close()
+
+	svc := newJWTAuthService(t, JWTConfig{JWKSURL: srv.URL})
+	svc.jwks.MinRefresh = 0
+
+	if _, err := svc.GetUserFromToken(signRS256(t, oldKey, "k1")); err != nil {
+		t.Fatalf("got error %v for the current key", err)
+	}
+	if _, err := svc.GetUserFromToken(signRS256(t, oldKey, "k1")); err != nil || jwks.fetches != 1 {
+		t.Fatalf("got error %v and %d fetches, want the cached key", err, jwks.fetches)
+	}
+
+	jwks.mu.Lock()
+	jwks.keys["k2"] = newKey
+	jwks.mu.Unlock()
+	if _, err := svc.GetUserFromToken(signRS256(t, newKey, "k2")); err != nil || jwks.fetches != 2 {
+		t.Fatalf("got error %v and %d fetches, want a refetch for the rotated key", err, jwks.fetches)
+	}
+
+	if _, err := svc.GetUserFromToken(signRS256(t, newKey, "k1")); err == nil {
+		t.Errorf("got no error for a token signed with the wrong key")
+	}
+	if _, err := svc.GetUserFromToken(signHS256(t, validClaims())); err == nil {
+		t.Errorf("got no error for HS256 without a configured secret")
+	}
+
+	svc.jwks.MinRefresh = time.Hour
+	if _, err := svc.GetUserFromToken(signRS256(t, newKey, "unknown")); err == nil || jwks.fetches != 2 {
+		t.Errorf("got error %v and %d fetches, want unknown kids not to refetch within MinRefresh", err, jwks.fetches)
+	}
+}
+
+func TestJWKSCacheEndpointDown(t *testing.T) {
+	var mu sync.Mutex
+	fetches := 0
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cache := NewJWKSCache(srv.URL, time.Hour)
+	var wg sync.WaitGroup
+	for _, kid := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func(kid string) {
+			defer wg.Done()
+			if _, err := cache.Key(context.Background(), kid); err == nil {
+				t.Errorf("got a key for %q from an endpoint that is down", kid)
+			}
+		}(kid)
+	}
+	// The lookups wait for the one fetch in progress
+	for {
+		mu.Lock()
+		n := fetches
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, kid := range []string{"e", "f"} {
+		if _, err := cache.Key(context.Background(), kid); err == nil || errors.Is(err, ErrUnknownKey) {
+			t.Errorf("got error %v for %q, want the fetch error", err, kid)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("got %d fetches, want unknown kids not to refetch within MinRefresh after a failure", fetches)
+	}
+}
+
+func TestAuthMiddlewareWithJWT(t *testing.T) {
+	svc := newJWTAuthService(t, JWTConfig{HMACSecret: testSecret})
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid token", token: "Bearer " + signHS256(t, validClaims()), wantStatus: http.StatusOK},
+		{name: "expired token", token: "Bearer " + signHS256(t, expired), wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", tt.token)
+			rr := httptest.NewRecorder()
+			AuthMiddleware(svc)(http.HandlerFunc(UserHandler)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// statusWriter remembers the status code and size of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// body captures the start of the response when it is sampled
+	body *bodyCapture
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	if w.body != nil {
+		w.body.Write(b[:n])
+	}
+	return n, err
+}
+
+// DefaultRedactFields are the JSON and form fields whose values are never logged
+var DefaultRedactFields = []string{"password", "token", "access_token", "refresh_token", "secret", "api_key", "authorization"}
+
+type LoggingConfig struct {
+	// BodySampleRate is the fraction of requests, from 0 to 1, logged with their
+	// request and response bodies
+	BodySampleRate float64
+	// MaxBodyBytes caps the logged part of each body, 4 KiB by default
+	MaxBodyBytes int
+	// RedactFields are matched case insensitively at any depth, DefaultRedactFields
+	// by default
+	RedactFields []string
+}
+
+func LoggingMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return LoggingMiddlewareWithConfig(logger, LoggingConfig{})
+}
+
+// LoggingMiddlewareWithConfig logs every request and, for a sample of them, the
+// bodies. JSON and form bodies are logged with sensitive fields redacted, other
+// content types and bodies cut off at MaxBodyBytes are only logged by size, so a
+// secret never reaches the logs unredacted.
+func LoggingMiddlewareWithConfig(logger *slog.Logger, cfg LoggingConfig) func(http.Handler) http.Handler {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 4 << 10
+	}
+	if cfg.RedactFields == nil {
+		cfg.RedactFields = DefaultRedactFields
+	}
+	redact := make(map[string]bool, len(cfg.RedactFields))
+	for _, field := range cfg.RedactFields {
+		redact[strings.ToLower(field)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+
+			var reqBody *bodyCapture
+			sampled := cfg.BodySampleRate > 0 && rand.Float64() < cfg.BodySampleRate
+			if sampled {
+				reqBody = &bodyCapture{max: cfg.MaxBodyBytes}
+				if r.Body != nil && r.Body != http.NoBody {
+					r.Body = &capturingReader{ReadCloser: r.Body, capture: reqBody}
+				}
+				sw.body = &bodyCapture{max: cfg.MaxBodyBytes}
+			}
+			next.ServeHTTP(sw, r)
+
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", sw.status,
+				"bytes", sw.bytes,
+				"duration", time.Since(start),
+				"client_ip", clientIPString(r),
+				"request_id", RequestIDFromContext(r.Context()),
+				"trace_id", TraceIDFromContext(r.Context()),
+			}
+			if sampled {
+				attrs = append(attrs,
+					"request_body", reqBody.loggable(r.Header.Get("Content-Type"), redact),
+					"response_body", sw.body.loggable(sw.Header().Get("Content-Type"), redact),
+				)
+			}
+			logger.Info("request", attrs...)
+		})
+	}
+}
+
+// bodyCapture keeps the first max bytes written to it and counts the rest
+type bodyCapture struct {
+	max   int
+	data  []byte
+	total int
+}
+
+func (c *bodyCapture) Write(b []byte) (int, error) {
+	n := len(b)
+	c.total += n
+	if room := c.max - len(c.data); room > 0 {
+		if n > room {
+			b = b[:room]
+		}
+		c.data = append(c.data, b...)
+	}
+	return n, nil
+}
+
+// capturingReader records the request body as the handler reads it, so bodies the
+// handler does not read are not consumed by logging
+type capturingReader struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+func (r *capturingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.Write(p[:n])
+	return n, err
+}
+
+func (c *bodyCapture) loggable(contentType string, redact map[string]bool) any {
+	if c.total == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if c.total <= c.max {
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			var v any
+			if json.Unmarshal(c.data, &v) == nil {
+				return redactJSON(v, redact)
+			}
+		case mediaType == "application/x-www-form-urlencoded":
+			if values, err := url.ParseQuery(string(c.data)); err == nil {
+				for key := range values {
+					if redact[strings.ToLower(key)] {
+						values[key] = []string{"[REDACTED]"}
+					}
+				}
+				return values.Encode()
+			}
+		}
+	}
+	if mediaType == "" {
+		mediaType = "unknown content type"
+	}
+	return fmt.Sprintf("[%d bytes of %s]", c.total, mediaType)
+}
+
+func redactJSON(v any, redact map[string]bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = "[REDACTED]"
+			} else {
+				v[key] = redactJSON(value, redact)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactJSON(value, redact)
+		}
+	}
+	return v
+}
@@ -0,0 +1,105 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoggingMiddlewareBodies(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		response     string
+		wantRequest  any
+		wantResponse any
+	}{
+		{
+			name:         "json fields are redacted at any depth",
+			contentType:  "application/json",
+			body:         `{"user":"alice","Password":"hunter2","auth":{"token":"abc"}}`,
+			response:     `{"access_token":"xyz","ok":true}`,
+			wantRequest:  map[string]any{"user": "alice", "Password": "[REDACTED]", "auth": map[string]any{"token": "[REDACTED]"}},
+			wantResponse: map[string]any{"access_token": "[REDACTED]", "ok": true},
+		},
+		{
+			name:         "form fields are redacted",
+			contentType:  "application/x-www-form-urlencoded",
+			body:         "user=alice&password=hunter2",
+			wantRequest:  "password=%5BREDACTED%5D&user=alice",
+			wantResponse: "",
+		},
+		{
+			name:         "truncated bodies are only logged by size",
+			contentType:  "application/json",
+			body:         `{"password":"` + strings.Repeat("x", 100) + `"}`,
+			wantRequest:  "[115 bytes of application/json]",
+			wantResponse: "",
+		},
+		{
+			name:         "other content types are only logged by size",
+			contentType:  "text/plain",
+			body:         "password hunter2",
+			wantRequest:  "[16 bytes of text/plain]",
+			wantResponse: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			handler := LoggingMiddlewareWithConfig(logger, LoggingConfig{BodySampleRate: 1, MaxBodyBytes: 64})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.ReadAll(r.Body)
+				if tt.response != "" {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(tt.response))
+				}
+			}))
+
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("got invalid log entry %q: %v", logs.String(), err)
+			}
+			if got, _ := json.Marshal(entry["request_body"]); !jsonEqual(got, tt.wantRequest) {
+				t.Errorf("got request body %s want %v", got, tt.wantRequest)
+			}
+			if got, _ := json.Marshal(entry["response_body"]); !jsonEqual(got, tt.wantResponse) {
+				t.Errorf("got response body %s want %v", got, tt.wantResponse)
+			}
+			if strings.Contains(logs.String(), "hunter2") {
+				t.Errorf("secret leaked into the log: %s", logs.String())
+			}
+		})
+	}
+}
+
+func jsonEqual(got []byte, want any) bool {
+	expected, _ := json.Marshal(want)
+	return string(got) == string(expected)
+}
+
+func TestLoggingMiddlewareWithoutSampling(t *testing.T) {
+	var logs bytes.Buffer
+	handler := LoggingMiddleware(slog.New(slog.NewJSONHandler(&logs, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(`{"a":1}`)))
+
+	if !strings.Contains(logs.String(), `"status":202`) || strings.Contains(logs.String(), "request_body") {
+		t.Errorf("got log %s", logs.String())
+	}
+}
@@ -0,0 +1,51 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"awesomeProject/platform/telemetry"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OTelMiddleware is telemetry.Middleware within RequestIDMiddleware: it replaces the
+// trace context of the request with the span's, so logs carry the trace ID of the
+// exported trace
+func OTelMiddleware(route telemetry.RouteFunc) func(http.Handler) http.Handler {
+	return Chain(telemetry.Middleware(route), spanTraceMiddleware)
+}
+
+func spanTraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(withSpanTrace(r.Context(), trace.SpanFromContext(r.Context()))))
+	})
+}
+
+// withSpanTrace makes the span's IDs the request's trace context, keeping tracestate
+func withSpanTrace(ctx context.Context, span trace.Span) context.Context {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return ctx
+	}
+	tc, _ := TraceFromContext(ctx)
+	tc.TraceID, tc.SpanID, tc.Sampled = sc.TraceID().String(), sc.SpanID().String(), sc.IsSampled()
+	if state := sc.TraceState().String(); state != "" {
+		tc.State = state
+	}
+	return context.WithValue(ctx, TraceKey, tc)
+}
+
+// NewInstrumentedClient returns a copy of client whose requests are client spans,
+// propagating the trace context, baggage and request ID of the request context
+func NewInstrumentedClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	instrumented := *client
+	// the client span's traceparent replaces the one PropagatingTransport sets
+	instrumented.Transport = &PropagatingTransport{Base: telemetry.Transport(client.Transport)}
+	return &instrumented
+}
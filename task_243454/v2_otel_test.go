@@ -0,0 +1,115 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupTestTelemetry(t *testing.T) (*tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	return spans, reader
+}
+
+func TestOTelMiddleware(t *testing.T) {
+	spans, reader := setupTestTelemetry(t)
+
+	var downstream http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	client := NewInstrumentedClient(nil)
+
+	var logTraceID string
+	handler := Chain(RequestIDMiddleware, OTelMiddleware(func(r *http.Request) string { return "/users/{id}" }))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logTraceID = TraceIDFromContext(r.Context())
+			req, _ := http.NewRequestWithContext(r.Context(), "GET", srv.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+	req := httptest.NewRequest("GET", "/users/7", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.Ended()
+	if len(ended) != 2 {
+		t.Fatalf("got %d spans want a client and a server span", len(ended))
+	}
+	clientSpan, serverSpan := ended[0], ended[1]
+
+	if serverSpan.Name() != "GET /users/{id}" || serverSpan.SpanKind() != trace.SpanKindServer {
+		t.Errorf("got server span %q of kind %v", serverSpan.Name(), serverSpan.SpanKind())
+	}
+	if serverSpan.Parent().SpanID().String() != "00f067aa0ba902b7" || serverSpan.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span did not continue the caller's trace: parent %v", serverSpan.Parent())
+	}
+	if logTraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("got trace ID %q in the request context", logTraceID)
+	}
+	if serverSpan.Status().Code != codes.Error || !hasAttribute(serverSpan.Attributes(), attribute.Int("http.response.status_code", http.StatusBadGateway)) {
+		t.Errorf("got server span status %v and attributes %v", serverSpan.Status(), serverSpan.Attributes())
+	}
+
+	if clientSpan.Parent().SpanID() != serverSpan.SpanContext().SpanID() || clientSpan.SpanKind() != trace.SpanKindClient {
+		t.Errorf("client span is not a child of the server span")
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + clientSpan.SpanContext().SpanID().String() + "-01"
+	if downstream.Get(TraceparentHeader) != want || downstream.Get(RequestIDHeader) != "req-1" {
+		t.Errorf("got downstream traceparent %q and request ID %q want %q", downstream.Get(TraceparentHeader), downstream.Get(RequestIDHeader), want)
+	}
+
+	var metrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &metrics); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, scope := range metrics.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "http.server.request.duration" {
+				found = len(h.DataPoints) == 1 && h.DataPoints[0].Count == 1
+			}
+		}
+	}
+	if !found {
+		t.Errorf("got metrics %+v want one http.server.request.duration data point", metrics.ScopeMetrics)
+	}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 7807 error body, extended with the request ID for correlation
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
@@ -0,0 +1,40 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"awesomeProject/platform/ratelimit"
+)
+
+// KeyFunc identifies the client a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// ClientIP keys requests by the client address resolved by ClientIPMiddleware, so
+// clients behind trusted proxies are told apart, and by the remote address without it
+func ClientIP(r *http.Request) string {
+	return "ip:" + clientIPString(r)
+}
+
+// APIKeyOrIP keys requests by the ID of the API key APIKeyAuth authenticated and
+// falls back to the client IP. The raw header is never used: random keys would get
+// a fresh limit each and the secrets would end up in the store.
+func APIKeyOrIP(r *http.Request) string {
+	if key, ok := APIKeyFromContext(r.Context()); ok {
+		return "key:" + key.ID
+	}
+	return ClientIP(r)
+}
+
+// RateLimitMiddleware is ratelimit.Middleware keying requests by ClientIP when
+// keyFunc is nil. Use a ratelimit.Limiter with a ratelimit.RedisStore to share the
+// limits between instances.
+func RateLimitMiddleware(limit int, window time.Duration, keyFunc KeyFunc) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = ClientIP
+	}
+	return ratelimit.Middleware(limit, window, ratelimit.KeyFunc(keyFunc))
+}
@@ -0,0 +1,60 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RateLimitMiddleware(2, time.Minute, APIKeyOrIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		apiKey        string
+		authenticated bool
+		wantStatus    int
+		wantRemaining string
+	}{
+		{name: "first request", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "second request from another port", remoteAddr: "10.0.0.1:5678", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{name: "over the limit", remoteAddr: "10.0.0.1:1234", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "other client", remoteAddr: "10.0.0.2:1234", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "unauthenticated api key counts for the ip", remoteAddr: "10.0.0.1:1234", apiKey: "random", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "api key has its own limit", remoteAddr: "10.0.0.1:1234", apiKey: "abc", authenticated: true, wantStatus: http.StatusOK, wantRemaining: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.authenticated {
+				req = req.WithContext(context.WithValue(req.Context(), APIKeyKey, &APIKey{ID: "key1"}))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+			if got := rr.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("got remaining %q want %q", got, tt.wantRemaining)
+			}
+			if rr.Header().Get("X-RateLimit-Limit") != "2" || rr.Header().Get("X-RateLimit-Reset") == "" {
+				t.Errorf("got headers %v", rr.Header())
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "60" {
+				t.Errorf("got Retry-After %q want 60", rr.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// handlerPanic carries a panic out of the goroutine TimeoutMiddleware runs the handler
+// on, along with the stack of that goroutine, which panicking again would lose
+type handlerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// Error describes the panic when nothing recovers it and net/http logs it
+func (p *handlerPanic) Error() string {
+	return fmt.Sprintf("%v\n%s", p.value, p.stack)
+}
+
+// RecoveryMiddleware turns a panic in a handler into a 500 problem response and logs it
+// with the stack trace and request ID. http.ErrAbortHandler is re-raised, it is the
+// documented way for handlers to abort a response.
+func RecoveryMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				err := recover()
+				if err == nil {
+					return
+				}
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+
+				stack := debug.Stack()
+				if p, ok := err.(*handlerPanic); ok {
+					err, stack = p.value, p.stack
+				}
+				logger.Error("panic recovered",
+					"error", err,
+					"method", r.Method,
+					"path", r.URL.Path,
+					"request_id", RequestIDFromContext(r.Context()),
+					"trace_id", TraceIDFromContext(r.Context()),
+					"stack", string(stack),
+				)
+				// Once the status line is out a 500 cannot be sent anymore
+				if sw.status == 0 {
+					writeProblem(w, r, http.StatusInternalServerError, "the server encountered an unexpected error")
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantStatus  int
+		wantProblem bool
+		wantLog     bool
+	}{
+		{
+			name:       "handler without panic is untouched",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:        "panic with a string",
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			wantStatus:  http.StatusInternalServerError,
+			wantProblem: true,
+			wantLog:     true,
+		},
+		{
+			name: "panic with a runtime error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var user *User
+				w.Write([]byte(user.Name))
+			},
+			wantStatus:  http.StatusInternalServerError,
+			wantProblem: true,
+			wantLog:     true,
+		},
+		{
+			name: "panic after the response started keeps the status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				panic("late")
+			},
+			wantStatus: http.StatusAccepted,
+			wantLog:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			handler := Chain(RequestIDMiddleware, RecoveryMiddleware(logger))(tt.handler)
+
+			req := httptest.NewRequest("GET", "/orders", nil)
+			req.Header.Set(RequestIDHeader, "req-42")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %v want %v", rr.Code, tt.wantStatus)
+			}
+
+			if tt.wantProblem {
+				var problem Problem
+				if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+					t.Fatalf("invalid JSON body %q: %v", rr.Body.String(), err)
+				}
+				if problem.Status != http.StatusInternalServerError || problem.RequestID != "req-42" {
+					t.Errorf("unexpected problem body: %+v", problem)
+				}
+				if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+					t.Errorf("got content type %q", ct)
+				}
+			}
+
+			if !tt.wantLog {
+				if logs.Len() != 0 {
+					t.Errorf("unexpected log output: %s", logs.String())
+				}
+				return
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("invalid log entry %q: %v", logs.String(), err)
+			}
+			stack, _ := entry["stack"].(string)
+			if entry["request_id"] != "req-42" || !strings.Contains(stack, "v2_recovery_test.go") {
+				t.Errorf("log entry lacks request ID or stack trace: %v", entry)
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareReraisesAbort(t *testing.T) {
+	handler := RecoveryMiddleware(slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-raised, got %v", err)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,180 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+const (
+	RequestIDKey ContextKey = "requestID"
+	TraceKey     ContextKey = "trace"
+)
+
+// maxRequestIDLength bounds caller supplied IDs, they end up in every log line
+const maxRequestIDLength = 128
+
+// TraceContext is the W3C trace context of a request: the trace it belongs to, the
+// span of this service and the caller's tracestate, which is passed on unchanged
+type TraceContext struct {
+	TraceID string
+	SpanID  string
+	// ParentID is the caller's span, empty when the trace starts here
+	ParentID string
+	Sampled  bool
+	State    string
+}
+
+// Traceparent formats the header that makes the span the parent of a downstream call
+func (tc TraceContext) Traceparent() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", tc.TraceID, tc.SpanID, flags)
+}
+
+// RequestIDMiddleware keeps the caller's X-Request-ID or generates one, and continues
+// the caller's trace from traceparent or starts a new one. Both are stored in the
+// context and the request ID is echoed in the response.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		tc, ok := parseTraceparent(r.Header.Get(TraceparentHeader))
+		if ok {
+			tc.ParentID, tc.SpanID = tc.SpanID, newSpanID()
+			tc.State = r.Header.Get(TracestateHeader)
+		} else {
+			tc = TraceContext{TraceID: randomHex(16), SpanID: newSpanID(), Sampled: true}
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), RequestIDKey, id)
+		ctx = context.WithValue(ctx, TraceKey, tc)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(RequestIDKey).(string)
+	return id
+}
+
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(TraceKey).(TraceContext)
+	return tc, ok
+}
+
+// TraceIDFromContext returns the trace ID for logs, empty outside a request
+func TraceIDFromContext(ctx context.Context) string {
+	tc, _ := TraceFromContext(ctx)
+	return tc.TraceID
+}
+
+// PropagatingTransport passes the request ID and trace context of the request
+// context on to downstream services. Requests must carry the incoming context:
+//
+//	client := &http.Client{Transport: &PropagatingTransport{}}
+//	req, _ := http.NewRequestWithContext(r.Context(), "GET", url, nil)
+//	client.Do(req)
+type PropagatingTransport struct {
+	// Base defaults to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *PropagatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	id := RequestIDFromContext(req.Context())
+	tc, traced := TraceFromContext(req.Context())
+	if id == "" && !traced {
+		return base.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	if id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if traced {
+		req.Header.Set(TraceparentHeader, tc.Traceparent())
+		if tc.State != "" {
+			req.Header.Set(TracestateHeader, tc.State)
+		}
+	}
+	return base.RoundTrip(req)
+}
+
+// parseTraceparent accepts version 00 headers and the prefix of later versions, as
+// the W3C spec requires. All-zero IDs are invalid.
+func parseTraceparent(header string) (TraceContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || !isLowerHex(parts[0], 2) || parts[0] == "ff" ||
+		(parts[0] == "00" && len(parts) != 4) ||
+		!isLowerHex(parts[1], 32) || !isLowerHex(parts[2], 16) || !isLowerHex(parts[3], 2) ||
+		strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return TraceContext{}, false
+	}
+
+	flags, _ := hex.DecodeString(parts[3])
+	return TraceContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags[0]&1 == 1}, true
+}
+
+func isLowerHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// validRequestID rejects IDs that are empty, too long or not printable ASCII, so a
+// caller cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	return randomHex(16)
+}
+
+func newSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,132 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDMiddlewareTraceContext(t *testing.T) {
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	tests := []struct {
+		name          string
+		headers       map[string]string
+		wantRequestID string
+		wantTraceID   string
+		wantParentID  string
+		wantSampled   bool
+	}{
+		{
+			name:          "caller's trace is continued",
+			headers:       map[string]string{RequestIDHeader: "req-1", TraceparentHeader: parent, TracestateHeader: "vendor=1"},
+			wantRequestID: "req-1",
+			wantTraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID:  "00f067aa0ba902b7",
+			wantSampled:   true,
+		},
+		{
+			name:         "unsampled flag is kept",
+			headers:      map[string]string{TraceparentHeader: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+			wantTraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID: "00f067aa0ba902b7",
+		},
+		{
+			name:         "future versions are accepted",
+			headers:      map[string]string{TraceparentHeader: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+			wantTraceID:  "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantParentID: "00f067aa0ba902b7",
+			wantSampled:  true,
+		},
+		{
+			name:        "all zero trace ID starts a new trace",
+			headers:     map[string]string{TraceparentHeader: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+			wantSampled: true,
+		},
+		{
+			name:        "uppercase hex starts a new trace",
+			headers:     map[string]string{TraceparentHeader: strings.ToUpper(parent)},
+			wantSampled: true,
+		},
+		{
+			name:        "request ID with a newline is replaced",
+			headers:     map[string]string{RequestIDHeader: "x\nlevel=ERROR"},
+			wantSampled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requestID string
+			var tc TraceContext
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requestID = RequestIDFromContext(r.Context())
+				tc, _ = TraceFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if tt.wantRequestID != "" && requestID != tt.wantRequestID || !validRequestID(requestID) {
+				t.Errorf("got request ID %q want %q", requestID, tt.wantRequestID)
+			}
+			if rr.Header().Get(RequestIDHeader) != requestID {
+				t.Errorf("got response request ID %q want %q", rr.Header().Get(RequestIDHeader), requestID)
+			}
+			if tt.wantTraceID != "" && tc.TraceID != tt.wantTraceID || !isLowerHex(tc.TraceID, 32) {
+				t.Errorf("got trace ID %q want %q", tc.TraceID, tt.wantTraceID)
+			}
+			if tc.ParentID != tt.wantParentID || !isLowerHex(tc.SpanID, 16) || tc.SpanID == tc.ParentID {
+				t.Errorf("got span %q with parent %q want parent %q", tc.SpanID, tc.ParentID, tt.wantParentID)
+			}
+			if tc.Sampled != tt.wantSampled {
+				t.Errorf("got sampled %v want %v", tc.Sampled, tt.wantSampled)
+			}
+		})
+	}
+}
+
+func TestPropagatingTransport(t *testing.T) {
+	var downstream http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	var tc TraceContext
+	client := &http.Client{Transport: &PropagatingTransport{}}
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc, _ = TraceFromContext(r.Context())
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if req.Header.Get(RequestIDHeader) != "" {
+			t.Error("transport modified the caller's request")
+		}
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "req-9")
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "vendor=1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if downstream.Get(RequestIDHeader) != "req-9" {
+		t.Errorf("got downstream request ID %q want %q", downstream.Get(RequestIDHeader), "req-9")
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + tc.SpanID + "-01"
+	if downstream.Get(TraceparentHeader) != want || downstream.Get(TracestateHeader) != "vendor=1" {
+		t.Errorf("got downstream traceparent %q and tracestate %q want %q", downstream.Get(TraceparentHeader), downstream.Get(TracestateHeader), want)
+	}
+}
@@ -0,0 +1,97 @@
+//go:build v2
+// +build v2
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	cancelled := make(chan error, 1)
+	writeErr := make(chan error, 1)
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "request completes",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "1")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("done"))
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+		},
+		{
+			name: "request times out",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(time.Second):
+					w.WriteHeader(http.StatusOK)
+				case <-r.Context().Done():
+					cancelled <- r.Context().Err()
+				}
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "writes after the timeout are discarded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				time.Sleep(10 * time.Millisecond)
+				_, err := w.Write([]byte("late"))
+				writeErr <- err
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			rr := httptest.NewRecorder()
+			TimeoutMiddleware(50*time.Millisecond)(tt.handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("got status %d want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && (rr.Body.String() != tt.wantBody || rr.Header().Get("X-Test") != "1") {
+				t.Errorf("got body %q and headers %v", rr.Body.String(), rr.Header())
+			}
+			if tt.wantStatus == http.StatusGatewayTimeout && rr.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("got content type %q want a problem", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got handler context error %v want deadline exceeded", err)
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("got late write error %v want %v", err, http.ErrHandlerTimeout)
+	}
+}
+
+func TestTimeoutMiddlewarePanic(t *testing.T) {
+	handler := RecoveryMiddleware(slog.New(slog.NewTextHandler(io.Discard, nil)))(TimeoutMiddleware(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d want %d", rr.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,337 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// archiveExts are the archives that can be browsed like directories
+var archiveExts = []string{".zip", ".tar.gz", ".tgz", ".tar"}
+
+// archiveExt returns the archive extension of name, or "" if it isn't an archive
+func archiveExt(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(lower, ext) && len(name) > len(ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func isArchive(name string) bool {
+	return archiveExt(name) != ""
+}
+
+// splitArchivePath finds the archive in the ancestors of p. inner is the slash
+// separated path within it, "." for the archive itself. Archives within archives
+// aren't browsed.
+func splitArchivePath(p string) (archive, inner string, ok bool) {
+	p = filepath.Clean(p)
+	for dir := p; ; {
+		if isArchive(dir) {
+			if info, err := os.Stat(dir); err == nil && info.Mode().IsRegular() {
+				rel, err := filepath.Rel(dir, p)
+				if err != nil {
+					return "", "", false
+				}
+				return dir, filepath.ToSlash(rel), true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", false
+		}
+		dir = parent
+	}
+}
+
+func inArchive(p string) bool {
+	_, _, ok := splitArchivePath(p)
+	return ok
+}
+
+// openArchive returns the archive at p as a file system, closing the closer releases
+// it
+func openArchive(p string) (fs.FS, io.Closer, error) {
+	if archiveExt(p) == ".zip" {
+		r, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r, nil
+	}
+	fsys, err := newTarFS(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fsys, io.NopCloser(nil), nil
+}
+
+// openDir opens the directory p to read its entries, p may be in an archive or on
+// a server
+func openDir(p string) (fs.ReadDirFile, error) {
+	if conn, remote, ok := splitRemotePath(p); ok {
+		return openRemoteDir(conn, remote)
+	}
+	archive, inner, ok := splitArchivePath(p)
+	if !ok {
+		return os.Open(p)
+	}
+	fsys, closer, err := openArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.Open(inner)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		f.Close()
+		closer.Close()
+		return nil, &fs.PathError{Op: "readdir", Path: p, Err: syscall.ENOTDIR}
+	}
+	return archiveDir{dir, closer}, nil
+}
+
+// openPath opens the file p, which may be in an archive or on a server
+func openPath(p string) (fs.File, error) {
+	if isRemote(p) {
+		fsys, name, err := fileSystem(p)
+		if err != nil {
+			return nil, err
+		}
+		return fsys.Open(name)
+	}
+	archive, inner, ok := splitArchivePath(p)
+	if !ok {
+		return os.Open(p)
+	}
+	fsys, closer, err := openArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := fsys.Open(inner)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return archiveFile{f, closer}, nil
+}
+
+// statPath returns the file info of p, which may be in an archive or on a server
+func statPath(p string) (fs.FileInfo, error) {
+	if conn, remote, ok := splitRemotePath(p); ok {
+		client, err := remoteClient(conn)
+		if err != nil {
+			return nil, err
+		}
+		return client.Stat(remote)
+	}
+	archive, inner, ok := splitArchivePath(p)
+	if !ok {
+		return os.Stat(p)
+	}
+	fsys, closer, err := openArchive(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+	return fs.Stat(fsys, inner)
+}
+
+// archiveFile closes the archive along with a file opened from it
+type archiveFile struct {
+	fs.File
+	archive io.Closer
+}
+
+func (f archiveFile) Close() error {
+	err := f.File.Close()
+	f.archive.Close()
+	return err
+}
+
+type archiveDir struct {
+	fs.ReadDirFile
+	archive io.Closer
+}
+
+func (d archiveDir) Close() error {
+	err := d.ReadDirFile.Close()
+	d.archive.Close()
+	return err
+}
+
+// newTarReader reads the tar archive in r, decompressing .tar.gz and .tgz files
+func newTarReader(name string, r io.Reader) (*tar.Reader, error) {
+	if ext := archiveExt(name); ext == ".tar.gz" || ext == ".tgz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	}
+	return tar.NewReader(r), nil
+}
+
+// tarFS is a tar archive as a file system. Only the headers are kept, opening a file
+// reads the archive again up to it as compressed tars can't seek.
+type tarFS struct {
+	archive string
+	files   map[string]*tarEntry
+}
+
+type tarEntry struct {
+	info fs.FileInfo
+	// children are the paths of the entries in a directory
+	children []string
+}
+
+func newTarFS(archive string) (*tarFS, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	tr, err := newTarReader(archive, f)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tarFS{archive: archive, files: map[string]*tarEntry{".": {info: implicitDir(".")}}}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(hdr.Name)
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		t.add(name, hdr.FileInfo())
+	}
+	for _, entry := range t.files {
+		sort.Strings(entry.children)
+	}
+	return t, nil
+}
+
+// add stores name, creating the parent directories archives may leave out
+func (t *tarFS) add(name string, info fs.FileInfo) {
+	if entry, ok := t.files[name]; ok {
+		// a later header for the same name replaces the earlier one
+		entry.info = info
+		return
+	}
+	parent := path.Dir(name)
+	if _, ok := t.files[parent]; !ok {
+		t.add(parent, implicitDir(path.Base(parent)))
+	}
+	t.files[name] = &tarEntry{info: info}
+	t.files[parent].children = append(t.files[parent].children, name)
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := t.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry.info.IsDir() {
+		return &tarDir{fsys: t, entry: entry}, nil
+	}
+
+	f, err := os.Open(t.archive)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := newTarReader(t.archive, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			f.Close()
+			if err == io.EOF {
+				err = fs.ErrNotExist
+			}
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if path.Clean(hdr.Name) == name {
+			return &tarFile{Reader: tr, info: entry.info, file: f}, nil
+		}
+	}
+}
+
+type tarFile struct {
+	io.Reader
+	info fs.FileInfo
+	file *os.File
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Close() error               { return f.file.Close() }
+
+type tarDir struct {
+	fsys   *tarFS
+	entry  *tarEntry
+	offset int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.entry.info, nil }
+func (d *tarDir) Close() error               { return nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir follows fs.ReadDirFile, with n > 0 it returns io.EOF once all were read
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entry.children[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(rest) {
+		n = len(rest)
+	}
+	entries := make([]fs.DirEntry, n)
+	for i, name := range rest[:n] {
+		entries[i] = fs.FileInfoToDirEntry(d.fsys.files[name].info)
+	}
+	d.offset += n
+	return entries, nil
+}
+
+// implicitDir is the info of a directory that has no header of its own
+type implicitDir string
+
+func (d implicitDir) Name() string       { return string(d) }
+func (d implicitDir) Size() int64        { return 0 }
+func (d implicitDir) Mode() fs.FileMode  { return fs.ModeDir | 0o755 }
+func (d implicitDir) ModTime() time.Time { return time.Time{} }
+func (d implicitDir) IsDir() bool        { return true }
+func (d implicitDir) Sys() any           { return nil }
@@ -0,0 +1,295 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// compressFormats are the archives compressPath creates
+var compressFormats = []string{".zip", ".tar.gz"}
+
+// progressFunc is told how many of the total bytes were processed
+type progressFunc func(done, total int64)
+
+// countingReader reports the bytes read through it
+type countingReader struct {
+	r        io.Reader
+	done     int64
+	total    int64
+	progress progressFunc
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.done += int64(n)
+	c.progress(c.done, c.total)
+	return n, err
+}
+
+// extractTarget is the directory an archive is extracted to, next to it and named
+// after it
+func extractTarget(archive string) string {
+	name := filepath.Base(archive)
+	return filepath.Join(filepath.Dir(archive), name[:len(name)-len(archiveExt(name))])
+}
+
+// extractArchive extracts archive into the new directory dst, which is removed again
+// if extracting fails. Entries that would end up outside dst fail the extraction,
+// links and special files are skipped.
+func extractArchive(archive, dst string, progress progressFunc) error {
+	if err := os.Mkdir(dst, 0o755); err != nil {
+		return err
+	}
+	var err error
+	if archiveExt(archive) == ".zip" {
+		err = extractZip(archive, dst, progress)
+	} else {
+		err = extractTar(archive, dst, progress)
+	}
+	if err != nil {
+		os.RemoveAll(dst)
+	}
+	return err
+}
+
+func extractZip(archive, dst string, progress progressFunc) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var total int64
+	for _, f := range r.File {
+		total += int64(f.UncompressedSize64)
+	}
+	counter := &countingReader{total: total, progress: progress}
+	for _, f := range r.File {
+		err := func() error {
+			if f.FileInfo().IsDir() {
+				return extractEntry(dst, f.Name, f.Mode(), nil)
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			counter.r = rc
+			return extractEntry(dst, f.Name, f.Mode(), counter)
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTar reports progress through the compressed archive, the uncompressed size
+// isn't known without reading it twice
+func extractTar(archive, dst string, progress progressFunc) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	tr, err := newTarReader(archive, &countingReader{r: f, total: info.Size(), progress: progress})
+	if err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = extractEntry(dst, hdr.Name, hdr.FileInfo().Mode(), nil)
+		case tar.TypeReg:
+			err = extractEntry(dst, hdr.Name, hdr.FileInfo().Mode(), tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractEntry creates the directory name in dst, or the file if r isn't nil
+func extractEntry(dst, name string, mode fs.FileMode, r io.Reader) error {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("archive entry %q points outside the archive", name)
+	}
+	if name == "." {
+		return nil
+	}
+	target := filepath.Join(dst, filepath.FromSlash(name))
+	if r == nil {
+		return os.MkdirAll(target, 0o755)
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// compressPath writes the files and directory trees srcs into the new archive dst,
+// its format chosen by the extension. Links and special files are left out.
+func compressPath(srcs []string, dst string, progress progressFunc) error {
+	var total int64
+	err := walkAllArchived(srcs, func(p, name string, info fs.FileInfo) error {
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	counter := &countingReader{total: total, progress: progress}
+	if archiveExt(dst) == ".zip" {
+		err = writeZip(out, srcs, counter)
+	} else {
+		err = writeTarGz(out, srcs, counter)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+// walkArchived calls fn with the directories and regular files of src and their
+// slash separated names in the archive, which start with the base name of src
+func walkArchived(src string, fn func(p, name string, info fs.FileInfo) error) error {
+	parent := filepath.Dir(src)
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+		return fn(p, filepath.ToSlash(rel), info)
+	})
+}
+
+// walkAllArchived walks every one of srcs with walkArchived
+func walkAllArchived(srcs []string, fn func(p, name string, info fs.FileInfo) error) error {
+	for _, src := range srcs {
+		if err := walkArchived(src, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeZip(w io.Writer, srcs []string, counter *countingReader) error {
+	zw := zip.NewWriter(w)
+	err := walkAllArchived(srcs, func(p, name string, info fs.FileInfo) error {
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+			_, err := zw.CreateHeader(hdr)
+			return err
+		}
+		hdr.Method = zip.Deflate
+		entry, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		return copyCounted(entry, p, counter)
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, srcs []string, counter *countingReader) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := walkAllArchived(srcs, func(p, name string, info fs.FileInfo) error {
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyCounted(tw, p, counter)
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func copyCounted(w io.Writer, p string, counter *countingReader) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	counter.r = f
+	_, err = io.Copy(w, counter)
+	return err
+}
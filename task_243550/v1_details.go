@@ -0,0 +1,209 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// maxTextPreview is how much of a text file is previewed
+	maxTextPreview = 64 << 10
+	// maxImagePreview is the largest image decoded for a preview
+	maxImagePreview = 20 << 20
+)
+
+type previewKind int
+
+const (
+	previewNone previewKind = iota
+	previewText
+	previewImage
+)
+
+// fileDetails is the metadata shown for the selected entry
+type fileDetails struct {
+	Path    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	MIME    string
+	Preview previewKind
+}
+
+// readDetails reads the metadata of path. The MIME type comes from the extension, or
+// from the content when the extension is unknown.
+func readDetails(path string) (fileDetails, error) {
+	info, err := statPath(path)
+	if err != nil {
+		return fileDetails{}, err
+	}
+	d := fileDetails{Path: path, Size: info.Size(), Mode: info.Mode(), ModTime: info.ModTime()}
+	if info.IsDir() {
+		d.MIME = "inode/directory"
+		return d, nil
+	}
+
+	d.MIME = mime.TypeByExtension(filepath.Ext(path))
+	if d.MIME == "" {
+		d.MIME = sniffMIME(path)
+	}
+	switch media := strings.SplitN(d.MIME, ";", 2)[0]; {
+	case media == "image/png" || media == "image/jpeg" || media == "image/svg+xml":
+		if d.Size <= maxImagePreview {
+			d.Preview = previewImage
+		}
+	case strings.HasPrefix(media, "text/") || media == "application/json" || media == "application/xml":
+		d.Preview = previewText
+	}
+	return d, nil
+}
+
+// sniffMIME detects the type from the first bytes of the file
+func sniffMIME(path string) string {
+	f, err := openPath(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	return http.DetectContentType(head[:n])
+}
+
+// readTextPreview returns the start of a text file, false if it isn't valid UTF-8
+func readTextPreview(path string) (string, bool) {
+	f, err := openPath(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxTextPreview))
+	if err != nil {
+		return "", false
+	}
+	// the limit may cut a multi-byte character
+	for i := 0; i < utf8.UTFMax && len(data) == maxTextPreview && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	return string(data), utf8.Valid(data)
+}
+
+// formatSize returns size in the largest unit that keeps it above 1
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// detailsPanel shows the metadata and a preview of the selected file
+type detailsPanel struct {
+	window  fyne.Window
+	info    *widget.Form
+	preview *fyne.Container
+	open    *widget.Button
+	path    string
+	content fyne.CanvasObject
+}
+
+func newDetailsPanel(window fyne.Window) *detailsPanel {
+	d := &detailsPanel{window: window, info: widget.NewForm(), preview: container.NewStack()}
+	d.open = widget.NewButton("Open", d.openDefault)
+	d.content = container.NewBorder(container.NewVBox(d.info, d.open), nil, nil, nil, d.preview)
+	d.show("")
+	return d
+}
+
+// show displays the details of path, or a placeholder when it's empty
+func (d *detailsPanel) show(path string) {
+	d.path = path
+	d.info.Items = nil
+	d.preview.Objects = nil
+	d.open.Disable()
+	defer func() {
+		d.info.Refresh()
+		d.preview.Refresh()
+	}()
+
+	if path == "" {
+		d.info.Append("", widget.NewLabel("No file selected"))
+		return
+	}
+	details, err := readDetails(path)
+	if err != nil {
+		d.info.Append("Error", widget.NewLabel(err.Error()))
+		return
+	}
+
+	d.info.Append("Name", widget.NewLabel(filepath.Base(path)))
+	d.info.Append("Size", widget.NewLabel(formatSize(details.Size)))
+	d.info.Append("Permissions", widget.NewLabel(details.Mode.String()))
+	d.info.Append("Modified", widget.NewLabel(details.ModTime.Format("2006-01-02 15:04:05")))
+	d.info.Append("Type", widget.NewLabel(details.MIME))
+	d.open.Enable()
+
+	switch details.Preview {
+	case previewImage:
+		f, err := openPath(path)
+		if err != nil {
+			break
+		}
+		// read from the file as it may be in an archive
+		image := canvas.NewImageFromReader(f, filepath.Base(path))
+		f.Close()
+		if image == nil {
+			break
+		}
+		image.FillMode = canvas.ImageFillContain
+		image.SetMinSize(fyne.NewSize(200, 200))
+		d.preview.Objects = []fyne.CanvasObject{image}
+	case previewText:
+		if text, ok := readTextPreview(path); ok {
+			label := widget.NewLabel(text)
+			label.TextStyle.Monospace = true
+			d.preview.Objects = []fyne.CanvasObject{container.NewScroll(label)}
+		}
+	}
+}
+
+func (d *detailsPanel) openDefault() {
+	if d.path == "" {
+		return
+	}
+	if err := openWithDefault(d.path); err != nil {
+		dialog.ShowError(err, d.window)
+	}
+}
+
+// openWithDefault opens path with the application the platform associates with it
+func openWithDefault(path string) error {
+	u, err := url.Parse(storage.NewFileURI(path).String())
+	if err != nil {
+		return err
+	}
+	return fyne.CurrentApp().OpenURL(u)
+}
@@ -0,0 +1,463 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// DuplicateFile is a file of a duplicate group as it was when scanned
+type DuplicateFile struct {
+	Path    string
+	ModTime time.Time
+}
+
+// DuplicateGroup holds files with the same size and content
+type DuplicateGroup struct {
+	Size  int64
+	Hash  string
+	Files []DuplicateFile
+}
+
+// Wasted is the space the copies beyond the first take
+func (g DuplicateGroup) Wasted() int64 {
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// DuplicateScan finds files with the same content below a directory. Files are
+// grouped by size first, only files sharing their size with another are hashed, by
+// a pool of workers reading them as streams. Empty files, links and hard links to
+// the same file are left out.
+type DuplicateScan struct {
+	// Workers is the number of files hashed at once, the number of CPUs when 0
+	Workers int
+
+	hashed atomic.Int64
+	total  atomic.Int64
+}
+
+// Progress returns the bytes hashed so far and the bytes to hash, which is known
+// once the tree was walked
+func (s *DuplicateScan) Progress() (done, total int64) {
+	return s.hashed.Load(), s.total.Load()
+}
+
+// Run returns the duplicate groups of root, the most wasted space first. Unreadable
+// files and directories are skipped. Cancelling ctx stops the scan and returns
+// ctx.Err().
+func (s *DuplicateScan) Run(ctx context.Context, root string) ([]DuplicateGroup, error) {
+	bySize := map[int64][]fs.FileInfo{}
+	paths := map[fs.FileInfo]string{}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() == 0 {
+			return nil
+		}
+		for _, other := range bySize[info.Size()] {
+			if os.SameFile(info, other) {
+				return nil
+			}
+		}
+		bySize[info.Size()] = append(bySize[info.Size()], info)
+		paths[info] = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []fs.FileInfo
+	for size, infos := range bySize {
+		if len(infos) > 1 {
+			candidates = append(candidates, infos...)
+			s.total.Add(size * int64(len(infos)))
+		}
+	}
+
+	type key struct {
+		size int64
+		hash string
+	}
+	var mu sync.Mutex
+	groups := map[key][]DuplicateFile{}
+	jobs := make(chan fs.FileInfo)
+	workers := s.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range jobs {
+				hash, err := s.hashFile(ctx, paths[info])
+				if err != nil {
+					continue
+				}
+				k := key{info.Size(), hash}
+				mu.Lock()
+				groups[k] = append(groups[k], DuplicateFile{Path: paths[info], ModTime: info.ModTime()})
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, info := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- info
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var duplicates []DuplicateGroup
+	for k, files := range groups {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+		duplicates = append(duplicates, DuplicateGroup{Size: k.size, Hash: k.hash, Files: files})
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		a, b := duplicates[i], duplicates[j]
+		if a.Wasted() != b.Wasted() {
+			return a.Wasted() > b.Wasted()
+		}
+		return a.Files[0].Path < b.Files[0].Path
+	})
+	return duplicates, nil
+}
+
+// hashFile returns the SHA-256 of a file, stopping early when ctx is cancelled
+func (s *DuplicateScan) hashFile(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	buf := make([]byte, 64*1024)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := f.Read(buf)
+		h.Write(buf[:n])
+		s.hashed.Add(int64(n))
+		if err == io.EOF {
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// KeepRule picks the file of a duplicate group that the selection helpers leave
+// unselected
+type KeepRule string
+
+const (
+	KeepOldest   KeepRule = "Oldest"
+	KeepNewest   KeepRule = "Newest"
+	KeepShortest KeepRule = "Shortest path"
+)
+
+var keepRules = []KeepRule{KeepOldest, KeepNewest, KeepShortest}
+
+// keep returns the index of the file to keep, the first in path order on ties
+func (r KeepRule) keep(files []DuplicateFile) int {
+	best := 0
+	for i, f := range files[1:] {
+		b := files[best]
+		switch r {
+		case KeepOldest:
+			if f.ModTime.Before(b.ModTime) {
+				best = i + 1
+			}
+		case KeepNewest:
+			if f.ModTime.After(b.ModTime) {
+				best = i + 1
+			}
+		case KeepShortest:
+			if len(f.Path) < len(b.Path) {
+				best = i + 1
+			}
+		}
+	}
+	return best
+}
+
+// selectDuplicates selects every file of the groups but the one the rule keeps
+func selectDuplicates(groups []DuplicateGroup, rule KeepRule) map[string]bool {
+	selected := map[string]bool{}
+	for _, g := range groups {
+		keep := rule.keep(g.Files)
+		for i, f := range g.Files {
+			if i != keep {
+				selected[f.Path] = true
+			}
+		}
+	}
+	return selected
+}
+
+// resolveDuplicates deletes the selected files, or with link replaces each of them
+// by a hard link to a file of its group that isn't selected. Groups whose files are
+// all selected are left alone, and so are files whose size changed since the scan.
+// Every file is tried, the errors are returned together.
+func resolveDuplicates(groups []DuplicateGroup, selected map[string]bool, link bool) error {
+	var errs []error
+	for _, g := range groups {
+		kept := ""
+		for _, f := range g.Files {
+			if !selected[f.Path] {
+				kept = f.Path
+				break
+			}
+		}
+		if kept == "" {
+			errs = append(errs, fmt.Errorf("every copy of %s is selected, keep one", filepath.Base(g.Files[0].Path)))
+			continue
+		}
+
+		for _, f := range g.Files {
+			if !selected[f.Path] {
+				continue
+			}
+			info, err := os.Lstat(f.Path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if !info.Mode().IsRegular() || info.Size() != g.Size {
+				errs = append(errs, fmt.Errorf("%s changed since the scan", f.Path))
+				continue
+			}
+			if link {
+				err = replaceWithLink(kept, f.Path)
+			} else {
+				err = os.Remove(f.Path)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// replaceWithLink links target to src under a temporary name first and renames the
+// link over target, so target isn't lost if linking fails
+func replaceWithLink(src, target string) error {
+	tmp := target + ".link-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// duplicatesView finds the duplicates below a directory in its own window. Groups
+// are listed with their files, the checked files are deleted or hard linked.
+type duplicatesView struct {
+	window   fyne.Window
+	dir      string
+	status   *widget.Label
+	tree     *widget.Tree
+	stop     *widget.Button
+	actions  *fyne.Container
+	groups   []DuplicateGroup
+	selected map[string]bool
+	cancel   context.CancelFunc
+}
+
+func showDuplicates(dir string, done func()) {
+	v := &duplicatesView{
+		window:   fyne.CurrentApp().NewWindow("Duplicates in " + filepath.Base(dir)),
+		dir:      dir,
+		selected: map[string]bool{},
+	}
+	v.status = widget.NewLabel("")
+	v.stop = widget.NewButton("Cancel", func() { v.cancel() })
+	v.tree = widget.NewTree(v.childIDs, v.isBranch, v.createRow, v.updateRow)
+
+	options := make([]string, len(keepRules))
+	for i, rule := range keepRules {
+		options[i] = string(rule)
+	}
+	keep := widget.NewSelect(options, func(rule string) {
+		v.selected = selectDuplicates(v.groups, KeepRule(rule))
+		v.tree.Refresh()
+	})
+	keep.PlaceHolder = "Select all but"
+	clear := widget.NewButton("Clear selection", func() {
+		keep.ClearSelected()
+		v.selected = map[string]bool{}
+		v.tree.Refresh()
+	})
+	remove := widget.NewButton("Delete selected", func() { v.resolve(false, done) })
+	link := widget.NewButton("Hard link selected", func() { v.resolve(true, done) })
+	v.actions = container.NewHBox(widget.NewLabel("Keep"), keep, clear, remove, link)
+	v.actions.Hide()
+
+	v.window.SetContent(container.NewBorder(container.NewHBox(v.actions, v.stop), v.status, nil, nil, v.tree))
+	v.window.SetOnClosed(func() { v.cancel() })
+	v.window.Resize(fyne.NewSize(800, 500))
+	v.window.Show()
+	v.start()
+}
+
+func (v *duplicatesView) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	v.cancel = cancel
+	scan := &DuplicateScan{}
+	finished := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-finished:
+				return
+			case <-ticker.C:
+				done, total := scan.Progress()
+				v.status.SetText(fmt.Sprintf("Hashing... %s of %s", formatSize(done), formatSize(total)))
+			}
+		}
+	}()
+
+	go func() {
+		groups, err := scan.Run(ctx, v.dir)
+		close(finished)
+		cancel()
+		v.stop.Hide()
+		if err != nil {
+			v.status.SetText("Scan cancelled")
+			return
+		}
+		v.groups = groups
+		var wasted int64
+		for _, g := range groups {
+			wasted += g.Wasted()
+		}
+		v.status.SetText(fmt.Sprintf("%d groups of duplicates, %s wasted", len(groups), formatSize(wasted)))
+		v.actions.Show()
+		v.tree.Refresh()
+	}()
+}
+
+// resolve deletes or links the selected files after a confirmation, then scans again
+func (v *duplicatesView) resolve(link bool, done func()) {
+	if len(v.selected) == 0 {
+		dialog.ShowInformation("Nothing selected", "Check the copies to remove, or pick the one to keep.", v.window)
+		return
+	}
+	action := "Delete"
+	if link {
+		action = "Hard link"
+	}
+	message := fmt.Sprintf("%s %d selected files? This can't be undone.", action, len(v.selected))
+	dialog.ShowConfirm(action+" duplicates", message, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := resolveDuplicates(v.groups, v.selected, link); err != nil {
+			dialog.ShowError(err, v.window)
+		}
+		v.selected = map[string]bool{}
+		v.actions.Hide()
+		v.stop.Show()
+		done()
+		v.start()
+	}, v.window)
+}
+
+// Tree IDs are the group index for groups and the path for files
+func (v *duplicatesView) childIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	if id == "" {
+		ids := make([]widget.TreeNodeID, len(v.groups))
+		for i := range v.groups {
+			ids[i] = strconv.Itoa(i)
+		}
+		return ids
+	}
+	i, err := strconv.Atoi(id)
+	if err != nil || i >= len(v.groups) {
+		return nil
+	}
+	ids := make([]widget.TreeNodeID, len(v.groups[i].Files))
+	for j, f := range v.groups[i].Files {
+		ids[j] = f.Path
+	}
+	return ids
+}
+
+func (v *duplicatesView) isBranch(id widget.TreeNodeID) bool {
+	_, err := strconv.Atoi(id)
+	return id == "" || err == nil
+}
+
+func (v *duplicatesView) createRow(branch bool) fyne.CanvasObject {
+	if branch {
+		return widget.NewLabel("")
+	}
+	return widget.NewCheck("", nil)
+}
+
+func (v *duplicatesView) updateRow(id widget.TreeNodeID, branch bool, row fyne.CanvasObject) {
+	if branch {
+		i, _ := strconv.Atoi(id)
+		if i < len(v.groups) {
+			g := v.groups[i]
+			row.(*widget.Label).SetText(fmt.Sprintf("%d copies of %s, %s wasted", len(g.Files), formatSize(g.Size), formatSize(g.Wasted())))
+		}
+		return
+	}
+	check := row.(*widget.Check)
+	// the row may have shown another file, don't let SetChecked select it
+	check.OnChanged = nil
+	check.Text = id
+	check.SetChecked(v.selected[id])
+	check.OnChanged = func(on bool) {
+		if on {
+			v.selected[id] = true
+		} else {
+			delete(v.selected, id)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// copyPath copies the file or directory tree src into the directory dstDir. It
+// doesn't overwrite, an existing target is an error.
+func copyPath(src, dstDir string) error {
+	dst, err := transferTarget(src, dstDir)
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		// devices, sockets and pipes are skipped
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// movePath moves src into the directory dstDir. Across file systems, where a rename
+// isn't possible, it copies and then removes src.
+func movePath(src, dstDir string) error {
+	dst, err := transferTarget(src, dstDir)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyPath(src, dstDir); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// transferTarget returns where src ends up in dstDir, refusing to overwrite and to
+// copy a directory into itself
+func transferTarget(src, dstDir string) (string, error) {
+	dst := filepath.Join(dstDir, filepath.Base(src))
+	fsys, name, err := fileSystem(dst)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fsys.Lstat(name); err == nil {
+		return "", fmt.Errorf("%s already exists", dst)
+	}
+	rel, err := filepath.Rel(src, dstDir)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("can't copy %s into itself", src)
+	}
+	return dst, nil
+}
@@ -0,0 +1,176 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// pageSize is how many entries Page Up and Page Down move
+const pageSize = 10
+
+// fileList is the list of a pane. The pane keeps the selection, the list takes the
+// keyboard focus when a row is clicked:
+//
+//   - arrows, Home, End, Page Up and Page Down move, with Shift extending the selection
+//   - Space toggles the entry under the cursor, Ctrl+A selects all
+//   - Enter opens, Backspace goes to the parent directory
+//   - typing a name selects the first entry starting with it
+type fileList struct {
+	widget.List
+	pane  *pane
+	shift bool
+}
+
+func newFileList(p *pane) *fileList {
+	l := &fileList{pane: p}
+	l.Length = func() int {
+		return len(p.state.Entries())
+	}
+	l.CreateItem = func() fyne.CanvasObject {
+		return newEntryRow(p)
+	}
+	l.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		row := o.(*entryRow)
+		row.id = i
+		if entry, ok := p.state.Entry(i); ok {
+			path := p.state.Path(i)
+			row.update(entry.Name(), entry.IsDir(), p.sel.IsSelected(path), p.sel.Cursor() == path)
+		}
+	}
+	l.ExtendBaseWidget(l)
+	return l
+}
+
+// FocusGained replaces the focus handling of the list, which has a cursor of its own
+func (l *fileList) FocusGained() {}
+
+func (l *fileList) FocusLost() {}
+
+// KeyDown tracks Shift, key events don't carry modifiers
+func (l *fileList) KeyDown(event *fyne.KeyEvent) {
+	if event.Name == desktop.KeyShiftLeft || event.Name == desktop.KeyShiftRight {
+		l.shift = true
+	}
+}
+
+func (l *fileList) KeyUp(event *fyne.KeyEvent) {
+	if event.Name == desktop.KeyShiftLeft || event.Name == desktop.KeyShiftRight {
+		l.shift = false
+	}
+}
+
+func (l *fileList) TypedKey(event *fyne.KeyEvent) {
+	p := l.pane
+	switch event.Name {
+	case fyne.KeyUp:
+		p.move(-1, l.shift)
+	case fyne.KeyDown:
+		p.move(1, l.shift)
+	case fyne.KeyPageUp:
+		p.move(-pageSize, l.shift)
+	case fyne.KeyPageDown:
+		p.move(pageSize, l.shift)
+	case fyne.KeyHome:
+		p.move(-len(p.state.Entries()), l.shift)
+	case fyne.KeyEnd:
+		p.move(len(p.state.Entries()), l.shift)
+	case fyne.KeySpace:
+		p.sel.ToggleCursor(p.paths())
+		p.selectionChanged()
+	case fyne.KeyReturn, fyne.KeyEnter:
+		p.open(p.state.Index(p.sel.Cursor()))
+	case fyne.KeyBackspace:
+		p.state.Up()
+	}
+}
+
+func (l *fileList) TypedRune(r rune) {
+	if r == ' ' {
+		// handled as KeySpace
+		return
+	}
+	p := l.pane
+	if p.sel.TypeAhead(p.paths(), r, time.Now()) {
+		p.selectionChanged()
+		l.ScrollTo(p.state.Index(p.sel.Cursor()))
+	}
+}
+
+func (l *fileList) TypedShortcut(shortcut fyne.Shortcut) {
+	if _, ok := shortcut.(*fyne.ShortcutSelectAll); ok {
+		l.pane.sel.SelectAll(l.pane.paths())
+		l.pane.selectionChanged()
+	}
+}
+
+// entryRow shows an entry, selected entries are highlighted and the cursor is marked
+type entryRow struct {
+	widget.BaseWidget
+	pane       *pane
+	id         widget.ListItemID
+	icon       *widget.Icon
+	label      *widget.Label
+	background *canvas.Rectangle
+	// modifier is recorded on mouse down, taps don't carry it
+	modifier fyne.KeyModifier
+}
+
+func newEntryRow(p *pane) *entryRow {
+	r := &entryRow{
+		pane:       p,
+		icon:       widget.NewIcon(theme.FileIcon()),
+		label:      widget.NewLabel("template"),
+		background: canvas.NewRectangle(nil),
+	}
+	r.background.CornerRadius = theme.SelectionRadiusSize()
+	r.ExtendBaseWidget(r)
+	return r
+}
+
+func (r *entryRow) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(container.NewStack(r.background, container.NewBorder(nil, nil, r.icon, nil, r.label)))
+}
+
+func (r *entryRow) update(name string, dir, selected, cursor bool) {
+	r.label.SetText(name)
+	if dir {
+		r.icon.SetResource(theme.FolderIcon())
+	} else {
+		r.icon.SetResource(theme.FileIcon())
+	}
+	switch {
+	case selected:
+		r.background.FillColor = theme.SelectionColor()
+	case cursor:
+		r.background.FillColor = theme.HoverColor()
+	default:
+		r.background.FillColor = nil
+	}
+	r.background.Refresh()
+}
+
+func (r *entryRow) MouseDown(event *desktop.MouseEvent) {
+	r.modifier = event.Modifier
+}
+
+func (r *entryRow) MouseUp(*desktop.MouseEvent) {}
+
+func (r *entryRow) Tapped(*fyne.PointEvent) {
+	r.pane.click(r.id, r.modifier)
+	r.modifier = 0
+}
+
+// DoubleTapped opens the entry like Enter
+func (r *entryRow) DoubleTapped(*fyne.PointEvent) {
+	r.pane.click(r.id, 0)
+	r.pane.open(r.id)
+}
@@ -0,0 +1,148 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// resolveLocation turns a typed location into a clean absolute directory path. A
+// leading ~ is the home directory, relative paths are relative to dir.
+func resolveLocation(text, dir string) (string, error) {
+	path := strings.TrimSpace(text)
+	if path == "" {
+		return "", fmt.Errorf("enter a location")
+	}
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	if !filepath.IsAbs(path) && !isRemote(path) {
+		path = filepath.Join(dir, path)
+	}
+	if conn, remote, ok := splitRemotePath(path); ok {
+		path = remotePath(conn, remote)
+	} else {
+		path = filepath.Clean(path)
+	}
+
+	info, err := statPath(path)
+	if err != nil {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+	return path, nil
+}
+
+// pathSegment is a directory of a breadcrumb, Name is what the crumb shows
+type pathSegment struct {
+	Name string
+	Path string
+}
+
+// pathSegments splits dir into its ancestors from the root down to dir itself. The
+// root of a server is named after the connection.
+func pathSegments(dir string) []pathSegment {
+	if conn, remote, ok := splitRemotePath(dir); ok {
+		dir = remotePath(conn, remote)
+	} else {
+		dir = filepath.Clean(dir)
+	}
+	var segments []pathSegment
+	for {
+		parent := parentDir(dir)
+		if parent == dir {
+			// the root, / or a volume like C:\ or a server
+			name := dir
+			if conn, _, ok := splitRemotePath(dir); ok {
+				name = conn
+			}
+			segments = append(segments, pathSegment{Name: name, Path: dir})
+			break
+		}
+		segments = append(segments, pathSegment{Name: filepath.Base(dir), Path: dir})
+		dir = parent
+	}
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	return segments
+}
+
+// locationBar shows the directory of a state as breadcrumbs, or as an entry to type
+// a location into after pressing the edit button
+type locationBar struct {
+	state   *ExplorerState
+	window  fyne.Window
+	crumbs  *fyne.Container
+	entry   *widget.Entry
+	stack   *fyne.Container
+	content fyne.CanvasObject
+}
+
+func newLocationBar(state *ExplorerState, window fyne.Window) *locationBar {
+	l := &locationBar{state: state, window: window, crumbs: container.NewHBox()}
+	l.entry = widget.NewEntry()
+	l.entry.OnSubmitted = l.submit
+	scroll := container.NewHScroll(l.crumbs)
+	l.stack = container.NewStack(scroll, l.entry)
+	l.entry.Hide()
+
+	edit := widget.NewButtonWithIcon("", theme.DocumentCreateIcon(), func() {
+		if l.entry.Visible() {
+			l.showCrumbs()
+			return
+		}
+		l.entry.SetText(state.Dir())
+		scroll.Hide()
+		l.entry.Show()
+		window.Canvas().Focus(l.entry)
+	})
+	l.content = container.NewBorder(nil, nil, nil, edit, l.stack)
+	return l
+}
+
+// setDir rebuilds the breadcrumbs for dir
+func (l *locationBar) setDir(dir string) {
+	l.crumbs.Objects = nil
+	for i, segment := range pathSegments(dir) {
+		if i > 0 {
+			l.crumbs.Add(widget.NewLabel(string(filepath.Separator)))
+		}
+		path := segment.Path
+		crumb := widget.NewButton(segment.Name, func() { l.state.SetDir(path) })
+		crumb.Importance = widget.LowImportance
+		l.crumbs.Add(crumb)
+	}
+	l.crumbs.Refresh()
+}
+
+func (l *locationBar) submit(text string) {
+	dir, err := resolveLocation(text, l.state.Dir())
+	if err != nil {
+		dialog.ShowError(err, l.window)
+		return
+	}
+	l.showCrumbs()
+	l.state.SetDir(dir)
+}
+
+func (l *locationBar) showCrumbs() {
+	l.entry.Hide()
+	l.stack.Objects[0].Show()
+}
@@ -0,0 +1,481 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// pane lists the entries of an ExplorerState with its own navigation. Clicking an
+// entry selects it, with Ctrl and Shift adding to the selection, double clicking
+// opens it. Copy, move and compress act on the selection.
+type pane struct {
+	state    *ExplorerState
+	window   fyne.Window
+	list     *fileList
+	location *locationBar
+	sel      *selection
+	content  fyne.CanvasObject
+	// onFocus is called when the user interacts with the pane
+	onFocus func(*pane)
+	// onChange is called when the entries or the selection changed
+	onChange func(*pane)
+}
+
+func newPane(state *ExplorerState, window fyne.Window) *pane {
+	p := &pane{state: state, window: window, sel: newSelection()}
+	p.list = newFileList(p)
+
+	// Navigation
+	backButton := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		p.focus()
+		state.Back()
+	})
+	forwardButton := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		p.focus()
+		state.Forward()
+	})
+	upButton := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		p.focus()
+		state.Up()
+	})
+
+	// Displaying current directory
+	p.location = newLocationBar(state, window)
+	spinner := widget.NewActivity()
+
+	shown := ""
+	update := func() {
+		if dir := state.Dir(); dir != shown {
+			shown = dir
+			p.location.setDir(dir)
+			p.sel.Clear()
+		} else {
+			// selected entries that were removed or filtered out
+			p.sel.Retain(p.paths())
+		}
+		setEnabled(backButton, state.CanBack())
+		setEnabled(forwardButton, state.CanForward())
+		if state.Loading() {
+			spinner.Show()
+			spinner.Start()
+		} else {
+			spinner.Stop()
+			spinner.Hide()
+		}
+		p.selectionChanged()
+	}
+	state.OnChange(update)
+	// the state may have loaded before the listener was registered
+	update()
+	state.OnError(func(err error) {
+		dialog.ShowError(err, window)
+	})
+	if _, err := watchState(state, watchDebounce); err != nil {
+		log.Println("Error watching directory:", err)
+	}
+
+	filter := widget.NewEntry()
+	filter.SetPlaceHolder("Filter")
+	filter.OnChanged = func(text string) {
+		view := state.View()
+		view.Filter = text
+		state.SetView(view)
+	}
+
+	buttons := container.NewHBox(backButton, forwardButton, upButton)
+	topBar := container.NewVBox(container.NewBorder(nil, nil, buttons, spinner, p.location.content), filter)
+	p.content = container.NewBorder(topBar, nil, nil, nil, p.list)
+	return p
+}
+
+func setEnabled(button *widget.Button, enabled bool) {
+	if enabled {
+		button.Enable()
+	} else {
+		button.Disable()
+	}
+}
+
+// paths returns the paths of the listed entries
+func (p *pane) paths() []string {
+	dir, entries := p.state.Dir(), p.state.Entries()
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = filepath.Join(dir, entry.Name())
+	}
+	return paths
+}
+
+// selectedPaths returns the selected entries in listed order
+func (p *pane) selectedPaths() []string {
+	return p.sel.Paths(p.paths())
+}
+
+// click selects the entry at id and moves the keyboard focus to the list
+func (p *pane) click(id widget.ListItemID, mod fyne.KeyModifier) {
+	p.focus()
+	p.window.Canvas().Focus(p.list)
+	p.sel.Click(p.paths(), id, mod)
+	p.selectionChanged()
+}
+
+func (p *pane) move(delta int, extend bool) {
+	p.sel.Move(p.paths(), delta, extend)
+	p.selectionChanged()
+	if i := p.state.Index(p.sel.Cursor()); i >= 0 {
+		p.list.ScrollTo(i)
+	}
+}
+
+// open navigates into the entry at id, or opens a file with its default application
+func (p *pane) open(id widget.ListItemID) {
+	if id < 0 || p.state.Open(id) {
+		return
+	}
+	if isRemote(p.state.Path(id)) {
+		dialog.ShowInformation("Can't open", "Files on servers can't be opened, copy them to the other pane first.", p.window)
+		return
+	}
+	if err := openWithDefault(p.state.Path(id)); err != nil {
+		dialog.ShowError(err, p.window)
+	}
+}
+
+func (p *pane) selectionChanged() {
+	p.list.Refresh()
+	if p.onChange != nil {
+		p.onChange(p)
+	}
+}
+
+func (p *pane) focus() {
+	if p.onFocus != nil {
+		p.onFocus(p)
+	}
+}
+
+// Preference keys of the view options shared by both panes
+const (
+	prefSortBy     = "sortBy"
+	prefShowHidden = "showHidden"
+)
+
+// explorer lays out one pane, or two side by side to copy and move files from the
+// pane used last to the directory of the other one
+type explorer struct {
+	window      fyne.Window
+	prefs       fyne.Preferences
+	left, right *pane
+	active      *pane
+	body        *fyne.Container
+	details     *detailsPanel
+	sidebar     *sidebar
+	status      *widget.Label
+	copyButton  *widget.Button
+	moveButton  *widget.Button
+	settings    settings
+	// toolbar controls of the view settings
+	sortBy     *widget.Select
+	showHidden *widget.Check
+	dualPane   *widget.Check
+}
+
+func newExplorer(window fyne.Window, prefs fyne.Preferences, dir string) *explorer {
+	e := &explorer{window: window, prefs: prefs, settings: settings{prefs}}
+	e.left = newPane(NewExplorerState(dir), window)
+	e.right = newPane(NewExplorerState(dir), window)
+	e.active = e.left
+	e.details = newDetailsPanel(window)
+	e.sidebar = newSidebar(prefs, dir, func(dir string) { e.active.state.SetDir(dir) })
+	window.SetOnDropped(e.sidebar.drop)
+	for _, p := range []*pane{e.left, e.right} {
+		p.state.OnVisit(e.sidebar.visited)
+		p.state.OnVisit(e.settings.SetLastDir)
+		p.onFocus = func(p *pane) {
+			if e.active != p {
+				e.active = p
+				e.showSelection()
+			}
+		}
+		p.onChange = func(p *pane) {
+			if e.active == p {
+				e.showSelection()
+			}
+		}
+	}
+
+	e.copyButton = widget.NewButton("Copy to other pane", func() { e.transfer(false) })
+	e.moveButton = widget.NewButton("Move to other pane", func() { e.transfer(true) })
+	e.body = container.NewStack()
+	e.status = widget.NewLabel("")
+	e.setDualPane(false)
+	e.applyView()
+	return e
+}
+
+func (e *explorer) content() fyne.CanvasObject {
+	e.dualPane = widget.NewCheck("Dual pane", func(on bool) {
+		e.settings.SetDualPane(on)
+		e.setDualPane(on)
+	})
+	e.dualPane.SetChecked(e.settings.DualPane())
+
+	options := make([]string, len(sortKeys))
+	for i, key := range sortKeys {
+		options[i] = string(key)
+	}
+	e.sortBy = widget.NewSelect(options, func(key string) {
+		e.settings.SetSortBy(SortKey(key))
+		e.applyView()
+	})
+	e.sortBy.SetSelected(string(e.settings.SortBy()))
+
+	e.showHidden = widget.NewCheck("Hidden files", func(on bool) {
+		e.settings.SetShowHidden(on)
+		e.applyView()
+	})
+	e.showHidden.SetChecked(e.settings.ShowHidden())
+
+	bookmark := widget.NewButtonWithIcon("Bookmark", theme.ContentAddIcon(), func() {
+		e.sidebar.bookmark(e.active.state.Dir())
+	})
+
+	rename := widget.NewButton("Rename", e.batchRename)
+	extract := widget.NewButton("Extract", e.extract)
+	compress := widget.NewButton("Compress", e.compress)
+	usage := widget.NewButton("Disk usage", e.diskUsage)
+	duplicates := widget.NewButton("Duplicates", e.findDuplicates)
+	connect := widget.NewButtonWithIcon("Connect", theme.ComputerIcon(), func() {
+		showConnect(e.prefs, e.window, e.active.state.SetDir)
+	})
+	settings := widget.NewButtonWithIcon("", theme.SettingsIcon(), e.showSettings)
+
+	toolbar := container.NewHBox(e.dualPane, widget.NewLabel("Sort by"), e.sortBy, e.showHidden, bookmark,
+		e.copyButton, e.moveButton, rename, extract, compress, usage, duplicates, connect, settings)
+	panes := container.NewHSplit(e.body, e.details.content)
+	panes.Offset = 0.7
+	body := container.NewHSplit(e.sidebar.content, panes)
+	body.Offset = 0.2
+	return container.NewBorder(toolbar, e.status, nil, nil, body)
+}
+
+// showSelection shows the selection of the active pane in the status bar, and the
+// details of the entry if it is the only one selected
+func (e *explorer) showSelection() {
+	p := e.active
+	selected := p.selectedPaths()
+	e.status.SetText(fmt.Sprintf("%d of %d selected", len(selected), len(p.state.Entries())))
+	path := ""
+	if len(selected) == 1 {
+		path = selected[0]
+	}
+	if path != e.details.path {
+		e.details.show(path)
+	}
+}
+
+// applyView sets the sort order and hidden files preferences on both panes, each
+// keeps its own filter
+func (e *explorer) applyView() {
+	for _, p := range []*pane{e.left, e.right} {
+		view := p.state.View()
+		view.SortBy = e.settings.SortBy()
+		view.ShowHidden = e.settings.ShowHidden()
+		if view != p.state.View() {
+			p.state.SetView(view)
+		}
+	}
+}
+
+func (e *explorer) setDualPane(on bool) {
+	if on {
+		e.body.Objects = []fyne.CanvasObject{container.NewHSplit(e.left.content, e.right.content)}
+		e.copyButton.Enable()
+		e.moveButton.Enable()
+	} else {
+		e.body.Objects = []fyne.CanvasObject{e.left.content}
+		e.active = e.left
+		e.showSelection()
+		e.copyButton.Disable()
+		e.moveButton.Disable()
+	}
+	e.body.Refresh()
+}
+
+func (e *explorer) transfer(move bool) {
+	src, dst := e.active, e.right
+	if src == e.right {
+		dst = e.left
+	}
+	paths := src.selectedPaths()
+	if len(paths) == 0 {
+		dialog.ShowInformation("Nothing selected", "Select the files and folders to copy or move in the pane.", e.window)
+		return
+	}
+
+	if isRemote(src.state.Dir()) || isRemote(dst.state.Dir()) {
+		title := "Copying to "
+		if move {
+			title = "Moving to "
+		}
+		e.runWithProgress(title+filepath.Base(dst.state.Dir()), func(progress progressFunc) error {
+			return transferAcross(paths, dst.state.Dir(), move, progress)
+		}, nil)
+		return
+	}
+
+	// every entry is tried, the errors are shown together
+	var errs []error
+	for _, path := range paths {
+		var err error
+		if move {
+			err = movePath(path, dst.state.Dir())
+		} else {
+			err = copyPath(path, dst.state.Dir())
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		dialog.ShowError(err, e.window)
+	}
+	// both panes may show the same directory
+	src.state.Reload()
+	dst.state.Reload()
+}
+
+// batchRename renames the selection of the active pane with a pattern
+func (e *explorer) batchRename() {
+	p := e.active
+	paths := p.selectedPaths()
+	if len(paths) == 0 {
+		dialog.ShowInformation("Nothing selected", "Select the files and folders to rename in the pane.", e.window)
+		return
+	}
+	if inArchive(p.state.Dir()) || isRemote(p.state.Dir()) {
+		dialog.ShowInformation("Can't rename", "Only local files can be renamed, extract or copy them first.", e.window)
+		return
+	}
+	showBatchRename(paths, e.window, func() {
+		e.left.state.Reload()
+		e.right.state.Reload()
+	})
+}
+
+// extract extracts the archive the active pane is browsing into a directory next to
+// it, and shows that directory
+func (e *explorer) extract() {
+	p := e.active
+	archive, _, ok := splitArchivePath(p.state.Dir())
+	if !ok {
+		dialog.ShowInformation("No archive open", "Open an archive to extract it next to itself.", e.window)
+		return
+	}
+	dst := extractTarget(archive)
+	e.runWithProgress("Extracting "+filepath.Base(archive), func(progress progressFunc) error {
+		return extractArchive(archive, dst, progress)
+	}, func() {
+		p.state.SetDir(dst)
+	})
+}
+
+// compress archives the selection of the active pane, or its directory when nothing
+// is selected, in the format picked. A single entry is archived next to it under its
+// name, several into an archive named after the directory.
+func (e *explorer) compress() {
+	dir := e.active.state.Dir()
+	srcs := e.active.selectedPaths()
+	if len(srcs) == 0 {
+		srcs = []string{dir}
+	}
+	if inArchive(dir) || isRemote(dir) {
+		dialog.ShowInformation("Can't compress", "Only local files can be compressed, extract or copy them first.", e.window)
+		return
+	}
+	name := filepath.Base(srcs[0])
+	target := srcs[0]
+	if len(srcs) > 1 {
+		name = fmt.Sprintf("%d items", len(srcs))
+		target = filepath.Join(dir, filepath.Base(dir))
+	}
+
+	format := widget.NewRadioGroup(compressFormats, nil)
+	format.Horizontal = true
+	format.Required = true
+	format.SetSelected(compressFormats[0])
+	dialog.ShowCustomConfirm("Compress "+name, "Compress", "Cancel", format, func(ok bool) {
+		if !ok {
+			return
+		}
+		dst := target + format.Selected
+		e.runWithProgress("Compressing "+name, func(progress progressFunc) error {
+			return compressPath(srcs, dst, progress)
+		}, nil)
+	}, e.window)
+}
+
+// diskUsage opens the disk usage view of the active pane's directory
+func (e *explorer) diskUsage() {
+	dir := e.active.state.Dir()
+	if inArchive(dir) || isRemote(dir) {
+		dialog.ShowInformation("Can't analyze", "Only the disk usage of local folders can be analyzed, extract or copy them first.", e.window)
+		return
+	}
+	showUsage(e.prefs, dir)
+}
+
+// findDuplicates opens the duplicate finder on the active pane's directory, the
+// panes are reloaded after duplicates are removed
+func (e *explorer) findDuplicates() {
+	dir := e.active.state.Dir()
+	if inArchive(dir) || isRemote(dir) {
+		dialog.ShowInformation("Can't search", "Duplicates can only be searched in local folders, extract or copy them first.", e.window)
+		return
+	}
+	showDuplicates(dir, func() {
+		e.left.state.Reload()
+		e.right.state.Reload()
+	})
+}
+
+// runWithProgress runs work in the background while a dialog shows its progress.
+// Both panes are reloaded afterwards, then done is called if it succeeded.
+func (e *explorer) runWithProgress(title string, work func(progressFunc) error, done func()) {
+	bar := widget.NewProgressBar()
+	d := dialog.NewCustomWithoutButtons(title, bar, e.window)
+	d.Show()
+
+	go func() {
+		shown := 0.0
+		err := work(func(n, total int64) {
+			if total <= 0 {
+				return
+			}
+			// redrawing on every read would slow the work down
+			if value := float64(n) / float64(total); value-shown >= 0.01 || value >= 1 {
+				shown = value
+				bar.SetValue(value)
+			}
+		})
+		d.Hide()
+		e.left.state.Reload()
+		e.right.state.Reload()
+		if err != nil {
+			dialog.ShowError(err, e.window)
+		} else if done != nil {
+			done()
+		}
+	}()
+}
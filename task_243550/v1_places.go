@@ -0,0 +1,219 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Preference keys of the sidebar lists
+const (
+	prefBookmarks = "bookmarks"
+	prefRecent    = "recent"
+)
+
+// maxRecent is how many recently visited folders the sidebar lists
+const maxRecent = 10
+
+// place is a directory the sidebar links to
+type place struct {
+	Name string
+	Path string
+}
+
+// standardPlaces returns the usual folders of home that exist
+func standardPlaces(home string) []place {
+	places := []place{{Name: "Home", Path: home}}
+	for _, name := range []string{"Desktop", "Documents", "Downloads"} {
+		path := filepath.Join(home, name)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			places = append(places, place{Name: name, Path: path})
+		}
+	}
+	return places
+}
+
+// mountedDrives returns the drives and removable media found where the platform
+// mounts them
+func mountedDrives() []place {
+	var drives []place
+	switch runtime.GOOS {
+	case "windows":
+		for letter := 'A'; letter <= 'Z'; letter++ {
+			root := string(letter) + `:\`
+			if _, err := os.Stat(root); err == nil {
+				drives = append(drives, place{Name: root, Path: root})
+			}
+		}
+	case "darwin":
+		drives = append(drives, subdirectories("/Volumes")...)
+	default:
+		drives = append(drives, place{Name: "/", Path: "/"})
+		dirs := []string{"/mnt"}
+		if u, err := user.Current(); err == nil {
+			dirs = append(dirs, filepath.Join("/media", u.Username), filepath.Join("/run/media", u.Username))
+		}
+		for _, dir := range dirs {
+			drives = append(drives, subdirectories(dir)...)
+		}
+	}
+	return drives
+}
+
+func subdirectories(dir string) []place {
+	entries, _ := os.ReadDir(dir)
+	var places []place
+	for _, entry := range entries {
+		if entry.IsDir() {
+			places = append(places, place{Name: entry.Name(), Path: filepath.Join(dir, entry.Name())})
+		}
+	}
+	return places
+}
+
+// places keeps the bookmarks and recent folders in the preferences
+type places struct {
+	prefs fyne.Preferences
+}
+
+func (p places) Bookmarks() []string {
+	return p.prefs.StringList(prefBookmarks)
+}
+
+// AddBookmark bookmarks dir, false if it already is
+func (p places) AddBookmark(dir string) bool {
+	bookmarks := p.Bookmarks()
+	for _, bookmark := range bookmarks {
+		if bookmark == dir {
+			return false
+		}
+	}
+	p.prefs.SetStringList(prefBookmarks, append(bookmarks, dir))
+	return true
+}
+
+func (p places) RemoveBookmark(dir string) {
+	p.prefs.SetStringList(prefBookmarks, without(p.Bookmarks(), dir))
+}
+
+// Recent returns the recently visited folders, the latest first
+func (p places) Recent() []string {
+	return p.prefs.StringList(prefRecent)
+}
+
+// AddRecent moves dir to the top of the recent folders
+func (p places) AddRecent(dir string) {
+	recent := append([]string{dir}, without(p.Recent(), dir)...)
+	if len(recent) > maxRecent {
+		recent = recent[:maxRecent]
+	}
+	p.prefs.SetStringList(prefRecent, recent)
+}
+
+func without(list []string, s string) []string {
+	kept := []string{}
+	for _, item := range list {
+		if item != s {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// sidebar links to standard places, drives, bookmarks and recent folders. Folders
+// dropped on it from the desktop are bookmarked.
+type sidebar struct {
+	places  places
+	home    string
+	box     *fyne.Container
+	content fyne.CanvasObject
+	// open navigates the active pane
+	open func(dir string)
+}
+
+func newSidebar(prefs fyne.Preferences, home string, open func(string)) *sidebar {
+	s := &sidebar{places: places{prefs: prefs}, home: home, box: container.NewVBox(), open: open}
+	s.content = container.NewVScroll(s.box)
+	s.refresh()
+	return s
+}
+
+// bookmark adds dir to the bookmarks if it is a directory
+func (s *sidebar) bookmark(dir string) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	if s.places.AddBookmark(dir) {
+		s.refresh()
+	}
+}
+
+func (s *sidebar) visited(dir string) {
+	s.places.AddRecent(dir)
+	s.refresh()
+}
+
+// drop bookmarks the folders dropped within the sidebar
+func (s *sidebar) drop(pos fyne.Position, uris []fyne.URI) {
+	origin := fyne.CurrentApp().Driver().AbsolutePositionForObject(s.content)
+	size := s.content.Size()
+	if pos.X < origin.X || pos.Y < origin.Y || pos.X > origin.X+size.Width || pos.Y > origin.Y+size.Height {
+		return
+	}
+	for _, uri := range uris {
+		if uri.Scheme() == "file" {
+			s.bookmark(uri.Path())
+		}
+	}
+}
+
+func (s *sidebar) refresh() {
+	s.box.Objects = nil
+	section := func(title string) {
+		s.box.Add(widget.NewLabelWithStyle(title, fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+	}
+	link := func(name, path string) *widget.Button {
+		button := widget.NewButton(name, func() { s.open(path) })
+		button.Alignment = widget.ButtonAlignLeading
+		button.Importance = widget.LowImportance
+		return button
+	}
+
+	section("Places")
+	for _, p := range standardPlaces(s.home) {
+		s.box.Add(link(p.Name, p.Path))
+	}
+	for _, p := range mountedDrives() {
+		s.box.Add(link(p.Name, p.Path))
+	}
+
+	section("Bookmarks")
+	bookmarks := s.places.Bookmarks()
+	if len(bookmarks) == 0 {
+		s.box.Add(widget.NewLabel("Drop folders here"))
+	}
+	for _, dir := range bookmarks {
+		dir := dir
+		remove := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+			s.places.RemoveBookmark(dir)
+			s.refresh()
+		})
+		remove.Importance = widget.LowImportance
+		s.box.Add(container.NewBorder(nil, nil, nil, remove, link(filepath.Base(dir), dir)))
+	}
+
+	section("Recent")
+	for _, dir := range s.places.Recent() {
+		s.box.Add(link(filepath.Base(dir), dir))
+	}
+	s.box.Refresh()
+}
@@ -0,0 +1,592 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Preference keys of the last SFTP connection, passwords aren't kept
+const (
+	prefSFTPHost = "sftpHost"
+	prefSFTPPort = "sftpPort"
+	prefSFTPUser = "sftpUser"
+	prefSFTPKey  = "sftpKey"
+)
+
+// remoteScheme starts the paths of files on SFTP servers, followed by the connection
+// and the path on the server: sftp:/user@host:22/home/user. That is the form
+// filepath.Clean and filepath.Join leave such paths in, "sftp://" is accepted too.
+const remoteScheme = "sftp:"
+
+// sshTimeout bounds connecting to a server
+const sshTimeout = 15 * time.Second
+
+// remotes are the open SFTP connections by name
+var remotes = struct {
+	sync.Mutex
+	clients map[string]*sftp.Client
+}{clients: map[string]*sftp.Client{}}
+
+// addRemote makes the files of client available under remotePath(conn, ...),
+// replacing an earlier connection of that name
+func addRemote(conn string, client *sftp.Client) {
+	remotes.Lock()
+	defer remotes.Unlock()
+	if old, ok := remotes.clients[conn]; ok && old != client {
+		old.Close()
+	}
+	remotes.clients[conn] = client
+}
+
+func remoteClient(conn string) (*sftp.Client, error) {
+	remotes.Lock()
+	defer remotes.Unlock()
+	client, ok := remotes.clients[conn]
+	if !ok {
+		return nil, fmt.Errorf("not connected to %s, connect to it first", conn)
+	}
+	return client, nil
+}
+
+// splitRemotePath returns the connection and the slash separated path on the server
+// of a remote path
+func splitRemotePath(p string) (conn, remote string, ok bool) {
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, remoteScheme+"/") {
+		return "", "", false
+	}
+	rest := strings.TrimLeft(strings.TrimPrefix(p, remoteScheme), "/")
+	conn, remote, _ = strings.Cut(rest, "/")
+	if conn == "" {
+		return "", "", false
+	}
+	return conn, path.Clean("/" + remote), true
+}
+
+// remotePath returns the path of the file at remote on the server of conn
+func remotePath(conn, remote string) string {
+	remote = path.Clean("/" + remote)
+	if remote == "/" {
+		return remoteScheme + "/" + conn
+	}
+	return remoteScheme + "/" + conn + remote
+}
+
+func isRemote(p string) bool {
+	_, _, ok := splitRemotePath(p)
+	return ok
+}
+
+// parentDir returns the directory containing p, the root of a server is its own
+// parent like / is
+func parentDir(p string) string {
+	if conn, remote, ok := splitRemotePath(p); ok {
+		return remotePath(conn, path.Dir(remote))
+	}
+	return filepath.Dir(p)
+}
+
+// FileSystem is the file access of transfers, on the local disk or on an SFTP
+// server. Names are absolute paths of the file system, not of the explorer.
+type FileSystem interface {
+	Open(name string) (fs.File, error)
+	// Lstat doesn't follow links
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Create creates a new file, failing if it exists
+	Create(name string, perm fs.FileMode) (io.WriteCloser, error)
+	Mkdir(name string, perm fs.FileMode) error
+	RemoveAll(name string) error
+}
+
+// fileSystem returns the file system of p and the name of p in it
+func fileSystem(p string) (FileSystem, string, error) {
+	conn, remote, ok := splitRemotePath(p)
+	if !ok {
+		return localFS{}, p, nil
+	}
+	client, err := remoteClient(conn)
+	if err != nil {
+		return nil, "", err
+	}
+	return sftpFS{client}, remote, nil
+}
+
+type localFS struct{}
+
+func (localFS) Open(name string) (fs.File, error)          { return os.Open(name) }
+func (localFS) Lstat(name string) (fs.FileInfo, error)     { return os.Lstat(name) }
+func (localFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (localFS) Mkdir(name string, perm fs.FileMode) error  { return os.Mkdir(name, perm) }
+func (localFS) RemoveAll(name string) error                { return os.RemoveAll(name) }
+
+func (localFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+}
+
+type sftpFS struct {
+	client *sftp.Client
+}
+
+func (s sftpFS) Open(name string) (fs.File, error)      { return s.client.Open(name) }
+func (s sftpFS) Lstat(name string) (fs.FileInfo, error) { return s.client.Lstat(name) }
+func (s sftpFS) RemoveAll(name string) error            { return s.client.RemoveAll(name) }
+
+func (s sftpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	infos, err := s.client.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (s sftpFS) Create(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	f, err := s.client.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return nil, err
+	}
+	// servers may not support modes, the file is still usable
+	f.Chmod(perm)
+	return f, nil
+}
+
+func (s sftpFS) Mkdir(name string, perm fs.FileMode) error {
+	if err := s.client.Mkdir(name); err != nil {
+		return err
+	}
+	s.client.Chmod(name, perm)
+	return nil
+}
+
+// openRemoteDir lists a directory of a server. SFTP reads it in one request, the
+// entries are then handed out in batches like local ones.
+func openRemoteDir(conn, remote string) (fs.ReadDirFile, error) {
+	client, err := remoteClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Stat(remote)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: remote, Err: errors.New("not a directory")}
+	}
+	entries, err := sftpFS{client}.ReadDir(remote)
+	if err != nil {
+		return nil, err
+	}
+	return &listedDir{info: info, entries: entries}, nil
+}
+
+// listedDir is a directory whose entries were already read
+type listedDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+}
+
+func (d *listedDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *listedDir) Read([]byte) (int, error)   { return 0, errors.New("is a directory") }
+func (d *listedDir) Close() error               { return nil }
+
+// ReadDir follows fs.ReadDirFile, with n > 0 it returns io.EOF once all were read
+func (d *listedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n > 0 && len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n <= 0 || n > len(d.entries) {
+		n = len(d.entries)
+	}
+	batch := d.entries[:n]
+	d.entries = d.entries[n:]
+	return batch, nil
+}
+
+// transferAcross copies or moves srcs into dstDir when either side is on a server,
+// reporting the bytes copied. Moves within one server are renames, otherwise the
+// sources are removed once copied. Every source is tried, the errors are returned
+// together.
+func transferAcross(srcs []string, dstDir string, move bool, progress progressFunc) error {
+	var total int64
+	for _, src := range srcs {
+		size, err := treeSize(src)
+		if err != nil {
+			return err
+		}
+		total += size
+	}
+
+	counter := &countingReader{total: total, progress: progress}
+	var errs []error
+	for _, src := range srcs {
+		dst, err := transferTarget(src, dstDir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		srcConn, srcRemote, _ := splitRemotePath(src)
+		dstConn, dstRemote, _ := splitRemotePath(dst)
+		if move && srcConn != "" && srcConn == dstConn {
+			client, err := remoteClient(srcConn)
+			if err == nil {
+				err = client.Rename(srcRemote, dstRemote)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if err := copyAcross(src, dst, counter); err != nil {
+			// what was copied of src is incomplete
+			if fsys, name, ferr := fileSystem(dst); ferr == nil {
+				fsys.RemoveAll(name)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if move {
+			fsys, name, err := fileSystem(src)
+			if err == nil {
+				err = fsys.RemoveAll(name)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// treeSize returns the size of the regular files of the tree at p
+func treeSize(p string) (int64, error) {
+	fsys, name, err := fileSystem(p)
+	if err != nil {
+		return 0, err
+	}
+	info, err := fsys.Lstat(name)
+	if err != nil {
+		return 0, err
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			return info.Size(), nil
+		}
+		return 0, nil
+	}
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, entry := range entries {
+		n, err := treeSize(filepath.Join(p, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	return size, nil
+}
+
+// copyAcross copies the tree at src to dst, which may be on different file systems.
+// Links and special files are skipped, servers don't agree on how to create them.
+func copyAcross(src, dst string, counter *countingReader) error {
+	srcFS, srcName, err := fileSystem(src)
+	if err != nil {
+		return err
+	}
+	dstFS, dstName, err := fileSystem(dst)
+	if err != nil {
+		return err
+	}
+	info, err := srcFS.Lstat(srcName)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		if err := dstFS.Mkdir(dstName, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := srcFS.ReadDir(srcName)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyAcross(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), counter); err != nil {
+				return err
+			}
+		}
+		return nil
+	case info.Mode().IsRegular():
+		in, err := srcFS.Open(srcName)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := dstFS.Create(dstName, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		counter.r = in
+		if _, err := io.Copy(out, counter); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return nil
+}
+
+// sshConfig is what connecting to a server takes, from the connect dialog
+type sshConfig struct {
+	Host       string
+	Port       int
+	User       string
+	KeyFile    string
+	Passphrase string
+	Password   string
+}
+
+// name is the name of the connection in remote paths
+func (c sshConfig) name() string {
+	return c.User + "@" + net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// auth returns the key and password authentication configured
+func (c sshConfig) auth() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if c.KeyFile != "" {
+		pem, err := os.ReadFile(expandHome(c.KeyFile))
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if c.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(c.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading key %s: %w", c.KeyFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if c.Password != "" {
+		methods = append(methods, ssh.Password(c.Password))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("a key file or a password is needed")
+	}
+	return methods, nil
+}
+
+// unknownHostError is returned for servers missing from known_hosts, with the key
+// they presented
+type unknownHostError struct {
+	addr string
+	key  ssh.PublicKey
+}
+
+func (e *unknownHostError) Error() string {
+	return fmt.Sprintf("%s isn't a known host, its key is %s", e.addr, ssh.FingerprintSHA256(e.key))
+}
+
+func knownHostsFile() string {
+	return expandHome("~/.ssh/known_hosts")
+}
+
+// trustHost adds the key of an unknown host to known_hosts
+func trustHost(e *unknownHostError) error {
+	file := knownHostsFile()
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(e.addr)}, e.key)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dialSFTP connects to the server of c, verifying its key against known_hosts. Hosts
+// that aren't known fail with an *unknownHostError.
+func dialSFTP(c sshConfig) (*sftp.Client, error) {
+	auth, err := c.auth()
+	if err != nil {
+		return nil, err
+	}
+	known, err := knownhosts.New(knownHostsFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		known = func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }
+	} else if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	config := &ssh.ClientConfig{
+		User: c.User,
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			err := known(hostname, remote, key)
+			var keyErr *knownhosts.KeyError
+			if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+				return &unknownHostError{addr: addr, key: key}
+			}
+			return err
+		},
+		Timeout: sshTimeout,
+	}
+
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		var unknown *unknownHostError
+		if errors.As(err, &unknown) {
+			return nil, unknown
+		}
+		return nil, err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// expandHome replaces a leading ~ by the home directory
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[1:])
+		}
+	}
+	return p
+}
+
+// showConnect asks for a server to connect to and calls connected with the remote
+// path of the user's directory on it
+func showConnect(prefs fyne.Preferences, window fyne.Window, connected func(dir string)) {
+	host := widget.NewEntry()
+	host.SetText(prefs.String(prefSFTPHost))
+	port := widget.NewEntry()
+	port.SetText(strconv.Itoa(prefs.IntWithFallback(prefSFTPPort, 22)))
+	user := widget.NewEntry()
+	user.SetText(prefs.String(prefSFTPUser))
+	key := widget.NewEntry()
+	key.SetPlaceHolder("e.g. ~/.ssh/id_ed25519")
+	key.SetText(prefs.String(prefSFTPKey))
+	passphrase := widget.NewPasswordEntry()
+	password := widget.NewPasswordEntry()
+	password.SetPlaceHolder("If the server doesn't accept the key")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Host", host),
+		widget.NewFormItem("Port", port),
+		widget.NewFormItem("User", user),
+		widget.NewFormItem("Key file", key),
+		widget.NewFormItem("Passphrase", passphrase),
+		widget.NewFormItem("Password", password),
+	}
+	d := dialog.NewForm("Connect to SFTP server", "Connect", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(port.Text))
+		if err != nil || n <= 0 || n > 65535 {
+			dialog.ShowError(errors.New("the port must be a number up to 65535"), window)
+			return
+		}
+		config := sshConfig{
+			Host:       strings.TrimSpace(host.Text),
+			Port:       n,
+			User:       strings.TrimSpace(user.Text),
+			KeyFile:    strings.TrimSpace(key.Text),
+			Passphrase: passphrase.Text,
+			Password:   password.Text,
+		}
+		if config.Host == "" || config.User == "" {
+			dialog.ShowError(errors.New("the host and the user are needed"), window)
+			return
+		}
+		prefs.SetString(prefSFTPHost, config.Host)
+		prefs.SetInt(prefSFTPPort, config.Port)
+		prefs.SetString(prefSFTPUser, config.User)
+		prefs.SetString(prefSFTPKey, config.KeyFile)
+		connect(config, window, connected)
+	}, window)
+	d.Resize(fyne.NewSize(450, 350))
+	d.Show()
+}
+
+// connect dials the server in the background. The key of an unknown host is shown
+// to be trusted before connecting again.
+func connect(config sshConfig, window fyne.Window, connected func(dir string)) {
+	progress := dialog.NewCustomWithoutButtons("Connecting to "+config.Host, container.NewPadded(widget.NewProgressBarInfinite()), window)
+	progress.Show()
+	go func() {
+		client, err := dialSFTP(config)
+		var home string
+		if err == nil {
+			if home, err = client.Getwd(); err != nil {
+				client.Close()
+			}
+		}
+		progress.Hide()
+
+		var unknown *unknownHostError
+		switch {
+		case errors.As(err, &unknown):
+			message := fmt.Sprintf("The authenticity of %s can't be established.\nIts key fingerprint is %s.\nTrust it and connect?",
+				unknown.addr, ssh.FingerprintSHA256(unknown.key))
+			dialog.ShowConfirm("Unknown host", message, func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := trustHost(unknown); err != nil {
+					dialog.ShowError(err, window)
+					return
+				}
+				connect(config, window, connected)
+			}, window)
+		case err != nil:
+			dialog.ShowError(err, window)
+		default:
+			addRemote(config.name(), client)
+			connected(remotePath(config.name(), home))
+		}
+	}()
+}
@@ -0,0 +1,318 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// CaseTransform changes the case of renamed names
+type CaseTransform string
+
+const (
+	CaseKeep  CaseTransform = "Keep case"
+	CaseLower CaseTransform = "lower case"
+	CaseUpper CaseTransform = "UPPER CASE"
+	CaseTitle CaseTransform = "Title Case"
+)
+
+var caseTransforms = []CaseTransform{CaseKeep, CaseLower, CaseUpper, CaseTitle}
+
+// RenameRule builds new names for a batch of files. Only the name before the
+// extension is changed, directories have no extension. Find is replaced first, then
+// the template is filled and the case transformed.
+type RenameRule struct {
+	Find    string
+	Replace string
+	// Regex treats Find as a regular expression, Replace may refer to its groups as $1
+	Regex      bool
+	IgnoreCase bool
+	// Template builds the name from {name}, the name after replacing, and {n}, the
+	// number of the file in the batch. Empty keeps the name.
+	Template string
+	// Start is the number of the first file, Digits pads the numbers with zeros
+	Start  int
+	Digits int
+	Case   CaseTransform
+}
+
+// RenamePlan is the new name of a file, Conflict says why it can't be renamed
+type RenamePlan struct {
+	Path     string
+	NewName  string
+	Conflict string
+}
+
+// Changed reports whether the file gets a new name
+func (p RenamePlan) Changed() bool {
+	return p.NewName != filepath.Base(p.Path)
+}
+
+// planRenames applies the rule to paths, numbered in order. Names that are empty,
+// contain a separator, are given to several files or are taken by a file outside
+// the batch are conflicts. The rule itself is only wrong for an invalid regex.
+func planRenames(paths []string, rule RenameRule) ([]RenamePlan, error) {
+	var find *regexp.Regexp
+	if rule.Find != "" {
+		pattern := rule.Find
+		if !rule.Regex {
+			pattern = regexp.QuoteMeta(pattern)
+		}
+		if rule.IgnoreCase {
+			pattern = "(?i)" + pattern
+		}
+		var err error
+		if find, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid expression: %w", err)
+		}
+	}
+
+	plans := make([]RenamePlan, len(paths))
+	for i, path := range paths {
+		name, ext := filepath.Base(path), ""
+		if info, err := os.Lstat(path); err != nil {
+			plans[i] = RenamePlan{Path: path, NewName: name, Conflict: "not found"}
+			continue
+		} else if !info.IsDir() {
+			ext = filepath.Ext(name)
+			name = strings.TrimSuffix(name, ext)
+		}
+
+		if find != nil {
+			if rule.Regex {
+				name = find.ReplaceAllString(name, rule.Replace)
+			} else {
+				name = find.ReplaceAllLiteralString(name, rule.Replace)
+			}
+		}
+		if rule.Template != "" {
+			n := strconv.Itoa(rule.Start + i)
+			if pad := rule.Digits - len(n); pad > 0 {
+				n = strings.Repeat("0", pad) + n
+			}
+			name = strings.NewReplacer("{name}", name, "{n}", n).Replace(rule.Template)
+		}
+		plans[i] = RenamePlan{Path: path, NewName: transformCase(name, rule.Case) + ext}
+	}
+
+	// a target may be taken by a file of the batch that is renamed away
+	leaving := map[string]bool{}
+	for _, plan := range plans {
+		if plan.Conflict == "" && plan.Changed() {
+			leaving[plan.Path] = true
+		}
+	}
+	targets := map[string]int{}
+	for _, plan := range plans {
+		targets[filepath.Join(filepath.Dir(plan.Path), plan.NewName)]++
+	}
+	for i, plan := range plans {
+		if plan.Conflict != "" || !plan.Changed() {
+			continue
+		}
+		target := filepath.Join(filepath.Dir(plan.Path), plan.NewName)
+		switch {
+		case plan.NewName == "" || plan.NewName == "." || plan.NewName == "..":
+			plans[i].Conflict = "invalid name"
+		case strings.ContainsAny(plan.NewName, `/\`):
+			plans[i].Conflict = "contains a separator"
+		case targets[target] > 1:
+			plans[i].Conflict = "same name as another file"
+		case !leaving[target] && exists(target):
+			plans[i].Conflict = "already exists"
+		}
+	}
+	return plans, nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func transformCase(s string, transform CaseTransform) string {
+	switch transform {
+	case CaseLower:
+		return strings.ToLower(s)
+	case CaseUpper:
+		return strings.ToUpper(s)
+	case CaseTitle:
+		runes := []rune(strings.ToLower(s))
+		for i, r := range runes {
+			if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+				runes[i] = unicode.ToUpper(r)
+			}
+		}
+		return string(runes)
+	}
+	return s
+}
+
+// applyRenames renames the changed files of plans, refusing if any has a conflict.
+// Files are moved to temporary names first so names can be swapped or shifted
+// within the batch. If a rename fails the files renamed so far are moved back.
+func applyRenames(plans []RenamePlan) error {
+	var changed []RenamePlan
+	for _, plan := range plans {
+		if plan.Conflict != "" {
+			return fmt.Errorf("%s: %s", filepath.Base(plan.Path), plan.Conflict)
+		}
+		if plan.Changed() {
+			changed = append(changed, plan)
+		}
+	}
+
+	suffix := ".rename-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	temps := make([]string, len(changed))
+	undo := func(done int) error {
+		var errs []error
+		for i := done - 1; i >= 0; i-- {
+			if err := os.Rename(temps[i], changed[i].Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	for i, plan := range changed {
+		temps[i] = plan.Path + suffix
+		if err := os.Rename(plan.Path, temps[i]); err != nil {
+			return errors.Join(err, undo(i))
+		}
+	}
+	for i, plan := range changed {
+		target := filepath.Join(filepath.Dir(plan.Path), plan.NewName)
+		if err := os.Rename(temps[i], target); err != nil {
+			// the targets reached are moved back to their temporary names first
+			for j := i - 1; j >= 0; j-- {
+				os.Rename(filepath.Join(filepath.Dir(changed[j].Path), changed[j].NewName), temps[j])
+			}
+			return errors.Join(err, undo(len(changed)))
+		}
+	}
+	return nil
+}
+
+// showBatchRename renames paths in a dialog previewing the new names as the rule is
+// edited. done is called after renaming.
+func showBatchRename(paths []string, window fyne.Window, done func()) {
+	rule := RenameRule{Start: 1, Case: CaseKeep}
+	var plans []RenamePlan
+
+	status := widget.NewLabel("")
+	table := widget.NewTable(
+		func() (int, int) { return len(plans), 3 },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			if id.Row >= len(plans) {
+				return
+			}
+			plan, text := plans[id.Row], ""
+			switch id.Col {
+			case 0:
+				text = filepath.Base(plan.Path)
+			case 1:
+				text = plan.NewName
+			case 2:
+				text = plan.Conflict
+				if text == "" && !plan.Changed() {
+					text = "unchanged"
+				}
+			}
+			cell.(*widget.Label).SetText(text)
+		})
+	table.ShowHeaderRow = true
+	table.CreateHeader = func() fyne.CanvasObject { return widget.NewLabel("") }
+	table.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+		cell.(*widget.Label).SetText([]string{"Name", "New name", "Problem"}[id.Col])
+	}
+	table.SetColumnWidth(0, 250)
+	table.SetColumnWidth(1, 250)
+	table.SetColumnWidth(2, 200)
+
+	var d dialog.Dialog
+	apply := widget.NewButton("Rename", func() {
+		if err := applyRenames(plans); err != nil {
+			dialog.ShowError(err, window)
+		}
+		d.Hide()
+		done()
+	})
+	preview := func() {
+		var err error
+		if plans, err = planRenames(paths, rule); err != nil {
+			plans = nil
+			status.SetText(err.Error())
+			apply.Disable()
+			table.Refresh()
+			return
+		}
+		changed, conflicts := 0, 0
+		for _, plan := range plans {
+			if plan.Conflict != "" {
+				conflicts++
+			} else if plan.Changed() {
+				changed++
+			}
+		}
+		status.SetText(fmt.Sprintf("%d of %d renamed, %d conflicts", changed, len(plans), conflicts))
+		setEnabled(apply, changed > 0 && conflicts == 0)
+		table.Refresh()
+	}
+
+	find := widget.NewEntry()
+	find.OnChanged = func(s string) { rule.Find = s; preview() }
+	replace := widget.NewEntry()
+	replace.OnChanged = func(s string) { rule.Replace = s; preview() }
+	regex := widget.NewCheck("Regular expression", func(on bool) { rule.Regex = on; preview() })
+	ignoreCase := widget.NewCheck("Ignore case", func(on bool) { rule.IgnoreCase = on; preview() })
+	template := widget.NewEntry()
+	template.SetPlaceHolder("e.g. {name}_{n}")
+	template.OnChanged = func(s string) { rule.Template = s; preview() }
+	number := func(initial int, set func(int)) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetText(strconv.Itoa(initial))
+		entry.OnChanged = func(s string) {
+			if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+				set(n)
+				preview()
+			}
+		}
+		return entry
+	}
+	start := number(rule.Start, func(n int) { rule.Start = n })
+	digits := number(rule.Digits, func(n int) { rule.Digits = n })
+	options := make([]string, len(caseTransforms))
+	for i, transform := range caseTransforms {
+		options[i] = string(transform)
+	}
+	caseSelect := widget.NewSelect(options, func(s string) { rule.Case = CaseTransform(s); preview() })
+	caseSelect.SetSelected(string(rule.Case))
+
+	form := widget.NewForm(
+		widget.NewFormItem("Find", find),
+		widget.NewFormItem("Replace with", replace),
+		widget.NewFormItem("", container.NewHBox(regex, ignoreCase)),
+		widget.NewFormItem("Template", template),
+		widget.NewFormItem("Numbers", container.NewGridWithColumns(4, widget.NewLabel("Start"), start, widget.NewLabel("Digits"), digits)),
+		widget.NewFormItem("Case", caseSelect),
+	)
+	content := container.NewBorder(form, container.NewBorder(nil, nil, nil, apply, status), nil, nil, table)
+	d = dialog.NewCustom(fmt.Sprintf("Rename %d items", len(paths)), "Cancel", content, window)
+	d.Resize(fyne.NewSize(750, 550))
+	preview()
+	d.Show()
+}
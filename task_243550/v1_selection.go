@@ -0,0 +1,216 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+)
+
+// typeAheadTimeout is the pause after which typing starts a new search
+const typeAheadTimeout = time.Second
+
+// selection is the set of selected entries of a pane. Entries are kept by path so
+// the selection survives reloads that move rows. The cursor is the entry the
+// keyboard moves from, the anchor where a Shift range starts.
+//
+// Methods get the paths of the listed entries in their order.
+type selection struct {
+	mu       sync.Mutex
+	selected map[string]bool
+	cursor   string
+	anchor   string
+	typed    string
+	typedAt  time.Time
+}
+
+func newSelection() *selection {
+	return &selection{selected: make(map[string]bool)}
+}
+
+// Click selects the entry at i. Ctrl, or Cmd on macOS, toggles it, Shift selects the
+// range from the anchor, added to the selection with both.
+func (s *selection) Click(paths []string, i int, mod fyne.KeyModifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i < 0 || i >= len(paths) {
+		return
+	}
+	toggle := mod&(fyne.KeyModifierControl|fyne.KeyModifierSuper) != 0
+	switch {
+	case mod&fyne.KeyModifierShift != 0:
+		s.selectRange(paths, i, toggle)
+	case toggle:
+		if s.selected[paths[i]] {
+			delete(s.selected, paths[i])
+		} else {
+			s.selected[paths[i]] = true
+		}
+		s.cursor, s.anchor = paths[i], paths[i]
+	default:
+		s.selectOnly(paths[i])
+	}
+}
+
+// Move moves the cursor by delta entries, stopping at the first and last. Extending
+// selects the range from the anchor instead of only the cursor.
+func (s *selection) Move(paths []string, delta int, extend bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(paths) == 0 {
+		return
+	}
+	i := indexOf(paths, s.cursor)
+	if i < 0 {
+		// nothing to move from, the first key lands on the first or last entry
+		i = 0
+		if delta < 0 {
+			i = len(paths) - 1
+		}
+	} else {
+		i = max(0, min(len(paths)-1, i+delta))
+	}
+	if extend {
+		s.selectRange(paths, i, false)
+	} else {
+		s.selectOnly(paths[i])
+	}
+}
+
+// ToggleCursor adds the entry under the cursor to the selection or removes it
+func (s *selection) ToggleCursor(paths []string) {
+	s.mu.Lock()
+	cursor := indexOf(paths, s.cursor)
+	s.mu.Unlock()
+	s.Click(paths, cursor, fyne.KeyModifierControl)
+}
+
+// SelectAll selects every listed entry
+func (s *selection) SelectAll(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, path := range paths {
+		s.selected[path] = true
+	}
+}
+
+// TypeAhead selects the next entry whose name starts with what was typed since the
+// last pause, ignoring case. Typing the same letter again cycles through the entries
+// starting with it. It reports whether an entry matched.
+func (s *selection) TypeAhead(paths []string, r rune, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.typedAt) > typeAheadTimeout {
+		s.typed = ""
+	}
+	s.typedAt = now
+	typed := s.typed + strings.ToLower(string(r))
+	// a repeated letter searches for the next entry with that letter
+	first, size := utf8.DecodeRuneInString(typed)
+	if strings.Trim(typed, string(first)) == "" {
+		typed = typed[:size]
+	}
+	s.typed = typed
+
+	start := indexOf(paths, s.cursor)
+	if len(typed) == size {
+		// a new search starts after the cursor, a longer one may match the cursor
+		start++
+	}
+	start = max(start, 0)
+	for n := 0; n < len(paths); n++ {
+		path := paths[(start+n)%len(paths)]
+		if strings.HasPrefix(strings.ToLower(filepath.Base(path)), typed) {
+			s.selectOnly(path)
+			return true
+		}
+	}
+	return false
+}
+
+// Retain drops the selected entries that are no longer listed
+func (s *selection) Retain(paths []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	listed := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		listed[path] = true
+	}
+	for path := range s.selected {
+		if !listed[path] {
+			delete(s.selected, path)
+		}
+	}
+}
+
+func (s *selection) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.selected = make(map[string]bool)
+	s.cursor, s.anchor, s.typed = "", "", ""
+}
+
+// Paths returns the selected paths in listed order
+func (s *selection) Paths(paths []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var selected []string
+	for _, path := range paths {
+		if s.selected[path] {
+			selected = append(selected, path)
+		}
+	}
+	return selected
+}
+
+func (s *selection) IsSelected(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.selected[path]
+}
+
+func (s *selection) Cursor() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+func (s *selection) selectOnly(path string) {
+	s.selected = map[string]bool{path: true}
+	s.cursor, s.anchor = path, path
+}
+
+// selectRange selects the entries between the anchor and i, keeping the rest of the
+// selection if add. The anchor stays, so the range can be changed.
+func (s *selection) selectRange(paths []string, i int, add bool) {
+	anchor := indexOf(paths, s.anchor)
+	if anchor < 0 {
+		anchor = i
+		s.anchor = paths[i]
+	}
+	if !add {
+		s.selected = make(map[string]bool)
+	}
+	for j := min(anchor, i); j <= max(anchor, i); j++ {
+		s.selected[paths[j]] = true
+	}
+	s.cursor = paths[i]
+}
+
+func indexOf(paths []string, path string) int {
+	if path == "" {
+		return -1
+	}
+	for i, p := range paths {
+		if p == path {
+			return i
+		}
+	}
+	return -1
+}
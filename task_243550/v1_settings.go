@@ -0,0 +1,190 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"image/color"
+	"os"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Preference keys of the settings restored on startup
+const (
+	prefTheme        = "theme"
+	prefDualPane     = "dualPane"
+	prefLastDir      = "lastDir"
+	prefWindowWidth  = "windowWidth"
+	prefWindowHeight = "windowHeight"
+)
+
+// Themes the settings offer, the system one follows the light or dark preference of
+// the desktop
+const (
+	themeSystem = "System"
+	themeLight  = "Light"
+	themeDark   = "Dark"
+)
+
+var themes = []string{themeSystem, themeLight, themeDark}
+
+// defaultWindowSize is used on the first start, and minWindowSize keeps a bad saved
+// size from opening an unusable window
+var (
+	defaultWindowSize = fyne.NewSize(1000, 600)
+	minWindowSize     = fyne.NewSize(400, 300)
+)
+
+// settings reads and writes the preferences of the explorer, falling back to the
+// defaults for missing or unknown values
+type settings struct {
+	prefs fyne.Preferences
+}
+
+func (s settings) Theme() string {
+	name := s.prefs.String(prefTheme)
+	for _, known := range themes {
+		if name == known {
+			return name
+		}
+	}
+	return themeSystem
+}
+
+func (s settings) SetTheme(name string) {
+	s.prefs.SetString(prefTheme, name)
+}
+
+func (s settings) SortBy() SortKey {
+	key := SortKey(s.prefs.String(prefSortBy))
+	for _, known := range sortKeys {
+		if key == known {
+			return key
+		}
+	}
+	return SortByName
+}
+
+func (s settings) SetSortBy(key SortKey) {
+	s.prefs.SetString(prefSortBy, string(key))
+}
+
+func (s settings) ShowHidden() bool {
+	return s.prefs.Bool(prefShowHidden)
+}
+
+func (s settings) SetShowHidden(on bool) {
+	s.prefs.SetBool(prefShowHidden, on)
+}
+
+func (s settings) DualPane() bool {
+	return s.prefs.Bool(prefDualPane)
+}
+
+func (s settings) SetDualPane(on bool) {
+	s.prefs.SetBool(prefDualPane, on)
+}
+
+// StartDir returns the directory visited last, or home if there is none or it is
+// gone. Archives aren't reopened, their paths aren't directories on disk.
+func (s settings) StartDir(home string) string {
+	dir := s.prefs.String(prefLastDir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return home
+	}
+	return dir
+}
+
+func (s settings) SetLastDir(dir string) {
+	s.prefs.SetString(prefLastDir, dir)
+}
+
+// WindowSize returns the size of the window when it was last closed, at least
+// minWindowSize
+func (s settings) WindowSize() fyne.Size {
+	width := s.prefs.FloatWithFallback(prefWindowWidth, float64(defaultWindowSize.Width))
+	height := s.prefs.FloatWithFallback(prefWindowHeight, float64(defaultWindowSize.Height))
+	return fyne.NewSize(float32(width), float32(height)).Max(minWindowSize)
+}
+
+func (s settings) SetWindowSize(size fyne.Size) {
+	s.prefs.SetFloat(prefWindowWidth, float64(size.Width))
+	s.prefs.SetFloat(prefWindowHeight, float64(size.Height))
+}
+
+// variantTheme is the default theme with its light or dark variant forced
+type variantTheme struct {
+	fyne.Theme
+	variant fyne.ThemeVariant
+}
+
+func (t variantTheme) Color(name fyne.ThemeColorName, _ fyne.ThemeVariant) color.Color {
+	return t.Theme.Color(name, t.variant)
+}
+
+// applyTheme switches the app to one of themes
+func applyTheme(app fyne.App, name string) {
+	switch name {
+	case themeLight:
+		app.Settings().SetTheme(variantTheme{theme.DefaultTheme(), theme.VariantLight})
+	case themeDark:
+		app.Settings().SetTheme(variantTheme{theme.DefaultTheme(), theme.VariantDark})
+	default:
+		app.Settings().SetTheme(theme.DefaultTheme())
+	}
+}
+
+// showSettings edits the settings in a dialog. The view options are applied through
+// the toolbar so it shows them too.
+func (e *explorer) showSettings() {
+	themeChoice := widget.NewRadioGroup(themes, nil)
+	themeChoice.Horizontal = true
+	themeChoice.SetSelected(e.settings.Theme())
+	options := make([]string, len(sortKeys))
+	for i, key := range sortKeys {
+		options[i] = string(key)
+	}
+	sortBy := widget.NewSelect(options, nil)
+	sortBy.SetSelected(string(e.settings.SortBy()))
+	showHidden := widget.NewCheck("Show hidden files", nil)
+	showHidden.SetChecked(e.settings.ShowHidden())
+	dualPane := widget.NewCheck("Open with two panes", nil)
+	dualPane.SetChecked(e.settings.DualPane())
+
+	reset := widget.NewButton("Reset to defaults", func() {
+		themeChoice.SetSelected(themeSystem)
+		sortBy.SetSelected(string(SortByName))
+		showHidden.SetChecked(false)
+		dualPane.SetChecked(false)
+	})
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Theme", themeChoice),
+		widget.NewFormItem("Sort by", sortBy),
+		widget.NewFormItem("", showHidden),
+		widget.NewFormItem("View", dualPane),
+		widget.NewFormItem("", reset),
+	}
+	d := dialog.NewForm("Settings", "Save", "Cancel", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		e.settings.SetTheme(themeChoice.Selected)
+		applyTheme(fyne.CurrentApp(), themeChoice.Selected)
+		e.sortBy.SetSelected(sortBy.Selected)
+		e.showHidden.SetChecked(showHidden.Checked)
+		e.dualPane.SetChecked(dualPane.Checked)
+	}, e.window)
+	d.Resize(fyne.NewSize(450, 300))
+	d.Show()
+}
+
+// saveSession keeps the window size for the next start, the last directory is
+// saved as it is visited
+func (e *explorer) saveSession() {
+	e.settings.SetWindowSize(e.window.Canvas().Size())
+}
@@ -0,0 +1,357 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// loadBatchSize is the number of entries read before the list is updated
+	loadBatchSize = 256
+	// maxHistory is how many directories back and forward remember
+	maxHistory = 100
+)
+
+// ExplorerState is the directory shown by a pane and its entries. Directories are
+// read in the background, in batches that are added to the entries as they arrive,
+// so slow network shares and huge folders don't freeze the UI. Navigating away
+// cancels the load. The entries are cached until the next navigation or reload,
+// the view options pick which of them are shown and in which order. Directories
+// enter the back and forward history once they were read.
+//
+// Listeners are notified after every change, from the loading goroutine.
+type ExplorerState struct {
+	mu      sync.Mutex
+	dir     string
+	all     []fs.DirEntry
+	entries []fs.DirEntry
+	view    ViewOptions
+	loading bool
+	back    []string
+	forward []string
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	listeners      []func()
+	errorListeners []func(error)
+	visitListeners []func(string)
+}
+
+// NewExplorerState starts loading dir
+func NewExplorerState(dir string) *ExplorerState {
+	s := &ExplorerState{view: ViewOptions{SortBy: SortByName}}
+	s.load(dir, "", nil)
+	return s
+}
+
+func (s *ExplorerState) Dir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+// Entries returns the entries loaded so far that the view options show
+func (s *ExplorerState) Entries() []fs.DirEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries
+}
+
+// Loading reports whether the entries are still being read
+func (s *ExplorerState) Loading() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loading
+}
+
+func (s *ExplorerState) View() ViewOptions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.view
+}
+
+// SetView changes which entries are shown and their order
+func (s *ExplorerState) SetView(view ViewOptions) {
+	s.mu.Lock()
+	s.view = view
+	s.entries = view.apply(s.all)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// Entry returns the entry at index i, the list may ask for rows that are gone
+func (s *ExplorerState) Entry(i int) (fs.DirEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i < 0 || i >= len(s.entries) {
+		return nil, false
+	}
+	return s.entries[i], true
+}
+
+// Index returns the index of the entry with the given path, -1 if it isn't shown
+func (s *ExplorerState) Index(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if filepath.Dir(path) != s.dir {
+		return -1
+	}
+	name := filepath.Base(path)
+	for i, entry := range s.entries {
+		if entry.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Path returns the full path of the entry at index i
+func (s *ExplorerState) Path(i int) string {
+	entry, ok := s.Entry(i)
+	if !ok {
+		return ""
+	}
+	return filepath.Join(s.Dir(), entry.Name())
+}
+
+// OnChange registers fn to be called after the directory or its entries changed
+func (s *ExplorerState) OnChange(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// OnError registers fn to be called when a directory can't be read
+func (s *ExplorerState) OnError(fn func(error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorListeners = append(s.errorListeners, fn)
+}
+
+// OnVisit registers fn to be called with every directory navigated to, once it was
+// read
+func (s *ExplorerState) OnVisit(fn func(dir string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.visitListeners = append(s.visitListeners, fn)
+}
+
+// SetDir navigates to dir. If it can't be read the state returns to the directory
+// it was in.
+func (s *ExplorerState) SetDir(dir string) {
+	previous := s.Dir()
+	if dir == previous {
+		return
+	}
+	s.load(dir, previous, func() {
+		s.back = pushHistory(s.back, previous)
+		s.forward = nil
+	})
+}
+
+// Back returns to the directory visited before this one
+func (s *ExplorerState) Back() {
+	s.mu.Lock()
+	if len(s.back) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	previous, dir := s.dir, s.back[len(s.back)-1]
+	s.mu.Unlock()
+	s.load(dir, previous, func() {
+		s.back = s.back[:len(s.back)-1]
+		s.forward = pushHistory(s.forward, previous)
+	})
+}
+
+// Forward returns to the directory left with Back
+func (s *ExplorerState) Forward() {
+	s.mu.Lock()
+	if len(s.forward) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	previous, dir := s.dir, s.forward[len(s.forward)-1]
+	s.mu.Unlock()
+	s.load(dir, previous, func() {
+		s.forward = s.forward[:len(s.forward)-1]
+		s.back = pushHistory(s.back, previous)
+	})
+}
+
+func (s *ExplorerState) CanBack() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.back) > 0
+}
+
+func (s *ExplorerState) CanForward() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.forward) > 0
+}
+
+// pushHistory appends dir, dropping the oldest entry past maxHistory
+func pushHistory(history []string, dir string) []string {
+	history = append(history, dir)
+	if len(history) > maxHistory {
+		history = append([]string{}, history[len(history)-maxHistory:]...)
+	}
+	return history
+}
+
+// Open navigates into the entry at index i if it is a directory or an archive. It
+// reports whether it did.
+func (s *ExplorerState) Open(i int) bool {
+	entry, ok := s.Entry(i)
+	if !ok {
+		return false
+	}
+	if entry.IsDir() || (isArchive(entry.Name()) && !inArchive(s.Dir())) {
+		s.SetDir(s.Path(i))
+		return true
+	}
+	return false
+}
+
+// Up navigates to the parent directory, it does nothing at the root
+func (s *ExplorerState) Up() {
+	dir := s.Dir()
+	if parent := parentDir(dir); parent != dir {
+		s.SetDir(parent)
+	}
+}
+
+// Reload reads the entries of the current directory again
+func (s *ExplorerState) Reload() {
+	dir := s.Dir()
+	s.load(dir, dir, nil)
+}
+
+// loaded returns a channel closed when the current load finished or was cancelled
+func (s *ExplorerState) loaded() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// load cancels the load in progress and starts reading dir, returning to previous
+// if that fails. Reloading keeps the entries shown until the new ones are read.
+// Once dir was read, visited is called holding the lock to update the history.
+func (s *ExplorerState) load(dir, previous string, visited func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	reload := dir == previous
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.dir, s.loading = dir, true
+	if !reload {
+		s.all, s.entries = nil, nil
+	}
+	s.cancel, s.done = cancel, done
+	s.mu.Unlock()
+	s.notify()
+
+	go func() {
+		defer close(done)
+		err := s.read(ctx, dir, !reload)
+
+		s.mu.Lock()
+		// a newer load owns the state once this one is cancelled, which only
+		// happens while holding the lock
+		if ctx.Err() != nil {
+			s.mu.Unlock()
+			return
+		}
+		s.loading = false
+		if err == nil && visited != nil {
+			visited()
+		}
+		visitListeners := s.visitListeners
+		s.mu.Unlock()
+		cancel()
+		s.notify()
+
+		if err == nil && !reload {
+			for _, fn := range visitListeners {
+				fn(dir)
+			}
+		}
+
+		if err != nil {
+			s.mu.Lock()
+			listeners := s.errorListeners
+			s.mu.Unlock()
+			for _, fn := range listeners {
+				fn(err)
+			}
+			if previous != "" && previous != dir {
+				s.load(previous, "", nil)
+			}
+		}
+	}()
+}
+
+// read adds the entries of dir in batches. Unless incremental, the entries are
+// replaced once all are read.
+func (s *ExplorerState) read(ctx context.Context, dir string, incremental bool) error {
+	f, err := openDir(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []fs.DirEntry
+	publish := func() bool {
+		s.mu.Lock()
+		if ctx.Err() != nil {
+			s.mu.Unlock()
+			return false
+		}
+		// the slices shown to callers are never modified
+		s.all = append([]fs.DirEntry{}, entries...)
+		s.entries = s.view.apply(s.all)
+		s.mu.Unlock()
+		s.notify()
+		return true
+	}
+
+	for {
+		batch, err := f.ReadDir(loadBatchSize)
+		entries = append(entries, batch...)
+		if incremental && len(batch) > 0 && !publish() {
+			return nil
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	if !incremental {
+		publish()
+	}
+	return nil
+}
+
+func (s *ExplorerState) notify() {
+	s.mu.Lock()
+	listeners := s.listeners
+	s.mu.Unlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
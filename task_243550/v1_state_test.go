@@ -0,0 +1,1003 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+)
+
+// wait blocks until the loads started so far have finished
+func wait(state *ExplorerState) {
+	for {
+		done := state.loaded()
+		<-done
+		if state.loaded() == done {
+			return
+		}
+	}
+}
+
+func TestExplorerState(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0o755)
+	os.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0o644)
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("n"), 0o644)
+
+	state := NewExplorerState(root)
+	wait(state)
+	var errs []error
+	state.OnError(func(err error) { errs = append(errs, err) })
+
+	t.Run("Entries Are Cached", func(t *testing.T) {
+		assert.Len(t, state.Entries(), 2)
+		assert.False(t, state.Loading())
+		os.WriteFile(filepath.Join(root, "later.txt"), nil, 0o644)
+		assert.Len(t, state.Entries(), 2)
+		state.Reload()
+		wait(state)
+		assert.Len(t, state.Entries(), 3)
+	})
+
+	t.Run("Open Directory", func(t *testing.T) {
+		state.Open(0)
+		wait(state)
+		assert.Equal(t, filepath.Join(root, "docs"), state.Dir())
+		assert.Equal(t, filepath.Join(root, "docs", "a.txt"), state.Path(0))
+	})
+
+	t.Run("Opening A File Does Nothing", func(t *testing.T) {
+		state.Open(0)
+		wait(state)
+		assert.Equal(t, filepath.Join(root, "docs"), state.Dir())
+	})
+
+	t.Run("Unreadable Directory Returns To The Previous One", func(t *testing.T) {
+		state.SetDir(filepath.Join(root, "missing"))
+		wait(state)
+		assert.Len(t, errs, 1)
+		assert.Equal(t, filepath.Join(root, "docs"), state.Dir())
+		assert.Len(t, state.Entries(), 1)
+	})
+
+	t.Run("Up", func(t *testing.T) {
+		state.Up()
+		wait(state)
+		assert.Equal(t, root, state.Dir())
+		_, ok := state.Entry(10)
+		assert.False(t, ok)
+	})
+}
+
+func TestExplorerStateLoadsInBatches(t *testing.T) {
+	big, small := t.TempDir(), t.TempDir()
+	for i := 0; i < loadBatchSize*3; i++ {
+		os.WriteFile(filepath.Join(big, fmt.Sprintf("f%04d", i)), nil, 0o644)
+	}
+	os.WriteFile(filepath.Join(small, "only"), nil, 0o644)
+
+	state := NewExplorerState(small)
+	wait(state)
+	var mu sync.Mutex
+	var sizes []int
+	state.OnChange(func() {
+		mu.Lock()
+		sizes = append(sizes, len(state.Entries()))
+		mu.Unlock()
+	})
+
+	state.SetDir(big)
+	wait(state)
+	assert.Len(t, state.Entries(), loadBatchSize*3)
+	assert.Equal(t, "f0000", state.Entries()[0].Name())
+	mu.Lock()
+	assert.Contains(t, sizes, loadBatchSize, "the first batch is shown before the rest is read")
+	mu.Unlock()
+
+	// navigating away cancels the load, its batches never show up
+	state.SetDir(big)
+	state.SetDir(small)
+	wait(state)
+	assert.Equal(t, small, state.Dir())
+	assert.Len(t, state.Entries(), 1)
+}
+
+func TestHistory(t *testing.T) {
+	root := t.TempDir()
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	os.Mkdir(a, 0o755)
+	os.Mkdir(b, 0o755)
+
+	state := NewExplorerState(root)
+	wait(state)
+	assert.False(t, state.CanBack())
+
+	state.SetDir(a)
+	wait(state)
+	state.SetDir(b)
+	wait(state)
+
+	t.Run("Back And Forward", func(t *testing.T) {
+		state.Back()
+		wait(state)
+		assert.Equal(t, a, state.Dir())
+		assert.True(t, state.CanForward())
+		state.Back()
+		wait(state)
+		assert.Equal(t, root, state.Dir())
+		assert.False(t, state.CanBack())
+		state.Forward()
+		wait(state)
+		assert.Equal(t, a, state.Dir())
+	})
+
+	t.Run("Navigating Clears Forward", func(t *testing.T) {
+		state.Up()
+		wait(state)
+		assert.Equal(t, root, state.Dir())
+		assert.False(t, state.CanForward())
+		state.Back()
+		wait(state)
+		assert.Equal(t, a, state.Dir())
+	})
+
+	t.Run("Failed Navigation Is Not Recorded", func(t *testing.T) {
+		state.SetDir(filepath.Join(root, "missing"))
+		wait(state)
+		assert.Equal(t, a, state.Dir())
+		state.Back()
+		wait(state)
+		assert.Equal(t, root, state.Dir())
+	})
+
+	t.Run("History Is Limited", func(t *testing.T) {
+		var history []string
+		for i := 0; i < maxHistory+10; i++ {
+			history = pushHistory(history, fmt.Sprint(i))
+		}
+		assert.Len(t, history, maxHistory)
+		assert.Equal(t, "10", history[0])
+	})
+}
+
+func TestLocation(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0o755)
+	os.WriteFile(filepath.Join(root, "file.txt"), nil, 0o644)
+	home, _ := os.UserHomeDir()
+
+	t.Run("Resolve", func(t *testing.T) {
+		tests := []struct {
+			text string
+			want string
+		}{
+			{root, root},
+			{"  " + root + "/docs/ ", filepath.Join(root, "docs")},
+			{"docs", filepath.Join(root, "docs")},
+			{"docs/..", root},
+			{"~", home},
+		}
+		for _, tt := range tests {
+			got, err := resolveLocation(tt.text, root)
+			assert.NoError(t, err, tt.text)
+			assert.Equal(t, tt.want, got, tt.text)
+		}
+	})
+
+	t.Run("Invalid Locations", func(t *testing.T) {
+		for _, text := range []string{"", "missing", "file.txt"} {
+			_, err := resolveLocation(text, root)
+			assert.Error(t, err, text)
+		}
+	})
+
+	t.Run("Segments", func(t *testing.T) {
+		assert.Equal(t, []pathSegment{
+			{Name: "/", Path: "/"},
+			{Name: "home", Path: "/home"},
+			{Name: "user", Path: "/home/user"},
+		}, pathSegments("/home/user/"))
+		assert.Equal(t, []pathSegment{{Name: "/", Path: "/"}}, pathSegments("/"))
+	})
+}
+
+func TestPlaces(t *testing.T) {
+	p := places{prefs: test.NewTempApp(t).Preferences()}
+
+	t.Run("Bookmarks", func(t *testing.T) {
+		assert.Empty(t, p.Bookmarks())
+		assert.True(t, p.AddBookmark("/a"))
+		assert.True(t, p.AddBookmark("/b"))
+		assert.False(t, p.AddBookmark("/a"))
+		assert.Equal(t, []string{"/a", "/b"}, p.Bookmarks())
+		p.RemoveBookmark("/a")
+		assert.Equal(t, []string{"/b"}, p.Bookmarks())
+	})
+
+	t.Run("Recent Folders", func(t *testing.T) {
+		for i := 0; i < maxRecent+2; i++ {
+			p.AddRecent(fmt.Sprint("/", i))
+		}
+		p.AddRecent("/5")
+		recent := p.Recent()
+		assert.Len(t, recent, maxRecent)
+		assert.Equal(t, []string{"/5", "/11", "/10"}, recent[:3])
+	})
+
+	t.Run("Standard Places", func(t *testing.T) {
+		home := t.TempDir()
+		os.Mkdir(filepath.Join(home, "Documents"), 0o755)
+		assert.Equal(t, []place{
+			{Name: "Home", Path: home},
+			{Name: "Documents", Path: filepath.Join(home, "Documents")},
+		}, standardPlaces(home))
+	})
+}
+
+func TestRecentFolders(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	os.Mkdir(sub, 0o755)
+
+	state := NewExplorerState(root)
+	wait(state)
+	var mu sync.Mutex
+	var visited []string
+	state.OnVisit(func(dir string) {
+		mu.Lock()
+		visited = append(visited, dir)
+		mu.Unlock()
+	})
+
+	state.SetDir(sub)
+	wait(state)
+	state.Reload()
+	wait(state)
+	state.SetDir(filepath.Join(root, "missing"))
+	wait(state)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// reloads aren't visits, a failed navigation only revisits where it started
+	assert.Equal(t, []string{sub, sub}, visited)
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	os.Mkdir(sub, 0o755)
+	os.WriteFile(filepath.Join(root, "a.txt"), nil, 0o644)
+
+	state := NewExplorerState(root)
+	wait(state)
+	w, err := watchState(state, 50*time.Millisecond)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+	count := func(n int) func() bool {
+		return func() bool { return len(state.Entries()) == n && !state.Loading() }
+	}
+
+	t.Run("Created And Removed Files", func(t *testing.T) {
+		os.WriteFile(filepath.Join(root, "b.txt"), nil, 0o644)
+		assert.Eventually(t, count(3), 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, 2, state.Index(filepath.Join(root, "b.txt")))
+
+		os.Remove(filepath.Join(root, "a.txt"))
+		assert.Eventually(t, count(2), 2*time.Second, 10*time.Millisecond)
+		assert.Equal(t, -1, state.Index(filepath.Join(root, "a.txt")))
+		assert.Equal(t, 1, state.Index(filepath.Join(root, "b.txt")))
+	})
+
+	t.Run("Follows Navigation", func(t *testing.T) {
+		state.SetDir(sub)
+		wait(state)
+		assert.Eventually(t, count(0), 2*time.Second, 10*time.Millisecond)
+		os.WriteFile(filepath.Join(sub, "c.txt"), nil, 0o644)
+		assert.Eventually(t, count(1), 2*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestViewOptions(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "zdir"), 0o755)
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("bbbbbb"), 0o644)
+	os.WriteFile(filepath.Join(root, "a.md"), []byte("aaa"), 0o644)
+	os.WriteFile(filepath.Join(root, "c.go"), []byte("c"), 0o644)
+	os.WriteFile(filepath.Join(root, ".hidden"), nil, 0o644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(root, "b.txt"), old, old)
+	os.Chtimes(filepath.Join(root, "a.md"), old.Add(-time.Hour), old.Add(-time.Hour))
+
+	state := NewExplorerState(root)
+	wait(state)
+	names := func() []string {
+		var names []string
+		for _, entry := range state.Entries() {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	t.Run("Sort Orders", func(t *testing.T) {
+		tests := []struct {
+			sortBy SortKey
+			want   []string
+		}{
+			{SortByName, []string{"zdir", "a.md", "b.txt", "c.go"}},
+			{SortBySize, []string{"zdir", "b.txt", "a.md", "c.go"}},
+			{SortByModified, []string{"zdir", "c.go", "b.txt", "a.md"}},
+			{SortByType, []string{"zdir", "c.go", "a.md", "b.txt"}},
+		}
+		for _, tt := range tests {
+			state.SetView(ViewOptions{SortBy: tt.sortBy})
+			assert.Equal(t, tt.want, names(), tt.sortBy)
+		}
+	})
+
+	t.Run("Hidden Files", func(t *testing.T) {
+		state.SetView(ViewOptions{SortBy: SortByName, ShowHidden: true})
+		assert.Equal(t, []string{"zdir", ".hidden", "a.md", "b.txt", "c.go"}, names())
+	})
+
+	t.Run("Filter Ignores Case", func(t *testing.T) {
+		state.SetView(ViewOptions{SortBy: SortByName, Filter: "TX"})
+		assert.Equal(t, []string{"b.txt"}, names())
+		assert.Equal(t, filepath.Join(root, "b.txt"), state.Path(0))
+	})
+
+	t.Run("Options Survive Navigation", func(t *testing.T) {
+		state.SetView(ViewOptions{SortBy: SortByName, Filter: "dir"})
+		state.Open(0)
+		wait(state)
+		assert.Equal(t, filepath.Join(root, "zdir"), state.Dir())
+		state.Up()
+		wait(state)
+		assert.Equal(t, []string{"zdir"}, names())
+	})
+}
+
+func TestFileDetails(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(root, name)
+		os.WriteFile(path, data, 0o640)
+		return path
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		path := write("notes.txt", []byte("hello"))
+		details, err := readDetails(path)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), details.Size)
+		assert.Equal(t, "-rw-r-----", details.Mode.String())
+		assert.Equal(t, "text/plain; charset=utf-8", details.MIME)
+		assert.Equal(t, previewText, details.Preview)
+	})
+
+	t.Run("Type From Content", func(t *testing.T) {
+		png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		details, err := readDetails(write("picture", png))
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", details.MIME)
+		assert.Equal(t, previewImage, details.Preview)
+	})
+
+	t.Run("Binary Files Have No Preview", func(t *testing.T) {
+		details, err := readDetails(write("data.bin", []byte{0, 1, 2}))
+		assert.NoError(t, err)
+		assert.Equal(t, previewNone, details.Preview)
+	})
+
+	t.Run("Text Preview Is Limited", func(t *testing.T) {
+		// a multi-byte character straddles the limit
+		data := append(bytes.Repeat([]byte("a"), maxTextPreview-1), "é and more"...)
+		text, ok := readTextPreview(write("long.txt", data))
+		assert.True(t, ok)
+		assert.Len(t, text, maxTextPreview-1)
+
+		_, ok = readTextPreview(write("latin1.txt", []byte{'c', 'a', 'f', 0xe9}))
+		assert.False(t, ok)
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		_, err := readDetails(filepath.Join(root, "missing"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Format Size", func(t *testing.T) {
+		assert.Equal(t, "512 B", formatSize(512))
+		assert.Equal(t, "1.5 KB", formatSize(1536))
+		assert.Equal(t, "3.0 GB", formatSize(3<<30))
+	})
+}
+
+func TestArchives(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(t.TempDir(), "project")
+	os.MkdirAll(filepath.Join(src, "docs"), 0o755)
+	os.WriteFile(filepath.Join(src, "main.go"), []byte("package main"), 0o644)
+	os.WriteFile(filepath.Join(src, "docs", "readme.txt"), []byte("read me"), 0o600)
+
+	for _, ext := range compressFormats {
+		t.Run("Browse And Extract "+ext, func(t *testing.T) {
+			archive := filepath.Join(root, "project"+ext)
+			var done, total int64
+			err := compressPath([]string{src}, archive, func(n, of int64) { done, total = n, of })
+			assert.NoError(t, err)
+			assert.Equal(t, int64(19), total)
+			assert.Equal(t, total, done)
+			assert.Error(t, compressPath([]string{src}, archive, func(int64, int64) {}), "existing archives are kept")
+
+			state := NewExplorerState(root)
+			wait(state)
+			assert.True(t, state.Open(state.Index(archive)))
+			wait(state)
+			assert.Equal(t, archive, state.Dir())
+			assert.Len(t, state.Entries(), 1)
+			state.Open(0)
+			wait(state)
+			assert.Equal(t, filepath.Join(archive, "project"), state.Dir())
+			assert.Equal(t, []string{"docs", "main.go"}, []string{state.Entries()[0].Name(), state.Entries()[1].Name()})
+
+			inner := filepath.Join(archive, "project", "docs", "readme.txt")
+			details, err := readDetails(inner)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), details.Size)
+			text, ok := readTextPreview(inner)
+			assert.True(t, ok)
+			assert.Equal(t, "read me", text)
+
+			dst := extractTarget(archive)
+			assert.Equal(t, root, filepath.Dir(dst))
+			assert.NoError(t, extractArchive(archive, dst, func(int64, int64) {}))
+			data, err := os.ReadFile(filepath.Join(dst, "project", "docs", "readme.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, "read me", string(data))
+			assert.Error(t, extractArchive(archive, dst, func(int64, int64) {}), "existing directories are kept")
+			os.RemoveAll(dst)
+		})
+	}
+
+	t.Run("Entries Outside The Archive", func(t *testing.T) {
+		archive := filepath.Join(root, "evil.zip")
+		f, _ := os.Create(archive)
+		zw := zip.NewWriter(f)
+		w, _ := zw.Create("../evil.txt")
+		w.Write([]byte("evil"))
+		zw.Close()
+		f.Close()
+
+		dst := extractTarget(archive)
+		assert.Error(t, extractArchive(archive, dst, func(int64, int64) {}))
+		assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+		assert.NoDirExists(t, dst)
+	})
+
+	t.Run("Split Archive Paths", func(t *testing.T) {
+		archive := filepath.Join(root, "split.tgz")
+		assert.NoError(t, compressPath([]string{src}, archive, func(int64, int64) {}))
+		tests := []struct {
+			path  string
+			inner string
+			ok    bool
+		}{
+			{archive, ".", true},
+			{filepath.Join(archive, "project", "docs"), "project/docs", true},
+			{src, "", false},
+			// not a file, only a directory named like an archive
+			{filepath.Join(root, "dir.zip", "x"), "", false},
+		}
+		os.Mkdir(filepath.Join(root, "dir.zip"), 0o755)
+		for _, tt := range tests {
+			_, inner, ok := splitArchivePath(tt.path)
+			assert.Equal(t, tt.ok, ok, tt.path)
+			assert.Equal(t, tt.inner, inner, tt.path)
+		}
+	})
+}
+
+func TestSelection(t *testing.T) {
+	paths := []string{"/d/alpha", "/d/beta", "/d/bravo", "/d/charlie", "/d/delta"}
+	sel := newSelection()
+	selected := func() []string { return sel.Paths(paths) }
+
+	t.Run("Click", func(t *testing.T) {
+		sel.Click(paths, 1, 0)
+		sel.Click(paths, 3, 0)
+		assert.Equal(t, []string{"/d/charlie"}, selected())
+		sel.Click(paths, 0, fyne.KeyModifierControl)
+		assert.Equal(t, []string{"/d/alpha", "/d/charlie"}, selected())
+		sel.Click(paths, 3, fyne.KeyModifierSuper)
+		assert.Equal(t, []string{"/d/alpha"}, selected())
+	})
+
+	t.Run("Shift Ranges", func(t *testing.T) {
+		sel.Click(paths, 1, 0)
+		sel.Click(paths, 3, fyne.KeyModifierShift)
+		assert.Equal(t, []string{"/d/beta", "/d/bravo", "/d/charlie"}, selected())
+		// the range follows the last shift click
+		sel.Click(paths, 0, fyne.KeyModifierShift)
+		assert.Equal(t, []string{"/d/alpha", "/d/beta"}, selected())
+		sel.Click(paths, 4, fyne.KeyModifierShift|fyne.KeyModifierControl)
+		assert.Equal(t, paths, selected())
+	})
+
+	t.Run("Arrows", func(t *testing.T) {
+		sel.Clear()
+		sel.Move(paths, 1, false)
+		assert.Equal(t, "/d/alpha", sel.Cursor(), "the first key starts at the top")
+		sel.Move(paths, 2, false)
+		assert.Equal(t, []string{"/d/bravo"}, selected())
+		sel.Move(paths, 1, true)
+		sel.Move(paths, 10, true)
+		assert.Equal(t, []string{"/d/bravo", "/d/charlie", "/d/delta"}, selected())
+		sel.Move(paths, -len(paths), false)
+		assert.Equal(t, []string{"/d/alpha"}, selected())
+		sel.ToggleCursor(paths)
+		assert.Empty(t, selected())
+	})
+
+	t.Run("Type Ahead", func(t *testing.T) {
+		now := time.Now()
+		sel.Clear()
+		assert.True(t, sel.TypeAhead(paths, 'B', now))
+		assert.Equal(t, "/d/beta", sel.Cursor())
+		assert.True(t, sel.TypeAhead(paths, 'r', now.Add(100*time.Millisecond)))
+		assert.Equal(t, "/d/bravo", sel.Cursor())
+		assert.False(t, sel.TypeAhead(paths, 'x', now.Add(200*time.Millisecond)))
+
+		// after a pause a new search starts, repeating a letter cycles
+		later := now.Add(2 * time.Second)
+		assert.True(t, sel.TypeAhead(paths, 'b', later))
+		assert.Equal(t, "/d/beta", sel.Cursor(), "wraps around after bravo")
+		assert.True(t, sel.TypeAhead(paths, 'b', later))
+		assert.Equal(t, "/d/bravo", sel.Cursor())
+		assert.Equal(t, []string{"/d/bravo"}, selected())
+	})
+
+	t.Run("Retain", func(t *testing.T) {
+		sel.SelectAll(paths)
+		sel.Retain(paths[2:])
+		assert.Equal(t, paths[2:], selected())
+	})
+}
+
+func TestCopyAndMove(t *testing.T) {
+	left, right := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(left, "tree", "sub"), 0o755)
+	os.WriteFile(filepath.Join(left, "tree", "sub", "f.txt"), []byte("content"), 0o600)
+	os.WriteFile(filepath.Join(left, "single.txt"), []byte("single"), 0o644)
+
+	assert.NoError(t, copyPath(filepath.Join(left, "tree"), right))
+	data, err := os.ReadFile(filepath.Join(right, "tree", "sub", "f.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "content", string(data))
+	info, _ := os.Stat(filepath.Join(right, "tree", "sub", "f.txt"))
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	assert.Error(t, copyPath(filepath.Join(left, "tree"), right), "existing targets are not overwritten")
+	assert.Error(t, copyPath(filepath.Join(left, "tree"), filepath.Join(left, "tree", "sub")), "a directory can't be copied into itself")
+
+	assert.NoError(t, movePath(filepath.Join(left, "single.txt"), right))
+	_, err = os.Stat(filepath.Join(left, "single.txt"))
+	assert.True(t, os.IsNotExist(err))
+	data, _ = os.ReadFile(filepath.Join(right, "single.txt"))
+	assert.Equal(t, "single", string(data))
+}
+
+func TestDiskUsage(t *testing.T) {
+	root := t.TempDir()
+	for path, size := range map[string]int{
+		"big/a.bin":                   300,
+		"big/nested/b.bin":            200,
+		"small/c.txt":                 10,
+		"node_modules/lib/d.js":       1000,
+		"image.iso":                   5000,
+		"top.txt":                     40,
+		"big/nested/deeper/empty.txt": 0,
+	} {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, make([]byte, size), 0o644)
+	}
+
+	t.Run("Exclusion Patterns", func(t *testing.T) {
+		patterns, err := parseExcludes(" node_modules, *.iso ,,")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"node_modules", "*.iso"}, patterns)
+		_, err = parseExcludes("[a-")
+		assert.Error(t, err)
+	})
+
+	scan := &UsageScan{Exclude: []string{"node_modules", "*.iso"}}
+	node, err := scan.Run(context.Background(), root)
+	assert.NoError(t, err)
+
+	t.Run("Sizes Are Summed Largest First", func(t *testing.T) {
+		assert.Equal(t, int64(550), node.Size)
+		assert.Equal(t, int64(5), node.Files)
+		names := []string{}
+		for _, child := range node.Children {
+			names = append(names, child.Name)
+		}
+		assert.Equal(t, []string{"big", "top.txt", "small"}, names)
+		assert.Equal(t, int64(500), node.Children[0].Size)
+		assert.Equal(t, "nested", node.Children[0].Children[1].Name)
+		files, bytes := scan.Progress()
+		assert.Equal(t, int64(5), files)
+		assert.Equal(t, int64(550), bytes)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, writeUsageCSV(&buf, node))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Equal(t, "Path,Type,Size,Files,Percent,Error", lines[0])
+		assert.Equal(t, root+",directory,550,5,100.0,", lines[1])
+		assert.Equal(t, filepath.Join(root, "big")+",directory,500,3,90.9,", lines[2])
+		assert.Len(t, lines, 11)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := (&UsageScan{}).Run(ctx, root)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestDuplicates(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string, age time.Duration) string {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+		modified := time.Now().Add(-age)
+		os.Chtimes(path, modified, modified)
+		return path
+	}
+	a := write("a.txt", "same content", time.Hour)
+	b := write("nested/deep/b.txt", "same content", 3*time.Hour)
+	c := write("nested/c.txt", "same content", 2*time.Hour)
+	write("other.txt", "diff content", 0)
+	d := write("big/d.bin", strings.Repeat("x", 100), 0)
+	e := write("e.bin", strings.Repeat("x", 100), 0)
+	write("empty1", "", 0)
+	write("empty2", "", 0)
+	link := filepath.Join(root, "z-link.txt")
+	os.Link(a, link)
+
+	scan := &DuplicateScan{Workers: 2}
+	groups, err := scan.Run(context.Background(), root)
+	assert.NoError(t, err)
+
+	t.Run("Groups By Content Most Wasted First", func(t *testing.T) {
+		assert.Len(t, groups, 2)
+		paths := func(g DuplicateGroup) []string {
+			var list []string
+			for _, f := range g.Files {
+				list = append(list, f.Path)
+			}
+			return list
+		}
+		assert.Equal(t, []string{d, e}, paths(groups[0]))
+		assert.Equal(t, int64(100), groups[0].Wasted())
+		// the hard link shares its file with a.txt, so only one of them is listed
+		assert.Len(t, groups[1].Files, 3)
+		assert.Equal(t, int64(24), groups[1].Wasted())
+		done, total := scan.Progress()
+		assert.Equal(t, total, done)
+	})
+
+	t.Run("Selection Helpers", func(t *testing.T) {
+		g := groups[1:]
+		assert.False(t, selectDuplicates(g, KeepOldest)[b])
+		assert.Len(t, selectDuplicates(g, KeepOldest), 2)
+		newest := selectDuplicates(g, KeepNewest)
+		assert.True(t, newest[b] && newest[c])
+		shortest := selectDuplicates(g, KeepShortest)
+		assert.True(t, shortest[b] && shortest[c])
+	})
+
+	t.Run("Every Copy Selected", func(t *testing.T) {
+		all := map[string]bool{d: true, e: true}
+		assert.Error(t, resolveDuplicates(groups[:1], all, false))
+		assert.FileExists(t, d)
+		assert.FileExists(t, e)
+	})
+
+	t.Run("Hard Link", func(t *testing.T) {
+		assert.NoError(t, resolveDuplicates(groups[1:], selectDuplicates(groups[1:], KeepOldest), true))
+		kept, _ := os.Stat(b)
+		for _, path := range []string{a, c} {
+			info, err := os.Stat(path)
+			assert.NoError(t, err)
+			assert.True(t, os.SameFile(kept, info), path)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, resolveDuplicates(groups[:1], map[string]bool{e: true}, false))
+		assert.NoFileExists(t, e)
+		assert.FileExists(t, d)
+		// the other name of a.txt still holds the old copy
+		groups, _ := (&DuplicateScan{}).Run(context.Background(), root)
+		assert.Len(t, groups, 1)
+		assert.Equal(t, link, groups[0].Files[1].Path)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := (&DuplicateScan{}).Run(ctx, root)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSettings(t *testing.T) {
+	s := settings{prefs: test.NewTempApp(t).Preferences()}
+	home, last := t.TempDir(), t.TempDir()
+
+	t.Run("Defaults", func(t *testing.T) {
+		assert.Equal(t, themeSystem, s.Theme())
+		assert.Equal(t, SortByName, s.SortBy())
+		assert.False(t, s.ShowHidden())
+		assert.False(t, s.DualPane())
+		assert.Equal(t, home, s.StartDir(home))
+		assert.Equal(t, defaultWindowSize, s.WindowSize())
+	})
+
+	t.Run("Saved Values", func(t *testing.T) {
+		s.SetTheme(themeDark)
+		s.SetSortBy(SortBySize)
+		s.SetShowHidden(true)
+		s.SetDualPane(true)
+		s.SetLastDir(last)
+		s.SetWindowSize(fyne.NewSize(1200, 800))
+		assert.Equal(t, themeDark, s.Theme())
+		assert.Equal(t, SortBySize, s.SortBy())
+		assert.True(t, s.ShowHidden())
+		assert.True(t, s.DualPane())
+		assert.Equal(t, last, s.StartDir(home))
+		assert.Equal(t, fyne.NewSize(1200, 800), s.WindowSize())
+	})
+
+	t.Run("Invalid Values Fall Back", func(t *testing.T) {
+		s.prefs.SetString(prefTheme, "Solarized")
+		s.prefs.SetString(prefSortBy, "Color")
+		s.SetWindowSize(fyne.NewSize(10, 2000))
+		assert.Equal(t, themeSystem, s.Theme())
+		assert.Equal(t, SortByName, s.SortBy())
+		assert.Equal(t, fyne.NewSize(400, 2000), s.WindowSize())
+	})
+
+	t.Run("Missing Last Directory", func(t *testing.T) {
+		os.Remove(last)
+		assert.Equal(t, home, s.StartDir(home))
+		archive := filepath.Join(home, "photos.zip")
+		os.WriteFile(archive, nil, 0o644)
+		s.SetLastDir(filepath.Join(archive, "2024"))
+		assert.Equal(t, home, s.StartDir(home))
+	})
+}
+
+func TestBatchRename(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"IMG_001.JPG", "IMG_002.JPG", "notes draft.txt", "Photos 2024"}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(dir, name)
+		if i == len(names)-1 {
+			os.Mkdir(paths[i], 0o755)
+		} else {
+			os.WriteFile(paths[i], []byte(name), 0o644)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "taken.txt"), nil, 0o644)
+
+	newNames := func(plans []RenamePlan) []string {
+		var list []string
+		for _, plan := range plans {
+			list = append(list, plan.NewName)
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		rule     RenameRule
+		expected []string
+	}{
+		{
+			name:     "Find And Replace",
+			rule:     RenameRule{Find: "img_", Replace: "holiday-", IgnoreCase: true},
+			expected: []string{"holiday-001.JPG", "holiday-002.JPG", "notes draft.txt", "Photos 2024"},
+		},
+		{
+			name:     "Regex With Groups",
+			rule:     RenameRule{Find: `^IMG_(\d+)$`, Replace: "photo $1", Regex: true},
+			expected: []string{"photo 001.JPG", "photo 002.JPG", "notes draft.txt", "Photos 2024"},
+		},
+		{
+			name:     "Numbering Template",
+			rule:     RenameRule{Template: "{n} - {name}", Start: 9, Digits: 2},
+			expected: []string{"09 - IMG_001.JPG", "10 - IMG_002.JPG", "11 - notes draft.txt", "12 - Photos 2024"},
+		},
+		{
+			name:     "Case Transforms Keep Extensions",
+			rule:     RenameRule{Case: CaseTitle},
+			expected: []string{"Img_001.JPG", "Img_002.JPG", "Notes Draft.txt", "Photos 2024"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plans, err := planRenames(paths, tt.rule)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, newNames(plans))
+			for _, plan := range plans {
+				assert.Empty(t, plan.Conflict, plan.NewName)
+			}
+		})
+	}
+
+	t.Run("Invalid Regex", func(t *testing.T) {
+		_, err := planRenames(paths, RenameRule{Find: "(", Regex: true})
+		assert.Error(t, err)
+	})
+
+	t.Run("Conflicts", func(t *testing.T) {
+		plans, _ := planRenames(paths, RenameRule{Template: "same"})
+		assert.Equal(t, "same name as another file", plans[0].Conflict)
+		plans, _ = planRenames(paths[2:3], RenameRule{Find: "notes draft", Replace: "taken"})
+		assert.Equal(t, "already exists", plans[0].Conflict)
+		plans, _ = planRenames(paths[:1], RenameRule{Find: "IMG", Replace: "a/b"})
+		assert.Equal(t, "contains a separator", plans[0].Conflict)
+		assert.Error(t, applyRenames([]RenamePlan{{Path: paths[0], NewName: "x", Conflict: "already exists"}}))
+		assert.FileExists(t, paths[0])
+	})
+
+	t.Run("Shifting Numbers Within The Batch", func(t *testing.T) {
+		// IMG_002 is free once it was renamed to IMG_003
+		plans, err := planRenames(paths[:2], RenameRule{Template: "IMG_{n}", Start: 2, Digits: 3})
+		assert.NoError(t, err)
+		assert.Empty(t, plans[0].Conflict)
+		assert.NoError(t, applyRenames(plans))
+		content, _ := os.ReadFile(filepath.Join(dir, "IMG_003.JPG"))
+		assert.Equal(t, "IMG_002.JPG", string(content))
+		assert.NoFileExists(t, paths[0])
+	})
+
+	t.Run("Missing Files", func(t *testing.T) {
+		plans, _ := planRenames(paths[:1], RenameRule{Case: CaseLower})
+		assert.Equal(t, "not found", plans[0].Conflict)
+		assert.Error(t, applyRenames(plans))
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		current := []string{filepath.Join(dir, "IMG_002.JPG"), filepath.Join(dir, "IMG_003.JPG"), paths[2], paths[3]}
+		plans, _ := planRenames(current, RenameRule{Find: " ", Replace: "_", Case: CaseUpper})
+		assert.NoError(t, applyRenames(plans))
+		entries, _ := os.ReadDir(dir)
+		var listed []string
+		for _, entry := range entries {
+			listed = append(listed, entry.Name())
+		}
+		assert.Equal(t, []string{"IMG_002.JPG", "IMG_003.JPG", "NOTES_DRAFT.txt", "PHOTOS_2024", "taken.txt"}, listed)
+	})
+}
+
+// newTestRemote connects to an in-memory SFTP server and returns the remote path of
+// its root
+func newTestRemote(t *testing.T, conn string) string {
+	server, client := net.Pipe()
+	go sftp.NewRequestServer(server, sftp.InMemHandler()).Serve()
+	c, err := sftp.NewClientPipe(client, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addRemote(conn, c)
+	t.Cleanup(func() { c.Close() })
+	return remotePath(conn, "/")
+}
+
+func TestRemote(t *testing.T) {
+	root := newTestRemote(t, "test@memory:22")
+	local := t.TempDir()
+	os.MkdirAll(filepath.Join(local, "docs", "nested"), 0o755)
+	os.WriteFile(filepath.Join(local, "docs", "a.txt"), []byte("hello"), 0o644)
+	os.WriteFile(filepath.Join(local, "docs", "nested", "b.txt"), []byte("remote world"), 0o644)
+
+	t.Run("Paths", func(t *testing.T) {
+		conn, remote, ok := splitRemotePath("sftp://test@memory:22/home/user/")
+		assert.True(t, ok)
+		assert.Equal(t, "test@memory:22", conn)
+		assert.Equal(t, "/home/user", remote)
+		// filepath.Join leaves remote paths in the same form
+		assert.Equal(t, "sftp:/test@memory:22/home/user", filepath.Join(root, "home", "user"))
+		assert.Equal(t, root, parentDir(root))
+		assert.Equal(t, root, parentDir(remotePath(conn, "/home")))
+		assert.False(t, isRemote(local))
+		assert.Equal(t, []pathSegment{
+			{Name: "test@memory:22", Path: root},
+			{Name: "home", Path: root + "/home"},
+		}, pathSegments(root+"/home"))
+	})
+
+	t.Run("Copy To Server", func(t *testing.T) {
+		var done, total int64
+		err := transferAcross([]string{filepath.Join(local, "docs")}, root, false, func(n, of int64) { done, total = n, of })
+		assert.NoError(t, err)
+		assert.Equal(t, int64(17), total)
+		assert.Equal(t, total, done)
+
+		f, err := openPath(filepath.Join(root, "docs", "nested", "b.txt"))
+		assert.NoError(t, err)
+		content, _ := io.ReadAll(f)
+		f.Close()
+		assert.Equal(t, "remote world", string(content))
+		assert.Error(t, transferAcross([]string{filepath.Join(local, "docs")}, root, false, func(int64, int64) {}))
+	})
+
+	t.Run("Browse", func(t *testing.T) {
+		state := NewExplorerState(filepath.Join(root, "docs"))
+		wait(state)
+		names := []string{}
+		for _, entry := range state.Entries() {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"nested", "a.txt"}, names)
+		state.Up()
+		wait(state)
+		assert.Equal(t, root, state.Dir())
+
+		dir, err := resolveLocation("docs/nested", root)
+		assert.NoError(t, err)
+		assert.Equal(t, root+"/docs/nested", dir)
+		_, err = resolveLocation("missing", root)
+		assert.Error(t, err)
+	})
+
+	t.Run("Move Within The Server", func(t *testing.T) {
+		fsys, name, _ := fileSystem(root + "/archive")
+		assert.NoError(t, fsys.Mkdir(name, 0o755))
+		assert.NoError(t, transferAcross([]string{root + "/docs/a.txt"}, root+"/archive", true, func(int64, int64) {}))
+		_, err := statPath(root + "/docs/a.txt")
+		assert.Error(t, err)
+		info, err := statPath(root + "/archive/a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), info.Size())
+	})
+
+	t.Run("Move To Local", func(t *testing.T) {
+		dst := t.TempDir()
+		assert.NoError(t, transferAcross([]string{root + "/docs"}, dst, true, func(int64, int64) {}))
+		content, err := os.ReadFile(filepath.Join(dst, "docs", "nested", "b.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "remote world", string(content))
+		_, err = statPath(root + "/docs")
+		assert.Error(t, err)
+	})
+
+	t.Run("Not Connected", func(t *testing.T) {
+		_, err := statPath(remotePath("nobody@nowhere:22", "/"))
+		assert.ErrorContains(t, err, "not connected")
+	})
+}
@@ -0,0 +1,386 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// prefUsageExclude keeps the exclusion patterns of the disk usage view
+const prefUsageExclude = "usageExclude"
+
+// UsageNode is the space taken by a file or a directory tree. Children are ordered
+// largest first.
+type UsageNode struct {
+	Name     string
+	Path     string
+	Dir      bool
+	Size     int64
+	Files    int64
+	Children []*UsageNode
+	// Err is why the directory couldn't be read, its size misses the unread entries
+	Err error
+}
+
+// UsageScan adds up the sizes of the regular files below a directory, reading
+// subdirectories concurrently. Links aren't followed.
+type UsageScan struct {
+	// Exclude holds shell patterns such as "node_modules" or "*.iso", entries whose
+	// name matches one are skipped
+	Exclude []string
+
+	files   atomic.Int64
+	bytes   atomic.Int64
+	workers chan struct{}
+}
+
+// parseExcludes splits comma separated patterns, rejecting malformed ones
+func parseExcludes(text string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(text, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Progress returns the files and bytes counted so far, it may be called while the
+// scan runs
+func (s *UsageScan) Progress() (files, bytes int64) {
+	return s.files.Load(), s.bytes.Load()
+}
+
+// Run scans root. Unreadable directories don't stop the scan, they are reported in
+// their node. Cancelling ctx stops it and returns ctx.Err().
+func (s *UsageScan) Run(ctx context.Context, root string) (*UsageNode, error) {
+	s.workers = make(chan struct{}, 4*runtime.NumCPU())
+	node := &UsageNode{Name: filepath.Base(root), Path: root, Dir: true}
+	s.scan(ctx, node)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (s *UsageScan) scan(ctx context.Context, node *UsageNode) {
+	entries, err := os.ReadDir(node.Path)
+	node.Err = err
+
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		if s.excluded(entry.Name()) {
+			continue
+		}
+		child := &UsageNode{Name: entry.Name(), Path: filepath.Join(node.Path, entry.Name()), Dir: entry.IsDir()}
+		node.Children = append(node.Children, child)
+		if child.Dir {
+			// the subdirectory is read here when every worker is busy, waiting for
+			// one could deadlock as the workers wait for their own subdirectories
+			select {
+			case s.workers <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-s.workers }()
+					s.scan(ctx, child)
+				}()
+			default:
+				s.scan(ctx, child)
+			}
+			continue
+		}
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			child.Size, child.Files = info.Size(), 1
+			s.files.Add(1)
+			s.bytes.Add(child.Size)
+		}
+	}
+	wg.Wait()
+
+	for _, child := range node.Children {
+		node.Size += child.Size
+		node.Files += child.Files
+	}
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Name < b.Name
+	})
+}
+
+func (s *UsageScan) excluded(name string) bool {
+	for _, pattern := range s.Exclude {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// share is the part of the parent's size taken by size, between 0 and 1
+func share(size, parent int64) float64 {
+	if parent <= 0 {
+		return 0
+	}
+	return float64(size) / float64(parent)
+}
+
+// writeUsageCSV writes every node of the tree, parents before their children, with
+// its size in bytes and its share of the scanned directory
+func writeUsageCSV(w io.Writer, root *UsageNode) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Path", "Type", "Size", "Files", "Percent", "Error"})
+	var write func(node *UsageNode)
+	write = func(node *UsageNode) {
+		kind, problem := "file", ""
+		if node.Dir {
+			kind = "directory"
+		}
+		if node.Err != nil {
+			problem = node.Err.Error()
+		}
+		cw.Write([]string{
+			node.Path,
+			kind,
+			strconv.FormatInt(node.Size, 10),
+			strconv.FormatInt(node.Files, 10),
+			strconv.FormatFloat(100*share(node.Size, root.Size), 'f', 1, 64),
+			problem,
+		})
+		for _, child := range node.Children {
+			write(child)
+		}
+	}
+	write(root)
+	cw.Flush()
+	return cw.Error()
+}
+
+// usageView scans a directory in its own window and lists its space consumers as a
+// tree, largest first, with the share of their parent
+type usageView struct {
+	window  fyne.Window
+	prefs   fyne.Preferences
+	dir     string
+	exclude *widget.Entry
+	status  *widget.Label
+	tree    *widget.Tree
+	scan    *widget.Button
+	stop    *widget.Button
+	export  *widget.Button
+
+	mu     sync.Mutex
+	root   *UsageNode
+	nodes  map[string]*UsageNode
+	cancel context.CancelFunc
+}
+
+func showUsage(prefs fyne.Preferences, dir string) {
+	v := &usageView{
+		window: fyne.CurrentApp().NewWindow("Disk usage of " + filepath.Base(dir)),
+		prefs:  prefs,
+		dir:    dir,
+		nodes:  map[string]*UsageNode{},
+	}
+	v.exclude = widget.NewEntry()
+	v.exclude.SetPlaceHolder("Exclude, e.g. node_modules, *.iso")
+	v.exclude.SetText(prefs.String(prefUsageExclude))
+	v.status = widget.NewLabel("")
+	v.scan = widget.NewButton("Scan", v.start)
+	v.stop = widget.NewButton("Cancel", v.cancelScan)
+	v.stop.Disable()
+	v.export = widget.NewButton("Export CSV", v.exportCSV)
+	v.export.Disable()
+	v.tree = widget.NewTree(v.childIDs, v.isBranch, v.createRow, v.updateRow)
+
+	top := container.NewBorder(nil, nil, widget.NewLabel(dir), container.NewHBox(v.scan, v.stop, v.export), v.exclude)
+	v.window.SetContent(container.NewBorder(top, v.status, nil, nil, v.tree))
+	v.window.SetOnClosed(v.cancelScan)
+	v.window.Resize(fyne.NewSize(700, 500))
+	v.window.Show()
+	v.start()
+}
+
+// node returns the scanned node of a tree ID, the root for ""
+func (v *usageView) node(id widget.TreeNodeID) *UsageNode {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if id == "" {
+		return v.root
+	}
+	return v.nodes[id]
+}
+
+func (v *usageView) childIDs(id widget.TreeNodeID) []widget.TreeNodeID {
+	node := v.node(id)
+	if node == nil {
+		return nil
+	}
+	ids := make([]widget.TreeNodeID, len(node.Children))
+	for i, child := range node.Children {
+		ids[i] = child.Path
+	}
+	return ids
+}
+
+func (v *usageView) isBranch(id widget.TreeNodeID) bool {
+	node := v.node(id)
+	return node != nil && len(node.Children) > 0
+}
+
+// createRow lays out a row as the name, then the size and a bar showing the share
+// of the parent
+func (v *usageView) createRow(bool) fyne.CanvasObject {
+	bar := widget.NewProgressBar()
+	bars := container.NewGridWrap(fyne.NewSize(150, bar.MinSize().Height), bar)
+	return container.NewBorder(nil, nil, nil, container.NewHBox(widget.NewLabel(""), bars), widget.NewLabel(""))
+}
+
+func (v *usageView) updateRow(id widget.TreeNodeID, _ bool, row fyne.CanvasObject) {
+	node, parent := v.node(id), v.node(filepath.Dir(id))
+	if node == nil || parent == nil {
+		return
+	}
+
+	objects := row.(*fyne.Container).Objects
+	name, right := objects[0].(*widget.Label), objects[1].(*fyne.Container).Objects
+	size, bar := right[0].(*widget.Label), right[1].(*fyne.Container).Objects[0].(*widget.ProgressBar)
+
+	text := node.Name
+	if node.Err != nil {
+		text += " (incomplete)"
+	}
+	name.SetText(text)
+	size.SetText(formatSize(node.Size))
+	bar.SetValue(share(node.Size, parent.Size))
+}
+
+// start scans the directory with the exclusions of the entry, cancelling a running
+// scan
+func (v *usageView) start() {
+	patterns, err := parseExcludes(v.exclude.Text)
+	if err != nil {
+		dialog.ShowError(err, v.window)
+		return
+	}
+	v.prefs.SetString(prefUsageExclude, v.exclude.Text)
+	v.cancelScan()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	v.mu.Lock()
+	v.cancel = cancel
+	v.mu.Unlock()
+	v.stop.Enable()
+	v.export.Disable()
+
+	scan := &UsageScan{Exclude: patterns}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				files, bytes := scan.Progress()
+				v.status.SetText(fmt.Sprintf("Scanning... %d files, %s", files, formatSize(bytes)))
+			}
+		}
+	}()
+
+	go func() {
+		root, err := scan.Run(ctx, v.dir)
+		close(done)
+		cancel()
+		if err != nil {
+			v.status.SetText("Scan cancelled")
+			return
+		}
+		v.show(root)
+	}()
+}
+
+// cancelScan stops the running scan, if any
+func (v *usageView) cancelScan() {
+	v.mu.Lock()
+	cancel := v.cancel
+	v.cancel = nil
+	v.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	v.stop.Disable()
+}
+
+func (v *usageView) show(root *UsageNode) {
+	nodes := map[string]*UsageNode{}
+	var index func(node *UsageNode)
+	index = func(node *UsageNode) {
+		nodes[node.Path] = node
+		for _, child := range node.Children {
+			index(child)
+		}
+	}
+	index(root)
+
+	v.mu.Lock()
+	v.root, v.nodes = root, nodes
+	v.mu.Unlock()
+	v.tree.Refresh()
+	v.stop.Disable()
+	v.export.Enable()
+	v.status.SetText(fmt.Sprintf("%d files, %s", root.Files, formatSize(root.Size)))
+}
+
+func (v *usageView) exportCSV() {
+	v.mu.Lock()
+	root := v.root
+	v.mu.Unlock()
+	if root == nil {
+		return
+	}
+	save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+		if err != nil || w == nil {
+			return
+		}
+		defer w.Close()
+		if err := writeUsageCSV(w, root); err != nil {
+			dialog.ShowError(err, v.window)
+		}
+	}, v.window)
+	save.SetFileName(filepath.Base(v.dir) + "-usage.csv")
+	save.Show()
+}
@@ -0,0 +1,98 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortKey is the order entries are listed in, directories always come first
+type SortKey string
+
+const (
+	// SortByName lists entries alphabetically
+	SortByName SortKey = "Name"
+	// SortBySize lists the largest files first
+	SortBySize SortKey = "Size"
+	// SortByModified lists the most recently modified entries first
+	SortByModified SortKey = "Modified"
+	// SortByType groups files by extension
+	SortByType SortKey = "Type"
+)
+
+var sortKeys = []SortKey{SortByName, SortBySize, SortByModified, SortByType}
+
+// ViewOptions select which entries of a directory are shown and in which order
+type ViewOptions struct {
+	SortBy SortKey
+	// Filter keeps the entries containing it in their name, ignoring case
+	Filter     string
+	ShowHidden bool
+}
+
+// isHidden reports dot files, the convention for hidden files outside Windows
+func isHidden(entry fs.DirEntry) bool {
+	return strings.HasPrefix(entry.Name(), ".")
+}
+
+// apply returns the entries to show, entries itself isn't modified
+func (v ViewOptions) apply(entries []fs.DirEntry) []fs.DirEntry {
+	filter := strings.ToLower(v.Filter)
+	type row struct {
+		entry   fs.DirEntry
+		size    int64
+		modTime time.Time
+	}
+	rows := make([]row, 0, len(entries))
+	for _, entry := range entries {
+		if !v.ShowHidden && isHidden(entry) {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(entry.Name()), filter) {
+			continue
+		}
+		r := row{entry: entry}
+		// Info costs a stat per call, it is only needed for these orders
+		if v.SortBy == SortBySize || v.SortBy == SortByModified {
+			if info, err := entry.Info(); err == nil {
+				r.size, r.modTime = info.Size(), info.ModTime()
+			}
+		}
+		rows = append(rows, r)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.entry.IsDir() != b.entry.IsDir() {
+			return a.entry.IsDir()
+		}
+		switch v.SortBy {
+		case SortBySize:
+			if a.size != b.size {
+				return a.size > b.size
+			}
+		case SortByModified:
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.After(b.modTime)
+			}
+		case SortByType:
+			extA := strings.ToLower(filepath.Ext(a.entry.Name()))
+			extB := strings.ToLower(filepath.Ext(b.entry.Name()))
+			if extA != extB {
+				return extA < extB
+			}
+		}
+		return a.entry.Name() < b.entry.Name()
+	})
+
+	visible := make([]fs.DirEntry, len(rows))
+	for i, r := range rows {
+		visible[i] = r.entry
+	}
+	return visible
+}
@@ -0,0 +1,99 @@
+//go:build v1
+// +build v1
+
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the watcher waits for more changes before reloading, so
+// copying many files reloads once
+const watchDebounce = 300 * time.Millisecond
+
+// dirWatcher reloads a state when entries of its directory are created, removed or
+// renamed by other programs. It follows the state to the directories it visits.
+type dirWatcher struct {
+	state    *ExplorerState
+	watcher  *fsnotify.Watcher
+	debounce time.Duration
+
+	mu    sync.Mutex
+	dir   string
+	timer *time.Timer
+}
+
+func watchState(state *ExplorerState, debounce time.Duration) (*dirWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &dirWatcher{state: state, watcher: watcher, debounce: debounce}
+	w.follow(state.Dir())
+	state.OnVisit(w.follow)
+	go w.run()
+	return w, nil
+}
+
+// follow watches dir instead of the directory watched so far
+func (w *dirWatcher) follow(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dir == w.dir {
+		return
+	}
+	if w.dir != "" {
+		w.watcher.Remove(w.dir)
+	}
+	w.dir = dir
+	if inArchive(dir) || isRemote(dir) {
+		// archives and servers are read when entered, they aren't watched
+		return
+	}
+	if err := w.watcher.Add(dir); err != nil {
+		// the listing still works, it just doesn't update by itself
+		log.Println("Error watching directory:", err)
+	}
+}
+
+func (w *dirWatcher) run() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				w.changed()
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("Error watching directory:", err)
+		}
+	}
+}
+
+// changed schedules a reload, postponing one already scheduled
+func (w *dirWatcher) changed() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(w.debounce, w.state.Reload)
+}
+
+func (w *dirWatcher) Close() error {
+	w.mu.Lock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	return w.watcher.Close()
+}
@@ -0,0 +1,257 @@
+// Command xml2json-server serves the XML to JSON conversion over HTTP.
+//
+// Usage:
+//
+//	xml2json-server [flags]
+//
+// POST /convert takes an XML document as the request body and responds with its JSON
+// conversion. The options of xml2json are taken from query parameters or, when a
+// parameter is absent, from the matching request header:
+//
+//	force-list   X-Force-List   element paths always written as arrays (repeatable or comma separated)
+//	attr-prefix  X-Attr-Prefix  prefix of attribute keys
+//	infer-types  X-Infer-Types  convert numbers, booleans and empty elements to native JSON types
+//	select       X-Select       convert only the elements at this path, e.g. Envelope.Body.*
+//
+// The conversion is streamed: the JSON is written while the body is decoded, so
+// large payloads use bounded memory. Bodies larger than -max-bytes are rejected with
+// 413, malformed documents with 400 and a JSON error locating the problem. An error
+// found after part of the response was sent aborts the connection instead, so a
+// client never mistakes truncated output for a complete document.
+//
+// Exit codes: 0 after a graceful shutdown, 1 when the server fails, 2 on invalid usage.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"awesomeProject/task_243738/xmljson"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const (
+	defaultMaxBytes = 10 << 20
+	// responseBuffer is how much JSON is held back before the response is committed.
+	// Errors found within it are still reported with a proper status.
+	responseBuffer = 64 << 10
+	shutdownGrace  = 10 * time.Second
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("xml2json-server", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: xml2json-server [flags]")
+		fs.PrintDefaults()
+	}
+
+	addr := fs.String("addr", ":8080", "listen `address`")
+	maxBytes := fs.Int64("max-bytes", defaultMaxBytes, "largest accepted request body in `bytes`")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 || *maxBytes <= 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           newHandler(*maxBytes),
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          log.New(stderr, "xml2json-server: ", log.LstdFlags),
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errc:
+		fmt.Fprintln(stderr, "xml2json-server:", err)
+		return exitError
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintln(stderr, "xml2json-server:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func newHandler(maxBytes int64) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/convert", &converter{maxBytes: maxBytes})
+	return mux
+}
+
+type converter struct {
+	maxBytes int64
+}
+
+func (c *converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("use POST"))
+		return
+	}
+	if !isXML(r.Header.Get("Content-Type")) {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("request body must be XML"))
+		return
+	}
+	if r.ContentLength > c.maxBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", c.maxBytes))
+		return
+	}
+
+	opts, err := options(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// HTTP/1 stops reading the body once the response is under way unless asked not to,
+	// test recorders and HTTP/2 don't need it
+	http.NewResponseController(w).EnableFullDuplex()
+
+	w.Header().Set("Content-Type", "application/json")
+	out := &responseWriter{w: w}
+	buf := bufio.NewWriterSize(out, responseBuffer)
+	err = xmljson.ConvertStream(http.MaxBytesReader(w, r.Body, c.maxBytes), buf, opts)
+	if err == nil {
+		if _, err = buf.WriteString("\n"); err == nil {
+			err = buf.Flush()
+		}
+		if err != nil {
+			// The client went away, there is nobody left to tell
+			log.Printf("xml2json-server: writing response: %v", err)
+		}
+		return
+	}
+
+	if out.committed {
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Del("Content-Type")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, err)
+}
+
+// options reads the conversion options from the query, falling back to the headers
+func options(r *http.Request) (xmljson.Options, error) {
+	query := r.URL.Query()
+	param := func(name, header string) []string {
+		if values, ok := query[name]; ok {
+			return values
+		}
+		return r.Header.Values(header)
+	}
+
+	var opts xmljson.Options
+	for _, value := range param("force-list", "X-Force-List") {
+		for _, path := range strings.Split(value, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				opts.ForceList = append(opts.ForceList, path)
+			}
+		}
+	}
+	if prefix := param("attr-prefix", "X-Attr-Prefix"); len(prefix) > 0 {
+		opts.AttrPrefix = prefix[0]
+	}
+	if sel := param("select", "X-Select"); len(sel) > 0 {
+		opts.Select = sel[0]
+	}
+	if infer := param("infer-types", "X-Infer-Types"); len(infer) > 0 {
+		// A bare ?infer-types turns the option on
+		if infer[0] == "" {
+			opts.InferTypes = true
+		} else {
+			v, err := strconv.ParseBool(infer[0])
+			if err != nil {
+				return opts, fmt.Errorf("invalid infer-types %q", infer[0])
+			}
+			opts.InferTypes = v
+		}
+	}
+	return opts, nil
+}
+
+// isXML accepts XML media types and a missing Content-Type
+func isXML(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// responseWriter records whether any output reached the client, after which the
+// status can no longer be changed
+type responseWriter struct {
+	w         http.ResponseWriter
+	committed bool
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.committed = true
+	return rw.w.Write(p)
+}
+
+// errorResponse is the body of failed requests, the position is set for malformed XML
+type errorResponse struct {
+	Error  string `json:"error"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	resp := errorResponse{Error: err.Error()}
+	var xmlErr *xmljson.Error
+	if errors.As(err, &xmlErr) {
+		resp.Line, resp.Column, resp.Path = xmlErr.Line, xmlErr.Column, xmlErr.Path
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestConvertHandler(t *testing.T) {
+	handler := newHandler(1 << 20)
+	post := func(target, contentType string, body io.Reader, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, body)
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Converts the body", func(t *testing.T) {
+			w := post("/convert", "application/xml", strings.NewReader(`<root><item>a</item></root>`), nil)
+			if w.Code != http.StatusOK || w.Body.String() != "{\"root\":{\"item\":\"a\"}}\n" {
+				t.Errorf("Unexpected response %d: %q", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected JSON, got %q", ct)
+			}
+		}},
+		{"Options from query parameters", func(t *testing.T) {
+			w := post("/convert?force-list=root.item&attr-prefix=_&infer-types", "text/xml; charset=utf-8",
+				strings.NewReader(`<root><item id="1">a</item></root>`), nil)
+			if w.Code != http.StatusOK || w.Body.String() != "{\"root\":{\"item\":[{\"_id\":1,\"#text\":\"a\"}]}}\n" {
+				t.Errorf("Unexpected response %d: %q", w.Code, w.Body.String())
+			}
+		}},
+		{"Options from headers, query wins", func(t *testing.T) {
+			header := http.Header{
+				"X-Force-List":  {"wrapper.root.item"},
+				"X-Attr-Prefix": {"_"},
+				"X-Infer-Types": {"true"},
+				"X-Select":      {"wrapper.root"},
+			}
+			w := post("/convert?infer-types=false", "", strings.NewReader(`<wrapper><root><item id="1">a</item></root></wrapper>`), header)
+			if w.Code != http.StatusOK || w.Body.String() != "{\"root\":{\"item\":[{\"_id\":\"1\",\"#text\":\"a\"}]}}\n" {
+				t.Errorf("Unexpected response %d: %q", w.Code, w.Body.String())
+			}
+		}},
+		{"Malformed XML is located", func(t *testing.T) {
+			w := post("/convert", "application/xml", strings.NewReader("<root>\n<item></root>"), nil)
+			var resp errorResponse
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if w.Code != http.StatusBadRequest || resp.Line != 2 || resp.Path != "root.item" {
+				t.Errorf("Expected a located 400, got %d: %s", w.Code, w.Body.String())
+			}
+		}},
+		{"Invalid requests are rejected", func(t *testing.T) {
+			if w := post("/convert", "application/json", strings.NewReader(`{}`), nil); w.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("Expected 415, got %d", w.Code)
+			}
+			if w := post("/convert?infer-types=maybe", "", strings.NewReader(`<a/>`), nil); w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", w.Code)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/convert", nil))
+			if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("Expected 405, got %d", w.Code)
+			}
+		}},
+		{"Oversized bodies are rejected", func(t *testing.T) {
+			body := "<root>" + strings.Repeat("<item>a</item>", 1<<17) + "</root>"
+			if w := post("/convert", "", strings.NewReader(body), nil); w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected 413 from Content-Length, got %d", w.Code)
+			}
+			// Without a Content-Length the limit is found while reading, before any output is sent
+			w := httptest.NewRecorder()
+			newHandler(1024).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/convert", io.MultiReader(strings.NewReader(body))))
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("Expected 413 while streaming, got %d: %.100s", w.Code, w.Body.String())
+			}
+		}},
+		{"Large payloads are streamed", func(t *testing.T) {
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			var body bytes.Buffer
+			body.WriteString("<root>")
+			for i := 0; i < 20000; i++ {
+				fmt.Fprintf(&body, "<item>%d</item>", i)
+			}
+			body.WriteString("</root>")
+
+			resp, err := http.Post(ts.URL+"/convert?infer-types=1", "application/xml", &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var result struct {
+				Root struct{ Item []int }
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil || len(result.Root.Item) != 20000 {
+				t.Errorf("Expected 20000 items, got %d: %v", len(result.Root.Item), err)
+			}
+		}},
+		{"Late errors abort the response", func(t *testing.T) {
+			ts := httptest.NewServer(handler)
+			defer ts.Close()
+
+			body := "<root>" + strings.Repeat("<item>a</item>", 30000) + "<broken></root>"
+			resp, err := http.Post(ts.URL+"/convert", "application/xml", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if _, err := io.ReadAll(resp.Body); resp.StatusCode != http.StatusOK || err == nil {
+				t.Errorf("Expected the committed response to be cut off, got %d: %v", resp.StatusCode, err)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
+
+func TestRun(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := run(context.Background(), []string{"-max-bytes", "0"}, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+	if code := run(context.Background(), []string{"extra"}, &stderr); code != exitUsage {
+		t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if code := run(ctx, []string{"-addr", "127.0.0.1:0"}, &stderr); code != exitOK {
+		t.Errorf("Expected a graceful shutdown, got %d: %s", code, stderr.String())
+	}
+}
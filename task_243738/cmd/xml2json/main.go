@@ -0,0 +1,151 @@
+// Command xml2json converts an XML document to JSON.
+//
+// Usage:
+//
+//	xml2json [flags] [input.xml]
+//
+// The document is read from the named file or from stdin and written to stdout
+// unless -o is given. Without -indent and -canonical the conversion is streamed, so
+// large files are converted with bounded memory.
+//
+// Exit codes: 0 on success, 1 when the input cannot be read or converted,
+// 2 on invalid usage.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"awesomeProject/task_243738/xmljson"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// stringList is a flag that can be repeated or given a comma separated list
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("xml2json", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: xml2json [flags] [input.xml]")
+		fs.PrintDefaults()
+	}
+
+	var opts xmljson.Options
+	output := fs.String("o", "", "write JSON to `file` instead of stdout")
+	indent := fs.Int("indent", 0, "pretty-print with `n` spaces per level")
+	fs.Var((*stringList)(&opts.ForceList), "force-list", "element `paths` always written as arrays, e.g. root.item (repeatable or comma separated)")
+	fs.StringVar(&opts.AttrPrefix, "attr-prefix", xmljson.DefaultAttrPrefix, "`prefix` of attribute keys")
+	fs.BoolVar(&opts.InferTypes, "infer-types", false, "convert numbers, booleans and empty elements to native JSON types")
+	fs.StringVar(&opts.ContentKey, "content-key", "", "write mixed text and elements in order under `key`, e.g. #content")
+	fs.StringVar(&opts.CDataKey, "cdata-key", "", "keep CDATA sections verbatim under `key`, e.g. #cdata")
+	fs.BoolVar(&opts.Canonical, "canonical", false, "sort keys and normalize whitespace for byte-stable output")
+	fs.StringVar(&opts.Select, "select", "", "convert only the elements at `path`, e.g. Envelope.Body.*")
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 1 || *indent < 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	in := stdin
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(stderr, "xml2json:", err)
+			return exitError
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var err error
+	if *output == "" {
+		err = convert(in, stdout, opts, *indent)
+	} else {
+		err = convertToFile(in, *output, opts, *indent)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "xml2json:", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// convertToFile removes the file again when the conversion fails half way
+func convertToFile(in io.Reader, path string, opts xmljson.Options, indent int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = convert(in, f, opts, indent)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+func convert(in io.Reader, out io.Writer, opts xmljson.Options, indent int) error {
+	if indent == 0 && !opts.Canonical {
+		if err := xmljson.ConvertStream(in, out, opts); err != nil {
+			return err
+		}
+		_, err := io.WriteString(out, "\n")
+		return err
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	result, err := xmljson.Convert(data, opts)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if indent == 0 {
+		buf.Write(result)
+	} else if err := json.Indent(&buf, result, "", strings.Repeat(" ", indent)); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(out)
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "in.xml")
+	os.WriteFile(inputFile, []byte(`<root><item id="1">a</item></root>`), 0o644)
+
+	var testCases = []struct {
+		name     string
+		testFunc func(*testing.T)
+	}{
+		{"Stdin to stdout", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(nil, strings.NewReader(`<root><item>a</item></root>`), &stdout, &stderr)
+			if code != exitOK || stdout.String() != "{\"root\":{\"item\":\"a\"}}\n" {
+				t.Errorf("Unexpected result %d: %q %s", code, stdout.String(), stderr.String())
+			}
+		}},
+		{"File input with flags", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			args := []string{"-force-list", "root.item", "-attr-prefix", "_", "-infer-types", inputFile}
+			code := run(args, nil, &stdout, &stderr)
+			if code != exitOK || stdout.String() != "{\"root\":{\"item\":[{\"_id\":1,\"#text\":\"a\"}]}}\n" {
+				t.Errorf("Unexpected result %d: %q %s", code, stdout.String(), stderr.String())
+			}
+		}},
+		{"Selected sub-tree", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			input := `<Envelope><Header/><Body><Result>ok</Result></Body></Envelope>`
+			code := run([]string{"-select", "Envelope.Body.*"}, strings.NewReader(input), &stdout, &stderr)
+			if code != exitOK || stdout.String() != "{\"Result\":\"ok\"}\n" {
+				t.Errorf("Unexpected result %d: %q %s", code, stdout.String(), stderr.String())
+			}
+		}},
+		{"Canonical output", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"-canonical"}, strings.NewReader(`<root b="1" a="2"> x  y </root>`), &stdout, &stderr)
+			if code != exitOK || stdout.String() != "{\"root\":{\"#text\":\"x y\",\"@a\":\"2\",\"@b\":\"1\"}}\n" {
+				t.Errorf("Unexpected result %d: %q %s", code, stdout.String(), stderr.String())
+			}
+		}},
+		{"Mixed content and CDATA keys", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			input := `<doc><p>a <b>b</b></p><code><![CDATA[ x<y ]]></code></doc>`
+			code := run([]string{"-content-key", "#content", "-cdata-key", "#cdata"}, strings.NewReader(input), &stdout, &stderr)
+			if code != exitOK || stdout.String() != "{\"doc\":{\"p\":{\"#content\":[\"a \",{\"b\":\"b\"}]},\"code\":{\"#cdata\":\" x<y \"}}}\n" {
+				t.Errorf("Unexpected result %d: %q %s", code, stdout.String(), stderr.String())
+			}
+		}},
+		{"Indented output to file", func(t *testing.T) {
+			outputFile := filepath.Join(dir, "out.json")
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"-indent", "2", "-o", outputFile, inputFile}, nil, &stdout, &stderr)
+			if code != exitOK {
+				t.Fatalf("Expected exit code %d, got %d: %s", exitOK, code, stderr.String())
+			}
+			data, _ := os.ReadFile(outputFile)
+			expected := "{\n  \"root\": {\n    \"item\": {\n      \"@id\": \"1\",\n      \"#text\": \"a\"\n    }\n  }\n}\n"
+			if string(data) != expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", expected, data)
+			}
+		}},
+		{"Parse error exits with 1 and removes output", func(t *testing.T) {
+			outputFile := filepath.Join(dir, "broken.json")
+			var stdout, stderr bytes.Buffer
+			code := run([]string{"-o", outputFile}, strings.NewReader(`<root><item>`), &stdout, &stderr)
+			if code != exitError || stderr.Len() == 0 {
+				t.Errorf("Expected exit code %d with a message, got %d", exitError, code)
+			}
+			if _, err := os.Stat(outputFile); !os.IsNotExist(err) {
+				t.Errorf("Expected partial output to be removed")
+			}
+		}},
+		{"Missing input file exits with 1", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{filepath.Join(dir, "missing.xml")}, nil, &stdout, &stderr); code != exitError {
+				t.Errorf("Expected exit code %d, got %d", exitError, code)
+			}
+		}},
+		{"Invalid usage exits with 2", func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"-unknown"}, nil, &stdout, &stderr); code != exitUsage {
+				t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+			}
+			if code := run([]string{"a.xml", "b.xml"}, nil, &stdout, &stderr); code != exitUsage {
+				t.Errorf("Expected exit code %d, got %d", exitUsage, code)
+			}
+		}},
+	}
+
+	passedTests := 0
+	totalTests := len(testCases)
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.testFunc(t)
+			result := "PASS"
+			if t.Failed() {
+				result = "FAIL"
+			} else {
+				passedTests++
+			}
+			fmt.Printf("Test Case %02d# %s - %s\n", i+1, tc.name, result)
+		})
+	}
+
+	fmt.Printf("\nPassed %d out of %d tests\n", passedTests, totalTests)
+}
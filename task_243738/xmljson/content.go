@@ -0,0 +1,118 @@
+package xmljson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// cdataSection is a CDATA section in the content of an element, kept apart from
+// the surrounding text when Options.CDataKey is set
+type cdataSection string
+
+// rawSource returns the input the decoder read between two of its offsets.
+// encoding/xml reports CDATA sections as plain character data, the raw input
+// tells them apart.
+type rawSource interface {
+	raw(from, to int64) []byte
+}
+
+// dataSource is a document held in memory
+type dataSource []byte
+
+func (d dataSource) raw(from, to int64) []byte {
+	return d[from:to]
+}
+
+// recorder keeps the input of the token being decoded while streaming. The decoder
+// uses it as its io.ByteReader, so offsets match, and what was read beyond the token
+// is kept for the next one.
+type recorder struct {
+	r    *bufio.Reader
+	buf  []byte
+	base int64
+}
+
+func newRecorder(r io.Reader) *recorder {
+	return &recorder{r: bufio.NewReader(r)}
+}
+
+func (r *recorder) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	return b, err
+}
+
+func (r *recorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// raw returns the input between from and to, which is valid until the next call.
+// Everything before from, the previous tokens, is forgotten.
+func (r *recorder) raw(from, to int64) []byte {
+	r.buf = append(r.buf[:0], r.buf[from-r.base:]...)
+	r.base = from
+	return r.buf[:to-from]
+}
+
+func isCDATA(tok xml.Token, raw []byte) bool {
+	_, ok := tok.(xml.CharData)
+	return ok && bytes.HasPrefix(raw, []byte("<![CDATA["))
+}
+
+// isMixed reports whether an element has text next to child elements and its
+// content is written in order under Options.ContentKey
+func (c *converter) isMixed(el *element) bool {
+	if c.opts.ContentKey == "" || len(el.children) == 0 {
+		return false
+	}
+	return strings.TrimSpace(el.text.String()) != "" || el.cdata.Len() > 0
+}
+
+// content converts the content of a mixed element to an array of text strings and
+// single member objects for child elements and CDATA sections, in document order.
+// Whitespace only text between them is dropped as indentation. Options.ForceList
+// does not apply to the children, each is a member of its own object anyway.
+func (c *converter) content(el *element, path string) []interface{} {
+	var items []interface{}
+	var text strings.Builder
+	flush := func() {
+		s := text.String()
+		text.Reset()
+		if strings.TrimSpace(s) == "" {
+			return
+		}
+		if c.opts.Canonical {
+			s = whitespace.ReplaceAllString(s, " ")
+		}
+		items = append(items, s)
+	}
+
+	for _, node := range el.content {
+		switch n := node.(type) {
+		case string:
+			text.WriteString(n)
+		case cdataSection:
+			flush()
+			obj := newObject()
+			obj.set(c.opts.CDataKey, string(n))
+			items = append(items, obj)
+		case *element:
+			flush()
+			obj := newObject()
+			obj.set(n.name, c.value(n, path+"."+n.name))
+			items = append(items, obj)
+		}
+	}
+	flush()
+	return items
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
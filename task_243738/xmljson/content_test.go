@@ -0,0 +1,117 @@
+package xmljson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMixedContentAndCDATA(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+		// streamErr is set for documents ConvertStream rejects
+		streamErr bool
+	}{
+		{
+			name:     "Mixed Content Is Joined By Default",
+			input:    `<doc><p>Hello <b>big</b> world</p></doc>`,
+			expected: `{"doc":{"p":{"b":"big","#text":"Hello  world"}}}`,
+		},
+		{
+			name:     "Mixed Content In Order",
+			input:    `<doc><p class="x">Hello <b>big</b> <i>wide</i> world</p></doc>`,
+			opts:     Options{ContentKey: "#content"},
+			expected: `{"doc":{"p":{"@class":"x","#content":["Hello ",{"b":"big"},{"i":"wide"}," world"]}}}`,
+		},
+		{
+			name: "Elements Without Text Are Not Mixed",
+			input: `<doc>
+                <p><b>only</b></p>
+            </doc>`,
+			opts:     Options{ContentKey: "#content"},
+			expected: `{"doc":{"p":{"b":"only"}}}`,
+		},
+		{
+			name:     "Repeated Children Keep Their Place",
+			input:    `<doc><p>a<br/>b<br/>c</p></doc>`,
+			opts:     Options{ContentKey: "#content", ForceList: []string{"doc.p.br"}},
+			expected: `{"doc":{"p":{"#content":["a",{"br":""},"b",{"br":""},"c"]}}}`,
+		},
+		{
+			name:     "CDATA Is Merged By Default",
+			input:    `<doc><code><![CDATA[ a < b ]]></code></doc>`,
+			expected: `{"doc":{"code":"a < b"}}`,
+		},
+		{
+			name: "CDATA Kept Verbatim",
+			input: `<doc><code lang="go"><![CDATA[  if a < b {
+}
+]]></code><note>x</note></doc>`,
+			opts:     Options{CDataKey: "#cdata"},
+			expected: `{"doc":{"code":{"@lang":"go","#cdata":"  if a < b {\n}\n"},"note":"x"}}`,
+		},
+		{
+			name:     "CDATA Next To Text",
+			input:    `<doc>see <![CDATA[<raw>]]></doc>`,
+			opts:     Options{CDataKey: "#cdata"},
+			expected: `{"doc":{"#text":"see","#cdata":"<raw>"}}`,
+		},
+		{
+			name:     "CDATA In Mixed Content",
+			input:    `<doc><p>run <![CDATA[a && b]]> then <b>stop</b></p></doc>`,
+			opts:     Options{ContentKey: "#content", CDataKey: "#cdata"},
+			expected: `{"doc":{"p":{"#content":["run ",{"#cdata":"a && b"}," then ",{"b":"stop"}]}}}`,
+		},
+		{
+			name:      "Canonical Mixed Content",
+			input:     `<doc><p z="1">Hello   <b>big</b>  world </p></doc>`,
+			opts:      Options{ContentKey: "#content", Canonical: true},
+			expected:  `{"doc":{"p":{"#content":["Hello ",{"b":"big"}," world "],"@z":"1"}}}`,
+			streamErr: true,
+		},
+		{
+			name:      "Mixed Streamed Element",
+			input:     `<doc>intro<p>x</p></doc>`,
+			opts:      Options{ContentKey: "#content"},
+			expected:  `{"doc":{"#content":["intro",{"p":"x"}]}}`,
+			streamErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert([]byte(tt.input), tt.opts)
+			if err != nil || string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s (%v)", tt.expected, result, err)
+			}
+
+			var out bytes.Buffer
+			err = ConvertStream(strings.NewReader(tt.input), &out, tt.opts)
+			if tt.streamErr != (err != nil) || (!tt.streamErr && out.String() != tt.expected) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("ConvertStream disagrees: %s (%v)", out.String(), err)
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+func TestCDATAInLenientMode(t *testing.T) {
+	input := `<doc><code><![CDATA[ x ]]></code><broken></doc>`
+	result, warnings, err := ConvertWithWarnings([]byte(input), Options{Lenient: true, CDataKey: "#cdata"})
+	if err != nil || len(warnings) == 0 {
+		t.Fatalf("Expected a repaired document with warnings, got %v %v", warnings, err)
+	}
+	if expected := `{"doc":{"code":{"#cdata":" x "},"broken":""}}`; string(result) != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, result)
+	}
+}
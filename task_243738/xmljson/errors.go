@@ -0,0 +1,257 @@
+package xmljson
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Error locates a problem in the XML input. It is returned for malformed documents
+// and reported as a warning for every repair made in lenient mode.
+type Error struct {
+	Line   int
+	Column int
+	// Path is the dot separated path of the element being decoded, e.g. "root.item"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	msg := e.Err.Error()
+	if se, ok := e.Err.(*xml.SyntaxError); ok {
+		msg = se.Msg
+	}
+	msg = strings.TrimPrefix(msg, "xmljson: ")
+
+	if e.Path == "" {
+		return fmt.Sprintf("xmljson: line %d, column %d: %s", e.Line, e.Column, msg)
+	}
+	return fmt.Sprintf("xmljson: line %d, column %d, in %s: %s", e.Line, e.Column, e.Path, msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorf wraps err with the current position and element path
+func (p *parser) errorf(err error) *Error {
+	e := &Error{Path: strings.Join(p.names, "."), Err: err}
+	if p.rec != nil {
+		e.Line, e.Column = p.rec.position(p.rec.absolute(p.dec.InputOffset()))
+	} else {
+		e.Line, e.Column = p.dec.InputPos()
+	}
+	return e
+}
+
+func (p *parser) warn(err error) {
+	p.rec.warnings = append(p.rec.warnings, p.errorf(err))
+}
+
+func (p *parser) warnings() []*Error {
+	if p.rec == nil {
+		return nil
+	}
+	return p.rec.warnings
+}
+
+// recovery holds the state to repair a document in lenient mode. encoding/xml stops
+// at the first syntax error, so decoding restarts at the next tag with a decoder whose
+// input is prefixed with the start tags of the open elements, keeping it balanced.
+type recovery struct {
+	data []byte
+	// base is the offset in data where the current decoder input starts, after
+	// the prefix of synthetic start tags
+	base   int
+	prefix int
+	// skip counts the synthetic start elements still to be swallowed
+	skip  int
+	opens []xml.StartElement
+	// selfClosing is set after a <x/> start tag, whose end tag has no input
+	selfClosing bool
+	// closedBy is the name of an end tag that closed elements it did not match,
+	// until the decoder delivers the element it belongs to
+	closedBy string
+	done     bool
+	warnings []*Error
+}
+
+// recoverFrom switches the parser to lenient mode for the document data
+func (p *parser) recoverFrom(data []byte) {
+	p.dec.Strict = false
+	p.rec = &recovery{data: data}
+}
+
+// token returns the next token, wrapping errors with their position. In lenient
+// mode malformed input is reported as warnings and skipped.
+func (p *parser) token() (xml.Token, error) {
+	if p.rec == nil {
+		start := p.dec.InputOffset()
+		tok, err := p.dec.Token()
+		if err != nil && err != io.EOF {
+			return nil, p.errorf(err)
+		}
+		if p.source != nil {
+			p.inCDATA = isCDATA(tok, p.source.raw(start, p.dec.InputOffset()))
+		}
+		return tok, err
+	}
+
+	r := p.rec
+	for {
+		if r.done {
+			if len(p.names) == 0 {
+				return nil, io.EOF
+			}
+			p.warn(fmt.Errorf("element <%s> is not closed", p.names[len(p.names)-1]))
+			return xml.EndElement{Name: p.rec.opens[len(p.rec.opens)-1].Name}, nil
+		}
+
+		start := r.absolute(p.dec.InputOffset())
+		tok, err := p.dec.Token()
+		if err == io.EOF {
+			r.done = true
+			continue
+		}
+		if se, ok := err.(*xml.SyntaxError); ok && se.Msg == "unexpected EOF" {
+			// reported per element when closing them
+			r.done = true
+			continue
+		}
+		if err != nil {
+			p.warn(err)
+			p.resync()
+			continue
+		}
+		raw := r.data[start:r.absolute(p.dec.InputOffset())]
+		p.inCDATA = p.source != nil && isCDATA(tok, raw)
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if r.skip > 0 {
+				r.skip--
+				continue
+			}
+			r.selfClosing = bytes.HasSuffix(raw, []byte("/>"))
+		case xml.EndElement:
+			if !p.checkEnd(t, raw) {
+				p.resync()
+				continue
+			}
+			r.selfClosing = false
+		}
+		return tok, nil
+	}
+}
+
+// checkEnd warns about end tags the non-strict decoder invented for unclosed elements.
+// It reports false for a stray end tag matching no open element, which must be skipped
+// before the decoder closes elements for it.
+func (p *parser) checkEnd(end xml.EndElement, raw []byte) bool {
+	r := p.rec
+	if len(raw) == 0 {
+		switch {
+		case r.selfClosing:
+		case r.closedBy == end.Name.Local:
+			r.closedBy = ""
+		default:
+			p.warn(fmt.Errorf("element <%s> is not closed", end.Name.Local))
+		}
+		return true
+	}
+
+	name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(string(raw), "</"), ">"))
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == end.Name.Local {
+		return true
+	}
+
+	for _, open := range r.opens {
+		if open.Name.Local == name {
+			r.closedBy = name
+			p.warn(fmt.Errorf("element <%s> closed by </%s>", end.Name.Local, name))
+			return true
+		}
+	}
+	p.warn(fmt.Errorf("unexpected end tag </%s>", name))
+	return false
+}
+
+// resync restarts decoding at the next tag after the malformed input
+func (p *parser) resync() {
+	r := p.rec
+	// the decoder consumes at least one byte before failing, this only guards
+	// against restarting on the same input forever
+	from := r.absolute(p.dec.InputOffset())
+	if from <= r.base && r.base < len(r.data) {
+		from = r.base + 1
+	}
+	next := bytes.IndexByte(r.data[from:], '<')
+	if next < 0 {
+		r.done = true
+		return
+	}
+	resume := from + next
+
+	var prefix bytes.Buffer
+	for _, start := range r.opens {
+		prefix.WriteString("<" + p.rawName(start.Name))
+		for _, attr := range start.Attr {
+			switch {
+			case attr.Name.Space == "xmlns":
+				prefix.WriteString(" xmlns:" + attr.Name.Local + `="`)
+			case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				prefix.WriteString(` xmlns="`)
+			default:
+				continue
+			}
+			xml.EscapeText(&prefix, []byte(attr.Value))
+			prefix.WriteString(`"`)
+		}
+		prefix.WriteString(">")
+	}
+
+	p.dec = xml.NewDecoder(io.MultiReader(bytes.NewReader(prefix.Bytes()), bytes.NewReader(r.data[resume:])))
+	p.dec.Strict = false
+	r.base, r.prefix, r.skip = resume, prefix.Len(), len(r.opens)
+	r.selfClosing, r.closedBy = false, ""
+}
+
+// rawName turns a resolved name back into the prefixed form of the document
+func (p *parser) rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	prefix, ok := p.ns.prefix(name.Space)
+	if !ok {
+		prefix = name.Space
+	}
+	if prefix == "" {
+		return name.Local
+	}
+	return prefix + ":" + name.Local
+}
+
+// absolute converts an offset of the current decoder to an offset in data
+func (r *recovery) absolute(offset int64) int {
+	abs := r.base + int(offset) - r.prefix
+	if abs < r.base {
+		return r.base
+	}
+	if abs > len(r.data) {
+		return len(r.data)
+	}
+	return abs
+}
+
+// position returns the 1-based line and column of an offset in data
+func (r *recovery) position(offset int) (line, column int) {
+	before := r.data[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
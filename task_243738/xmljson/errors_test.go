@@ -0,0 +1,138 @@
+package xmljson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestErrorPositions(t *testing.T) {
+	input := "<root>\n  <item>\n    <name>test</root>"
+
+	_, err := Convert([]byte(input), Options{})
+	var xmlErr *Error
+	if !errors.As(err, &xmlErr) {
+		t.Fatalf("Expected *Error, got %T: %v", err, err)
+	}
+	if xmlErr.Line != 3 || xmlErr.Column != 22 || xmlErr.Path != "root.item.name" {
+		t.Errorf("Unexpected position: line %d, column %d, path %q", xmlErr.Line, xmlErr.Column, xmlErr.Path)
+	}
+	expected := "xmljson: line 3, column 22, in root.item.name: element <name> closed by </root>"
+	if err.Error() != expected {
+		t.Errorf("Expected message %q, got %q", expected, err.Error())
+	}
+
+	_, err = Convert([]byte(`<a><b><c/></b></a>`), Options{MaxDepth: 2})
+	if !errors.Is(err, ErrMaxDepth) || !errors.As(err, &xmlErr) || xmlErr.Path != "a.b" {
+		t.Errorf("Expected ErrMaxDepth in a.b, got %v", err)
+	}
+
+	err = ConvertStream(strings.NewReader("<root>\n<a>1</a>\n<b>2</b>\n<a>3</a></root>"), &bytes.Buffer{}, Options{})
+	if !errors.As(err, &xmlErr) || xmlErr.Line != 4 || xmlErr.Path != "root.a" {
+		t.Errorf("Expected streaming error on line 4 in root.a, got %v", err)
+	}
+}
+
+func TestLenientMode(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		warnings []string
+	}{
+		{
+			name:     "Well Formed Input Has No Warnings",
+			input:    `<root><a>1</a><b/></root>`,
+			expected: `{"root":{"a":"1","b":""}}`,
+		},
+		{
+			name:     "Mismatched End Tag",
+			input:    `<root><item><name>test</root>`,
+			expected: `{"root":{"item":{"name":"test"}}}`,
+			warnings: []string{
+				"line 1, column 30, in root.item.name: element <name> closed by </root>",
+				"line 1, column 30, in root.item: element <item> is not closed",
+			},
+		},
+		{
+			name:     "Unclosed Elements At End Of Input",
+			input:    "<root>\n<a>1</a>\n<b>2",
+			expected: `{"root":{"a":"1","b":"2"}}`,
+			warnings: []string{
+				"line 3, column 5, in root.b: element <b> is not closed",
+				"line 3, column 5, in root: element <root> is not closed",
+			},
+		},
+		{
+			name:     "Malformed Node Is Skipped",
+			input:    `<root><a>1</a><b <c>x</c><d>2</d></root>`,
+			expected: `{"root":{"a":"1","c":"x","d":"2"}}`,
+			warnings: []string{
+				"line 1, column 18, in root: expected attribute name in element",
+			},
+		},
+		{
+			name:     "Stray End Tag Is Skipped",
+			input:    `<root><a>1</a></x><b>2</b></root>`,
+			expected: `{"root":{"a":"1","b":"2"}}`,
+			warnings: []string{
+				"line 1, column 19, in root: unexpected end tag </x>",
+			},
+		},
+		{
+			name:     "Namespaces Survive Recovery",
+			input:    `<s:Envelope xmlns:s="urn:soap"><s:Body><1bad/><s:Item>1</s:Item></s:Body></s:Envelope>`,
+			expected: `{"s:Envelope":{"@xmlns:s":"urn:soap","s:Body":{"s:Item":"1"}}}`,
+			warnings: []string{
+				"line 1, column 45, in s:Envelope.s:Body: invalid XML name: 1bad",
+			},
+		},
+		{
+			name:     "Unknown Entities Are Kept",
+			input:    `<root><a>fish &amp; chips &nbsp;</a></root>`,
+			expected: `{"root":{"a":"fish & chips &nbsp;"}}`,
+		},
+		{
+			name:     "Extra Root Is Skipped",
+			input:    `<a>1</a><b>2</b>`,
+			expected: `{"a":"1"}`,
+			warnings: []string{
+				"line 1, column 12: document has more than one root element",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Lenient: true}
+			if strings.Contains(tt.input, "xmlns") {
+				opts.Namespaces = NamespaceKeep
+			}
+			result, warnings, err := ConvertWithWarnings([]byte(tt.input), opts)
+			if err != nil || string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s (%v)", tt.expected, string(result), err)
+			}
+
+			var got []string
+			for _, w := range warnings {
+				got = append(got, strings.TrimPrefix(w.Error(), "xmljson: "))
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.warnings, "\n") {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected warnings:\n%s\nGot:\n%s", strings.Join(tt.warnings, "\n"), strings.Join(got, "\n"))
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+
+	if err := ConvertStream(strings.NewReader("<a/>"), &bytes.Buffer{}, Options{Lenient: true}); err == nil {
+		t.Errorf("Expected streaming to reject lenient mode")
+	}
+}
@@ -0,0 +1,219 @@
+package xmljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// JSONToXML is the reverse of Convert. The JSON must be an object with a single key
+// naming the root element. Keys starting with Options.AttrPrefix, or the members of
+// Options.AttributesKey when set, become attributes, including namespace declarations
+// such as "@xmlns:soap" written by NamespaceKeep. Options.TextKey becomes
+// character data and arrays become repeated elements, so a single item array written
+// by ForceList turns back into one element. Key order is kept.
+func JSONToXML(data []byte, opts Options) ([]byte, error) {
+	opts = opts.withDefaults()
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	doc, err := decodeJSON(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("xmljson: unexpected data after the JSON document")
+	}
+
+	root, ok := doc.(*object)
+	if !ok || len(root.keys) != 1 {
+		return nil, errors.New("xmljson: JSON document must be an object with a single root key")
+	}
+
+	w := &xmlWriter{opts: opts}
+	name := root.keys[0]
+	if _, isList := root.values[name].([]interface{}); isList {
+		return nil, errors.New("xmljson: the root element cannot be an array")
+	}
+	if err := w.element(name, root.values[name]); err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// decodeJSON reads the next value keeping the key order of objects
+func decodeJSON(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := newObject()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(keyTok.(string), v)
+			}
+			_, err := dec.Token()
+			return obj, err
+		case '[':
+			list := []interface{}{}
+			for dec.More() {
+				v, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		return nil, fmt.Errorf("xmljson: unexpected %v", t)
+	default:
+		return t, nil
+	}
+}
+
+type xmlWriter struct {
+	opts Options
+	buf  bytes.Buffer
+}
+
+func (w *xmlWriter) element(name string, v interface{}) error {
+	if !isXMLName(name) {
+		return fmt.Errorf("xmljson: %q is not a valid element name", name)
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if _, nested := item.([]interface{}); nested {
+				return fmt.Errorf("xmljson: nested arrays under %q cannot be represented in XML", name)
+			}
+			if err := w.element(name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *object:
+		return w.object(name, v)
+	default:
+		w.buf.WriteString("<" + name + ">")
+		if err := w.text(v); err != nil {
+			return err
+		}
+		w.buf.WriteString("</" + name + ">")
+		return nil
+	}
+}
+
+func (w *xmlWriter) object(name string, obj *object) error {
+	attrs := obj
+	if w.opts.AttributesKey != "" {
+		attrs = newObject()
+		if nested, ok := obj.values[w.opts.AttributesKey]; ok {
+			if attrs, ok = nested.(*object); !ok {
+				return fmt.Errorf("xmljson: %q of %q must be an object", w.opts.AttributesKey, name)
+			}
+		}
+	}
+
+	w.buf.WriteString("<" + name)
+	for _, key := range attrs.keys {
+		attr, isAttr := key, true
+		if w.opts.AttributesKey == "" {
+			attr, isAttr = strings.CutPrefix(key, w.opts.AttrPrefix)
+		}
+		if !isAttr {
+			continue
+		}
+		if !isXMLName(attr) {
+			return fmt.Errorf("xmljson: %q is not a valid attribute name", attr)
+		}
+		value, err := scalarText(attrs.values[key])
+		if err != nil {
+			return fmt.Errorf("xmljson: attribute %q: %w", attr, err)
+		}
+		w.buf.WriteString(" " + attr + `="`)
+		xml.EscapeText(&w.buf, []byte(value))
+		w.buf.WriteString(`"`)
+	}
+	w.buf.WriteString(">")
+
+	if text, ok := obj.values[w.opts.TextKey]; ok {
+		if err := w.text(text); err != nil {
+			return err
+		}
+	}
+	for _, key := range obj.keys {
+		if key == w.opts.TextKey || w.isAttributeKey(key) {
+			continue
+		}
+		if err := w.element(key, obj.values[key]); err != nil {
+			return err
+		}
+	}
+
+	w.buf.WriteString("</" + name + ">")
+	return nil
+}
+
+func (w *xmlWriter) isAttributeKey(key string) bool {
+	if w.opts.AttributesKey != "" {
+		return key == w.opts.AttributesKey
+	}
+	return strings.HasPrefix(key, w.opts.AttrPrefix)
+}
+
+func (w *xmlWriter) text(v interface{}) error {
+	s, err := scalarText(v)
+	if err != nil {
+		return err
+	}
+	return xml.EscapeText(&w.buf, []byte(s))
+}
+
+// scalarText formats strings, numbers, booleans and null as character data
+func scalarText(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	}
+	return "", errors.New("expected a string, number, boolean or null")
+}
+
+func isXMLName(name string) bool {
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r) || r == '_' || r == ':':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
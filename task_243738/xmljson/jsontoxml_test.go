@@ -0,0 +1,113 @@
+package xmljson
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJSONToXML(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "Arrays Become Repeated Elements",
+			input:    `{"root":{"item":[{"name":"item1"},{"name":"item2"}]}}`,
+			expected: `<root><item><name>item1</name></item><item><name>item2</name></item></root>`,
+		},
+		{
+			name:     "Attributes And Text",
+			input:    `{"book":{"@id":"7","title":"Go","price":{"@currency":"EUR","#text":"12.50"}}}`,
+			expected: `<book id="7"><title>Go</title><price currency="EUR">12.50</price></book>`,
+		},
+		{
+			name:     "Custom Attribute Prefix And Text Key",
+			input:    `{"price":{"-currency":"EUR","value":"12.50"}}`,
+			opts:     Options{AttrPrefix: "-", TextKey: "value"},
+			expected: `<price currency="EUR">12.50</price>`,
+		},
+		{
+			name:     "Namespace Declarations",
+			input:    `{"soap:Envelope":{"@xmlns:soap":"http://www.w3.org/2003/05/soap-envelope","soap:Body":""}}`,
+			expected: `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body></soap:Body></soap:Envelope>`,
+		},
+		{
+			name:     "Scalars And Escaping",
+			input:    `{"r":{"n":12.5,"b":true,"z":null,"s":"<a & b>"}}`,
+			expected: `<r><n>12.5</n><b>true</b><z></z><s>&lt;a &amp; b&gt;</s></r>`,
+		},
+		{
+			name:    "Multiple Root Keys",
+			input:   `{"a":"1","b":"2"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid Element Name",
+			input:   `{"root":{"1st":"x"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Nested Arrays",
+			input:   `{"root":{"item":[["a"]]}}`,
+			wantErr: true,
+		},
+		{
+			name:    "Invalid JSON",
+			input:   `{"root":`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := JSONToXML([]byte(tt.input), tt.opts)
+
+			if tt.wantErr != (err != nil) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected error: %v, got error: %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr && string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s", tt.expected, string(result))
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []struct {
+		xml  string
+		opts Options
+	}{
+		{xml: `<root><item id="1"><name>a</name></item><item id="2"><name>b</name></item></root>`},
+		{xml: `<root><item><name>only</name></item></root>`, opts: Options{ForceList: []string{"root.item"}}},
+		{xml: `<doc lang="en">intro<p>one</p><p>two</p></doc>`},
+	}
+
+	for i, in := range inputs {
+		first, err := Convert([]byte(in.xml), in.opts)
+		if err != nil {
+			t.Fatalf("Input %d: convert failed: %v", i+1, err)
+		}
+		back, err := JSONToXML(first, in.opts)
+		if err != nil {
+			t.Fatalf("Input %d: reverse failed: %v", i+1, err)
+		}
+		second, err := Convert(back, in.opts)
+		if err != nil {
+			t.Fatalf("Input %d: second convert failed: %v", i+1, err)
+		}
+		if string(first) != string(second) {
+			t.Errorf("Input %d: round trip changed the document\nfirst:  %s\nsecond: %s", i+1, first, second)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package xmljson
+
+import "encoding/xml"
+
+// NamespaceMode selects how namespaced element and attribute names are converted
+type NamespaceMode int
+
+const (
+	// NamespaceStrip drops prefixes and namespace declarations: <soap:Body> becomes "Body"
+	NamespaceStrip NamespaceMode = iota
+	// NamespaceKeep keeps the prefixes declared in the document and the xmlns attributes,
+	// so the JSON converts back to equivalent XML: <soap:Body> becomes "soap:Body"
+	NamespaceKeep
+	// NamespaceAlias replaces prefixes by the aliases in Options.NamespaceAliases, keyed
+	// by namespace URI, so documents using different prefixes for the same namespace
+	// produce the same JSON. Namespaces without an alias keep their prefix.
+	NamespaceAlias
+)
+
+const xmlNamespaceURI = "http://www.w3.org/XML/1998/namespace"
+
+// nsBinding is a prefix declared for a namespace URI, an empty prefix is the default namespace
+type nsBinding struct {
+	prefix string
+	uri    string
+}
+
+// namespaces tracks the declarations in scope while decoding. encoding/xml resolves
+// prefixes to URIs, this maps them back to the prefixes the document used.
+type namespaces struct {
+	mode     NamespaceMode
+	aliases  map[string]string
+	bindings []nsBinding
+	// scopes holds the length of bindings before each open element
+	scopes []int
+}
+
+func newNamespaces(opts Options) *namespaces {
+	return &namespaces{
+		mode:     opts.Namespaces,
+		aliases:  opts.NamespaceAliases,
+		bindings: []nsBinding{{prefix: "xml", uri: xmlNamespaceURI}},
+	}
+}
+
+// push brings the declarations among attrs into scope until the matching pop
+func (n *namespaces) push(attrs []xml.Attr) {
+	n.scopes = append(n.scopes, len(n.bindings))
+	for _, attr := range attrs {
+		switch {
+		case attr.Name.Space == "xmlns":
+			n.bindings = append(n.bindings, nsBinding{prefix: attr.Name.Local, uri: attr.Value})
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			n.bindings = append(n.bindings, nsBinding{uri: attr.Value})
+		}
+	}
+}
+
+func (n *namespaces) pop() {
+	last := len(n.scopes) - 1
+	n.bindings = n.bindings[:n.scopes[last]]
+	n.scopes = n.scopes[:last]
+}
+
+// name converts an element name
+func (n *namespaces) name(name xml.Name) string {
+	if name.Space == "" || n.mode == NamespaceStrip {
+		return name.Local
+	}
+
+	prefix, ok := "", false
+	if n.mode == NamespaceAlias {
+		prefix, ok = n.aliases[name.Space]
+	}
+	if !ok {
+		prefix, ok = n.prefix(name.Space)
+	}
+	if !ok {
+		// encoding/xml leaves undeclared prefixes in Space
+		prefix = name.Space
+	}
+
+	if prefix == "" {
+		return name.Local
+	}
+	return prefix + ":" + name.Local
+}
+
+// attrName converts an attribute name, reporting false for namespace declarations
+// that are not kept
+func (n *namespaces) attrName(attr xml.Attr) (string, bool) {
+	switch {
+	case attr.Name.Space == "xmlns":
+		return "xmlns:" + attr.Name.Local, n.mode == NamespaceKeep
+	case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+		return "xmlns", n.mode == NamespaceKeep
+	}
+	return n.name(attr.Name), true
+}
+
+// prefix finds the innermost prefix bound to uri
+func (n *namespaces) prefix(uri string) (string, bool) {
+	for i := len(n.bindings) - 1; i >= 0; i-- {
+		if n.bindings[i].uri == uri {
+			return n.bindings[i].prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,111 @@
+package xmljson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const soapEnvelope = `<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope" xmlns:m="urn:stock">
+  <soap:Body><m:GetPrice m:id="1"><m:Item>IBM</m:Item></m:GetPrice></soap:Body>
+</soap:Envelope>`
+
+func TestNamespaces(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "Prefixes Are Stripped By Default",
+			input:    soapEnvelope,
+			expected: `{"Envelope":{"Body":{"GetPrice":{"@id":"1","Item":"IBM"}}}}`,
+		},
+		{
+			name:     "Keep Prefixes And Declarations",
+			input:    soapEnvelope,
+			opts:     Options{Namespaces: NamespaceKeep},
+			expected: `{"soap:Envelope":{"@xmlns:soap":"http://www.w3.org/2003/05/soap-envelope","@xmlns:m":"urn:stock","soap:Body":{"m:GetPrice":{"@m:id":"1","m:Item":"IBM"}}}}`,
+		},
+		{
+			name:  "Aliases Replace Document Prefixes",
+			input: `<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Body/></env:Envelope>`,
+			opts: Options{Namespaces: NamespaceAlias, NamespaceAliases: map[string]string{
+				"http://www.w3.org/2003/05/soap-envelope": "s",
+			}},
+			expected: `{"s:Envelope":{"s:Body":""}}`,
+		},
+		{
+			name:  "Alias For Default Namespace",
+			input: `<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/"><entry><title>a</title><media:thumbnail url="x"/></entry></feed>`,
+			opts: Options{Namespaces: NamespaceAlias, NamespaceAliases: map[string]string{
+				"http://www.w3.org/2005/Atom": "atom",
+			}},
+			expected: `{"atom:feed":{"atom:entry":{"atom:title":"a","media:thumbnail":{"@url":"x"}}}}`,
+		},
+		{
+			name:     "Inner Declarations Go Out Of Scope",
+			input:    `<r xmlns:a="urn:one"><x xmlns:a="urn:two"><a:v>2</a:v></x><a:v>1</a:v></r>`,
+			opts:     Options{Namespaces: NamespaceKeep},
+			expected: `{"r":{"@xmlns:a":"urn:one","x":{"@xmlns:a":"urn:two","a:v":"2"},"a:v":"1"}}`,
+		},
+		{
+			name:     "Predeclared xml Prefix",
+			input:    `<title xml:lang="en">Hi</title>`,
+			opts:     Options{Namespaces: NamespaceKeep},
+			expected: `{"title":{"@xml:lang":"en","#text":"Hi"}}`,
+		},
+		{
+			name:     "Attributes Object",
+			input:    `<rss version="2.0"><channel><item><guid isPermaLink="false">42</guid></item></channel></rss>`,
+			opts:     Options{AttributesKey: "_attributes"},
+			expected: `{"rss":{"_attributes":{"version":"2.0"},"channel":{"item":{"guid":{"_attributes":{"isPermaLink":"false"},"#text":"42"}}}}}`,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert([]byte(tt.input), tt.opts)
+			if err != nil || string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s (%v)", tt.expected, string(result), err)
+			}
+
+			var streamed bytes.Buffer
+			if err := ConvertStream(strings.NewReader(tt.input), &streamed, tt.opts); err != nil || streamed.String() != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Streaming expected: %s\nGot: %s (%v)", tt.expected, streamed.String(), err)
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+func TestNamespaceRoundTrip(t *testing.T) {
+	for _, opts := range []Options{
+		{Namespaces: NamespaceKeep},
+		{Namespaces: NamespaceKeep, AttributesKey: "_attributes"},
+	} {
+		first, err := Convert([]byte(soapEnvelope), opts)
+		if err != nil {
+			t.Fatalf("Convert failed: %v", err)
+		}
+		back, err := JSONToXML(first, opts)
+		if err != nil {
+			t.Fatalf("JSONToXML failed: %v", err)
+		}
+		second, err := Convert(back, opts)
+		if err != nil {
+			t.Fatalf("Converting %s failed: %v", back, err)
+		}
+		if string(first) != string(second) {
+			t.Errorf("Round trip changed the document\nfirst:  %s\nsecond: %s", first, second)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package xmljson
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ValueType is the JSON type the text of an element or attribute converts to
+type ValueType string
+
+const (
+	TypeString  ValueType = "string"
+	TypeInteger ValueType = "integer"
+	TypeNumber  ValueType = "number"
+	TypeBoolean ValueType = "boolean"
+)
+
+// ElementHint declares how the element or attribute at a path converts
+type ElementHint struct {
+	// List marks elements that may occur more than once, they are always arrays
+	List bool
+	// Type of the text content, empty leaves the value to InferTypes
+	Type ValueType
+}
+
+// Schema maps element paths such as "order.item" and attribute paths such as
+// "order.item.@id" to hints. It can be written by hand or derived from an XSD.
+type Schema map[string]ElementHint
+
+var jsonInteger = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+
+// convert returns text as the declared type, values that are not valid for the
+// type are kept as strings rather than lost
+func (t ValueType) convert(text string) interface{} {
+	switch t {
+	case TypeInteger:
+		// XSD allows a leading plus and leading zeros, JSON does not
+		if n, err := strconv.ParseInt(strings.TrimPrefix(text, "+"), 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(n, 10))
+		}
+		if jsonInteger.MatchString(text) {
+			return json.Number(text)
+		}
+	case TypeNumber:
+		if jsonNumber.MatchString(text) {
+			return json.Number(text)
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(text, "InfNa") {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	case TypeBoolean:
+		switch text {
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		}
+	}
+	return text
+}
+
+// xsdTypes maps built-in XSD types to JSON types, unlisted built-ins are strings
+var xsdTypes = map[string]ValueType{
+	"integer": TypeInteger, "int": TypeInteger, "long": TypeInteger, "short": TypeInteger,
+	"byte": TypeInteger, "nonNegativeInteger": TypeInteger, "positiveInteger": TypeInteger,
+	"nonPositiveInteger": TypeInteger, "negativeInteger": TypeInteger, "unsignedLong": TypeInteger,
+	"unsignedInt": TypeInteger, "unsignedShort": TypeInteger, "unsignedByte": TypeInteger,
+	"decimal": TypeNumber, "float": TypeNumber, "double": TypeNumber,
+	"boolean": TypeBoolean,
+}
+
+type xsdSchema struct {
+	Elements     []xsdElement     `xml:"element"`
+	ComplexTypes []xsdComplexType `xml:"complexType"`
+	SimpleTypes  []xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdElement struct {
+	Name        string          `xml:"name,attr"`
+	Ref         string          `xml:"ref,attr"`
+	Type        string          `xml:"type,attr"`
+	MaxOccurs   string          `xml:"maxOccurs,attr"`
+	ComplexType *xsdComplexType `xml:"complexType"`
+	SimpleType  *xsdSimpleType  `xml:"simpleType"`
+}
+
+type xsdComplexType struct {
+	Name          string          `xml:"name,attr"`
+	Sequence      *xsdGroup       `xml:"sequence"`
+	Choice        *xsdGroup       `xml:"choice"`
+	All           *xsdGroup       `xml:"all"`
+	Attributes    []xsdAttribute  `xml:"attribute"`
+	SimpleContent *xsdExtension   `xml:"simpleContent>extension"`
+	Extension     *xsdComplexType `xml:"complexContent>extension"`
+	Base          string          `xml:"base,attr"`
+}
+
+type xsdGroup struct {
+	MaxOccurs string       `xml:"maxOccurs,attr"`
+	Elements  []xsdElement `xml:"element"`
+	Sequences []xsdGroup   `xml:"sequence"`
+	Choices   []xsdGroup   `xml:"choice"`
+}
+
+type xsdExtension struct {
+	Base       string         `xml:"base,attr"`
+	Attributes []xsdAttribute `xml:"attribute"`
+}
+
+type xsdAttribute struct {
+	Name       string         `xml:"name,attr"`
+	Type       string         `xml:"type,attr"`
+	SimpleType *xsdSimpleType `xml:"simpleType"`
+}
+
+type xsdSimpleType struct {
+	Name        string `xml:"name,attr"`
+	Restriction struct {
+		Base string `xml:"base,attr"`
+	} `xml:"restriction"`
+}
+
+// ParseXSD derives a Schema from an XML Schema document. Elements with a maxOccurs
+// above one, directly or through a repeating sequence or choice, become lists and
+// built-in numeric and boolean types, also through named simple types, become typed
+// values. Named complex types and element references are followed, recursive types
+// are expanded once. Namespaces are ignored, paths use local names.
+func ParseXSD(data []byte) (Schema, error) {
+	var doc xsdSchema
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("xmljson: parsing XSD: %w", err)
+	}
+
+	b := &schemaBuilder{
+		schema:       make(Schema),
+		elements:     make(map[string]xsdElement),
+		complexTypes: make(map[string]*xsdComplexType),
+		simpleTypes:  make(map[string]string),
+		expanding:    make(map[string]bool),
+	}
+	for _, el := range doc.Elements {
+		b.elements[el.Name] = el
+	}
+	for i := range doc.ComplexTypes {
+		b.complexTypes[doc.ComplexTypes[i].Name] = &doc.ComplexTypes[i]
+	}
+	for _, st := range doc.SimpleTypes {
+		b.simpleTypes[st.Name] = st.Restriction.Base
+	}
+
+	for _, el := range doc.Elements {
+		b.element(el, "", false)
+	}
+	return b.schema, nil
+}
+
+type schemaBuilder struct {
+	schema       Schema
+	elements     map[string]xsdElement
+	complexTypes map[string]*xsdComplexType
+	simpleTypes  map[string]string
+	// expanding holds the named complex types on the current path
+	expanding map[string]bool
+}
+
+func (b *schemaBuilder) element(el xsdElement, parent string, repeated bool) {
+	if el.Ref != "" {
+		ref, ok := b.elements[localName(el.Ref)]
+		if !ok {
+			return
+		}
+		ref.MaxOccurs = el.MaxOccurs
+		el = ref
+	}
+
+	path := el.Name
+	if parent != "" {
+		path = parent + "." + el.Name
+	}
+
+	hint := ElementHint{List: repeated || isRepeated(el.MaxOccurs)}
+	switch {
+	case el.ComplexType != nil:
+		hint.Type = b.complexType(el.ComplexType, path)
+	case el.SimpleType != nil:
+		hint.Type = b.valueType(el.SimpleType.Restriction.Base)
+	case el.Type != "":
+		if ct, ok := b.complexTypes[localName(el.Type)]; ok {
+			name := localName(el.Type)
+			if !b.expanding[name] {
+				b.expanding[name] = true
+				hint.Type = b.complexType(ct, path)
+				delete(b.expanding, name)
+			}
+		} else {
+			hint.Type = b.valueType(el.Type)
+		}
+	}
+
+	if hint.List || hint.Type != "" {
+		b.schema[path] = hint
+	}
+}
+
+// complexType adds the children and attributes of ct below path and returns the
+// type of its text content for simple content
+func (b *schemaBuilder) complexType(ct *xsdComplexType, path string) ValueType {
+	for _, attr := range ct.Attributes {
+		b.attribute(attr, path)
+	}
+	for _, group := range []*xsdGroup{ct.Sequence, ct.Choice, ct.All} {
+		if group != nil {
+			b.group(*group, path, false)
+		}
+	}
+	if ct.Extension != nil {
+		if base, ok := b.complexTypes[localName(ct.Extension.Base)]; ok && !b.expanding[base.Name] {
+			b.expanding[base.Name] = true
+			b.complexType(base, path)
+			delete(b.expanding, base.Name)
+		}
+		b.complexType(ct.Extension, path)
+	}
+	if ct.SimpleContent != nil {
+		for _, attr := range ct.SimpleContent.Attributes {
+			b.attribute(attr, path)
+		}
+		return b.valueType(ct.SimpleContent.Base)
+	}
+	return ""
+}
+
+func (b *schemaBuilder) group(g xsdGroup, path string, repeated bool) {
+	repeated = repeated || isRepeated(g.MaxOccurs)
+	for _, el := range g.Elements {
+		b.element(el, path, repeated)
+	}
+	for _, nested := range g.Sequences {
+		b.group(nested, path, repeated)
+	}
+	for _, nested := range g.Choices {
+		b.group(nested, path, repeated)
+	}
+}
+
+func (b *schemaBuilder) attribute(attr xsdAttribute, path string) {
+	var t ValueType
+	if attr.SimpleType != nil {
+		t = b.valueType(attr.SimpleType.Restriction.Base)
+	} else {
+		t = b.valueType(attr.Type)
+	}
+	if t != "" {
+		b.schema[path+".@"+attr.Name] = ElementHint{Type: t}
+	}
+}
+
+// valueType resolves a built-in or named simple type
+func (b *schemaBuilder) valueType(name string) ValueType {
+	for seen := 0; name != "" && seen < 32; seen++ {
+		local := localName(name)
+		if base, ok := b.simpleTypes[local]; ok {
+			name = base
+			continue
+		}
+		if t, ok := xsdTypes[local]; ok {
+			return t
+		}
+		return TypeString
+	}
+	return ""
+}
+
+func isRepeated(maxOccurs string) bool {
+	if maxOccurs == "unbounded" {
+		return true
+	}
+	n, err := strconv.Atoi(maxOccurs)
+	return err == nil && n > 1
+}
+
+func localName(name string) string {
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,133 @@
+package xmljson
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const orderXSD = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:simpleType name="ZipCode">
+    <xs:restriction base="xs:string"><xs:pattern value="[0-9]{5}"/></xs:restriction>
+  </xs:simpleType>
+  <xs:simpleType name="Quantity">
+    <xs:restriction base="xs:positiveInteger"/>
+  </xs:simpleType>
+  <xs:complexType name="ItemType">
+    <xs:sequence>
+      <xs:element name="sku" type="xs:string"/>
+      <xs:element name="qty" type="Quantity"/>
+      <xs:element name="tag" type="xs:string" minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+    <xs:attribute name="id" type="xs:int"/>
+  </xs:complexType>
+  <xs:element name="zip" type="ZipCode"/>
+  <xs:element name="order">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element ref="zip"/>
+        <xs:element name="paid" type="xs:boolean"/>
+        <xs:element name="total">
+          <xs:complexType>
+            <xs:simpleContent>
+              <xs:extension base="xs:decimal"><xs:attribute name="currency" type="xs:string"/></xs:extension>
+            </xs:simpleContent>
+          </xs:complexType>
+        </xs:element>
+        <xs:element name="item" type="ItemType" maxOccurs="unbounded"/>
+        <xs:choice maxOccurs="unbounded">
+          <xs:element name="note" type="xs:string"/>
+        </xs:choice>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func TestParseXSD(t *testing.T) {
+	schema, err := ParseXSD([]byte(orderXSD))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := Schema{
+		"zip":                   {Type: TypeString},
+		"order.zip":             {Type: TypeString},
+		"order.paid":            {Type: TypeBoolean},
+		"order.total":           {Type: TypeNumber},
+		"order.total.@currency": {Type: TypeString},
+		"order.item":            {List: true},
+		"order.item.@id":        {Type: TypeInteger},
+		"order.item.sku":        {Type: TypeString},
+		"order.item.qty":        {Type: TypeInteger},
+		"order.item.tag":        {List: true, Type: TypeString},
+		"order.note":            {List: true, Type: TypeString},
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, schema)
+	}
+
+	if _, err := ParseXSD([]byte("<xs:schema>")); err == nil {
+		t.Errorf("Expected an error for a malformed XSD")
+	}
+}
+
+func TestSchemaGuidedConversion(t *testing.T) {
+	schema, err := ParseXSD([]byte(orderXSD))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "Cardinality And Types From XSD",
+			input:    `<order><zip>01234</zip><paid>1</paid><total currency="EUR">12.50</total><item id="007"><sku>123</sku><qty>+3</qty></item></order>`,
+			opts:     Options{Schema: schema},
+			expected: `{"order":{"zip":"01234","paid":true,"total":{"@currency":"EUR","#text":12.50},"item":[{"@id":7,"sku":"123","qty":3}]}}`,
+		},
+		{
+			name:     "Declared Types Win Over Inference",
+			input:    `<order><zip>01234</zip><item><sku>42</sku><tag>true</tag></item><extra>5</extra></order>`,
+			opts:     Options{Schema: schema, InferTypes: true},
+			expected: `{"order":{"zip":"01234","item":[{"sku":"42","tag":["true"]}],"extra":5}}`,
+		},
+		{
+			name:     "Invalid Values Stay Strings",
+			input:    `<order><paid>maybe</paid><item><qty>many</qty></item></order>`,
+			opts:     Options{Schema: schema},
+			expected: `{"order":{"paid":"maybe","item":[{"qty":"many"}]}}`,
+		},
+		{
+			name:     "Empty Typed Elements Are Null",
+			input:    `<order><paid/><zip/></order>`,
+			opts:     Options{Schema: schema},
+			expected: `{"order":{"paid":null,"zip":""}}`,
+		},
+		{
+			name:     "Hand Written Schema",
+			input:    `<r><v>1.5</v></r>`,
+			opts:     Options{Schema: Schema{"r.v": {List: true, Type: TypeNumber}}},
+			expected: `{"r":{"v":[1.5]}}`,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert([]byte(tt.input), tt.opts)
+			if err != nil || string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s (%v)", tt.expected, string(result), err)
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
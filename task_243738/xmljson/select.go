@@ -0,0 +1,173 @@
+package xmljson
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrNoMatch is returned when Options.Select matches no element of the document
+var ErrNoMatch = errors.New("xmljson: select path matches no element")
+
+// step is a segment of a select path: an element name or "*" for any element,
+// with an optional 1-based position among the siblings it matches, 0 for all
+type step struct {
+	name     string
+	position int
+}
+
+func (s step) matches(name string) bool {
+	return s.name == "*" || s.name == name
+}
+
+// selector is a parsed Options.Select path, a step per level from the root
+type selector []step
+
+// parseSelector accepts the dot separated paths used by the other options,
+// e.g. "Envelope.Body.*", and the XPath form "/Envelope/Body/*". A step may
+// carry a position such as "item[2]".
+func parseSelector(path string) (selector, error) {
+	sep := "."
+	if strings.HasPrefix(path, "/") {
+		sep = "/"
+		path = path[1:]
+	}
+
+	var sel selector
+	for _, segment := range strings.Split(path, sep) {
+		s := step{name: segment}
+		if open := strings.IndexByte(segment, '['); open >= 0 {
+			n, err := strconv.Atoi(strings.TrimSuffix(segment[open+1:], "]"))
+			if !strings.HasSuffix(segment, "]") || err != nil || n < 1 {
+				return nil, fmt.Errorf("xmljson: invalid position in select path step %q", segment)
+			}
+			s = step{name: segment[:open], position: n}
+		}
+		if s.name == "" || strings.ContainsAny(s.name, "[]/") {
+			return nil, fmt.Errorf("xmljson: invalid select path %q", path)
+		}
+		sel = append(sel, s)
+	}
+	return sel, nil
+}
+
+// matchFunc receives every opened element a selector matches, with its path from the
+// root and its depth. It must read the element up to and including its end tag.
+type matchFunc func(el *element, path string, depth int) error
+
+// selectElements walks the document and passes the elements sel matches to match.
+// Everything outside the selected sub-trees is skipped without being kept in memory.
+func (p *parser) selectElements(sel selector, match matchFunc) error {
+	var seenRoot bool
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if seenRoot && p.rec == nil {
+			return p.errorf(errMultipleRoots)
+		}
+
+		root, err := p.open(start, 1)
+		if err != nil {
+			return err
+		}
+		if seenRoot {
+			p.warn(errMultipleRoots)
+			err = p.skip(1)
+		} else if sel[0].matches(root.name) && sel[0].position <= 1 {
+			err = p.descend(root, root.name, 1, sel, match)
+		} else {
+			err = p.skip(1)
+		}
+		if err != nil {
+			return err
+		}
+		seenRoot = true
+	}
+
+	if !seenRoot {
+		return ErrNoRoot
+	}
+	return nil
+}
+
+// descend continues the selection below an opened element matching sel[depth-1]
+func (p *parser) descend(el *element, path string, depth int, sel selector, match matchFunc) error {
+	if depth == len(sel) {
+		return match(el, path, depth)
+	}
+
+	next := sel[depth]
+	var position int
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			return p.errorf(io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := p.open(t, depth+1)
+			if err != nil {
+				return err
+			}
+			selected := next.matches(child.name)
+			if selected {
+				position++
+				selected = next.position == 0 || next.position == position
+			}
+			if selected {
+				err = p.descend(child, path+"."+child.name, depth+1, sel, match)
+			} else {
+				err = p.skip(depth + 1)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			p.close()
+			return nil
+		}
+	}
+}
+
+// skip reads past the content of an opened element up to its end tag
+func (p *parser) skip(depth int) error {
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			return p.errorf(io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if _, err := p.open(t, depth+1); err != nil {
+				return err
+			}
+			if err := p.skip(depth + 1); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			p.close()
+			return nil
+		}
+	}
+}
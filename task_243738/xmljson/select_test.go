@@ -0,0 +1,129 @@
+package xmljson
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const soapResponse = `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Header><auth token="secret"/></soap:Header>
+  <soap:Body>
+    <m:GetQuoteResponse xmlns:m="urn:quotes">
+      <m:Quote symbol="ACME">12.5</m:Quote>
+      <m:Quote symbol="INIT">7</m:Quote>
+    </m:GetQuoteResponse>
+  </soap:Body>
+</soap:Envelope>`
+
+func TestSelect(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+		wantErr  error
+	}{
+		{
+			name:     "SOAP Body Content",
+			input:    soapResponse,
+			opts:     Options{Select: "Envelope.Body.GetQuoteResponse"},
+			expected: `{"GetQuoteResponse":{"Quote":[{"@symbol":"ACME","#text":"12.5"},{"@symbol":"INIT","#text":"7"}]}}`,
+		},
+		{
+			name:     "XPath Form With Wildcard",
+			input:    soapResponse,
+			opts:     Options{Select: "/Envelope/Body/*/Quote", InferTypes: true},
+			expected: `{"Quote":[{"@symbol":"ACME","#text":12.5},{"@symbol":"INIT","#text":7}]}`,
+		},
+		{
+			name:     "Position",
+			input:    soapResponse,
+			opts:     Options{Select: "Envelope.Body.GetQuoteResponse.Quote[2]"},
+			expected: `{"Quote":{"@symbol":"INIT","#text":"7"}}`,
+		},
+		{
+			name:     "Kept Namespace Prefixes",
+			input:    soapResponse,
+			opts:     Options{Select: "soap:Envelope.soap:Header.auth", Namespaces: NamespaceKeep},
+			expected: `{"auth":{"@token":"secret"}}`,
+		},
+		{
+			name:     "Option Paths Start At The Root",
+			input:    `<root><a><other>1</other></a><b><item>2</item></b></root>`,
+			opts:     Options{Select: "root.*.item", ForceList: []string{"root.b.item"}},
+			expected: `{"item":["2"]}`,
+		},
+		{
+			name:     "Wildcard Position",
+			input:    `<root><a>1</a><b x="y"/><c>3</c></root>`,
+			opts:     Options{Select: "root.*[2]"},
+			expected: `{"b":{"@x":"y"}}`,
+		},
+		{
+			name:     "Different Names Become Members",
+			input:    `<root><a>1</a><b>2</b><b>3</b></root>`,
+			opts:     Options{Select: "root.*"},
+			expected: `{"a":"1","b":["2","3"]}`,
+		},
+		{
+			name:    "No Match",
+			input:   soapResponse,
+			opts:    Options{Select: "Envelope.Body.Fault"},
+			wantErr: ErrNoMatch,
+		},
+		{
+			name:    "Wrong Root",
+			input:   soapResponse,
+			opts:    Options{Select: "Body"},
+			wantErr: ErrNoMatch,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := Convert([]byte(tt.input), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected error: %v, got error: %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && string(converted) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s", tt.expected, converted)
+			}
+
+			var out bytes.Buffer
+			err = ConvertStream(strings.NewReader(tt.input), &out, tt.opts)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && out.String() != tt.expected) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("ConvertStream disagrees: %s (%v)", out.String(), err)
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+func TestSelectInvalidPath(t *testing.T) {
+	for _, path := range []string{"root..item", "root.item[0]", "root.item[x]", "root.item[1", "/"} {
+		if _, err := Convert([]byte(`<root/>`), Options{Select: path}); err == nil || errors.Is(err, ErrNoMatch) {
+			t.Errorf("Expected %q to be rejected, got %v", path, err)
+		}
+	}
+}
+
+func TestSelectStreamsLargeDocument(t *testing.T) {
+	const items = 50000
+
+	var out bytes.Buffer
+	err := ConvertStream(&itemReader{n: items + 1}, &out, Options{Select: fmt.Sprintf("root.item[%d].name", items)})
+	if err != nil || out.String() != fmt.Sprintf(`{"name":"item%d"}`, items) {
+		t.Errorf("Unexpected result %s: %v", out.String(), err)
+	}
+}
@@ -0,0 +1,320 @@
+package xmljson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ConvertStream converts the XML document read from r and writes the JSON to w while
+// decoding, so documents much larger than memory can be converted.
+//
+// The root element and the ancestors of Options.StreamPath are written token by token,
+// their children are decoded one at a time and written as soon as it is known whether
+// they belong to an array. Memory use is therefore bounded by the largest child of a
+// streamed element rather than by the document. Repeated elements below a streamed
+// element must be contiguous and, with Options.ContentKey, streamed elements must
+// not mix text and children. The output matches Convert for such documents.
+// On error w may already hold part of the output.
+func ConvertStream(r io.Reader, w io.Writer, opts Options) error {
+	opts = opts.withDefaults()
+	if opts.Lenient {
+		return errors.New("xmljson: lenient mode needs the whole document, use Convert")
+	}
+	if opts.Canonical {
+		return errors.New("xmljson: canonical mode needs the whole document, use Convert")
+	}
+
+	var s *streamer
+	if opts.CDataKey != "" {
+		rec := newRecorder(r)
+		s = newStreamer(xml.NewDecoder(rec), bufio.NewWriter(w), opts)
+		s.p.source = rec
+	} else {
+		s = newStreamer(xml.NewDecoder(r), bufio.NewWriter(w), opts)
+	}
+	if opts.Select == "" {
+		if err := s.document(); err != nil {
+			return err
+		}
+	} else if err := s.selection(); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+type streamer struct {
+	conv *converter
+	p    *parser
+	w    *bufio.Writer
+	err  error
+	// ancestors holds the paths above Options.StreamPath, they are streamed as well
+	ancestors map[string]bool
+}
+
+func newStreamer(dec *xml.Decoder, w *bufio.Writer, opts Options) *streamer {
+	s := &streamer{conv: newConverter(opts), p: newParser(dec, opts), w: w, ancestors: make(map[string]bool)}
+	if opts.StreamPath != "" {
+		parts := strings.Split(opts.StreamPath, ".")
+		for i := 1; i < len(parts); i++ {
+			s.ancestors[strings.Join(parts[:i], ".")] = true
+		}
+	}
+	return s
+}
+
+func (s *streamer) document() error {
+	var seenRoot bool
+	for {
+		tok, err := s.p.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if seenRoot {
+			return s.p.errorf(errMultipleRoots)
+		}
+		seenRoot = true
+
+		root, err := s.p.open(start, 1)
+		if err != nil {
+			return err
+		}
+		s.writeString("{")
+		s.writeKey(root.name)
+		if err := s.element(root, root.name, 1); err != nil {
+			return err
+		}
+		s.writeString("}")
+	}
+
+	if !seenRoot {
+		return ErrNoRoot
+	}
+	return s.err
+}
+
+// selection streams the elements matched by Options.Select as the members of the output
+func (s *streamer) selection() error {
+	sel, err := parseSelector(s.conv.opts.Select)
+	if err != nil {
+		return err
+	}
+
+	c := s.newChildren()
+	err = s.p.selectElements(sel, func(el *element, path string, depth int) error {
+		return s.child(c, el, path, depth)
+	})
+	if err != nil {
+		return err
+	}
+	if c.group == nil {
+		return ErrNoMatch
+	}
+	s.closeGroup(c.obj, c.group)
+	s.writeString("}")
+	return s.err
+}
+
+// element streams the content of an opened element up to and including the end tag
+func (s *streamer) element(el *element, path string, depth int) error {
+	c := s.newChildren()
+	attrs := s.conv.attributes(el.attrs, path)
+	for _, key := range attrs.keys {
+		c.obj.key(key)
+		s.writeValue(attrs.values[key])
+	}
+
+	var text, cdata strings.Builder
+	for {
+		tok, err := s.p.token()
+		if err == io.EOF {
+			return s.p.errorf(io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			// Leading whitespace is dropped right away, it would otherwise pile up
+			// between millions of children only to be trimmed at the end
+			if s.p.inCDATA {
+				cdata.Write(t)
+			} else if text.Len() > 0 || len(bytes.TrimSpace(t)) > 0 {
+				text.Write(t)
+			}
+		case xml.StartElement:
+			child, err := s.p.open(t, depth+1)
+			if err != nil {
+				return err
+			}
+			if err := s.child(c, child, path+"."+child.name, depth+1); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			s.p.close()
+			s.closeGroup(c.obj, c.group)
+
+			value := s.conv.text(text.String())
+			if s.conv.opts.ContentKey != "" && c.group != nil && (value != "" || cdata.Len() > 0) {
+				return s.p.errorf(fmt.Errorf("mixed content of streamed element <%s> cannot be kept in order, use Convert", el.name))
+			}
+			if !c.obj.open && cdata.Len() == 0 {
+				s.writeValue(s.conv.leaf(value, path))
+				return s.err
+			}
+			if value != "" {
+				c.obj.key(s.conv.opts.TextKey)
+				s.writeValue(s.conv.scalar(value, path))
+			}
+			if cdata.Len() > 0 {
+				c.obj.key(s.conv.opts.CDataKey)
+				s.writeValue(cdata.String())
+			}
+			s.writeString("}")
+			return s.err
+		}
+
+		if s.err != nil {
+			return s.err
+		}
+	}
+}
+
+// children is the state of the object members written for the children of an element
+type children struct {
+	obj     *objectWriter
+	group   *siblingGroup
+	emitted map[string]bool
+}
+
+func (s *streamer) newChildren() *children {
+	return &children{obj: &objectWriter{s: s}, emitted: make(map[string]bool)}
+}
+
+// child writes an opened child element, reading it up to its end tag
+func (s *streamer) child(c *children, child *element, path string, depth int) error {
+	name := child.name
+	if c.group == nil || c.group.name != name {
+		s.closeGroup(c.obj, c.group)
+		if c.emitted[name] {
+			return s.p.errorf(fmt.Errorf("element <%s> repeats after other elements, streaming requires repeated elements to be contiguous", name))
+		}
+		c.emitted[name] = true
+		c.group = &siblingGroup{name: name, forced: s.conv.forceList[path], streamed: s.ancestors[path]}
+	}
+
+	if c.group.streamed {
+		if c.group.count++; c.group.count > 1 {
+			return s.p.errorf(fmt.Errorf("element <%s> is on the stream path and must occur once", name))
+		}
+		c.obj.key(name)
+		return s.element(child, path, depth)
+	}
+
+	if err := s.p.content(child, depth); err != nil {
+		return err
+	}
+	s.addToGroup(c.obj, c.group, s.conv.value(child, path))
+	return s.err
+}
+
+// siblingGroup is a run of same named children of a streamed element. A single
+// occurrence is held back until the next sibling shows whether an array is needed.
+type siblingGroup struct {
+	name     string
+	forced   bool
+	streamed bool
+	count    int
+	pending  interface{}
+}
+
+func (s *streamer) addToGroup(obj *objectWriter, g *siblingGroup, v interface{}) {
+	g.count++
+	switch {
+	case g.forced && g.count == 1:
+		obj.key(g.name)
+		s.writeString("[")
+		s.writeValue(v)
+	case g.count == 1:
+		g.pending = v
+	case !g.forced && g.count == 2:
+		obj.key(g.name)
+		s.writeString("[")
+		s.writeValue(g.pending)
+		s.writeString(",")
+		s.writeValue(v)
+		g.pending = nil
+	default:
+		s.writeString(",")
+		s.writeValue(v)
+	}
+}
+
+func (s *streamer) closeGroup(obj *objectWriter, g *siblingGroup) {
+	if g == nil || g.streamed {
+		return
+	}
+	if !g.forced && g.count == 1 {
+		obj.key(g.name)
+		s.writeValue(g.pending)
+		return
+	}
+	s.writeString("]")
+}
+
+func (s *streamer) writeKey(key string) {
+	s.writeValue(key)
+	s.writeString(":")
+}
+
+func (s *streamer) writeValue(v interface{}) {
+	if s.err != nil {
+		return
+	}
+	b, err := marshal(v)
+	if err != nil {
+		s.err = err
+		return
+	}
+	_, s.err = s.w.Write(b)
+}
+
+func (s *streamer) writeString(str string) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.w.WriteString(str)
+}
+
+// objectWriter opens a JSON object lazily so elements without attributes or
+// children can still be written as plain strings
+type objectWriter struct {
+	s     *streamer
+	open  bool
+	count int
+}
+
+func (o *objectWriter) key(k string) {
+	if !o.open {
+		o.s.writeString("{")
+		o.open = true
+	}
+	if o.count > 0 {
+		o.s.writeString(",")
+	}
+	o.count++
+	o.s.writeKey(k)
+}
@@ -0,0 +1,166 @@
+package xmljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConvertStream(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "Repeated Elements Become Arrays",
+			input: `<root>
+                <item><name>item1</name><value>value1</value></item>
+                <item><name>item2</name><value>value2</value></item>
+            </root>`,
+			expected: `{"root":{"item":[{"name":"item1","value":"value1"},{"name":"item2","value":"value2"}]}}`,
+		},
+		{
+			name:     "Single Element Stays An Object",
+			input:    `<root><item><name>item1</name></item></root>`,
+			expected: `{"root":{"item":{"name":"item1"}}}`,
+		},
+		{
+			name:     "Attributes And Text On Streamed Root",
+			input:    `<book id="7">intro<title>Go</title></book>`,
+			expected: `{"book":{"@id":"7","title":"Go","#text":"intro"}}`,
+		},
+		{
+			name:     "Leaf Root",
+			input:    `<note> hello </note>`,
+			expected: `{"note":"hello"}`,
+		},
+		{
+			name:     "Force List",
+			input:    `<root><item>a</item><meta>x</meta></root>`,
+			opts:     Options{ForceList: []string{"root.item"}},
+			expected: `{"root":{"item":["a"],"meta":"x"}}`,
+		},
+		{
+			name:     "Stream Path Below The Root",
+			input:    `<feed><title>News</title><entries><entry id="1">a</entry></entries></feed>`,
+			opts:     Options{StreamPath: "feed.entries.entry"},
+			expected: `{"feed":{"title":"News","entries":{"entry":[{"@id":"1","#text":"a"}]}}}`,
+		},
+		{
+			name:     "Type Inference",
+			input:    `<root count="2"><item>1</item><item>x</item><none/></root>`,
+			opts:     Options{InferTypes: true},
+			expected: `{"root":{"@count":2,"item":[1,"x"],"none":null}}`,
+		},
+		{
+			name:    "Depth Limit Exceeded",
+			input:   `<a><b><c><d>deep</d></c></b></a>`,
+			opts:    Options{MaxDepth: 3},
+			wantErr: true,
+		},
+		{
+			name:    "Non Contiguous Repeats",
+			input:   `<root><a>1</a><b>2</b><a>3</a></root>`,
+			wantErr: true,
+		},
+		{
+			name:    "Truncated Document",
+			input:   `<root><item>a</item>`,
+			wantErr: true,
+		},
+		{
+			name:    "Multiple Roots",
+			input:   `<a/><b/>`,
+			wantErr: true,
+		},
+		{
+			name:    "Canonical Mode Is Not Streamed",
+			input:   `<a/>`,
+			opts:    Options{Canonical: true},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := ConvertStream(strings.NewReader(tt.input), &out, tt.opts)
+
+			if tt.wantErr != (err != nil) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected error: %v, got error: %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr {
+				if out.String() != tt.expected {
+					fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+					t.Fatalf("Expected: %s\nGot: %s", tt.expected, out.String())
+				}
+				converted, err := Convert([]byte(tt.input), tt.opts)
+				if err != nil || string(converted) != out.String() {
+					fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+					t.Fatalf("Convert and ConvertStream disagree: %s vs %s (%v)", converted, out.String(), err)
+				}
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+// itemReader generates <root><item>..</item>...</root> without holding the document
+type itemReader struct {
+	n, next int
+	buf     bytes.Buffer
+}
+
+func (r *itemReader) Read(p []byte) (int, error) {
+	for r.buf.Len() < len(p) && r.next <= r.n {
+		switch {
+		case r.next == 0:
+			r.buf.WriteString("<root>")
+		case r.next == r.n:
+			r.buf.WriteString("</root>")
+		default:
+			fmt.Fprintf(&r.buf, `<item id="%d"><name>item%d</name></item>`, r.next, r.next)
+		}
+		r.next++
+	}
+	if r.buf.Len() == 0 {
+		return 0, io.EOF
+	}
+	return r.buf.Read(p)
+}
+
+func TestConvertStreamLargeDocument(t *testing.T) {
+	const items = 100000
+
+	var out bytes.Buffer
+	if err := ConvertStream(&itemReader{n: items + 1}, &out, Options{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var doc struct {
+		Root struct {
+			Item []map[string]string `json:"item"`
+		} `json:"root"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(doc.Root.Item) != items {
+		t.Fatalf("Expected %d items, got %d", items, len(doc.Root.Item))
+	}
+	if last := doc.Root.Item[items-1]; last["@id"] != fmt.Sprint(items) || last["name"] != fmt.Sprintf("item%d", items) {
+		t.Errorf("Unexpected last item: %v", last)
+	}
+}
@@ -0,0 +1,548 @@
+// Package xmljson converts arbitrary XML documents to JSON without predeclared structs.
+//
+// Elements become JSON objects keyed by element name, repeated sibling elements become
+// arrays, attributes are stored under a configurable prefix and character data of
+// elements that also carry attributes or children is stored under a text key.
+// Elements with neither attributes nor children are converted to plain strings.
+package xmljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Default option values
+const (
+	DefaultAttrPrefix = "@"
+	DefaultTextKey    = "#text"
+	DefaultMaxDepth   = 1000
+)
+
+// ErrNoRoot is returned when the input contains no XML element
+var ErrNoRoot = errors.New("xmljson: document has no root element")
+
+// ErrMaxDepth is returned when elements nest deeper than Options.MaxDepth
+var ErrMaxDepth = errors.New("xmljson: maximum element depth exceeded")
+
+var errMultipleRoots = errors.New("xmljson: document has more than one root element")
+
+// Options controls how XML is mapped to JSON. The zero value uses the defaults.
+type Options struct {
+	// AttrPrefix is prepended to attribute names to tell them apart from child elements
+	AttrPrefix string
+	// TextKey holds the character data of elements that also have attributes or children
+	TextKey string
+	// ForceList lists element paths that always serialize as arrays, even when the
+	// element occurs once. Paths are element names joined by dots starting at the
+	// root, e.g. "root.item".
+	ForceList []string
+	// Select converts only the elements at this path instead of the whole document,
+	// e.g. "Envelope.Body.GetQuoteResponse" to unwrap a SOAP response. Steps may be
+	// "*" for any element and carry a 1-based position such as "item[2]", the XPath
+	// form "/Envelope/Body/*" is accepted as well. The selected elements become the
+	// top-level members of the output, following the rules for children. Paths in the
+	// other options still start at the document root.
+	Select string
+	// StreamPath is the path of the repeated element that ConvertStream emits one at
+	// a time, e.g. "feed.entries.entry". It is always serialized as an array.
+	StreamPath string
+	// MaxDepth limits how deep elements may nest, the root element has depth 1.
+	// It guards against pathological inputs, zero means DefaultMaxDepth.
+	MaxDepth int
+	// InferTypes converts text that looks like a number or a boolean to the native
+	// JSON type and empty elements to null
+	InferTypes bool
+	// StringPaths lists element paths, or attribute paths such as "root.item.@id",
+	// whose values stay strings when InferTypes is set, e.g. zip codes. Attribute
+	// paths always use "@", whatever AttrPrefix is.
+	StringPaths []string
+	// Schema declares cardinality and types by path, typically built by ParseXSD.
+	// Declared types take precedence over InferTypes and StringPaths.
+	Schema Schema
+	// Namespaces selects how namespaced names are written, by default prefixes are stripped.
+	// Paths in the other options use the names as they appear in the JSON.
+	Namespaces NamespaceMode
+	// NamespaceAliases maps namespace URIs to the prefixes used with NamespaceAlias
+	NamespaceAliases map[string]string
+	// AttributesKey, when set, groups the attributes of an element in an object under
+	// this key (e.g. "_attributes") with unprefixed names instead of using AttrPrefix
+	AttributesKey string
+	// ContentKey, when set (e.g. "#content"), writes elements mixing text and child
+	// elements as an array under this key keeping the document order: text as strings
+	// and every child as an object with a single member, "a <b>bold</b> move" becomes
+	// {"#content":["a ",{"b":"bold"}," move"]}. Attributes stay members of the element.
+	// Without it the text of mixed elements is joined under TextKey.
+	ContentKey string
+	// CDataKey, when set (e.g. "#cdata"), keeps CDATA sections verbatim, whitespace
+	// included, under this key instead of merging them into the text of the element
+	CDataKey string
+	// Canonical produces byte-stable output for diffing and hashing: object keys are
+	// sorted by byte order and runs of whitespace inside text collapse to one space, so
+	// documents differing only in attribute order, the order of differently named
+	// siblings or formatting convert identically. Arrays keep document order and
+	// numbers keep their lexical form. Streaming does not support it.
+	Canonical bool
+	// Lenient skips malformed nodes instead of failing: unparsable markup is dropped and
+	// unclosed or mismatched tags are closed, each reported as a warning by
+	// ConvertWithWarnings. Unknown entities are kept as text. Streaming does not support it.
+	Lenient bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.AttrPrefix == "" {
+		o.AttrPrefix = DefaultAttrPrefix
+	}
+	if o.TextKey == "" {
+		o.TextKey = DefaultTextKey
+	}
+	if o.MaxDepth <= 0 {
+		o.MaxDepth = DefaultMaxDepth
+	}
+	return o
+}
+
+// Convert maps an XML document to JSON. Errors in the document are reported as *Error.
+func Convert(data []byte, opts Options) ([]byte, error) {
+	result, _, err := ConvertWithWarnings(data, opts)
+	return result, err
+}
+
+// ConvertWithWarnings is Convert that also returns the repairs made in lenient mode
+func ConvertWithWarnings(data []byte, opts Options) ([]byte, []*Error, error) {
+	opts = opts.withDefaults()
+
+	p := newParser(xml.NewDecoder(bytes.NewReader(data)), opts)
+	if opts.CDataKey != "" {
+		p.source = dataSource(data)
+	}
+	if opts.Lenient {
+		p.recoverFrom(data)
+	}
+
+	conv := newConverter(opts)
+	doc := newObject()
+	if opts.Select == "" {
+		root, err := p.document()
+		if err != nil {
+			return nil, p.warnings(), err
+		}
+		doc.set(root.name, conv.value(root, root.name))
+	} else if err := p.selection(conv, doc); err != nil {
+		return nil, p.warnings(), err
+	}
+	if opts.Canonical {
+		doc.sort()
+	}
+
+	result, err := marshal(doc)
+	return result, p.warnings(), err
+}
+
+// selection adds the elements matched by Options.Select to doc
+func (p *parser) selection(conv *converter, doc *object) error {
+	sel, err := parseSelector(conv.opts.Select)
+	if err != nil {
+		return err
+	}
+
+	err = p.selectElements(sel, func(el *element, path string, depth int) error {
+		if err := p.content(el, depth); err != nil {
+			return err
+		}
+		v := conv.value(el, path)
+		if _, exists := doc.values[el.name]; !exists && conv.forceList[path] {
+			v = []interface{}{v}
+		}
+		doc.add(el.name, v)
+		return nil
+	})
+	if err == nil && len(doc.keys) == 0 {
+		err = ErrNoMatch
+	}
+	return err
+}
+
+// element is the in-memory form of a decoded XML element
+type element struct {
+	name     string
+	attrs    []attribute
+	children []*element
+	text     strings.Builder
+	cdata    strings.Builder
+	// content holds the text, CDATA sections and children in document order
+	// when Options.ContentKey is set
+	content []interface{}
+}
+
+// attribute is an attribute with its name converted according to Options.Namespaces
+type attribute struct {
+	name  string
+	value string
+}
+
+// parser builds elements from a decoder, refusing to nest deeper than maxDepth
+type parser struct {
+	dec      *xml.Decoder
+	maxDepth int
+	ns       *namespaces
+	// names holds the converted names of the open elements, for error paths
+	names []string
+	// rec repairs malformed input in lenient mode, nil otherwise
+	rec *recovery
+	// source is set when CDATA sections are kept apart from text, inCDATA then
+	// reports whether the last token was one
+	source  rawSource
+	inCDATA bool
+	// ordered keeps the content of elements in document order for mixed content
+	ordered bool
+}
+
+func newParser(dec *xml.Decoder, opts Options) *parser {
+	return &parser{dec: dec, maxDepth: opts.MaxDepth, ns: newNamespaces(opts), ordered: opts.ContentKey != ""}
+}
+
+// document decodes the single root element of a document
+func (p *parser) document() (*element, error) {
+	var root *element
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == nil {
+			if root, err = p.element(start, 1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if p.rec == nil {
+			return nil, p.errorf(errMultipleRoots)
+		}
+		p.warn(errMultipleRoots)
+		if _, err := p.element(start, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	if root == nil {
+		return nil, ErrNoRoot
+	}
+	return root, nil
+}
+
+// element reads the content of start up to and including its end tag. depth is
+// the nesting level of start, the root element has depth 1.
+func (p *parser) element(start xml.StartElement, depth int) (*element, error) {
+	el, err := p.open(start, depth)
+	if err != nil {
+		return nil, err
+	}
+	return el, p.content(el, depth)
+}
+
+// open converts the start tag and brings its namespace declarations into scope
+func (p *parser) open(start xml.StartElement, depth int) (*element, error) {
+	if depth > p.maxDepth {
+		return nil, p.errorf(fmt.Errorf("%w (%d)", ErrMaxDepth, p.maxDepth))
+	}
+
+	p.ns.push(start.Attr)
+	el := &element{name: p.ns.name(start.Name)}
+	for _, attr := range start.Attr {
+		if name, ok := p.ns.attrName(attr); ok {
+			el.attrs = append(el.attrs, attribute{name: name, value: attr.Value})
+		}
+	}
+
+	p.names = append(p.names, el.name)
+	if p.rec != nil {
+		p.rec.opens = append(p.rec.opens, start.Copy())
+	}
+	return el, nil
+}
+
+// close ends the innermost open element
+func (p *parser) close() {
+	p.ns.pop()
+	p.names = p.names[:len(p.names)-1]
+	if p.rec != nil {
+		p.rec.opens = p.rec.opens[:len(p.rec.opens)-1]
+	}
+}
+
+// content reads the children and text of an opened element up to its end tag
+func (p *parser) content(el *element, depth int) error {
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			return p.errorf(io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := p.element(t, depth+1)
+			if err != nil {
+				return err
+			}
+			el.children = append(el.children, child)
+			if p.ordered {
+				el.content = append(el.content, child)
+			}
+		case xml.CharData:
+			if p.inCDATA {
+				el.cdata.Write(t)
+				if p.ordered {
+					el.content = append(el.content, cdataSection(t))
+				}
+				continue
+			}
+			el.text.Write(t)
+			if p.ordered {
+				el.content = append(el.content, string(t))
+			}
+		case xml.EndElement:
+			p.close()
+			return nil
+		}
+	}
+}
+
+type converter struct {
+	opts        Options
+	forceList   map[string]bool
+	stringPaths map[string]bool
+	types       map[string]ValueType
+}
+
+func newConverter(opts Options) *converter {
+	c := &converter{
+		opts:        opts,
+		forceList:   make(map[string]bool),
+		stringPaths: make(map[string]bool),
+		types:       make(map[string]ValueType),
+	}
+	for _, path := range opts.ForceList {
+		c.forceList[path] = true
+	}
+	for _, path := range opts.StringPaths {
+		c.stringPaths[path] = true
+	}
+	if opts.StreamPath != "" {
+		c.forceList[opts.StreamPath] = true
+	}
+	for path, hint := range opts.Schema {
+		if hint.List {
+			c.forceList[path] = true
+		}
+		if hint.Type != "" {
+			c.types[path] = hint.Type
+		}
+	}
+	return c
+}
+
+// value converts an element to a string or an object. path is the dot separated
+// chain of element names from the root, e.g. "root.item".
+func (c *converter) value(el *element, path string) interface{} {
+	text := c.text(el.text.String())
+	if len(el.attrs) == 0 && len(el.children) == 0 && el.cdata.Len() == 0 {
+		return c.leaf(text, path)
+	}
+
+	obj := newObject()
+	attrs := c.attributes(el.attrs, path)
+	for _, key := range attrs.keys {
+		obj.set(key, attrs.values[key])
+	}
+	if c.isMixed(el) {
+		obj.set(c.opts.ContentKey, c.content(el, path))
+		if c.opts.Canonical {
+			obj.sort()
+		}
+		return obj
+	}
+	for _, child := range el.children {
+		childPath := path + "." + child.name
+		v := c.value(child, childPath)
+		if _, exists := obj.values[child.name]; !exists && c.forceList[childPath] {
+			v = []interface{}{v}
+		}
+		obj.add(child.name, v)
+	}
+	if text != "" {
+		obj.set(c.opts.TextKey, c.scalar(text, path))
+	}
+	if el.cdata.Len() > 0 {
+		obj.set(c.opts.CDataKey, el.cdata.String())
+	}
+	if c.opts.Canonical {
+		obj.sort()
+	}
+	return obj
+}
+
+// text trims character data, in canonical mode inner whitespace is collapsed as well
+func (c *converter) text(s string) string {
+	if c.opts.Canonical {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	return strings.TrimSpace(s)
+}
+
+// attributes returns the members an element's attributes add to its object, either
+// prefixed keys or a single object under Options.AttributesKey
+func (c *converter) attributes(attrs []attribute, path string) *object {
+	obj := newObject()
+	for _, attr := range attrs {
+		key := c.opts.AttrPrefix + attr.name
+		value := c.scalar(attr.value, path+".@"+attr.name)
+		if c.opts.AttributesKey != "" {
+			key = attr.name
+		}
+		obj.set(key, value)
+	}
+	if c.opts.AttributesKey == "" || len(obj.keys) == 0 {
+		return obj
+	}
+
+	if c.opts.Canonical {
+		obj.sort()
+	}
+	nested := newObject()
+	nested.set(c.opts.AttributesKey, obj)
+	return nested
+}
+
+// jsonNumber matches the JSON number grammar, other numeric looking text such as
+// "007" or "1e" stays a string
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+func (c *converter) infers(path string) bool {
+	return c.opts.InferTypes && !c.stringPaths[path]
+}
+
+// leaf converts the text of an element without attributes or children
+func (c *converter) leaf(text, path string) interface{} {
+	if text != "" {
+		return c.scalar(text, path)
+	}
+	if t, ok := c.types[path]; ok {
+		if t == TypeString {
+			return text
+		}
+		return nil
+	}
+	if c.infers(path) {
+		return nil
+	}
+	return text
+}
+
+// scalar converts text to a JSON number or boolean when the schema declares it or
+// types are inferred for path
+func (c *converter) scalar(text, path string) interface{} {
+	if t, ok := c.types[path]; ok {
+		return t.convert(text)
+	}
+	if !c.infers(path) {
+		return text
+	}
+	switch text {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if jsonNumber.MatchString(text) {
+		return json.Number(text)
+	}
+	return text
+}
+
+// object is a JSON object that keeps keys in document order
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+func (o *object) set(key string, v interface{}) {
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// add stores v under key, turning the value into an array when the key repeats
+func (o *object) add(key string, v interface{}) {
+	existing, exists := o.values[key]
+	if !exists {
+		o.set(key, v)
+		return
+	}
+	if list, ok := existing.([]interface{}); ok {
+		o.values[key] = append(list, v)
+		return
+	}
+	o.values[key] = []interface{}{existing, v}
+}
+
+// sort orders the keys by byte order
+func (o *object) sort() {
+	sort.Strings(o.keys)
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshal encodes v without HTML escaping, XML content is full of '<' and '&'
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,187 @@
+package xmljson
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	var totalTests, passedTests int
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+		wantErr  bool
+	}{
+		{
+			name: "Repeated Elements Become Arrays",
+			input: `<root>
+                <item><name>item1</name><value>value1</value></item>
+                <item><name>item2</name><value>value2</value></item>
+            </root>`,
+			expected: `{"root":{"item":[{"name":"item1","value":"value1"},{"name":"item2","value":"value2"}]}}`,
+		},
+		{
+			name:     "Single Element Stays An Object",
+			input:    `<root><item><name>item1</name></item></root>`,
+			expected: `{"root":{"item":{"name":"item1"}}}`,
+		},
+		{
+			name:     "Attributes And Text",
+			input:    `<book id="7" lang="en"><title>Go</title><price currency="EUR">12.50</price></book>`,
+			expected: `{"book":{"@id":"7","@lang":"en","title":"Go","price":{"@currency":"EUR","#text":"12.50"}}}`,
+		},
+		{
+			name:     "Custom Attribute Prefix And Text Key",
+			input:    `<price currency="EUR">12.50</price>`,
+			opts:     Options{AttrPrefix: "-", TextKey: "value"},
+			expected: `{"price":{"-currency":"EUR","value":"12.50"}}`,
+		},
+		{
+			name:     "Document Order Is Preserved",
+			input:    `<r><z>1</z><a>2</a><m>3</m></r>`,
+			expected: `{"r":{"z":"1","a":"2","m":"3"}}`,
+		},
+		{
+			name:     "Empty Elements",
+			input:    `<root><empty/><blank></blank></root>`,
+			expected: `{"root":{"empty":"","blank":""}}`,
+		},
+		{
+			name:     "Special Characters Are Not HTML Escaped",
+			input:    `<root><name>special&amp;</name><value>&lt;test&gt;</value></root>`,
+			expected: `{"root":{"name":"special&","value":"<test>"}}`,
+		},
+		{
+			name:     "Prolog And Comments Are Ignored",
+			input:    `<?xml version="1.0"?><!-- note --><root><a>1</a></root>`,
+			expected: `{"root":{"a":"1"}}`,
+		},
+		{
+			name:     "Force List On Single Occurrence",
+			input:    `<root><item><name>item1</name></item><meta>x</meta></root>`,
+			opts:     Options{ForceList: []string{"root.item"}},
+			expected: `{"root":{"item":[{"name":"item1"}],"meta":"x"}}`,
+		},
+		{
+			name:     "Force List With Multiple Occurrences",
+			input:    `<root><item>a</item><item>b</item></root>`,
+			opts:     Options{ForceList: []string{"root.item"}},
+			expected: `{"root":{"item":["a","b"]}}`,
+		},
+		{
+			name:     "Force List Matches Full Path Only",
+			input:    `<root><item><tag>t</tag></item><tag>u</tag></root>`,
+			opts:     Options{ForceList: []string{"root.item.tag"}},
+			expected: `{"root":{"item":{"tag":["t"]},"tag":"u"}}`,
+		},
+		{
+			name:     "Nested Elements Inside Values",
+			input:    `<root><item><name>a</name><value><item><name>b</name></item><item><name>c</name></item></value></item></root>`,
+			expected: `{"root":{"item":{"name":"a","value":{"item":[{"name":"b"},{"name":"c"}]}}}}`,
+		},
+		{
+			name:     "Depth Within Limit",
+			input:    `<a><b><c>deep</c></b></a>`,
+			opts:     Options{MaxDepth: 3},
+			expected: `{"a":{"b":{"c":"deep"}}}`,
+		},
+		{
+			name:    "Depth Limit Exceeded",
+			input:   `<a><b><c><d>deep</d></c></b></a>`,
+			opts:    Options{MaxDepth: 3},
+			wantErr: true,
+		},
+		{
+			name:    "Default Depth Limit",
+			input:   strings.Repeat("<n>", DefaultMaxDepth+1) + strings.Repeat("</n>", DefaultMaxDepth+1),
+			wantErr: true,
+		},
+		{
+			name:     "Strings By Default",
+			input:    `<r><n>42</n><b>true</b><e/></r>`,
+			expected: `{"r":{"n":"42","b":"true","e":""}}`,
+		},
+		{
+			name:     "Type Inference",
+			input:    `<r id="3"><int>-42</int><float>12.50</float><exp>1e3</exp><t>true</t><f>false</f><e/><s>True</s><lead>007</lead><v unit="cm">1.5</v></r>`,
+			opts:     Options{InferTypes: true},
+			expected: `{"r":{"@id":3,"int":-42,"float":12.50,"exp":1e3,"t":true,"f":false,"e":null,"s":"True","lead":"007","v":{"@unit":"cm","#text":1.5}}}`,
+		},
+		{
+			name:     "Type Inference With String Paths",
+			input:    `<address code="01"><zip>10115</zip><number>7</number><zip2 code="12345"/></address>`,
+			opts:     Options{InferTypes: true, StringPaths: []string{"address.zip", "address.zip2.@code"}},
+			expected: `{"address":{"@code":"01","zip":"10115","number":7,"zip2":{"@code":"12345"}}}`,
+		},
+		{
+			name: "Canonical Mode",
+			input: `<r z="1" a="2"><b>x</b><a>  two
+			    words </a><b>y</b><c/>text</r>`,
+			opts:     Options{Canonical: true},
+			expected: `{"r":{"#text":"text","@a":"2","@z":"1","a":"two words","b":["x","y"],"c":""}}`,
+		},
+		{
+			name:     "Canonical Mode With Attributes Key",
+			input:    `<r z="1" a="2"/>`,
+			opts:     Options{Canonical: true, AttributesKey: "_attributes"},
+			expected: `{"r":{"_attributes":{"a":"2","z":"1"}}}`,
+		},
+		{
+			name:    "Invalid XML",
+			input:   `<root><item><name>test</root>`,
+			wantErr: true,
+		},
+		{
+			name:    "Empty Input",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "Multiple Roots",
+			input:   `<a/><b/>`,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		totalTests++
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Convert([]byte(tt.input), tt.opts)
+
+			if tt.wantErr != (err != nil) {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected error: %v, got error: %v", tt.wantErr, err)
+			}
+
+			if !tt.wantErr && string(result) != tt.expected {
+				fmt.Printf("Test %d# %s (Failed)\n", i+1, tt.name)
+				t.Fatalf("Expected: %s\nGot: %s", tt.expected, string(result))
+			}
+			passedTests++
+			fmt.Printf("Test %d# %s (Passed)\n", i+1, tt.name)
+		})
+	}
+	fmt.Printf("\nTests Passed %d/%d\n", passedTests, totalTests)
+}
+
+func TestCanonicalIsByteStable(t *testing.T) {
+	docs := []string{
+		`<order id="7" status="open"><total>12.50</total><customer>Jane  Doe</customer><line sku="a"/><line sku="b"/></order>`,
+		"<order status=\"open\" id=\"7\">\n  <customer>\n    Jane Doe\n  </customer>\n  <line sku=\"a\"/>\n  <line sku=\"b\"/>\n  <total>12.50</total>\n</order>\n",
+	}
+
+	var results []string
+	for _, doc := range docs {
+		result, err := Convert([]byte(doc), Options{Canonical: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		results = append(results, string(result))
+	}
+	if results[0] != results[1] {
+		t.Errorf("Expected identical output:\n%s\n%s", results[0], results[1])
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/health"
 	"database/sql"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
@@ -35,6 +37,14 @@ func NewApplication() (*Application, error) {
 }
 
 func (app *Application) routes() {
+	app.Router.Use(bodylimit.MaxBytes(bodylimit.DefaultMaxBytes), bodylimit.RequireContentType("application/json"))
+
+	app.Router.HandleFunc("/healthz", health.Liveness).Methods("GET")
+	app.Router.HandleFunc("/readyz", health.Readiness(health.DefaultTimeout, map[string]health.Check{
+		"database": health.Ping(app.DB),
+	})).Methods("GET")
+	app.Router.HandleFunc("/version", health.VersionHandler).Methods("GET")
+
 	app.Router.HandleFunc("/register", app.registerHandler).Methods("POST")
 	app.Router.HandleFunc("/login", app.loginHandler).Methods("POST")
 	app.Router.HandleFunc("/users", app.authMiddleware(app.listUsersHandler)).Methods("GET")
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/testkit"
+)
+
+func TestBodyLimits(t *testing.T) {
+	app, _ := setupTestApp(t)
+	large := `{"username":"` + strings.Repeat("a", bodylimit.DefaultMaxBytes) + `"}`
+
+	req := testkit.NewRequest("POST", "/register", strings.NewReader(large))
+	req.Header.Set("Content-Type", "application/json")
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, req), http.StatusRequestEntityTooLarge); err != nil {
+		t.Errorf("declared length: %v", err)
+	}
+
+	// a chunked body is only cut off while the handler reads it
+	req = testkit.NewRequest("POST", "/login", io.NopCloser(strings.NewReader(large)))
+	req.Header.Set("Content-Type", "application/json")
+	req.ContentLength = -1
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, req), http.StatusRequestEntityTooLarge); err != nil {
+		t.Errorf("chunked: %v", err)
+	}
+
+	req = testkit.NewRequest("POST", "/register", strings.NewReader("username=a"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, req), http.StatusUnsupportedMediaType); err != nil {
+		t.Errorf("form: %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"awesomeProject/platform/bodylimit"
+	"awesomeProject/platform/errs"
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"net/http"
@@ -18,10 +20,24 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// decodeJSON decodes the body of r into v. It answers 413 for a body cut off by the
+// size limit and 400 for a malformed one, and returns false then.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+	if tooLarge := bodylimit.Exceeded(err); tooLarge != nil {
+		errs.Write(w, r, tooLarge)
+		return false
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+	return false
+}
+
 func (app *Application) registerHandler(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -42,8 +58,7 @@ func (app *Application) registerHandler(w http.ResponseWriter, r *http.Request)
 
 func (app *Application) loginHandler(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -2,7 +2,16 @@
 package main
 
 import (
+    "awesomeProject/platform/bodylimit"
+    "awesomeProject/platform/health"
+    "awesomeProject/platform/idempotency"
+    "awesomeProject/platform/logging"
+    "awesomeProject/platform/ratelimit"
+    "awesomeProject/platform/telemetry"
     "database/sql"
+    "expvar"
+    "log/slog"
+    "time"
     "github.com/gin-gonic/gin"
     "github.com/gin-contrib/sessions"
     "github.com/gin-contrib/sessions/cookie"
@@ -10,46 +19,105 @@ import (
 )
 
 type Application struct {
-    DB      *sql.DB
-    Router  *gin.Engine
-    UserSvc UserService
+    DB       *sql.DB
+    Router   *gin.Engine
+    UserSvc  UserService
+    Webhooks *WebhookDispatcher
+    Tokens   TokenStore
+    // Admins are the usernames allowed to manage the webhooks
+    Admins []string
+    // SCIMToken is the bearer token of the identity providers using the SCIM API,
+    // which is closed while it is empty
+    SCIMToken string
+    // Idempotency keeps the responses to the user creations for their retries, in
+    // memory when nil
+    Idempotency idempotency.Store
 }
 
-func NewApplication() (*Application, error) {
-    db, err := sql.Open("mysql", "root:password@tcp(localhost:3306)/crud_db?parseTime=true")
-    if err != nil {
-        return nil, err
-    }
-
-    if err := db.Ping(); err != nil {
-        return nil, err
-    }
+// signInLimit bounds the sign-ins and registrations per client address and minute
+const signInLimit = 10
 
-    router := gin.Default()
+// NewApplication wires the services and routes around an open database
+func NewApplication(db *sql.DB) *Application {
+    router := gin.New()
+    router.Use(gin.Recovery(), logging.Gin(slog.Default()), telemetry.Gin())
     store := cookie.NewStore([]byte("your-secret-key"))
     router.Use(sessions.Sessions("mysession", store))
 
+    webhooks := NewWebhookDispatcher(NewWebhookStore(db))
     app := &Application{
-        DB:      db,
-        Router:  router,
-        UserSvc: &SQLUserService{db: db},
+        DB:       db,
+        Router:   router,
+        UserSvc:  newWebhookUserService(NewUserService(db), webhooks),
+        Webhooks: webhooks,
+        Tokens:   NewTokenStore(db),
     }
 
     app.setupRoutes()
 
-    return app, nil
+    return app
 }
 
 func (app *Application) setupRoutes() {
-    app.Router.POST("/register", app.registerHandler)
-    app.Router.POST("/login", app.loginHandler)
+    // The API takes JSON bodies, SCIM clients label theirs with the SCIM media type
+    app.Router.Use(
+        bodylimit.GinMaxBytes(bodylimit.DefaultMaxBytes),
+        bodylimit.GinRequireContentType("application/json", scimContentType),
+    )
+
+    app.Router.GET("/healthz", gin.WrapF(health.Liveness))
+    app.Router.GET("/readyz", gin.WrapF(health.Readiness(health.DefaultTimeout, map[string]health.Check{
+        "database": health.Ping(app.DB),
+    })))
+    app.Router.GET("/version", gin.WrapF(health.VersionHandler))
+
+    // Retried creations with an Idempotency-Key get the first response
+    idempotent := idempotency.Gin(app.Idempotency, idempotency.DefaultTTL, idempotencyCaller)
+
+    // Against password guessing and sign-up floods, per client address
+    signIn := ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), Limit: signInLimit, Window: time.Minute}.Gin()
+
+    app.Router.POST("/register", signIn, idempotent, app.registerHandler)
+    app.Router.POST("/login", signIn, app.loginHandler)
 
     protected := app.Router.Group("/")
     protected.Use(app.authMiddleware())
     {
-        protected.GET("/users", app.listUsersHandler)
-        protected.GET("/users/:id", app.getUserHandler)
-        protected.PUT("/users/:id", app.updateUserHandler)
-        protected.DELETE("/users/:id", app.deleteUserHandler)
+        protected.GET("/users", app.requireScope(ScopeUsersRead), app.listUsersHandler)
+        protected.GET("/users/export", app.requireScope(ScopeUsersRead), app.exportUsersHandler)
+        protected.GET("/users/:id", app.requireScope(ScopeUsersRead), app.getUserHandler)
+        protected.PUT("/users/:id", app.requireScope(ScopeUsersWrite), app.updateUserHandler)
+        protected.DELETE("/users/:id", app.requireScope(ScopeUsersWrite), app.deleteUserHandler)
+    }
+
+    tokens := protected.Group("/tokens")
+    tokens.Use(app.sessionOnly())
+    {
+        tokens.POST("", app.createTokenHandler)
+        tokens.GET("", app.listTokensHandler)
+        tokens.DELETE("/:id", app.revokeTokenHandler)
+    }
+
+    // The expvars, with the query metrics
+    debug := protected.Group("/debug")
+    debug.Use(app.adminMiddleware())
+    debug.GET("/vars", gin.WrapH(expvar.Handler()))
+
+    admin := protected.Group("/webhooks")
+    admin.Use(app.requireScope(ScopeWebhooks), app.adminMiddleware())
+    {
+        admin.POST("", app.createWebhookHandler)
+        admin.GET("", app.listWebhooksHandler)
+        admin.DELETE("/:id", app.deleteWebhookHandler)
+        admin.GET("/:id/deliveries", app.webhookDeliveriesHandler)
+    }
+
+    scim := app.Router.Group("/scim/v2")
+    scim.Use(app.scimAuthMiddleware())
+    {
+        scim.GET("/Users", app.scimListUsersHandler)
+        scim.POST("/Users", idempotent, app.scimCreateUserHandler)
+        scim.GET("/Users/:id", app.scimGetUserHandler)
+        scim.PATCH("/Users/:id", app.scimPatchUserHandler)
     }
 }
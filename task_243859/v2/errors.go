@@ -1,11 +1,33 @@
 
 package main
 
-import "errors"
+import (
+    "awesomeProject/platform/bodylimit"
+    "awesomeProject/platform/errs"
+    "github.com/gin-gonic/gin"
+)
 
 var (
-    ErrUserNotFound      = errors.New("user not found")
-    ErrDuplicateUsername = errors.New("username already exists")
-    ErrDuplicateEmail    = errors.New("email already exists")
-    ErrInvalidCredentials = errors.New("invalid credentials")
+    ErrUserNotFound       = errs.New(errs.ErrNotFound, "User not found")
+    ErrDuplicateUsername  = errs.New(errs.ErrConflict, "Username already exists")
+    ErrDuplicateEmail     = errs.New(errs.ErrConflict, "Email already exists")
+    ErrInvalidCredentials = errs.New(errs.ErrUnauthorized, "Invalid credentials")
+    ErrInvalidUserID      = errs.New(errs.ErrInvalid, "Invalid user ID")
+    ErrNotAuthenticated   = errs.New(errs.ErrUnauthorized, "Unauthorized")
+    ErrNotAdmin           = errs.New(errs.ErrForbidden, "Admin access required")
 )
+
+// respondError writes err as problem details and stops the handler chain
+func respondError(c *gin.Context, err error) {
+    errs.Write(c.Writer, c.Request, err)
+    c.Abort()
+}
+
+// invalidBody is the error for a request body that doesn't bind, 413 when it was
+// cut off at the size limit
+func invalidBody(err error) error {
+    if tooLarge := bodylimit.Exceeded(err); tooLarge != nil {
+        return tooLarge
+    }
+    return errs.Wrap(errs.ErrInvalid, err.Error(), err)
+}
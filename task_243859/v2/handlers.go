@@ -2,8 +2,14 @@
 package main
 
 import (
+    "awesomeProject/platform/errs"
+    "awesomeProject/platform/logging"
+    "errors"
+    "fmt"
     "net/http"
+    "slices"
     "strconv"
+    "time"
     "github.com/gin-gonic/gin"
     "github.com/gin-contrib/sessions"
 )
@@ -11,19 +17,13 @@ import (
 func (app *Application) registerHandler(c *gin.Context) {
     var user User
     if err := c.ShouldBindJSON(&user); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, invalidBody(err))
         return
     }
 
+    user.Active = true
     if err := app.UserSvc.Create(&user); err != nil {
-        switch err {
-        case ErrDuplicateUsername:
-            c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-        case ErrDuplicateEmail:
-            c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-        default:
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-        }
+        respondError(c, err)
         return
     }
 
@@ -38,13 +38,13 @@ func (app *Application) loginHandler(c *gin.Context) {
     }
 
     if err := c.ShouldBindJSON(&credentials); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, invalidBody(err))
         return
     }
 
     user, err := app.UserSvc.Authenticate(credentials.Username, credentials.Password)
     if err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+        respondError(c, err)
         return
     }
 
@@ -52,7 +52,7 @@ func (app *Application) loginHandler(c *gin.Context) {
     session.Set("authenticated", true)
     session.Set("user_id", user.ID)
     if err := session.Save(); err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save session"})
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to save session", err))
         return
     }
 
@@ -62,28 +62,42 @@ func (app *Application) loginHandler(c *gin.Context) {
 func (app *Application) listUsersHandler(c *gin.Context) {
     users, err := app.UserSvc.List()
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to fetch users", err))
         return
     }
 
     c.JSON(http.StatusOK, users)
 }
 
+// exportUsersHandler downloads every user as one JSON document, without passwords
+func (app *Application) exportUsersHandler(c *gin.Context) {
+    users, err := app.UserSvc.List()
+    if err != nil {
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to fetch users", err))
+        return
+    }
+    if users == nil {
+        users = []User{}
+    }
+
+    c.Header("Content-Disposition", `attachment; filename="users.json"`)
+    c.JSON(http.StatusOK, gin.H{
+        "exported_at": time.Now().UTC(),
+        "count":       len(users),
+        "users":       users,
+    })
+}
+
 func (app *Application) getUserHandler(c *gin.Context) {
     id, err := strconv.Atoi(c.Param("id"))
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        respondError(c, ErrInvalidUserID)
         return
     }
 
     user, err := app.UserSvc.GetByID(id)
     if err != nil {
-        switch err {
-        case ErrUserNotFound:
-            c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        default:
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-        }
+        respondError(c, err)
         return
     }
 
@@ -93,28 +107,19 @@ func (app *Application) getUserHandler(c *gin.Context) {
 func (app *Application) updateUserHandler(c *gin.Context) {
     id, err := strconv.Atoi(c.Param("id"))
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        respondError(c, ErrInvalidUserID)
         return
     }
 
     var user User
     if err := c.ShouldBindJSON(&user); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        respondError(c, invalidBody(err))
         return
     }
 
     user.ID = id
     if err := app.UserSvc.Update(&user); err != nil {
-        switch err {
-        case ErrUserNotFound:
-            c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        case ErrDuplicateUsername:
-            c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
-        case ErrDuplicateEmail:
-            c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
-        default:
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
-        }
+        respondError(c, err)
         return
     }
 
@@ -124,28 +129,88 @@ func (app *Application) updateUserHandler(c *gin.Context) {
 func (app *Application) deleteUserHandler(c *gin.Context) {
     id, err := strconv.Atoi(c.Param("id"))
     if err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+        respondError(c, ErrInvalidUserID)
         return
     }
 
     if err := app.UserSvc.Delete(id); err != nil {
-        switch err {
-        case ErrUserNotFound:
-            c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        default:
-            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
-        }
+        respondError(c, err)
         return
     }
 
     c.Status(http.StatusOK)
 }
 
+// idempotencyCaller scopes the Idempotency-Key of a request to the user it acts for,
+// or to the client address on the routes open to everybody
+func idempotencyCaller(c *gin.Context) string {
+    if id, ok := c.Get(ctxUserID); ok {
+        return fmt.Sprint("user:", id)
+    }
+    return "ip:" + c.ClientIP()
+}
+
+// authMiddleware lets requests with an API token or a session of an active user
+// through and records the user they act for
 func (app *Application) authMiddleware() gin.HandlerFunc {
     return func(c *gin.Context) {
+        if token, ok := bearerToken(c); ok {
+            apiToken, err := app.authenticateToken(token)
+            if err != nil {
+                respondError(c, err)
+                return
+            }
+            c.Set(ctxUserID, apiToken.UserID)
+            c.Set(ctxTokenScopes, apiToken.Scopes)
+            logging.WithUserID(c.Request.Context(), strconv.Itoa(apiToken.UserID))
+            c.Next()
+            return
+        }
+
         session := sessions.Default(c)
-        if auth, _ := session.Get("authenticated").(bool); !auth {
-            c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+        id, ok := session.Get("user_id").(int)
+        if auth, _ := session.Get("authenticated").(bool); !auth || !ok {
+            respondError(c, ErrNotAuthenticated)
+            return
+        }
+        // Like API tokens, sessions end as soon as the user is deleted or deactivated
+        user, err := app.UserSvc.GetByID(id)
+        if errors.Is(err, ErrUserNotFound) || (err == nil && !user.Active) {
+            session.Clear()
+            session.Save()
+            respondError(c, ErrNotAuthenticated)
+            return
+        }
+        if err != nil {
+            respondError(c, err)
+            return
+        }
+        c.Set(ctxUserID, id)
+        logging.WithUserID(c.Request.Context(), strconv.Itoa(id))
+        c.Next()
+    }
+}
+
+// adminMiddleware lets the signed in users named in Admins through. It runs after
+// authMiddleware.
+func (app *Application) adminMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id, ok := currentUserID(c)
+        if !ok {
+            respondError(c, ErrNotAdmin)
+            return
+        }
+        user, err := app.UserSvc.GetByID(id)
+        if errors.Is(err, ErrUserNotFound) {
+            respondError(c, ErrNotAdmin)
+            return
+        }
+        if err != nil {
+            respondError(c, err)
+            return
+        }
+        if !slices.Contains(app.Admins, user.Username) {
+            respondError(c, ErrNotAdmin)
             return
         }
         c.Next()
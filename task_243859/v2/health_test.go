@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	app := &Application{DB: db, Router: gin.New(), UserSvc: NewMockUserService()}
+	app.setupRoutes()
+
+	for _, target := range []string{"/healthz", "/version"} {
+		if err := testkit.ExpectStatus(testkit.Serve(app.Router, testkit.NewRequest("GET", target, nil)), http.StatusOK); err != nil {
+			t.Errorf("%s: %v", target, err)
+		}
+	}
+
+	mock.ExpectPing()
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, testkit.NewRequest("GET", "/readyz", nil)), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, testkit.NewRequest("GET", "/readyz", nil)), http.StatusServiceUnavailable); err != nil {
+		t.Error(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -8,5 +8,6 @@ type UserService interface {
     List() ([]User, error)
     Update(user *User) error
     Delete(id int) error
+    SetActive(id int, active bool) error
     Authenticate(username, password string) (*User, error)
 }
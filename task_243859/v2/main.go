@@ -2,18 +2,84 @@
 package main
 
 import (
+    "awesomeProject/platform/app"
+    "awesomeProject/platform/config"
+    "awesomeProject/platform/cors"
+    "awesomeProject/platform/idempotency"
+    "awesomeProject/platform/logging"
+    "awesomeProject/platform/telemetry"
+    "context"
+    "database/sql"
+    "errors"
+    "expvar"
+    "flag"
     "log"
+    "log/slog"
+    "net/http"
+    "os"
 )
 
+// defaultDSN is the database of a local development setup
+const defaultDSN = "root:password@tcp(localhost:3306)/crud_db?parseTime=true"
+
 func main() {
-    app, err := NewApplication()
+    cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+    if err != nil {
+        log.Fatal(err)
+    }
+    dsn := cfg.String("mysql.dsn", defaultDSN)
+    addr := cfg.String("http.addr", app.DefaultAddr)
+    scimToken := cfg.String("scim.token", "")
+    admins := cfg.Strings("admin.users", nil)
+    origins := cfg.Strings("cors.origins", nil)
+    slowQuery = cfg.Duration("db.slow.query", slowQuery)
+    if slowQuery < 0 {
+        cfg.Check("db.slow.query", errors.New("must not be negative"))
+    }
+    logger := logging.FromConfig(cfg, "users")
+    if err := cfg.Err(); err != nil {
+        log.Fatal(err)
+    }
+    slog.SetDefault(logger)
+    expvar.Publish("db_queries", queryMetrics)
+    if scimToken == "" {
+        logger.Warn("scim.token is not set, the SCIM API is closed")
+    }
+    shutdownTelemetry, err := telemetry.Setup(context.Background(), "users")
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    // users is built with the router, before the background workers start
+    var users *Application
+    application, err := app.NewApplication(app.Options{
+        Name:   "users",
+        Addr:   addr,
+        Logger: logger,
+        OpenDB: func(context.Context) (*sql.DB, error) {
+            return sql.Open("mysql", dsn)
+        },
+        Router: func(a *app.Application) (http.Handler, error) {
+            users = NewApplication(a.DB)
+            users.SCIMToken = scimToken
+            users.Admins = admins
+            // browser apps of the origins set in cors.origins may call the API
+            return cors.Middleware(cors.Config{
+                AllowedOrigins: origins,
+                AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+                AllowedHeaders: []string{"Authorization", "Content-Type", logging.RequestIDHeader, idempotency.Header},
+            })(users.Router), nil
+        },
+        Background: []func(ctx context.Context){
+            func(ctx context.Context) { users.Webhooks.Run(ctx) },
+        },
+        OnStop: []app.Hook{shutdownTelemetry},
+    })
     if err != nil {
         log.Fatal(err)
     }
-    defer app.DB.Close()
 
-    log.Printf("Server starting on port 8080")
-    if err := app.Router.Run(":8080"); err != nil {
+    if err := application.Run(context.Background()); err != nil {
         log.Fatal(err)
     }
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	svc := NewUserService(db)
+	before := queryMetrics.Stats()
+
+	mock.ExpectQuery("SELECT id, username, email, active, created_at, updated_at FROM users").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "active", "created_at", "updated_at"}))
+	mock.ExpectExec("DELETE FROM users").WithArgs(1).WillReturnError(errors.New("lock wait timeout"))
+	if _, err := svc.GetByID(1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Expected the user not to be found, got %v", err)
+	}
+	if err := svc.Delete(1); err == nil {
+		t.Error("Expected the delete to fail")
+	}
+
+	after := queryMetrics.Stats()
+	if after.Queries-before.Queries != 2 || after.Errors-before.Errors != 1 {
+		t.Errorf("Expected 2 queries and 1 error, got %+v then %+v", before, after)
+	}
+	if after.Statements["select"]-before.Statements["select"] != 1 || after.Statements["delete"]-before.Statements["delete"] != 1 {
+		t.Errorf("Expected the statements to be counted, got %v", after.Statements)
+	}
+}
+
+func TestDebugVarsNeedAdmin(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	app.Admins = []string{"admin"}
+
+	for _, name := range []string{"admin", "bob"} {
+		user := &User{Username: name, Password: "password123", Email: name + "@example.com", Active: true}
+		if err := app.UserSvc.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(username string) *httptest.ResponseRecorder {
+		client := testkit.NewClient(app.Router)
+		client.Do(testkit.JSONRequest("POST", "/login", map[string]string{"username": username, "password": "password123"}))
+		return client.Do(testkit.NewRequest("GET", "/debug/vars", nil))
+	}
+
+	if err := testkit.ExpectStatus(get("bob"), http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+	w := get("admin")
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil || !strings.Contains(w.Body.String(), "memstats") {
+		t.Errorf("Expected the expvars, got %s (%v)", w.Body.String(), err)
+	}
+}
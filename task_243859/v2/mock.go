@@ -3,10 +3,16 @@ package main
 
 import (
 	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"sync"
 	"time"
 )
 
+// MockUserService keeps the users in memory. It is safe for concurrent use and
+// behaves like SQLUserService: users are listed by ID, and callers get copies, so
+// changing a returned or passed in user doesn't change the stored one.
 type MockUserService struct {
+	mu     sync.RWMutex
 	users  map[int]*User
 	nextID int
 }
@@ -19,36 +25,46 @@ func NewMockUserService() UserService {
 }
 
 func (m *MockUserService) Create(user *User) error {
-	// Check for duplicate username
+	// Hash password, before locking as it is slow
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Check for duplicate username, then email, like the database does
 	for _, existingUser := range m.users {
 		if existingUser.Username == user.Username {
 			return ErrDuplicateUsername
 		}
+	}
+	for _, existingUser := range m.users {
 		if existingUser.Email == user.Email {
 			return ErrDuplicateEmail
 		}
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return err
-	}
-
-	// Set user fields
+	// Set the generated fields, the caller keeps its password
+	now := time.Now()
 	user.ID = m.nextID
-	user.Password = string(hashedPassword)
-	user.CreatedAt = time.Now()
-	user.UpdatedAt = time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
 
-	// Store user
-	m.users[user.ID] = user
+	// Store a copy
+	stored := *user
+	stored.Password = string(hashedPassword)
+	m.users[user.ID] = &stored
 	m.nextID++
 
 	return nil
 }
 
 func (m *MockUserService) GetByID(id int) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	user, exists := m.users[id]
 	if !exists {
 		return nil, ErrUserNotFound
@@ -60,7 +76,11 @@ func (m *MockUserService) GetByID(id int) (*User, error) {
 	return &userCopy, nil
 }
 
+// GetByUsername returns the user with the password hash, like the SQL service
 func (m *MockUserService) GetByUsername(username string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, user := range m.users {
 		if user.Username == username {
 			userCopy := *user
@@ -71,16 +91,33 @@ func (m *MockUserService) GetByUsername(username string) (*User, error) {
 }
 
 func (m *MockUserService) List() ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	users := make([]User, 0, len(m.users))
 	for _, user := range m.users {
 		userCopy := *user
 		userCopy.Password = "" // Remove password from response
 		users = append(users, userCopy)
 	}
+	// Ordered by ID like the database does
+	slices.SortFunc(users, func(a, b User) int { return a.ID - b.ID })
 	return users, nil
 }
 
 func (m *MockUserService) Update(user *User) error {
+	var hashedPassword []byte
+	if user.Password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	existingUser, exists := m.users[user.ID]
 	if !exists {
 		return ErrUserNotFound
@@ -88,13 +125,13 @@ func (m *MockUserService) Update(user *User) error {
 
 	// Check for duplicate username/email with other users
 	for id, u := range m.users {
-		if id != user.ID {
-			if u.Username == user.Username {
-				return ErrDuplicateUsername
-			}
-			if u.Email == user.Email {
-				return ErrDuplicateEmail
-			}
+		if id != user.ID && u.Username == user.Username {
+			return ErrDuplicateUsername
+		}
+	}
+	for id, u := range m.users {
+		if id != user.ID && u.Email == user.Email {
+			return ErrDuplicateEmail
 		}
 	}
 
@@ -104,11 +141,7 @@ func (m *MockUserService) Update(user *User) error {
 	existingUser.UpdatedAt = time.Now()
 
 	// Update password if provided
-	if user.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
+	if hashedPassword != nil {
 		existingUser.Password = string(hashedPassword)
 	}
 
@@ -116,6 +149,9 @@ func (m *MockUserService) Update(user *User) error {
 }
 
 func (m *MockUserService) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if _, exists := m.users[id]; !exists {
 		return ErrUserNotFound
 	}
@@ -123,6 +159,19 @@ func (m *MockUserService) Delete(id int) error {
 	return nil
 }
 
+func (m *MockUserService) SetActive(id int, active bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, exists := m.users[id]
+	if !exists {
+		return ErrUserNotFound
+	}
+	user.Active = active
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
 func (m *MockUserService) Authenticate(username, password string) (*User, error) {
 	user, err := m.GetByUsername(username)
 	if err != nil {
@@ -130,7 +179,7 @@ func (m *MockUserService) Authenticate(username, password string) (*User, error)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
+	if err != nil || !user.Active {
 		return nil, ErrInvalidCredentials
 	}
 
@@ -138,6 +187,134 @@ func (m *MockUserService) Authenticate(username, password string) (*User, error)
 	return user, nil
 }
 
+type MockWebhookStore struct {
+	mu     sync.Mutex
+	hooks  map[int]*Webhook
+	nextID int
+}
+
+func NewMockWebhookStore() WebhookStore {
+	return &MockWebhookStore{
+		hooks:  make(map[int]*Webhook),
+		nextID: 1,
+	}
+}
+
+func (m *MockWebhookStore) Create(hook *Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hook.ID = m.nextID
+	hook.CreatedAt = time.Now()
+	hookCopy := *hook
+	m.hooks[hook.ID] = &hookCopy
+	m.nextID++
+	return nil
+}
+
+func (m *MockWebhookStore) List() ([]Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hooks := make([]Webhook, 0, len(m.hooks))
+	for _, hook := range m.hooks {
+		hooks = append(hooks, *hook)
+	}
+	slices.SortFunc(hooks, func(a, b Webhook) int { return a.ID - b.ID })
+	return hooks, nil
+}
+
+func (m *MockWebhookStore) Get(id int) (*Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hook, exists := m.hooks[id]
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	hookCopy := *hook
+	return &hookCopy, nil
+}
+
+func (m *MockWebhookStore) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.hooks[id]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(m.hooks, id)
+	return nil
+}
+
+type MockTokenStore struct {
+	mu     sync.Mutex
+	tokens map[int]*APIToken
+	hashes map[int]string
+	nextID int
+}
+
+func NewMockTokenStore() TokenStore {
+	return &MockTokenStore{
+		tokens: make(map[int]*APIToken),
+		hashes: make(map[int]string),
+		nextID: 1,
+	}
+}
+
+func (m *MockTokenStore) Create(token *APIToken, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = m.nextID
+	token.CreatedAt = time.Now()
+	tokenCopy := *token
+	m.tokens[token.ID] = &tokenCopy
+	m.hashes[token.ID] = hash
+	m.nextID++
+	return nil
+}
+
+func (m *MockTokenStore) List(userID int) ([]APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tokens []APIToken
+	for _, token := range m.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, *token)
+		}
+	}
+	slices.SortFunc(tokens, func(a, b APIToken) int { return a.ID - b.ID })
+	return tokens, nil
+}
+
+func (m *MockTokenStore) Lookup(hash string) (*APIToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, h := range m.hashes {
+		if h == hash {
+			tokenCopy := *m.tokens[id]
+			return &tokenCopy, nil
+		}
+	}
+	return nil, ErrTokenNotFound
+}
+
+func (m *MockTokenStore) Touch(id int, t time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token, exists := m.tokens[id]; exists {
+		token.LastUsedAt = &t
+	}
+	return nil
+}
+
+func (m *MockTokenStore) Delete(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token, exists := m.tokens[id]; !exists || token.UserID != userID {
+		return ErrTokenNotFound
+	}
+	delete(m.tokens, id)
+	delete(m.hashes, id)
+	return nil
+}
+
 // Add these helper functions to main_test.go
 func createTestUser(svc UserService) (*User, error) {
 	user := &User{
@@ -151,6 +328,8 @@ func createTestUser(svc UserService) (*User, error) {
 
 func clearMockData(svc UserService) {
 	if mockSvc, ok := svc.(*MockUserService); ok {
+		mockSvc.mu.Lock()
+		defer mockSvc.mu.Unlock()
 		mockSvc.users = make(map[int]*User)
 		mockSvc.nextID = 1
 	}
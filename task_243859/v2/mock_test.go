@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMockUserServiceConcurrency(t *testing.T) {
+	svc := NewMockUserService()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			user := &User{Username: fmt.Sprintf("user%d", i), Password: "password123", Email: fmt.Sprintf("user%d@example.com", i)}
+			if err := svc.Create(user); err != nil {
+				t.Error(err)
+				return
+			}
+			svc.List()
+			user.Email = fmt.Sprintf("user%d@example.org", i)
+			if err := svc.Update(user); err != nil {
+				t.Error(err)
+			}
+			svc.SetActive(user.ID, true)
+		}(i)
+	}
+	wg.Wait()
+
+	users, _ := svc.List()
+	if len(users) != 20 {
+		t.Fatalf("Expected 20 users, got %d", len(users))
+	}
+	for i, user := range users {
+		if user.ID != i+1 || !user.Active || user.Password != "" {
+			t.Errorf("Expected the users by ID without passwords, got %+v at %d", user, i)
+		}
+	}
+}
+
+func TestMockUserServiceCopies(t *testing.T) {
+	svc := NewMockUserService()
+	user := &User{Username: "alice", Password: "password123", Email: "alice@example.com", Active: true}
+	if err := svc.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 1 || user.Password != "password123" {
+		t.Errorf("Expected the ID to be set and the password kept, got %+v", user)
+	}
+
+	// The handlers clear the password of the created user, which mustn't reach the
+	// stored one
+	user.Password = ""
+	user.Username = "mallory"
+	got, _ := svc.GetByID(1)
+	got.Email = "changed@example.com"
+	if _, err := svc.Authenticate("alice", "password123"); err != nil {
+		t.Errorf("Expected the stored user to be unchanged, got %v", err)
+	}
+	if again, _ := svc.GetByID(1); again.Username != "alice" || again.Email != "alice@example.com" {
+		t.Errorf("Expected a copy, got %+v", again)
+	}
+
+	if err := svc.Create(&User{Username: "alice", Password: "x", Email: "alice@example.com"}); !errors.Is(err, ErrDuplicateUsername) {
+		t.Errorf("Expected the username to be checked first, got %v", err)
+	}
+}
@@ -5,11 +5,14 @@ import (
     "time"
 )
 
+// User is an account of the service. Inactive users can't sign in, Update leaves
+// Active alone and SetActive changes it.
 type User struct {
     ID        int       `json:"id"`
     Username  string    `json:"username" binding:"required"`
     Password  string    `json:"password,omitempty" binding:"required,min=6"`
     Email     string    `json:"email" binding:"required,email"`
+    Active    bool      `json:"active"`
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
 }
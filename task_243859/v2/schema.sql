@@ -0,0 +1,38 @@
+-- Schema of the users database (MySQL 8)
+
+CREATE TABLE IF NOT EXISTS users (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    username   VARCHAR(255) NOT NULL UNIQUE,
+    password   VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL UNIQUE,
+    active     BOOLEAN      NOT NULL DEFAULT TRUE,
+    created_at DATETIME     NOT NULL,
+    updated_at DATETIME     NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    url        VARCHAR(2048) NOT NULL,
+    secret     VARCHAR(255)  NOT NULL,
+    -- Comma separated event types
+    events     VARCHAR(255)  NOT NULL,
+    created_at DATETIME      NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id           INT AUTO_INCREMENT PRIMARY KEY,
+    user_id      INT          NOT NULL,
+    name         VARCHAR(255) NOT NULL,
+    -- Hex SHA-256 of the token, which isn't stored
+    token_hash   CHAR(64)     NOT NULL UNIQUE,
+    prefix       VARCHAR(16)  NOT NULL,
+    -- Comma separated scopes
+    scopes       VARCHAR(255) NOT NULL,
+    expires_at   DATETIME     NOT NULL,
+    last_used_at DATETIME,
+    created_at   DATETIME     NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+-- Databases created before users could be deactivated:
+-- ALTER TABLE users ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,371 @@
+
+package main
+
+import (
+    "awesomeProject/platform/errs"
+    "crypto/subtle"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/gin-gonic/gin"
+)
+
+// The minimal SCIM 2.0 (RFC 7643, RFC 7644) subset identity providers need to
+// provision users: creating them, looking them up by userName and deactivating them.
+
+const (
+    scimContentType = "application/scim+json"
+    scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+    scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+    scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+    scimMaxPageSize = 100
+)
+
+var (
+    ErrSCIMUnauthorized  = errs.New(errs.ErrUnauthorized, "Invalid SCIM token")
+    ErrSCIMInvalidFilter = errs.New(errs.ErrInvalid, `Only filters of the form userName eq "value" are supported`)
+    ErrSCIMInvalidPath   = errs.New(errs.ErrInvalid, "Only the active attribute can be patched")
+    ErrSCIMInvalidValue  = errs.New(errs.ErrInvalid, "Invalid attribute value")
+    ErrSCIMNoEmail       = errs.New(errs.ErrInvalid, "An email address is required")
+)
+
+// scimTypes are the scimType error details of the errors that have one
+var scimTypes = []struct {
+    err      error
+    scimType string
+}{
+    {ErrSCIMInvalidFilter, "invalidFilter"},
+    {ErrSCIMInvalidPath, "invalidPath"},
+    {ErrSCIMInvalidValue, "invalidValue"},
+    {ErrSCIMNoEmail, "invalidValue"},
+    {errs.ErrConflict, "uniqueness"},
+}
+
+// scimFilter matches the only filter supported, userName eq "value"
+var scimFilter = regexp.MustCompile(`(?i)^\s*userName\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+type scimEmail struct {
+    Value   string `json:"value"`
+    Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+    ResourceType string    `json:"resourceType"`
+    Created      time.Time `json:"created"`
+    LastModified time.Time `json:"lastModified"`
+    Location     string    `json:"location"`
+}
+
+// scimUser is the SCIM representation of a user
+type scimUser struct {
+    Schemas  []string    `json:"schemas"`
+    ID       string      `json:"id,omitempty"`
+    UserName string      `json:"userName"`
+    Password string      `json:"password,omitempty"`
+    Active   *bool       `json:"active,omitempty"`
+    Emails   []scimEmail `json:"emails,omitempty"`
+    Meta     *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimListResponse struct {
+    Schemas      []string   `json:"schemas"`
+    TotalResults int        `json:"totalResults"`
+    StartIndex   int        `json:"startIndex"`
+    ItemsPerPage int        `json:"itemsPerPage"`
+    Resources    []scimUser `json:"Resources"`
+}
+
+type scimPatchRequest struct {
+    Schemas    []string `json:"schemas"`
+    Operations []struct {
+        Op    string          `json:"op"`
+        Path  string          `json:"path"`
+        Value json.RawMessage `json:"value"`
+    } `json:"Operations"`
+}
+
+type scimErrorResponse struct {
+    Schemas  []string `json:"schemas"`
+    Status   string   `json:"status"`
+    ScimType string   `json:"scimType,omitempty"`
+    Detail   string   `json:"detail,omitempty"`
+}
+
+func toSCIMUser(user *User) scimUser {
+    active := user.Active
+    id := strconv.Itoa(user.ID)
+    return scimUser{
+        Schemas:  []string{scimUserSchema},
+        ID:       id,
+        UserName: user.Username,
+        Active:   &active,
+        Emails:   []scimEmail{{Value: user.Email, Primary: true}},
+        Meta: &scimMeta{
+            ResourceType: "User",
+            Created:      user.CreatedAt,
+            LastModified: user.UpdatedAt,
+            Location:     "/scim/v2/Users/" + id,
+        },
+    }
+}
+
+// primaryEmail returns the primary email address, the first one if none is marked
+func (u scimUser) primaryEmail() string {
+    for _, email := range u.Emails {
+        if email.Primary {
+            return email.Value
+        }
+    }
+    if len(u.Emails) > 0 {
+        return u.Emails[0].Value
+    }
+    return ""
+}
+
+// randomPassword is the password of users provisioned without one, who sign in
+// through their identity provider
+func randomPassword() (string, error) {
+    return randomHex(24)
+}
+
+// parseSCIMBool parses a boolean value, which some identity providers send as a
+// string
+func parseSCIMBool(raw json.RawMessage) (bool, error) {
+    var v bool
+    if err := json.Unmarshal(raw, &v); err == nil {
+        return v, nil
+    }
+    var s string
+    if err := json.Unmarshal(raw, &s); err != nil {
+        return false, ErrSCIMInvalidValue
+    }
+    v, err := strconv.ParseBool(strings.ToLower(s))
+    if err != nil {
+        return false, ErrSCIMInvalidValue
+    }
+    return v, nil
+}
+
+// respondSCIM writes v with the SCIM media type
+func respondSCIM(c *gin.Context, status int, v any) {
+    c.Header("Content-Type", scimContentType)
+    c.JSON(status, v)
+}
+
+// respondSCIMError writes err as a SCIM error and stops the handler chain
+func respondSCIMError(c *gin.Context, err error) {
+    status := errs.Status(err)
+    resp := scimErrorResponse{
+        Schemas: []string{scimErrorSchema},
+        Status:  strconv.Itoa(status),
+        Detail:  errs.ProblemFor(err, "").Detail,
+    }
+    for _, t := range scimTypes {
+        if errors.Is(err, t.err) {
+            resp.ScimType = t.scimType
+            break
+        }
+    }
+    respondSCIM(c, status, resp)
+    c.Abort()
+}
+
+// scimAuthMiddleware lets identity providers in with the SCIM bearer token
+func (app *Application) scimAuthMiddleware() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+        if !ok || app.SCIMToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(app.SCIMToken)) != 1 {
+            c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+            respondSCIMError(c, ErrSCIMUnauthorized)
+            return
+        }
+        c.Next()
+    }
+}
+
+// scimListUsersHandler lists the users a page at a time, with the startIndex and
+// count parameters, or looks one up with a userName filter
+func (app *Application) scimListUsersHandler(c *gin.Context) {
+    var users []User
+    if filter := c.Query("filter"); filter != "" {
+        m := scimFilter.FindStringSubmatch(filter)
+        if m == nil {
+            respondSCIMError(c, ErrSCIMInvalidFilter)
+            return
+        }
+        var username string
+        if err := json.Unmarshal([]byte(m[1]), &username); err != nil {
+            respondSCIMError(c, ErrSCIMInvalidFilter)
+            return
+        }
+        user, err := app.UserSvc.GetByUsername(username)
+        if err != nil && !errors.Is(err, ErrUserNotFound) {
+            respondSCIMError(c, err)
+            return
+        }
+        if user != nil {
+            users = []User{*user}
+        }
+    } else {
+        var err error
+        if users, err = app.UserSvc.List(); err != nil {
+            respondSCIMError(c, err)
+            return
+        }
+    }
+
+    startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+    if err != nil || startIndex < 1 {
+        startIndex = 1
+    }
+    count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimMaxPageSize)))
+    if err != nil || count < 0 || count > scimMaxPageSize {
+        count = scimMaxPageSize
+    }
+
+    page := users[min(startIndex-1, len(users)):]
+    page = page[:min(count, len(page))]
+    resp := scimListResponse{
+        Schemas:      []string{scimListSchema},
+        TotalResults: len(users),
+        StartIndex:   startIndex,
+        ItemsPerPage: len(page),
+        Resources:    make([]scimUser, len(page)),
+    }
+    for i := range page {
+        resp.Resources[i] = toSCIMUser(&page[i])
+    }
+    respondSCIM(c, http.StatusOK, resp)
+}
+
+// scimCreateUserHandler provisions a user. Users are active unless the request
+// says otherwise, and get a random password if it has none.
+func (app *Application) scimCreateUserHandler(c *gin.Context) {
+    var req scimUser
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondSCIMError(c, invalidBody(err))
+        return
+    }
+    if req.UserName == "" {
+        respondSCIMError(c, errs.New(errs.ErrInvalid, "userName is required"))
+        return
+    }
+    email := req.primaryEmail()
+    if email == "" {
+        respondSCIMError(c, ErrSCIMNoEmail)
+        return
+    }
+
+    user := User{Username: req.UserName, Password: req.Password, Email: email, Active: true}
+    if req.Active != nil {
+        user.Active = *req.Active
+    }
+    if user.Password == "" {
+        password, err := randomPassword()
+        if err != nil {
+            respondSCIMError(c, err)
+            return
+        }
+        user.Password = password
+    }
+
+    if err := app.UserSvc.Create(&user); err != nil {
+        respondSCIMError(c, err)
+        return
+    }
+    created, err := app.UserSvc.GetByID(user.ID)
+    if err != nil {
+        respondSCIMError(c, err)
+        return
+    }
+
+    resp := toSCIMUser(created)
+    c.Header("Location", resp.Meta.Location)
+    respondSCIM(c, http.StatusCreated, resp)
+}
+
+func (app *Application) scimGetUserHandler(c *gin.Context) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        respondSCIMError(c, ErrUserNotFound)
+        return
+    }
+
+    user, err := app.UserSvc.GetByID(id)
+    if err != nil {
+        respondSCIMError(c, err)
+        return
+    }
+
+    respondSCIM(c, http.StatusOK, toSCIMUser(user))
+}
+
+// scimPatchUserHandler applies a PatchOp that replaces the active attribute, either
+// with the "active" path or with a value object holding it
+func (app *Application) scimPatchUserHandler(c *gin.Context) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        respondSCIMError(c, ErrUserNotFound)
+        return
+    }
+
+    var req scimPatchRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondSCIMError(c, invalidBody(err))
+        return
+    }
+    if len(req.Operations) == 0 {
+        respondSCIMError(c, errs.New(errs.ErrInvalid, "No operations"))
+        return
+    }
+
+    var active *bool
+    for _, op := range req.Operations {
+        if kind := strings.ToLower(op.Op); kind != "replace" && kind != "add" {
+            respondSCIMError(c, ErrSCIMInvalidPath)
+            return
+        }
+
+        raw := op.Value
+        switch {
+        case strings.EqualFold(op.Path, "active"):
+        case op.Path == "":
+            var values map[string]json.RawMessage
+            if err := json.Unmarshal(op.Value, &values); err != nil {
+                respondSCIMError(c, ErrSCIMInvalidValue)
+                return
+            }
+            var ok bool
+            if raw, ok = values["active"]; !ok || len(values) != 1 {
+                respondSCIMError(c, ErrSCIMInvalidPath)
+                return
+            }
+        default:
+            respondSCIMError(c, ErrSCIMInvalidPath)
+            return
+        }
+
+        v, err := parseSCIMBool(raw)
+        if err != nil {
+            respondSCIMError(c, err)
+            return
+        }
+        active = &v
+    }
+
+    if err := app.UserSvc.SetActive(id, *active); err != nil {
+        respondSCIMError(c, err)
+        return
+    }
+    user, err := app.UserSvc.GetByID(id)
+    if err != nil {
+        respondSCIMError(c, err)
+        return
+    }
+
+    respondSCIM(c, http.StatusOK, toSCIMUser(user))
+}
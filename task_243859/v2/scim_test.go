@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"awesomeProject/platform/testkit"
+)
+
+const testSCIMToken = "scim-test-token"
+
+func scimRequest(method, target string, body any) *http.Request {
+	var req *http.Request
+	if body != nil {
+		req = testkit.JSONRequest(method, target, body)
+		req.Header.Set("Content-Type", scimContentType)
+	} else {
+		req = testkit.NewRequest(method, target, nil)
+	}
+	req.Header.Set("Authorization", "Bearer "+testSCIMToken)
+	return req
+}
+
+func newSCIMUser(userName string, active bool) map[string]any {
+	return map[string]any{
+		"schemas":  []string{scimUserSchema},
+		"userName": userName,
+		"active":   active,
+		"emails":   []map[string]any{{"value": "other-" + userName + "@example.com"}, {"value": userName + "@example.com", "primary": true}},
+	}
+}
+
+func TestSCIMAuth(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+
+	w := testkit.Serve(app.Router, scimRequest("GET", "/scim/v2/Users", nil))
+	if err := testkit.ExpectStatus(w, http.StatusUnauthorized); err != nil {
+		t.Errorf("Expected the API to be closed without a token: %v", err)
+	}
+
+	app.SCIMToken = testSCIMToken
+	req := scimRequest("GET", "/scim/v2/Users", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w = testkit.Serve(app.Router, req)
+	if err := testkit.ExpectStatus(w, http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+	var resp scimErrorResponse
+	if err := testkit.DecodeJSON(w, &resp); err != nil || resp.Status != "401" || resp.Schemas[0] != scimErrorSchema {
+		t.Errorf("Expected a SCIM error, got %+v (%v)", resp, err)
+	}
+
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, scimRequest("GET", "/scim/v2/Users", nil)), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	app.SCIMToken = testSCIMToken
+
+	// Create
+	w := testkit.Serve(app.Router, scimRequest("POST", "/scim/v2/Users", newSCIMUser("bjensen", true)))
+	if err := testkit.ExpectStatus(w, http.StatusCreated); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, scimContentType) {
+		t.Errorf("Expected the SCIM media type, got %s", ct)
+	}
+	var created scimUser
+	if err := testkit.DecodeJSON(w, &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID != "1" || created.Active == nil || !*created.Active || created.Emails[0].Value != "bjensen@example.com" {
+		t.Errorf("Expected the active user with the primary email, got %+v", created)
+	}
+	if w.Header().Get("Location") != "/scim/v2/Users/1" || created.Password != "" {
+		t.Errorf("Expected the location and no password, got %q and %+v", w.Header().Get("Location"), created)
+	}
+
+	w = testkit.Serve(app.Router, scimRequest("POST", "/scim/v2/Users", newSCIMUser("BJensen2", false)))
+	if err := testkit.ExpectStatus(w, http.StatusCreated); err != nil {
+		t.Fatal(err)
+	}
+
+	w = testkit.Serve(app.Router, scimRequest("POST", "/scim/v2/Users", newSCIMUser("bjensen", true)))
+	var conflict scimErrorResponse
+	if err := testkit.DecodeJSON(w, &conflict); err != nil || w.Code != http.StatusConflict || conflict.ScimType != "uniqueness" {
+		t.Errorf("Expected a uniqueness error, got %d %+v (%v)", w.Code, conflict, err)
+	}
+
+	// Filter and pages
+	tests := []struct {
+		query string
+		total int
+		names []string
+	}{
+		{"", 2, []string{"bjensen", "BJensen2"}},
+		{"?startIndex=2&count=5", 2, []string{"BJensen2"}},
+		{"?count=1", 2, []string{"bjensen"}},
+		{"?startIndex=9", 2, nil},
+		{"?filter=" + url.QueryEscape(`userName eq "bjensen"`), 1, []string{"bjensen"}},
+		{"?filter=" + url.QueryEscape(`username EQ "nobody"`), 0, nil},
+	}
+	for _, tt := range tests {
+		w := testkit.Serve(app.Router, scimRequest("GET", "/scim/v2/Users"+tt.query, nil))
+		var list scimListResponse
+		if err := testkit.DecodeJSON(w, &list); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, u := range list.Resources {
+			names = append(names, u.UserName)
+		}
+		if list.TotalResults != tt.total || fmt.Sprint(names) != fmt.Sprint(tt.names) || list.ItemsPerPage != len(tt.names) {
+			t.Errorf("%q: expected %d results and %v, got %+v", tt.query, tt.total, tt.names, list)
+		}
+	}
+
+	w = testkit.Serve(app.Router, scimRequest("GET", "/scim/v2/Users?filter="+url.QueryEscape(`emails co "example"`), nil))
+	var invalid scimErrorResponse
+	if err := testkit.DecodeJSON(w, &invalid); err != nil || w.Code != http.StatusBadRequest || invalid.ScimType != "invalidFilter" {
+		t.Errorf("Expected an invalidFilter error, got %d %+v (%v)", w.Code, invalid, err)
+	}
+}
+
+func TestSCIMPatchActive(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	app.SCIMToken = testSCIMToken
+
+	user := &User{Username: "testuser", Password: "password123", Email: "test@example.com", Active: true}
+	if err := app.UserSvc.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := func(operations ...map[string]any) *http.Request {
+		return scimRequest("PATCH", "/scim/v2/Users/1", map[string]any{
+			"schemas":    []string{"urn:ietf:params:scim:api:messages:2.0:PatchOp"},
+			"Operations": operations,
+		})
+	}
+	tests := []struct {
+		name     string
+		req      *http.Request
+		status   int
+		active   bool
+		scimType string
+	}{
+		{"path", patch(map[string]any{"op": "replace", "path": "active", "value": false}), http.StatusOK, false, ""},
+		{"value object", patch(map[string]any{"op": "replace", "value": map[string]any{"active": true}}), http.StatusOK, true, ""},
+		{"string value", patch(map[string]any{"op": "Replace", "path": "active", "value": "False"}), http.StatusOK, false, ""},
+		{"other path", patch(map[string]any{"op": "replace", "path": "userName", "value": "x"}), http.StatusBadRequest, false, "invalidPath"},
+		{"remove", patch(map[string]any{"op": "remove", "path": "active"}), http.StatusBadRequest, false, "invalidPath"},
+		{"invalid value", patch(map[string]any{"op": "replace", "path": "active", "value": "maybe"}), http.StatusBadRequest, false, "invalidValue"},
+		{"missing user", scimRequest("PATCH", "/scim/v2/Users/9", map[string]any{
+			"Operations": []map[string]any{{"op": "replace", "path": "active", "value": false}},
+		}), http.StatusNotFound, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testkit.Serve(app.Router, tt.req)
+			if err := testkit.ExpectStatus(w, tt.status); err != nil {
+				t.Fatalf("%v: %s", err, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				var resp scimErrorResponse
+				if err := testkit.DecodeJSON(w, &resp); err != nil || resp.ScimType != tt.scimType {
+					t.Errorf("Expected scimType %q, got %+v (%v)", tt.scimType, resp, err)
+				}
+				return
+			}
+			var got scimUser
+			if err := testkit.DecodeJSON(w, &got); err != nil || got.Active == nil || *got.Active != tt.active {
+				t.Errorf("Expected active to be %v, got %+v (%v)", tt.active, got, err)
+			}
+		})
+	}
+
+	if _, err := app.UserSvc.Authenticate("testuser", "password123"); err == nil {
+		t.Error("Expected a deactivated user not to sign in")
+	}
+}
+
+func TestSCIMDeactivateEndsSessions(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	app.SCIMToken = testSCIMToken
+	client := testkit.NewClient(app.Router)
+
+	user := &User{Username: "testuser", Password: "password123", Email: "test@example.com", Active: true}
+	if err := app.UserSvc.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	login := map[string]string{"username": "testuser", "password": "password123"}
+	if err := testkit.ExpectStatus(client.Do(testkit.JSONRequest("POST", "/login", login)), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/users", nil)), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	deactivate := scimRequest("PATCH", fmt.Sprintf("/scim/v2/Users/%d", user.ID), map[string]any{
+		"Operations": []map[string]any{{"op": "replace", "path": "active", "value": false}},
+	})
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, deactivate), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/users", nil)), http.StatusUnauthorized); err != nil {
+		t.Errorf("Expected the session of the deactivated user to end: %v", err)
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	client := testkit.NewClient(app.Router)
+
+	for _, name := range []string{"alice", "bob"} {
+		user := &User{Username: name, Password: "password123", Email: name + "@example.com", Active: true}
+		if err := app.UserSvc.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := testkit.ExpectStatus(client.Do(testkit.NewRequest("GET", "/users/export", nil)), http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+	login := map[string]string{"username": "alice", "password": "password123"}
+	if err := testkit.ExpectStatus(client.Do(testkit.JSONRequest("POST", "/login", login)), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	w := client.Do(testkit.NewRequest("GET", "/users/export", nil))
+	if err := testkit.ExpectStatus(w, http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, "users.json") {
+		t.Errorf("Expected a download, got %q", cd)
+	}
+	var export struct {
+		Count int    `json:"count"`
+		Users []User `json:"users"`
+	}
+	if err := testkit.DecodeJSON(w, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Count != 2 || export.Users[0].Username != "alice" || export.Users[1].Username != "bob" || !export.Users[1].Active {
+		t.Errorf("Expected both active users in order, got %+v", export)
+	}
+	if strings.Contains(w.Body.String(), "password") {
+		t.Errorf("Expected no passwords in the export, got %s", w.Body.String())
+	}
+}
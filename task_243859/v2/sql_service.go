@@ -2,46 +2,41 @@
 package main
 
 import (
+	"awesomeProject/platform/dbutil"
+	"context"
 	"database/sql"
+	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"log/slog"
+	"time"
 )
 
+// slowQuery is how long a query may take before it is logged and counted as slow,
+// set from the db.slow.query setting
+var slowQuery = 200 * time.Millisecond
+
+// queryMetrics aggregates the queries of the SQL services, main publishes it as the
+// db_queries expvar
+var queryMetrics = dbutil.NewQueryMetrics()
+
+// queryHooks log and measure the queries of the SQL services. Query arguments are
+// never logged.
+func queryHooks() []dbutil.QueryHook {
+	return []dbutil.QueryHook{dbutil.LogHook(slog.Default(), slowQuery), queryMetrics.Hook(slowQuery)}
+}
+
 type SQLUserService struct {
-	db *sql.DB
+	db *dbutil.DB
 }
 
 func NewUserService(db *sql.DB) UserService {
 	return &SQLUserService{
-		db: db,
+		db: dbutil.New(db, dbutil.MySQL, queryHooks()...),
 	}
 }
 
 func (s *SQLUserService) Create(user *User) error {
-	// Begin transaction
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Check for duplicate username
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", user.Username).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return ErrDuplicateUsername
-	}
-
-	// Check for duplicate email
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", user.Email).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return ErrDuplicateEmail
-	}
+	ctx := context.Background()
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
@@ -49,34 +44,55 @@ func (s *SQLUserService) Create(user *User) error {
 		return err
 	}
 
-	// Insert user
-	result, err := tx.Exec(`
-        INSERT INTO users (username, password, email, created_at, updated_at)
-        VALUES (?, ?, ?, NOW(), NOW())
-    `, user.Username, hashedPassword, user.Email)
-	if err != nil {
-		return err
-	}
+	// The checks and the insert are retried together when the transaction deadlocks
+	return dbutil.WithTxRetry(ctx, s.db, dbutil.DefaultRetryPolicy, func(tx *dbutil.Tx) error {
+		// Check for duplicate username
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = ?)", user.Username).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateUsername
+		}
 
-	// Get inserted ID
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
-	user.ID = int(id)
+		// Check for duplicate email
+		err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)", user.Email).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateEmail
+		}
 
-	return tx.Commit()
+		// Insert user
+		result, err := tx.ExecContext(ctx, `
+            INSERT INTO users (username, password, email, active, created_at, updated_at)
+            VALUES (?, ?, ?, ?, NOW(), NOW())
+        `, user.Username, hashedPassword, user.Email, user.Active)
+		if err != nil {
+			return err
+		}
+
+		// Get inserted ID
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		user.ID = int(id)
+		return nil
+	})
 }
 
 func (s *SQLUserService) GetByID(id int) (*User, error) {
 	user := &User{}
-	err := s.db.QueryRow(`
-        SELECT id, username, email, created_at, updated_at
+	err := s.db.QueryRowContext(context.Background(), `
+        SELECT id, username, email, active, created_at, updated_at
         FROM users
         WHERE id = ?
-    `, id).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+    `, id).Scan(&user.ID, &user.Username, &user.Email, &user.Active, &user.CreatedAt, &user.UpdatedAt)
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -88,8 +104,8 @@ func (s *SQLUserService) GetByID(id int) (*User, error) {
 
 func (s *SQLUserService) GetByUsername(username string) (*User, error) {
 	user := &User{}
-	err := s.db.QueryRow(`
-        SELECT id, username, password, email, created_at, updated_at
+	err := s.db.QueryRowContext(context.Background(), `
+        SELECT id, username, password, email, active, created_at, updated_at
         FROM users
         WHERE username = ?
     `, username).Scan(
@@ -97,11 +113,12 @@ func (s *SQLUserService) GetByUsername(username string) (*User, error) {
 		&user.Username,
 		&user.Password,
 		&user.Email,
+		&user.Active,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
-	if err == sql.ErrNoRows {
+	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrUserNotFound
 	}
 	if err != nil {
@@ -112,8 +129,8 @@ func (s *SQLUserService) GetByUsername(username string) (*User, error) {
 }
 
 func (s *SQLUserService) List() ([]User, error) {
-	rows, err := s.db.Query(`
-        SELECT id, username, email, created_at, updated_at
+	rows, err := s.db.QueryContext(context.Background(), `
+        SELECT id, username, email, active, created_at, updated_at
         FROM users
         ORDER BY id ASC
     `)
@@ -129,6 +146,7 @@ func (s *SQLUserService) List() ([]User, error) {
 			&user.ID,
 			&user.Username,
 			&user.Email,
+			&user.Active,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -146,79 +164,96 @@ func (s *SQLUserService) List() ([]User, error) {
 }
 
 func (s *SQLUserService) Update(user *User) error {
-	tx, err := s.db.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	ctx := context.Background()
 
-	// Check if user exists
-	var exists bool
-	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", user.ID).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return ErrUserNotFound
+	var hashedPassword []byte
+	if user.Password != "" {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Check for duplicate username (excluding current user)
-	err = tx.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM users 
-            WHERE username = ? AND id != ?
-        )
-    `, user.Username, user.ID).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return ErrDuplicateUsername
-	}
+	return dbutil.WithTxRetry(ctx, s.db, dbutil.DefaultRetryPolicy, func(tx *dbutil.Tx) error {
+		// Check if user exists
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)", user.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUserNotFound
+		}
 
-	// Check for duplicate email (excluding current user)
-	err = tx.QueryRow(`
-        SELECT EXISTS(
-            SELECT 1 FROM users 
-            WHERE email = ? AND id != ?
-        )
-    `, user.Email, user.ID).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return ErrDuplicateEmail
-	}
+		// Check for duplicate username (excluding current user)
+		err = tx.QueryRowContext(ctx, `
+            SELECT EXISTS(
+                SELECT 1 FROM users 
+                WHERE username = ? AND id != ?
+            )
+        `, user.Username, user.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDuplicateUsername
+		}
 
-	if user.Password != "" {
-		// Update with new password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		// Check for duplicate email (excluding current user)
+		err = tx.QueryRowContext(ctx, `
+            SELECT EXISTS(
+                SELECT 1 FROM users 
+                WHERE email = ? AND id != ?
+            )
+        `, user.Email, user.ID).Scan(&exists)
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec(`
-            UPDATE users 
-            SET username = ?, password = ?, email = ?, updated_at = NOW()
-            WHERE id = ?
-        `, user.Username, hashedPassword, user.Email, user.ID)
-	} else {
-		// Update without changing password
-		_, err = tx.Exec(`
-            UPDATE users 
-            SET username = ?, email = ?, updated_at = NOW()
-            WHERE id = ?
-        `, user.Username, user.Email, user.ID)
+		if exists {
+			return ErrDuplicateEmail
+		}
+
+		if hashedPassword != nil {
+			// Update with new password
+			_, err = tx.ExecContext(ctx, `
+                UPDATE users 
+                SET username = ?, password = ?, email = ?, updated_at = NOW()
+                WHERE id = ?
+            `, user.Username, hashedPassword, user.Email, user.ID)
+		} else {
+			// Update without changing password
+			_, err = tx.ExecContext(ctx, `
+                UPDATE users 
+                SET username = ?, email = ?, updated_at = NOW()
+                WHERE id = ?
+            `, user.Username, user.Email, user.ID)
+		}
+		return err
+	})
+}
+
+func (s *SQLUserService) Delete(id int) error {
+	result, err := s.db.ExecContext(context.Background(), "DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
 	}
 
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
 }
 
-func (s *SQLUserService) Delete(id int) error {
-	result, err := s.db.Exec("DELETE FROM users WHERE id = ?", id)
+// SetActive activates or deactivates the user
+func (s *SQLUserService) SetActive(id int, active bool) error {
+	result, err := s.db.ExecContext(context.Background(), "UPDATE users SET active = ?, updated_at = NOW() WHERE id = ?", active, id)
 	if err != nil {
 		return err
 	}
@@ -228,8 +263,11 @@ func (s *SQLUserService) Delete(id int) error {
 		return err
 	}
 
+	// MySQL doesn't count rows that were left as they were, so tell those apart
+	// from missing users
 	if rowsAffected == 0 {
-		return ErrUserNotFound
+		_, err := s.GetByID(id)
+		return err
 	}
 
 	return nil
@@ -242,7 +280,7 @@ func (s *SQLUserService) Authenticate(username, password string) (*User, error)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
+	if err != nil || !user.Active {
 		return nil, ErrInvalidCredentials
 	}
 
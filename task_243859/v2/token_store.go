@@ -0,0 +1,134 @@
+package main
+
+import (
+	"awesomeProject/platform/dbutil"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+type TokenStore interface {
+	// Create stores the token with the hash of its secret
+	Create(token *APIToken, hash string) error
+	List(userID int) ([]APIToken, error)
+	// Lookup returns the token with the hash, expired or not
+	Lookup(hash string) (*APIToken, error)
+	// Touch records that the token was used at t
+	Touch(id int, t time.Time) error
+	// Delete revokes a token of the user
+	Delete(userID, id int) error
+}
+
+type SQLTokenStore struct {
+	db *dbutil.DB
+}
+
+func NewTokenStore(db *sql.DB) TokenStore {
+	return &SQLTokenStore{
+		db: dbutil.New(db, dbutil.MySQL, queryHooks()...),
+	}
+}
+
+func (s *SQLTokenStore) Create(token *APIToken, hash string) error {
+	token.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	result, err := s.db.ExecContext(context.Background(), `
+        INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, token.UserID, token.Name, hash, token.Prefix, strings.Join(token.Scopes, ","), token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = int(id)
+	return nil
+}
+
+func (s *SQLTokenStore) List(userID int) ([]APIToken, error) {
+	rows, err := s.db.QueryContext(context.Background(), `
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+        FROM api_tokens
+        WHERE user_id = ?
+        ORDER BY id ASC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		if err := scanToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (s *SQLTokenStore) Lookup(hash string) (*APIToken, error) {
+	token := &APIToken{}
+	err := scanToken(s.db.QueryRowContext(context.Background(), `
+        SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at
+        FROM api_tokens
+        WHERE token_hash = ?
+    `, hash), token)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (s *SQLTokenStore) Touch(id int, t time.Time) error {
+	_, err := s.db.ExecContext(context.Background(), "UPDATE api_tokens SET last_used_at = ? WHERE id = ?", t.UTC(), id)
+	return err
+}
+
+func (s *SQLTokenStore) Delete(userID, id int) error {
+	result, err := s.db.ExecContext(context.Background(), "DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// scanToken scans a row of the token columns, the scopes are stored comma separated
+func scanToken(row interface{ Scan(dest ...any) error }, token *APIToken) error {
+	var scopes string
+	var lastUsed sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes, &token.ExpiresAt, &lastUsed, &token.CreatedAt)
+	if err != nil {
+		return err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	if lastUsed.Valid {
+		token.LastUsedAt = &lastUsed.Time
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+
+package main
+
+import (
+    "awesomeProject/platform/errs"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+    "github.com/gin-gonic/gin"
+)
+
+// The scopes of the API tokens. Sessions have all of them.
+const (
+    ScopeUsersRead  = "users:read"
+    ScopeUsersWrite = "users:write"
+    ScopeWebhooks   = "webhooks"
+)
+
+var tokenScopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeWebhooks}
+
+const (
+    // tokenPrefix marks the API tokens, so they are recognized in logs and by
+    // secret scanners
+    tokenPrefix = "pat_"
+    // tokenDisplayLength is how much of a token is kept to tell tokens apart
+    tokenDisplayLength = len(tokenPrefix) + 8
+
+    defaultTokenDays = 30
+    maxTokenDays     = 365
+)
+
+// The keys of the gin context values set by authMiddleware
+const (
+    ctxUserID      = "user_id"
+    ctxTokenScopes = "token_scopes"
+)
+
+var (
+    ErrInvalidToken    = errs.New(errs.ErrUnauthorized, "Invalid or expired API token")
+    ErrTokenNotFound   = errs.New(errs.ErrNotFound, "API token not found")
+    ErrSessionRequired = errs.New(errs.ErrForbidden, "API tokens can't manage API tokens, sign in instead")
+)
+
+// APIToken is a personal access token, acting for its user with its scopes until it
+// expires or is revoked. Only the SHA-256 hash of the token is stored, the token
+// itself is shown once when it is created.
+type APIToken struct {
+    ID         int        `json:"id"`
+    UserID     int        `json:"user_id"`
+    Name       string     `json:"name"`
+    Prefix     string     `json:"prefix"`
+    Scopes     []string   `json:"scopes"`
+    ExpiresAt  time.Time  `json:"expires_at"`
+    LastUsedAt *time.Time `json:"last_used_at"`
+    CreatedAt  time.Time  `json:"created_at"`
+}
+
+// hashToken returns the stored form of a token. Tokens are random, so an unsalted
+// fast hash is enough.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the API token of the Authorization header, if it holds one
+func bearerToken(c *gin.Context) (string, bool) {
+    token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+    return token, ok && strings.HasPrefix(token, tokenPrefix)
+}
+
+// authenticateToken returns the stored token, if it is current and its user active
+func (app *Application) authenticateToken(token string) (*APIToken, error) {
+    apiToken, err := app.Tokens.Lookup(hashToken(token))
+    if errors.Is(err, ErrTokenNotFound) {
+        return nil, ErrInvalidToken
+    }
+    if err != nil {
+        return nil, err
+    }
+    if !time.Now().Before(apiToken.ExpiresAt) {
+        return nil, ErrInvalidToken
+    }
+
+    user, err := app.UserSvc.GetByID(apiToken.UserID)
+    if errors.Is(err, ErrUserNotFound) || (err == nil && !user.Active) {
+        return nil, ErrInvalidToken
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    if err := app.Tokens.Touch(apiToken.ID, time.Now()); err != nil {
+        return nil, err
+    }
+    return apiToken, nil
+}
+
+// currentUserID returns the ID of the user the request acts for
+func currentUserID(c *gin.Context) (int, bool) {
+    id, ok := c.Get(ctxUserID)
+    if !ok {
+        return 0, false
+    }
+    userID, ok := id.(int)
+    return userID, ok
+}
+
+// requireScope lets sessions and API tokens with scope through. It runs after
+// authMiddleware.
+func (app *Application) requireScope(scope string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if scopes, ok := c.Get(ctxTokenScopes); ok && !slices.Contains(scopes.([]string), scope) {
+            respondError(c, errs.New(errs.ErrForbidden, "API token lacks the "+scope+" scope"))
+            return
+        }
+        c.Next()
+    }
+}
+
+// sessionOnly keeps API tokens out, so a leaked token can't create more of them
+func (app *Application) sessionOnly() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if _, ok := c.Get(ctxTokenScopes); ok {
+            respondError(c, ErrSessionRequired)
+            return
+        }
+        c.Next()
+    }
+}
+
+// Handlers
+
+// createTokenHandler creates an API token for the signed in user. The response holds
+// the token, which can't be shown again.
+func (app *Application) createTokenHandler(c *gin.Context) {
+    userID, ok := currentUserID(c)
+    if !ok {
+        respondError(c, ErrNotAuthenticated)
+        return
+    }
+
+    var req struct {
+        Name          string   `json:"name" binding:"required,max=255"`
+        Scopes        []string `json:"scopes" binding:"required,min=1"`
+        ExpiresInDays int      `json:"expires_in_days"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        respondError(c, invalidBody(err))
+        return
+    }
+
+    fields := make(map[string]string)
+    for _, scope := range req.Scopes {
+        if !slices.Contains(tokenScopes, scope) {
+            fields["scopes"] = "unknown scope " + strconv.Quote(scope)
+        }
+    }
+    if req.ExpiresInDays == 0 {
+        req.ExpiresInDays = defaultTokenDays
+    }
+    if req.ExpiresInDays < 1 || req.ExpiresInDays > maxTokenDays {
+        fields["expires_in_days"] = "must be between 1 and " + strconv.Itoa(maxTokenDays)
+    }
+    if len(fields) > 0 {
+        respondError(c, errs.Validation(fields))
+        return
+    }
+
+    secret, err := randomHex(32)
+    if err != nil {
+        respondError(c, err)
+        return
+    }
+    token := tokenPrefix + secret
+    scopes := slices.Clone(req.Scopes)
+    slices.Sort(scopes)
+    apiToken := APIToken{
+        UserID:    userID,
+        Name:      req.Name,
+        Prefix:    token[:tokenDisplayLength],
+        Scopes:    slices.Compact(scopes),
+        ExpiresAt: time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour).UTC().Truncate(time.Second),
+    }
+    if err := app.Tokens.Create(&apiToken, hashToken(token)); err != nil {
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to save API token", err))
+        return
+    }
+
+    c.JSON(http.StatusCreated, gin.H{"token": token, "api_token": apiToken})
+}
+
+// listTokensHandler lists the API tokens of the signed in user
+func (app *Application) listTokensHandler(c *gin.Context) {
+    userID, ok := currentUserID(c)
+    if !ok {
+        respondError(c, ErrNotAuthenticated)
+        return
+    }
+
+    tokens, err := app.Tokens.List(userID)
+    if err != nil {
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to fetch API tokens", err))
+        return
+    }
+    if tokens == nil {
+        tokens = []APIToken{}
+    }
+
+    c.JSON(http.StatusOK, tokens)
+}
+
+// revokeTokenHandler deletes an API token of the signed in user
+func (app *Application) revokeTokenHandler(c *gin.Context) {
+    userID, ok := currentUserID(c)
+    if !ok {
+        respondError(c, ErrNotAuthenticated)
+        return
+    }
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        respondError(c, ErrTokenNotFound)
+        return
+    }
+
+    if err := app.Tokens.Delete(userID, id); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    c.Status(http.StatusOK)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"awesomeProject/platform/testkit"
+)
+
+func TestAPITokens(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	app.Tokens = NewMockTokenStore()
+
+	for _, name := range []string{"alice", "bob"} {
+		user := &User{Username: name, Password: "password123", Email: name + "@example.com", Active: true}
+		if err := app.UserSvc.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := testkit.NewClient(app.Router)
+	login := map[string]string{"username": "alice", "password": "password123"}
+	if err := testkit.ExpectStatus(session.Do(testkit.JSONRequest("POST", "/login", login)), http.StatusOK); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]any{"name": "ci", "scopes": []string{"users:admin"}, "expires_in_days": 1000}
+	if err := testkit.ExpectStatus(session.Do(testkit.JSONRequest("POST", "/tokens", invalid)), http.StatusUnprocessableEntity); err != nil {
+		t.Error(err)
+	}
+
+	w := session.Do(testkit.JSONRequest("POST", "/tokens", map[string]any{"name": "ci", "scopes": []string{ScopeUsersRead, ScopeUsersRead}}))
+	var created struct {
+		Token    string   `json:"token"`
+		APIToken APIToken `json:"api_token"`
+	}
+	if err := testkit.DecodeJSON(w, &created); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("Expected the token, got %d %s (%v)", w.Code, w.Body.String(), err)
+	}
+	if !strings.HasPrefix(created.Token, created.APIToken.Prefix) || len(created.APIToken.Scopes) != 1 || created.APIToken.UserID != 1 {
+		t.Errorf("Expected the prefix of the token and its scope, got %+v", created)
+	}
+	if days := time.Until(created.APIToken.ExpiresAt).Hours() / 24; days < 29 || days > 30 {
+		t.Errorf("Expected the token to expire in 30 days, got %v", created.APIToken.ExpiresAt)
+	}
+
+	withToken := func(method, target, token string) *http.Request {
+		req := testkit.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"read", withToken("GET", "/users/2", created.Token), http.StatusOK},
+		{"write without scope", withToken("DELETE", "/users/2", created.Token), http.StatusForbidden},
+		{"webhooks without scope", withToken("GET", "/webhooks", created.Token), http.StatusForbidden},
+		{"tokens", withToken("GET", "/tokens", created.Token), http.StatusForbidden},
+		{"unknown token", withToken("GET", "/users/2", "pat_0123"), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testkit.Serve(app.Router, tt.req)
+			if err := testkit.ExpectStatus(w, tt.status); err != nil {
+				t.Errorf("%v: %s", err, w.Body.String())
+			}
+		})
+	}
+
+	var tokens []APIToken
+	if err := testkit.DecodeJSON(session.Do(testkit.NewRequest("GET", "/tokens", nil)), &tokens); err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("Expected the used token, got %+v (%v)", tokens, err)
+	}
+	if strings.Contains(session.Do(testkit.NewRequest("GET", "/tokens", nil)).Body.String(), created.Token) {
+		t.Error("Expected the token not to be listed")
+	}
+
+	// Other users can't revoke it, and it stops working once revoked
+	other := &APIToken{UserID: 2, Name: "bob's", Scopes: []string{ScopeUsersRead}, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := app.Tokens.Create(other, hashToken("pat_bob")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.ExpectStatus(session.Do(testkit.NewRequest("DELETE", "/tokens/2", nil)), http.StatusNotFound); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(session.Do(testkit.NewRequest("DELETE", "/tokens/1", nil)), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, withToken("GET", "/users/2", created.Token)), http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+
+	// Tokens of deactivated users and expired tokens are refused
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, withToken("GET", "/users/1", "pat_bob")), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := app.UserSvc.SetActive(2, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, withToken("GET", "/users/1", "pat_bob")), http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+	expired := &APIToken{UserID: 1, Name: "old", Scopes: []string{ScopeUsersRead}, ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := app.Tokens.Create(expired, hashToken("pat_old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := testkit.ExpectStatus(testkit.Serve(app.Router, withToken("GET", "/users/1", "pat_old")), http.StatusUnauthorized); err != nil {
+		t.Error(err)
+	}
+}
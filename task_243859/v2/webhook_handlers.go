@@ -0,0 +1,102 @@
+
+package main
+
+import (
+    "awesomeProject/platform/errs"
+    "net/http"
+    "net/url"
+    "slices"
+    "strconv"
+    "github.com/gin-gonic/gin"
+)
+
+// createWebhookHandler registers a webhook. Without a secret one is generated, the
+// response is the only time it is shown.
+func (app *Application) createWebhookHandler(c *gin.Context) {
+    var hook Webhook
+    if err := c.ShouldBindJSON(&hook); err != nil {
+        respondError(c, invalidBody(err))
+        return
+    }
+
+    fields := make(map[string]string)
+    if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+        fields["url"] = "must be an http or https URL"
+    }
+    for _, event := range hook.Events {
+        if !slices.Contains(webhookEvents, event) {
+            fields["events"] = "unknown event " + strconv.Quote(event)
+        }
+    }
+    if len(fields) > 0 {
+        respondError(c, errs.Validation(fields))
+        return
+    }
+
+    if hook.Secret == "" {
+        secret, err := randomHex(32)
+        if err != nil {
+            respondError(c, err)
+            return
+        }
+        hook.Secret = secret
+    }
+    if err := app.Webhooks.Store.Create(&hook); err != nil {
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to save webhook", err))
+        return
+    }
+
+    c.JSON(http.StatusCreated, hook)
+}
+
+func (app *Application) listWebhooksHandler(c *gin.Context) {
+    hooks, err := app.Webhooks.Store.List()
+    if err != nil {
+        respondError(c, errs.Wrap(errs.ErrInternal, "Failed to fetch webhooks", err))
+        return
+    }
+    for i := range hooks {
+        hooks[i].Secret = ""
+    }
+    if hooks == nil {
+        hooks = []Webhook{}
+    }
+
+    c.JSON(http.StatusOK, hooks)
+}
+
+func (app *Application) deleteWebhookHandler(c *gin.Context) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        respondError(c, ErrWebhookNotFound)
+        return
+    }
+
+    if err := app.Webhooks.Store.Delete(id); err != nil {
+        respondError(c, err)
+        return
+    }
+    app.Webhooks.forget(id)
+
+    c.Status(http.StatusOK)
+}
+
+// webhookDeliveriesHandler shows the recent delivery attempts of a webhook, latest
+// first, with the payloads and the responses of the receiver
+func (app *Application) webhookDeliveriesHandler(c *gin.Context) {
+    id, err := strconv.Atoi(c.Param("id"))
+    if err != nil {
+        respondError(c, ErrWebhookNotFound)
+        return
+    }
+    if _, err := app.Webhooks.Store.Get(id); err != nil {
+        respondError(c, err)
+        return
+    }
+
+    deliveries := app.Webhooks.Deliveries(id)
+    if deliveries == nil {
+        deliveries = []WebhookDelivery{}
+    }
+    c.JSON(http.StatusOK, deliveries)
+}
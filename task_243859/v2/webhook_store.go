@@ -0,0 +1,117 @@
+package main
+
+import (
+	"awesomeProject/platform/dbutil"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+type WebhookStore interface {
+	Create(hook *Webhook) error
+	List() ([]Webhook, error)
+	Get(id int) (*Webhook, error)
+	Delete(id int) error
+}
+
+type SQLWebhookStore struct {
+	db *dbutil.DB
+}
+
+func NewWebhookStore(db *sql.DB) WebhookStore {
+	return &SQLWebhookStore{
+		db: dbutil.New(db, dbutil.MySQL, queryHooks()...),
+	}
+}
+
+func (s *SQLWebhookStore) Create(hook *Webhook) error {
+	result, err := s.db.ExecContext(context.Background(), `
+        INSERT INTO webhooks (url, secret, events, created_at)
+        VALUES (?, ?, ?, NOW())
+    `, hook.URL, hook.Secret, strings.Join(hook.Events, ","))
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	hook.ID = int(id)
+	return nil
+}
+
+func (s *SQLWebhookStore) List() ([]Webhook, error) {
+	rows, err := s.db.QueryContext(context.Background(), `
+        SELECT id, url, secret, events, created_at
+        FROM webhooks
+        ORDER BY id ASC
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var hook Webhook
+		if err := scanWebhook(rows, &hook); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+func (s *SQLWebhookStore) Get(id int) (*Webhook, error) {
+	hook := &Webhook{}
+	err := scanWebhook(s.db.QueryRowContext(context.Background(), `
+        SELECT id, url, secret, events, created_at
+        FROM webhooks
+        WHERE id = ?
+    `, id), hook)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (s *SQLWebhookStore) Delete(id int) error {
+	result, err := s.db.ExecContext(context.Background(), "DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// scanWebhook scans a row of the webhook columns, the events are stored comma
+// separated
+func scanWebhook(row interface{ Scan(dest ...any) error }, hook *Webhook) error {
+	var events string
+	if err := row.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.CreatedAt); err != nil {
+		return err
+	}
+	hook.Events = strings.Split(events, ",")
+	return nil
+}
@@ -0,0 +1,387 @@
+
+package main
+
+import (
+    "awesomeProject/platform/dbutil"
+    "awesomeProject/platform/errs"
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log/slog"
+    "net"
+    "net/http"
+    "slices"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// The user lifecycle events webhooks subscribe to
+const (
+    EventUserCreated = "user.created"
+    EventUserUpdated = "user.updated"
+    EventUserDeleted = "user.deleted"
+)
+
+var webhookEvents = []string{EventUserCreated, EventUserUpdated, EventUserDeleted}
+
+// Headers of the webhook requests. The signature is t=<unix time>,v1=<hex HMAC-SHA256
+// of "<unix time>.<body>" keyed with the webhook secret>, so receivers can check
+// where the request comes from and reject replays.
+const (
+    webhookEventHeader     = "X-Webhook-Event"
+    webhookDeliveryHeader  = "X-Webhook-Delivery"
+    webhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+    // maxWebhookDeliveries is how many attempts the delivery log keeps per webhook
+    maxWebhookDeliveries = 100
+    // maxLoggedResponse bounds the response bodies in the delivery log
+    maxLoggedResponse = 1024
+    webhookQueueSize  = 256
+    webhookWorkers    = 4
+)
+
+var ErrWebhookNotFound = errs.New(errs.ErrNotFound, "Webhook not found")
+
+// Webhook is an endpoint notified of the events it subscribed to. The secret is
+// only shown when the webhook is registered.
+type Webhook struct {
+    ID        int       `json:"id"`
+    URL       string    `json:"url" binding:"required,url"`
+    Secret    string    `json:"secret,omitempty"`
+    Events    []string  `json:"events" binding:"required,min=1"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one attempt to deliver an event, kept in the delivery log
+type WebhookDelivery struct {
+    ID         string          `json:"id"`
+    Event      string          `json:"event"`
+    Attempt    int             `json:"attempt"`
+    StatusCode int             `json:"status_code,omitempty"`
+    Error      string          `json:"error,omitempty"`
+    Response   string          `json:"response,omitempty"`
+    DurationMS int64           `json:"duration_ms"`
+    Payload    json.RawMessage `json:"payload"`
+    At         time.Time       `json:"at"`
+}
+
+// webhookPayload is the body of the webhook requests
+type webhookPayload struct {
+    ID        string    `json:"id"`
+    Event     string    `json:"event"`
+    CreatedAt time.Time `json:"created_at"`
+    Data      any       `json:"data"`
+}
+
+type webhookJob struct {
+    hook  Webhook
+    id    string
+    event string
+    body  []byte
+    // attempt is the number of the attempt to make, backoff the wait before the
+    // retry after it
+    attempt int
+    backoff time.Duration
+}
+
+// statusError is a delivery the receiver didn't accept
+type statusError struct {
+    code int
+}
+
+func (e *statusError) Error() string {
+    return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// retryableDelivery retries network errors, server errors and rate limits. Other
+// statuses mean the receiver rejected the event, which won't change.
+func retryableDelivery(err error) bool {
+    var se *statusError
+    if errors.As(err, &se) {
+        return se.code >= 500 || se.code == http.StatusTooManyRequests
+    }
+    var ne net.Error
+    return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// DefaultWebhookRetry tries a delivery six times over about half an hour
+var DefaultWebhookRetry = dbutil.RetryPolicy{
+    Attempts:   6,
+    Backoff:    time.Minute,
+    MaxBackoff: 15 * time.Minute,
+    Retryable:  retryableDelivery,
+}
+
+// WebhookDispatcher delivers the events to the webhooks subscribed to them. Events
+// are queued by Publish and sent by the workers of Run, so a slow receiver doesn't
+// hold up the requests. Retries wait on timers and are handed back to the workers
+// when they are due, so failing receivers don't hold up the others.
+type WebhookDispatcher struct {
+    Store  WebhookStore
+    Client *http.Client
+    Retry  dbutil.RetryPolicy
+    Logger *slog.Logger
+
+    queue chan webhookJob
+    // retries are the deliveries due for another attempt. They don't go through the
+    // queue so they can't crowd out new events.
+    retries chan webhookJob
+
+    mu         sync.Mutex
+    deliveries map[int][]WebhookDelivery
+}
+
+func NewWebhookDispatcher(store WebhookStore) *WebhookDispatcher {
+    return &WebhookDispatcher{
+        Store:      store,
+        Client:     &http.Client{Timeout: 10 * time.Second},
+        Retry:      DefaultWebhookRetry,
+        Logger:     slog.Default(),
+        queue:      make(chan webhookJob, webhookQueueSize),
+        retries:    make(chan webhookJob),
+        deliveries: make(map[int][]WebhookDelivery),
+    }
+}
+
+// Publish queues event with data for the webhooks subscribed to it. Events that
+// don't fit in the queue are dropped and show up in the delivery log.
+func (d *WebhookDispatcher) Publish(event string, data any) {
+    hooks, err := d.Store.List()
+    if err != nil {
+        d.Logger.Error("listing webhooks", "event", event, "error", err)
+        return
+    }
+
+    for _, hook := range hooks {
+        if !slices.Contains(hook.Events, event) {
+            continue
+        }
+        id, err := randomHex(16)
+        if err != nil {
+            d.Logger.Error("creating delivery ID", "error", err)
+            return
+        }
+        body, err := json.Marshal(webhookPayload{ID: id, Event: event, CreatedAt: time.Now().UTC(), Data: data})
+        if err != nil {
+            d.Logger.Error("encoding webhook payload", "event", event, "error", err)
+            return
+        }
+
+        job := webhookJob{hook: hook, id: id, event: event, body: body, attempt: 1, backoff: d.Retry.Backoff}
+        select {
+        case d.queue <- job:
+        default:
+            d.logDelivery(hook.ID, WebhookDelivery{ID: id, Event: event, Error: "queue full, event dropped", Payload: body, At: time.Now()})
+            d.Logger.Warn("webhook queue full, event dropped", "webhook_id", hook.ID, "event", event)
+        }
+    }
+}
+
+// Run delivers the queued events until ctx is done. Deliveries still waiting for a
+// retry then give up.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+    var wg sync.WaitGroup
+    for i := 0; i < webhookWorkers; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                select {
+                case <-ctx.Done():
+                    return
+                case job := <-d.queue:
+                    d.deliver(ctx, job)
+                case job := <-d.retries:
+                    d.deliver(ctx, job)
+                }
+            }
+        }()
+    }
+    wg.Wait()
+}
+
+// deliver makes an attempt to send job and logs it. A failed attempt the retry
+// policy allows is scheduled for later.
+func (d *WebhookDispatcher) deliver(ctx context.Context, job webhookJob) {
+    start := time.Now()
+    status, response, err := d.send(ctx, job)
+    d.logDelivery(job.hook.ID, WebhookDelivery{
+        ID:         job.id,
+        Event:      job.event,
+        Attempt:    job.attempt,
+        StatusCode: status,
+        Error:      errorString(err),
+        Response:   response,
+        DurationMS: time.Since(start).Milliseconds(),
+        Payload:    job.body,
+        At:         start,
+    })
+    if err == nil {
+        return
+    }
+
+    retryable := d.Retry.Retryable
+    if retryable == nil {
+        retryable = retryableDelivery
+    }
+    if job.attempt >= d.Retry.Attempts || !retryable(err) || ctx.Err() != nil {
+        d.Logger.Warn("webhook delivery failed", "webhook_id", job.hook.ID, "event", job.event, "delivery", job.id, "attempts", job.attempt, "error", err)
+        return
+    }
+    d.scheduleRetry(ctx, job)
+}
+
+// scheduleRetry hands job back to the workers once its backoff has passed, doubling
+// the backoff up to the MaxBackoff of the retry policy. Retries still waiting when
+// ctx is done give up.
+func (d *WebhookDispatcher) scheduleRetry(ctx context.Context, job webhookJob) {
+    delay := job.backoff
+    job.attempt++
+    job.backoff *= 2
+    if d.Retry.MaxBackoff > 0 && job.backoff > d.Retry.MaxBackoff {
+        job.backoff = d.Retry.MaxBackoff
+    }
+
+    time.AfterFunc(delay, func() {
+        select {
+        case d.retries <- job:
+        case <-ctx.Done():
+            d.Logger.Warn("webhook delivery abandoned", "webhook_id", job.hook.ID, "event", job.event, "delivery", job.id, "attempts", job.attempt-1)
+        }
+    })
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, job webhookJob) (int, string, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.hook.URL, bytes.NewReader(job.body))
+    if err != nil {
+        return 0, "", err
+    }
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set(webhookEventHeader, job.event)
+    req.Header.Set(webhookDeliveryHeader, job.id)
+    req.Header.Set(webhookSignatureHeader, signWebhook(job.hook.Secret, time.Now(), job.body))
+
+    resp, err := d.Client.Do(req)
+    if err != nil {
+        return 0, "", err
+    }
+    defer resp.Body.Close()
+    response, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+    if resp.StatusCode < 200 || resp.StatusCode > 299 {
+        return resp.StatusCode, string(response), &statusError{code: resp.StatusCode}
+    }
+    return resp.StatusCode, string(response), nil
+}
+
+// signWebhook returns the signature header of body sent at t
+func signWebhook(secret string, t time.Time, body []byte) string {
+    ts := strconv.FormatInt(t.Unix(), 10)
+    mac := hmac.New(sha256.New, []byte(secret))
+    mac.Write([]byte(ts + "."))
+    mac.Write(body)
+    return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) logDelivery(hookID int, delivery WebhookDelivery) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    log := append(d.deliveries[hookID], delivery)
+    if len(log) > maxWebhookDeliveries {
+        log = log[len(log)-maxWebhookDeliveries:]
+    }
+    d.deliveries[hookID] = log
+}
+
+// Deliveries returns the delivery log of the webhook, latest attempt first
+func (d *WebhookDispatcher) Deliveries(hookID int) []WebhookDelivery {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    log := slices.Clone(d.deliveries[hookID])
+    slices.Reverse(log)
+    return log
+}
+
+// forget drops the delivery log of a deleted webhook
+func (d *WebhookDispatcher) forget(hookID int) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    delete(d.deliveries, hookID)
+}
+
+func randomHex(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
+
+func errorString(err error) string {
+    if err == nil {
+        return ""
+    }
+    return err.Error()
+}
+
+// webhookUserService publishes the changes made through the service it wraps
+type webhookUserService struct {
+    UserService
+    hooks *WebhookDispatcher
+}
+
+func newWebhookUserService(svc UserService, hooks *WebhookDispatcher) UserService {
+    return &webhookUserService{UserService: svc, hooks: hooks}
+}
+
+func (s *webhookUserService) Create(user *User) error {
+    if err := s.UserService.Create(user); err != nil {
+        return err
+    }
+    s.publishUser(EventUserCreated, user.ID)
+    return nil
+}
+
+func (s *webhookUserService) Update(user *User) error {
+    if err := s.UserService.Update(user); err != nil {
+        return err
+    }
+    s.publishUser(EventUserUpdated, user.ID)
+    return nil
+}
+
+func (s *webhookUserService) SetActive(id int, active bool) error {
+    if err := s.UserService.SetActive(id, active); err != nil {
+        return err
+    }
+    s.publishUser(EventUserUpdated, id)
+    return nil
+}
+
+func (s *webhookUserService) Delete(id int) error {
+    if err := s.UserService.Delete(id); err != nil {
+        return err
+    }
+    s.hooks.Publish(EventUserDeleted, map[string]int{"id": id})
+    return nil
+}
+
+// publishUser publishes the user as stored, without the password
+func (s *webhookUserService) publishUser(event string, id int) {
+    user, err := s.UserService.GetByID(id)
+    if err != nil {
+        s.hooks.Logger.Error("loading user for webhooks", "event", event, "user_id", id, "error", err)
+        return
+    }
+    user.Password = ""
+    s.hooks.Publish(event, user)
+}
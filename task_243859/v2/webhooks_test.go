@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"awesomeProject/platform/dbutil"
+	"awesomeProject/platform/testkit"
+)
+
+// setupWebhooks gives app a dispatcher with an in-memory store and fast retries,
+// running until the test ends
+func setupWebhooks(t *testing.T, app *Application) *WebhookDispatcher {
+	hooks := NewWebhookDispatcher(NewMockWebhookStore())
+	hooks.Retry = dbutil.RetryPolicy{Attempts: 3, Backoff: time.Millisecond, Retryable: retryableDelivery}
+	app.Webhooks = hooks
+	app.UserSvc = newWebhookUserService(app.UserSvc, hooks)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hooks.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return hooks
+}
+
+// waitForDeliveries waits until the delivery log of the webhook has n attempts
+func waitForDeliveries(t *testing.T, hooks *WebhookDispatcher, id, n int) []WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		deliveries := hooks.Deliveries(id)
+		if len(deliveries) >= n {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d delivery attempts, got %+v", n, deliveries)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	hooks := setupWebhooks(t, app)
+
+	var calls atomic.Int32
+	var received atomic.Value
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _, _ := strings.Cut(strings.TrimPrefix(r.Header.Get(webhookSignatureHeader), "t="), ",")
+		unix, _ := strconv.ParseInt(ts, 10, 64)
+		want := signWebhook("s3cret", time.Unix(unix, 0), body)
+		if r.Header.Get(webhookEventHeader) == "" || !hmac.Equal([]byte(r.Header.Get(webhookSignatureHeader)), []byte(want)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+		if calls.Add(1) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		received.Store(body)
+		io.WriteString(w, "thanks")
+	}))
+	defer receiver.Close()
+
+	hook := &Webhook{URL: receiver.URL, Secret: "s3cret", Events: []string{EventUserCreated, EventUserDeleted}}
+	if err := hooks.Store.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{Username: "alice", Password: "password123", Email: "alice@example.com", Active: true}
+	if err := app.UserSvc.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	deliveries := waitForDeliveries(t, hooks, hook.ID, 3)
+	if deliveries[0].Attempt != 3 || deliveries[0].StatusCode != http.StatusOK || deliveries[0].Response != "thanks" {
+		t.Errorf("Expected the third attempt to succeed, got %+v", deliveries[0])
+	}
+	if deliveries[2].StatusCode != http.StatusServiceUnavailable || deliveries[2].Error == "" || deliveries[0].ID != deliveries[2].ID {
+		t.Errorf("Expected the first attempt of the same delivery to fail, got %+v", deliveries[2])
+	}
+
+	var payload struct {
+		Event string `json:"event"`
+		Data  User   `json:"data"`
+	}
+	if err := json.Unmarshal(received.Load().([]byte), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != EventUserCreated || payload.Data.Username != "alice" || payload.Data.Password != "" {
+		t.Errorf("Expected the created user without the password, got %+v", payload)
+	}
+
+	// Not subscribed to updates
+	user.Email = "alice@example.org"
+	if err := app.UserSvc.Update(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.UserSvc.Delete(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	deliveries = waitForDeliveries(t, hooks, hook.ID, 4)
+	if deliveries[0].Event != EventUserDeleted || len(deliveries) != 4 {
+		t.Errorf("Expected only the deletion to be delivered, got %+v", deliveries)
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	hooks := setupWebhooks(t, app)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	hook := &Webhook{URL: receiver.URL, Secret: "s3cret", Events: []string{EventUserCreated}}
+	if err := hooks.Store.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	hooks.Publish(EventUserCreated, map[string]int{"id": 1})
+
+	deliveries := waitForDeliveries(t, hooks, hook.ID, 1)
+	time.Sleep(20 * time.Millisecond)
+	if deliveries = hooks.Deliveries(hook.ID); len(deliveries) != 1 || deliveries[0].StatusCode != http.StatusGone {
+		t.Errorf("Expected a rejected delivery not to be retried, got %+v", deliveries)
+	}
+}
+
+func TestWebhookRetriesDontBlockWorkers(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	hooks := setupWebhooks(t, app)
+	hooks.Retry.Backoff = time.Hour
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	// More failing webhooks than workers, all queued before the healthy one
+	var failingIDs []int
+	for i := 0; i < webhookWorkers+1; i++ {
+		hook := &Webhook{URL: failing.URL, Secret: "s3cret", Events: []string{EventUserCreated}}
+		if err := hooks.Store.Create(hook); err != nil {
+			t.Fatal(err)
+		}
+		failingIDs = append(failingIDs, hook.ID)
+	}
+	hook := &Webhook{URL: healthy.URL, Secret: "s3cret", Events: []string{EventUserCreated}}
+	if err := hooks.Store.Create(hook); err != nil {
+		t.Fatal(err)
+	}
+	hooks.Publish(EventUserCreated, map[string]int{"id": 1})
+
+	if deliveries := waitForDeliveries(t, hooks, hook.ID, 1); deliveries[0].StatusCode != http.StatusOK {
+		t.Errorf("Expected the healthy webhook to get the event, got %+v", deliveries)
+	}
+	for _, id := range failingIDs {
+		if deliveries := waitForDeliveries(t, hooks, id, 1); len(deliveries) != 1 {
+			t.Errorf("Expected the retry to wait for its backoff, got %+v", deliveries)
+		}
+	}
+}
+
+func TestWebhookAdmin(t *testing.T) {
+	app, server := setupTestApp(t)
+	defer server.Close()
+	hooks := setupWebhooks(t, app)
+	app.Admins = []string{"admin"}
+
+	for _, name := range []string{"admin", "bob"} {
+		user := &User{Username: name, Password: "password123", Email: name + "@example.com", Active: true}
+		if err := app.UserSvc.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	signIn := func(username string) *testkit.Client {
+		client := testkit.NewClient(app.Router)
+		login := map[string]string{"username": username, "password": "password123"}
+		if err := testkit.ExpectStatus(client.Do(testkit.JSONRequest("POST", "/login", login)), http.StatusOK); err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	hook := map[string]any{"url": "https://example.com/hooks", "events": []string{EventUserCreated}}
+	if err := testkit.ExpectStatus(signIn("bob").Do(testkit.JSONRequest("POST", "/webhooks", hook)), http.StatusForbidden); err != nil {
+		t.Error(err)
+	}
+
+	admin := signIn("admin")
+	invalid := map[string]any{"url": "ftp://example.com/hooks", "events": []string{"user.renamed"}}
+	if err := testkit.ExpectStatus(admin.Do(testkit.JSONRequest("POST", "/webhooks", invalid)), http.StatusUnprocessableEntity); err != nil {
+		t.Error(err)
+	}
+
+	w := admin.Do(testkit.JSONRequest("POST", "/webhooks", hook))
+	var created Webhook
+	if err := testkit.DecodeJSON(w, &created); err != nil || w.Code != http.StatusCreated || created.ID != 1 || len(created.Secret) != 64 {
+		t.Fatalf("Expected the webhook with a generated secret, got %d %+v (%v)", w.Code, created, err)
+	}
+
+	w = admin.Do(testkit.NewRequest("GET", "/webhooks", nil))
+	if strings.Contains(w.Body.String(), created.Secret) || !strings.Contains(w.Body.String(), "https://example.com/hooks") {
+		t.Errorf("Expected the webhooks without their secrets, got %s", w.Body.String())
+	}
+
+	hooks.logDelivery(created.ID, WebhookDelivery{ID: "d1", Event: EventUserCreated, Attempt: 1, StatusCode: 500})
+	hooks.logDelivery(created.ID, WebhookDelivery{ID: "d1", Event: EventUserCreated, Attempt: 2, StatusCode: 200})
+	var deliveries []WebhookDelivery
+	if err := testkit.DecodeJSON(admin.Do(testkit.NewRequest("GET", "/webhooks/1/deliveries", nil)), &deliveries); err != nil || len(deliveries) != 2 || deliveries[0].Attempt != 2 {
+		t.Errorf("Expected the latest attempt first, got %+v (%v)", deliveries, err)
+	}
+
+	if err := testkit.ExpectStatus(admin.Do(testkit.NewRequest("DELETE", "/webhooks/1", nil)), http.StatusOK); err != nil {
+		t.Error(err)
+	}
+	if err := testkit.ExpectStatus(admin.Do(testkit.NewRequest("GET", "/webhooks/1/deliveries", nil)), http.StatusNotFound); err != nil {
+		t.Error(err)
+	}
+}